@@ -56,7 +56,7 @@ export class AuthService {
     }).join(',');
 
     const landingUrl = document.location.protocol + '//' + document.location.hostname + ':' + document.location.port + '/oauth-landing';
-    window.open(environment.url_auth + '?+?t=' + new Date().getTime() + '&redirect=' + landingUrl, 'oauth_window', settings);
+    window.open(environment.url_auth + '?+?t=' + new Date().getTime() + '&redirect=' + landingUrl + '&client_id=' + environment.oauth_client_id, 'oauth_window', settings);
 
     const localStorageTimer = setInterval(() => this.waitForLocalStorageToken(localStorageTimer, callback), 250);
   }
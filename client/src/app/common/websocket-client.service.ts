@@ -1,6 +1,6 @@
 import { EventEmitter, Injectable } from '@angular/core';
 import { environment } from '../../environments/environment';
-import { WebsocketMessage } from './websocket-message';
+import { WebsocketClientMessage, WebsocketMessage, WebsocketMessageType } from './websocket-message';
 import { WebSocketSubject } from 'rxjs/webSocket';
 import { delay, retryWhen, tap } from 'rxjs/operators';
 
@@ -10,6 +10,9 @@ import { delay, retryWhen, tap } from 'rxjs/operators';
 export class WebsocketClientService {
   public messageReceived: EventEmitter<WebsocketMessage> = new EventEmitter<WebsocketMessage>();
 
+  // ID of the last received event, used to get missed events after a reconnect
+  private lastEventId: number;
+
   constructor() {
     this.connect();
   }
@@ -20,8 +23,18 @@ export class WebsocketClientService {
       return;
     }
 
-    const ws = new WebSocketSubject<WebsocketMessage[]>({
-      url: environment.url_updates + '?token=' + encodeURIComponent(authToken)
+    const ws = new WebSocketSubject<any>({
+      url: environment.url_updates,
+      openObserver: {
+        next: () => {
+          // Authenticate via message instead of an URL parameter, so that the token doesn't end up in any logs
+          ws.next(new WebsocketClientMessage('auth', authToken));
+
+          if (!!this.lastEventId) {
+            ws.next(new WebsocketClientMessage('resume', undefined, undefined, this.lastEventId));
+          }
+        }
+      }
     });
 
     ws
@@ -38,6 +51,16 @@ export class WebsocketClientService {
       )
       .subscribe((messages: WebsocketMessage[]) => {
         for (const msg of messages) {
+          if (!!msg.id) {
+            this.lastEventId = msg.id;
+          }
+
+          if (msg.type === WebsocketMessageType.MessageType_ResyncRequired) {
+            console.warn('Missed updates while being disconnected, reload the page to get the latest data');
+          } else if (msg.type === WebsocketMessageType.MessageType_Error) {
+            console.error('WebSocket error: ' + msg.data);
+          }
+
           this.messageReceived.emit(msg);
         }
       }, err => console.error(err));
@@ -1,7 +1,19 @@
 export class WebsocketMessage {
   constructor(
     public type: string,
-    public data: any
+    public data: any,
+    public id?: number,
+    public projectId?: string
+  ) {
+  }
+}
+
+export class WebsocketClientMessage {
+  constructor(
+    public type: string,
+    public token?: string,
+    public projectId?: string,
+    public lastEventId?: number
   ) {
   }
 }
@@ -11,4 +23,9 @@ export enum WebsocketMessageType {
   MessageType_ProjectUpdated = 'project_updated',
   MessageType_ProjectDeleted = 'project_deleted',
   MessageType_ProjectUserRemoved = 'project_user_removed',
+  MessageType_Authenticated = 'authenticated',
+  MessageType_Subscribed = 'subscribed',
+  MessageType_Unsubscribed = 'unsubscribed',
+  MessageType_ResyncRequired = 'resync_required',
+  MessageType_Error = 'error',
 }
@@ -119,6 +119,23 @@ describe('ProjectCreationComponent', () => {
     expect(component.selectInteraction.getActive()).toEqual(false);
   });
 
+  it('should draw exclusions on "Draw" tab', () => {
+    component.drawExclusions = true;
+    component.onDrawExclusionsChanged();
+
+    expect(component.drawInteraction.getActive()).toEqual(false);
+    expect(component.exclusionDrawInteraction.getActive()).toEqual(true);
+
+    component.onTabSelected(3);
+
+    expect(component.exclusionDrawInteraction.getActive()).toEqual(false);
+
+    component.onTabSelected(0);
+
+    expect(component.drawInteraction.getActive()).toEqual(false);
+    expect(component.exclusionDrawInteraction.getActive()).toEqual(true);
+  });
+
   it('should set interaction for "Upload" tab', () => {
     component.onTabSelected(1);
 
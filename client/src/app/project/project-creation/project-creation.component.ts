@@ -33,12 +33,15 @@ export class ProjectCreationComponent implements OnInit, AfterViewInit {
   public gridCellSize: number;
   public gridCellShape: string;
   public lastDrawnPolygon: Feature;
+  public drawExclusions: boolean;
 
   // public for tests
   public modifyInteraction: Modify;
   public drawInteraction: Draw;
   public selectInteraction: Select;
+  public exclusionDrawInteraction: Draw;
   public vectorSource: VectorSource;
+  public exclusionSource: VectorSource; // Areas (e.g. lakes) the divider doesn't create shapes in
 
   private map: Map;
 
@@ -55,6 +58,7 @@ export class ProjectCreationComponent implements OnInit, AfterViewInit {
     this.newMaxProcessPoints = 100;
     this.gridCellShape = 'squareGrid';
     this.gridCellSize = 1000;
+    this.drawExclusions = false;
   }
 
   ngAfterViewInit(): void {
@@ -81,6 +85,21 @@ export class ProjectCreationComponent implements OnInit, AfterViewInit {
       style
     });
 
+    // this vector source contains the exclusion zones, which are not part of the project
+    this.exclusionSource = new VectorSource();
+    const exclusionLayer = new VectorLayer({
+      source: this.exclusionSource,
+      style: new Style({
+        stroke: new Stroke({
+          color: '#e5393590',
+          width: 2,
+        }),
+        fill: new Fill({
+          color: '#ef9a9a50'
+        })
+      })
+    });
+
     this.map = new Map({
       target: 'map',
       controls: defaultControls().extend([
@@ -91,6 +110,7 @@ export class ProjectCreationComponent implements OnInit, AfterViewInit {
         new TileLayer({
           source: new OSM()
         }),
+        exclusionLayer,
         vectorLayer
       ],
       view: new View({
@@ -129,6 +149,13 @@ export class ProjectCreationComponent implements OnInit, AfterViewInit {
     });
     this.map.addInteraction(this.drawInteraction);
 
+    this.exclusionDrawInteraction = new Draw({
+      source: this.exclusionSource,
+      type: GeometryType.POLYGON
+    });
+    this.exclusionDrawInteraction.setActive(false);
+    this.map.addInteraction(this.exclusionDrawInteraction);
+
     const snap = new Snap({
       source: this.vectorSource
     });
@@ -152,6 +179,15 @@ export class ProjectCreationComponent implements OnInit, AfterViewInit {
     return !!this.vectorSource && this.vectorSource.getFeatures().length !== 0;
   }
 
+  public get exclusions(): Feature[] {
+    return !!this.exclusionSource ? this.exclusionSource.getFeatures() : [];
+  }
+
+  public onDrawExclusionsChanged() {
+    this.drawInteraction.setActive(!this.drawExclusions);
+    this.exclusionDrawInteraction.setActive(this.drawExclusions);
+  }
+
   public onSaveButtonClicked() {
     let taskNameCounter = 1;
 
@@ -223,18 +259,21 @@ export class ProjectCreationComponent implements OnInit, AfterViewInit {
   onTabSelected(tabIndex: number) {
     switch (tabIndex) {
       case 0: // Tab: Draw
-        this.drawInteraction.setActive(true);
+        this.drawInteraction.setActive(!this.drawExclusions);
+        this.exclusionDrawInteraction.setActive(this.drawExclusions);
         this.modifyInteraction.setActive(true);
         this.selectInteraction.setActive(false);
         break;
       case 1: // Tab: Upload
       case 2: // Tab: Remote
         this.drawInteraction.setActive(false);
+        this.exclusionDrawInteraction.setActive(false);
         this.modifyInteraction.setActive(true);
         this.selectInteraction.setActive(false);
         break;
       case 3: // Tab: Remove
         this.drawInteraction.setActive(false);
+        this.exclusionDrawInteraction.setActive(false);
         this.modifyInteraction.setActive(false);
         this.selectInteraction.setActive(true);
         break;
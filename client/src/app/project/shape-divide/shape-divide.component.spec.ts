@@ -4,19 +4,27 @@ import { ShapeDivideComponent } from './shape-divide.component';
 import { FormsModule } from '@angular/forms';
 import { Polygon } from 'ol/geom';
 import { Feature } from 'ol';
+import { HttpClientTestingModule, HttpTestingController } from '@angular/common/http/testing';
+import { RouterTestingModule } from '@angular/router/testing';
+import { environment } from '../../../environments/environment';
 
 describe('ShapeDivideComponent', () => {
   let component: ShapeDivideComponent;
   let fixture: ComponentFixture<ShapeDivideComponent>;
+  let httpTestingController: HttpTestingController;
 
   beforeEach(waitForAsync(() => {
     TestBed.configureTestingModule({
       imports: [
-        FormsModule
+        FormsModule,
+        HttpClientTestingModule,
+        RouterTestingModule.withRoutes([])
       ],
       declarations: [ShapeDivideComponent]
     })
       .compileComponents();
+
+    httpTestingController = TestBed.inject(HttpTestingController);
   }));
 
   beforeEach(() => {
@@ -43,6 +51,84 @@ describe('ShapeDivideComponent', () => {
     expect(spy).toHaveBeenCalledTimes(3);
   });
 
+  it('should not create shapes within exclusions', () => {
+    const spy = spyOn(component.shapesCreated, 'emit');
+    component.lastDrawnPolygon = new Feature(new Polygon([[[0, 0], [0, 2000], [2000, 2000], [2000, 0], [0, 0]]]));
+    component.gridCellSize = 100;
+    component.gridCellShape = 'squareGrid';
+    component.exclusions = [new Feature(new Polygon([[[0, 0], [0, 1000], [1000, 1000], [1000, 0], [0, 0]]]))];
+
+    component.onDivideButtonClicked();
+
+    expect(spy).toHaveBeenCalled();
+    const cells = spy.calls.mostRecent().args[0] as Feature[];
+    const excludedCells = cells.filter(c => {
+      const interiorPoint = (c.getGeometry() as Polygon).getInteriorPoint().getCoordinates();
+      return interiorPoint[0] < 0.0089 && interiorPoint[1] < 0.0089;
+    });
+    expect(cells.length).toBeGreaterThan(0);
+    expect(excludedCells.length).toEqual(0);
+  });
+
+  it('should split dense cells in adaptive mode', () => {
+    const spy = spyOn(component.shapesCreated, 'emit');
+    component.lastDrawnPolygon = new Feature(new Polygon([[[0, 0], [0, 2000], [2000, 2000], [2000, 0], [0, 0]]]));
+    component.gridCellSize = 500;
+    component.gridCellShape = 'squareGrid';
+    component.adaptive = true;
+    component.maxFeaturesPerCell = 2;
+
+    component.onDivideButtonClicked();
+
+    const request = httpTestingController.expectOne(environment.overpass_url);
+    expect(request.request.method).toEqual('POST');
+    expect(request.request.body).toContain(encodeURIComponent('nwr[building]'));
+    request.flush({elements: [{type: 'node', lat: 0.001, lon: 0.001}, {type: 'way', center: {lat: 0.001, lon: 0.001}}]});
+
+    expect(spy).toHaveBeenCalledTimes(1);
+    httpTestingController.verify();
+  });
+
+  it('should split squares into squares and triangles into triangles', () => {
+    component.maxFeaturesPerCell = 2;
+    const locations = [[0.5, 0.5], [0.6, 0.6], [1.5, 1.5]];
+
+    // Three locations are too many, but each quarter only contains two or less
+    const square = new Polygon([[[0, 0], [0, 2], [2, 2], [2, 0], [0, 0]]]);
+    let cells = component.subdivideDenseCells([square], locations);
+    expect(cells.length).toEqual(4);
+    cells.forEach(c => expect(c.getArea()).toEqual(1));
+
+    const triangle = new Polygon([[[0, 0], [0, 4], [4, 0], [0, 0]]]);
+    cells = component.subdivideDenseCells([triangle], locations);
+    expect(cells.length).toEqual(4);
+    cells.forEach(c => {
+      expect(c.getCoordinates()[0].length).toEqual(4);
+      expect(c.getArea()).toEqual(2);
+    });
+  });
+
+  it('should stop splitting after maximum depth', () => {
+    component.maxFeaturesPerCell = 2;
+    const locations = [[0.1, 0.1], [0.1, 0.1], [0.1, 0.1]];
+
+    // All locations stay within one cell, which is split three times into four cells
+    const square = new Polygon([[[0, 0], [0, 2], [2, 2], [2, 0], [0, 0]]]);
+    expect(component.subdivideDenseCells([square], locations).length).toEqual(13);
+  });
+
+  it('should not emit event when all shapes are excluded', () => {
+    const spy = spyOn(component.shapesCreated, 'emit');
+    component.lastDrawnPolygon = new Feature(new Polygon([[[0, 0], [1000, 1000], [2000, 0], [0, 0]]]));
+    component.gridCellSize = 100;
+    component.gridCellShape = 'squareGrid';
+    component.exclusions = [new Feature(new Polygon([[[-1000, -1000], [-1000, 2000], [3000, 2000], [3000, -1000], [-1000, -1000]]]))];
+
+    component.onDivideButtonClicked();
+
+    expect(spy).not.toHaveBeenCalled();
+  });
+
   it('should emit event when clicked on divide button', () => {
     const spy = spyOn(component.shapesCreated, 'emit');
     component.lastDrawnPolygon = new Feature(new Polygon([[[0, 0], [1000, 1000], [2000, 0], [0, 0]]]));
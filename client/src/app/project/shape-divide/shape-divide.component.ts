@@ -5,7 +5,17 @@ import hexGrid from '@turf/hex-grid';
 import triangleGrid from '@turf/triangle-grid';
 import { Polygon } from 'ol/geom';
 import { Feature } from 'ol';
+import { Coordinate } from 'ol/coordinate';
+import { Extent } from 'ol/extent';
+import { HttpClient, HttpHeaders } from '@angular/common/http';
+import { Observable } from 'rxjs';
+import { map } from 'rxjs/operators';
 import { NotificationService } from '../../common/notification.service';
+import { LoadingService } from '../../common/loading.service';
+import { environment } from '../../../environments/environment';
+
+// Dense cells are split at most this many times, so a square of 1000m becomes at least 125m large
+const maxSubdivisionDepth = 3;
 
 @Component({
   selector: 'app-shape-divide',
@@ -17,11 +27,19 @@ export class ShapeDivideComponent implements OnInit {
   @Input() public gridCellShape: string;
   @Input() public lastDrawnPolygon: Feature;
   @Input() public hasTasks: boolean;
+  @Input() public exclusions: Feature[] = [];
 
   @Output() public shapesCreated: EventEmitter<Feature[]> = new EventEmitter();
 
+  // Adaptive mode: Cells with more OSM features (matching the Overpass filter) than the maximum are split further
+  public adaptive = false;
+  public densityFilter = 'building';
+  public maxFeaturesPerCell = 200;
+
   constructor(
-    private notificationService: NotificationService
+    private http: HttpClient,
+    private notificationService: NotificationService,
+    private loadingService: LoadingService
   ) {
   }
 
@@ -56,16 +74,110 @@ export class ShapeDivideComponent implements OnInit {
         return;
     }
 
-    const newFeatures = grid.features.map(g => {
+    // Clone the exclusions, otherwise the transformation would move them on the map
+    const exclusionPolygons = (this.exclusions || [])
+      .map(f => (f.getGeometry().clone() as Polygon).transform('EPSG:3857', 'EPSG:4326') as Polygon);
+
+    const cells = grid.features
       // Turn geo GeoJSON polygon from turf.js into an openlayers polygon and
       // transform it into the used coordinate system.
-      const geometry = new Polygon(g.geometry.coordinates);
+      .map(g => new Polygon(g.geometry.coordinates))
+      // Cells within exclusion zones (e.g. lakes) would be tasks without anything to map
+      .filter(geometry => !this.isExcluded(geometry, exclusionPolygons));
+
+    if (cells.length === 0) {
+      this.notificationService.addError($localize`:@@ERROR_ALL_CELLS_EXCLUDED:All shapes are within exclusion zones`);
+      return;
+    }
+
+    if (!this.adaptive) {
+      this.emitCells(cells);
+      return;
+    }
+
+    this.loadingService.start();
+    this.loadFeatureLocations(extent).subscribe(
+      locations => {
+        this.loadingService.end();
+        this.emitCells(this.subdivideDenseCells(cells, locations));
+      }, e => {
+        this.loadingService.end();
+        console.error('Error loading feature density from Overpass');
+        console.error(e);
+        this.notificationService.addError($localize`:@@ERROR_LOAD_DENSITY:Could not load feature density from Overpass`);
+      });
+  }
+
+  // Splits every cell containing more locations than allowed until each part is small enough or the maximum depth has
+  // been reached. This creates smaller tasks in dense areas (e.g. city centers) and larger ones in sparse areas.
+  public subdivideDenseCells(cells: Polygon[], locations: Coordinate[], depth: number = 0): Polygon[] {
+    return [].concat(...cells.map(cell => {
+      const cellLocations = locations.filter(l => cell.intersectsCoordinate(l));
+      if (cellLocations.length <= this.maxFeaturesPerCell || depth >= maxSubdivisionDepth) {
+        return [cell];
+      }
+
+      return this.subdivideDenseCells(this.splitCell(cell), cellLocations, depth + 1);
+    }));
+  }
 
-      // create the map feature and set the task-id to select the task when the
-      // polygon has been clicked
-      return new Feature(geometry);
+  private emitCells(cells: Polygon[]) {
+    // create the map feature and set the task-id to select the task when the
+    // polygon has been clicked
+    this.shapesCreated.emit(cells.map(geometry => new Feature(geometry)));
+  }
+
+  // Loads the locations (nodes and centers of ways and relations) of all features matching the density filter
+  // (e.g. "building" or "highway=residential") within the extent.
+  private loadFeatureLocations(extent: Extent): Observable<Coordinate[]> {
+    const bbox = [extent[1], extent[0], extent[3], extent[2]].join(','); // south, west, north, east
+    const query = `[out:json][timeout:60];nwr[${this.densityFilter}](${bbox});out center;`;
+
+    const headers = new HttpHeaders({'Content-Type': 'application/x-www-form-urlencoded'});
+    return this.http.post<any>(environment.overpass_url, 'data=' + encodeURIComponent(query), {headers})
+      .pipe(
+        map(result => result.elements
+          .map(e => e.type === 'node' ? [e.lon, e.lat] : (e.center ? [e.center.lon, e.center.lat] : undefined))
+          .filter(c => !!c))
+      );
+  }
+
+  // Splits triangles into four triangles by connecting the midpoints of their edges. All other cells are split into
+  // one quadrilateral per corner by connecting the center with the edge midpoints, so squares become four squares.
+  // This tiles the cell completely as long as it's convex, which is the case for all grid shapes.
+  private splitCell(cell: Polygon): Polygon[] {
+    // The outer ring without the closing coordinate
+    const ring = cell.getCoordinates()[0].slice(0, -1);
+    const midpoints = ring.map((c, i) => this.midpoint(c, ring[(i + 1) % ring.length]));
+
+    if (ring.length === 3) {
+      return [
+        new Polygon([[ring[0], midpoints[0], midpoints[2], ring[0]]]),
+        new Polygon([[ring[1], midpoints[1], midpoints[0], ring[1]]]),
+        new Polygon([[ring[2], midpoints[2], midpoints[1], ring[2]]]),
+        new Polygon([[midpoints[0], midpoints[1], midpoints[2], midpoints[0]]])
+      ];
+    }
+
+    const center = [
+      ring.map(c => c[0]).reduce((a, b) => a + b) / ring.length,
+      ring.map(c => c[1]).reduce((a, b) => a + b) / ring.length
+    ];
+
+    return ring.map((c, i) => {
+      const previousMidpoint = midpoints[(i + ring.length - 1) % ring.length];
+      return new Polygon([[c, midpoints[i], center, previousMidpoint, c]]);
     });
+  }
+
+  private midpoint(a: Coordinate, b: Coordinate): Coordinate {
+    return [(a[0] + b[0]) / 2, (a[1] + b[1]) / 2];
+  }
 
-    this.shapesCreated.emit(newFeatures);
+  // A cell is excluded when its interior point lies within one of the exclusion polygons. Cells only touching an
+  // exclusion zone are kept, since parts of them can still be mapped.
+  private isExcluded(cell: Polygon, exclusions: Polygon[]): boolean {
+    const interiorPoint = cell.getInteriorPoint().getCoordinates().slice(0, 2);
+    return exclusions.some(e => e.intersectsCoordinate(interiorPoint));
   }
 }
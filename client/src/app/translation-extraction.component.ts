@@ -20,6 +20,8 @@ import { ChangeDetectionStrategy, Component } from '@angular/core';
         <span i18n="@@ERROR_PARSING_OSM_DATA">Error parsing loaded OSM data</span>
         <span i18n="@@ERROR_OVERPASS_NO_POLYGONS">No usable polygons have been found. Make sure the output format is set to \'out:xml\' and the result contains actual polygons.</span>
         <span i18n="@@ERROR_UNABLE_LOAD_URL">Unable to load data from remote URL</span>
+        <span i18n="@@ERROR_ALL_CELLS_EXCLUDED">All shapes are within exclusion zones</span>
+        <span i18n="@@ERROR_LOAD_DENSITY">Could not load feature density from Overpass</span>
         <span i18n="@@WARN_ALREADY_MEMBER">User '{{interp}}' is already a member of this project</span>
         <span i18n="@@ERROR_USER_ID">Could not load user ID for user '{{interp}}'</span>
         <span i18n="@@ERROR_UNABLE_LOAD_USER">Unable to load assigned user</span>
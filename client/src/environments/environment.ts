@@ -5,7 +5,9 @@ export const environment = {
   production: false,
   test_mode: false,
   oauth_landing: document.location.origin + '/oauth-landing',
+  oauth_client_id: 'stm-web',
   osm_api_url: 'https://master.apis.dev.openstreetmap.org/api/0.6',
+  overpass_url: 'https://overpass-api.de/api/interpreter',
 
   base_url: baseUrl,
   url_auth: baseUrl + '/oauth_login',
@@ -0,0 +1,156 @@
+package account
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+
+	"github.com/hauke96/simple-task-manager/server/session"
+	"github.com/hauke96/simple-task-manager/server/storage"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+var (
+	accountIdRegex = regexp.MustCompile("^[a-zA-Z][a-zA-Z0-9_.-]{0,63}$")
+)
+
+type AccountService struct {
+	*util.Logger
+	store          storage.AccountStore
+	sessionService *session.SessionService
+}
+
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger, sessionService *session.SessionService) *AccountService {
+	return &AccountService{
+		Logger:         logger,
+		store:          getStore(ctx, tx, logger),
+		sessionService: sessionService,
+	}
+}
+
+// AddAccount creates a new account with a new login key. The key is only returned here, just its hash is stored. The
+// caller has to make sure the requesting user is an admin.
+func (s *AccountService) AddAccount(accountId string, adminId string) (*Account, error) {
+	if !accountIdRegex.MatchString(accountId) {
+		return nil, errors.New(fmt.Sprintf("account ID '%s' is invalid, it must start with a letter and can only contain letters, digits, '_', '.' and '-'", accountId))
+	}
+
+	existingAccount, err := s.store.GetAccount(accountId)
+	if err != nil {
+		return nil, err
+	}
+	if existingAccount != nil {
+		return nil, errors.New(fmt.Sprintf("account %s already exists", accountId))
+	}
+
+	key, err := createKey()
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := s.store.AddAccount(accountId, hashKey(key), adminId)
+	if err != nil {
+		return nil, err
+	}
+	account.Key = key
+
+	s.Log("Account %s added by %s", accountId, adminId)
+
+	return account, nil
+}
+
+func (s *AccountService) GetAccounts() ([]*Account, error) {
+	return s.store.GetAccounts()
+}
+
+// RemoveAccount prevents further logins of the account and revokes its sessions, so that tokens created before can't
+// be used anymore either. The caller has to make sure the requesting user is an admin.
+func (s *AccountService) RemoveAccount(accountId string) error {
+	account, err := s.store.GetAccount(accountId)
+	if err != nil {
+		return err
+	}
+	if account == nil {
+		return errors.New(fmt.Sprintf("account %s does not exist", accountId))
+	}
+
+	err = s.store.RemoveAccount(accountId)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := s.sessionService.RevokeAll(accountId)
+	if err != nil {
+		return err
+	}
+
+	s.Log("Account %s removed, revoked %d sessions", accountId, revoked)
+
+	return nil
+}
+
+// RenewKey replaces the login key of the account, e.g. when it got lost. The old key can't be used anymore. The caller
+// has to make sure the requesting user is an admin.
+func (s *AccountService) RenewKey(accountId string) (*Account, error) {
+	account, err := s.store.GetAccount(accountId)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, errors.New(fmt.Sprintf("account %s does not exist", accountId))
+	}
+
+	key, err := createKey()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.SetKeyHash(accountId, hashKey(key))
+	if err != nil {
+		return nil, err
+	}
+	account.Key = key
+
+	s.Log("Key of account %s renewed", accountId)
+
+	return account, nil
+}
+
+// Login returns the account with the given key or an error when there's no such account.
+func (s *AccountService) Login(key string) (*Account, error) {
+	if key == "" {
+		return nil, errors.New("login key not set")
+	}
+
+	account, err := s.store.GetAccountByKeyHash(hashKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, errors.New("login key not valid")
+	}
+
+	return account, nil
+}
+
+// createKey returns 32 random bytes as hex string. Such keys can't be guessed, so a simple hash is enough to store them.
+func createKey() (string, error) {
+	bytes := make([]byte, 32)
+
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to read random bytes for login key")
+	}
+
+	return hex.EncodeToString(bytes), nil
+}
+
+func hashKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
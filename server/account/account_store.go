@@ -0,0 +1,17 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hauke96/simple-task-manager/server/storage"
+	"github.com/hauke96/simple-task-manager/server/util"
+)
+
+// Account of the "local" auth backend, see "storage.Account". The type lives in the "storage" package, so that the
+// stores of all drivers can use it.
+type Account = storage.Account
+
+func getStore(ctx context.Context, tx *sql.Tx, logger *util.Logger) storage.AccountStore {
+	return storage.NewStore(storage.ComponentAccount, ctx, tx, logger).(storage.AccountStore)
+}
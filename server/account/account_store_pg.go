@@ -0,0 +1,122 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/storage"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+type storePg struct {
+	*util.Logger
+	ctx   context.Context
+	tx    *sql.Tx
+	table database.Table
+}
+
+func init() {
+	storage.Register(storage.DriverPostgres, storage.ComponentAccount, func(ctx context.Context, tx storage.Transaction, logger *util.Logger) interface{} {
+		return newStorePg(ctx, tx.(*sql.Tx), logger)
+	})
+}
+
+func newStorePg(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
+	return &storePg{
+		Logger: logger,
+		ctx:    ctx,
+		tx:     tx,
+		table:  database.TableLocalAccounts,
+	}
+}
+
+func (s *storePg) AddAccount(accountId string, keyHash string, adminId string) (*Account, error) {
+	query := fmt.Sprintf("INSERT INTO %s(id, key_hash, created_by) VALUES($1, $2, $3) RETURNING id, created_by, created_at;", s.table)
+	accounts, err := s.queryAccounts(query, accountId, keyHash, adminId)
+	if err != nil {
+		return nil, err
+	}
+
+	return accounts[0], nil
+}
+
+func (s *storePg) GetAccounts() ([]*Account, error) {
+	query := fmt.Sprintf("SELECT id, created_by, created_at FROM %s ORDER BY id;", s.table)
+	return s.queryAccounts(query)
+}
+
+// GetAccount returns the account or "nil" when there's none with this ID.
+func (s *storePg) GetAccount(accountId string) (*Account, error) {
+	query := fmt.Sprintf("SELECT id, created_by, created_at FROM %s WHERE id=$1;", s.table)
+	return s.querySingleAccount(query, accountId)
+}
+
+// GetAccountByKeyHash returns the account or "nil" when there's none with this key.
+func (s *storePg) GetAccountByKeyHash(keyHash string) (*Account, error) {
+	query := fmt.Sprintf("SELECT id, created_by, created_at FROM %s WHERE key_hash=$1;", s.table)
+	return s.querySingleAccount(query, keyHash)
+}
+
+func (s *storePg) SetKeyHash(accountId string, keyHash string) error {
+	query := fmt.Sprintf("UPDATE %s SET key_hash=$1 WHERE id=$2;", s.table)
+	return s.execQuery(query, keyHash, accountId)
+}
+
+func (s *storePg) RemoveAccount(accountId string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id=$1;", s.table)
+	return s.execQuery(query, accountId)
+}
+
+func (s *storePg) querySingleAccount(query string, params ...interface{}) (*Account, error) {
+	accounts, err := s.queryAccounts(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(accounts) == 0 {
+		return nil, nil
+	}
+
+	return accounts[0], nil
+}
+
+func (s *storePg) queryAccounts(query string, params ...interface{}) ([]*Account, error) {
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error executing account query")
+	}
+	defer rows.Close()
+
+	result := make([]*Account, 0)
+	for rows.Next() {
+		account := &Account{}
+		err = rows.Scan(&account.Id, &account.CreatedBy, &account.CreatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan account")
+		}
+
+		result = append(result, account)
+	}
+
+	return result, nil
+}
+
+func (s *storePg) execQuery(query string, params ...interface{}) error {
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return errors.Wrap(err, "error executing account query")
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/session"
+	"github.com/hauke96/simple-task-manager/server/test"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq" // Make driver "postgres" usable
+)
+
+var (
+	tx             *sql.Tx
+	s              *AccountService
+	sessionService *session.SessionService
+	h              *test.TestHelper
+)
+
+func TestMain(m *testing.M) {
+	h = &test.TestHelper{
+		Setup: setup,
+	}
+
+	m.Run()
+}
+
+func setup() {
+	config.LoadConfig("../config/test.json")
+	test.InitWithDummyData()
+	sigolo.LogLevel = sigolo.LOG_DEBUG
+
+	logger := util.NewLogger()
+
+	ctx := context.Background()
+
+	var err error
+	tx, err = database.GetTransaction(ctx, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	h.Tx = tx
+	sessionService = session.Init(ctx, tx, logger)
+	s = Init(ctx, tx, logger, sessionService)
+}
+
+func TestAddAccountAndLogin(t *testing.T) {
+	h.Run(t, func() error {
+		account, err := s.AddAccount("anna", "Peter")
+		if err != nil {
+			return err
+		}
+		if account.Id != "anna" || account.CreatedBy != "Peter" || len(account.Key) != 64 {
+			return errors.New(fmt.Sprintf("Account not matching: %#v", account))
+		}
+
+		loggedInAccount, err := s.Login(account.Key)
+		if err != nil {
+			return err
+		}
+		if loggedInAccount.Id != "anna" || loggedInAccount.Key != "" {
+			return errors.New(fmt.Sprintf("Logged in account not matching: %#v", loggedInAccount))
+		}
+
+		_, err = s.AddAccount("anna", "Peter")
+		if err == nil {
+			return errors.New("Adding existing account should not be possible")
+		}
+
+		_, err = s.Login("foo")
+		if err == nil {
+			return errors.New("Login with invalid key should not be possible")
+		}
+
+		return nil
+	})
+}
+
+func TestAddAccountInvalidId(t *testing.T) {
+	h.Run(t, func() error {
+		for _, id := range []string{"", "1234", "anna smith", "anna/smith"} {
+			_, err := s.AddAccount(id, "Peter")
+			if err == nil {
+				return errors.New(fmt.Sprintf("Adding account with ID '%s' should not be possible", id))
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestRenewKeyAndRemoveAccount(t *testing.T) {
+	h.Run(t, func() error {
+		account, err := s.AddAccount("anna", "Peter")
+		if err != nil {
+			return err
+		}
+
+		renewedAccount, err := s.RenewKey("anna")
+		if err != nil {
+			return err
+		}
+		if renewedAccount.Key == "" || renewedAccount.Key == account.Key {
+			return errors.New(fmt.Sprintf("Key should have been renewed: %#v", renewedAccount))
+		}
+
+		_, err = s.Login(account.Key)
+		if err == nil {
+			return errors.New("Login with old key should not be possible")
+		}
+
+		loginSession, err := sessionService.Start("anna", "1.2.3.4", "test-agent", time.Now().Add(time.Hour))
+		if err != nil {
+			return err
+		}
+
+		err = s.RemoveAccount("anna")
+		if err != nil {
+			return err
+		}
+
+		_, err = sessionService.Verify(loginSession.Id, "anna")
+		if err == nil {
+			return errors.New("Session of removed account should have been revoked")
+		}
+
+		_, err = s.Login(renewedAccount.Key)
+		if err == nil {
+			return errors.New("Login of removed account should not be possible")
+		}
+
+		accounts, err := s.GetAccounts()
+		if err != nil {
+			return err
+		}
+		if len(accounts) != 0 {
+			return errors.New(fmt.Sprintf("There should be no accounts: %#v", accounts))
+		}
+
+		return nil
+	})
+}
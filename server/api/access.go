@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/auth"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+type MaintenanceDto struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message"`
+}
+
+var (
+	maintenance      = &MaintenanceDto{}
+	maintenanceMutex = &sync.RWMutex{}
+
+	ipAllowList []*net.IPNet
+	ipDenyList  []*net.IPNet
+
+	securityHeaders map[string]string
+)
+
+func initAccess() error {
+	var err error
+
+	ipAllowList, err = parseIpList(config.Conf.IpAllowList)
+	if err != nil {
+		return errors.Wrap(err, "could not parse IP allow list")
+	}
+
+	ipDenyList, err = parseIpList(config.Conf.IpDenyList)
+	if err != nil {
+		return errors.Wrap(err, "could not parse IP deny list")
+	}
+
+	setMaintenance(config.Conf.MaintenanceMode, config.Conf.MaintenanceMessage)
+
+	securityHeaders = getSecurityHeaders(strings.HasPrefix(config.Conf.ServerUrl, "https"), config.Conf.SecurityHeaders)
+
+	return nil
+}
+
+// getSecurityHeaders returns the default security headers overridden by the configured ones. Headers configured with an
+// empty value are not sent at all. HSTS is only sent by default when the server uses HTTPS.
+func getSecurityHeaders(useHttps bool, overrides map[string]string) map[string]string {
+	headers := map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"Referrer-Policy":         "no-referrer",
+		"Content-Security-Policy": "default-src 'none'; frame-ancestors 'none'",
+	}
+	if useHttps {
+		headers["Strict-Transport-Security"] = "max-age=31536000; includeSubDomains"
+	}
+
+	for header, value := range overrides {
+		header = http.CanonicalHeaderKey(header)
+		if value == "" {
+			delete(headers, header)
+		} else {
+			headers[header] = value
+		}
+	}
+
+	return headers
+}
+
+// securityHeadersMiddleware adds the security headers to all responses, so that instances pass common security scans
+// without a proxy in front of them. The API only returns JSON and plain text, so the content security policy doesn't
+// allow any content to be loaded.
+func securityHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for header, value := range securityHeaders {
+			w.Header().Set(header, value)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseIpList turns single addresses (e.g. "1.2.3.4") and networks in CIDR notation (e.g. "1.2.3.0/24") into networks.
+func parseIpList(entries []string) ([]*net.IPNet, error) {
+	result := make([]*net.IPNet, 0)
+
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, errors.New(fmt.Sprintf("invalid IP address '%s'", entry))
+			}
+
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid network '%s'", entry))
+		}
+
+		result = append(result, network)
+	}
+
+	return result, nil
+}
+
+func containsIp(list []*net.IPNet, ip net.IP) bool {
+	for _, network := range list {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAddressAllowed checks the IP of the given "host:port" address against the deny and allow lists.
+func isAddressAllowed(address string) bool {
+	if len(ipAllowList) == 0 && len(ipDenyList) == 0 {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	ip := net.ParseIP(host)
+
+	return ip != nil && !containsIp(ipDenyList, ip) && (len(ipAllowList) == 0 || containsIp(ipAllowList, ip))
+}
+
+// ipFilterMiddleware rejects requests from addresses on the deny list and, if there's an allow list, from all addresses
+// not on the allow list. Only the address of the direct connection is considered, headers like "X-Forwarded-For" can
+// be set by anyone and are therefore ignored.
+func ipFilterMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAddressAllowed(r.RemoteAddr) {
+			logger := util.NewLogger()
+			logger.Log("Reject request from %s to %s %s", r.RemoteAddr, r.Method, r.URL.Path)
+			util.ErrorResponse(w, logger, errors.New("Access denied"), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isAdmin(uid string) bool {
+	for _, admin := range config.Conf.Admins {
+		if admin == uid {
+			return true
+		}
+	}
+	return false
+}
+
+func getMaintenance() MaintenanceDto {
+	maintenanceMutex.RLock()
+	defer maintenanceMutex.RUnlock()
+
+	return *maintenance
+}
+
+func setMaintenance(enabled bool, message string) {
+	maintenanceMutex.Lock()
+	defer maintenanceMutex.Unlock()
+
+	maintenance.Enabled = enabled
+	maintenance.Message = message
+
+	sigolo.Info("Maintenance mode enabled: %v", enabled)
+}
+
+// rejectDuringMaintenance writes a 503 response containing the maintenance message when the maintenance mode is active
+// and the user is no admin. Requests without token (nil) are always rejected during maintenance. The return value states
+// if the request has been rejected.
+func rejectDuringMaintenance(w http.ResponseWriter, token *auth.Token, logger *util.Logger) bool {
+	m := getMaintenance()
+	if !m.Enabled || (token != nil && isAdmin(token.UID)) {
+		return false
+	}
+
+	if token != nil {
+		logger.Log("Reject request from '%s' (%s) due to maintenance", token.User, token.UID)
+	} else {
+		logger.Log("Reject request without token due to maintenance")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	encoder := json.NewEncoder(w)
+	encoder.Encode(m)
+
+	return true
+}
@@ -1,7 +1,12 @@
 package api
 
 import (
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -10,20 +15,140 @@ import (
 	"github.com/hauke96/sigolo"
 	"github.com/hauke96/simple-task-manager/server/auth"
 	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/feature"
+	"github.com/hauke96/simple-task-manager/server/project"
+	"github.com/hauke96/simple-task-manager/server/push"
+	"github.com/hauke96/simple-task-manager/server/quota"
+	"github.com/hauke96/simple-task-manager/server/task"
 	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 var (
 	supportedApiVersions = make([]string, 0)
 )
 
+type LocalLoginDto struct {
+	Key string `json:"key" validate:"required"`
+	Id  string `json:"id"` // Optional ID of the account, the key must then belong to this account
+}
+
+type LocalLoginResultDto struct {
+	Token string `json:"token"`
+}
+
+// InfoDto describes the capabilities of this server, so that clients can adapt their UI instead of hard-coding them.
+type InfoDto struct {
+	Version         string        `json:"version"`
+	ApiVersions     []string      `json:"apiVersions"`
+	AuthBackend     string        `json:"authBackend"`     // See "auth.Backend..." values
+	InviteOnly      bool          `json:"inviteOnly"`      // Only invited OSM users can log in
+	PushPublicKey   string        `json:"pushPublicKey"`   // VAPID key browsers need to subscribe to push messages, empty when disabled
+	Features        []string      `json:"features"`        // Enabled feature flags
+	GeometryTypes   []string      `json:"geometryTypes"`   // Geometry types projects can allow for their tasks
+	GeometryFormats []string      `json:"geometryFormats"` // Formats the geometries of new tasks can be sent in
+	Crs             []string      `json:"crs"`             // Coordinate reference systems the geometries of new tasks can be sent in
+	Limits          InfoLimitsDto `json:"limits"`
+}
+
+type InfoLimitsDto struct {
+	MaxDescriptionLength      int         `json:"maxDescriptionLength"` // 0 means no limit, same for the other limits of projects
+	MaxNameLength             int         `json:"maxNameLength"`
+	MaxTasksPerProject        int         `json:"maxTasksPerProject"`
+	MaxUsersPerProject        int         `json:"maxUsersPerProject"`
+	MaxChangesetCommentLength int         `json:"maxChangesetCommentLength"`
+	MaxRequestBodySize        int64       `json:"maxRequestBodySize"` // Bytes, also limits the size of uploaded tasks
+	DefaultQuota              quota.Quota `json:"defaultQuota"`       // Quota of users without a quota set by an admin
+}
+
 func Init() error {
+	err := initAccess()
+	if err != nil {
+		return err
+	}
+
+	router := NewRouter()
+
+	// The REST API and the gRPC interface use the same certificate
+	var tlsConfig *tls.Config
+	var certManager *autocert.Manager
+	if strings.HasPrefix(config.Conf.ServerUrl, "https") {
+		tlsConfig, certManager, err = newTlsConfig()
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.Conf.GrpcPort != 0 {
+		go func() {
+			err := serveGrpc(tlsConfig)
+			if err != nil {
+				sigolo.Error("gRPC server stopped: %s", err.Error())
+			}
+		}()
+	}
+
+	if tlsConfig != nil {
+		sigolo.Info("Use HTTPS? yes")
+		err = serveTls(router, tlsConfig, certManager)
+	} else {
+		sigolo.Info("Use HTTPS? no")
+		err = http.ListenAndServe(":"+strconv.Itoa(config.Conf.Port), router)
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	sigolo.Info("Start serving ...")
+
+	return nil
+}
+
+// ServeLocal serves the API like "Init" but without TLS on a random port of the loopback interface, so that nothing is
+// exposed. It's used by the load test mode, which sends real requests to the server. Besides the server, which must be
+// closed by the caller, the base URL of the server is returned.
+func ServeLocal() (io.Closer, string, error) {
+	err := initAccess()
+	if err != nil {
+		return nil, "", err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, "", errors.Wrap(err, "unable to listen on loopback interface")
+	}
+
+	server := &http.Server{Handler: NewRouter()}
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			sigolo.Error("Local server stopped: %s", err.Error())
+		}
+	}()
+
+	return server, "http://" + listener.Addr().String(), nil
+}
+
+// NewRouter registers all general and versioned routes on a new router. The router is also used by the API tests,
+// which serve it without TLS via the "httptest" package.
+func NewRouter() *mux.Router {
 	// Register routes and print them
 	router := mux.NewRouter()
+	router.Use(securityHeadersMiddleware)
+	router.Use(ipFilterMiddleware)
+	router.Use(authenticationMiddleware)
 
 	router.HandleFunc("/info", getInfo).Methods(http.MethodGet)
-	router.HandleFunc("/oauth_login", auth.OauthLogin).Methods(http.MethodGet)
-	router.HandleFunc("/oauth_callback", auth.OauthCallback).Methods(http.MethodGet)
+	router.HandleFunc("/metrics", getMetrics).Methods(http.MethodGet)
+	if auth.IsLocalBackend() {
+		router.HandleFunc("/local_login", loginTransactionHandler(localLogin)).Methods(http.MethodPost)
+	} else {
+		router.HandleFunc("/oauth_login", auth.OauthLogin).Methods(http.MethodGet)
+		router.HandleFunc("/oauth_callback", auth.OauthCallback).Methods(http.MethodGet)
+	}
 
 	sigolo.Info("Registered general routes:")
 	printRoutes(router)
@@ -49,31 +174,211 @@ func Init() error {
 		w.Header().Set("Access-Control-Allow-Request-Methods", "GET,POST,DELETE,PUT")
 	})
 
-	var err error
-	if strings.HasPrefix(config.Conf.ServerUrl, "https") {
-		sigolo.Info("Use HTTPS? yes")
-		err = http.ListenAndServeTLS(":"+strconv.Itoa(config.Conf.Port), config.Conf.SslCertFile, config.Conf.SslKeyFile, router)
-	} else {
-		sigolo.Info("Use HTTPS? no")
-		err = http.ListenAndServe(":"+strconv.Itoa(config.Conf.Port), router)
+	return router
+}
+
+// newTlsConfig creates the TLS configuration of the HTTPS server and the gRPC interface. The certificate either comes
+// from the configured files or is requested via ACME when domains for it are configured. In the latter case, the
+// certificate manager is returned as well, since it has to answer the HTTP-01 challenges.
+func newTlsConfig() (*tls.Config, *autocert.Manager, error) {
+	if len(config.Conf.SslAutocertDomains) != 0 {
+		sigolo.Info("Use ACME certificates for domains %v", config.Conf.SslAutocertDomains)
+
+		certManager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(config.Conf.SslAutocertDomains...),
+			Cache:      autocert.DirCache(config.Conf.SslAutocertCacheDir),
+		}
+
+		tlsConfig := certManager.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		return tlsConfig, certManager, nil
+	}
+
+	if config.Conf.SslCertFile == "" || config.Conf.SslKeyFile == "" {
+		return nil, nil, errors.New("HTTPS requires either certificate files or domains for ACME certificates")
 	}
 
+	certificate, err := tls.LoadX509KeyPair(config.Conf.SslCertFile, config.Conf.SslKeyFile)
 	if err != nil {
-		panic(err)
+		return nil, nil, errors.Wrap(err, "could not load TLS certificate")
 	}
 
-	sigolo.Info("Start serving ...")
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{certificate},
+	}, nil, nil
+}
 
-	return nil
+// serveTls starts the HTTPS server. HTTP/2 is enabled automatically by the net/http package when using TLS. The
+// certificate manager is only set when ACME certificates are used.
+func serveTls(router *mux.Router, tlsConfig *tls.Config, certManager *autocert.Manager) error {
+	server := &http.Server{
+		Addr:      ":" + strconv.Itoa(config.Conf.Port),
+		Handler:   router,
+		TLSConfig: tlsConfig,
+	}
+
+	var redirectHandler http.Handler = http.HandlerFunc(redirectToHttps)
+	if certManager != nil {
+		// The manager answers the HTTP-01 challenges and passes all other requests to the redirect
+		redirectHandler = certManager.HTTPHandler(redirectHandler)
+	}
+
+	if config.Conf.HttpRedirectPort != 0 {
+		sigolo.Info("Redirect HTTP requests on port %d to HTTPS", config.Conf.HttpRedirectPort)
+
+		go func() {
+			err := http.ListenAndServe(":"+strconv.Itoa(config.Conf.HttpRedirectPort), redirectHandler)
+			if err != nil {
+				sigolo.Error("HTTP redirect server stopped: %s", err.Error())
+			}
+		}()
+	}
+
+	// The certificates come from the TLS config, so no files are needed
+	return server.ListenAndServeTLS("", "")
 }
 
+func redirectToHttps(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	url := fmt.Sprintf("https://%s:%d%s", host, config.Conf.Port, r.URL.RequestURI())
+	http.Redirect(w, r, url, http.StatusMovedPermanently)
+}
+
+// getInfo returns the capabilities of this server as JSON when the client accepts it (e.g. "Accept: application/json")
+// and as text page otherwise.
 func getInfo(w http.ResponseWriter, r *http.Request) {
+	info := getServerInfo()
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+		return
+	}
+
 	fmtStr := "%*s : %s\n"
 	fmtColWidth := 22
 
 	fmt.Fprintf(w, "SimpleTaskManager Server:\n")
 	fmt.Fprintf(w, "=========================\n\n")
-	fmt.Fprintf(w, fmtStr, fmtColWidth, "Version", util.VERSION)
+	fmt.Fprintf(w, fmtStr, fmtColWidth, "Version", info.Version)
 	fmt.Fprintf(w, fmtStr, fmtColWidth, "Code", "https://github.com/hauke96/simple-task-manager")
-	fmt.Fprintf(w, fmtStr, fmtColWidth, "Supported API versions", strings.Join(supportedApiVersions, ", "))
+	fmt.Fprintf(w, fmtStr, fmtColWidth, "Supported API versions", strings.Join(info.ApiVersions, ", "))
+	fmt.Fprintf(w, fmtStr, fmtColWidth, "Enabled features", strings.Join(info.Features, ", "))
+	fmt.Fprintf(w, fmtStr, fmtColWidth, "Auth backend", info.AuthBackend)
+	fmt.Fprintf(w, fmtStr, fmtColWidth, "Invite-only", strconv.FormatBool(info.InviteOnly))
+	fmt.Fprintf(w, fmtStr, fmtColWidth, "Geometry types", strings.Join(info.GeometryTypes, ", "))
+	fmt.Fprintf(w, fmtStr, fmtColWidth, "Geometry formats", strings.Join(info.GeometryFormats, ", "))
+	fmt.Fprintf(w, fmtStr, fmtColWidth, "Geometry CRS", strings.Join(info.Crs, ", "))
+	fmt.Fprintf(w, fmtStr, fmtColWidth, "Max description length", strconv.Itoa(info.Limits.MaxDescriptionLength))
+	fmt.Fprintf(w, fmtStr, fmtColWidth, "Max name length", strconv.Itoa(info.Limits.MaxNameLength))
+	fmt.Fprintf(w, fmtStr, fmtColWidth, "Max tasks per project", strconv.Itoa(info.Limits.MaxTasksPerProject))
+	fmt.Fprintf(w, fmtStr, fmtColWidth, "Max users per project", strconv.Itoa(info.Limits.MaxUsersPerProject))
+	fmt.Fprintf(w, fmtStr, fmtColWidth, "Max request size", fmt.Sprintf("%d bytes", info.Limits.MaxRequestBodySize))
+}
+
+// getMetrics serves the metrics of this server in the text format of Prometheus. The configured metrics token has to be
+// sent as bearer token, without configured token the metrics are not available at all.
+func getMetrics(w http.ResponseWriter, r *http.Request) {
+	logger := requestLogger(r)
+
+	if config.Conf.MetricsToken == "" {
+		util.ErrorResponse(w, logger, errors.New("metrics are disabled on this instance"), http.StatusNotFound)
+		return
+	}
+	if !isMetricsTokenValid(r.Header.Get("Authorization"), config.Conf.MetricsToken) {
+		// No further information to caller (which is a potential attacker)
+		util.ResponseUnauthorized(w, logger, errors.New("No valid metrics token found"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	err := database.WriteMetrics(w)
+	if err == nil {
+		err = auth.WriteMetrics(w)
+	}
+	if err != nil {
+		sigolo.Error("Unable to write metrics: %s", err.Error())
+	}
+}
+
+// isMetricsTokenValid checks the "Authorization" header of a metrics request in constant time.
+func isMetricsTokenValid(authorizationHeader string, metricsToken string) bool {
+	expectedHeader := "Bearer " + metricsToken
+	return subtle.ConstantTimeCompare([]byte(authorizationHeader), []byte(expectedHeader)) == 1
+}
+
+func getServerInfo() *InfoDto {
+	projectLimits := project.GetLimits()
+
+	return &InfoDto{
+		Version:         util.VERSION,
+		ApiVersions:     supportedApiVersions,
+		AuthBackend:     config.Conf.AuthBackend,
+		InviteOnly:      config.Conf.InviteOnly && !auth.IsLocalBackend(),
+		PushPublicKey:   push.PublicKey(),
+		Features:        feature.GetEnabledFlags(),
+		GeometryTypes:   task.GetGeometryTypes(),
+		GeometryFormats: task.GetGeometryFormats(),
+		Crs:             task.GetSupportedCrs(),
+		Limits: InfoLimitsDto{
+			MaxDescriptionLength:      projectLimits.MaxDescriptionLength,
+			MaxNameLength:             projectLimits.MaxNameLength,
+			MaxTasksPerProject:        projectLimits.MaxTasks,
+			MaxUsersPerProject:        projectLimits.MaxUsers,
+			MaxChangesetCommentLength: project.MaxChangesetCommentLength(),
+			MaxRequestBodySize:        config.Conf.MaxRequestBodySize,
+			DefaultQuota:              quota.DefaultQuota(),
+		},
+	}
+}
+
+// localLogin creates a token for the local account with the given key. The token is the same as the one created after
+// logging in via the OSM server, so all other routes work the same for both auth backends.
+func localLogin(r *http.Request, context *Context) *ApiResponse {
+	var dto LocalLoginDto
+	err := decodeJsonBody(r, &dto)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling login"))
+	}
+
+	err = auth.CheckLoginAttempt(r)
+	if err != nil {
+		return TooManyRequestsError(err.(*auth.LoginThrottledError))
+	}
+
+	account, err := context.AccountService.Login(dto.Key)
+	if err == nil && dto.Id != "" && account.Id != dto.Id {
+		err = errors.New(fmt.Sprintf("login key doesn't belong to account %s", dto.Id))
+	}
+	if err != nil {
+		auth.RecordFailedLogin(r)
+		context.Err("Login of local account failed: %s", err.Error())
+		// No further information to caller (which is a potential attacker)
+		return UnauthorizedError(errors.New("login failed"))
+	}
+	auth.RecordSuccessfulLogin(r)
+
+	if getMaintenance().Enabled && !isAdmin(account.Id) {
+		context.Log("Reject login of local account %s due to maintenance", account.Id)
+		return ServiceUnavailableError(errors.New(getMaintenance().Message))
+	}
+
+	token, err := auth.CreateToken(context.Logger, account.Id, account.Id, r)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully logged in local account %s", account.Id)
+
+	return JsonResponse(&LocalLoginResultDto{
+		Token: token,
+	})
 }
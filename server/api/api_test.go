@@ -0,0 +1,588 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/auth"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/feature"
+	"github.com/hauke96/simple-task-manager/server/notification"
+	"github.com/hauke96/simple-task-manager/server/project"
+	"github.com/hauke96/simple-task-manager/server/quota"
+	"github.com/hauke96/simple-task-manager/server/rpc"
+	"github.com/hauke96/simple-task-manager/server/session"
+	"github.com/hauke96/simple-task-manager/server/task"
+	"github.com/hauke96/simple-task-manager/server/test"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/hauke96/simple-task-manager/server/websocket"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq" // Make driver "postgres" usable
+)
+
+var (
+	server *httptest.Server
+	h      *test.TestHelper
+)
+
+// TestMain serves the complete router, so the tests send real HTTP requests through authentication, transaction
+// handling and the handlers against the database filled with the dummy data.
+func TestMain(m *testing.M) {
+	config.LoadConfig("../config/test.json")
+	sigolo.LogLevel = sigolo.LOG_DEBUG
+
+	database.Init()
+	auth.Init()
+	err := initAccess()
+	sigolo.FatalCheck(err)
+
+	server = httptest.NewServer(NewRouter())
+	defer server.Close()
+
+	h = &test.TestHelper{
+		Setup: setup,
+	}
+
+	m.Run()
+}
+
+func setup() {
+	test.InitWithDummyData()
+}
+
+// client creates an API client for a fake user with the same name and ID. The token is created like after a real login.
+func client(userId string) *test.ApiClient {
+	token, err := auth.CreateToken(util.NewLogger(), userId, userId, nil)
+	if err != nil {
+		panic(err)
+	}
+	return test.NewApiClient(server, token)
+}
+
+func TestInfo(t *testing.T) {
+	h.Run(t, func() error {
+		response, err := test.NewAnonymousApiClient(server).Request(http.MethodGet, "/info", nil)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+
+		body, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			return err
+		}
+
+		if !strings.Contains(string(body), "v2.4") {
+			return errors.New("supported API version v2.4 missing in info page")
+		}
+
+		if response.Header.Get("X-Content-Type-Options") != "nosniff" {
+			return errors.New("security headers missing on info page")
+		}
+
+		return nil
+	})
+}
+
+func TestInfoJson(t *testing.T) {
+	h.Run(t, func() error {
+		request, err := http.NewRequest(http.MethodGet, server.URL+"/info", nil)
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Accept", "application/json")
+
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+
+		var info InfoDto
+		err = json.NewDecoder(response.Body).Decode(&info)
+		if err != nil {
+			return err
+		}
+
+		if len(info.ApiVersions) == 0 || info.ApiVersions[0] != "v2.4" || len(info.GeometryTypes) != 3 || info.Limits.MaxDescriptionLength != 10000 || info.Limits.MaxRequestBodySize != config.Conf.MaxRequestBodySize {
+			return errors.New(fmt.Sprintf("info does not match: %#v", info))
+		}
+
+		return nil
+	})
+}
+
+func TestUnauthenticatedRequest(t *testing.T) {
+	h.Run(t, func() error {
+		return test.NewAnonymousApiClient(server).ExpectStatus(http.MethodGet, "/v2.4/projects", nil, http.StatusUnauthorized)
+	})
+}
+
+func TestAuthenticationMiddleware(t *testing.T) {
+	var token *auth.Token
+	var err error
+	handler := authenticationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err = requestToken(r)
+	}))
+
+	request := httptest.NewRequest(http.MethodGet, "/v2.4/projects", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	if token != nil || err == nil {
+		t.Errorf("request without token should not have a token: %v", token)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/v2.4/projects", nil)
+	request.Header.Set("Authorization", "invalid")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	if token != nil || err == nil {
+		t.Errorf("request with invalid token should not have a token: %v", token)
+	}
+
+	// Requests not passing the middleware have no token either
+	_, err = requestToken(httptest.NewRequest(http.MethodGet, "/v2.4/projects", nil))
+	if err == nil {
+		t.Error("request without middleware should not have a token")
+	}
+}
+
+func TestGetProjects_v2_4(t *testing.T) {
+	h.Run(t, func() error {
+		var projects []*ProjectDto_v2_4
+		err := client("Peter").RequestJson(http.MethodGet, "/v2.4/projects", nil, &projects)
+		if err != nil {
+			return err
+		}
+
+		if len(projects) != 1 || projects[0].Id != "1" {
+			return errors.New(fmt.Sprintf("expected only project 1 but got %#v", projects))
+		}
+
+		return nil
+	})
+}
+
+func TestGetProjectNotMember_v2_4(t *testing.T) {
+	h.Run(t, func() error {
+		return client("Otto").ExpectStatus(http.MethodGet, "/v2.4/projects/1", nil, http.StatusInternalServerError)
+	})
+}
+
+func TestAssignUser_v2_4(t *testing.T) {
+	h.Run(t, func() error {
+		var assignedTask TaskDto_v2_4
+		err := client("Maria").RequestJson(http.MethodPost, "/v2.4/tasks/4/assignedUser", nil, &assignedTask)
+		if err != nil {
+			return err
+		}
+
+		if assignedTask.AssignedUser != "Maria" {
+			return errors.New(fmt.Sprintf("expected task assigned to Maria but was '%s'", assignedTask.AssignedUser))
+		}
+
+		// Another member must see the committed change
+		var loadedTask TaskDto_v2_4
+		err = client("John").RequestJson(http.MethodGet, "/v2.4/tasks/4", nil, &loadedTask)
+		if err != nil {
+			return err
+		}
+
+		if loadedTask.AssignedUser != "Maria" {
+			return errors.New(fmt.Sprintf("expected stored task assigned to Maria but was '%s'", loadedTask.AssignedUser))
+		}
+
+		// Task is already assigned, so the transaction of this request is rolled back
+		return client("John").ExpectStatus(http.MethodPost, "/v2.4/tasks/4/assignedUser", nil, http.StatusInternalServerError)
+	})
+}
+
+func TestGetAssignedTasks_v2_4(t *testing.T) {
+	h.Run(t, func() error {
+		var tasks []*AssignedTaskDto_v2_4
+		err := client("Otto").RequestJson(http.MethodGet, "/v2.4/user/tasks", nil, &tasks)
+		if err != nil {
+			return err
+		}
+
+		if len(tasks) != 1 || tasks[0].Id != "8" || tasks[0].ProjectId != "3" || tasks[0].ProjectName != "Project 3" {
+			return errors.New(fmt.Sprintf("expected only task 8 of project 3 but got %#v", tasks))
+		}
+
+		return nil
+	})
+}
+
+func TestGetDashboard_v2_4(t *testing.T) {
+	h.Run(t, func() error {
+		var dashboard DashboardDto
+		err := client("Maria").RequestJson(http.MethodGet, "/v2.4/user/dashboard", nil, &dashboard)
+		if err != nil {
+			return err
+		}
+
+		if len(dashboard.AssignedTasks) != 2 {
+			return errors.New(fmt.Sprintf("expected 2 assigned tasks but got %d", len(dashboard.AssignedTasks)))
+		}
+
+		if len(dashboard.OwnedProjects) != 1 || dashboard.OwnedProjects[0].Id != "2" {
+			return errors.New(fmt.Sprintf("expected only owned project 2 but got %#v", dashboard.OwnedProjects))
+		}
+
+		if len(dashboard.TargetStates) != 0 {
+			return errors.New(fmt.Sprintf("expected no target states since project 2 has no targets but got %#v", dashboard.TargetStates))
+		}
+
+		return nil
+	})
+}
+
+func TestGetProjectTaskViewers_v2_4(t *testing.T) {
+	h.Run(t, func() error {
+		var viewers []*websocket.TaskViewers
+		err := client("Maria").RequestJson(http.MethodGet, "/v2.4/projects/1/viewers", nil, &viewers)
+		if err != nil {
+			return err
+		}
+
+		if len(viewers) != 0 {
+			return errors.New(fmt.Sprintf("expected no viewers but got %#v", viewers))
+		}
+
+		// John is not a member of project 1
+		return client("John").ExpectStatus(http.MethodGet, "/v2.4/projects/1/viewers", nil, http.StatusInternalServerError)
+	})
+}
+
+func TestGetProjectChanges_v2_4(t *testing.T) {
+	h.Run(t, func() error {
+		// First sync returns everything
+		var changes ProjectChangesDto
+		err := client("Maria").RequestJson(http.MethodGet, "/v2.4/projects/changes", nil, &changes)
+		if err != nil {
+			return err
+		}
+
+		if len(changes.Projects) != 2 || len(changes.ProjectIds) != 2 || len(changes.Tasks) != 6 {
+			return errors.New(fmt.Sprintf("expected 2 projects and 6 tasks but got %d (%d IDs) and %d", len(changes.Projects), len(changes.ProjectIds), len(changes.Tasks)))
+		}
+
+		// Nothing changed since then
+		cursor := changes.Cursor
+		changes = ProjectChangesDto{}
+		err = client("Maria").RequestJson(http.MethodGet, "/v2.4/projects/changes?since="+cursor, nil, &changes)
+		if err != nil {
+			return err
+		}
+
+		if len(changes.Projects) != 0 || len(changes.Tasks) != 0 || len(changes.ProjectIds) != 2 || changes.Cursor != cursor {
+			return errors.New(fmt.Sprintf("expected no changes but got %#v", changes))
+		}
+
+		err = client("Maria").RequestJson(http.MethodPost, "/v2.4/tasks/4/assignedUser", nil, &TaskDto_v2_4{})
+		if err != nil {
+			return err
+		}
+
+		changes = ProjectChangesDto{}
+		err = client("Maria").RequestJson(http.MethodGet, "/v2.4/projects/changes?since="+cursor, nil, &changes)
+		if err != nil {
+			return err
+		}
+
+		if len(changes.Projects) != 0 || len(changes.Tasks) != 1 || changes.Tasks[0].Id != "4" || changes.Cursor == cursor {
+			return errors.New(fmt.Sprintf("expected only changed task 4 but got %#v", changes))
+		}
+
+		return client("Maria").ExpectStatus(http.MethodGet, "/v2.4/projects/changes?since=yesterday", nil, http.StatusBadRequest)
+	})
+}
+
+func TestSetQuotaNotAdmin_v2_4(t *testing.T) {
+	h.Run(t, func() error {
+		return client("Peter").ExpectStatus(http.MethodPut, "/v2.4/quotas/Peter", &quota.Quota{MaxOwnedProjects: 100}, http.StatusInternalServerError)
+	})
+}
+
+func TestGetProjectBadge_v2_4(t *testing.T) {
+	h.Run(t, func() error {
+		response, err := test.NewAnonymousApiClient(server).Request(http.MethodGet, "/v2.4/projects/2/badge.svg", nil)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "image/svg+xml" {
+			return errors.New(fmt.Sprintf("SVG badge expected but got status %d and type '%s'", response.StatusCode, response.Header.Get("Content-Type")))
+		}
+
+		return nil
+	})
+}
+
+func TestSetFeatureNotAdmin_v2_4(t *testing.T) {
+	h.Run(t, func() error {
+		err := client("Peter").ExpectStatus(http.MethodPut, "/v2.4/features/websocket?enabled=false", nil, http.StatusInternalServerError)
+		if err != nil {
+			return err
+		}
+
+		if !feature.IsEnabled(feature.Websocket) {
+			return errors.New("websocket feature should still be enabled")
+		}
+
+		return nil
+	})
+}
+
+func TestAddUserNotification_v2_4(t *testing.T) {
+	h.Run(t, func() error {
+		err := client("Peter").RequestJson(http.MethodPost, "/v2.4/projects/1/users?uid=John", nil, nil)
+		if err != nil {
+			return err
+		}
+
+		var notifications []*notification.Notification
+		err = client("John").RequestJson(http.MethodGet, "/v2.4/user/notifications?unread=true", nil, &notifications)
+		if err != nil {
+			return err
+		}
+
+		if len(notifications) != 1 || notifications[0].Type != notification.TypeProjectUserAdded || notifications[0].ProjectId != "1" || notifications[0].Actor != "Peter" {
+			return errors.New(fmt.Sprintf("expected notification about project 1 but got %#v", notifications))
+		}
+
+		return client("John").RequestJson(http.MethodPut, "/v2.4/user/notifications/"+notifications[0].Id+"/read", nil, nil)
+	})
+}
+
+func TestRevokeSession_v2_4(t *testing.T) {
+	h.Run(t, func() error {
+		peter := client("Peter")
+		otherPeter := client("Peter")
+
+		var sessions []*session.Session
+		err := peter.RequestJson(http.MethodGet, "/v2.4/user/sessions", nil, &sessions)
+		if err != nil {
+			return err
+		}
+		if len(sessions) != 2 || !sessions[1].Current {
+			return errors.New(fmt.Sprintf("expected two sessions, the older one being the current one, but got %#v", sessions))
+		}
+
+		// Revoke the session of "otherPeter", whose token can't be used anymore
+		err = peter.RequestJson(http.MethodDelete, "/v2.4/user/sessions/"+sessions[0].Id, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		err = otherPeter.ExpectStatus(http.MethodGet, "/v2.4/projects", nil, http.StatusUnauthorized)
+		if err != nil {
+			return err
+		}
+
+		return peter.ExpectStatus(http.MethodGet, "/v2.4/projects", nil, http.StatusOK)
+	})
+}
+
+func TestAddProjectInvalidBody_v2_4(t *testing.T) {
+	h.Run(t, func() error {
+		body := map[string]interface{}{
+			"project": map[string]interface{}{
+				"name":  "foo",
+				"users": []string{"Peter"},
+				"owner": "Peter",
+				"color": "red",
+			},
+			"tasks": []interface{}{},
+		}
+
+		response, err := client("Peter").Request(http.MethodPost, "/v2.4/projects", body)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+
+		responseBytes, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			return err
+		}
+
+		if response.StatusCode != http.StatusBadRequest || !strings.Contains(string(responseBytes), `unknown field "color"`) {
+			return errors.New(fmt.Sprintf("expected bad request due to unknown field but got status %d: %s", response.StatusCode, string(responseBytes)))
+		}
+
+		return nil
+	})
+}
+
+func TestProjectDtoMapping_v2_4(t *testing.T) {
+	dto := toProjectDto_v2_4(&project.Project{
+		Id:                "1",
+		Name:              "foo",
+		Users:             []string{"Peter"},
+		Owner:             "Peter",
+		DoneProcessPoints: 10,
+		GeometryTypes:     []string{task.GeometryTypePoint},
+		UpdatedAt:         time.Date(2021, 3, 14, 15, 9, 26, 0, time.UTC),
+	})
+	if dto.Id != "1" || dto.Name != "foo" || dto.DoneProcessPoints != 10 || dto.GeometryTypes[0] != task.GeometryTypePoint || dto.UpdatedAt.Year() != 2021 {
+		t.Errorf("Project DTO not matching: %#v", dto)
+	}
+
+	// Computed fields sent by clients are ignored
+	model := toProjectModel_v2_4(dto)
+	if model.Name != "foo" || model.Owner != "Peter" || model.DoneProcessPoints != 0 {
+		t.Errorf("Project model not matching: %#v", model)
+	}
+}
+
+func TestTaskDtoMapping_v2_4(t *testing.T) {
+	dto := toTaskDto_v2_4(&task.Task{
+		Id:               "1",
+		MaxProcessPoints: 10,
+		Geometry:         "{}",
+		BoundingBox:      []float64{0, 0, 1, 1},
+		Version:          3,
+	})
+	if dto.Id != "1" || dto.MaxProcessPoints != 10 || len(dto.BoundingBox) != 4 || dto.Version != 3 {
+		t.Errorf("Task DTO not matching: %#v", dto)
+	}
+
+	models := toTaskModels_v2_4([]*TaskDto_v2_4{dto})
+	if len(models) != 1 || models[0].MaxProcessPoints != 10 || models[0].BoundingBox != nil || models[0].Version != 0 || !models[0].UpdatedAt.IsZero() {
+		t.Errorf("Task model not matching: %#v", models)
+	}
+}
+
+func TestGrpcMessageMapping(t *testing.T) {
+	message := toTaskMessage(&task.Task{
+		Id:               "1",
+		ProcessPoints:    5,
+		MaxProcessPoints: 10,
+		AssignedUser:     "Peter",
+		UpdatedAt:        time.Date(2021, 3, 14, 15, 9, 26, 0, time.UTC),
+	})
+	if message.Id != "1" || message.ProcessPoints != 5 || message.MaxProcessPoints != 10 || message.AssignedUser != "Peter" ||
+		message.CreatedAt != nil || message.UpdatedAt.AsTime().Year() != 2021 {
+		t.Errorf("Task message not matching: %#v", message)
+	}
+
+	update, err := toUpdateMessage(websocket.Message{Id: 42, Type: websocket.MessageType_ProjectDeleted, ProjectId: "1", Data: "1"})
+	if err != nil {
+		t.Error(err)
+		t.Fail()
+		return
+	}
+	if update.Id != 42 || update.Type != websocket.MessageType_ProjectDeleted || update.ProjectId != "1" || update.Data != `"1"` {
+		t.Errorf("Update message not matching: %#v", update)
+	}
+}
+
+func TestProtobufResponse(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/v2.4/projects/1/tasks", nil)
+	if acceptsProtobuf(request) {
+		t.Errorf("Request without Accept header should get JSON")
+	}
+
+	request.Header.Set("Accept", ContentTypeProtobuf)
+	if !acceptsProtobuf(request) {
+		t.Errorf("Request should get protobuf")
+	}
+
+	response := ProtobufResponse(&rpc.TaskList{Tasks: toTaskMessages([]*task.Task{{Id: "1", Centroid: []float64{9.9, 53.5}, Blocked: true}})})
+	if response.statusCode != http.StatusOK || response.contentType != ContentTypeProtobuf {
+		t.Errorf("Response not matching: %#v", response)
+		return
+	}
+
+	var tasks rpc.TaskList
+	err := proto.Unmarshal(response.data.([]byte), &tasks)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(tasks.Tasks) != 1 || tasks.Tasks[0].Id != "1" || len(tasks.Tasks[0].Centroid) != 2 || !tasks.Tasks[0].Blocked {
+		t.Errorf("Decoded tasks not matching: %v", tasks.Tasks)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	headers := getSecurityHeaders(false, nil)
+	if headers["X-Content-Type-Options"] != "nosniff" || headers["Referrer-Policy"] == "" || headers["Content-Security-Policy"] == "" {
+		t.Errorf("Default headers missing: %v", headers)
+	}
+	if _, ok := headers["Strict-Transport-Security"]; ok {
+		t.Errorf("HSTS should only be sent with HTTPS: %v", headers)
+	}
+
+	headers = getSecurityHeaders(true, map[string]string{
+		"referrer-policy":    "same-origin",
+		"X-Frame-Options":    "",
+		"Permissions-Policy": "geolocation=()",
+	})
+	if headers["Strict-Transport-Security"] == "" || headers["Referrer-Policy"] != "same-origin" || headers["Permissions-Policy"] != "geolocation=()" {
+		t.Errorf("Headers not overridden: %v", headers)
+	}
+	if _, ok := headers["X-Frame-Options"]; ok {
+		t.Errorf("Header with empty value should be removed: %v", headers)
+	}
+}
+
+func TestGrpcRequiresTlsWithHttps(t *testing.T) {
+	previousServerUrl, previousCertFile := config.Conf.ServerUrl, config.Conf.SslCertFile
+	defer func() { config.Conf.ServerUrl, config.Conf.SslCertFile = previousServerUrl, previousCertFile }()
+	config.Conf.ServerUrl = "https://stm.example.com"
+	config.Conf.SslCertFile = ""
+
+	_, _, err := newTlsConfig()
+	if err == nil {
+		t.Errorf("HTTPS without certificate should not be possible")
+	}
+
+	err = serveGrpc(nil)
+	if err == nil {
+		t.Errorf("gRPC without TLS should not be possible when the server uses HTTPS")
+	}
+}
+
+func TestMetricsToken(t *testing.T) {
+	if !isMetricsTokenValid("Bearer secret-token", "secret-token") {
+		t.Errorf("Configured token should be valid")
+	}
+	if isMetricsTokenValid("Bearer other-token", "secret-token") || isMetricsTokenValid("secret-token", "secret-token") || isMetricsTokenValid("", "secret-token") {
+		t.Errorf("Other and missing tokens should not be valid")
+	}
+
+	previousToken := config.Conf.MetricsToken
+	defer func() { config.Conf.MetricsToken = previousToken }()
+
+	config.Conf.MetricsToken = ""
+	recorder := httptest.NewRecorder()
+	getMetrics(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Metrics without configured token should be disabled but got status %d", recorder.Code)
+	}
+
+	config.Conf.MetricsToken = "secret-token"
+	recorder = httptest.NewRecorder()
+	getMetrics(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Metrics without token should be rejected but got status %d", recorder.Code)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	request.Header.Set("Authorization", "Bearer secret-token")
+	recorder = httptest.NewRecorder()
+	getMetrics(recorder, request)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "stm_login_failures_total") {
+		t.Errorf("Metrics with token should be returned but got status %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
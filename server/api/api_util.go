@@ -1,20 +1,40 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/hauke96/sigolo"
 	"github.com/hauke96/simple-task-manager/server/auth"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/feature"
+	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/project"
+	"github.com/hauke96/simple-task-manager/server/quota"
+	"github.com/hauke96/simple-task-manager/server/task"
+	"github.com/hauke96/simple-task-manager/server/usage"
 	"github.com/hauke96/simple-task-manager/server/util"
 	"github.com/hauke96/simple-task-manager/server/websocket"
 	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
+// ContentTypeProtobuf is the content type of protobuf encoded responses (see "ProtobufResponse").
+const ContentTypeProtobuf = "application/x-protobuf"
+
 type ApiResponse struct {
-	statusCode int
-	data       interface{}
+	statusCode  int
+	data        interface{}
+	contentType string // Only set for file responses, which write the data as it is instead of encoding it as JSON
+	fileName    string
+	stream      func(w io.Writer) error // Only set for stream responses, see "StreamResponse"
+	headers     map[string]string       // Additional headers, e.g. "Retry-After" for error responses
 }
 
 func BadRequestError(err error) *ApiResponse {
@@ -24,6 +44,29 @@ func BadRequestError(err error) *ApiResponse {
 	}
 }
 
+func UnauthorizedError(err error) *ApiResponse {
+	return &ApiResponse{
+		statusCode: http.StatusUnauthorized,
+		data:       err,
+	}
+}
+
+// TooManyRequestsError tells the client when to try again via the "Retry-After" header.
+func TooManyRequestsError(err *auth.LoginThrottledError) *ApiResponse {
+	return &ApiResponse{
+		statusCode: http.StatusTooManyRequests,
+		data:       err,
+		headers:    map[string]string{"Retry-After": strconv.Itoa(err.RetrySeconds())},
+	}
+}
+
+func ServiceUnavailableError(err error) *ApiResponse {
+	return &ApiResponse{
+		statusCode: http.StatusServiceUnavailable,
+		data:       err,
+	}
+}
+
 func InternalServerError(err error) *ApiResponse {
 	return &ApiResponse{
 		statusCode: http.StatusInternalServerError,
@@ -38,6 +81,61 @@ func JsonResponse(data interface{}) *ApiResponse {
 	}
 }
 
+// FilteredJsonResponse works like "JsonResponse" but only contains the fields requested by the optional "fields" URL
+// parameter (e.g. "?fields=id,name"). Without this parameter, the whole data is returned.
+func FilteredJsonResponse(r *http.Request, data interface{}) *ApiResponse {
+	fields := util.GetListParam("fields", r)
+	if len(fields) == 0 {
+		return JsonResponse(data)
+	}
+
+	filteredData, err := util.FilterFields(data, fields)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error filtering fields of response"))
+	}
+
+	return JsonResponse(filteredData)
+}
+
+// FileResponse writes the given data as it is instead of encoding it as JSON. When a file name is given, the data is
+// sent as attachment with this name.
+func FileResponse(data []byte, contentType string, fileName string) *ApiResponse {
+	return &ApiResponse{
+		statusCode:  http.StatusOK,
+		data:        data,
+		contentType: contentType,
+		fileName:    fileName,
+	}
+}
+
+// StreamResponse works like "FileResponse" but the data is written by the given function while the transaction is
+// still open, e.g. to write large results while reading them from the database. Errors while streaming can't be sent
+// to the client anymore, they're only logged and the response ends early.
+func StreamResponse(contentType string, fileName string, stream func(w io.Writer) error) *ApiResponse {
+	return &ApiResponse{
+		statusCode:  http.StatusOK,
+		contentType: contentType,
+		fileName:    fileName,
+		stream:      stream,
+	}
+}
+
+// ProtobufResponse encodes the message of the rpc package as protobuf, which is much faster to parse than JSON for large
+// responses (e.g. on mobile devices). See "acceptsProtobuf".
+func ProtobufResponse(message proto.Message) *ApiResponse {
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return InternalServerError(errors.Wrap(err, "error encoding protobuf response"))
+	}
+
+	return FileResponse(data, ContentTypeProtobuf, "")
+}
+
+// acceptsProtobuf is true when the client requested a protobuf encoded response via the "Accept" header.
+func acceptsProtobuf(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), ContentTypeProtobuf)
+}
+
 func EmptyResponse() *ApiResponse {
 	return &ApiResponse{
 		statusCode: http.StatusOK,
@@ -62,16 +160,55 @@ func authenticatedTransactionHandler(handler func(r *http.Request, context *Cont
 	}
 }
 
-func authenticatedWebsocket(handler func(w http.ResponseWriter, r *http.Request, token *auth.Token, websocketSender *websocket.WebsocketSender)) func(http.ResponseWriter, *http.Request) {
+// publicTransactionHandler works like "authenticatedTransactionHandler" but for routes called without token, e.g. by
+// images embedded in other websites. The context of such handlers has no token.
+func publicTransactionHandler(handler func(r *http.Request, context *Context) *ApiResponse) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		logger := util.NewLogger()
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+
+		logger := requestLogger(r)
+		setRouteLogFields(r, logger)
+
+		if rejectDuringMaintenance(w, nil, logger) {
+			return
+		}
+
+		handleInTransaction(w, r, nil, logger, handler)
+	}
+}
+
+// loginTransactionHandler works like "publicTransactionHandler" but doesn't reject requests during maintenance. Login
+// handlers have to check the maintenance mode themselves once the user is known, so that admins are still able to log in.
+func loginTransactionHandler(handler func(r *http.Request, context *Context) *ApiResponse) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+
+		logger := requestLogger(r)
+		setRouteLogFields(r, logger)
+
+		handleInTransaction(w, r, nil, logger, handler)
+	}
+}
+
+// websocketHandler establishes websocket connections. Clients either authenticate with the "token" query parameter
+// (deprecated since tokens in URLs end up in logs) or with an "auth" message right after connecting. In the latter
+// case, the token passed to the handler is nil.
+func websocketHandler(handler func(w http.ResponseWriter, r *http.Request, token *auth.Token, websocketSender *websocket.WebsocketSender)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := requestLogger(r)
+		sender := websocket.Init(logger)
+
+		if !feature.IsEnabled(feature.Websocket) {
+			util.ErrorResponse(w, logger, errors.New("websocket updates are disabled on this instance"), http.StatusNotFound)
+			return
+		}
 
 		query := r.URL.Query()
 
 		t := query.Get("token")
 		if t == "" || t == "null" || t == "\u009e" {
-			err := errors.New("could not establish websocket connection: query parameter 'token' not set")
-			util.ResponseUnauthorized(w, logger, err)
+			logger.Debug("No token in websocket URL, client has to authenticate via message")
+			handler(w, r, nil, sender)
 			return
 		}
 		query.Del("token")
@@ -87,20 +224,109 @@ func authenticatedWebsocket(handler func(w http.ResponseWriter, r *http.Request,
 			return
 		}
 
-		sender := websocket.Init(logger)
+		logger.SetField(util.LogFieldUser, token.UID)
+
+		if rejectDuringMaintenance(w, token, logger) {
+			return
+		}
 
 		handler(w, r, token, sender)
 	}
 }
 
-// prepareAndHandle gets and verifies the token from the request, creates the context, starts a transaction, manages
-// commit/rollback, calls the handler and also does error handling. When this function returns, everything should have a
-// valid state: The response as well as the transaction (database).
+// authenticateWebsocket verifies tokens sent via the "auth" message of websocket clients.
+func authenticateWebsocket(logger *util.Logger) websocket.Authenticator {
+	return func(encodedToken string) (string, error) {
+		token, err := auth.VerifyToken(encodedToken, logger)
+		if err != nil {
+			return "", err
+		}
+
+		logger.SetField(util.LogFieldUser, token.UID)
+
+		if getMaintenance().Enabled && !isAdmin(token.UID) {
+			return "", errors.New("maintenance mode is active")
+		}
+
+		return token.UID, nil
+	}
+}
+
+// verifyWebsocketSubscription checks in a separate transaction that the user is a member of the project.
+func verifyWebsocketSubscription(logger *util.Logger) websocket.SubscriptionVerifier {
+	return func(uid string, projectId string) error {
+		ctx := context.Background()
+
+		tx, err := database.GetTransaction(ctx, logger)
+		if err != nil {
+			return errors.Wrap(err, "error getting transaction")
+		}
+		// Nothing has been changed, so there's nothing to commit
+		defer tx.Rollback()
+
+		return permission.Init(ctx, tx, logger).VerifyMembershipProject(projectId, uid)
+	}
+}
+
+// verifyWebsocketTaskView checks in a separate transaction that the user is a member of the project of the task and
+// returns the project and its members.
+func verifyWebsocketTaskView(logger *util.Logger) websocket.TaskViewVerifier {
+	return func(uid string, taskId string) (string, []string, error) {
+		ctx := context.Background()
+
+		tx, err := database.GetTransaction(ctx, logger)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "error getting transaction")
+		}
+		// Nothing has been changed, so there's nothing to commit
+		defer tx.Rollback()
+
+		permissionService := permission.Init(ctx, tx, logger)
+		taskService := task.Init(ctx, tx, logger, permissionService)
+		projectService := project.Init(ctx, tx, logger, taskService, permissionService, quota.Init(ctx, tx, logger))
+
+		p, err := projectService.GetProjectByTask(taskId, uid)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return p.Id, p.Users, nil
+	}
+}
+
+// setRouteLogFields attaches the project or task ID of the called route (e.g. "/projects/{id}/users") to the logger.
+func setRouteLogFields(r *http.Request, logger *util.Logger) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return
+	}
+
+	pathTemplate, err := route.GetPathTemplate()
+	if err != nil {
+		return
+	}
+
+	id, ok := mux.Vars(r)["id"]
+	if !ok {
+		return
+	}
+
+	if strings.Contains(pathTemplate, "/projects/{id}") {
+		logger.SetField(util.LogFieldProject, id)
+	} else if strings.Contains(pathTemplate, "/tasks/{id}") {
+		logger.SetField(util.LogFieldTask, id)
+	}
+}
+
+// prepareAndHandle gets the token verified by the "authenticationMiddleware", creates the context, starts a transaction,
+// manages commit/rollback, calls the handler and also does error handling. When this function returns, everything
+// should have a valid state: The response as well as the transaction (database).
 func prepareAndHandle(w http.ResponseWriter, r *http.Request, handler func(r *http.Request, context *Context) *ApiResponse) {
 	// temporary logger before there's a context
-	logger := util.NewLogger()
+	logger := requestLogger(r)
 
-	token, err := auth.VerifyRequest(r, logger)
+	// The token has already been verified by the "authenticationMiddleware"
+	token, err := requestToken(r)
 	if err != nil {
 		logger.Debug("URL without valid token called: %s", r.URL.Path)
 		logger.Err("Token verification failed: %s", err)
@@ -109,17 +335,52 @@ func prepareAndHandle(w http.ResponseWriter, r *http.Request, handler func(r *ht
 		return
 	}
 
+	setRouteLogFields(r, logger)
+
+	usage.Record(token.UID)
+
+	if rejectDuringMaintenance(w, token, logger) {
+		return
+	}
+
+	handleInTransaction(w, r, token, logger, handler)
+}
+
+// newRequestLogger creates a logger for a request. Reverse proxies might set an ID to find the request in their logs as
+// well, so this ID is attached to the logger.
+func newRequestLogger(r *http.Request) *util.Logger {
+	logger := util.NewLogger()
+
+	requestId := r.Header.Get("X-Request-Id")
+	if requestId != "" {
+		logger.SetField(util.LogFieldRequest, requestId)
+	}
+
+	return logger
+}
+
+// handleInTransaction creates the context, manages commit/rollback, calls the handler and writes the response. The
+// token is nil for public routes.
+func handleInTransaction(w http.ResponseWriter, r *http.Request, token *auth.Token, logger *util.Logger, handler func(r *http.Request, context *Context) *ApiResponse) {
+	caller := "anonymous user"
+	if token != nil {
+		caller = fmt.Sprintf("'%s' (%s)", token.User, token.UID)
+	}
+
 	// Create context with a new transaction and new service instances
-	context, err := createContext(token, logger)
+	context, err := createContext(r.Context(), token, logger)
 	if err != nil {
-		logger.Err("Unable to create context for call user from '%s' (%s) to %s %s: %s", token.User, token.UID, r.Method, r.URL.Path, err)
+		logger.Err("Unable to create context for call from %s to %s %s: %s", caller, r.Method, r.URL.Path, err)
 		logger.Stack(err)
 		// No further information to caller (which is a potential attacker)
 		util.ResponseInternalError(w, logger, errors.New("Unable to create context"))
 		return
 	}
 
-	context.Log("Call from '%s' (%s) to %s %s", token.User, token.UID, r.Method, r.URL.Path)
+	context.Log("Call from %s to %s %s", caller, r.Method, r.URL.Path)
+
+	// Response written when recovering from a panic. Error responses of the handler replace this to keep their status.
+	errorResponse := InternalServerError(nil)
 
 	// Recover from panic and perform rollback on transaction
 	defer func() {
@@ -135,7 +396,10 @@ func prepareAndHandle(w http.ResponseWriter, r *http.Request, handler func(r *ht
 			context.Err(fmt.Sprintf("!! PANIC !! Recover from panic:"))
 			context.Stack(err)
 
-			util.ResponseInternalError(w, context.Logger, err)
+			for key, value := range errorResponse.headers {
+				w.Header().Set(key, value)
+			}
+			util.ErrorResponse(w, context.Logger, err, errorResponse.statusCode)
 
 			context.Log("Try to perform rollback")
 			rollbackErr := context.Transaction.Rollback()
@@ -150,10 +414,16 @@ func prepareAndHandle(w http.ResponseWriter, r *http.Request, handler func(r *ht
 	response = handler(r, context)
 
 	if response.statusCode != http.StatusOK {
+		errorResponse = response
 		// Cause panic which will be recovered using the above function. This will then trigger a transaction rollback.
 		panic(response.data.(error))
 	}
 
+	if response.stream != nil {
+		writeStream(w, context, response)
+		return
+	}
+
 	// Commit transaction
 	err = context.Transaction.Commit()
 	if err != nil {
@@ -162,8 +432,57 @@ func prepareAndHandle(w http.ResponseWriter, r *http.Request, handler func(r *ht
 	}
 	context.Debug("Committed transaction")
 
+	if response.contentType != "" {
+		setFileHeaders(w, response)
+		w.Write(response.data.([]byte))
+		return
+	}
+
 	if response.data != nil {
 		encoder := json.NewEncoder(w)
 		encoder.Encode(response.data)
 	}
 }
+
+// writeStream writes a stream response and commits the transaction afterwards. The status code has already been sent
+// when streaming fails, so the error is only logged and the transaction is rolled back.
+func writeStream(w http.ResponseWriter, context *Context, response *ApiResponse) {
+	setFileHeaders(w, response)
+
+	err := response.stream(w)
+	if err != nil {
+		context.Err("Unable to stream response: %s", err.Error())
+		context.Stack(err)
+
+		rollbackErr := context.Transaction.Rollback()
+		if rollbackErr != nil {
+			context.Stack(errors.Wrap(rollbackErr, "error performing rollback"))
+		}
+		return
+	}
+
+	err = context.Transaction.Commit()
+	if err != nil {
+		context.Err("Unable to commit transaction: %s", err.Error())
+		return
+	}
+	context.Debug("Committed transaction")
+}
+
+func setFileHeaders(w http.ResponseWriter, response *ApiResponse) {
+	w.Header().Set("Content-Type", response.contentType)
+	if response.fileName != "" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", response.fileName))
+	}
+}
+
+// decodeJsonBody strictly decodes and validates the JSON body of the request into the target. The returned error
+// describes the invalid field and is meant to be returned to the client.
+func decodeJsonBody(r *http.Request, target interface{}) error {
+	return util.DecodeJsonBody(r, target, config.Conf.MaxRequestBodySize)
+}
+
+// readBody reads plain (non-JSON) request bodies and rejects too large ones.
+func readBody(r *http.Request) ([]byte, error) {
+	return util.ReadBody(r, config.Conf.MaxRequestBodySize)
+}
@@ -1,21 +1,110 @@
 package api
 
 import (
-	"encoding/json"
+	"fmt"
 	"github.com/gorilla/mux"
+	"github.com/hauke96/simple-task-manager/server/audit"
 	"github.com/hauke96/simple-task-manager/server/auth"
+	"github.com/hauke96/simple-task-manager/server/feature"
+	"github.com/hauke96/simple-task-manager/server/notification"
 	"github.com/hauke96/simple-task-manager/server/project"
+	"github.com/hauke96/simple-task-manager/server/push"
+	"github.com/hauke96/simple-task-manager/server/quota"
+	"github.com/hauke96/simple-task-manager/server/rpc"
+	"github.com/hauke96/simple-task-manager/server/session"
 	"github.com/hauke96/simple-task-manager/server/task"
 	"github.com/hauke96/simple-task-manager/server/util"
 	"github.com/hauke96/simple-task-manager/server/websocket"
 	"github.com/pkg/errors"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 )
 
 type ProjectAddDto struct {
-	Project project.Project `json:"project"`
-	Tasks []*task.Task `json:"tasks"`
+	Project ProjectDto_v2_4 `json:"project"`
+	Tasks []*TaskDto_v2_4 `json:"tasks" validate:"required"`
+}
+
+type SyncDto struct {
+	Operations []*task.Operation `json:"operations"`
+}
+
+type ProjectUsersBatchDto struct {
+	Add    []string `json:"add"`    // IDs of users to add
+	Remove []string `json:"remove"` // IDs of users to remove
+}
+
+type ProjectUsersBatchResultDto struct {
+	Project *ProjectDto_v2_4            `json:"project"`
+	Results []*project.UserChangeResult `json:"results"`
+}
+
+type ProjectChangesetTemplateDto struct {
+	Comment  string   `json:"comment"`  // May contain the placeholders "{taskId}" and "{projectId}"
+	Hashtags []string `json:"hashtags"` // Each starting with "#", may contain the same placeholders as the comment
+}
+
+type ShareLinkDto struct {
+	Token      string    `json:"token"`      // Used in the "/v2.4/shared/{token}" routes
+	ValidUntil time.Time `json:"validUntil"`
+}
+
+type TaskAllowedUsersDto struct {
+	Users []string `json:"users"` // IDs of the users allowed to work on the task, empty allows all members
+}
+
+type TaskDependenciesDto struct {
+	DependsOn []string `json:"dependsOn"` // IDs of the tasks which have to be completed first, empty removes all dependencies
+}
+
+type TaskFlagDto struct {
+	Reason  string `json:"reason" validate:"oneof=bad_imagery unmappable too_large other"` // One of the "FlagReason..." values of the task package
+	Comment string `json:"comment" validate:"max=1000"`
+}
+
+type TaskReopenDto struct {
+	Reason string `json:"reason" validate:"required,max=1000"`
+}
+
+type ProjectChecklistDto struct {
+	Items        task.Checklist `json:"items"`        // New items have no ID, existing items keep theirs
+	DerivePoints bool           `json:"derivePoints"` // Derive the process points of the tasks from their completed items
+}
+
+type TaskChecklistDto struct {
+	CompletedItems []string `json:"completedItems"` // IDs of the completed checklist items of the project
+}
+
+type TaskNoteDto struct {
+	Text string `json:"text" validate:"max=5000"` // An empty text removes the note
+}
+
+type TaskOsmNoteDto struct {
+	Text string `json:"text" validate:"required,max=2000"` // Text of the new OSM note, a hint to the task is added
+}
+
+// EditorLinkDto is the link to open a task in an editor together with the task reserved for the requesting user.
+type EditorLinkDto struct {
+	Url  string        `json:"url"`
+	Task *TaskDto_v2_4 `json:"task"`
+}
+
+// DashboardDto contains everything a user has to take care of, so that clients need only one request for it.
+type DashboardDto struct {
+	AssignedTasks []*AssignedTaskDto_v2_4           `json:"assignedTasks"`
+	OwnedProjects []*ProjectDto_v2_4                `json:"ownedProjects"`
+	JoinRequests  []*project.JoinRequest            `json:"joinRequests"` // Open requests to join one of the owned projects
+	TargetStates  map[string][]*project.TargetState `json:"targetStates"` // States of the targets of the owned projects (project ID -> states), only projects with targets are included
+}
+
+// ProjectChangesDto contains everything that changed since the last sync of a client.
+type ProjectChangesDto struct {
+	Projects   []*ProjectDto_v2_4      `json:"projects"`   // Changed projects of the user
+	Tasks      []*AssignedTaskDto_v2_4 `json:"tasks"`      // Changed tasks of all projects of the user
+	ProjectIds []string                `json:"projectIds"` // IDs of all projects of the user, so that clients can remove the others
+	Cursor     string                  `json:"cursor"`     // Value of the "since" parameter for the next sync
 }
 
 func Init_v2_4(router *mux.Router) (*mux.Router, string) {
@@ -23,49 +112,265 @@ func Init_v2_4(router *mux.Router) (*mux.Router, string) {
 
 	r.HandleFunc("/projects", authenticatedTransactionHandler(getProjects_v2_4)).Methods(http.MethodGet)
 	r.HandleFunc("/projects", authenticatedTransactionHandler(addProject_v2_4)).Methods(http.MethodPost) // NEW
+	r.HandleFunc("/projects/changes", authenticatedTransactionHandler(getProjectChanges_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/projects/nearby", authenticatedTransactionHandler(getNearbyProjects_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/projects/tutorial", authenticatedTransactionHandler(addTutorialProject_v2_4)).Methods(http.MethodPost)
 	r.HandleFunc("/projects/{id}", authenticatedTransactionHandler(getProject_v2_4)).Methods(http.MethodGet)
 	r.HandleFunc("/projects/{id}", authenticatedTransactionHandler(deleteProjects_v2_4)).Methods(http.MethodDelete)
 	r.HandleFunc("/projects/{id}/name", authenticatedTransactionHandler(updateProjectName_v2_4)).Methods(http.MethodPut)
 	r.HandleFunc("/projects/{id}/description", authenticatedTransactionHandler(updateProjectDescription_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/projects/{id}/minChangesets", authenticatedTransactionHandler(updateProjectMinChangesets_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/projects/{id}/pointStep", authenticatedTransactionHandler(updateProjectPointStep_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/projects/{id}/aoi", authenticatedTransactionHandler(updateProjectAoi_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/projects/{id}/public", authenticatedTransactionHandler(updateProjectPublic_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/projects/{id}/unassignAfter", authenticatedTransactionHandler(updateProjectUnassignAfter_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/projects/{id}/assignmentLimits", authenticatedTransactionHandler(updateProjectAssignmentLimits_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/projects/{id}/locale", authenticatedTransactionHandler(updateProjectLocale_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/projects/{id}/descriptions", authenticatedTransactionHandler(updateProjectDescriptions_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/projects/{id}/changesetTemplate", authenticatedTransactionHandler(updateProjectChangesetTemplate_v2_4)).Methods(http.MethodPut)
 	r.HandleFunc("/projects/{id}/users", authenticatedTransactionHandler(addUserToProject_v2_4)).Methods(http.MethodPost)
 	r.HandleFunc("/projects/{id}/users", authenticatedTransactionHandler(leaveProject_v2_4)).Methods(http.MethodDelete)
 	r.HandleFunc("/projects/{id}/users/{uid}", authenticatedTransactionHandler(removeUser_v2_4)).Methods(http.MethodDelete)
+	r.HandleFunc("/projects/{id}/users/batch", authenticatedTransactionHandler(changeUsers_v2_4)).Methods(http.MethodPost)
 	r.HandleFunc("/projects/{id}/tasks", authenticatedTransactionHandler(getProjectTasks_v2_4)).Methods(http.MethodGet)
-
+	r.HandleFunc("/projects/{id}/export", authenticatedTransactionHandler(exportProjectTasks_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/projects/{id}/exports", authenticatedTransactionHandler(addExportJob_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/projects/{id}/preview.png", authenticatedTransactionHandler(getProjectPreview_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/projects/{id}/badge.svg", publicTransactionHandler(getProjectBadge_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/projects/{id}/shareLinks", authenticatedTransactionHandler(createShareLink_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/projects/{id}/snapshots", authenticatedTransactionHandler(getProjectSnapshots_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/projects/{id}/targets", authenticatedTransactionHandler(getProjectTargets_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/projects/{id}/viewers", authenticatedTransactionHandler(getProjectTaskViewers_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/projects/{id}/targets", authenticatedTransactionHandler(updateProjectTargets_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/projects/{id}/layers", authenticatedTransactionHandler(updateProjectLayers_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/projects/{id}/checklist", authenticatedTransactionHandler(updateProjectChecklist_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/projects/{id}/timeline", authenticatedTransactionHandler(getProjectTimeline_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/projects/{id}/digest", authenticatedTransactionHandler(subscribeDigest_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/projects/{id}/digest", authenticatedTransactionHandler(unsubscribeDigest_v2_4)).Methods(http.MethodDelete)
+	r.HandleFunc("/projects/{id}/watch", authenticatedTransactionHandler(watchProject_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/projects/{id}/watch", authenticatedTransactionHandler(unwatchProject_v2_4)).Methods(http.MethodDelete)
+	r.HandleFunc("/projects/{id}/commands", authenticatedTransactionHandler(getProjectCommands_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/projects/{id}/revert/{eventId}", authenticatedTransactionHandler(revertProjectCommand_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/projects/{id}/redo/{eventId}", authenticatedTransactionHandler(redoProjectCommand_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/projects/{id}/joinRequests", authenticatedTransactionHandler(requestJoin_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/projects/{id}/joinRequests", authenticatedTransactionHandler(getJoinRequests_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/projects/{id}/joinRequests/{uid}", authenticatedTransactionHandler(approveJoinRequest_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/projects/{id}/joinRequests/{uid}", authenticatedTransactionHandler(denyJoinRequest_v2_4)).Methods(http.MethodDelete)
+	r.HandleFunc("/projects/{id}/merges", authenticatedTransactionHandler(getMergeRequests_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/projects/{id}/merges/{sourceId}", authenticatedTransactionHandler(requestMerge_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/projects/{id}/merges/{sourceId}", authenticatedTransactionHandler(approveMerge_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/projects/{id}/merges/{sourceId}", authenticatedTransactionHandler(denyMerge_v2_4)).Methods(http.MethodDelete)
+	r.HandleFunc("/projects/{id}/notes", authenticatedTransactionHandler(getTaskNotes_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/projects/{id}/changesetStats", authenticatedTransactionHandler(getChangesetStats_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/projects/{id}/areaStats", authenticatedTransactionHandler(getAreaStats_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/projects/{id}/members/export", authenticatedTransactionHandler(exportProjectMembers_v2_4)).Methods(http.MethodGet)
+
+	r.HandleFunc("/shared/{token}", publicTransactionHandler(getSharedProject_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/shared/{token}/tasks", publicTransactionHandler(getSharedTasks_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/shared/{token}/snapshots", publicTransactionHandler(getSharedSnapshots_v2_4)).Methods(http.MethodGet)
+
+	r.HandleFunc("/tasks/{id}", authenticatedTransactionHandler(getTask_v2_4)).Methods(http.MethodGet)
 	r.HandleFunc("/tasks/{id}/assignedUser", authenticatedTransactionHandler(assignUser_v2_4)).Methods(http.MethodPost)
 	r.HandleFunc("/tasks/{id}/assignedUser", authenticatedTransactionHandler(unassignUser_v2_4)).Methods(http.MethodDelete)
 	r.HandleFunc("/tasks/{id}/processPoints", authenticatedTransactionHandler(setProcessPoints_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/tasks/{id}/checklist", authenticatedTransactionHandler(setTaskChecklist_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/tasks/{id}/difficulty", authenticatedTransactionHandler(setDifficulty_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/tasks/{id}/allowedUsers", authenticatedTransactionHandler(setAllowedUsers_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/tasks/{id}/dependencies", authenticatedTransactionHandler(setDependencies_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/tasks/{id}/flag", authenticatedTransactionHandler(flagTask_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/tasks/{id}/flag", authenticatedTransactionHandler(resolveTaskFlag_v2_4)).Methods(http.MethodDelete)
+	r.HandleFunc("/tasks/{id}/reservation", authenticatedTransactionHandler(reserveTask_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/tasks/{id}/reservation", authenticatedTransactionHandler(releaseTaskReservation_v2_4)).Methods(http.MethodDelete)
+	r.HandleFunc("/tasks/{id}/reopen", authenticatedTransactionHandler(reopenTask_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/tasks/{id}/handover", authenticatedTransactionHandler(requestHandover_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/tasks/{id}/handover", authenticatedTransactionHandler(acceptHandover_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/tasks/{id}/handover", authenticatedTransactionHandler(removeHandover_v2_4)).Methods(http.MethodDelete)
+	r.HandleFunc("/tasks/{id}/note", authenticatedTransactionHandler(getTaskNote_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/tasks/{id}/note", authenticatedTransactionHandler(setTaskNote_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/tasks/{id}/changesets/{changesetId}", authenticatedTransactionHandler(linkChangeset_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/tasks/{id}/osmNotes", authenticatedTransactionHandler(getOsmNotes_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/tasks/{id}/osmNotes", authenticatedTransactionHandler(createOsmNote_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/tasks/{id}/osmNotes/{noteId}", authenticatedTransactionHandler(linkOsmNote_v2_4)).Methods(http.MethodPut)
 	//r.HandleFunc("/tasks", authenticatedTransactionHandler(addTasks_v2_3)).Methods(http.MethodPost)
 
-	r.HandleFunc("/updates", authenticatedWebsocket(getWebsocketConnection))
+	r.HandleFunc("/exports/{id}", authenticatedTransactionHandler(getExportJob_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/exports/{id}/file", authenticatedTransactionHandler(getExportFile_v2_4)).Methods(http.MethodGet)
+
+	r.HandleFunc("/sync", authenticatedTransactionHandler(sync_v2_4)).Methods(http.MethodPost)
+
+	r.HandleFunc("/user/contributions", authenticatedTransactionHandler(getContributions_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/user/tasks", authenticatedTransactionHandler(getAssignedTasks_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/user/handovers", authenticatedTransactionHandler(getHandovers_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/user/watchedProjects", authenticatedTransactionHandler(getWatchedProjects_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/user/dashboard", authenticatedTransactionHandler(getDashboard_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/user/notifications", authenticatedTransactionHandler(getNotifications_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/user/notifications/read", authenticatedTransactionHandler(markAllNotificationsRead_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/user/notifications/{id}/read", authenticatedTransactionHandler(markNotificationRead_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/user/pushSubscriptions", authenticatedTransactionHandler(subscribePush_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/user/pushSubscriptions", authenticatedTransactionHandler(unsubscribePush_v2_4)).Methods(http.MethodDelete)
+	r.HandleFunc("/user/sessions", authenticatedTransactionHandler(getSessions_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/user/sessions", authenticatedTransactionHandler(revokeOtherSessions_v2_4)).Methods(http.MethodDelete)
+	r.HandleFunc("/user/sessions/{id}", authenticatedTransactionHandler(revokeSession_v2_4)).Methods(http.MethodDelete)
+
+	r.HandleFunc("/maintenance", authenticatedTransactionHandler(setMaintenance_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/usage", authenticatedTransactionHandler(getUsage_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/audit", authenticatedTransactionHandler(exportAuditLog_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/retention", authenticatedTransactionHandler(getRetention_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/loginThrottle", authenticatedTransactionHandler(getLoginThrottle_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/consistency", authenticatedTransactionHandler(getConsistency_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/consistency/repair", authenticatedTransactionHandler(repairConsistency_v2_4)).Methods(http.MethodPost)
+	r.HandleFunc("/features", authenticatedTransactionHandler(getFeatures_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/features/{name}", authenticatedTransactionHandler(setFeature_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/features/{name}", authenticatedTransactionHandler(resetFeature_v2_4)).Methods(http.MethodDelete)
+	r.HandleFunc("/quotas/{uid}", authenticatedTransactionHandler(getQuota_v2_4)).Methods(http.MethodGet)
+	r.HandleFunc("/quotas/{uid}", authenticatedTransactionHandler(setQuota_v2_4)).Methods(http.MethodPut)
+	r.HandleFunc("/quotas/{uid}", authenticatedTransactionHandler(resetQuota_v2_4)).Methods(http.MethodDelete)
+
+	if auth.IsLocalBackend() {
+		r.HandleFunc("/accounts", authenticatedTransactionHandler(getAccounts_v2_4)).Methods(http.MethodGet)
+		r.HandleFunc("/accounts/{uid}", authenticatedTransactionHandler(addAccount_v2_4)).Methods(http.MethodPost)
+		r.HandleFunc("/accounts/{uid}", authenticatedTransactionHandler(removeAccount_v2_4)).Methods(http.MethodDelete)
+		r.HandleFunc("/accounts/{uid}/key", authenticatedTransactionHandler(renewAccountKey_v2_4)).Methods(http.MethodPut)
+	} else {
+		r.HandleFunc("/invitations", authenticatedTransactionHandler(getInvitations_v2_4)).Methods(http.MethodGet)
+		r.HandleFunc("/invitations/{name}", authenticatedTransactionHandler(invite_v2_4)).Methods(http.MethodPut)
+		r.HandleFunc("/invitations/{name}", authenticatedTransactionHandler(removeInvitation_v2_4)).Methods(http.MethodDelete)
+	}
+
+	r.HandleFunc("/updates", websocketHandler(getWebsocketConnection))
 
 	return r, "v2.4"
 }
 
 func getProjects_v2_4(r *http.Request, context *Context) *ApiResponse {
-	projects, err := context.ProjectService.GetProjects(context.Token.UID)
+	projects, err := context.ProjectService.GetProjects(context.UserId())
 	if err != nil {
 		return InternalServerError(err)
 	}
 
+	if r.FormValue("since") != "" {
+		since, err := util.GetTimeParam("since", r)
+		if err != nil {
+			return BadRequestError(errors.Wrap(err, "url param 'since' is not a RFC 3339 timestamp"))
+		}
+
+		projects = project.FilterChangedSince(projects, since)
+	}
+
+	for _, p := range projects {
+		p.Localize(r.Header.Get("Accept-Language"))
+	}
+
 	context.Log("Successfully got projects")
 
-	return JsonResponse(projects)
+	return FilteredJsonResponse(r, toProjectDtos_v2_4(projects))
 }
 
-func addProject_v2_4(r *http.Request, context *Context) *ApiResponse {
-	bodyBytes, err := ioutil.ReadAll(r.Body)
+func getNearbyProjects_v2_4(r *http.Request, context *Context) *ApiResponse {
+	lat, err := util.GetFloatParam("lat", r)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "url param 'lat' not set or not a number"))
+	}
+
+	lon, err := util.GetFloatParam("lon", r)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "url param 'lon' not set or not a number"))
+	}
+
+	radius, err := util.GetFloatParam("radius", r)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "url param 'radius' not set or not a number"))
+	}
+
+	projects, err := context.ProjectService.GetNearbyProjects(lat, lon, radius)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got %d nearby projects", len(projects))
+
+	return JsonResponse(toNearbyProjectDtos_v2_4(projects))
+}
+
+func getProjectChanges_v2_4(r *http.Request, context *Context) *ApiResponse {
+	// Without "since" parameter, everything is returned (e.g. for the first sync of a client)
+	var since time.Time
+	if r.FormValue("since") != "" {
+		var err error
+		since, err = util.GetTimeParam("since", r)
+		if err != nil {
+			return BadRequestError(errors.Wrap(err, "url param 'since' is not a RFC 3339 timestamp"))
+		}
+	}
+
+	projects, err := context.ProjectService.GetProjects(context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	tasks, err := context.TaskService.GetChangedTasks(context.UserId(), since)
 	if err != nil {
-		return BadRequestError(errors.Wrap(err, "error reading request body"))
+		return InternalServerError(err)
 	}
 
+	// The cursor is the latest change the client knows about after this sync
+	cursor := since
+	projectIds := make([]string, len(projects))
+	for i, p := range projects {
+		projectIds[i] = p.Id
+		if p.UpdatedAt.After(cursor) {
+			cursor = p.UpdatedAt
+		}
+	}
+	for _, t := range tasks {
+		if t.UpdatedAt.After(cursor) {
+			cursor = t.UpdatedAt
+		}
+	}
+
+	changedProjects := project.FilterChangedSince(projects, since)
+	for _, p := range changedProjects {
+		p.Localize(r.Header.Get("Accept-Language"))
+	}
+
+	context.Log("Successfully got %d changed projects and %d changed tasks", len(changedProjects), len(tasks))
+
+	return JsonResponse(ProjectChangesDto{
+		Projects:   toProjectDtos_v2_4(changedProjects),
+		Tasks:      toAssignedTaskDtos_v2_4(tasks),
+		ProjectIds: projectIds,
+		Cursor:     cursor.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+func addProject_v2_4(r *http.Request, context *Context) *ApiResponse {
 	var dto ProjectAddDto
-	err = json.Unmarshal(bodyBytes, &dto)
+	err := decodeJsonBody(r, &dto)
 	if err != nil {
-		return InternalServerError(errors.Wrap(err, "error unmarshalling project draft"))
+		return BadRequestError(errors.Wrap(err, "error unmarshalling project draft"))
+	}
+
+	// The CRS of a task overrides the one of the request
+	defaultCrs := r.FormValue("crs")
+
+	for _, t := range dto.Tasks {
+		t.Geometry, err = task.ConvertGeometry(t.Geometry, t.GeometryFormat)
+		if err != nil {
+			return BadRequestError(errors.Wrap(err, "error converting task geometry"))
+		}
+
+		crs := t.Crs
+		if crs == "" {
+			crs = defaultCrs
+		}
+
+		t.Geometry, err = task.ReprojectGeometry(t.Geometry, crs)
+		if err != nil {
+			return BadRequestError(errors.Wrap(err, "error reprojecting task geometry"))
+		}
 	}
 
-	addedProject, err := context.ProjectService.AddProjectWithTasks(&dto.Project, dto.Tasks)
+	addedProject, err := context.ProjectService.AddProjectWithTasks(toProjectModel_v2_4(&dto.Project), toTaskModels_v2_4(dto.Tasks))
 	if err != nil {
 		return InternalServerError(errors.Wrap(err, "error adding project with tasks"))
 	}
@@ -74,7 +379,20 @@ func addProject_v2_4(r *http.Request, context *Context) *ApiResponse {
 
 	context.Log("Successfully added project %s with %d tasks", addedProject.Id, len(dto.Tasks))
 
-	return JsonResponse(addedProject)
+	return JsonResponse(toProjectDto_v2_4(addedProject))
+}
+
+func addTutorialProject_v2_4(r *http.Request, context *Context) *ApiResponse {
+	addedProject, err := context.ProjectService.CreateTutorialProject(context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	sendAdd(context.WebsocketSender, addedProject)
+
+	context.Log("Successfully added tutorial project %s", addedProject.Id)
+
+	return JsonResponse(toProjectDto_v2_4(addedProject))
 }
 
 func getProject_v2_4(r *http.Request, context *Context) *ApiResponse {
@@ -84,14 +402,16 @@ func getProject_v2_4(r *http.Request, context *Context) *ApiResponse {
 		return BadRequestError(errors.New("url segment 'id' not set"))
 	}
 
-	project, err := context.ProjectService.GetProject(projectId, context.Token.UID)
+	project, err := context.ProjectService.GetProject(projectId, context.UserId())
 	if err != nil {
 		return InternalServerError(err)
 	}
 
+	project.Localize(r.Header.Get("Accept-Language"))
+
 	context.Log("Successfully got project project %s", projectId)
 
-	return JsonResponse(project)
+	return FilteredJsonResponse(r, toProjectDto_v2_4(project))
 }
 
 func leaveProject_v2_4(r *http.Request, context *Context) *ApiResponse {
@@ -101,14 +421,14 @@ func leaveProject_v2_4(r *http.Request, context *Context) *ApiResponse {
 		return BadRequestError(errors.New("url segment 'id' not set"))
 	}
 
-	updatedProject, err := context.ProjectService.RemoveUser(projectId, context.Token.UID, context.Token.UID)
+	updatedProject, err := context.ProjectService.RemoveUser(projectId, context.UserId(), context.UserId())
 	if err != nil {
 		return InternalServerError(err)
 	}
 
-	sendUserRemoved(context.WebsocketSender, updatedProject, context.Token.UID)
+	sendUserRemoved(context.WebsocketSender, updatedProject, context.UserId())
 
-	context.Log("Successfully removed user '%s' from project %s (user left)", context.Token.UID, projectId)
+	context.Log("Successfully removed user '%s' from project %s (user left)", context.UserId(), projectId)
 
 	return EmptyResponse()
 }
@@ -125,16 +445,21 @@ func removeUser_v2_4(r *http.Request, context *Context) *ApiResponse {
 		return BadRequestError(errors.New("url segment 'uid' not set"))
 	}
 
-	updatedProject, err := context.ProjectService.RemoveUser(projectId, context.Token.UID, userToRemove)
+	updatedProject, err := context.ProjectService.RemoveUser(projectId, context.UserId(), userToRemove)
 	if err != nil {
 		return InternalServerError(err)
 	}
 
 	sendUserRemoved(context.WebsocketSender, updatedProject, userToRemove)
 
+	err = notifyMembershipChange(context, updatedProject, userToRemove, notification.TypeProjectUserRemoved)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
 	context.Log("Successfully removed user '%s' from project %s", userToRemove, projectId)
 
-	return JsonResponse(updatedProject)
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
 }
 
 func deleteProjects_v2_4(r *http.Request, context *Context) *ApiResponse {
@@ -144,12 +469,12 @@ func deleteProjects_v2_4(r *http.Request, context *Context) *ApiResponse {
 		return BadRequestError(errors.New("url segment 'id' not set"))
 	}
 
-	projectToDelete, err := context.ProjectService.GetProject(projectId, context.Token.UID)
+	projectToDelete, err := context.ProjectService.GetProject(projectId, context.UserId())
 	if err != nil {
 		return InternalServerError(err)
 	}
 
-	err = context.ProjectService.DeleteProject(projectId, context.Token.UID)
+	err = context.ProjectService.DeleteProject(projectId, context.UserId())
 	if err != nil {
 		return InternalServerError(err)
 	}
@@ -161,219 +486,2579 @@ func deleteProjects_v2_4(r *http.Request, context *Context) *ApiResponse {
 	return EmptyResponse()
 }
 
-func updateProjectName_v2_4(r *http.Request, context *Context) *ApiResponse {
+func updateProjectMinChangesets_v2_4(r *http.Request, context *Context) *ApiResponse {
 	vars := mux.Vars(r)
 	projectId, ok := vars["id"]
 	if !ok {
 		return BadRequestError(errors.New("url segment 'id' not set"))
 	}
 
-	bodyBytes, err := ioutil.ReadAll(r.Body)
+	minChangesets, err := util.GetIntParam("min_changesets", r)
 	if err != nil {
-		return InternalServerError(errors.Wrap(err, "error reading request body"))
+		return BadRequestError(errors.Wrap(err, "url param 'min_changesets' not set or not a number"))
 	}
 
-	updatedProject, err := context.ProjectService.UpdateName(projectId, string(bodyBytes), context.Token.UID)
+	updatedProject, err := context.ProjectService.UpdateMinChangesets(projectId, minChangesets, context.UserId())
 	if err != nil {
 		return InternalServerError(err)
 	}
 
 	sendUpdate(context.WebsocketSender, updatedProject)
 
-	context.Log("Successfully updated name of project %s", projectId)
+	context.Log("Successfully updated minimum number of changesets of project %s", projectId)
 
-	return JsonResponse(updatedProject)
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
 }
 
-func updateProjectDescription_v2_4(r *http.Request, context *Context) *ApiResponse {
+func updateProjectPointStep_v2_4(r *http.Request, context *Context) *ApiResponse {
 	vars := mux.Vars(r)
 	projectId, ok := vars["id"]
 	if !ok {
 		return BadRequestError(errors.New("url segment 'id' not set"))
 	}
 
-	bodyBytes, err := ioutil.ReadAll(r.Body)
+	pointStep, err := util.GetIntParam("point_step", r)
 	if err != nil {
-		return InternalServerError(errors.Wrap(err, "error reading request body"))
+		return BadRequestError(errors.Wrap(err, "url param 'point_step' not set or not a number"))
 	}
 
-	updatedProject, err := context.ProjectService.UpdateDescription(projectId, string(bodyBytes), context.Token.UID)
+	updatedProject, err := context.ProjectService.UpdatePointStep(projectId, pointStep, context.UserId())
 	if err != nil {
 		return InternalServerError(err)
 	}
 
 	sendUpdate(context.WebsocketSender, updatedProject)
 
-	context.Log("Successfully updated description of project %s", projectId)
+	context.Log("Successfully updated point step of project %s", projectId)
 
-	return JsonResponse(updatedProject)
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
 }
 
-func getProjectTasks_v2_4(r *http.Request, context *Context) *ApiResponse {
+func updateProjectAoi_v2_4(r *http.Request, context *Context) *ApiResponse {
 	vars := mux.Vars(r)
 	projectId, ok := vars["id"]
 	if !ok {
 		return BadRequestError(errors.New("url segment 'id' not set"))
 	}
 
-	tasks, err := context.TaskService.GetTasks(projectId, context.Token.UID)
+	bodyBytes, err := readBody(r)
+	if err != nil {
+		return BadRequestError(err)
+	}
+
+	updatedProject, err := context.ProjectService.UpdateAoi(projectId, strings.TrimSpace(string(bodyBytes)), context.UserId())
 	if err != nil {
 		return InternalServerError(err)
 	}
 
-	context.Log("Successfully got tasks of project %s", projectId)
+	sendUpdate(context.WebsocketSender, updatedProject)
 
-	return JsonResponse(tasks)
+	context.Log("Successfully updated AOI of project %s", projectId)
+
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
 }
 
-func addUserToProject_v2_4(r *http.Request, context *Context) *ApiResponse {
-	userToAdd, err := util.GetParam("uid", r)
+func updateProjectPublic_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	public, err := util.GetBoolParam("public", r)
 	if err != nil {
-		return BadRequestError(errors.Wrap(err, "url param 'uid' not set"))
+		return BadRequestError(errors.Wrap(err, "url param 'public' not set or not a boolean"))
 	}
 
+	updatedProject, err := context.ProjectService.UpdatePublic(projectId, public, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	sendUpdate(context.WebsocketSender, updatedProject)
+
+	context.Log("Successfully set project %s to public=%v", projectId, public)
+
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
+}
+
+func updateProjectUnassignAfter_v2_4(r *http.Request, context *Context) *ApiResponse {
 	vars := mux.Vars(r)
 	projectId, ok := vars["id"]
 	if !ok {
 		return BadRequestError(errors.New("url segment 'id' not set"))
 	}
 
-	updatedProject, err := context.ProjectService.AddUser(projectId, userToAdd, context.Token.UID)
+	hours, err := util.GetIntParam("hours", r)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "url param 'hours' not set or not a number"))
+	}
+
+	updatedProject, err := context.ProjectService.UpdateUnassignAfter(projectId, hours, context.UserId())
 	if err != nil {
 		return InternalServerError(err)
 	}
 
 	sendUpdate(context.WebsocketSender, updatedProject)
 
-	context.Log("Successfully added user '%s' to project %s", userToAdd, projectId)
+	context.Log("Successfully updated hours until inactive tasks of project %s get unassigned", projectId)
 
-	return JsonResponse(updatedProject)
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
 }
 
-func assignUser_v2_4(r *http.Request, context *Context) *ApiResponse {
+func updateProjectAssignmentLimits_v2_4(r *http.Request, context *Context) *ApiResponse {
 	vars := mux.Vars(r)
-	taskId, ok := vars["id"]
+	projectId, ok := vars["id"]
 	if !ok {
 		return BadRequestError(errors.New("url segment 'id' not set"))
 	}
 
-	user := context.Token.UID
+	maxAssignedTasks, err := util.GetIntParam("max_assigned_tasks", r)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "url param 'max_assigned_tasks' not set or not a number"))
+	}
 
-	task, err := context.TaskService.AssignUser(taskId, user)
+	maxCompletions, err := util.GetIntParam("max_completions", r)
 	if err != nil {
-		return InternalServerError(err)
+		return BadRequestError(errors.Wrap(err, "url param 'max_completions' not set or not a number"))
 	}
 
-	// Send via websockets
-	err = sendTaskUpdate(context.WebsocketSender, task, user, context)
+	updatedProject, err := context.ProjectService.UpdateAssignmentLimits(projectId, maxAssignedTasks, maxCompletions, context.UserId())
 	if err != nil {
 		return InternalServerError(err)
 	}
 
-	context.Log("Successfully assigned user '%s' to task '%s'", user, taskId)
+	sendUpdate(context.WebsocketSender, updatedProject)
 
-	return JsonResponse(*task)
+	context.Log("Successfully updated assignment limits of project %s", projectId)
+
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
 }
 
-func unassignUser_v2_4(r *http.Request, context *Context) *ApiResponse {
+func updateProjectLocale_v2_4(r *http.Request, context *Context) *ApiResponse {
 	vars := mux.Vars(r)
-	taskId, ok := vars["id"]
+	projectId, ok := vars["id"]
 	if !ok {
 		return BadRequestError(errors.New("url segment 'id' not set"))
 	}
 
-	user := context.Token.UID
+	// An empty locale is allowed and means that the language of the description is unknown
+	locale := r.FormValue("locale")
 
-	task, err := context.TaskService.UnassignUser(taskId, user)
+	updatedProject, err := context.ProjectService.UpdateLocale(projectId, locale, context.UserId())
 	if err != nil {
 		return InternalServerError(err)
 	}
 
-	// Send via websockets
-	err = sendTaskUpdate(context.WebsocketSender, task, user, context)
+	sendUpdate(context.WebsocketSender, updatedProject)
+
+	context.Log("Successfully updated locale of project %s", projectId)
+
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
+}
+
+func updateProjectDescriptions_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	var descriptions map[string]string
+	err := decodeJsonBody(r, &descriptions)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling descriptions"))
+	}
+
+	updatedProject, err := context.ProjectService.UpdateDescriptions(projectId, descriptions, context.UserId())
 	if err != nil {
 		return InternalServerError(err)
 	}
 
-	context.Log("Successfully unassigned user '%s' from task '%s'", user, taskId)
+	sendUpdate(context.WebsocketSender, updatedProject)
+
+	context.Log("Successfully updated translated descriptions of project %s", projectId)
 
-	return JsonResponse(*task)
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
 }
 
-func setProcessPoints_v2_4(r *http.Request, context *Context) *ApiResponse {
+func updateProjectChangesetTemplate_v2_4(r *http.Request, context *Context) *ApiResponse {
 	vars := mux.Vars(r)
-	taskId, ok := vars["id"]
+	projectId, ok := vars["id"]
 	if !ok {
 		return BadRequestError(errors.New("url segment 'id' not set"))
 	}
 
-	processPoints, err := util.GetIntParam("process_points", r)
+	var dto ProjectChangesetTemplateDto
+	err := decodeJsonBody(r, &dto)
 	if err != nil {
-		return BadRequestError(errors.Wrap(err, "url üarameter 'process_point' not set"))
+		return BadRequestError(errors.Wrap(err, "error unmarshalling changeset template"))
 	}
 
-	task, err := context.TaskService.SetProcessPoints(taskId, processPoints, context.Token.UID)
+	updatedProject, err := context.ProjectService.UpdateChangesetTemplate(projectId, dto.Comment, dto.Hashtags, context.UserId())
 	if err != nil {
 		return InternalServerError(err)
 	}
 
-	// Send via websockets
-	err = sendTaskUpdate(context.WebsocketSender, task, context.Token.UID, context)
+	sendUpdate(context.WebsocketSender, updatedProject)
+
+	context.Log("Successfully updated changeset template of project %s", projectId)
+
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
+}
+
+func updateProjectName_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	bodyBytes, err := readBody(r)
+	if err != nil {
+		return BadRequestError(err)
+	}
+
+	updatedProject, err := context.ProjectService.UpdateName(projectId, string(bodyBytes), context.UserId())
 	if err != nil {
 		return InternalServerError(err)
 	}
 
-	context.Log("Successfully set process points on task '%s' to %d", taskId, processPoints)
+	sendUpdate(context.WebsocketSender, updatedProject)
 
-	return JsonResponse(*task)
-}
+	context.Log("Successfully updated name of project %s", projectId)
 
-func getWebsocketConnection(w http.ResponseWriter, r *http.Request, token *auth.Token, websocketSender *websocket.WebsocketSender) {
-	websocketSender.GetWebsocketConnection(w, r, token.UID)
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
 }
 
-func sendAdd(sender *websocket.WebsocketSender, addedProject *project.Project) {
-	sender.Send(websocket.Message{
-		Type: websocket.MessageType_ProjectAdded,
-		Data: addedProject,
-	}, addedProject.Users...)
-}
+func updateProjectDescription_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
 
-func sendUpdate(sender *websocket.WebsocketSender, updatedProject *project.Project) {
-	sender.Send(websocket.Message{
-		Type: websocket.MessageType_ProjectUpdated,
-		Data: updatedProject,
-	}, updatedProject.Users...)
-}
+	bodyBytes, err := readBody(r)
+	if err != nil {
+		return BadRequestError(err)
+	}
 
-func sendUserRemoved(sender *websocket.WebsocketSender, updatedProject *project.Project, removedUser string) {
-	sender.Send(websocket.Message{
-		Type: websocket.MessageType_ProjectUpdated,
-		Data: updatedProject,
-	}, updatedProject.Users...)
-	sender.Send(websocket.Message{
-		Type: websocket.MessageType_ProjectUserRemoved,
-		Data: updatedProject.Id,
-	}, removedUser)
-}
+	updatedProject, err := context.ProjectService.UpdateDescription(projectId, string(bodyBytes), context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
 
-func sendDelete(sender *websocket.WebsocketSender, removedProject *project.Project) {
-	sender.Send(websocket.Message{
-		Type: websocket.MessageType_ProjectDeleted,
-		Data: removedProject.Id,
-	}, removedProject.Users...)
+	sendUpdate(context.WebsocketSender, updatedProject)
+
+	context.Log("Successfully updated description of project %s", projectId)
+
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
 }
 
-func sendTaskUpdate(sender *websocket.WebsocketSender, task *task.Task, userId string, context *Context) error {
-	project, err := context.ProjectService.GetProjectByTask(task.Id, userId)
+func changeUsers_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	var dto ProjectUsersBatchDto
+	err := decodeJsonBody(r, &dto)
 	if err != nil {
-		return err
+		return BadRequestError(errors.Wrap(err, "error unmarshalling user changes"))
 	}
 
-	sender.Send(websocket.Message{
-		Type: websocket.MessageType_ProjectUpdated,
-		Data: project,
-	}, project.Users...)
+	updatedProject, results, err := context.ProjectService.ChangeUsers(projectId, dto.Add, dto.Remove, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
 
-	return nil
-}
\ No newline at end of file
+	sendUpdate(context.WebsocketSender, updatedProject)
+	for _, result := range results {
+		if !result.Success {
+			continue
+		}
+
+		notificationType := notification.TypeProjectUserAdded
+		if result.Action == project.UserChangeRemove {
+			notificationType = notification.TypeProjectUserRemoved
+			context.WebsocketSender.Send(websocket.Message{
+				Type:      websocket.MessageType_ProjectUserRemoved,
+				ProjectId: updatedProject.Id,
+				Data:      updatedProject.Id,
+			}, result.UserId)
+		}
+
+		err = notifyMembershipChange(context, updatedProject, result.UserId, notificationType)
+		if err != nil {
+			return InternalServerError(err)
+		}
+	}
+
+	context.Log("Successfully changed users of project %s", projectId)
+
+	return JsonResponse(ProjectUsersBatchResultDto{
+		Project: toProjectDto_v2_4(updatedProject),
+		Results: results,
+	})
+}
+
+func getProjectTasks_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	var tasks []*task.Task
+	if r.FormValue("simplify") != "" {
+		tolerance, err := util.GetFloatParam("simplify", r)
+		if err != nil {
+			return BadRequestError(errors.Wrap(err, "url param 'simplify' is not a number"))
+		}
+
+		tasks, err = context.TaskService.GetSimplifiedTasks(projectId, tolerance, context.UserId())
+		if err != nil {
+			return InternalServerError(err)
+		}
+	} else {
+		var err error
+		tasks, err = context.TaskService.GetTasks(projectId, context.UserId())
+		if err != nil {
+			return InternalServerError(err)
+		}
+	}
+
+	difficulties := util.GetListParam("difficulty", r)
+	if len(difficulties) != 0 {
+		for _, d := range difficulties {
+			if !task.IsValidDifficulty(d) {
+				return BadRequestError(errors.New(fmt.Sprintf("unknown difficulty '%s'", d)))
+			}
+		}
+
+		tasks = task.FilterByDifficulty(tasks, difficulties)
+	}
+
+	if r.FormValue("since") != "" {
+		since, err := util.GetTimeParam("since", r)
+		if err != nil {
+			return BadRequestError(errors.Wrap(err, "url param 'since' is not a RFC 3339 timestamp"))
+		}
+
+		tasks = task.FilterChangedSince(tasks, since)
+	}
+
+	context.Log("Successfully got tasks of project %s", projectId)
+
+	if acceptsProtobuf(r) {
+		return ProtobufResponse(&rpc.TaskList{Tasks: toTaskMessages(tasks)})
+	}
+
+	return FilteredJsonResponse(r, toTaskDtos_v2_4(tasks))
+}
+
+func exportProjectTasks_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	format, err := util.GetParam("format", r)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "url param 'format' not set"))
+	}
+
+	var contentType string
+	switch format {
+	case task.ExportFormatGpx:
+		contentType = "application/gpx+xml"
+	case task.ExportFormatOsm:
+		contentType = "application/x-osm+xml"
+	default:
+		return BadRequestError(errors.New(fmt.Sprintf("unknown export format '%s'", format)))
+	}
+
+	data, err := context.TaskService.ExportDoneTasks(projectId, format, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully exported done tasks of project %s as %s", projectId, format)
+
+	return FileResponse(data, contentType, fmt.Sprintf("project-%s.%s", projectId, format))
+}
+
+func addExportJob_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	format, err := util.GetParam("format", r)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "url param 'format' not set"))
+	}
+
+	job, err := context.ExportService.AddJob(projectId, format, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully added export job %s for project %s", job.Id, projectId)
+
+	return JsonResponse(job)
+}
+
+func getExportJob_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	jobId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	job, err := context.ExportService.GetJob(jobId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got export job %s", jobId)
+
+	return JsonResponse(job)
+}
+
+func getExportFile_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	jobId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	file, err := context.ExportService.GetFile(jobId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got file of export job %s", jobId)
+
+	return FileResponse(file.Data, file.ContentType, file.Name)
+}
+
+func getProjectCommands_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	commands, err := context.ProjectService.GetCommands(projectId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got %d commands of project %s", len(commands), projectId)
+
+	return JsonResponse(commands)
+}
+
+func revertProjectCommand_v2_4(r *http.Request, context *Context) *ApiResponse {
+	return changeProjectCommand_v2_4(r, context, context.ProjectService.RevertCommand, "reverted")
+}
+
+func redoProjectCommand_v2_4(r *http.Request, context *Context) *ApiResponse {
+	return changeProjectCommand_v2_4(r, context, context.ProjectService.RedoCommand, "redid")
+}
+
+// changeProjectCommand_v2_4 reverts or redoes a command and informs the members about the changed project.
+func changeProjectCommand_v2_4(r *http.Request, context *Context, change func(projectId string, commandId string, requestingUserId string) (*project.Project, error), action string) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	commandId, ok := vars["eventId"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'eventId' not set"))
+	}
+
+	updatedProject, err := change(projectId, commandId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	sendUpdate(context.WebsocketSender, updatedProject)
+
+	context.Log("Successfully %s command %s of project %s", action, commandId, projectId)
+
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
+}
+
+func getProjectPreview_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	size := 512
+	if r.FormValue("size") != "" {
+		var err error
+		size, err = util.GetIntParam("size", r)
+		if err != nil {
+			return BadRequestError(errors.Wrap(err, "url param 'size' is not a number"))
+		}
+	}
+
+	data, err := context.TaskService.RenderProjectPreview(projectId, size, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully rendered preview of project %s", projectId)
+
+	return FileResponse(data, "image/png", "")
+}
+
+func getProjectTimeline_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	events, err := context.TaskService.GetTimeline(projectId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got %d timeline events of project %s", len(events), projectId)
+
+	return JsonResponse(events)
+}
+
+// getProjectBadge_v2_4 is called without token (e.g. by wiki pages embedding the badge), so the context has no token.
+func getProjectBadge_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	data, err := context.ProjectService.RenderStatusBadge(projectId)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully rendered status badge of project %s", projectId)
+
+	return FileResponse(data, "image/svg+xml", "")
+}
+
+func createShareLink_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	validityString, err := util.GetParam("validity", r)
+	if err != nil {
+		return BadRequestError(err)
+	}
+
+	validity, err := time.ParseDuration(validityString)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "url param 'validity' is not a duration"))
+	}
+
+	token, validUntil, err := context.ProjectService.CreateShareLink(projectId, validity, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully created share link for project %s", projectId)
+
+	return JsonResponse(&ShareLinkDto{
+		Token:      token,
+		ValidUntil: validUntil,
+	})
+}
+
+// getSharedProjectId verifies the share token of the URL and returns the ID of the shared project. The shared routes
+// are called without a user token, so the context has no token.
+func getSharedProjectId(r *http.Request, context *Context) (string, error) {
+	vars := mux.Vars(r)
+	encodedToken, ok := vars["token"]
+	if !ok {
+		return "", errors.New("url segment 'token' not set")
+	}
+
+	shareToken, err := auth.VerifyShareToken(encodedToken, context.Logger)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid share token")
+	}
+
+	context.SetField(util.LogFieldProject, shareToken.ProjectId)
+
+	return shareToken.ProjectId, nil
+}
+
+func getSharedProject_v2_4(r *http.Request, context *Context) *ApiResponse {
+	projectId, err := getSharedProjectId(r, context)
+	if err != nil {
+		return BadRequestError(err)
+	}
+
+	project, err := context.ProjectService.GetSharedProject(projectId)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	project.Localize(r.Header.Get("Accept-Language"))
+
+	context.Log("Successfully got shared project %s", projectId)
+
+	return JsonResponse(toProjectDto_v2_4(project))
+}
+
+func getSharedTasks_v2_4(r *http.Request, context *Context) *ApiResponse {
+	projectId, err := getSharedProjectId(r, context)
+	if err != nil {
+		return BadRequestError(err)
+	}
+
+	tasks, err := context.TaskService.GetSharedTasks(projectId)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got tasks of shared project %s", projectId)
+
+	if acceptsProtobuf(r) {
+		return ProtobufResponse(&rpc.TaskList{Tasks: toTaskMessages(tasks)})
+	}
+
+	return JsonResponse(toTaskDtos_v2_4(tasks))
+}
+
+func getSharedSnapshots_v2_4(r *http.Request, context *Context) *ApiResponse {
+	projectId, err := getSharedProjectId(r, context)
+	if err != nil {
+		return BadRequestError(err)
+	}
+
+	snapshots, err := context.ProjectService.GetSharedSnapshots(projectId)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got snapshots of shared project %s", projectId)
+
+	return JsonResponse(snapshots)
+}
+
+func getTask_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	task, err := context.TaskService.GetTask(taskId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got task %s", taskId)
+
+	return FilteredJsonResponse(r, toTaskDto_v2_4(task))
+}
+
+func addUserToProject_v2_4(r *http.Request, context *Context) *ApiResponse {
+	userToAdd, err := util.GetParam("uid", r)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "url param 'uid' not set"))
+	}
+
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	updatedProject, err := context.ProjectService.AddUser(projectId, userToAdd, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	sendUpdate(context.WebsocketSender, updatedProject)
+
+	err = notifyMembershipChange(context, updatedProject, userToAdd, notification.TypeProjectUserAdded)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully added user '%s' to project %s", userToAdd, projectId)
+
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
+}
+
+func requestJoin_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	request, owner, err := context.ProjectService.RequestJoin(projectId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.WebsocketSender.Send(websocket.Message{
+		Type:      websocket.MessageType_JoinRequested,
+		ProjectId: projectId,
+		Data:      request,
+	}, owner)
+
+	context.Log("Successfully requested to join project %s", projectId)
+
+	return JsonResponse(request)
+}
+
+func getJoinRequests_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	requests, err := context.ProjectService.GetJoinRequests(projectId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got join requests of project %s", projectId)
+
+	return JsonResponse(requests)
+}
+
+func approveJoinRequest_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	userId, ok := vars["uid"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'uid' not set"))
+	}
+
+	updatedProject, err := context.ProjectService.ApproveJoinRequest(projectId, userId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	sendUpdate(context.WebsocketSender, updatedProject)
+
+	err = notifyMembershipChange(context, updatedProject, userId, notification.TypeProjectUserAdded)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully approved join request of user '%s' for project %s", userId, projectId)
+
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
+}
+
+func denyJoinRequest_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	userId, ok := vars["uid"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'uid' not set"))
+	}
+
+	err := context.ProjectService.DenyJoinRequest(projectId, userId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully denied join request of user '%s' for project %s", userId, projectId)
+
+	return EmptyResponse()
+}
+
+// requestMerge_v2_4 requests to merge the project "sourceId" into the project "id". When the requesting user owns both
+// projects, they are merged right away and the merged project is returned.
+func requestMerge_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	targetId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	sourceId, ok := vars["sourceId"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'sourceId' not set"))
+	}
+
+	request, source, target, err := context.ProjectService.RequestMerge(sourceId, targetId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	if request == nil {
+		err = sendMergeUpdates(context, source, target)
+		if err != nil {
+			return InternalServerError(err)
+		}
+
+		context.Log("Successfully merged project %s into %s", sourceId, targetId)
+
+		return JsonResponse(toProjectDto_v2_4(target))
+	}
+
+	context.WebsocketSender.Send(websocket.Message{
+		Type:      websocket.MessageType_MergeRequested,
+		ProjectId: targetId,
+		Data:      request,
+	}, request.Approver)
+
+	context.Log("Successfully requested to merge project %s into %s", sourceId, targetId)
+
+	return JsonResponse(request)
+}
+
+func getMergeRequests_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	requests, err := context.ProjectService.GetMergeRequests(projectId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got merge requests of project %s", projectId)
+
+	return JsonResponse(requests)
+}
+
+func approveMerge_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	targetId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	sourceId, ok := vars["sourceId"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'sourceId' not set"))
+	}
+
+	source, target, err := context.ProjectService.ApproveMerge(sourceId, targetId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	err = sendMergeUpdates(context, source, target)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully approved merge of project %s into %s", sourceId, targetId)
+
+	return JsonResponse(toProjectDto_v2_4(target))
+}
+
+func denyMerge_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	targetId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	sourceId, ok := vars["sourceId"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'sourceId' not set"))
+	}
+
+	err := context.ProjectService.DenyMerge(sourceId, targetId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully denied merge of project %s into %s", sourceId, targetId)
+
+	return EmptyResponse()
+}
+
+// sendMergeUpdates informs the members of both projects about the merge. The target might be completed now, since it
+// got all tasks of the source.
+func sendMergeUpdates(context *Context, source *project.Project, target *project.Project) error {
+	err := updateProjectCompletion(context.WebsocketSender, target, context)
+	if err != nil {
+		return err
+	}
+
+	sendUpdate(context.WebsocketSender, source)
+	sendUpdate(context.WebsocketSender, target)
+	return nil
+}
+
+func getProjectTargets_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	states, err := context.ProjectService.GetTargetStates(projectId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got targets of project %s", projectId)
+
+	return JsonResponse(states)
+}
+
+func updateProjectTargets_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	var targets []*project.Target
+	err := decodeJsonBody(r, &targets)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling targets"))
+	}
+
+	updatedProject, err := context.ProjectService.UpdateTargets(projectId, targets, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	sendUpdate(context.WebsocketSender, updatedProject)
+
+	context.Log("Successfully updated targets of project %s", projectId)
+
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
+}
+
+// getProjectTaskViewers_v2_4 returns the users currently viewing tasks of the project, so that clients opening the
+// project know them without waiting for the next "task_viewers" websocket message.
+func getProjectTaskViewers_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	// Only used to check the membership
+	_, err := context.ProjectService.GetProject(projectId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got task viewers of project %s", projectId)
+
+	return JsonResponse(websocket.GetTaskViewers(projectId))
+}
+
+func updateProjectLayers_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	var layers []*project.Layer
+	err := decodeJsonBody(r, &layers)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling layers"))
+	}
+
+	updatedProject, err := context.ProjectService.UpdateLayers(projectId, layers, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	sendUpdate(context.WebsocketSender, updatedProject)
+
+	context.Log("Successfully updated layers of project %s", projectId)
+
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
+}
+
+func updateProjectChecklist_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	var dto ProjectChecklistDto
+	err := decodeJsonBody(r, &dto)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling checklist"))
+	}
+
+	updatedProject, err := context.ProjectService.UpdateChecklist(projectId, dto.Items, dto.DerivePoints, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	sendUpdate(context.WebsocketSender, updatedProject)
+
+	context.Log("Successfully updated checklist of project %s", projectId)
+
+	return JsonResponse(toProjectDto_v2_4(updatedProject))
+}
+
+func getProjectSnapshots_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	snapshots, err := context.ProjectService.GetSnapshots(projectId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got snapshots of project %s", projectId)
+
+	return JsonResponse(snapshots)
+}
+
+func subscribeDigest_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	email, err := util.GetParam("email", r)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "url param 'email' not set"))
+	}
+
+	interval, err := util.GetParam("interval", r)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "url param 'interval' not set"))
+	}
+
+	subscription, err := context.DigestService.Subscribe(projectId, email, interval, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully subscribed user '%s' to %s digest of project %s", context.UserId(), interval, projectId)
+
+	return JsonResponse(subscription)
+}
+
+func unsubscribeDigest_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	err := context.DigestService.Unsubscribe(projectId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully unsubscribed user '%s' from digest of project %s", context.UserId(), projectId)
+
+	return EmptyResponse()
+}
+
+func watchProject_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	watch, err := context.WatchService.Watch(projectId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully added watch of user '%s' for project %s", context.UserId(), projectId)
+
+	return JsonResponse(watch)
+}
+
+func unwatchProject_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	err := context.WatchService.Unwatch(projectId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully removed watch of user '%s' for project %s", context.UserId(), projectId)
+
+	return EmptyResponse()
+}
+
+func getWatchedProjects_v2_4(r *http.Request, context *Context) *ApiResponse {
+	projects, err := context.WatchService.GetWatchedProjects(context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got %d watched projects of user '%s'", len(projects), context.UserId())
+
+	return JsonResponse(toProjectDtos_v2_4(projects))
+}
+
+func assignUser_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	user := context.UserId()
+
+	task, err := context.TaskService.AssignUser(taskId, user)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	// Send via websockets
+	err = sendTaskUpdate(context.WebsocketSender, task, user, context)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully assigned user '%s' to task '%s'", user, taskId)
+
+	return JsonResponse(toTaskDto_v2_4(task))
+}
+
+func unassignUser_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	user := context.UserId()
+
+	task, err := context.TaskService.UnassignUser(taskId, user)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	// Send via websockets
+	err = sendTaskUpdate(context.WebsocketSender, task, user, context)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully unassigned user '%s' from task '%s'", user, taskId)
+
+	return JsonResponse(toTaskDto_v2_4(task))
+}
+
+func setProcessPoints_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	processPoints, err := util.GetIntParam("process_points", r)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "url üarameter 'process_point' not set"))
+	}
+
+	task, err := context.TaskService.SetProcessPoints(taskId, processPoints, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	// Send via websockets
+	err = sendTaskUpdate(context.WebsocketSender, task, context.UserId(), context)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully set process points on task '%s' to %d", taskId, processPoints)
+
+	return JsonResponse(toTaskDto_v2_4(task))
+}
+
+func setTaskChecklist_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	var dto TaskChecklistDto
+	err := decodeJsonBody(r, &dto)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling completed checklist items"))
+	}
+
+	task, err := context.TaskService.SetChecklistDone(taskId, dto.CompletedItems, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	err = sendTaskUpdate(context.WebsocketSender, task, context.UserId(), context)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully set %d completed checklist items on task '%s'", len(task.ChecklistDone), taskId)
+
+	return JsonResponse(toTaskDto_v2_4(task))
+}
+
+func sync_v2_4(r *http.Request, context *Context) *ApiResponse {
+	var dto SyncDto
+	err := decodeJsonBody(r, &dto)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling operations"))
+	}
+
+	results, err := context.TaskService.ApplyOperations(dto.Operations, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	// Send one update per changed task, even when several operations changed it
+	updatedTasks := make(map[string]*task.Task)
+	for _, result := range results {
+		if result.Success {
+			updatedTasks[result.Task.Id] = result.Task
+		}
+	}
+	for _, t := range updatedTasks {
+		err = sendTaskUpdate(context.WebsocketSender, t, context.UserId(), context)
+		if err != nil {
+			return InternalServerError(err)
+		}
+	}
+
+	context.Log("Successfully applied %d operations", len(results))
+
+	return JsonResponse(toOperationResultDtos_v2_4(results))
+}
+
+func setDifficulty_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	difficulty, err := util.GetParam("difficulty", r)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "url param 'difficulty' not set"))
+	}
+
+	updatedTask, err := context.TaskService.SetDifficulty(taskId, difficulty, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	err = sendTaskUpdate(context.WebsocketSender, updatedTask, context.UserId(), context)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully set difficulty of task %s to %s", taskId, difficulty)
+
+	return JsonResponse(toTaskDto_v2_4(updatedTask))
+}
+
+func setAllowedUsers_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	var dto TaskAllowedUsersDto
+	err := decodeJsonBody(r, &dto)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling allowed users"))
+	}
+
+	if dto.Users == nil {
+		dto.Users = make([]string, 0)
+	}
+
+	updatedTask, err := context.TaskService.SetAllowedUsers(taskId, dto.Users, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	err = sendTaskUpdate(context.WebsocketSender, updatedTask, context.UserId(), context)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully set allowed users of task %s to %v", taskId, dto.Users)
+
+	return JsonResponse(toTaskDto_v2_4(updatedTask))
+}
+
+func setDependencies_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	var dto TaskDependenciesDto
+	err := decodeJsonBody(r, &dto)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling dependencies"))
+	}
+
+	if dto.DependsOn == nil {
+		dto.DependsOn = make([]string, 0)
+	}
+
+	updatedTask, err := context.TaskService.SetDependencies(taskId, dto.DependsOn, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	err = sendTaskUpdate(context.WebsocketSender, updatedTask, context.UserId(), context)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully set dependencies of task %s to %v", taskId, dto.DependsOn)
+
+	return JsonResponse(toTaskDto_v2_4(updatedTask))
+}
+
+func reopenTask_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	var dto TaskReopenDto
+	err := decodeJsonBody(r, &dto)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling reopen reason"))
+	}
+
+	reopenedTask, previousMapper, err := context.TaskService.Reopen(taskId, dto.Reason, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	err = sendTaskUpdate(context.WebsocketSender, reopenedTask, context.UserId(), context)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	// The mapper should know why the work has to be done again
+	if previousMapper != "" && previousMapper != context.UserId() {
+		project, err := context.ProjectService.GetProjectByTask(taskId, context.UserId())
+		if err != nil {
+			return InternalServerError(err)
+		}
+
+		n, err := context.NotificationService.AddTaskReopenedNotification(previousMapper, project.Id, project.Name, taskId, context.UserId(), strings.TrimSpace(dto.Reason))
+		if err != nil {
+			return InternalServerError(err)
+		}
+
+		context.WebsocketSender.Send(websocket.Message{
+			Type: websocket.MessageType_Notification,
+			Data: n,
+		}, previousMapper)
+	}
+
+	context.Log("Successfully reopened task %s", taskId)
+
+	return JsonResponse(toTaskDto_v2_4(reopenedTask))
+}
+
+func flagTask_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	var dto TaskFlagDto
+	err := decodeJsonBody(r, &dto)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling flag"))
+	}
+
+	flaggedTask, err := context.TaskService.Flag(taskId, dto.Reason, dto.Comment, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	err = sendTaskUpdate(context.WebsocketSender, flaggedTask, context.UserId(), context)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	// The owner has to take care of flagged tasks
+	project, err := context.ProjectService.GetProjectByTask(taskId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.WebsocketSender.Send(websocket.Message{
+		Type:      websocket.MessageType_TaskFlagged,
+		ProjectId: project.Id,
+		Data:      toTaskDto_v2_4(flaggedTask),
+	}, project.Owner)
+
+	context.Log("Successfully flagged task %s with reason %s", taskId, dto.Reason)
+
+	return JsonResponse(toTaskDto_v2_4(flaggedTask))
+}
+
+func reserveTask_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	editor, err := util.GetParam("editor", r)
+	if err != nil {
+		return BadRequestError(err)
+	}
+
+	reservedTask, editorUrl, err := context.TaskService.ReserveForEditor(taskId, editor, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	err = sendTaskUpdate(context.WebsocketSender, reservedTask, context.UserId(), context)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully reserved task %s for editor %s", taskId, editor)
+
+	return JsonResponse(EditorLinkDto{
+		Url:  editorUrl,
+		Task: toTaskDto_v2_4(reservedTask),
+	})
+}
+
+func releaseTaskReservation_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	releasedTask, err := context.TaskService.ReleaseReservation(taskId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	err = sendTaskUpdate(context.WebsocketSender, releasedTask, context.UserId(), context)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully released reservation of task %s", taskId)
+
+	return JsonResponse(toTaskDto_v2_4(releasedTask))
+}
+
+func resolveTaskFlag_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	resolvedTask, err := context.TaskService.ResolveFlag(taskId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	err = sendTaskUpdate(context.WebsocketSender, resolvedTask, context.UserId(), context)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully resolved flag of task %s", taskId)
+
+	return JsonResponse(toTaskDto_v2_4(resolvedTask))
+}
+
+func requestHandover_v2_4(r *http.Request, context *Context) *ApiResponse {
+	toUser, err := util.GetParam("uid", r)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "url param 'uid' not set"))
+	}
+
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	handover, err := context.TaskService.RequestHandover(taskId, toUser, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.WebsocketSender.Send(websocket.Message{
+		Type:      websocket.MessageType_HandoverRequested,
+		ProjectId: handover.ProjectId,
+		Data:      handover,
+	}, toUser)
+
+	context.Log("Successfully offered task %s to user %s", taskId, toUser)
+
+	return JsonResponse(handover)
+}
+
+func acceptHandover_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	task, handover, err := context.TaskService.AcceptHandover(taskId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	err = sendTaskUpdate(context.WebsocketSender, task, context.UserId(), context)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.WebsocketSender.Send(websocket.Message{
+		Type:      websocket.MessageType_HandoverAccepted,
+		ProjectId: handover.ProjectId,
+		Data:      handover,
+	}, handover.FromUser)
+
+	context.Log("Successfully accepted handover of task %s from user %s", taskId, handover.FromUser)
+
+	return JsonResponse(toTaskDto_v2_4(task))
+}
+
+func removeHandover_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	handover, err := context.TaskService.RemoveHandover(taskId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	otherUser := handover.ToUser
+	if otherUser == context.UserId() {
+		otherUser = handover.FromUser
+	}
+
+	context.WebsocketSender.Send(websocket.Message{
+		Type:      websocket.MessageType_HandoverRemoved,
+		ProjectId: handover.ProjectId,
+		Data:      handover,
+	}, otherUser)
+
+	context.Log("Successfully removed handover of task %s", taskId)
+
+	return EmptyResponse()
+}
+
+func getHandovers_v2_4(r *http.Request, context *Context) *ApiResponse {
+	handovers, err := context.TaskService.GetHandovers(context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got %d handovers offered to user %s", len(handovers), context.UserId())
+
+	return JsonResponse(handovers)
+}
+
+func getTaskNote_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	note, err := context.TaskService.GetNote(taskId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got note of task %s", taskId)
+
+	if note == nil {
+		return EmptyResponse()
+	}
+	return JsonResponse(note)
+}
+
+func setTaskNote_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	var dto TaskNoteDto
+	err := decodeJsonBody(r, &dto)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling note"))
+	}
+
+	note, err := context.TaskService.SetNote(taskId, dto.Text, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully set note of task %s", taskId)
+
+	if note == nil {
+		return EmptyResponse()
+	}
+	return JsonResponse(note)
+}
+
+func getTaskNotes_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	notes, err := context.TaskService.GetNotes(projectId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got %d notes of project %s", len(notes), projectId)
+
+	return JsonResponse(notes)
+}
+
+func linkChangeset_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	changesetId, ok := vars["changesetId"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'changesetId' not set"))
+	}
+
+	err := context.TaskService.LinkChangeset(taskId, changesetId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully linked changeset %s to task %s", changesetId, taskId)
+
+	return EmptyResponse()
+}
+
+func getOsmNotes_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	notes, err := context.TaskService.GetOsmNotes(taskId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got %d OSM notes of task %s", len(notes), taskId)
+
+	return JsonResponse(notes)
+}
+
+func createOsmNote_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	var dto TaskOsmNoteDto
+	err := decodeJsonBody(r, &dto)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling OSM note"))
+	}
+
+	note, task, err := context.TaskService.CreateOsmNote(taskId, dto.Text, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	err = sendTaskUpdate(context.WebsocketSender, task, context.UserId(), context)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully created OSM note %s for task %s", note.NoteId, taskId)
+
+	return JsonResponse(note)
+}
+
+func linkOsmNote_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	taskId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	noteId, ok := vars["noteId"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'noteId' not set"))
+	}
+
+	note, task, err := context.TaskService.LinkOsmNote(taskId, noteId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	err = sendTaskUpdate(context.WebsocketSender, task, context.UserId(), context)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully linked OSM note %s to task %s", noteId, taskId)
+
+	return JsonResponse(note)
+}
+
+func getChangesetStats_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	stats, err := context.TaskService.GetChangesetStats(projectId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got changeset statistics of project %s", projectId)
+
+	return JsonResponse(stats)
+}
+
+func getAreaStats_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	stats, err := context.TaskService.GetAreaStats(projectId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got area statistics of project %s", projectId)
+
+	return JsonResponse(stats)
+}
+
+func exportProjectMembers_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	projectId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	format := r.FormValue("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		return BadRequestError(errors.New(fmt.Sprintf("unknown export format '%s'", format)))
+	}
+
+	members, err := context.ProjectService.GetMembers(projectId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully exported %d members of project %s as %s", len(members), projectId, format)
+
+	if format == "json" {
+		return JsonResponse(members)
+	}
+
+	data, err := project.ExportMembersCsv(members)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	return FileResponse(data, "text/csv", fmt.Sprintf("project-%s-members.csv", projectId))
+}
+
+func getContributions_v2_4(r *http.Request, context *Context) *ApiResponse {
+	contributions, err := context.TaskService.GetContributions(context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got %d contributions of user '%s'", len(contributions), context.UserId())
+
+	return JsonResponse(contributions)
+}
+
+func getAssignedTasks_v2_4(r *http.Request, context *Context) *ApiResponse {
+	assignedTasks, err := context.TaskService.GetAssignedTasks(context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got %d tasks assigned to user '%s'", len(assignedTasks), context.UserId())
+
+	return FilteredJsonResponse(r, toAssignedTaskDtos_v2_4(assignedTasks))
+}
+
+func getDashboard_v2_4(r *http.Request, context *Context) *ApiResponse {
+	assignedTasks, err := context.TaskService.GetAssignedTasks(context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	ownedProjects, err := context.ProjectService.GetOwnedProjects(context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	targetStates := make(map[string][]*project.TargetState)
+	for _, p := range ownedProjects {
+		p.Localize(r.Header.Get("Accept-Language"))
+
+		if len(p.Targets) == 0 {
+			continue
+		}
+
+		targetStates[p.Id], err = context.ProjectService.EvaluateTargets(p.Id)
+		if err != nil {
+			return InternalServerError(err)
+		}
+	}
+
+	joinRequests, err := context.ProjectService.GetJoinRequestsOfOwner(context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got dashboard of user '%s'", context.UserId())
+
+	return JsonResponse(DashboardDto{
+		AssignedTasks: toAssignedTaskDtos_v2_4(assignedTasks),
+		OwnedProjects: toProjectDtos_v2_4(ownedProjects),
+		JoinRequests:  joinRequests,
+		TargetStates:  targetStates,
+	})
+}
+
+func getWebsocketConnection(w http.ResponseWriter, r *http.Request, token *auth.Token, websocketSender *websocket.WebsocketSender) {
+	uid := ""
+	if token != nil {
+		uid = token.UID
+	}
+
+	websocketSender.GetWebsocketConnection(w, r, uid, authenticateWebsocket(websocketSender.Logger), verifyWebsocketSubscription(websocketSender.Logger), verifyWebsocketTaskView(websocketSender.Logger))
+}
+
+func sendAdd(sender *websocket.WebsocketSender, addedProject *project.Project) {
+	sender.Send(websocket.Message{
+		Type:      websocket.MessageType_ProjectAdded,
+		ProjectId: addedProject.Id,
+		Data:      toProjectDto_v2_4(addedProject),
+	}, addedProject.Users...)
+}
+
+func sendUpdate(sender *websocket.WebsocketSender, updatedProject *project.Project) {
+	sender.Send(websocket.Message{
+		Type:      websocket.MessageType_ProjectUpdated,
+		ProjectId: updatedProject.Id,
+		Data:      toProjectDto_v2_4(updatedProject),
+	}, updatedProject.Users...)
+}
+
+func sendUserRemoved(sender *websocket.WebsocketSender, updatedProject *project.Project, removedUser string) {
+	sender.Send(websocket.Message{
+		Type:      websocket.MessageType_ProjectUpdated,
+		ProjectId: updatedProject.Id,
+		Data:      toProjectDto_v2_4(updatedProject),
+	}, updatedProject.Users...)
+	sender.Send(websocket.Message{
+		Type:      websocket.MessageType_ProjectUserRemoved,
+		ProjectId: updatedProject.Id,
+		Data:      updatedProject.Id,
+	}, removedUser)
+}
+
+// notifyMembershipChange adds a notification to the inbox of the user, whose membership has been changed by the
+// requesting user, and informs the user via websocket.
+func notifyMembershipChange(context *Context, changedProject *project.Project, userId string, notificationType string) error {
+	n, err := context.NotificationService.AddProjectMembershipNotification(userId, notificationType, changedProject.Id, changedProject.Name, context.UserId())
+	if err != nil {
+		return err
+	}
+
+	context.WebsocketSender.Send(websocket.Message{
+		Type: websocket.MessageType_Notification,
+		Data: n,
+	}, userId)
+
+	return nil
+}
+
+func sendDelete(sender *websocket.WebsocketSender, removedProject *project.Project) {
+	sender.Send(websocket.Message{
+		Type:      websocket.MessageType_ProjectDeleted,
+		ProjectId: removedProject.Id,
+		Data:      removedProject.Id,
+	}, removedProject.Users...)
+}
+
+func sendTaskUpdate(sender *websocket.WebsocketSender, task *task.Task, userId string, context *Context) error {
+	project, err := context.ProjectService.GetProjectByTask(task.Id, userId)
+	if err != nil {
+		return err
+	}
+
+	context.SetField(util.LogFieldProject, project.Id)
+
+	err = updateProjectCompletion(sender, project, context)
+	if err != nil {
+		return err
+	}
+
+	err = notifyWatchers(sender, project, context)
+	if err != nil {
+		return err
+	}
+
+	sender.Send(websocket.Message{
+		Type:      websocket.MessageType_ProjectUpdated,
+		ProjectId: project.Id,
+		Data:      toProjectDto_v2_4(project),
+	}, project.Users...)
+
+	return nil
+}
+
+// updateProjectCompletion is called after every task change. When the change completed the project, all members get
+// notified via websockets and all digest subscribers via mail.
+func updateProjectCompletion(sender *websocket.WebsocketSender, project *project.Project, context *Context) error {
+	justCompleted, err := context.ProjectService.UpdateCompletion(project)
+	if err != nil {
+		return err
+	}
+
+	if !justCompleted {
+		return nil
+	}
+
+	sender.Send(websocket.Message{
+		Type:      websocket.MessageType_ProjectCompleted,
+		ProjectId: project.Id,
+		Data:      toProjectDto_v2_4(project),
+	}, project.Users...)
+
+	// The mails are only added to the outbox, so an error here is a database error and the transaction has to fail
+	return context.DigestService.SendCompletionMails(project.Id)
+}
+
+// notifyWatchers is called after every task change. When the progress of the project reached a new milestone, the
+// watchers get a notification in their inbox and via websocket.
+func notifyWatchers(sender *websocket.WebsocketSender, project *project.Project, context *Context) error {
+	notifications, err := context.WatchService.NotifyProgress(project)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notifications {
+		sender.Send(websocket.Message{
+			Type: websocket.MessageType_Notification,
+			Data: n,
+		}, n.UserId)
+	}
+
+	return nil
+}
+
+func setMaintenance_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	var dto MaintenanceDto
+	err := decodeJsonBody(r, &dto)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling maintenance settings"))
+	}
+
+	setMaintenance(dto.Enabled, dto.Message)
+
+	context.Log("Successfully set maintenance mode to %v", dto.Enabled)
+
+	return JsonResponse(getMaintenance())
+}
+
+func getUsage_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	days := 7
+	if r.FormValue("days") != "" {
+		var err error
+		days, err = util.GetIntParam("days", r)
+		if err != nil {
+			return BadRequestError(errors.Wrap(err, "url param 'days' is not a number"))
+		}
+	}
+
+	usages, err := context.UsageService.GetUsage(days)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got API usage of %d users", len(usages))
+
+	return JsonResponse(usages)
+}
+
+func exportAuditLog_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	format := r.FormValue("format")
+	if format == "" {
+		format = audit.FormatCsv
+	}
+	if !audit.IsValidFormat(format) {
+		return BadRequestError(errors.New(fmt.Sprintf("unknown audit log format '%s'", format)))
+	}
+
+	filter := &audit.Filter{
+		ProjectId: r.FormValue("project"),
+		UserId:    r.FormValue("user"),
+	}
+	for _, param := range []string{"from", "to"} {
+		if r.FormValue(param) == "" {
+			continue
+		}
+
+		t, err := util.GetTimeParam(param, r)
+		if err != nil {
+			return BadRequestError(errors.Wrapf(err, "url param '%s' is not a valid timestamp", param))
+		}
+
+		if param == "from" {
+			filter.From = &t
+		} else {
+			filter.To = &t
+		}
+	}
+	if filter.ProjectId != "" {
+		_, err := util.GetIntParam("project", r)
+		if err != nil {
+			return BadRequestError(errors.Wrap(err, "url param 'project' is not a number"))
+		}
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return BadRequestError(errors.New("url param 'from' must be before 'to'"))
+	}
+
+	contentType := "text/csv"
+	if format == audit.FormatJsonl {
+		contentType = "application/x-ndjson"
+	}
+
+	return StreamResponse(contentType, "audit-log."+format, func(w io.Writer) error {
+		count, err := context.AuditService.Export(filter, format, w)
+		if err != nil {
+			return err
+		}
+
+		context.Log("Successfully exported %d audit log entries as %s", count, format)
+		return nil
+	})
+}
+
+func getRetention_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	stats, err := context.RetentionService.GetStats()
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got retention statistics of %d tables", len(stats))
+
+	return JsonResponse(stats)
+}
+
+func getLoginThrottle_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	stats := auth.GetLoginThrottleStats()
+
+	context.Log("Successfully got login throttle statistics")
+
+	return JsonResponse(stats)
+}
+
+func getConsistency_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	inconsistencies, err := context.ProjectService.CheckConsistency(false)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully checked consistency, found %d inconsistencies", len(inconsistencies))
+
+	return JsonResponse(inconsistencies)
+}
+
+func repairConsistency_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	inconsistencies, err := context.ProjectService.CheckConsistency(true)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully repaired %d inconsistencies", len(inconsistencies))
+
+	return JsonResponse(inconsistencies)
+}
+
+func getNotifications_v2_4(r *http.Request, context *Context) *ApiResponse {
+	unreadOnly := false
+	if r.FormValue("unread") != "" {
+		var err error
+		unreadOnly, err = util.GetBoolParam("unread", r)
+		if err != nil {
+			return BadRequestError(errors.Wrap(err, "url param 'unread' is not a boolean"))
+		}
+	}
+
+	notifications, err := context.NotificationService.GetNotifications(context.UserId(), unreadOnly)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got %d notifications", len(notifications))
+
+	return JsonResponse(notifications)
+}
+
+func markNotificationRead_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	notificationId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	n, err := context.NotificationService.MarkRead(notificationId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully marked notification %s as read", notificationId)
+
+	return JsonResponse(n)
+}
+
+func markAllNotificationsRead_v2_4(r *http.Request, context *Context) *ApiResponse {
+	err := context.NotificationService.MarkAllRead(context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully marked all notifications as read")
+
+	return EmptyResponse()
+}
+
+func subscribePush_v2_4(r *http.Request, context *Context) *ApiResponse {
+	var dto push.Subscription
+	err := decodeJsonBody(r, &dto)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling push subscription"))
+	}
+
+	subscription, err := context.PushService.Subscribe(&dto, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully subscribed to push messages")
+
+	return JsonResponse(subscription)
+}
+
+func unsubscribePush_v2_4(r *http.Request, context *Context) *ApiResponse {
+	endpoint, err := util.GetParam("endpoint", r)
+	if err != nil {
+		return BadRequestError(err)
+	}
+
+	err = context.PushService.Unsubscribe(endpoint, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully unsubscribed from push messages")
+
+	return EmptyResponse()
+}
+
+// getSessions_v2_4 returns the active sessions of the user. With "all=true", the latest logins including revoked and
+// expired sessions are returned.
+func getSessions_v2_4(r *http.Request, context *Context) *ApiResponse {
+	all := false
+	if r.FormValue("all") != "" {
+		var err error
+		all, err = util.GetBoolParam("all", r)
+		if err != nil {
+			return BadRequestError(errors.Wrap(err, "url param 'all' is not a boolean"))
+		}
+	}
+
+	var sessions []*session.Session
+	var err error
+	if all {
+		sessions, err = context.SessionService.GetLogins(context.UserId(), context.SessionId())
+	} else {
+		sessions, err = context.SessionService.GetSessions(context.UserId(), context.SessionId())
+	}
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got %d sessions", len(sessions))
+
+	return JsonResponse(sessions)
+}
+
+func revokeSession_v2_4(r *http.Request, context *Context) *ApiResponse {
+	vars := mux.Vars(r)
+	sessionId, ok := vars["id"]
+	if !ok {
+		return BadRequestError(errors.New("url segment 'id' not set"))
+	}
+
+	err := context.SessionService.Revoke(sessionId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully revoked session %s", sessionId)
+
+	return EmptyResponse()
+}
+
+// revokeOtherSessions_v2_4 revokes all sessions of the user except the one of the current token.
+func revokeOtherSessions_v2_4(r *http.Request, context *Context) *ApiResponse {
+	revoked, err := context.SessionService.RevokeOthers(context.UserId(), context.SessionId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully revoked %d other sessions", revoked)
+
+	return EmptyResponse()
+}
+
+func getFeatures_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	flags := feature.GetFlags()
+
+	context.Log("Successfully got %d feature flags", len(flags))
+
+	return JsonResponse(flags)
+}
+
+func setFeature_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	enabled, err := util.GetBoolParam("enabled", r)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "url param 'enabled' is not a boolean"))
+	}
+
+	flag, err := context.FeatureService.SetOverride(name, enabled, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully set feature flag '%s' to %v", name, enabled)
+
+	return JsonResponse(flag)
+}
+
+func resetFeature_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	flag, err := context.FeatureService.RemoveOverride(name)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully reset feature flag '%s'", name)
+
+	return JsonResponse(flag)
+}
+
+func getQuota_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	vars := mux.Vars(r)
+	userId := vars["uid"]
+
+	userQuota, err := context.QuotaService.GetQuota(userId)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got quota of user %s", userId)
+
+	return JsonResponse(userQuota)
+}
+
+func setQuota_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	vars := mux.Vars(r)
+	userId := vars["uid"]
+
+	var newQuota quota.Quota
+	err := decodeJsonBody(r, &newQuota)
+	if err != nil {
+		return BadRequestError(errors.Wrap(err, "error unmarshalling quota"))
+	}
+
+	userQuota, err := context.QuotaService.SetQuota(userId, &newQuota, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully set quota of user %s", userId)
+
+	return JsonResponse(userQuota)
+}
+
+func resetQuota_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	vars := mux.Vars(r)
+	userId := vars["uid"]
+
+	userQuota, err := context.QuotaService.RemoveQuota(userId)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully reset quota of user %s", userId)
+
+	return JsonResponse(userQuota)
+}
+
+func getAccounts_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	accounts, err := context.AccountService.GetAccounts()
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got %d accounts", len(accounts))
+
+	return JsonResponse(accounts)
+}
+
+func addAccount_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	vars := mux.Vars(r)
+	accountId := vars["uid"]
+
+	account, err := context.AccountService.AddAccount(accountId, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully added account %s", accountId)
+
+	return JsonResponse(account)
+}
+
+func removeAccount_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	vars := mux.Vars(r)
+	accountId := vars["uid"]
+
+	err := context.AccountService.RemoveAccount(accountId)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully removed account %s", accountId)
+
+	return EmptyResponse()
+}
+
+func renewAccountKey_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	vars := mux.Vars(r)
+	accountId := vars["uid"]
+
+	account, err := context.AccountService.RenewKey(accountId)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully renewed key of account %s", accountId)
+
+	return JsonResponse(account)
+}
+
+func getInvitations_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	invitations, err := context.InviteService.GetInvitations()
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully got %d invitations", len(invitations))
+
+	return JsonResponse(invitations)
+}
+
+func invite_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	vars := mux.Vars(r)
+	userName := vars["name"]
+
+	invitation, err := context.InviteService.Invite(userName, context.UserId())
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully invited user '%s'", userName)
+
+	return JsonResponse(invitation)
+}
+
+func removeInvitation_v2_4(r *http.Request, context *Context) *ApiResponse {
+	if !context.IsAdmin() {
+		return InternalServerError(errors.New(fmt.Sprintf("user %s is not an admin", context.UserId())))
+	}
+
+	vars := mux.Vars(r)
+	userName := vars["name"]
+
+	err := context.InviteService.RemoveInvitation(userName)
+	if err != nil {
+		return InternalServerError(err)
+	}
+
+	context.Log("Successfully removed invitation of user '%s'", userName)
+
+	return EmptyResponse()
+}
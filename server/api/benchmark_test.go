@@ -0,0 +1,92 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/auth"
+	"github.com/hauke96/simple-task-manager/server/loadtest"
+	"github.com/hauke96/simple-task-manager/server/util"
+)
+
+// The benchmarks measure the most used endpoints with the fixtures of the load test against the test database, e.g.
+// run "go test ./api -run none -bench . -benchtime 500x" to compare the performance before and after a change.
+const (
+	benchmarkUser            = "Bench"
+	benchmarkProjects        = 10
+	benchmarkTasksPerProject = 100
+)
+
+func BenchmarkGetProjects_v2_4(b *testing.B) {
+	c, _ := benchmarkSetup(b)
+
+	for i := 0; i < b.N; i++ {
+		err := c.GetProjects()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetProjectTasks_v2_4(b *testing.B) {
+	c, fixtures := benchmarkSetup(b)
+
+	for i := 0; i < b.N; i++ {
+		err := c.GetTasks(fixtures.ProjectIds[i%len(fixtures.ProjectIds)])
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSetProcessPoints_v2_4(b *testing.B) {
+	c, fixtures := benchmarkSetup(b)
+
+	for i := 0; i < b.N; i++ {
+		// Stay below the maximum of 100 points, so that no task gets completed
+		err := c.SetProcessPoints(fixtures.TaskIds[i%len(fixtures.TaskIds)], i%99+1)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetProjectsParallel_v2_4(b *testing.B) {
+	c, _ := benchmarkSetup(b)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			err := c.GetProjects()
+			if err != nil {
+				b.Error(err) // "Fatal" must not be called outside the benchmark goroutine
+				return
+			}
+		}
+	})
+}
+
+// benchmarkSetup resets the database and adds the projects of the benchmark user. The log level is raised during the
+// benchmark, since logging every request would distort the results.
+func benchmarkSetup(b *testing.B) (*loadtest.Client, *loadtest.Fixtures) {
+	setup()
+
+	logLevel := sigolo.LogLevel
+	sigolo.LogLevel = sigolo.LOG_ERROR
+	b.Cleanup(func() {
+		sigolo.LogLevel = logLevel
+	})
+
+	token, err := auth.CreateToken(util.NewLogger(), benchmarkUser, benchmarkUser, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	c := loadtest.NewClient(server.URL, token, server.Client())
+
+	fixtures, err := loadtest.CreateFixtures(c, benchmarkUser, benchmarkProjects, benchmarkTasksPerProject)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	return c, fixtures
+}
@@ -1,43 +1,106 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"github.com/hauke96/simple-task-manager/server/account"
+	"github.com/hauke96/simple-task-manager/server/audit"
 	"github.com/hauke96/simple-task-manager/server/auth"
 	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/digest"
+	"github.com/hauke96/simple-task-manager/server/export"
+	"github.com/hauke96/simple-task-manager/server/feature"
+	"github.com/hauke96/simple-task-manager/server/invite"
+	"github.com/hauke96/simple-task-manager/server/notification"
+	"github.com/hauke96/simple-task-manager/server/outbox"
 	"github.com/hauke96/simple-task-manager/server/permission"
 	"github.com/hauke96/simple-task-manager/server/project"
+	"github.com/hauke96/simple-task-manager/server/push"
+	"github.com/hauke96/simple-task-manager/server/quota"
+	"github.com/hauke96/simple-task-manager/server/retention"
+	"github.com/hauke96/simple-task-manager/server/session"
 	"github.com/hauke96/simple-task-manager/server/task"
+	"github.com/hauke96/simple-task-manager/server/usage"
 	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/hauke96/simple-task-manager/server/watch"
 	"github.com/hauke96/simple-task-manager/server/websocket"
 	"github.com/pkg/errors"
 )
 
 type Context struct {
 	*util.Logger
-	Token           *auth.Token
-	Transaction     *sql.Tx
-	ProjectService  *project.ProjectService
-	TaskService     *task.TaskService
-	WebsocketSender *websocket.WebsocketSender
+	Token               *auth.Token
+	Transaction         *sql.Tx
+	AccountService      *account.AccountService
+	ProjectService      *project.ProjectService
+	TaskService         *task.TaskService
+	DigestService       *digest.DigestService
+	ExportService       *export.ExportService
+	FeatureService      *feature.FeatureService
+	InviteService       *invite.InviteService
+	NotificationService *notification.NotificationService
+	PushService         *push.PushService
+	QuotaService        *quota.QuotaService
+	RetentionService    *retention.RetentionService
+	SessionService      *session.SessionService
+	UsageService        *usage.UsageService
+	AuditService        *audit.AuditService
+	WatchService        *watch.WatchService
+	WebsocketSender     *websocket.WebsocketSender
 }
 
 // createContext starts a new Transaction and creates new service instances which use this new Transaction so that all
-// services (also those calling each other) are using the same Transaction.
-func createContext(token *auth.Token, logger *util.Logger) (*Context, error) {
+// services (also those calling each other) are using the same Transaction. All database queries are cancelled when the
+// given request context is cancelled.
+func createContext(requestContext context.Context, token *auth.Token, logger *util.Logger) (*Context, error) {
 	ctx := &Context{}
 	ctx.Token = token
 	ctx.Logger = logger
 
-	tx, err := database.GetTransaction(logger)
+	tx, err := database.GetTransaction(requestContext, logger)
 	if err != nil {
 		return nil, errors.Wrap(err, "error getting Transaction")
 	}
 	ctx.Transaction = tx
 
-	permissionService := permission.Init(tx, ctx.Logger)
-	ctx.TaskService = task.Init(tx, ctx.Logger, permissionService)
-	ctx.ProjectService = project.Init(tx, ctx.Logger, ctx.TaskService, permissionService)
+	permissionService := permission.Init(requestContext, tx, ctx.Logger)
+	ctx.TaskService = task.Init(requestContext, tx, ctx.Logger, permissionService)
+	ctx.QuotaService = quota.Init(requestContext, tx, ctx.Logger)
+	ctx.ProjectService = project.Init(requestContext, tx, ctx.Logger, ctx.TaskService, permissionService, ctx.QuotaService)
+	ctx.DigestService = digest.Init(requestContext, tx, ctx.Logger, permissionService, ctx.ProjectService, outbox.Init(requestContext, tx, ctx.Logger))
+	ctx.ExportService = export.Init(requestContext, tx, ctx.Logger, ctx.TaskService, permissionService)
+	ctx.UsageService = usage.Init(requestContext, tx, ctx.Logger)
+	ctx.AuditService = audit.Init(requestContext, tx, ctx.Logger)
+	ctx.RetentionService = retention.Init(requestContext, tx, ctx.Logger)
+	ctx.SessionService = session.Init(requestContext, tx, ctx.Logger)
+	ctx.FeatureService = feature.Init(requestContext, tx, ctx.Logger)
+	ctx.NotificationService = notification.Init(requestContext, tx, ctx.Logger)
+	ctx.PushService = push.Init(requestContext, tx, ctx.Logger)
+	ctx.AccountService = account.Init(requestContext, tx, ctx.Logger, ctx.SessionService)
+	ctx.InviteService = invite.Init(requestContext, tx, ctx.Logger)
+	ctx.WatchService = watch.Init(requestContext, tx, ctx.Logger, ctx.ProjectService, ctx.NotificationService)
 	ctx.WebsocketSender = websocket.Init(ctx.Logger)
 
 	return ctx, nil
 }
+
+// UserId returns the ID of the authenticated user or an empty string for public routes.
+func (c *Context) UserId() string {
+	if c.Token == nil {
+		return ""
+	}
+	return c.Token.UID
+}
+
+// SessionId returns the ID of the session of the authenticated user or an empty string for public routes.
+func (c *Context) SessionId() string {
+	if c.Token == nil {
+		return ""
+	}
+	return c.Token.Session
+}
+
+// IsAdmin returns true when the authenticated user is one of the configured admins.
+func (c *Context) IsAdmin() bool {
+	return c.Token != nil && isAdmin(c.Token.UID)
+}
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/project"
+	"github.com/hauke96/simple-task-manager/server/task"
+)
+
+// The DTOs of this file define the JSON payloads of API v2.4. Services only work with the models of their packages, so
+// that the models can change (e.g. get internal fields) without changing the API. The mappers convert between both.
+
+type ProjectDto_v2_4 struct {
+	Id                 string            `json:"id"`
+	Name               string            `json:"name" validate:"required"`
+	TaskIDs            []string          `json:"taskIds"`
+	Users              []string          `json:"users" validate:"required"`
+	Owner              string            `json:"owner" validate:"required"`
+	Description        string            `json:"description"`
+	NeedsAssignment    bool              `json:"needsAssignment"`
+	TotalProcessPoints int               `json:"totalProcessPoints"`
+	DoneProcessPoints  int               `json:"doneProcessPoints"`
+	Status             string            `json:"status"`
+	DefaultDifficulty  string            `json:"defaultDifficulty"`
+	MinChangesets      int               `json:"minChangesets"`
+	PointStep          int               `json:"pointStep"`
+	MaxAssignedTasks   int               `json:"maxAssignedTasks"`
+	MaxCompletions     int               `json:"maxCompletions"`
+	UnassignAfterHours int               `json:"unassignAfterHours"`
+	CompletedAt        *time.Time        `json:"completedAt"`
+	Archived           bool              `json:"archived"`
+	Locale             string            `json:"locale"`
+	Descriptions       map[string]string `json:"descriptions"`
+	Targets            []*project.Target `json:"targets"` // Set via "PUT /projects/{id}/targets"
+	Layers             []*project.Layer  `json:"layers"`  // Set via "PUT /projects/{id}/layers"
+	Checklist          task.Checklist    `json:"checklist"`
+	ChecklistPoints    bool              `json:"checklistPoints"`
+	GeometryTypes      []string          `json:"geometryTypes"`
+	ChangesetComment   string            `json:"changesetComment"`  // Template, see "changesetComment" of the tasks
+	ChangesetHashtags  []string          `json:"changesetHashtags"` // Templates, see "changesetHashtags" of the tasks
+	Public             bool              `json:"public"`
+	Aoi                string            `json:"aoi"`        // GeoJSON geometry, computed from the tasks unless supplied
+	MergedInto         string            `json:"mergedInto"` // ID of the project this archived project has been merged into
+	Tutorial           bool              `json:"tutorial"`   // Set by the server, see "POST /projects/tutorial"
+	CreatedAt          time.Time         `json:"createdAt"`
+	UpdatedAt          time.Time         `json:"updatedAt"`
+}
+
+type TaskDto_v2_4 struct {
+	Id                string                `json:"id"`
+	ProcessPoints     int                   `json:"processPoints" validate:"min=0"`
+	MaxProcessPoints  int                   `json:"maxProcessPoints" validate:"min=1"`
+	Geometry          string                `json:"geometry" validate:"required"`
+	GeometryFormat    string                `json:"geometryFormat,omitempty"` // Format of the geometry when adding tasks, see "task.GeometryFormat..." values, GeoJSON by default
+	Crs               string                `json:"crs,omitempty"`            // Coordinate reference system of the geometry when adding tasks (like "EPSG:3857"), WGS84 by default
+	AssignedUser      string                `json:"assignedUser"`
+	BoundingBox       []float64             `json:"bbox"`
+	Centroid          []float64             `json:"centroid"`
+	Area              float64               `json:"area"`      // km², computed by the server
+	Perimeter         float64               `json:"perimeter"` // km, computed by the server
+	Version           int                   `json:"version"`
+	Difficulty        string                `json:"difficulty"`
+	AllowedUsers      []string              `json:"allowedUsers"`
+	DependsOn         []string              `json:"dependsOn"`
+	Blocked           bool                  `json:"blocked"`
+	Flag              *task.TaskFlag        `json:"flag"`
+	Reservation       *task.TaskReservation `json:"reservation"`
+	ChecklistDone     []string              `json:"checklistDone"`
+	OpenOsmNotes      int                   `json:"openOsmNotes"`
+	Locality          string                `json:"locality"`          // Name of the area around the task, empty until looked up
+	ChangesetComment  string                `json:"changesetComment"`  // Comment for changesets of this task, placeholders already replaced
+	ChangesetHashtags []string              `json:"changesetHashtags"` // Hashtags for changesets of this task, placeholders already replaced
+	CreatedAt         time.Time             `json:"createdAt"`
+	UpdatedAt         time.Time             `json:"updatedAt"`
+}
+
+// AssignedTaskDto_v2_4 is a task together with its project.
+type AssignedTaskDto_v2_4 struct {
+	*TaskDto_v2_4
+	ProjectId   string     `json:"projectId"`
+	ProjectName string     `json:"projectName"`
+	AssignedAt  *time.Time `json:"assignedAt"`
+}
+
+// NearbyProjectDto_v2_4 is a public project together with its distance to the location of the user.
+type NearbyProjectDto_v2_4 struct {
+	*ProjectDto_v2_4
+	Distance float64 `json:"distance"` // Meters to the nearest task
+}
+
+type OperationResultDto_v2_4 struct {
+	Id       string        `json:"id"`
+	Success  bool          `json:"success"`
+	Conflict bool          `json:"conflict"`
+	Error    string        `json:"error,omitempty"`
+	Task     *TaskDto_v2_4 `json:"task,omitempty"`
+}
+
+func toProjectDto_v2_4(p *project.Project) *ProjectDto_v2_4 {
+	return &ProjectDto_v2_4{
+		Id:                 p.Id,
+		Name:               p.Name,
+		TaskIDs:            p.TaskIDs,
+		Users:              p.Users,
+		Owner:              p.Owner,
+		Description:        p.Description,
+		NeedsAssignment:    p.NeedsAssignment,
+		TotalProcessPoints: p.TotalProcessPoints,
+		DoneProcessPoints:  p.DoneProcessPoints,
+		Status:             p.Status,
+		DefaultDifficulty:  p.DefaultDifficulty,
+		MinChangesets:      p.MinChangesets,
+		PointStep:          p.PointStep,
+		MaxAssignedTasks:   p.MaxAssignedTasks,
+		MaxCompletions:     p.MaxCompletions,
+		UnassignAfterHours: p.UnassignAfterHours,
+		CompletedAt:        p.CompletedAt,
+		Archived:           p.Archived,
+		Locale:             p.Locale,
+		Descriptions:       p.Descriptions,
+		Targets:            p.Targets,
+		Layers:             p.Layers,
+		Checklist:          p.Checklist,
+		ChecklistPoints:    p.ChecklistPoints,
+		GeometryTypes:      p.GeometryTypes,
+		ChangesetComment:   p.ChangesetComment,
+		ChangesetHashtags:  p.ChangesetHashtags,
+		Public:             p.Public,
+		Aoi:                p.Aoi,
+		MergedInto:         p.MergedInto,
+		Tutorial:           p.Tutorial,
+		CreatedAt:          p.CreatedAt,
+		UpdatedAt:          p.UpdatedAt,
+	}
+}
+
+func toProjectDtos_v2_4(projects []*project.Project) []*ProjectDto_v2_4 {
+	result := make([]*ProjectDto_v2_4, len(projects))
+	for i, p := range projects {
+		result[i] = toProjectDto_v2_4(p)
+	}
+	return result
+}
+
+func toNearbyProjectDtos_v2_4(projects []*project.NearbyProject) []*NearbyProjectDto_v2_4 {
+	result := make([]*NearbyProjectDto_v2_4, len(projects))
+	for i, p := range projects {
+		result[i] = &NearbyProjectDto_v2_4{
+			ProjectDto_v2_4: toProjectDto_v2_4(p.Project),
+			Distance:        p.Distance,
+		}
+	}
+	return result
+}
+
+// toProjectModel_v2_4 converts a project sent by a client. Fields computed by the server (like the process points) are
+// not taken over.
+func toProjectModel_v2_4(dto *ProjectDto_v2_4) *project.Project {
+	return &project.Project{
+		Id:                 dto.Id,
+		Name:               dto.Name,
+		TaskIDs:            dto.TaskIDs,
+		Users:              dto.Users,
+		Owner:              dto.Owner,
+		Description:        dto.Description,
+		NeedsAssignment:    dto.NeedsAssignment,
+		DefaultDifficulty:  dto.DefaultDifficulty,
+		MinChangesets:      dto.MinChangesets,
+		PointStep:          dto.PointStep,
+		MaxAssignedTasks:   dto.MaxAssignedTasks,
+		MaxCompletions:     dto.MaxCompletions,
+		UnassignAfterHours: dto.UnassignAfterHours,
+		Locale:             dto.Locale,
+		Descriptions:       dto.Descriptions,
+		GeometryTypes:      dto.GeometryTypes,
+		ChangesetComment:   dto.ChangesetComment,
+		ChangesetHashtags:  dto.ChangesetHashtags,
+		Public:             dto.Public,
+		Aoi:                dto.Aoi,
+	}
+}
+
+func toTaskDto_v2_4(t *task.Task) *TaskDto_v2_4 {
+	return &TaskDto_v2_4{
+		Id:                t.Id,
+		ProcessPoints:     t.ProcessPoints,
+		MaxProcessPoints:  t.MaxProcessPoints,
+		Geometry:          t.Geometry,
+		AssignedUser:      t.AssignedUser,
+		BoundingBox:       t.BoundingBox,
+		Centroid:          t.Centroid,
+		Area:              t.Area,
+		Perimeter:         t.Perimeter,
+		Version:           t.Version,
+		Difficulty:        t.Difficulty,
+		AllowedUsers:      t.AllowedUsers,
+		DependsOn:         t.DependsOn,
+		Blocked:           t.Blocked,
+		ChecklistDone:     t.ChecklistDone,
+		OpenOsmNotes:      t.OpenOsmNotes,
+		Locality:          t.Locality,
+		Flag:              t.Flag,
+		Reservation:       t.Reservation,
+		ChangesetComment:  t.ChangesetComment,
+		ChangesetHashtags: t.ChangesetHashtags,
+		CreatedAt:         t.CreatedAt,
+		UpdatedAt:         t.UpdatedAt,
+	}
+}
+
+func toTaskDtos_v2_4(tasks []*task.Task) []*TaskDto_v2_4 {
+	result := make([]*TaskDto_v2_4, len(tasks))
+	for i, t := range tasks {
+		result[i] = toTaskDto_v2_4(t)
+	}
+	return result
+}
+
+// toTaskModels_v2_4 converts tasks sent by a client. Fields computed by the server (like the bounding box) are not
+// taken over.
+func toTaskModels_v2_4(dtos []*TaskDto_v2_4) []*task.Task {
+	result := make([]*task.Task, len(dtos))
+	for i, dto := range dtos {
+		result[i] = &task.Task{
+			Id:               dto.Id,
+			ProcessPoints:    dto.ProcessPoints,
+			MaxProcessPoints: dto.MaxProcessPoints,
+			Geometry:         dto.Geometry,
+			AssignedUser:     dto.AssignedUser,
+			Difficulty:       dto.Difficulty,
+			AllowedUsers:     dto.AllowedUsers,
+		}
+	}
+	return result
+}
+
+func toAssignedTaskDtos_v2_4(tasks []*task.AssignedTask) []*AssignedTaskDto_v2_4 {
+	result := make([]*AssignedTaskDto_v2_4, len(tasks))
+	for i, t := range tasks {
+		result[i] = &AssignedTaskDto_v2_4{
+			TaskDto_v2_4: toTaskDto_v2_4(t.Task),
+			ProjectId:    t.ProjectId,
+			ProjectName:  t.ProjectName,
+			AssignedAt:   t.AssignedAt,
+		}
+	}
+	return result
+}
+
+func toOperationResultDtos_v2_4(results []*task.OperationResult) []*OperationResultDto_v2_4 {
+	result := make([]*OperationResultDto_v2_4, len(results))
+	for i, r := range results {
+		result[i] = &OperationResultDto_v2_4{
+			Id:       r.Id,
+			Success:  r.Success,
+			Conflict: r.Conflict,
+			Error:    r.Error,
+		}
+		if r.Task != nil {
+			result[i].Task = toTaskDto_v2_4(r.Task)
+		}
+	}
+	return result
+}
@@ -0,0 +1,406 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/auth"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/project"
+	"github.com/hauke96/simple-task-manager/server/rpc"
+	"github.com/hauke96/simple-task-manager/server/task"
+	"github.com/hauke96/simple-task-manager/server/usage"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/hauke96/simple-task-manager/server/websocket"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServer implements the gRPC interface (see "rpc/stm.proto"). Every call works like a REST request: The token gets
+// verified, a new context with its own transaction is created and the same services as the REST API are called.
+type grpcServer struct {
+	rpc.UnimplementedSimpleTaskManagerServer
+}
+
+// serveGrpc starts the gRPC interface on its own port. It uses the same TLS configuration as the HTTPS server (see
+// "newTlsConfig"), which is nil when the REST API doesn't use HTTPS either.
+func serveGrpc(tlsConfig *tls.Config) error {
+	options := make([]grpc.ServerOption, 0)
+	if tlsConfig != nil {
+		sigolo.Info("Use TLS for gRPC? yes")
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig.Clone())))
+	} else if strings.HasPrefix(config.Conf.ServerUrl, "https") {
+		// Tokens would be sent in plain text otherwise
+		return errors.New("refusing to serve gRPC without TLS while the server uses HTTPS")
+	} else {
+		sigolo.Info("Use TLS for gRPC? no")
+	}
+
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(config.Conf.GrpcPort))
+	if err != nil {
+		return errors.Wrap(err, "could not listen on gRPC port")
+	}
+
+	server := grpc.NewServer(options...)
+	rpc.RegisterSimpleTaskManagerServer(server, &grpcServer{})
+
+	sigolo.Info("Serve gRPC on port %d", config.Conf.GrpcPort)
+	return server.Serve(listener)
+}
+
+// authenticateGrpc checks the address of the caller, verifies the token from the "authorization" metadata and rejects
+// non-admins during maintenance.
+func authenticateGrpc(ctx context.Context, logger *util.Logger) (*auth.Token, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok || !isAddressAllowed(p.Addr.String()) {
+		return nil, status.Error(codes.PermissionDenied, "Access denied")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "No valid authentication token found")
+	}
+
+	token, err := auth.VerifyToken(values[0], logger)
+	if err != nil {
+		logger.Err("Token verification failed: %s", err)
+		// No further information to caller (which is a potential attacker)
+		return nil, status.Error(codes.Unauthenticated, "No valid authentication token found")
+	}
+
+	logger.SetField(util.LogFieldUser, token.UID)
+
+	usage.Record(token.UID)
+
+	m := getMaintenance()
+	if m.Enabled && !isAdmin(token.UID) {
+		logger.Log("Reject gRPC call from '%s' (%s) due to maintenance", token.User, token.UID)
+		return nil, status.Error(codes.Unavailable, m.Message)
+	}
+
+	return token, nil
+}
+
+// handleGrpc works like "handleInTransaction" of the REST API: It authenticates the caller, creates the context,
+// calls the handler and commits the transaction when the handler succeeded. Errors and panics of the handler cause a
+// rollback and are returned to the caller.
+func handleGrpc(ctx context.Context, method string, handler func(context *Context) error) (err error) {
+	logger := util.NewLogger()
+
+	token, err := authenticateGrpc(ctx, logger)
+	if err != nil {
+		return err
+	}
+
+	context, err := createContext(ctx, token, logger)
+	if err != nil {
+		logger.Err("Unable to create context for gRPC call from '%s' (%s) to %s: %s", token.User, token.UID, method, err)
+		logger.Stack(err)
+		// No further information to caller (which is a potential attacker)
+		return status.Error(codes.Internal, "Unable to create context")
+	}
+
+	context.Log("gRPC call from '%s' (%s) to %s", token.User, token.UID, method)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+
+		if err == nil {
+			return
+		}
+
+		context.Stack(err)
+
+		context.Log("Try to perform rollback")
+		rollbackErr := context.Transaction.Rollback()
+		if rollbackErr != nil {
+			logger.Stack(errors.Wrap(rollbackErr, "error performing rollback"))
+		}
+
+		if _, isStatus := status.FromError(err); !isStatus {
+			err = status.Error(codes.Internal, err.Error())
+		}
+	}()
+
+	err = handler(context)
+	if err != nil {
+		return err
+	}
+
+	err = context.Transaction.Commit()
+	if err != nil {
+		context.Err("Unable to commit transaction: %s", err.Error())
+		return err
+	}
+	context.Debug("Committed transaction")
+
+	return nil
+}
+
+// acceptLanguage returns the "accept-language" metadata, which is used like the "Accept-Language" header of the REST
+// API to localize projects.
+func acceptLanguage(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("accept-language")
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (s *grpcServer) GetProjects(ctx context.Context, request *rpc.GetProjectsRequest) (*rpc.ProjectList, error) {
+	result := &rpc.ProjectList{}
+
+	err := handleGrpc(ctx, "GetProjects", func(context *Context) error {
+		projects, err := context.ProjectService.GetProjects(context.UserId())
+		if err != nil {
+			return err
+		}
+
+		for _, p := range projects {
+			p.Localize(acceptLanguage(ctx))
+		}
+		result.Projects = toProjectMessages(projects)
+
+		context.Log("Successfully got projects")
+		return nil
+	})
+
+	return result, err
+}
+
+func (s *grpcServer) GetProject(ctx context.Context, request *rpc.GetProjectRequest) (*rpc.Project, error) {
+	if request.ProjectId == "" {
+		return nil, status.Error(codes.InvalidArgument, "field 'project_id' is required")
+	}
+
+	var result *rpc.Project
+
+	err := handleGrpc(ctx, "GetProject", func(context *Context) error {
+		project, err := context.ProjectService.GetProject(request.ProjectId, context.UserId())
+		if err != nil {
+			return err
+		}
+
+		project.Localize(acceptLanguage(ctx))
+		result = toProjectMessage(project)
+
+		context.Log("Successfully got project %s", request.ProjectId)
+		return nil
+	})
+
+	return result, err
+}
+
+func (s *grpcServer) GetTasks(ctx context.Context, request *rpc.GetTasksRequest) (*rpc.TaskList, error) {
+	if request.ProjectId == "" {
+		return nil, status.Error(codes.InvalidArgument, "field 'project_id' is required")
+	}
+
+	result := &rpc.TaskList{}
+
+	err := handleGrpc(ctx, "GetTasks", func(context *Context) error {
+		tasks, err := context.TaskService.GetTasks(request.ProjectId, context.UserId())
+		if err != nil {
+			return err
+		}
+
+		result.Tasks = toTaskMessages(tasks)
+
+		context.Log("Successfully got tasks of project %s", request.ProjectId)
+		return nil
+	})
+
+	return result, err
+}
+
+func (s *grpcServer) AssignTask(ctx context.Context, request *rpc.TaskRequest) (*rpc.Task, error) {
+	return s.updateTask(ctx, "AssignTask", request.TaskId, func(context *Context) (*task.Task, error) {
+		return context.TaskService.AssignUser(request.TaskId, context.UserId())
+	})
+}
+
+func (s *grpcServer) UnassignTask(ctx context.Context, request *rpc.TaskRequest) (*rpc.Task, error) {
+	return s.updateTask(ctx, "UnassignTask", request.TaskId, func(context *Context) (*task.Task, error) {
+		return context.TaskService.UnassignUser(request.TaskId, context.UserId())
+	})
+}
+
+func (s *grpcServer) SetProcessPoints(ctx context.Context, request *rpc.SetProcessPointsRequest) (*rpc.Task, error) {
+	return s.updateTask(ctx, "SetProcessPoints", request.TaskId, func(context *Context) (*task.Task, error) {
+		return context.TaskService.SetProcessPoints(request.TaskId, int(request.ProcessPoints), context.UserId())
+	})
+}
+
+// updateTask performs the given change and informs all members of the project via websocket (and therefore also via
+// "StreamUpdates"), just like the REST API does.
+func (s *grpcServer) updateTask(ctx context.Context, method string, taskId string, change func(context *Context) (*task.Task, error)) (*rpc.Task, error) {
+	if taskId == "" {
+		return nil, status.Error(codes.InvalidArgument, "field 'task_id' is required")
+	}
+
+	var result *rpc.Task
+
+	err := handleGrpc(ctx, method, func(context *Context) error {
+		context.SetField(util.LogFieldTask, taskId)
+
+		updatedTask, err := change(context)
+		if err != nil {
+			return err
+		}
+
+		err = sendTaskUpdate(context.WebsocketSender, updatedTask, context.UserId(), context)
+		if err != nil {
+			return err
+		}
+
+		result = toTaskMessage(updatedTask)
+
+		context.Log("Successfully performed %s on task '%s'", method, taskId)
+		return nil
+	})
+
+	return result, err
+}
+
+// StreamUpdates sends the websocket events of the user until the client cancels the call. Like websocket connections,
+// the stream doesn't use a transaction, only the subscribed projects are checked once at the beginning.
+func (s *grpcServer) StreamUpdates(request *rpc.StreamUpdatesRequest, stream rpc.SimpleTaskManager_StreamUpdatesServer) error {
+	logger := util.NewLogger()
+
+	token, err := authenticateGrpc(stream.Context(), logger)
+	if err != nil {
+		return err
+	}
+
+	projectIds := make(map[string]bool)
+	for _, projectId := range request.ProjectIds {
+		err = verifyWebsocketSubscription(logger)(token.UID, projectId)
+		if err != nil {
+			logger.Err("User %s is not allowed to stream updates of project %s: %s", token.UID, projectId, err.Error())
+			return status.Error(codes.PermissionDenied, "subscription not allowed")
+		}
+		projectIds[projectId] = true
+	}
+
+	messages, stop := websocket.Init(logger).Listen(token.UID)
+	defer stop()
+
+	logger.Log("Stream updates of %d projects to user %s", len(projectIds), token.UID)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			logger.Debug("Stop streaming updates: %s", stream.Context().Err())
+			return nil
+		case m := <-messages:
+			if len(projectIds) != 0 && m.ProjectId != "" && !projectIds[m.ProjectId] {
+				continue
+			}
+
+			update, err := toUpdateMessage(m)
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+
+			err = stream.Send(update)
+			if err != nil {
+				logger.Debug("Unable to send update, stop streaming: %s", err.Error())
+				return err
+			}
+		}
+	}
+}
+
+func toProjectMessage(p *project.Project) *rpc.Project {
+	return &rpc.Project{
+		Id:                 p.Id,
+		Name:               p.Name,
+		TaskIds:            p.TaskIDs,
+		Users:              p.Users,
+		Owner:              p.Owner,
+		Description:        p.Description,
+		TotalProcessPoints: int32(p.TotalProcessPoints),
+		DoneProcessPoints:  int32(p.DoneProcessPoints),
+		Status:             p.Status,
+		Archived:           p.Archived,
+		CreatedAt:          toTimestamp(p.CreatedAt),
+		UpdatedAt:          toTimestamp(p.UpdatedAt),
+	}
+}
+
+func toProjectMessages(projects []*project.Project) []*rpc.Project {
+	result := make([]*rpc.Project, len(projects))
+	for i, p := range projects {
+		result[i] = toProjectMessage(p)
+	}
+	return result
+}
+
+func toTaskMessage(t *task.Task) *rpc.Task {
+	return &rpc.Task{
+		Id:                t.Id,
+		ProcessPoints:     int32(t.ProcessPoints),
+		MaxProcessPoints:  int32(t.MaxProcessPoints),
+		Geometry:          t.Geometry,
+		AssignedUser:      t.AssignedUser,
+		Version:           int32(t.Version),
+		Difficulty:        t.Difficulty,
+		AllowedUsers:      t.AllowedUsers,
+		CreatedAt:         toTimestamp(t.CreatedAt),
+		UpdatedAt:         toTimestamp(t.UpdatedAt),
+		Bbox:              t.BoundingBox,
+		Centroid:          t.Centroid,
+		DependsOn:         t.DependsOn,
+		Blocked:           t.Blocked,
+		ChangesetComment:  t.ChangesetComment,
+		ChangesetHashtags: t.ChangesetHashtags,
+	}
+}
+
+func toTaskMessages(tasks []*task.Task) []*rpc.Task {
+	result := make([]*rpc.Task, len(tasks))
+	for i, t := range tasks {
+		result[i] = toTaskMessage(t)
+	}
+	return result
+}
+
+// toUpdateMessage encodes the data of the websocket message as JSON, so it's the same as the one websocket clients get.
+func toUpdateMessage(m websocket.Message) (*rpc.Update, error) {
+	data, err := json.Marshal(m.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding data of update")
+	}
+
+	return &rpc.Update{
+		Id:        m.Id,
+		Type:      m.Type,
+		ProjectId: m.ProjectId,
+		Data:      string(data),
+	}, nil
+}
+
+// toTimestamp returns nil for unset times, so that clients can distinguish them from the 1st January of year 1.
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
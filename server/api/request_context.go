@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/hauke96/simple-task-manager/server/auth"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Keys of the values "authenticationMiddleware" stores in the context of a request
+type requestContextKey int
+
+const (
+	requestLoggerKey requestContextKey = iota
+	requestTokenKey
+	requestTokenErrorKey
+)
+
+// authenticationMiddleware verifies the token of every request with an "Authorization" header and stores it together
+// with the logger of the request in the request context, see "requestToken" and "requestLogger". Requests with invalid
+// tokens are not rejected here, since public routes work without token. The "authenticatedTransactionHandler" rejects
+// them instead.
+func authenticationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := newRequestLogger(r)
+		ctx := context.WithValue(r.Context(), requestLoggerKey, logger)
+
+		if r.Header.Get("Authorization") != "" {
+			token, err := auth.VerifyRequest(r, logger)
+			if err != nil {
+				ctx = context.WithValue(ctx, requestTokenErrorKey, err)
+			} else {
+				logger.SetField(util.LogFieldUser, token.UID)
+				ctx = context.WithValue(ctx, requestTokenKey, token)
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestToken returns the token verified by "authenticationMiddleware" or the reason why the request has no valid
+// token.
+func requestToken(r *http.Request) (*auth.Token, error) {
+	if token, ok := r.Context().Value(requestTokenKey).(*auth.Token); ok {
+		return token, nil
+	}
+
+	if err, ok := r.Context().Value(requestTokenErrorKey).(error); ok {
+		return nil, err
+	}
+
+	return nil, errors.New("request has no token")
+}
+
+// requestLogger returns the logger of the request, which already has the request ID and the user of a valid token as
+// fields. Requests not passing the "authenticationMiddleware" get a new logger.
+func requestLogger(r *http.Request) *util.Logger {
+	if logger, ok := r.Context().Value(requestLoggerKey).(*util.Logger); ok {
+		return logger
+	}
+
+	return newRequestLogger(r)
+}
@@ -0,0 +1,155 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+const (
+	FormatCsv   = "csv"
+	FormatJsonl = "jsonl"
+
+	// ActionEndOfLog is the action of the last entry of every complete export. Its details contain the number of
+	// exported entries, so that clients can detect exports that broke off (e.g. due to a database error).
+	ActionEndOfLog = "end_of_log"
+)
+
+// Entry is one event of the audit log. Events are the changes of tasks (see the "History..." values of the task
+// package) and the changes of projects made by their owners (see the "Command..." values of the project package).
+// Reverted changes of projects appear a second time with the "_reverted" suffix at the time of the revert, undone
+// reverts with the "_redone" suffix. The entries are kept when the tasks or projects are removed.
+type Entry struct {
+	Time      time.Time       `json:"time"`
+	ProjectId string          `json:"projectId"`
+	TaskId    string          `json:"taskId"` // Empty for changes of the project
+	UserId    string          `json:"userId"`
+	Action    string          `json:"action"`
+	Details   json.RawMessage `json:"details"` // Depends on the action, e.g. the process points of the task
+}
+
+// Filter restricts the entries of the audit log. Empty/nil values don't restrict anything.
+type Filter struct {
+	From      *time.Time // Inclusive
+	To        *time.Time // Exclusive
+	ProjectId string
+	UserId    string
+}
+
+type AuditService struct {
+	*util.Logger
+	store *storePg
+}
+
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger) *AuditService {
+	return &AuditService{
+		Logger: logger,
+		store:  getStore(ctx, tx, logger),
+	}
+}
+
+// IsValidFormat returns true for the formats supported by "Export".
+func IsValidFormat(format string) bool {
+	return format == FormatCsv || format == FormatJsonl
+}
+
+// Export writes the entries matching the filter in chronological order to the writer, followed by an entry with the
+// action "ActionEndOfLog". The entries are written while they're read from the database, so that large logs don't have
+// to fit into memory. The number of written entries (without the end entry) is returned.
+func (s *AuditService) Export(filter *Filter, format string, w io.Writer) (int, error) {
+	if !IsValidFormat(format) {
+		return 0, errors.New(fmt.Sprintf("unknown audit log format '%s'", format))
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return 0, errors.New("start of the audit log period must be before its end")
+	}
+
+	writer := newEntryWriter(format, w)
+	err := writer.writeHeader()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = s.store.forEachEntry(filter, func(entry *Entry) error {
+		count++
+		return writer.write(entry)
+	})
+	if err != nil {
+		return count, err
+	}
+
+	err = writer.write(&Entry{
+		Time:    time.Now().UTC(),
+		Action:  ActionEndOfLog,
+		Details: []byte(fmt.Sprintf(`{"entries":%d}`, count)),
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, writer.flush()
+}
+
+type entryWriter interface {
+	writeHeader() error
+	write(entry *Entry) error
+	flush() error
+}
+
+func newEntryWriter(format string, w io.Writer) entryWriter {
+	if format == FormatCsv {
+		return &csvWriter{writer: csv.NewWriter(w)}
+	}
+	return &jsonlWriter{encoder: json.NewEncoder(w)}
+}
+
+// csvWriter writes one line per entry, the details are a JSON string.
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func (c *csvWriter) writeHeader() error {
+	return c.writer.Write([]string{"time", "project_id", "task_id", "user_id", "action", "details"})
+}
+
+func (c *csvWriter) write(entry *Entry) error {
+	err := c.writer.Write([]string{entry.Time.Format(time.RFC3339), entry.ProjectId, entry.TaskId, entry.UserId, entry.Action, string(entry.Details)})
+	if err != nil {
+		return errors.Wrap(err, "error writing CSV")
+	}
+	return nil
+}
+
+func (c *csvWriter) flush() error {
+	c.writer.Flush()
+	return errors.Wrap(c.writer.Error(), "error writing CSV")
+}
+
+// jsonlWriter writes one JSON object per line (JSON Lines), so that the log can be processed line by line.
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (j *jsonlWriter) writeHeader() error {
+	return nil
+}
+
+func (j *jsonlWriter) write(entry *Entry) error {
+	err := j.encoder.Encode(entry)
+	if err != nil {
+		return errors.Wrap(err, "error writing JSON line")
+	}
+	return nil
+}
+
+func (j *jsonlWriter) flush() error {
+	return nil
+}
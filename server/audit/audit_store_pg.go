@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+type storePg struct {
+	*util.Logger
+	ctx   context.Context
+	tx    *sql.Tx
+	table database.Table
+}
+
+func getStore(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
+	return &storePg{
+		Logger: logger,
+		ctx:    ctx,
+		tx:     tx,
+		table:  database.TableAuditLog,
+	}
+}
+
+// forEachEntry reads the entries matching the filter in chronological order and calls the handler for each of them
+// while iterating over the result. An error of the handler stops the iteration. Since the result is streamed, the query
+// isn't limited by the query timeout.
+func (s *storePg) forEachEntry(filter *Filter, handler func(entry *Entry) error) error {
+	conditions := make([]string, 0)
+	params := make([]interface{}, 0)
+	addCondition := func(condition string, param interface{}) {
+		params = append(params, param)
+		conditions = append(conditions, fmt.Sprintf(condition, len(params)))
+	}
+
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < $%d", *filter.To)
+	}
+	if filter.ProjectId != "" {
+		projectId, err := strconv.Atoi(filter.ProjectId)
+		if err != nil {
+			return errors.Wrapf(err, "invalid project ID '%s'", filter.ProjectId)
+		}
+		addCondition("project_id = $%d", projectId)
+	}
+	if filter.UserId != "" {
+		addCondition("user_id = $%d", filter.UserId)
+	}
+
+	where := ""
+	if len(conditions) != 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := fmt.Sprintf("SELECT created_at, project_id, COALESCE(task_id::TEXT, ''), user_id, action, details::TEXT FROM %s %s ORDER BY created_at, id;", s.table, where)
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.StreamingQueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
+	if err != nil {
+		return errors.Wrap(err, "error getting audit log")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry Entry
+		var projectId int
+		var details string
+		err = rows.Scan(&entry.Time, &projectId, &entry.TaskId, &entry.UserId, &entry.Action, &details)
+		if err != nil {
+			return errors.Wrap(err, "could not scan audit log entry")
+		}
+		entry.ProjectId = strconv.Itoa(projectId)
+		entry.Details = []byte(details)
+
+		err = handler(&entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return errors.Wrap(rows.Err(), "error reading audit log")
+}
@@ -0,0 +1,163 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/test"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq" // Make driver "postgres" usable
+)
+
+var (
+	tx *sql.Tx
+	s  *AuditService
+	h  *test.TestHelper
+)
+
+func TestMain(m *testing.M) {
+	h = &test.TestHelper{
+		Setup: setup,
+	}
+
+	m.Run()
+}
+
+func setup() {
+	config.LoadConfig("../config/test.json")
+	test.InitWithDummyData()
+	sigolo.LogLevel = sigolo.LOG_DEBUG
+
+	logger := util.NewLogger()
+
+	ctx := context.Background()
+
+	var err error
+	tx, err = database.GetTransaction(ctx, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	h.Tx = tx
+	s = Init(ctx, tx, logger)
+}
+
+func TestExport(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := tx.Exec("INSERT INTO audit_log(project_id, user_id, action, details, created_at) VALUES (1, 'Peter', 'user_added', '{\"user\": \"Anna\"}', '2020-09-04 10:00:00'), (1, 'Peter', 'user_added_reverted', '{\"user\": \"Anna\"}', '2020-09-04 11:00:00');")
+		if err != nil {
+			return err
+		}
+
+		var buffer bytes.Buffer
+		count, err := s.Export(&Filter{}, FormatJsonl, &buffer)
+		if err != nil {
+			return err
+		}
+		lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+		if count != 9 || len(lines) != 10 {
+			return errors.New(fmt.Sprintf("Expected 9 entries and the end of the log but got %d with %d lines", count, len(lines)))
+		}
+		if !strings.Contains(lines[0], `"taskId":"2"`) || !strings.Contains(lines[8], `"action":"user_added_reverted"`) {
+			return errors.New(fmt.Sprintf("Entries are not in chronological order:\n%s", buffer.String()))
+		}
+		if !strings.Contains(lines[9], `"action":"end_of_log","details":{"entries":9}`) {
+			return errors.New(fmt.Sprintf("Export should end with the number of entries:\n%s", lines[9]))
+		}
+
+		// Entries stay when the task is removed
+		_, err = tx.Exec("DELETE FROM tasks WHERE id=3;")
+		if err != nil {
+			return err
+		}
+
+		from := time.Date(2020, 9, 2, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2020, 9, 4, 0, 0, 0, 0, time.UTC)
+		buffer.Reset()
+		count, err = s.Export(&Filter{From: &from, To: &to, ProjectId: "2", UserId: "Maria"}, FormatCsv, &buffer)
+		if err != nil {
+			return err
+		}
+		lines = strings.Split(strings.TrimSpace(buffer.String()), "\n")
+		if count != 2 || len(lines) != 4 || lines[0] != "time,project_id,task_id,user_id,action,details" || !strings.Contains(lines[3], ",end_of_log,") {
+			return errors.New(fmt.Sprintf("Filtered CSV does not match:\n%s", buffer.String()))
+		}
+		if !strings.HasPrefix(lines[1], "2020-09-02T08:00:00Z,2,3,Maria,assigned,") {
+			return errors.New(fmt.Sprintf("CSV line '%s' does not match", lines[1]))
+		}
+
+		buffer.Reset()
+		count, err = s.Export(&Filter{ProjectId: "1", UserId: "Peter"}, FormatJsonl, &buffer)
+		if err != nil {
+			return err
+		}
+		if count != 2 {
+			return errors.New(fmt.Sprintf("Expected the command and its revert but got %d entries", count))
+		}
+
+		return nil
+	})
+}
+
+func TestExportInvalidParameters(t *testing.T) {
+	// The parameters are checked before any query is made, so no transaction is needed
+	s := Init(context.Background(), nil, util.NewLogger())
+	var buffer bytes.Buffer
+
+	_, err := s.Export(&Filter{}, "xml", &buffer)
+	if err == nil {
+		t.Errorf("Unknown formats should not be possible")
+	}
+
+	from := time.Date(2020, 9, 2, 0, 0, 0, 0, time.UTC)
+	_, err = s.Export(&Filter{From: &from, To: &from}, FormatCsv, &buffer)
+	if err == nil {
+		t.Errorf("Empty periods should not be possible")
+	}
+
+	if buffer.Len() != 0 {
+		t.Errorf("Nothing should be written for invalid parameters: %s", buffer.String())
+	}
+}
+
+func TestWriteEntries(t *testing.T) {
+	entry := &Entry{
+		Time:      time.Date(2020, 9, 1, 10, 0, 0, 0, time.UTC),
+		ProjectId: "2",
+		TaskId:    "3",
+		UserId:    "Maria",
+		Action:    "reopened",
+		Details:   []byte(`{"comment":"Missing, buildings"}`),
+	}
+
+	var buffer bytes.Buffer
+	writer := newEntryWriter(FormatCsv, &buffer)
+	if writer.writeHeader() != nil || writer.write(entry) != nil || writer.flush() != nil {
+		t.Errorf("Writing CSV should work")
+		return
+	}
+	expected := "time,project_id,task_id,user_id,action,details\n2020-09-01T10:00:00Z,2,3,Maria,reopened,\"{\"\"comment\"\":\"\"Missing, buildings\"\"}\"\n"
+	if buffer.String() != expected {
+		t.Errorf("CSV '%s' does not match '%s'", buffer.String(), expected)
+	}
+
+	buffer.Reset()
+	writer = newEntryWriter(FormatJsonl, &buffer)
+	if writer.writeHeader() != nil || writer.write(entry) != nil || writer.write(entry) != nil || writer.flush() != nil {
+		t.Errorf("Writing JSON lines should work")
+		return
+	}
+	line := `{"time":"2020-09-01T10:00:00Z","projectId":"2","taskId":"3","userId":"Maria","action":"reopened","details":{"comment":"Missing, buildings"}}` + "\n"
+	if buffer.String() != line+line {
+		t.Errorf("JSON lines '%s' do not match '%s'", buffer.String(), line+line)
+	}
+}
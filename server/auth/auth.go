@@ -8,16 +8,24 @@ import (
 	"time"
 
 	"fmt"
-	"io/ioutil"
 	"net/http"
+	"strconv"
+	"sync"
 
 	"github.com/hauke96/sigolo"
 	"github.com/kurrik/oauth1a"
 
 	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/osm"
 	"github.com/hauke96/simple-task-manager/server/util"
 )
 
+// Values of the "auth-backend" config entry
+const (
+	BackendOsm   = "osm"
+	BackendLocal = "local"
+)
+
 var (
 	oauthRedirectUrl  string
 	oauthConsumerKey  string
@@ -25,18 +33,59 @@ var (
 	oauthBaseUrl      string
 	osmUserDetailsUrl string
 
-	service *oauth1a.Service
+	service   *oauth1a.Service
+	osmClient *osm.Client
 
 	tokenValidityDuration time.Duration
 
-	configs map[string]*oauth1a.UserConfig
-	loggers map[string]*util.Logger
+	// Logins waiting for the callback of the OSM server by their config key. Logins happen concurrently, so the map is
+	// only accessed via "storePendingLogin" and "takePendingLogin".
+	pendingLogins      map[string]*pendingLogin
+	pendingLoginsMutex = &sync.Mutex{}
 )
 
+// pendingLogin is everything the callback needs to finish the login process started by "OauthLogin".
+type pendingLogin struct {
+	userConfig  *oauth1a.UserConfig
+	logger      *util.Logger
+	redirectUrl string // URL of the client to redirect to after the login
+}
+
+// IsLocalBackend returns true when users log in with local accounts instead of their OSM account.
+func IsLocalBackend() bool {
+	return config.Conf.AuthBackend == BackendLocal
+}
+
 func Init() {
 	err := tokenInit()
 	sigolo.FatalCheck(err)
 
+	tokenValidityDuration, err = time.ParseDuration(config.Conf.TokenValidityDuration)
+	sigolo.FatalCheckf(err, "unable to parse token validity duration from config entry '%s'", config.Conf.TokenValidityDuration)
+
+	err = shareInit()
+	sigolo.FatalCheck(err)
+
+	loginLockout, err := time.ParseDuration(config.Conf.LoginLockout)
+	sigolo.FatalCheckf(err, "unable to parse login lockout from config entry '%s'", config.Conf.LoginLockout)
+	err = setLoginThrottle(config.Conf.LoginMaxFailures, loginLockout)
+	sigolo.FatalCheck(err)
+
+	switch config.Conf.AuthBackend {
+	case BackendLocal:
+		// Logins are handled by the API with the accounts from the database, there's nothing to set up here. Local
+		// accounts are created by admins, so the server is invite-only anyway.
+		sigolo.Info("Use local accounts for logins")
+		return
+	case BackendOsm:
+		sigolo.Info("Use OSM server for logins")
+		if config.Conf.InviteOnly {
+			sigolo.Info("Only invited users can log in")
+		}
+	default:
+		sigolo.Fatal("Unknown auth backend '%s', use '%s' or '%s'", config.Conf.AuthBackend, BackendOsm, BackendLocal)
+	}
+
 	oauthRedirectUrl = fmt.Sprintf("%s:%d/oauth_callback", config.Conf.ServerUrl, config.Conf.Port)
 	oauthConsumerKey = config.Conf.OauthConsumerKey
 	oauthSecret = config.Conf.OauthSecret
@@ -55,11 +104,15 @@ func Init() {
 		Signer: new(oauth1a.HmacSha1Signer),
 	}
 
-	tokenValidityDuration, err = time.ParseDuration(config.Conf.TokenValidityDuration)
-	sigolo.FatalCheckf(err, "unable to parse token validity duration from config entry '%s'", config.Conf.TokenValidityDuration)
+	osmClient = osm.GetClient()
+
+	pendingLoginsMutex.Lock()
+	pendingLogins = make(map[string]*pendingLogin)
+	pendingLoginsMutex.Unlock()
 
-	configs = make(map[string]*oauth1a.UserConfig)
-	loggers = make(map[string]*util.Logger)
+	if len(config.Conf.OauthClients) == 0 {
+		sigolo.Error("No OAuth clients configured, logins will not be possible")
+	}
 }
 
 func OauthLogin(w http.ResponseWriter, r *http.Request) {
@@ -82,21 +135,41 @@ func OauthLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	clientId, err := util.GetParam("client_id", r)
+	if err != nil {
+		logger.Stack(err)
+		util.ResponseBadRequest(w, logger, err)
+		return
+	}
+
+	// Only redirect to URLs registered for the client. Otherwise anyone could trick users into logging in and then
+	// receive their token on a foreign page.
+	err = verifyRedirectUrl(clientId, clientRedirectUrl)
+	if err != nil {
+		logger.Stack(err)
+		util.ResponseBadRequest(w, logger, err)
+		return
+	}
+
 	// We add the config-param to the redirect URL in order to transfer the config key to the callback function. There
 	// we use this key to retrieve the config back and be able to make proper requests to the OSM server..
-	// The redirect param is the URL of the web application we want to redirect back to, after everything is done.
-	service.ClientConfig.CallbackURL = oauthRedirectUrl + "?redirect=" + clientRedirectUrl + "&config=" + configKey
-	logger.Log("%s", service.ClientConfig.CallbackURL)
-
-	httpClient := new(http.Client)
-	err = userConfig.GetRequestToken(service, httpClient)
+	// The URL of the web application we want to redirect back to, after everything is done, is stored under this key
+	// as well.
+	// The service is shared by all logins, so each login gets its own copy with its own callback URL.
+	clientConfig := *service.ClientConfig
+	clientConfig.CallbackURL = oauthRedirectUrl + "?config=" + configKey
+	loginService := *service
+	loginService.ClientConfig = &clientConfig
+	logger.Log("%s", clientConfig.CallbackURL)
+
+	err = userConfig.GetRequestToken(&loginService, osmClient.HttpClient())
 	if err != nil {
 		//sigolo.Error("could not get request token from config: %s", err.Error())
 		logger.Stack(err)
 		return
 	}
 
-	url, err := userConfig.GetAuthorizeURL(service)
+	url, err := userConfig.GetAuthorizeURL(&loginService)
 	if err != nil {
 		//sigolo.Error("could not get authorization URL from config: %s", err.Error())
 		logger.Stack(err)
@@ -105,8 +178,11 @@ func OauthLogin(w http.ResponseWriter, r *http.Request) {
 
 	logger.Debug("Redirect to URL: %s", url)
 
-	configs[configKey] = userConfig
-	loggers[configKey] = logger
+	storePendingLogin(configKey, &pendingLogin{
+		userConfig:  userConfig,
+		logger:      logger,
+		redirectUrl: clientRedirectUrl,
+	})
 
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
@@ -114,67 +190,75 @@ func OauthLogin(w http.ResponseWriter, r *http.Request) {
 func OauthCallback(w http.ResponseWriter, r *http.Request) {
 	sigolo.Debug("Callback called")
 
+	err := CheckLoginAttempt(r)
+	if err != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(err.(*LoginThrottledError).RetrySeconds()))
+		util.ErrorResponse(w, util.NewLogger(), err, http.StatusTooManyRequests)
+		return
+	}
+
 	configKey, err := util.GetParam("config", r)
 	if err != nil {
 		logger := util.NewLogger()
 		logger.Err("Could not load config key from request URL")
 		logger.Stack(err)
+		RecordFailedLogin(r)
 		util.ResponseBadRequest(w, logger, err)
 		return
 	}
 
-	// Get the logger for this login process.
-	logger, ok := loggers[configKey]
-	if !ok || logger == nil {
-		err := errors.New(fmt.Sprintf("Logger for config key %s not found", configKey))
+	// Get the login process started by "OauthLogin". It's removed, so that every login can only be finished once.
+	login := takePendingLogin(configKey)
+	if login == nil {
+		err := errors.New(fmt.Sprintf("Login for config key %s not found", configKey))
 		logger := util.NewLogger()
 		logger.Stack(err)
+		RecordFailedLogin(r)
 		util.ResponseBadRequest(w, logger, err)
 		return
 	}
-	loggers[configKey] = nil // Remove the config, we don't need it  anymore
+	logger := login.logger
+	userConfig := login.userConfig
 
-	// Get the config where the request tokens are stored in. They are needed later to get some basic user information.
-	userConfig, ok := configs[configKey]
-	if !ok || userConfig == nil {
-		err := errors.New("User config not found")
-		logger.Stack(err)
-		util.ResponseBadRequest(w, logger, err)
-		return
-	}
-	configs[configKey] = nil // Remove the config, we don't need it  anymore
+	// This gets the redirect URL of the web-client (so e.g. "https://stm-hauke-stieler.de/oauth-landing"), which has
+	// been verified and stored during the login. The "redirect" parameter of this request is therefore not used.
+	clientRedirectUrl := login.redirectUrl
 
-	// This gets the redirect URL of the web-client. So e.g. "https://stm-hauke-stieler.de/oauth-landing"
-	clientRedirectUrl, err := util.GetParam("redirect", r)
+	// Request access token from the OSM server in order to then get some user information.
+	err = requestAccessToken(r, userConfig)
 	if err != nil {
 		logger.Stack(err)
-		util.ResponseBadRequest(w, logger, err)
+		RecordFailedLogin(r)
+		util.ResponseInternalError(w, logger, err)
 		return
 	}
 
-	// Request access token from the OSM server in order to then get some user information.
-	err = requestAccessToken(r, userConfig)
+	userName, userId, err := requestUserInformation(logger, userConfig)
 	if err != nil {
 		logger.Stack(err)
 		util.ResponseInternalError(w, logger, err)
 		return
 	}
 
-	userName, userId, err := requestUserInformation(userConfig)
+	allowed, err := isLoginAllowed(logger, userName, userId)
 	if err != nil {
 		logger.Stack(err)
 		util.ResponseInternalError(w, logger, err)
 		return
 	}
+	if !allowed {
+		logger.Log("Reject login of user '%s' (%s), the user is not invited", userName, userId)
+		err = errors.New(fmt.Sprintf("Sorry, only invited users can use this server and the OSM user '%s' has not been invited yet. Please ask the admins of this server for an invitation.", userName))
+		util.ErrorResponse(w, logger, err, http.StatusForbidden)
+		return
+	}
+
+	RecordSuccessfulLogin(r)
 
 	// Until here, the user is considered to be successfully logged in. Now we can create the token used to authenticate
 	// against this server.
 
-	logger.Log("Create token for user '%s'", userName)
-
-	validUntil := time.Now().Add(tokenValidityDuration).Unix()
-
-	encodedTokenString, err := createTokenString(logger, userName, userId, validUntil)
+	encodedTokenString, err := CreateToken(logger, userName, userId, r)
 	if err != nil {
 		logger.Stack(err)
 		util.ResponseInternalError(w, logger, err)
@@ -186,43 +270,67 @@ func OauthCallback(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, clientRedirectUrl+"?token="+encodedTokenString, http.StatusTemporaryRedirect)
 }
 
+func storePendingLogin(configKey string, login *pendingLogin) {
+	pendingLoginsMutex.Lock()
+	defer pendingLoginsMutex.Unlock()
+
+	pendingLogins[configKey] = login
+}
+
+// takePendingLogin returns and removes the login with the given key. It returns nil when there's no such login.
+func takePendingLogin(configKey string) *pendingLogin {
+	pendingLoginsMutex.Lock()
+	defer pendingLoginsMutex.Unlock()
+
+	login, ok := pendingLogins[configKey]
+	if !ok {
+		return nil
+	}
+	delete(pendingLogins, configKey)
+
+	return login
+}
+
 func requestAccessToken(r *http.Request, userConfig *oauth1a.UserConfig) error {
 	token := r.FormValue("oauth_token")
 	userConfig.AccessTokenSecret = token
 	userConfig.Verifier = r.FormValue("oauth_verifier")
 
-	httpClient := new(http.Client)
-	return userConfig.GetAccessToken(userConfig.RequestTokenKey, userConfig.Verifier, service, httpClient)
+	return userConfig.GetAccessToken(userConfig.RequestTokenKey, userConfig.Verifier, service, osmClient.HttpClient())
 }
 
-func requestUserInformation(userConfig *oauth1a.UserConfig) (string, string, error) {
-	req, err := http.NewRequest("GET", osmUserDetailsUrl, nil)
-	if err != nil {
-		return "", "", errors.Wrap(err, "Creating request user information failed")
-	}
-
-	// The OSM server expects a signed request
-	err = service.Sign(req, userConfig)
-	if err != nil {
-		return "", "", errors.Wrap(err, "Signing request failed")
-	}
-
-	client := &http.Client{}
-	response, err := client.Do(req)
+func requestUserInformation(logger *util.Logger, userConfig *oauth1a.UserConfig) (string, string, error) {
+	// Every login gets a new access token and the user isn't known before the response arrives, so there's nothing a
+	// cached response could be found by. The request is therefore not cached (but still retried).
+	responseBody, err := osmClient.Get(logger, "", func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", osmUserDetailsUrl, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "Creating request user information failed")
+		}
+
+		// The OSM server expects a signed request
+		err = service.Sign(req, userConfig)
+		if err != nil {
+			return nil, errors.Wrap(err, "Signing request failed")
+		}
+
+		return req, nil
+	})
 	if err != nil {
 		return "", "", errors.Wrap(err, "Requesting user information failed")
 	}
 
-	responseBody, err := ioutil.ReadAll(response.Body)
-	defer response.Body.Close()
+	var osmResponse util.Osm
+	err = xml.Unmarshal(responseBody, &osmResponse)
 	if err != nil {
-		return "", "", errors.Wrap(err, "Could not get response body")
+		return "", "", errors.Wrap(err, "Could not parse user information")
 	}
 
-	var osm util.Osm
-	xml.Unmarshal(responseBody, &osm)
+	if osmResponse.User.UserId == "" {
+		return "", "", errors.New("User information does not contain a user ID")
+	}
 
-	return osm.User.DisplayName, osm.User.UserId, nil
+	return osmResponse.User.DisplayName, osmResponse.User.UserId, nil
 }
 
 func getRandomBytes(count int) ([]byte, error) {
@@ -240,17 +348,48 @@ func getRandomBytes(count int) ([]byte, error) {
 	return bytes, nil
 }
 
+// CreateToken starts a new session for the login of the given user and creates the encoded token, which is valid for
+// the configured duration. Besides the OAuth callback, this is used by the login of local accounts and by the API tests
+// to authenticate fake users without a login at the OSM server. The request is used to record the address and user
+// agent of the client, it's nil for fake users.
+func CreateToken(logger *util.Logger, userName string, userId string, r *http.Request) (string, error) {
+	logger.Log("Create token for user '%s'", userName)
+
+	validUntil := time.Now().Add(tokenValidityDuration)
+
+	ip, userAgent := "", ""
+	if r != nil {
+		ip, userAgent = clientAddress(r), r.UserAgent()
+	}
+
+	s, err := startSession(logger, userId, ip, userAgent, validUntil)
+	if err != nil {
+		return "", err
+	}
+
+	return createTokenString(logger, userName, userId, s.Id, validUntil.Unix())
+}
+
 // verifyRequest checks the integrity of the token and the "validUntil" date. It
 // then returns the token but without the secret part, just the meta information
 // (e.g. user name) is set.
 func VerifyRequest(r *http.Request, logger *util.Logger) (*Token, error) {
-	encodedToken := r.Header.Get("Authorization")
+	return VerifyToken(r.Header.Get("Authorization"), logger)
+}
 
+// VerifyToken works like "VerifyRequest" but for tokens that are not part of the "Authorization" header, e.g. tokens
+// sent via websocket messages.
+func VerifyToken(encodedToken string, logger *util.Logger) (*Token, error) {
 	token, err := verifyToken(logger, encodedToken)
 	if err != nil {
 		return nil, err
 	}
 
+	err = verifySession(logger, token)
+	if err != nil {
+		return nil, err
+	}
+
 	logger.Debug("User '%s' has valid token", token.User)
 
 	token.Secret = ""
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/kurrik/oauth1a"
+)
+
+func TestPendingLogins(t *testing.T) {
+	pendingLogins = make(map[string]*pendingLogin)
+
+	logger := util.NewLogger()
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			storePendingLogin(fmt.Sprintf("key-%d", i), &pendingLogin{
+				userConfig:  &oauth1a.UserConfig{},
+				logger:      logger,
+				redirectUrl: fmt.Sprintf("https://stm.example.com/landing-%d", i),
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	login := takePendingLogin("key-7")
+	if login == nil || login.redirectUrl != "https://stm.example.com/landing-7" {
+		t.Errorf("Login should be found: %v", login)
+		return
+	}
+
+	if takePendingLogin("key-7") != nil {
+		t.Errorf("Logins should only be taken once")
+	}
+	if takePendingLogin("unknown") != nil {
+		t.Errorf("Unknown logins should not be found")
+	}
+	if len(pendingLogins) != 19 {
+		t.Errorf("Other logins should be kept, but there are %d", len(pendingLogins))
+	}
+}
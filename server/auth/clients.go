@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/pkg/errors"
+)
+
+// verifyRedirectUrl checks whether the given redirect URL is registered for the given client in the config. This
+// prevents the login from redirecting tokens to arbitrary (potentially malicious) pages.
+func verifyRedirectUrl(clientId string, redirectUrl string) error {
+	allowedUrls, ok := config.Conf.OauthClients[clientId]
+	if !ok {
+		return errors.New(fmt.Sprintf("unknown client '%s'", clientId))
+	}
+
+	normalizedRedirectUrl, err := normalizeUrl(redirectUrl)
+	if err != nil {
+		return err
+	}
+
+	for _, allowedUrl := range allowedUrls {
+		normalizedAllowedUrl, err := normalizeUrl(allowedUrl)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("invalid redirect URL configured for client '%s'", clientId))
+		}
+
+		if normalizedRedirectUrl == normalizedAllowedUrl {
+			return nil
+		}
+	}
+
+	return errors.New(fmt.Sprintf("redirect URL '%s' not allowed for client '%s'", redirectUrl, clientId))
+}
+
+// normalizeUrl turns the URL into the form "scheme://host[:port]/path" so that e.g. an empty port ("https://foo.com:/")
+// or upper case letters in the host don't make a difference. Query and fragment are not allowed.
+func normalizeUrl(rawUrl string) (string, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("invalid URL '%s'", rawUrl))
+	}
+
+	if u.Scheme == "" || u.Hostname() == "" {
+		return "", errors.New(fmt.Sprintf("URL '%s' must be absolute", rawUrl))
+	}
+
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", errors.New(fmt.Sprintf("URL '%s' must not contain query or fragment", rawUrl))
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if u.Port() != "" {
+		host += ":" + u.Port()
+	}
+
+	return strings.ToLower(u.Scheme) + "://" + host + u.EscapedPath(), nil
+}
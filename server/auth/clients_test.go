@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/hauke96/simple-task-manager/server/config"
+)
+
+func TestVerifyRedirectUrl(t *testing.T) {
+	config.Conf = &config.Config{
+		OauthClients: map[string][]string{
+			"stm-web": {"https://stm.example.com/oauth-landing", "http://localhost:4200/oauth-landing"},
+			"other":   {"https://other.example.com/landing"},
+		},
+	}
+
+	// Registered URLs
+
+	err := verifyRedirectUrl("stm-web", "https://stm.example.com/oauth-landing")
+	if err != nil {
+		t.Errorf("Registered URL should be allowed: %s", err.Error())
+	}
+
+	err = verifyRedirectUrl("stm-web", "http://localhost:4200/oauth-landing")
+	if err != nil {
+		t.Errorf("Registered URL should be allowed: %s", err.Error())
+	}
+
+	// Empty port and upper case host (as created by the web client) are normalized
+
+	err = verifyRedirectUrl("stm-web", "https://STM.example.com:/oauth-landing")
+	if err != nil {
+		t.Errorf("Normalized URL should be allowed: %s", err.Error())
+	}
+
+	// URL of a different client
+
+	err = verifyRedirectUrl("stm-web", "https://other.example.com/landing")
+	if err == nil {
+		t.Error("URL of different client should not be allowed")
+	}
+
+	// Unknown URLs and clients
+
+	err = verifyRedirectUrl("stm-web", "https://evil.example.com/oauth-landing")
+	if err == nil {
+		t.Error("Unknown URL should not be allowed")
+	}
+
+	err = verifyRedirectUrl("stm-web", "https://stm.example.com/oauth-landing?foo=bar")
+	if err == nil {
+		t.Error("URL with query should not be allowed")
+	}
+
+	err = verifyRedirectUrl("stm-web", "/oauth-landing")
+	if err == nil {
+		t.Error("Relative URL should not be allowed")
+	}
+
+	err = verifyRedirectUrl("unknown", "https://stm.example.com/oauth-landing")
+	if err == nil {
+		t.Error("Unknown client should not be allowed")
+	}
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/invite"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// isLoginAllowed returns true when the OSM user is allowed to log in. That's always the case unless the server is
+// invite-only, then only admins and invited users are allowed. The invitations are read in a separate transaction, like
+// the sessions.
+func isLoginAllowed(logger *util.Logger, userName string, userId string) (bool, error) {
+	if !config.Conf.InviteOnly {
+		return true, nil
+	}
+
+	// Admins have to be able to log in to invite the first users
+	for _, admin := range config.Conf.Admins {
+		if admin == userId {
+			return true, nil
+		}
+	}
+
+	ctx := context.Background()
+
+	tx, err := database.GetTransaction(ctx, logger)
+	if err != nil {
+		return false, errors.Wrap(err, "error getting transaction")
+	}
+	defer tx.Rollback()
+
+	return invite.Init(ctx, tx, logger).IsInvited(userName)
+}
@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/util"
+)
+
+func TestIsLoginAllowedWithoutDatabase(t *testing.T) {
+	oldConf := config.Conf
+	defer func() { config.Conf = oldConf }()
+	config.Conf = &config.Config{Admins: []string{"123"}}
+
+	allowed, err := isLoginAllowed(util.NewLogger(), "Peter", "456")
+	if err != nil || !allowed {
+		t.Errorf("Everyone should be allowed to log in when the server is not invite-only: %v", err)
+	}
+
+	// Admins don't need an invitation, so the database is not needed
+	config.Conf.InviteOnly = true
+	allowed, err = isLoginAllowed(util.NewLogger(), "Maria", "123")
+	if err != nil || !allowed {
+		t.Errorf("Admins should always be allowed to log in: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/session"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// startSession stores the session of a login in a separate transaction. This way, the session exists as soon as the
+// token is handed out, even when the login itself happens within the transaction of a request.
+func startSession(logger *util.Logger, userId string, ip string, userAgent string, validUntil time.Time) (*session.Session, error) {
+	ctx := context.Background()
+
+	tx, err := database.GetTransaction(ctx, logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting transaction")
+	}
+
+	s, err := session.Init(ctx, tx, logger).Start(userId, ip, userAgent, validUntil)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Wrap(err, "error committing new session")
+	}
+
+	return s, nil
+}
+
+// verifySession checks that the session of the token is still active, e.g. hasn't been revoked by the user. This happens
+// on every request, so the check only reads the session without a transaction. The time of the last usage is updated
+// in the background when needed.
+func verifySession(logger *util.Logger, token *Token) error {
+	ctx := context.Background()
+
+	db, err := database.GetConnection(logger)
+	if err != nil {
+		return errors.Wrap(err, "error getting database connection")
+	}
+
+	s, err := session.InitWithoutTransaction(ctx, db, logger).Verify(token.Session, token.UID)
+	if err != nil {
+		return err
+	}
+
+	if s.NeedsTouch() {
+		go touchSession(logger, s.Id)
+	}
+
+	return nil
+}
+
+// touchSession updates the time of the last usage of the session in a separate transaction. Errors are only logged,
+// since the request itself has already been verified.
+func touchSession(logger *util.Logger, sessionId string) {
+	ctx := context.Background()
+
+	tx, err := database.GetTransaction(ctx, logger)
+	if err != nil {
+		logger.Err("Unable to get transaction to update usage of session %s: %s", sessionId, err.Error())
+		return
+	}
+
+	err = session.Init(ctx, tx, logger).Touch(sessionId)
+	if err != nil {
+		tx.Rollback()
+		logger.Err("Unable to update usage of session %s: %s", sessionId, err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Err("Unable to commit usage of session %s: %s", sessionId, err.Error())
+	}
+}
+
+// clientAddress returns the IP address of the direct connection. Like the IP filter of the API, headers like
+// "X-Forwarded-For" are ignored since they can be set by anyone.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
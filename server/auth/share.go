@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hauke96/sigolo"
+	"github.com/pkg/errors"
+
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/util"
+)
+
+// ShareToken grants read-only access to one project without an account. Other than the "Token" it doesn't belong to a
+// user, so it's only accepted by the routes for shared projects.
+type ShareToken struct {
+	ProjectId  string `json:"project_id"`
+	ValidUntil int64  `json:"valid_until"`
+	Secret     string `json:"secret"`
+}
+
+var (
+	shareKey         []byte
+	shareMaxValidity time.Duration
+)
+
+func shareInit() error {
+	var err error
+	shareMaxValidity, err = time.ParseDuration(config.Conf.ShareMaxValidity)
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse share link validity from config entry '%s'", config.Conf.ShareMaxValidity)
+	}
+
+	if config.Conf.ShareLinkKey != "" {
+		shareKey = []byte(config.Conf.ShareLinkKey)
+		return nil
+	}
+
+	sigolo.Info("No key for share links set, they become invalid after a restart")
+	shareKey, err = getRandomBytes(256)
+	return err
+}
+
+// CreateShareToken creates an URL-safe token granting read-only access to the project for the given duration, which
+// must not exceed the configured maximum.
+func CreateShareToken(projectId string, validity time.Duration) (string, time.Time, error) {
+	if validity <= 0 || validity > shareMaxValidity {
+		return "", time.Time{}, errors.New(fmt.Sprintf("validity must be between 0 and %s but was %s", shareMaxValidity, validity))
+	}
+
+	validUntil := time.Now().Add(validity)
+
+	token := &ShareToken{
+		ProjectId:  projectId,
+		ValidUntil: validUntil.Unix(),
+		Secret:     createShareSecret(projectId, validUntil.Unix()),
+	}
+
+	jsonBytes, err := json.Marshal(token)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "error marshalling share token object")
+	}
+
+	return base64.RawURLEncoding.EncodeToString(jsonBytes), validUntil, nil
+}
+
+// VerifyShareToken checks the signature and expiration of the share token and returns it.
+func VerifyShareToken(encodedToken string, logger *util.Logger) (*ShareToken, error) {
+	tokenBytes, err := base64.RawURLEncoding.DecodeString(encodedToken)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding encoded share token")
+	}
+
+	var token ShareToken
+	err = json.Unmarshal(tokenBytes, &token)
+	if err != nil {
+		logger.Err("Unable to unmarshal share token bytes: %s", string(tokenBytes))
+		return nil, errors.Wrap(err, "error unmarshalling share token object")
+	}
+
+	targetSecret := createShareSecret(token.ProjectId, token.ValidUntil)
+	if !hmac.Equal([]byte(token.Secret), []byte(targetSecret)) {
+		return nil, errors.New("Secret not valid")
+	}
+
+	if token.ValidUntil < time.Now().Unix() {
+		return nil, errors.New("Share token expired")
+	}
+
+	return &token, nil
+}
+
+// createShareSecret works like "createSecret" but with a different base string and key, so that user tokens and share
+// tokens can't be used for each other.
+func createShareSecret(projectId string, expirationTime int64) string {
+	secretBaseString := fmt.Sprintf("share\n%s\n%d\n", projectId, expirationTime)
+
+	hash := hmac.New(sha256.New, shareKey)
+	hash.Write([]byte(secretBaseString))
+
+	return base64.StdEncoding.EncodeToString(hash.Sum(nil))
+}
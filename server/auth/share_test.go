@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/util"
+)
+
+func TestShareToken(t *testing.T) {
+	config.Conf = &config.Config{
+		ShareMaxValidity: "24h",
+		ShareLinkKey:     "test-key",
+	}
+	err := shareInit()
+	if err != nil {
+		t.Fatalf("Init should work: %s", err.Error())
+	}
+	logger := util.NewLogger()
+
+	token, validUntil, err := CreateShareToken("12", time.Hour)
+	if err != nil {
+		t.Fatalf("Creating token should work: %s", err.Error())
+	}
+	if strings.ContainsAny(token, "+/=") {
+		t.Errorf("Token should be URL-safe: %s", token)
+	}
+	if validUntil.Before(time.Now().Add(59 * time.Minute)) {
+		t.Errorf("Token should be valid for one hour but is valid until %s", validUntil)
+	}
+
+	shareToken, err := VerifyShareToken(token, logger)
+	if err != nil {
+		t.Fatalf("Token should be valid: %s", err.Error())
+	}
+	if shareToken.ProjectId != "12" {
+		t.Errorf("Token should be for project 12 but was for %s", shareToken.ProjectId)
+	}
+
+	// Too long validity
+
+	_, _, err = CreateShareToken("12", 48*time.Hour)
+	if err == nil {
+		t.Error("Validity above the maximum should not be possible")
+	}
+
+	// Manipulated token
+
+	manipulatedToken := *shareToken
+	manipulatedToken.ProjectId = "13"
+	_, err = VerifyShareToken(encodeShareToken(t, &manipulatedToken), logger)
+	if err == nil {
+		t.Error("Token for other project should not be valid")
+	}
+
+	// Expired token
+
+	expiredToken := &ShareToken{
+		ProjectId:  "12",
+		ValidUntil: time.Now().Add(-time.Minute).Unix(),
+	}
+	expiredToken.Secret = createShareSecret(expiredToken.ProjectId, expiredToken.ValidUntil)
+	_, err = VerifyShareToken(encodeShareToken(t, expiredToken), logger)
+	if err == nil {
+		t.Error("Expired token should not be valid")
+	}
+
+	// User token secrets are not valid for share tokens
+
+	key = []byte("test-key")
+	userToken := &ShareToken{
+		ProjectId:  "12",
+		ValidUntil: shareToken.ValidUntil,
+		Secret:     createSecret("", "12", "", shareToken.ValidUntil),
+	}
+	_, err = VerifyShareToken(encodeShareToken(t, userToken), logger)
+	if err == nil {
+		t.Error("Secret of user token should not be valid")
+	}
+}
+
+func encodeShareToken(t *testing.T, token *ShareToken) string {
+	jsonBytes, err := json.Marshal(token)
+	if err != nil {
+		t.Fatalf("Encoding token should work: %s", err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(jsonBytes)
+}
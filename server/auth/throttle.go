@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// LoginThrottleStats are the counters of the brute-force protection of the logins since the start of the server.
+type LoginThrottleStats struct {
+	FailedLogins   int64 `json:"failedLogins"`
+	RejectedLogins int64 `json:"rejectedLogins"` // Logins rejected due to a delay or lockout without checking them
+	Lockouts       int64 `json:"lockouts"`
+	LockedIps      int   `json:"lockedIps"` // Number of currently locked IP addresses
+}
+
+// LoginThrottledError is returned when a login isn't allowed due to previous failed logins.
+type LoginThrottledError struct {
+	RetryAfter time.Duration // Time until the next login is allowed
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("too many failed logins, try again in %d seconds", e.RetrySeconds())
+}
+
+// RetrySeconds returns the time until the next login is allowed in full seconds, e.g. for the "Retry-After" header.
+func (e *LoginThrottledError) RetrySeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// loginAttempts are the failed logins of one IP address since the last successful login.
+type loginAttempts struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+var (
+	loginMaxFailures int
+	loginLockout     time.Duration
+
+	attempts      = make(map[string]*loginAttempts) // IP address -> failed logins
+	attemptsStats = LoginThrottleStats{}
+	lastPrune     = time.Now()
+	attemptsMutex = &sync.Mutex{}
+)
+
+// setLoginThrottle configures after how many failed logins the IP address is locked and for how long. A maximum of 0
+// disables the protection.
+func setLoginThrottle(maxFailures int, lockout time.Duration) error {
+	if maxFailures < 0 || lockout < 0 {
+		return errors.New(fmt.Sprintf("login throttle must not be negative but was %d failures and %s lockout", maxFailures, lockout))
+	}
+
+	attemptsMutex.Lock()
+	defer attemptsMutex.Unlock()
+
+	loginMaxFailures = maxFailures
+	loginLockout = lockout
+	attempts = make(map[string]*loginAttempts)
+
+	return nil
+}
+
+// CheckLoginAttempt returns a "LoginThrottledError" when logins from the address of the request are currently not
+// allowed. After every failed login, the next attempt is delayed twice as long as the previous one (starting with one
+// second) and after the configured number of failures, logins are locked completely.
+//
+// There are intentionally no counters per user: The user of a failed login is unknown or given by the client, so anyone
+// could lock out other users by sending their IDs.
+func CheckLoginAttempt(r *http.Request) error {
+	attemptsMutex.Lock()
+	defer attemptsMutex.Unlock()
+
+	if loginMaxFailures == 0 {
+		return nil
+	}
+
+	a, ok := attempts[clientAddress(r)]
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+	retryAt := a.lockedUntil
+	if retryAt.IsZero() {
+		retryAt = a.lastFailure.Add(loginDelay(a.failures))
+	}
+
+	if now.Before(retryAt) {
+		attemptsStats.RejectedLogins++
+		return &LoginThrottledError{RetryAfter: retryAt.Sub(now)}
+	}
+
+	return nil
+}
+
+// RecordFailedLogin counts the failed login for the address of the request.
+func RecordFailedLogin(r *http.Request) {
+	attemptsMutex.Lock()
+	defer attemptsMutex.Unlock()
+
+	if loginMaxFailures == 0 {
+		return
+	}
+
+	now := time.Now()
+	pruneAttempts(now)
+
+	attemptsStats.FailedLogins++
+
+	address := clientAddress(r)
+	a, ok := attempts[address]
+	if !ok || (!a.lockedUntil.IsZero() && now.After(a.lockedUntil)) {
+		// Start from scratch after a lockout ended
+		a = &loginAttempts{}
+		attempts[address] = a
+	}
+
+	a.failures++
+	a.lastFailure = now
+
+	if a.failures >= loginMaxFailures && a.lockedUntil.IsZero() {
+		a.lockedUntil = now.Add(loginLockout)
+		attemptsStats.Lockouts++
+	}
+}
+
+// RecordSuccessfulLogin resets the failed logins of the address of the request.
+func RecordSuccessfulLogin(r *http.Request) {
+	attemptsMutex.Lock()
+	defer attemptsMutex.Unlock()
+
+	delete(attempts, clientAddress(r))
+}
+
+// GetLoginThrottleStats returns the counters of failed and rejected logins, e.g. to see ongoing brute-force attacks.
+func GetLoginThrottleStats() LoginThrottleStats {
+	attemptsMutex.Lock()
+	defer attemptsMutex.Unlock()
+
+	stats := attemptsStats
+
+	now := time.Now()
+	for _, a := range attempts {
+		if now.Before(a.lockedUntil) {
+			stats.LockedIps++
+		}
+	}
+
+	return stats
+}
+
+// WriteMetrics writes the counters of "GetLoginThrottleStats" in the text format of Prometheus.
+func WriteMetrics(w io.Writer) error {
+	stats := GetLoginThrottleStats()
+
+	lines := []string{
+		"# HELP stm_login_failures_total Failed logins since the start of the server.",
+		"# TYPE stm_login_failures_total counter",
+		fmt.Sprintf("stm_login_failures_total %d", stats.FailedLogins),
+		"# HELP stm_login_rejections_total Logins rejected due to a delay or lockout since the start of the server.",
+		"# TYPE stm_login_rejections_total counter",
+		fmt.Sprintf("stm_login_rejections_total %d", stats.RejectedLogins),
+		"# HELP stm_login_lockouts_total Lockouts of IP addresses since the start of the server.",
+		"# TYPE stm_login_lockouts_total counter",
+		fmt.Sprintf("stm_login_lockouts_total %d", stats.Lockouts),
+		"# HELP stm_login_locked_ips Number of currently locked IP addresses.",
+		"# TYPE stm_login_locked_ips gauge",
+		fmt.Sprintf("stm_login_locked_ips %d", stats.LockedIps),
+	}
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// loginDelay returns the time the next login has to wait after the given number of failures: 1s, 2s, 4s, ... but never
+// more than the lockout.
+func loginDelay(failures int) time.Duration {
+	delay := loginLockout
+	if failures < 32 {
+		delay = time.Duration(1<<uint(failures-1)) * time.Second
+	}
+
+	if delay > loginLockout {
+		return loginLockout
+	}
+	return delay
+}
+
+// pruneAttempts removes all entries which neither delay nor lock logins anymore. To not iterate over all entries on
+// every failed login, this happens at most once per minute.
+func pruneAttempts(now time.Time) {
+	if now.Sub(lastPrune) < time.Minute {
+		return
+	}
+	lastPrune = now
+
+	for key, a := range attempts {
+		if now.After(a.lockedUntil) && now.After(a.lastFailure.Add(loginDelay(a.failures))) {
+			delete(attempts, key)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	err := setLoginThrottle(3, time.Hour)
+	if err != nil {
+		t.Fatalf("Setting throttle should work: %s", err.Error())
+	}
+	defer setLoginThrottle(0, 0)
+
+	r := httptest.NewRequest(http.MethodPost, "/local_login", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	otherR := httptest.NewRequest(http.MethodPost, "/local_login", nil)
+	otherR.RemoteAddr = "192.0.2.2:1234"
+
+	if CheckLoginAttempt(r) != nil {
+		t.Errorf("First login should be allowed")
+	}
+
+	// The next login is delayed by one second
+	RecordFailedLogin(r)
+	err = CheckLoginAttempt(r)
+	if err == nil || err.(*LoginThrottledError).RetrySeconds() != 1 {
+		t.Errorf("Login should be delayed by one second: %v", err)
+	}
+	if CheckLoginAttempt(otherR) != nil {
+		t.Errorf("Login from other address should be allowed")
+	}
+
+	// The delay doubles with every failure
+	attempts["192.0.2.1"].lastFailure = time.Now().Add(-time.Second)
+	if CheckLoginAttempt(r) != nil {
+		t.Errorf("Login should be allowed after the delay")
+	}
+	RecordFailedLogin(r)
+	err = CheckLoginAttempt(r)
+	if err == nil || err.(*LoginThrottledError).RetrySeconds() != 2 {
+		t.Errorf("Login should be delayed by two seconds: %v", err)
+	}
+
+	// Reaching the maximum locks the address
+	RecordFailedLogin(r)
+	err = CheckLoginAttempt(r)
+	if err == nil || err.(*LoginThrottledError).RetrySeconds() != 3600 {
+		t.Errorf("Login should be locked for one hour: %v", err)
+	}
+
+	stats := GetLoginThrottleStats()
+	if stats.FailedLogins != 3 || stats.Lockouts != 1 || stats.LockedIps != 1 || stats.RejectedLogins != 3 {
+		t.Errorf("Stats not matching: %#v", stats)
+	}
+
+	buffer := &bytes.Buffer{}
+	err = WriteMetrics(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"stm_login_failures_total 3", "stm_login_rejections_total 3", "stm_login_lockouts_total 1", "stm_login_locked_ips 1"} {
+		if !strings.Contains(buffer.String(), line+"\n") {
+			t.Errorf("Metrics should contain line '%s':\n%s", line, buffer.String())
+		}
+	}
+
+	RecordSuccessfulLogin(r)
+	if CheckLoginAttempt(r) != nil {
+		t.Errorf("Login should be allowed after a successful login")
+	}
+}
@@ -16,6 +16,7 @@ type Token struct {
 	ValidUntil int64  `json:"valid_until"`
 	User       string `json:"user"`
 	UID        string `json:"uid"`
+	Session    string `json:"session"` // ID of the session started by the login, see "session.Session"
 	Secret     string `json:"secret"`
 }
 
@@ -29,14 +30,15 @@ func tokenInit() error {
 	return err
 }
 
-func createTokenString(logger *util.Logger, userName string, userId string, validUntil int64) (string, error) {
-	secret := createSecret(userName, userId, validUntil)
+func createTokenString(logger *util.Logger, userName string, userId string, sessionId string, validUntil int64) (string, error) {
+	secret := createSecret(userName, userId, sessionId, validUntil)
 
 	// Create actual token
 	token := &Token{
 		ValidUntil: validUntil,
 		User:       userName,
 		UID:        userId,
+		Session:    sessionId,
 		Secret:     secret,
 	}
 
@@ -52,9 +54,9 @@ func createTokenString(logger *util.Logger, userName string, userId string, vali
 }
 
 // createSecret builds a new secret string encoded as base64. This uses HMAC with SHA-256 inside.
-func createSecret(user string, uid string, expirationTime int64) string {
-	// Create base string "<userName><userId><expirationTime>"
-	secretBaseString := fmt.Sprintf("%s\n%s\n%d\n", user, uid, expirationTime)
+func createSecret(user string, uid string, sessionId string, expirationTime int64) string {
+	// Create base string "<userName><userId><sessionId><expirationTime>"
+	secretBaseString := fmt.Sprintf("%s\n%s\n%s\n%d\n", user, uid, sessionId, expirationTime)
 
 	hash := hmac.New(sha256.New, key)
 	hash.Write([]byte(secretBaseString))
@@ -78,7 +80,7 @@ func verifyToken(logger *util.Logger, encodedToken string) (*Token, error) {
 		return nil, errors.Wrap(err, msg)
 	}
 
-	targetSecret := createSecret(token.User, token.UID, token.ValidUntil)
+	targetSecret := createSecret(token.User, token.UID, token.Session, token.ValidUntil)
 
 	if token.Secret != targetSecret {
 		return nil, errors.New("Secret not valid")
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"strings"
+	"reflect"
 
 	"github.com/hauke96/sigolo"
 )
@@ -15,17 +15,74 @@ var (
 )
 
 type Config struct {
-	ServerUrl             string `json:"server-url"`
-	Port                  int    `json:"port"`
-	SslCertFile           string `json:"ssl-cert-file"`
-	SslKeyFile            string `json:"ssl-key-file"`
+	ServerUrl             string   `json:"server-url"`
+	Port                  int      `json:"port"`
+	SslCertFile           string   `json:"ssl-cert-file"`
+	SslKeyFile            string   `json:"ssl-key-file"`
+	SslAutocertDomains    []string `json:"ssl-autocert-domains"`   // When set, certificates are requested via ACME (Let's Encrypt) instead of using the files above
+	SslAutocertCacheDir   string   `json:"ssl-autocert-cache-dir"` // Folder where the ACME certificates are stored
+	HttpRedirectPort      int      `json:"http-redirect-port"`     // Port on which HTTP requests are redirected to HTTPS, 0 disables the redirect
+	GrpcPort              int      `json:"grpc-port"`              // Port of the gRPC interface, 0 disables it
 	OauthConsumerKey      string
 	OauthSecret           string
 	OsmBaseUrl            string `json:"osm-base-url"`
 	DebugLogging          bool   `json:"debug-logging"`
 	DbUsername            string
 	DbPassword            string
-	TokenValidityDuration string `json:"token-validity"`
+	TokenValidityDuration string              `json:"token-validity"`
+	OauthClients          map[string][]string `json:"oauth-clients"` // Client ID -> allowed redirect URLs after login
+	AuthBackend           string              `json:"auth-backend"`  // "osm" for logins via the OSM server or "local" for accounts created by admins
+	SmtpHost              string              `json:"smtp-host"`
+	SmtpPort              int                 `json:"smtp-port"`
+	SmtpUsername          string
+	SmtpPassword          string
+	MailFrom              string            `json:"mail-from"`
+	OsmRequestTimeout     string            `json:"osm-request-timeout"`    // Timeout for every request to the OSM server
+	OsmRequestRetries     int               `json:"osm-request-retries"`    // Retries of failing requests with exponential backoff
+	OsmCacheTtl           string            `json:"osm-cache-ttl"`          // Time until cached OSM responses get revalidated
+	OsmMaxParallel        int               `json:"osm-max-parallel"`       // Maximum number of requests to the OSM server running at the same time
+	OsmRequestInterval    string            `json:"osm-request-interval"`   // Minimum time between two requests to the OSM server
+	OsmQueueTimeout       string            `json:"osm-queue-timeout"`      // Maximum time a request waits for a free slot
+	OsmBreakerThreshold   int               `json:"osm-breaker-threshold"`  // Consecutive failed requests after which no requests are sent for a while, 0 disables this
+	OsmBreakerCooldown    string            `json:"osm-breaker-cooldown"`   // Time no requests are sent after the threshold has been reached
+	NominatimUrl          string            `json:"nominatim-url"`          // Nominatim server to look up the localities of tasks, empty disables this
+	Admins                []string          `json:"admins"`                 // OSM user IDs of the admins of this instance
+	MaintenanceMode       bool              `json:"maintenance-mode"`       // Initial state of the maintenance mode, admins can change it at runtime
+	MaintenanceMessage    string            `json:"maintenance-message"`    // Message returned to non-admins during maintenance
+	IpAllowList           []string          `json:"ip-allow-list"`          // IPs or networks (CIDR notation) allowed to access the server, empty allows everyone
+	IpDenyList            []string          `json:"ip-deny-list"`           // IPs or networks (CIDR notation) not allowed to access the server
+	DbQueryTimeout        string            `json:"db-query-timeout"`       // Timeout for every single database query
+	SlowQueryThreshold    string            `json:"slow-query-threshold"`   // Database queries taking at least this long are logged, empty disables the logging
+	StoreDriver           string            `json:"store-driver"`           // Driver of the project, task, account, permission and user stores, see package "storage"
+	ArchiveGracePeriod    string            `json:"archive-grace-period"`   // Time after which completed projects get archived, empty disables the archiving
+	StatusInProgress      float64           `json:"status-in-progress"`     // Ratio of done process points above which a project is in progress
+	StatusNearlyDone      float64           `json:"status-nearly-done"`     // Ratio of done process points from which on a project is nearly done
+	FeatureFlags          map[string]bool   `json:"feature-flags"`          // States of feature flags, admins can override them at runtime
+	MaxRequestBodySize    int64             `json:"max-request-body-size"`  // Maximum size of request bodies in bytes
+	QuotaOwnedProjects    int               `json:"quota-owned-projects"`   // Default number of projects a user can own, 0 means no limit
+	QuotaProjectTasks     int               `json:"quota-project-tasks"`    // Default number of tasks per project of a user, 0 means no limit
+	QuotaTotalTasks       int               `json:"quota-total-tasks"`      // Default number of tasks of all projects of a user, 0 means no limit
+	ShareMaxValidity      string            `json:"share-max-validity"`     // Maximum time a share link of a project is valid
+	ExportRetention       string            `json:"export-retention"`       // Time the files of background exports can be downloaded
+	RevertWindow          string            `json:"revert-window"`          // Time in which owners can revert their changes of a project
+	ReservationDuration   string            `json:"reservation-duration"`   // Time a task stays reserved after being opened in an editor
+	RetentionDays         map[string]int    `json:"retention-days"`         // Days after which old rows of a table are aggregated or removed, missing tables are kept forever
+	MaxDescriptionLength  int               `json:"max-description-length"` // Maximum number of characters of project descriptions, 0 means no limit
+	MaxNameLength         int               `json:"max-name-length"`        // Maximum number of characters of project names, 0 means no limit
+	MaxTasksPerProject    int               `json:"max-tasks-per-project"`  // Maximum number of tasks of one project, 0 means no limit
+	MaxUsersPerProject    int               `json:"max-users-per-project"`  // Maximum number of members of one project, 0 means no limit
+	SecurityHeaders       map[string]string `json:"security-headers"`       // Overrides of the security headers added to every response, empty values remove the header
+	LoginMaxFailures      int               `json:"login-max-failures"`     // Failed logins after which the IP address is locked, 0 disables the brute-force protection
+	LoginLockout          string            `json:"login-lockout"`          // Time logins are locked after too many failures
+	RepairInconsistencies bool              `json:"repair-inconsistencies"` // Repair broken references of tasks found by the daily consistency check instead of only logging them
+	AnonymizeUsers        bool              `json:"anonymize-users"`        // Replace user IDs by pseudonyms in public data, statistics and exports, owners still see the real IDs
+	InviteOnly            bool              `json:"invite-only"`            // Only admins and users invited by them can log in via OSM
+	PushSubject           string            `json:"push-subject"`           // Contact ("mailto:" or "https:" URL) sent to push services along with push messages
+	ShareLinkKey          string            // Key to sign share links, a random key (links invalid after restart) is used when empty
+	PseudonymKey          string            // Key the pseudonyms of anonymized users are derived from, a random key (pseudonyms change after restart) is used when empty
+	VapidPrivateKey       string            // Key push messages are signed with, push messages are disabled when empty
+	MetricsToken          string            // Bearer token needed to get the metrics, the metrics are disabled when empty
+	EncryptionKeys        string            // Comma separated keys to encrypt secrets in the database with, the first one encrypts new values
 }
 
 func LoadConfig(file string) {
@@ -38,6 +95,30 @@ func LoadConfig(file string) {
 
 	Conf = &Config{}
 	Conf.TokenValidityDuration = "24h"
+	Conf.AuthBackend = "osm"
+	Conf.SmtpPort = 25
+	Conf.SslAutocertCacheDir = "./certs"
+	Conf.OsmRequestTimeout = "10s"
+	Conf.OsmRequestRetries = 3
+	Conf.OsmCacheTtl = "5m"
+	Conf.OsmMaxParallel = 4
+	Conf.OsmRequestInterval = "100ms"
+	Conf.OsmQueueTimeout = "10s"
+	Conf.OsmBreakerThreshold = 5
+	Conf.OsmBreakerCooldown = "30s"
+	Conf.DbQueryTimeout = "30s"
+	Conf.SlowQueryThreshold = "1s"
+	Conf.StoreDriver = "postgres"
+	Conf.StatusInProgress = 0
+	Conf.StatusNearlyDone = 0.8
+	Conf.MaxRequestBodySize = 16 * 1024 * 1024
+	Conf.ShareMaxValidity = "720h"
+	Conf.ExportRetention = "24h"
+	Conf.RevertWindow = "24h"
+	Conf.ReservationDuration = "15m"
+	Conf.MaxDescriptionLength = 10000
+	Conf.LoginMaxFailures = 5
+	Conf.LoginLockout = "15m"
 
 	err = json.Unmarshal([]byte(fileContent), Conf)
 	if err != nil {
@@ -69,26 +150,51 @@ func LoadConfig(file string) {
 
 	Conf.DbUsername = dbUsername
 	Conf.DbPassword = dbPassword
+
+	// SMTP configs (optional, no mails are sent without a SMTP host)
+	smtpUsername, _ := os.LookupEnv("STM_SMTP_USERNAME")
+	smtpPassword, _ := os.LookupEnv("STM_SMTP_PASSWORD")
+	Conf.SmtpUsername = smtpUsername
+	Conf.SmtpPassword = smtpPassword
+
+	// Key for share links (optional, they become invalid on restart without it)
+	shareLinkKey, _ := os.LookupEnv("STM_SHARE_LINK_KEY")
+	Conf.ShareLinkKey = shareLinkKey
+
+	// Key for pseudonyms (optional, they change on restart without it)
+	pseudonymKey, _ := os.LookupEnv("STM_PSEUDONYM_KEY")
+	Conf.PseudonymKey = pseudonymKey
+
+	// Key for push messages (optional, no push messages are sent without it)
+	vapidPrivateKey, _ := os.LookupEnv("STM_VAPID_PRIVATE_KEY")
+	Conf.VapidPrivateKey = vapidPrivateKey
+
+	// Token for the metrics (optional, the metrics are not available without it)
+	metricsToken, _ := os.LookupEnv("STM_METRICS_TOKEN")
+	Conf.MetricsToken = metricsToken
+
+	// Keys to encrypt secrets in the database (optional, but needed for push messages)
+	encryptionKeys, _ := os.LookupEnv("STM_ENCRYPTION_KEYS")
+	Conf.EncryptionKeys = encryptionKeys
 }
 
 func PrintConfig() {
-	// Parse config struct to print it:
-	wholeConfStr := fmt.Sprintf("%#v", Conf)                      // -> "main.Config{Serve...}"
-	splitConfStr := strings.Split(wholeConfStr, "{")              // --> "main.Config" and "Serve...}"
-	propertyString := splitConfStr[1][0 : len(splitConfStr[1])-1] // cut last "}" off
-	propertyList := strings.Split(propertyString, ", ")
+	// Go through the config struct via reflection to print it (string-splitting the "%#v" output doesn't work for
+	// properties like maps or slices):
+	confValue := reflect.ValueOf(Conf).Elem()
+	confType := confValue.Type()
 
 	sigolo.Info("Config:")
-	for _, p := range propertyList {
-		propertyName := strings.Split(p, ":")[0]
+	for i := 0; i < confType.NumField(); i++ {
+		propertyName := confType.Field(i).Name
 
 		var propertyValue string
-		if propertyName == "DbPassword" || propertyName == "OauthSecret" {
+		if propertyName == "DbPassword" || propertyName == "OauthSecret" || propertyName == "SmtpPassword" || propertyName == "ShareLinkKey" || propertyName == "PseudonymKey" || propertyName == "VapidPrivateKey" || propertyName == "MetricsToken" || propertyName == "EncryptionKeys" {
 			propertyValue = "******" // don't show passwords etc. in the logs
 		} else {
-			propertyValue = strings.Join(strings.Split(p, ":")[1:], ":") // Join remaining parts back together
+			propertyValue = fmt.Sprintf("%#v", confValue.Field(i).Interface())
 		}
-		
+
 		sigolo.Info("  %-*s = %s", 21, propertyName, propertyValue)
 	}
 }
@@ -1,21 +1,50 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"github.com/hauke96/sigolo"
 	"github.com/hauke96/simple-task-manager/server/config"
 	"github.com/hauke96/simple-task-manager/server/util"
 	_ "github.com/lib/pq"
 	"github.com/pkg/errors"
+	"time"
 )
 
 var (
-	db *sql.DB
+	db                 *sql.DB
+	queryTimeout       time.Duration
+	slowQueryThreshold time.Duration
 )
 
+// Init parses the database related entries of the config.
+func Init() {
+	var err error
+	queryTimeout, err = time.ParseDuration(config.Conf.DbQueryTimeout)
+	sigolo.FatalCheckf(err, "unable to parse database query timeout from config entry '%s'", config.Conf.DbQueryTimeout)
+
+	if config.Conf.SlowQueryThreshold != "" {
+		slowQueryThreshold, err = time.ParseDuration(config.Conf.SlowQueryThreshold)
+		sigolo.FatalCheckf(err, "unable to parse slow query threshold from config entry '%s'", config.Conf.SlowQueryThreshold)
+	}
+}
+
 // GetTransaction tries to open to the database and creates a transaction. Only if the initial connection succeeds,
-// a reconnect loop starts.
-func GetTransaction(logger *util.Logger) (*sql.Tx, error) {
+// a reconnect loop starts. The transaction is rolled back when the given context is cancelled (e.g. because the client
+// disconnected).
+func GetTransaction(ctx context.Context, logger *util.Logger) (*sql.Tx, error) {
+	conn, err := GetConnection(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.BeginTx(ctx, nil)
+}
+
+// GetConnection works like "GetTransaction" but returns the connection itself. This is meant for single read-only
+// queries, which don't need a transaction.
+func GetConnection(logger *util.Logger) (*sql.DB, error) {
 	if db == nil { // No database connection at all
 		err := open()
 		if err != nil {
@@ -35,7 +64,38 @@ func GetTransaction(logger *util.Logger) (*sql.Tx, error) {
 		logger.Log("Successfully created new database connection")
 	}
 
-	return db.Begin()
+	return db, nil
+}
+
+// QueryContext derives the context for a single query from the given context. The query is cancelled when the given
+// context is cancelled or when the configured query timeout is reached. The returned cancel function must be called
+// after the result of the query has been read. It also records the duration of the query (including reading the
+// result) for the metrics and logs the query when it was slow.
+func QueryContext(ctx context.Context, logger *util.Logger, query string) (context.Context, context.CancelFunc) {
+	return queryContext(ctx, logger, query, queryTimeout)
+}
+
+// StreamingQueryContext works like "QueryContext" but without the configured query timeout. This is meant for queries
+// whose result is streamed to the client while reading it (e.g. exports of large logs), which might take longer than
+// the timeout. The query is still cancelled when the given context is cancelled, e.g. because the client disconnected.
+func StreamingQueryContext(ctx context.Context, logger *util.Logger, query string) (context.Context, context.CancelFunc) {
+	return queryContext(ctx, logger, query, 0)
+}
+
+func queryContext(ctx context.Context, logger *util.Logger, query string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	var queryCtx context.Context
+	var cancel context.CancelFunc
+	if timeout <= 0 {
+		queryCtx, cancel = context.WithCancel(ctx)
+	} else {
+		queryCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	start := time.Now()
+	return queryCtx, func() {
+		cancel()
+		observeQuery(logger, query, time.Since(start))
+	}
 }
 
 // open tries to open to the database and performs a simple health-check by using the "Ping" function on the database.
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/util"
+)
+
+// Upper bounds (in seconds) of the buckets of the query duration histograms
+var queryDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type queryHistogram struct {
+	bucketCounts []uint64 // Counts per bucket of "queryDurationBuckets", not cumulative
+	count        uint64
+	sum          float64 // Seconds
+}
+
+var (
+	// Histograms of all statements executed since the start of the server: statement label -> histogram
+	queryHistograms      = make(map[string]*queryHistogram)
+	queryHistogramsMutex = &sync.Mutex{}
+)
+
+// observeQuery adds the duration of the query to the histogram of its statement and logs the query when it took longer
+// than the configured threshold. The parameters are not logged, since they might contain personal data.
+func observeQuery(logger *util.Logger, query string, duration time.Duration) {
+	label := statementLabel(query)
+	seconds := duration.Seconds()
+
+	queryHistogramsMutex.Lock()
+	histogram, ok := queryHistograms[label]
+	if !ok {
+		histogram = &queryHistogram{bucketCounts: make([]uint64, len(queryDurationBuckets))}
+		queryHistograms[label] = histogram
+	}
+	for i, bound := range queryDurationBuckets {
+		if seconds <= bound {
+			histogram.bucketCounts[i]++
+			break
+		}
+	}
+	histogram.count++
+	histogram.sum += seconds
+	queryHistogramsMutex.Unlock()
+
+	if slowQueryThreshold > 0 && duration >= slowQueryThreshold {
+		logger.Err("Slow query '%s' took %dms (parameters redacted): %s", label, duration.Milliseconds(), strings.Join(strings.Fields(query), " "))
+	}
+}
+
+// statementLabel returns the type of the statement and the first table it uses, e.g. "select tasks" or "update
+// projects". Parameters and the rest of the query are ignored, so that all executions of a statement share one label.
+func statementLabel(query string) string {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return "unknown"
+	}
+
+	statement := words[0]
+	for i, word := range words {
+		isTableKeyword := word == "from" || word == "into" || (i == 0 && word == "update")
+		if !isTableKeyword || i+1 >= len(words) || strings.HasPrefix(words[i+1], "(") {
+			continue
+		}
+
+		table := words[i+1]
+		if end := strings.IndexAny(table, "(;,)"); end != -1 {
+			table = table[:end]
+		}
+		table = strings.Trim(table, "\"`")
+		if table != "" {
+			return statement + " " + table
+		}
+	}
+
+	return statement
+}
+
+// WriteMetrics writes the query duration histograms in the text format of Prometheus.
+func WriteMetrics(w io.Writer) error {
+	queryHistogramsMutex.Lock()
+	defer queryHistogramsMutex.Unlock()
+
+	labels := make([]string, 0, len(queryHistograms))
+	for label := range queryHistograms {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	lines := []string{
+		"# HELP stm_db_query_duration_seconds Duration of database queries including reading the result.",
+		"# TYPE stm_db_query_duration_seconds histogram",
+	}
+	for _, label := range labels {
+		histogram := queryHistograms[label]
+
+		var cumulativeCount uint64
+		for i, bound := range queryDurationBuckets {
+			cumulativeCount += histogram.bucketCounts[i]
+			lines = append(lines, fmt.Sprintf("stm_db_query_duration_seconds_bucket{statement=%q,le=\"%g\"} %d", label, bound, cumulativeCount))
+		}
+
+		lines = append(lines,
+			fmt.Sprintf("stm_db_query_duration_seconds_bucket{statement=%q,le=\"+Inf\"} %d", label, histogram.count),
+			fmt.Sprintf("stm_db_query_duration_seconds_sum{statement=%q} %g", label, histogram.sum),
+			fmt.Sprintf("stm_db_query_duration_seconds_count{statement=%q} %d", label, histogram.count),
+		)
+	}
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
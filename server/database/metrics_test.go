@@ -0,0 +1,71 @@
+package database
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/util"
+)
+
+func TestStatementLabel(t *testing.T) {
+	labels := map[string]string{
+		"SELECT * FROM tasks WHERE id = $1;":                                         "select tasks",
+		"UPDATE projects SET name=$1 WHERE id=$2 RETURNING *;":                       "update projects",
+		"INSERT INTO task_history(task_id, user_id) VALUES($1, $2);":                 "insert task_history",
+		"DELETE FROM notifications WHERE id=$1;":                                     "delete notifications",
+		"SELECT COUNT(*) FROM (SELECT DISTINCT l.user_id FROM changesets l) c;":      "select changesets",
+		"\n\tWITH t AS (SELECT id FROM tasks)\n\tUPDATE projects SET done=0 FROM t;": "with tasks",
+		"BEGIN":  "begin",
+		"   \n ": "unknown",
+	}
+
+	for query, expectedLabel := range labels {
+		label := statementLabel(query)
+		if label != expectedLabel {
+			t.Errorf("Label of query '%s' should be '%s' but was '%s'", query, expectedLabel, label)
+		}
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	queryHistogramsMutex.Lock()
+	queryHistograms = make(map[string]*queryHistogram)
+	queryHistogramsMutex.Unlock()
+
+	logger := util.NewLogger()
+	observeQuery(logger, "SELECT * FROM tasks WHERE id=$1;", 3*time.Millisecond)
+	observeQuery(logger, "SELECT * FROM tasks WHERE project_id=$1;", 200*time.Millisecond)
+	observeQuery(logger, "DELETE FROM tasks WHERE id=$1;", 20*time.Second)
+
+	buffer := &bytes.Buffer{}
+	err := WriteMetrics(buffer)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	metrics := buffer.String()
+
+	expectedLines := []string{
+		"# TYPE stm_db_query_duration_seconds histogram",
+		`stm_db_query_duration_seconds_bucket{statement="select tasks",le="0.001"} 0`,
+		`stm_db_query_duration_seconds_bucket{statement="select tasks",le="0.005"} 1`,
+		`stm_db_query_duration_seconds_bucket{statement="select tasks",le="0.25"} 2`,
+		`stm_db_query_duration_seconds_bucket{statement="select tasks",le="+Inf"} 2`,
+		`stm_db_query_duration_seconds_count{statement="select tasks"} 2`,
+		`stm_db_query_duration_seconds_sum{statement="select tasks"} 0.203`,
+		`stm_db_query_duration_seconds_bucket{statement="delete tasks",le="10"} 0`,
+		`stm_db_query_duration_seconds_bucket{statement="delete tasks",le="+Inf"} 1`,
+		`stm_db_query_duration_seconds_count{statement="delete tasks"} 1`,
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("Metrics should contain line '%s':\n%s", line, metrics)
+		}
+	}
+
+	if strings.Index(metrics, `statement="delete tasks"`) > strings.Index(metrics, `statement="select tasks"`) {
+		t.Errorf("Statements should be ordered alphabetically:\n%s", metrics)
+	}
+}
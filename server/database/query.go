@@ -0,0 +1,79 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Table is the name of a database table. Queries only interpolate these constants into their text, everything else
+// (especially user input) has to be passed as query parameter. A plain string can't be used where a Table is expected
+// without an explicit conversion, which makes mistakes obvious in reviews.
+type Table string
+
+// All tables of the database, see the "scripts" folder for their columns
+const (
+	TableApiUsage            Table = "api_usage"
+	TableAuditLog            Table = "audit_log"
+	TableDigestSubscriptions Table = "digest_subscriptions"
+	TableExportJobs          Table = "export_jobs"
+	TableFeatureFlags        Table = "feature_flags"
+	TableInvitations         Table = "invitations"
+	TableJoinRequests        Table = "join_requests"
+	TableLocalAccounts       Table = "local_accounts"
+	TableMergeRequests       Table = "merge_requests"
+	TableNotifications       Table = "notifications"
+	TableOutbox              Table = "outbox"
+	TableProjectCommands     Table = "project_commands"
+	TableProjectRedirects    Table = "project_redirects"
+	TableProjectSnapshots    Table = "project_snapshots"
+	TableProjectWatchers     Table = "project_watchers"
+	TableProjects            Table = "projects"
+	TablePushSubscriptions   Table = "push_subscriptions"
+	TableRetentionStats      Table = "retention_stats"
+	TableSessions            Table = "sessions"
+	TableTaskChangesets      Table = "task_changesets"
+	TableTaskHandovers       Table = "task_handovers"
+	TableTaskHistory         Table = "task_history"
+	TableTaskNotes           Table = "task_notes"
+	TableTaskOsmNotes        Table = "task_osm_notes"
+	TableTaskReservations    Table = "task_reservations"
+	TableTasks               Table = "tasks"
+	TableUserQuotas          Table = "user_quotas"
+)
+
+// Columns are the columns (or SQL expressions) of a query result together with the destinations they're scanned into.
+// Keeping both in one list ensures that the selected columns and the scanned values can't get out of order, so adding a
+// column is a change in one place.
+type Columns struct {
+	expressions  []string
+	destinations []interface{}
+}
+
+// Add appends the column and returns the columns, so that calls can be chained.
+func (c *Columns) Add(expression string, destination interface{}) *Columns {
+	c.expressions = append(c.expressions, expression)
+	c.destinations = append(c.destinations, destination)
+	return c
+}
+
+// SelectList returns the expressions of the columns as comma separated list, e.g. for SELECT or RETURNING clauses.
+func (c *Columns) SelectList() string {
+	return strings.Join(c.expressions, ", ")
+}
+
+// Destinations returns the destinations of the columns followed by the additional ones, e.g. for "Rows.Scan".
+func (c *Columns) Destinations(additional ...interface{}) []interface{} {
+	destinations := make([]interface{}, 0, len(c.destinations)+len(additional))
+	destinations = append(destinations, c.destinations...)
+	return append(destinations, additional...)
+}
+
+// InsertQuery returns an INSERT statement for the columns with one parameter per column ("$1", "$2", ...) without
+// trailing semicolon, so that e.g. a RETURNING clause can be appended.
+func InsertQuery(table Table, columns ...string) string {
+	params := make([]string, len(columns))
+	for i := range columns {
+		params[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return fmt.Sprintf("INSERT INTO %s(%s) VALUES(%s)", table, strings.Join(columns, ", "), strings.Join(params, ", "))
+}
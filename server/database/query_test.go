@@ -0,0 +1,37 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestColumns(t *testing.T) {
+	var id int
+	var name string
+	var extra bool
+
+	columns := new(Columns).
+		Add("id", &id).
+		Add("COALESCE(name, '')", &name)
+
+	selectList := columns.SelectList()
+	if selectList != "id, COALESCE(name, '')" {
+		t.Errorf("Unexpected select list '%s'", selectList)
+	}
+
+	destinations := columns.Destinations(&extra)
+	if len(destinations) != 3 || destinations[0] != &id || destinations[1] != &name || destinations[2] != &extra {
+		t.Errorf("Destinations should be the ones of the columns followed by the additional one: %v", destinations)
+	}
+
+	// Additional destinations must not be added to the columns themselves
+	if len(columns.Destinations()) != 2 {
+		t.Errorf("Columns should still have 2 destinations but had %d", len(columns.Destinations()))
+	}
+}
+
+func TestInsertQuery(t *testing.T) {
+	query := InsertQuery(TableTasks, "process_points", "geometry", "project_id")
+	if query != "INSERT INTO tasks(process_points, geometry, project_id) VALUES($1, $2, $3)" {
+		t.Errorf("Unexpected query '%s'", query)
+	}
+}
@@ -0,0 +1,14 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE digest_subscriptions(
+    project_id      INT         NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id         TEXT        NOT NULL,
+    email           TEXT        NOT NULL,
+    digest_interval TEXT        NOT NULL,
+    last_sent       TIMESTAMP,
+    PRIMARY KEY (project_id, user_id)
+);
+
+INSERT INTO db_versions VALUES('010');
+
+END TRANSACTION;
@@ -0,0 +1,41 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE tasks ADD COLUMN bbox DOUBLE PRECISION[];
+ALTER TABLE tasks ADD COLUMN centroid DOUBLE PRECISION[];
+
+-- Compute bounding box and centroid of all existing tasks based on the outer ring of their polygon. This is the same
+-- computation as the server does when adding new tasks.
+WITH points AS (
+    SELECT t.id, p.i,
+           (p.c->>0)::DOUBLE PRECISION AS x,
+           (p.c->>1)::DOUBLE PRECISION AS y
+    FROM tasks t, jsonb_array_elements(t.geometry::jsonb->'geometry'->'coordinates'->0) WITH ORDINALITY AS p(c, i)
+),
+edges AS (
+    SELECT id, x, y,
+           LEAD(x) OVER (PARTITION BY id ORDER BY i) AS next_x,
+           LEAD(y) OVER (PARTITION BY id ORDER BY i) AS next_y
+    FROM points
+),
+aggregated AS (
+    SELECT id,
+           MIN(x) AS min_x, MIN(y) AS min_y, MAX(x) AS max_x, MAX(y) AS max_y,
+           AVG(x) AS avg_x, AVG(y) AS avg_y,
+           SUM(x * next_y - next_x * y) / 2 AS area,
+           SUM((x + next_x) * (x * next_y - next_x * y)) AS cx,
+           SUM((y + next_y) * (x * next_y - next_x * y)) AS cy
+    FROM edges
+    GROUP BY id
+)
+UPDATE tasks SET
+    bbox = ARRAY[a.min_x, a.min_y, a.max_x, a.max_y],
+    centroid = CASE
+        WHEN a.area = 0 THEN ARRAY[a.avg_x, a.avg_y]
+        ELSE ARRAY[a.cx / (6 * a.area), a.cy / (6 * a.area)]
+    END
+FROM aggregated a
+WHERE tasks.id = a.id;
+
+INSERT INTO db_versions VALUES('011');
+
+END TRANSACTION;
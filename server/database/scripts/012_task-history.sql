@@ -0,0 +1,18 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE task_history(
+    id              SERIAL PRIMARY KEY  NOT NULL,
+    task_id         INT                 NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id         TEXT                NOT NULL,
+    type            TEXT                NOT NULL,
+    process_points  INT                 NOT NULL,
+    points_delta    INT                 NOT NULL DEFAULT 0,
+    created_at      TIMESTAMP           NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX task_history_user_id_idx ON task_history(user_id);
+CREATE INDEX task_history_task_id_idx ON task_history(task_id);
+
+INSERT INTO db_versions VALUES('012');
+
+END TRANSACTION;
@@ -0,0 +1,13 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE project_snapshots(
+    project_id              INT     NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    date                    DATE    NOT NULL,
+    done_process_points     INT     NOT NULL,
+    total_process_points    INT     NOT NULL,
+    PRIMARY KEY (project_id, date)
+);
+
+INSERT INTO db_versions VALUES('013');
+
+END TRANSACTION;
@@ -0,0 +1,12 @@
+BEGIN TRANSACTION;
+
+-- The "project_id" of a task is the only place where the relation between projects and tasks is stored. Tasks without
+-- project can't be used anyway (see also script 007).
+DELETE FROM tasks WHERE project_id IS NULL;
+ALTER TABLE tasks ALTER COLUMN project_id SET NOT NULL;
+
+CREATE INDEX tasks_project_id_idx ON tasks(project_id);
+
+INSERT INTO db_versions VALUES('014');
+
+END TRANSACTION;
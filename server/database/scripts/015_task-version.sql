@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+-- Increased with every change of a task, used to detect conflicts of changes made by offline clients
+ALTER TABLE tasks ADD COLUMN version INT NOT NULL DEFAULT 0;
+
+INSERT INTO db_versions VALUES('015');
+
+END TRANSACTION;
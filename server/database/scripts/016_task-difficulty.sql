@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE projects ADD COLUMN default_difficulty TEXT NOT NULL DEFAULT 'medium';
+ALTER TABLE tasks ADD COLUMN difficulty TEXT NOT NULL DEFAULT 'medium';
+
+INSERT INTO db_versions VALUES('016');
+
+END TRANSACTION;
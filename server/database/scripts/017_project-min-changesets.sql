@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+-- Minimum number of changesets a user must have uploaded to get a task of the project assigned
+ALTER TABLE projects ADD COLUMN min_changesets INT NOT NULL DEFAULT 0;
+
+INSERT INTO db_versions VALUES('017');
+
+END TRANSACTION;
@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+
+-- Time when all process points of the project have been reached, NULL while the project is not completed
+ALTER TABLE projects ADD COLUMN completed_at TIMESTAMP;
+-- Archived projects can't be changed anymore
+ALTER TABLE projects ADD COLUMN archived BOOLEAN NOT NULL DEFAULT false;
+
+INSERT INTO db_versions VALUES('018');
+
+END TRANSACTION;
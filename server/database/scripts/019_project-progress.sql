@@ -0,0 +1,13 @@
+BEGIN TRANSACTION;
+
+-- Sums of the process points of all tasks, updated whenever tasks change so that they don't need to be computed on every request
+ALTER TABLE projects ADD COLUMN done_process_points INT NOT NULL DEFAULT 0;
+ALTER TABLE projects ADD COLUMN total_process_points INT NOT NULL DEFAULT 0;
+
+UPDATE projects p SET
+	done_process_points = (SELECT COALESCE(SUM(t.process_points), 0) FROM tasks t WHERE t.project_id = p.id),
+	total_process_points = (SELECT COALESCE(SUM(t.max_process_points), 0) FROM tasks t WHERE t.project_id = p.id);
+
+INSERT INTO db_versions VALUES('019');
+
+END TRANSACTION;
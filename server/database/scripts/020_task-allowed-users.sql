@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+-- Only these users are allowed to work on the task, an empty array allows all members of the project
+ALTER TABLE tasks ADD COLUMN allowed_users TEXT[] NOT NULL DEFAULT '{}';
+
+INSERT INTO db_versions VALUES('020');
+
+END TRANSACTION;
@@ -0,0 +1,13 @@
+BEGIN TRANSACTION;
+
+-- Requests of non-members to join a project, removed when the owner approved or denied them
+CREATE TABLE join_requests(
+    project_id INT       NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id    TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (project_id, user_id)
+);
+
+INSERT INTO db_versions VALUES('021');
+
+END TRANSACTION;
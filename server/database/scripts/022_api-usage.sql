@@ -0,0 +1,14 @@
+BEGIN TRANSACTION;
+
+-- Number of API calls per user and day
+CREATE TABLE api_usage(
+    user_id       TEXT      NOT NULL,
+    date          DATE      NOT NULL,
+    calls         INT       NOT NULL,
+    last_activity TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, date)
+);
+
+INSERT INTO db_versions VALUES('022');
+
+END TRANSACTION;
@@ -0,0 +1,9 @@
+BEGIN TRANSACTION;
+
+-- Language of the project description and translations of the description (locale -> text)
+ALTER TABLE projects ADD COLUMN locale TEXT NOT NULL DEFAULT '';
+ALTER TABLE projects ADD COLUMN descriptions JSONB NOT NULL DEFAULT '{}';
+
+INSERT INTO db_versions VALUES('023');
+
+END TRANSACTION;
@@ -0,0 +1,11 @@
+BEGIN TRANSACTION;
+
+-- Tasks mappers couldn't complete (e.g. due to bad imagery), flagged until the owner resolves the flag
+ALTER TABLE tasks ADD COLUMN flag_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN flag_comment TEXT NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN flagged_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN flagged_at TIMESTAMP;
+
+INSERT INTO db_versions VALUES('024');
+
+END TRANSACTION;
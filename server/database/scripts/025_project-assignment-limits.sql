@@ -0,0 +1,9 @@
+BEGIN TRANSACTION;
+
+-- Limits per user to spread the tasks across all participants, 0 means no limit
+ALTER TABLE projects ADD COLUMN max_assigned_tasks INT NOT NULL DEFAULT 0;
+ALTER TABLE projects ADD COLUMN max_completions_per_day INT NOT NULL DEFAULT 0;
+
+INSERT INTO db_versions VALUES('025');
+
+END TRANSACTION;
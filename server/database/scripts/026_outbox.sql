@@ -0,0 +1,21 @@
+BEGIN TRANSACTION;
+
+-- Messages (e.g. mails) written in the same transaction as the change causing them and delivered afterwards by a job
+CREATE TABLE outbox(
+    id            SERIAL PRIMARY KEY  NOT NULL,
+    type          TEXT                NOT NULL,
+    recipient     TEXT                NOT NULL,
+    subject       TEXT                NOT NULL,
+    body          TEXT                NOT NULL,
+    attempts      INT                 NOT NULL DEFAULT 0,
+    next_attempt  TIMESTAMP           NOT NULL DEFAULT NOW(),
+    last_error    TEXT                NOT NULL DEFAULT '',
+    created_at    TIMESTAMP           NOT NULL DEFAULT NOW(),
+    sent_at       TIMESTAMP
+);
+
+CREATE INDEX outbox_pending_idx ON outbox(next_attempt) WHERE sent_at IS NULL;
+
+INSERT INTO db_versions VALUES('026');
+
+END TRANSACTION;
@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+-- GeoJSON geometry types allowed for the tasks of the project
+ALTER TABLE projects ADD COLUMN geometry_types TEXT[] NOT NULL DEFAULT '{Polygon}';
+
+INSERT INTO db_versions VALUES('027');
+
+END TRANSACTION;
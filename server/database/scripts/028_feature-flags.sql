@@ -0,0 +1,13 @@
+BEGIN TRANSACTION;
+
+-- Feature flags set by admins at runtime, they take precedence over the config
+CREATE TABLE feature_flags(
+    name        TEXT PRIMARY KEY  NOT NULL,
+    enabled     BOOLEAN           NOT NULL,
+    updated_by  TEXT              NOT NULL,
+    updated_at  TIMESTAMP         NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO db_versions VALUES('028');
+
+END TRANSACTION;
@@ -0,0 +1,20 @@
+BEGIN TRANSACTION;
+
+-- Inbox of the users, e.g. for being added to or removed from a project. The project is no foreign key, so
+-- notifications stay when the project is deleted.
+CREATE TABLE notifications(
+    id            SERIAL PRIMARY KEY  NOT NULL,
+    user_id       TEXT                NOT NULL,
+    type          TEXT                NOT NULL,
+    project_id    INT                 NOT NULL,
+    project_name  TEXT                NOT NULL,
+    actor         TEXT                NOT NULL,
+    created_at    TIMESTAMP           NOT NULL DEFAULT NOW(),
+    read_at       TIMESTAMP
+);
+
+CREATE INDEX notifications_user_idx ON notifications(user_id, created_at);
+
+INSERT INTO db_versions VALUES('029');
+
+END TRANSACTION;
@@ -0,0 +1,14 @@
+BEGIN TRANSACTION;
+
+-- Creation and modification time of projects and tasks, existing entries get the time of this migration
+ALTER TABLE projects ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE projects ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE tasks ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE tasks ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+CREATE INDEX projects_updated_at_idx ON projects(updated_at);
+CREATE INDEX tasks_project_id_updated_at_idx ON tasks(project_id, updated_at);
+
+INSERT INTO db_versions VALUES('030');
+
+END TRANSACTION;
@@ -0,0 +1,15 @@
+BEGIN TRANSACTION;
+
+-- Quotas set by admins for single users, they take precedence over the default quotas from the config
+CREATE TABLE user_quotas(
+    user_id               TEXT PRIMARY KEY  NOT NULL,
+    max_owned_projects    INTEGER           NOT NULL,
+    max_tasks_per_project INTEGER           NOT NULL,
+    max_total_tasks       INTEGER           NOT NULL,
+    updated_by            TEXT              NOT NULL,
+    updated_at            TIMESTAMP         NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO db_versions VALUES('031');
+
+END TRANSACTION;
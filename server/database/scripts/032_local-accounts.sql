@@ -0,0 +1,13 @@
+BEGIN TRANSACTION;
+
+-- Accounts of the "local" auth backend, created by admins for instances without OSM logins
+CREATE TABLE local_accounts(
+    id         TEXT PRIMARY KEY  NOT NULL,
+    key_hash   TEXT UNIQUE       NOT NULL,
+    created_by TEXT              NOT NULL,
+    created_at TIMESTAMP         NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO db_versions VALUES('032');
+
+END TRANSACTION;
@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+-- IDs of tasks of the same project, which have to be completed before the task can be assigned
+ALTER TABLE tasks ADD COLUMN depends_on INTEGER[] NOT NULL DEFAULT '{}';
+
+INSERT INTO db_versions VALUES('033');
+
+END TRANSACTION;
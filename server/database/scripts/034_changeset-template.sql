@@ -0,0 +1,9 @@
+BEGIN TRANSACTION;
+
+-- Template for the changesets of the tasks, "{taskId}" and "{projectId}" get replaced
+ALTER TABLE projects ADD COLUMN changeset_comment TEXT NOT NULL DEFAULT '';
+ALTER TABLE projects ADD COLUMN changeset_hashtags TEXT[] NOT NULL DEFAULT '{}';
+
+INSERT INTO db_versions VALUES('034');
+
+END TRANSACTION;
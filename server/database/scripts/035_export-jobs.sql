@@ -0,0 +1,21 @@
+BEGIN TRANSACTION;
+
+-- Exports built in the background, the file is removed together with the job when it expired
+CREATE TABLE export_jobs(
+    id          SERIAL PRIMARY KEY NOT NULL,
+    project_id  INT                NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    format      TEXT               NOT NULL,
+    status      TEXT               NOT NULL DEFAULT 'pending',
+    error       TEXT               NOT NULL DEFAULT '',
+    data        BYTEA,
+    created_by  TEXT               NOT NULL,
+    created_at  TIMESTAMP          NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP,
+    expires_at  TIMESTAMP
+);
+
+CREATE INDEX export_jobs_status_idx ON export_jobs(status);
+
+INSERT INTO db_versions VALUES('035');
+
+END TRANSACTION;
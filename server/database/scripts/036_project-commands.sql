@@ -0,0 +1,19 @@
+BEGIN TRANSACTION;
+
+-- Changes of owners, which can be reverted within a time window. The data contains everything needed to revert them.
+CREATE TABLE project_commands(
+    id          SERIAL PRIMARY KEY NOT NULL,
+    project_id  INT                NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id     TEXT               NOT NULL,
+    type        TEXT               NOT NULL,
+    data        JSONB              NOT NULL DEFAULT '{}',
+    created_at  TIMESTAMP          NOT NULL DEFAULT NOW(),
+    reverted_at TIMESTAMP,
+    reverted_by TEXT               NOT NULL DEFAULT ''
+);
+
+CREATE INDEX project_commands_project_id_idx ON project_commands(project_id);
+
+INSERT INTO db_versions VALUES('036');
+
+END TRANSACTION;
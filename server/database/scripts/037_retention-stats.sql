@@ -0,0 +1,13 @@
+BEGIN TRANSACTION;
+
+-- Number of rows removed by the retention policies per table
+CREATE TABLE retention_stats(
+    table_name   TEXT PRIMARY KEY NOT NULL,
+    last_run_at  TIMESTAMP        NOT NULL,
+    last_purged  INT              NOT NULL,
+    total_purged BIGINT           NOT NULL
+);
+
+INSERT INTO db_versions VALUES('037');
+
+END TRANSACTION;
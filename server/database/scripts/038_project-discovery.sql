@@ -0,0 +1,11 @@
+BEGIN TRANSACTION;
+
+-- Distances between projects and the location of a user are computed by PostGIS
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+-- Public projects can be found by users who are not a member yet
+ALTER TABLE projects ADD COLUMN public BOOLEAN NOT NULL DEFAULT false;
+
+INSERT INTO db_versions VALUES('038');
+
+END TRANSACTION;
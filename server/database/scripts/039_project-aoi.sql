@@ -0,0 +1,14 @@
+BEGIN TRANSACTION;
+
+-- Area of interest of the project. Unless supplied by the owner, this is the union of all task geometries.
+ALTER TABLE projects ADD COLUMN aoi GEOMETRY(Geometry, 4326);
+ALTER TABLE projects ADD COLUMN aoi_supplied BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE projects p SET
+    aoi = (SELECT ST_Union(ST_MakeValid(ST_SetSRID(ST_GeomFromGeoJSON(t.geometry::JSON->>'geometry'), 4326))) FROM tasks t WHERE t.project_id = p.id);
+
+CREATE INDEX projects_aoi_idx ON projects USING GIST((aoi::GEOGRAPHY));
+
+INSERT INTO db_versions VALUES('039');
+
+END TRANSACTION;
@@ -0,0 +1,22 @@
+BEGIN TRANSACTION;
+
+-- Requests of an owner to merge the source project into the target project, removed when the owner of the other project
+-- approved or denied them
+CREATE TABLE merge_requests(
+    source_project_id INT       NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    target_project_id INT       NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    requested_by      TEXT      NOT NULL,
+    created_at        TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (source_project_id, target_project_id)
+);
+
+-- Merged (and therefore archived) projects pointing to the project their tasks and members have been moved to
+CREATE TABLE project_redirects(
+    source_project_id INT       PRIMARY KEY NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    target_project_id INT       NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    merged_at         TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO db_versions VALUES('040');
+
+END TRANSACTION;
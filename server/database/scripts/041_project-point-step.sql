@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+-- Process points of the tasks can only be set to multiples of this step (or the maximum), 0 allows all values
+ALTER TABLE projects ADD COLUMN point_step INT NOT NULL DEFAULT 0;
+
+INSERT INTO db_versions VALUES('041');
+
+END TRANSACTION;
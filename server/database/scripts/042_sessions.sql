@@ -0,0 +1,19 @@
+BEGIN TRANSACTION;
+
+-- Every login creates a session, which is referenced by the token. Revoked or expired sessions are kept as login audit.
+CREATE TABLE sessions(
+    id           SERIAL PRIMARY KEY NOT NULL,
+    user_id      TEXT               NOT NULL,
+    ip           TEXT               NOT NULL DEFAULT '',
+    user_agent   TEXT               NOT NULL DEFAULT '',
+    created_at   TIMESTAMP          NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP          NOT NULL DEFAULT NOW(),
+    valid_until  TIMESTAMP          NOT NULL,
+    revoked_at   TIMESTAMP
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions(user_id);
+
+INSERT INTO db_versions VALUES('042');
+
+END TRANSACTION;
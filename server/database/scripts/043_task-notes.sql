@@ -0,0 +1,14 @@
+BEGIN TRANSACTION;
+
+-- Private notes of the project owner on tasks. They're kept apart from the tasks, so that they're never part of the
+-- tasks returned to members.
+CREATE TABLE task_notes(
+    task_id    INT       PRIMARY KEY NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    text       TEXT      NOT NULL,
+    updated_by TEXT      NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO db_versions VALUES('043');
+
+END TRANSACTION;
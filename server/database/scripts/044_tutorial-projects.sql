@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+-- Tutorial projects are sandboxes for new users and not part of any statistics
+ALTER TABLE projects ADD COLUMN tutorial BOOLEAN NOT NULL DEFAULT false;
+
+INSERT INTO db_versions VALUES('044');
+
+END TRANSACTION;
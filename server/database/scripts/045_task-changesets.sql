@@ -0,0 +1,20 @@
+BEGIN TRANSACTION;
+
+-- Changesets uploaded for tasks. The user and number of changes are loaded from the OSM API afterwards and stay NULL
+-- until then.
+CREATE TABLE task_changesets(
+    task_id       INT       NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    changeset_id  BIGINT    NOT NULL,
+    linked_by     TEXT      NOT NULL,
+    linked_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    user_id       TEXT,
+    changes_count INT,
+    open          BOOLEAN   NOT NULL DEFAULT true,
+    fetched_at    TIMESTAMP,
+    PRIMARY KEY (task_id, changeset_id)
+);
+CREATE INDEX task_changesets_changeset_id_idx ON task_changesets(changeset_id);
+
+INSERT INTO db_versions VALUES('045');
+
+END TRANSACTION;
@@ -0,0 +1,14 @@
+BEGIN TRANSACTION;
+
+-- Offers of the assigned user to hand the task over to another member, removed when the member accepted or declined it
+CREATE TABLE task_handovers(
+    task_id    INT       PRIMARY KEY NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    from_user  TEXT      NOT NULL,
+    to_user    TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX task_handovers_to_user_idx ON task_handovers(to_user);
+
+INSERT INTO db_versions VALUES('046');
+
+END TRANSACTION;
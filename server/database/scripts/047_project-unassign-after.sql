@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+-- Tasks assigned this many hours without any process point change get unassigned automatically, 0 disables this
+ALTER TABLE projects ADD COLUMN unassign_after_hours INT NOT NULL DEFAULT 0;
+
+INSERT INTO db_versions VALUES('047');
+
+END TRANSACTION;
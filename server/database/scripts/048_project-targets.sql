@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+-- Targets of the owner like "80% of the process points by 2021-06-01" as JSON array of {"percentage", "date"} objects
+ALTER TABLE projects ADD COLUMN targets JSONB NOT NULL DEFAULT '[]';
+
+INSERT INTO db_versions VALUES('048');
+
+END TRANSACTION;
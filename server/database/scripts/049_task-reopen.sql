@@ -0,0 +1,13 @@
+BEGIN TRANSACTION;
+
+-- Reason given by the user, e.g. why the owner reopened a completed task
+ALTER TABLE task_history ADD COLUMN comment TEXT NOT NULL DEFAULT '';
+
+-- Notifications about a single task, like the reopening of a task completed by the user. No foreign key, so that
+-- notifications stay when the task is deleted.
+ALTER TABLE notifications ADD COLUMN task_id INT;
+ALTER TABLE notifications ADD COLUMN comment TEXT NOT NULL DEFAULT '';
+
+INSERT INTO db_versions VALUES('049');
+
+END TRANSACTION;
@@ -0,0 +1,8 @@
+BEGIN TRANSACTION;
+
+-- Imagery layers of the project as JSON array of {"name", "type", "url", "attribution", "maxZoom"} objects
+ALTER TABLE projects ADD COLUMN layers JSONB NOT NULL DEFAULT '[]';
+
+INSERT INTO db_versions VALUES('050');
+
+END TRANSACTION;
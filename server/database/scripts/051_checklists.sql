@@ -0,0 +1,13 @@
+BEGIN TRANSACTION;
+
+-- Instructions of the owner as JSON array of {"id", "text"} objects, optionally the process points of the tasks are
+-- derived from the completed items
+ALTER TABLE projects ADD COLUMN checklist JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE projects ADD COLUMN checklist_points BOOLEAN NOT NULL DEFAULT false;
+
+-- IDs of the completed checklist items of the task
+ALTER TABLE tasks ADD COLUMN checklist_done TEXT[] NOT NULL DEFAULT '{}';
+
+INSERT INTO db_versions VALUES('051');
+
+END TRANSACTION;
@@ -0,0 +1,18 @@
+BEGIN TRANSACTION;
+
+-- OSM notes created for or linked to tasks, e.g. for issues mappers couldn't solve on their own. The state of the notes
+-- is loaded from the OSM API regularly, so that the tasks know how many of their notes are still open.
+CREATE TABLE task_osm_notes(
+    task_id    INT       NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    note_id    BIGINT    NOT NULL,
+    linked_by  TEXT      NOT NULL,
+    linked_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    open       BOOLEAN   NOT NULL DEFAULT true,
+    fetched_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (task_id, note_id)
+);
+CREATE INDEX task_osm_notes_note_id_idx ON task_osm_notes(note_id);
+
+INSERT INTO db_versions VALUES('052');
+
+END TRANSACTION;
@@ -0,0 +1,13 @@
+BEGIN TRANSACTION;
+
+-- OSM users allowed to log in when the server is invite-only. OSM user names are case-insensitive.
+CREATE TABLE invitations(
+    user_name  TEXT PRIMARY KEY  NOT NULL,
+    invited_by TEXT              NOT NULL,
+    invited_at TIMESTAMP         NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX invitations_lower_user_name_idx ON invitations(LOWER(user_name));
+
+INSERT INTO db_versions VALUES('053');
+
+END TRANSACTION;
@@ -0,0 +1,17 @@
+BEGIN TRANSACTION;
+
+-- Web Push subscriptions of the browsers of users. The endpoint identifies a subscription, so a browser re-subscribing
+-- (e.g. for another user) replaces its old subscription.
+CREATE TABLE push_subscriptions(
+    endpoint    TEXT PRIMARY KEY  NOT NULL,
+    user_id     TEXT              NOT NULL,
+    p256dh      TEXT              NOT NULL,
+    auth        TEXT              NOT NULL,
+    created_at  TIMESTAMP         NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX push_subscriptions_user_id_idx ON push_subscriptions(user_id);
+
+INSERT INTO db_versions VALUES('054');
+
+END TRANSACTION;
@@ -0,0 +1,25 @@
+BEGIN TRANSACTION;
+
+-- Size of the outer ring of polygons (area in km² and perimeter in km) and the length of lines (in km) on a sphere. This
+-- is the same computation as the server does when adding new tasks, points have neither area nor perimeter.
+ALTER TABLE tasks ADD COLUMN area DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN perimeter DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+WITH shapes AS (
+    SELECT id,
+           CASE
+               WHEN ST_GeometryType(g) = 'ST_Polygon' THEN ST_ExteriorRing(g)
+               ELSE g
+           END AS outline,
+           ST_GeometryType(g) = 'ST_Polygon' AS is_polygon
+    FROM (SELECT id, ST_SetSRID(ST_GeomFromGeoJSON(geometry::JSON->>'geometry'), 4326) AS g FROM tasks) t
+)
+UPDATE tasks SET
+    area = CASE WHEN s.is_polygon THEN ST_Area(ST_MakePolygon(s.outline)::GEOGRAPHY, false) / 1000000 ELSE 0 END,
+    perimeter = ST_Length(s.outline::GEOGRAPHY, false) / 1000
+FROM shapes s
+WHERE tasks.id = s.id;
+
+INSERT INTO db_versions VALUES('055');
+
+END TRANSACTION;
@@ -0,0 +1,15 @@
+BEGIN TRANSACTION;
+
+-- Short-lived reservations of tasks opened in an editor, so that others see that someone is working on them. This is
+-- independent of the assignment, expired reservations are removed regularly.
+CREATE TABLE task_reservations(
+    task_id    INT       PRIMARY KEY NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id    TEXT      NOT NULL,
+    editor     TEXT      NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+CREATE INDEX task_reservations_expires_at_idx ON task_reservations(expires_at);
+
+INSERT INTO db_versions VALUES('056');
+
+END TRANSACTION;
@@ -0,0 +1,16 @@
+BEGIN TRANSACTION;
+
+-- Users watching public projects without being members. The progress is the last milestone (in percent) the watcher
+-- has been notified about, so that every milestone is only notified once.
+CREATE TABLE project_watchers(
+    project_id        INT       NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id           TEXT      NOT NULL,
+    notified_progress INT       NOT NULL DEFAULT 0,
+    created_at        TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (project_id, user_id)
+);
+CREATE INDEX project_watchers_user_id_idx ON project_watchers(user_id);
+
+INSERT INTO db_versions VALUES('058');
+
+END TRANSACTION;
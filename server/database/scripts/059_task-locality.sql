@@ -0,0 +1,9 @@
+BEGIN TRANSACTION;
+
+-- Human-readable name of the area around the centroid of the task, looked up via reverse geocoding. NULL means that
+-- the locality hasn't been looked up yet.
+ALTER TABLE tasks ADD COLUMN locality TEXT;
+
+INSERT INTO db_versions VALUES('059');
+
+END TRANSACTION;
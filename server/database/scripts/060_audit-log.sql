@@ -0,0 +1,33 @@
+BEGIN TRANSACTION;
+
+-- Append-only log of the changes of tasks and projects (see the audit package). The task history and the project
+-- commands are removed together with their tasks and projects (and by the retention), so the audit log is a table of
+-- its own without foreign keys.
+CREATE TABLE audit_log(
+    id          SERIAL PRIMARY KEY NOT NULL,
+    created_at  TIMESTAMP          NOT NULL DEFAULT NOW(),
+    project_id  INT                NOT NULL,
+    task_id     INT,
+    user_id     TEXT               NOT NULL,
+    action      TEXT               NOT NULL,
+    details     JSONB              NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log(created_at);
+
+-- Take over the entries of the existing task history and commands
+INSERT INTO audit_log(created_at, project_id, task_id, user_id, action, details)
+SELECT created_at, project_id, task_id, user_id, action, details FROM (
+    SELECT h.created_at, t.project_id, h.task_id, h.user_id, h.type AS action,
+        jsonb_build_object('processPoints', h.process_points, 'pointsDelta', h.points_delta, 'comment', h.comment) AS details
+    FROM task_history h JOIN tasks t ON t.id = h.task_id
+    UNION ALL
+    SELECT c.created_at, c.project_id, NULL, c.user_id, c.type, c.data FROM project_commands c
+    UNION ALL
+    SELECT c.reverted_at, c.project_id, NULL, c.reverted_by, c.type || '_reverted', c.data FROM project_commands c WHERE c.reverted_at IS NOT NULL
+) e
+ORDER BY created_at;
+
+INSERT INTO db_versions VALUES('060');
+
+END TRANSACTION;
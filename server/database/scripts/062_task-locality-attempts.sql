@@ -0,0 +1,10 @@
+BEGIN TRANSACTION;
+
+-- Time of the last lookup of the locality. Tasks without locality are looked up in order of their last attempt, so
+-- tasks whose lookup keeps failing don't block the lookup of newer tasks.
+ALTER TABLE tasks ADD COLUMN locality_attempted_at TIMESTAMP;
+CREATE INDEX tasks_locality_lookup_idx ON tasks(locality_attempted_at NULLS FIRST, id) WHERE locality IS NULL;
+
+INSERT INTO db_versions VALUES('062');
+
+END TRANSACTION;
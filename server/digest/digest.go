@@ -0,0 +1,225 @@
+package digest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	netmail "net/mail"
+	"strings"
+
+	"github.com/hauke96/simple-task-manager/server/mail"
+	"github.com/hauke96/simple-task-manager/server/outbox"
+	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/project"
+	"github.com/hauke96/simple-task-manager/server/quota"
+	"github.com/hauke96/simple-task-manager/server/task"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+const (
+	IntervalDaily  = "daily"
+	IntervalWeekly = "weekly"
+)
+
+// Subscription is the opt-in of a member to receive a digest of a project via mail.
+type Subscription struct {
+	ProjectId string `json:"projectId"`
+	UserId    string `json:"userId"`
+	Email     string `json:"email"`
+	Interval  string `json:"interval"` // Either "daily" or "weekly"
+}
+
+// Digest summarizes the progress of a project.
+type Digest struct {
+	ProjectId          string
+	ProjectName        string
+	DoneProcessPoints  int
+	TotalProcessPoints int
+	RemainingTasks     int // Tasks where not all process points have been set yet
+	ActiveMembers      int // Members currently assigned to at least one task
+	Targets            []*project.TargetState
+}
+
+type DigestService struct {
+	*util.Logger
+	store             *storePg
+	permissionService *permission.PermissionService
+	projectService    *project.ProjectService
+	outboxService     *outbox.OutboxService
+}
+
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger, permissionService *permission.PermissionService, projectService *project.ProjectService, outboxService *outbox.OutboxService) *DigestService {
+	return &DigestService{
+		Logger:            logger,
+		store:             getStore(ctx, tx, logger),
+		permissionService: permissionService,
+		projectService:    projectService,
+		outboxService:     outboxService,
+	}
+}
+
+// SendDigestsJob is meant to be executed by the scheduler. It sends all digests that are due.
+func SendDigestsJob(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	permissionService := permission.Init(ctx, tx, logger)
+	taskService := task.Init(ctx, tx, logger, permissionService)
+	projectService := project.Init(ctx, tx, logger, taskService, permissionService, quota.Init(ctx, tx, logger))
+	return Init(ctx, tx, logger, permissionService, projectService, outbox.Init(ctx, tx, logger)).SendDueDigests()
+}
+
+// Subscribe opts the requesting user in to receive digests of the given project. An existing subscription of this
+// user for this project is overwritten.
+func (s *DigestService) Subscribe(projectId string, email string, interval string, requestingUserId string) (*Subscription, error) {
+	err := s.permissionService.VerifyMembershipProject(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if interval != IntervalDaily && interval != IntervalWeekly {
+		return nil, errors.New(fmt.Sprintf("interval must be '%s' or '%s' but was '%s'", IntervalDaily, IntervalWeekly, interval))
+	}
+
+	address, err := netmail.ParseAddress(email)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("invalid e-mail address '%s'", email))
+	}
+
+	subscription, err := s.store.addSubscription(projectId, requestingUserId, address.Address, interval)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("User %s subscribed to %s digest of project %s", requestingUserId, interval, projectId)
+
+	return subscription, nil
+}
+
+// Unsubscribe removes the subscription of the requesting user for the given project.
+func (s *DigestService) Unsubscribe(projectId string, requestingUserId string) error {
+	err := s.permissionService.VerifyMembershipProject(projectId, requestingUserId)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.removeSubscription(projectId, requestingUserId)
+	if err != nil {
+		return err
+	}
+	s.Log("User %s unsubscribed from digest of project %s", requestingUserId, projectId)
+
+	return nil
+}
+
+// SendDueDigests sends a digest mail for every subscription where the last digest is older than the subscribed interval.
+// Only subscriptions of users still being a member of the project are considered. The mails are added to the outbox
+// together with marking the subscriptions as sent, so that they are delivered exactly once.
+func (s *DigestService) SendDueDigests() error {
+	if !mail.Enabled() {
+		s.Debug("No SMTP server configured, skip sending digests")
+		return nil
+	}
+
+	subscriptions, err := s.store.getDueSubscriptions()
+	if err != nil {
+		return err
+	}
+
+	// Each project digest is only computed once even when several members subscribed to it
+	digests := make(map[string]*Digest)
+
+	for _, subscription := range subscriptions {
+		digest, ok := digests[subscription.ProjectId]
+		if !ok {
+			digest, err = s.getDigest(subscription.ProjectId)
+			if err != nil {
+				return err
+			}
+			digests[subscription.ProjectId] = digest
+		}
+
+		err = s.outboxService.AddMail(subscription.Email, fmt.Sprintf("Progress of project '%s'", digest.ProjectName), digest.toText())
+		if err != nil {
+			return err
+		}
+
+		err = s.store.markSent(subscription.ProjectId, subscription.UserId)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.Log("Processed %d due digest subscriptions", len(subscriptions))
+
+	return nil
+}
+
+// SendCompletionMails tells all members subscribed to digests of the project that the project has been completed. The
+// mails are added to the outbox and therefore only sent when the completing transaction is committed.
+func (s *DigestService) SendCompletionMails(projectId string) error {
+	if !mail.Enabled() {
+		s.Debug("No SMTP server configured, skip sending completion mails")
+		return nil
+	}
+
+	subscriptions, err := s.store.getSubscriptions(projectId)
+	if err != nil {
+		return err
+	}
+
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	digest, err := s.getDigest(projectId)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("All tasks of project '%s' are done, thanks for your help!\n\n", digest.ProjectName) + digest.toText()
+
+	for _, subscription := range subscriptions {
+		err = s.outboxService.AddMail(subscription.Email, fmt.Sprintf("Project '%s' completed", digest.ProjectName), body)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.Log("Sent completion mails of project %s to %d subscribers", projectId, len(subscriptions))
+
+	return nil
+}
+
+// getDigest computes the digest of the project including the state of its targets.
+func (s *DigestService) getDigest(projectId string) (*Digest, error) {
+	digest, err := s.store.getDigest(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	digest.Targets, err = s.projectService.EvaluateTargets(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	return digest, nil
+}
+
+func (d *Digest) toText() string {
+	percentage := 0
+	if d.TotalProcessPoints != 0 {
+		percentage = d.DoneProcessPoints * 100 / d.TotalProcessPoints
+	}
+
+	lines := []string{
+		fmt.Sprintf("Summary of project '%s':", d.ProjectName),
+		"",
+		fmt.Sprintf("  Progress        : %d / %d process points (%d%%)", d.DoneProcessPoints, d.TotalProcessPoints, percentage),
+		fmt.Sprintf("  Remaining tasks : %d", d.RemainingTasks),
+		fmt.Sprintf("  Active members  : %d", d.ActiveMembers),
+	}
+
+	for _, t := range d.Targets {
+		lines = append(lines, fmt.Sprintf("  Target          : %d%% by %s, %s (%d%% expected)", t.Percentage, t.Date, strings.ReplaceAll(t.Status, "_", " "), t.ProjectedPercentage))
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
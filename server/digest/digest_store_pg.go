@@ -0,0 +1,184 @@
+package digest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+type storePg struct {
+	*util.Logger
+	ctx          context.Context
+	tx           *sql.Tx
+	table        database.Table
+	projectTable database.Table
+	taskTable    database.Table
+}
+
+var (
+	subscriptionReturnValues = "project_id, user_id, email, digest_interval"
+)
+
+func getStore(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
+	return &storePg{
+		Logger:       logger,
+		ctx:          ctx,
+		tx:           tx,
+		table:        database.TableDigestSubscriptions,
+		projectTable: database.TableProjects,
+		taskTable:    database.TableTasks,
+	}
+}
+
+func (s *storePg) addSubscription(projectId string, userId string, email string, interval string) (*Subscription, error) {
+	query := fmt.Sprintf("INSERT INTO %s(project_id, user_id, email, digest_interval) VALUES($1, $2, $3, $4) ON CONFLICT (project_id, user_id) DO UPDATE SET email=$3, digest_interval=$4 RETURNING %s;", s.table, subscriptionReturnValues)
+	s.LogQuery(query, projectId, userId, email, interval)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, projectId, userId, email, interval)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not run query")
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, errors.New("there is no next row or an error happened")
+	}
+
+	return rowToSubscription(rows)
+}
+
+func (s *storePg) removeSubscription(projectId string, userId string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE project_id=$1 AND user_id=$2;", s.table)
+	s.LogQuery(query, projectId, userId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, projectId, userId)
+	return err
+}
+
+// getDueSubscriptions returns all subscriptions where the last digest is older than the interval of the subscription.
+// Subscriptions of users that aren't a member of the project anymore are ignored.
+func (s *storePg) getDueSubscriptions() ([]*Subscription, error) {
+	query := fmt.Sprintf(`SELECT s.project_id, s.user_id, s.email, s.digest_interval FROM %s s, %s p
+WHERE s.project_id = p.id AND s.user_id = ANY(p.users) AND (
+	s.last_sent IS NULL OR
+	(s.digest_interval = 'daily' AND s.last_sent < NOW() - INTERVAL '1 day') OR
+	(s.digest_interval = 'weekly' AND s.last_sent < NOW() - INTERVAL '7 days')
+);`, s.table, s.projectTable)
+	s.LogQuery(query)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "error executing query to get due digest subscriptions")
+	}
+	defer rows.Close()
+
+	subscriptions := make([]*Subscription, 0)
+	for rows.Next() {
+		subscription, err := rowToSubscription(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "error converting row to subscription")
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, nil
+}
+
+// getSubscriptions returns all subscriptions of the project. Subscriptions of users that aren't a member of the project
+// anymore are ignored.
+func (s *storePg) getSubscriptions(projectId string) ([]*Subscription, error) {
+	query := fmt.Sprintf(`SELECT s.project_id, s.user_id, s.email, s.digest_interval FROM %s s, %s p
+WHERE s.project_id = p.id AND s.user_id = ANY(p.users) AND s.project_id = $1;`, s.table, s.projectTable)
+	s.LogQuery(query, projectId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, projectId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error executing query to get digest subscriptions of project %s", projectId)
+	}
+	defer rows.Close()
+
+	subscriptions := make([]*Subscription, 0)
+	for rows.Next() {
+		subscription, err := rowToSubscription(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "error converting row to subscription")
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, nil
+}
+
+func (s *storePg) markSent(projectId string, userId string) error {
+	query := fmt.Sprintf("UPDATE %s SET last_sent=NOW() WHERE project_id=$1 AND user_id=$2;", s.table)
+	s.LogQuery(query, projectId, userId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, projectId, userId)
+	return err
+}
+
+// getDigest aggregates the progress information of the given project.
+func (s *storePg) getDigest(projectId string) (*Digest, error) {
+	query := fmt.Sprintf(`SELECT p.id, p.name,
+	COALESCE(SUM(t.process_points), 0),
+	COALESCE(SUM(t.max_process_points), 0),
+	COUNT(t.id) FILTER (WHERE t.process_points < t.max_process_points),
+	COUNT(DISTINCT t.assigned_user) FILTER (WHERE t.assigned_user <> '')
+FROM %s p LEFT JOIN %s t ON t.project_id = p.id
+WHERE p.id = $1
+GROUP BY p.id, p.name;`, s.projectTable, s.taskTable)
+	s.LogQuery(query, projectId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, projectId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error executing query to get digest of project %s", projectId)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, errors.New(fmt.Sprintf("project %s does not exist", projectId))
+	}
+
+	var id int
+	digest := &Digest{}
+	err = rows.Scan(&id, &digest.ProjectName, &digest.DoneProcessPoints, &digest.TotalProcessPoints, &digest.RemainingTasks, &digest.ActiveMembers)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not scan digest")
+	}
+	digest.ProjectId = strconv.Itoa(id)
+
+	return digest, nil
+}
+
+// rowToSubscription turns the current row into a Subscription object. This does not close the row.
+func rowToSubscription(rows *sql.Rows) (*Subscription, error) {
+	var projectId int
+	subscription := &Subscription{}
+
+	err := rows.Scan(&projectId, &subscription.UserId, &subscription.Email, &subscription.Interval)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not scan rows")
+	}
+	subscription.ProjectId = strconv.Itoa(projectId)
+
+	return subscription, nil
+}
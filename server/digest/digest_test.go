@@ -0,0 +1,216 @@
+package digest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/outbox"
+	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/project"
+	"github.com/hauke96/simple-task-manager/server/quota"
+	"github.com/hauke96/simple-task-manager/server/task"
+	"github.com/hauke96/simple-task-manager/server/test"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+	"strings"
+	"testing"
+
+	_ "github.com/lib/pq" // Make driver "postgres" usable
+)
+
+var (
+	tx *sql.Tx
+	s  *DigestService
+	h  *test.TestHelper
+)
+
+func TestMain(m *testing.M) {
+	h = &test.TestHelper{
+		Setup: setup,
+	}
+
+	m.Run()
+}
+
+func setup() {
+	config.LoadConfig("../config/test.json")
+	test.InitWithDummyData()
+	sigolo.LogLevel = sigolo.LOG_DEBUG
+
+	logger := util.NewLogger()
+
+	ctx := context.Background()
+
+	var err error
+	tx, err = database.GetTransaction(ctx, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	h.Tx = tx
+	permissionService := permission.Init(ctx, tx, logger)
+	taskService := task.Init(ctx, tx, logger, permissionService)
+	projectService := project.Init(ctx, tx, logger, taskService, permissionService, quota.Init(ctx, tx, logger))
+	s = Init(ctx, tx, logger, permissionService, projectService, outbox.Init(ctx, tx, logger))
+}
+
+func TestSubscribe(t *testing.T) {
+	h.Run(t, func() error {
+		subscription, err := s.Subscribe("2", "john@example.com", IntervalDaily, "John")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error: %s\n", err.Error()))
+		}
+
+		if subscription.ProjectId != "2" ||
+			subscription.UserId != "John" ||
+			subscription.Email != "john@example.com" ||
+			subscription.Interval != IntervalDaily {
+			return errors.New(fmt.Sprintf("Subscription does not match: %#v", subscription))
+		}
+
+		// Subscribing again overwrites the existing subscription
+		subscription, err = s.Subscribe("2", "John <john@example.org>", IntervalWeekly, "John")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error: %s\n", err.Error()))
+		}
+
+		if subscription.Email != "john@example.org" || subscription.Interval != IntervalWeekly {
+			return errors.New(fmt.Sprintf("Subscription not updated: %#v", subscription))
+		}
+
+		return nil
+	})
+}
+
+func TestSubscribeInvalid(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := s.Subscribe("2", "john@example.com", "hourly", "John")
+		if err == nil {
+			return errors.New("Interval 'hourly' should not be allowed")
+		}
+
+		_, err = s.Subscribe("2", "not a mail address", IntervalDaily, "John")
+		if err == nil {
+			return errors.New("Invalid e-mail address should not be allowed")
+		}
+
+		_, err = s.Subscribe("1", "john@example.com", IntervalDaily, "John")
+		if err == nil {
+			return errors.New("John is not a member of project 1 and should not be able to subscribe")
+		}
+
+		return nil
+	})
+}
+
+func TestUnsubscribe(t *testing.T) {
+	h.Run(t, func() error {
+		err := s.Unsubscribe("1", "Peter")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error: %s\n", err.Error()))
+		}
+
+		subscriptions, err := s.store.getDueSubscriptions()
+		if err != nil {
+			return err
+		}
+
+		if len(subscriptions) != 0 {
+			return errors.New(fmt.Sprintf("Expected no due subscriptions but found %d", len(subscriptions)))
+		}
+
+		// Non-members can't have a subscription
+		err = s.Unsubscribe("1", "John")
+		if err == nil {
+			return errors.New("John is not a member of project 1")
+		}
+
+		return nil
+	})
+}
+
+func TestGetDueSubscriptions(t *testing.T) {
+	h.Run(t, func() error {
+		// Maria got her weekly digest just now, so only Peter (who never got one) is due
+		subscriptions, err := s.store.getDueSubscriptions()
+		if err != nil {
+			return err
+		}
+
+		if len(subscriptions) != 1 || subscriptions[0].UserId != "Peter" || subscriptions[0].ProjectId != "1" {
+			return errors.New(fmt.Sprintf("Due subscriptions do not match: %#v", subscriptions))
+		}
+
+		err = s.store.markSent("1", "Peter")
+		if err != nil {
+			return err
+		}
+
+		subscriptions, err = s.store.getDueSubscriptions()
+		if err != nil {
+			return err
+		}
+
+		if len(subscriptions) != 0 {
+			return errors.New("Peter got a digest and should not be due anymore")
+		}
+
+		return nil
+	})
+}
+
+func TestGetDigest(t *testing.T) {
+	h.Run(t, func() error {
+		digest, err := s.store.getDigest("2")
+		if err != nil {
+			return err
+		}
+
+		if digest.ProjectId != "2" ||
+			digest.ProjectName != "Project 2" ||
+			digest.DoneProcessPoints != 154 ||
+			digest.TotalProcessPoints != 308 ||
+			digest.RemainingTasks != 4 ||
+			digest.ActiveMembers != 2 {
+			return errors.New(fmt.Sprintf("Digest does not match: %#v", digest))
+		}
+
+		_, err = s.store.getDigest("42")
+		if err == nil {
+			return errors.New("Project 42 does not exist")
+		}
+
+		return nil
+	})
+}
+
+func TestGetDigestWithTargets(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := tx.Exec(`UPDATE projects SET targets='[{"percentage": 50, "date": "2000-01-01"}, {"percentage": 40, "date": "2999-01-01"}]' WHERE id=2;`)
+		if err != nil {
+			return err
+		}
+
+		digest, err := s.getDigest("2")
+		if err != nil {
+			return err
+		}
+
+		if len(digest.Targets) != 2 ||
+			digest.Targets[0].Status != project.TargetStatusMissed ||
+			digest.Targets[1].Status != project.TargetStatusReached {
+			return errors.New(fmt.Sprintf("Targets of digest do not match: %#v", digest.Targets))
+		}
+
+		text := digest.toText()
+		if !strings.Contains(text, "Target          : 50% by 2000-01-01, missed") ||
+			!strings.Contains(text, "Target          : 40% by 2999-01-01, reached") {
+			return errors.New(fmt.Sprintf("Text of digest does not contain targets: %s", text))
+		}
+
+		return nil
+	})
+}
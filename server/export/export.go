@@ -0,0 +1,218 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/task"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Values of the "Status" of a job
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+
+	maxPendingJobs = 5 // Maximum number of not yet built exports per user
+)
+
+// Job is an export built in the background, because large exports would time out synchronous requests.
+type Job struct {
+	Id         string     `json:"id"`
+	ProjectId  string     `json:"projectId"`
+	Format     string     `json:"format"`   // One of the "ExportFormat..." values of the task package
+	Status     string     `json:"status"`   // One of the "Status..." values
+	Progress   int        `json:"progress"` // Percent, only meaningful while the job is running
+	Error      string     `json:"error,omitempty"`
+	CreatedBy  string     `json:"createdBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"` // The file can be downloaded until then, afterwards the job is removed
+}
+
+// File is the result of a finished job.
+type File struct {
+	Data        []byte
+	ContentType string
+	Name        string
+}
+
+type ExportService struct {
+	*util.Logger
+	store             *storePg
+	taskService       *task.TaskService
+	permissionService *permission.PermissionService
+}
+
+var (
+	// Jobs are built within one transaction, so their state isn't visible in the database until they're finished. The
+	// progress of running jobs is therefore only kept in memory (job ID -> percent).
+	progress      = make(map[string]int)
+	progressMutex = &sync.Mutex{}
+)
+
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger, taskService *task.TaskService, permissionService *permission.PermissionService) *ExportService {
+	return &ExportService{
+		Logger:            logger,
+		store:             getStore(ctx, tx, logger),
+		taskService:       taskService,
+		permissionService: permissionService,
+	}
+}
+
+// BuildJob creates a job for the scheduler, which builds the oldest pending export. The file can be downloaded for the
+// given retention time.
+func BuildJob(retention time.Duration) func(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	return func(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+		permissionService := permission.Init(ctx, tx, logger)
+		return Init(ctx, tx, logger, task.Init(ctx, tx, logger, permissionService), permissionService).BuildNext(retention)
+	}
+}
+
+// CleanupJob is meant to be executed by the scheduler. It removes all expired jobs together with their files.
+func CleanupJob(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	return Init(ctx, tx, logger, nil, nil).RemoveExpired()
+}
+
+// AddJob checks the membership of the requesting user and adds a pending job, which is built by the scheduler.
+func (s *ExportService) AddJob(projectId string, format string, requestingUserId string) (*Job, error) {
+	err := s.permissionService.VerifyMembershipProject(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !task.IsValidExportFormat(format) {
+		return nil, errors.New(fmt.Sprintf("unknown export format '%s'", format))
+	}
+
+	pendingJobs, err := s.store.countPendingJobs(requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+	if pendingJobs >= maxPendingJobs {
+		return nil, errors.New(fmt.Sprintf("user %s already has %d pending exports, wait until they're finished", requestingUserId, pendingJobs))
+	}
+
+	job, err := s.store.addJob(projectId, format, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Added %s export job %s for project %s", format, job.Id, projectId)
+
+	return job, nil
+}
+
+// GetJob returns the job with its current progress. Only the user who created the job can see it.
+func (s *ExportService) GetJob(jobId string, requestingUserId string) (*Job, error) {
+	job, err := s.store.getJob(jobId)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.CreatedBy != requestingUserId {
+		return nil, errors.New(fmt.Sprintf("user %s is not allowed to see export job %s", requestingUserId, jobId))
+	}
+
+	if job.Status == StatusPending {
+		if percent, ok := getProgress(jobId); ok {
+			job.Status = StatusRunning
+			job.Progress = percent
+		}
+	}
+
+	return job, nil
+}
+
+// GetFile returns the file of the finished job. Only the user who created the job can download it.
+func (s *ExportService) GetFile(jobId string, requestingUserId string) (*File, error) {
+	job, err := s.GetJob(jobId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Status != StatusDone {
+		return nil, errors.New(fmt.Sprintf("export job %s is not done but %s", jobId, job.Status))
+	}
+
+	data, err := s.store.getData(jobId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{
+		Data:        data,
+		ContentType: task.ExportContentType(job.Format),
+		Name:        task.ExportFileName(job.ProjectId, job.Format),
+	}, nil
+}
+
+// BuildNext builds the oldest pending export. Jobs are locked while being built, so concurrent runs (e.g. of several
+// server instances) don't build them twice. Failing exports are marked as failed and not retried.
+func (s *ExportService) BuildNext(retention time.Duration) error {
+	job, err := s.store.getNextPendingJob()
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return nil
+	}
+
+	setProgress(job.Id, 0)
+	defer removeProgress(job.Id)
+
+	// The export is built with the permissions of the user who created the job, who might have left the project since
+	data, buildErr := s.taskService.Export(job.ProjectId, job.Format, job.CreatedBy)
+	if buildErr != nil {
+		s.Err("Unable to build export job %s: %s", job.Id, buildErr.Error())
+		return s.store.markFailed(job.Id, buildErr.Error(), retention)
+	}
+	setProgress(job.Id, 90)
+
+	err = s.store.markDone(job.Id, data, retention)
+	if err != nil {
+		return err
+	}
+	s.Log("Built %s export job %s for project %s with %d bytes", job.Format, job.Id, job.ProjectId, len(data))
+
+	return nil
+}
+
+// RemoveExpired removes all jobs whose files can't be downloaded anymore.
+func (s *ExportService) RemoveExpired() error {
+	count, err := s.store.removeExpiredJobs()
+	if err != nil {
+		return err
+	}
+
+	if count != 0 {
+		s.Log("Removed %d expired export jobs", count)
+	}
+
+	return nil
+}
+
+func setProgress(jobId string, percent int) {
+	progressMutex.Lock()
+	defer progressMutex.Unlock()
+	progress[jobId] = percent
+}
+
+func getProgress(jobId string) (int, bool) {
+	progressMutex.Lock()
+	defer progressMutex.Unlock()
+	percent, ok := progress[jobId]
+	return percent, ok
+}
+
+func removeProgress(jobId string) {
+	progressMutex.Lock()
+	defer progressMutex.Unlock()
+	delete(progress, jobId)
+}
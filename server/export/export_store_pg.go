@@ -0,0 +1,165 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+type storePg struct {
+	*util.Logger
+	ctx   context.Context
+	tx    *sql.Tx
+	table database.Table
+}
+
+var (
+	// The data is not part of the return values, since it's only needed for downloads
+	returnValues = "id, project_id, format, status, error, created_by, created_at, finished_at, expires_at"
+)
+
+func getStore(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
+	return &storePg{
+		Logger: logger,
+		ctx:    ctx,
+		tx:     tx,
+		table:  database.TableExportJobs,
+	}
+}
+
+func (s *storePg) addJob(projectId string, format string, userId string) (*Job, error) {
+	query := fmt.Sprintf("INSERT INTO %s(project_id, format, created_by) VALUES($1, $2, $3) RETURNING %s;", s.table, returnValues)
+	return s.execQuery(query, projectId, format, userId)
+}
+
+func (s *storePg) getJob(jobId string) (*Job, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id=$1;", returnValues, s.table)
+	job, err := s.execQuery(query, jobId)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, errors.New(fmt.Sprintf("export job %s does not exist", jobId))
+	}
+
+	return job, nil
+}
+
+func (s *storePg) countPendingJobs(userId string) (int, error) {
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE created_by=$1 AND status=$2;", s.table)
+	s.LogQuery(query, userId, StatusPending)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+
+	var count int
+	err := s.tx.QueryRowContext(ctx, query, userId, StatusPending).Scan(&count)
+	if err != nil {
+		return 0, errors.Wrap(err, "error counting pending export jobs")
+	}
+
+	return count, nil
+}
+
+// getNextPendingJob returns the oldest pending job or nil if there's none. The job is locked until the end of the
+// transaction, already locked ones are skipped.
+func (s *storePg) getNextPendingJob() (*Job, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE status=$1 ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED;", returnValues, s.table)
+	return s.execQuery(query, StatusPending)
+}
+
+func (s *storePg) getData(jobId string) ([]byte, error) {
+	query := fmt.Sprintf("SELECT data FROM %s WHERE id=$1;", s.table)
+	s.LogQuery(query, jobId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+
+	var data []byte
+	err := s.tx.QueryRowContext(ctx, query, jobId).Scan(&data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting file of export job %s", jobId)
+	}
+
+	return data, nil
+}
+
+func (s *storePg) markDone(jobId string, data []byte, retention time.Duration) error {
+	query := fmt.Sprintf("UPDATE %s SET status=$4, data=$2, finished_at=NOW(), expires_at=NOW() + $3::INT * INTERVAL '1 second' WHERE id=$1 RETURNING %s;", s.table, returnValues)
+	_, err := s.execQuery(query, jobId, data, int(retention.Seconds()), StatusDone)
+	return err
+}
+
+// markFailed stores the error, the job is removed after the retention time like successful ones.
+func (s *storePg) markFailed(jobId string, jobError string, retention time.Duration) error {
+	query := fmt.Sprintf("UPDATE %s SET status=$4, error=$2, finished_at=NOW(), expires_at=NOW() + $3::INT * INTERVAL '1 second' WHERE id=$1 RETURNING %s;", s.table, returnValues)
+	_, err := s.execQuery(query, jobId, jobError, int(retention.Seconds()), StatusFailed)
+	return err
+}
+
+func (s *storePg) removeExpiredJobs() (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE expires_at < NOW();", s.table)
+	s.LogQuery(query)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+
+	result, err := s.tx.ExecContext(ctx, query)
+	if err != nil {
+		return 0, errors.Wrap(err, "error removing expired export jobs")
+	}
+
+	return result.RowsAffected()
+}
+
+// execQuery executes the query and returns the first job of the result or nil if there's none.
+func (s *storePg) execQuery(query string, params ...interface{}) (*Job, error) {
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error executing export job query")
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	return rowToJob(rows)
+}
+
+func rowToJob(rows *sql.Rows) (*Job, error) {
+	var id int
+	var projectId int
+	var finishedAt sql.NullTime
+	var expiresAt sql.NullTime
+	job := &Job{}
+
+	err := rows.Scan(&id, &projectId, &job.Format, &job.Status, &job.Error, &job.CreatedBy, &job.CreatedAt, &finishedAt, &expiresAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not scan export job")
+	}
+
+	job.Id = strconv.Itoa(id)
+	job.ProjectId = strconv.Itoa(projectId)
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	if expiresAt.Valid {
+		job.ExpiresAt = &expiresAt.Time
+	}
+	if job.Status == StatusDone {
+		job.Progress = 100
+	}
+
+	return job, nil
+}
@@ -0,0 +1,175 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/task"
+	"github.com/hauke96/simple-task-manager/server/test"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq" // Make driver "postgres" usable
+)
+
+var (
+	tx *sql.Tx
+	s  *ExportService
+	h  *test.TestHelper
+)
+
+func TestMain(m *testing.M) {
+	h = &test.TestHelper{
+		Setup: setup,
+	}
+
+	m.Run()
+}
+
+func setup() {
+	config.LoadConfig("../config/test.json")
+	test.InitWithDummyData()
+	sigolo.LogLevel = sigolo.LOG_DEBUG
+
+	logger := util.NewLogger()
+
+	ctx := context.Background()
+
+	var err error
+	tx, err = database.GetTransaction(ctx, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	h.Tx = tx
+	permissionService := permission.Init(ctx, tx, logger)
+	s = Init(ctx, tx, logger, task.Init(ctx, tx, logger, permissionService), permissionService)
+}
+
+func TestProgress(t *testing.T) {
+	setProgress("1", 42)
+
+	percent, ok := getProgress("1")
+	if !ok || percent != 42 {
+		t.Errorf("Expected progress of 42 but got %d", percent)
+	}
+
+	removeProgress("1")
+
+	_, ok = getProgress("1")
+	if ok {
+		t.Errorf("Progress should have been removed")
+	}
+}
+
+func TestBuildExport(t *testing.T) {
+	h.Run(t, func() error {
+		job, err := s.AddJob("2", task.ExportFormatCsv, "Maria")
+		if err != nil {
+			return err
+		}
+		if job.Status != StatusPending || job.ExpiresAt != nil {
+			return errors.New(fmt.Sprintf("New job should be pending: %#v", job))
+		}
+
+		_, err = s.GetFile(job.Id, "Maria")
+		if err == nil {
+			return errors.New("Getting file of pending job should not work")
+		}
+
+		err = s.BuildNext(time.Hour)
+		if err != nil {
+			return err
+		}
+
+		job, err = s.GetJob(job.Id, "Maria")
+		if err != nil {
+			return err
+		}
+		if job.Status != StatusDone || job.Progress != 100 || job.ExpiresAt == nil {
+			return errors.New(fmt.Sprintf("Job should be done: %#v", job))
+		}
+
+		file, err := s.GetFile(job.Id, "Maria")
+		if err != nil {
+			return err
+		}
+		if file.Name != "project-2.csv" || file.ContentType != "text/csv" || !strings.HasPrefix(string(file.Data), "id,process_points") {
+			return errors.New(fmt.Sprintf("File not matching: %s %s %s", file.Name, file.ContentType, string(file.Data)))
+		}
+
+		_, err = s.GetJob(job.Id, "Peter")
+		if err == nil {
+			return errors.New("Other users should not see the job")
+		}
+
+		// Nothing left to build
+		err = s.BuildNext(time.Hour)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func TestAddJobFails(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := s.AddJob("2", task.ExportFormatCsv, "Peter")
+		if err == nil {
+			return errors.New("Non-members should not be able to export the project")
+		}
+
+		_, err = s.AddJob("2", "shp", "Maria")
+		if err == nil {
+			return errors.New("Unknown formats should not be possible")
+		}
+
+		for i := 0; i < maxPendingJobs; i++ {
+			_, err = s.AddJob("2", task.ExportFormatGeojson, "Maria")
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = s.AddJob("2", task.ExportFormatGeojson, "Maria")
+		if err == nil {
+			return errors.New("Too many pending jobs should not be possible")
+		}
+
+		return nil
+	})
+}
+
+func TestRemoveExpired(t *testing.T) {
+	h.Run(t, func() error {
+		job, err := s.AddJob("2", task.ExportFormatHistory, "Maria")
+		if err != nil {
+			return err
+		}
+
+		err = s.BuildNext(-time.Hour)
+		if err != nil {
+			return err
+		}
+
+		err = s.RemoveExpired()
+		if err != nil {
+			return err
+		}
+
+		_, err = s.GetJob(job.Id, "Maria")
+		if err == nil {
+			return errors.New("Expired job should have been removed")
+		}
+
+		return nil
+	})
+}
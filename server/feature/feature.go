@@ -0,0 +1,205 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Names of the known feature flags
+const (
+	Oauth2             = "oauth2"
+	Websocket          = "websocket"
+	ValidationWorkflow = "validation-workflow"
+)
+
+// Flag is the current state of one feature flag.
+type Flag struct {
+	Name       string `json:"name"`
+	Enabled    bool   `json:"enabled"`
+	Overridden bool   `json:"overridden"` // True when the state has been set by an admin and not by the config
+}
+
+type FeatureService struct {
+	*util.Logger
+	store *storePg
+}
+
+var (
+	// States of all known flags when neither the config nor an admin sets them
+	defaults = map[string]bool{
+		Oauth2:             false,
+		Websocket:          true,
+		ValidationWorkflow: false,
+	}
+
+	configured = copyFlags(defaults)
+	overrides  = make(map[string]bool)
+	flagsMutex = &sync.RWMutex{}
+)
+
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger) *FeatureService {
+	return &FeatureService{
+		Logger: logger,
+		store:  getStore(ctx, tx, logger),
+	}
+}
+
+// LoadOverridesJob is meant to be executed by the scheduler. It reads the flags set by admins, so that changes made on
+// other instances of the server are applied as well.
+func LoadOverridesJob(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	return Init(ctx, tx, logger).LoadOverrides()
+}
+
+// Configure sets the states of the flags from the config. Flags not mentioned there keep their default state.
+func Configure(flags map[string]bool) error {
+	result := copyFlags(defaults)
+
+	for name, enabled := range flags {
+		if !isKnown(name) {
+			return errors.New(fmt.Sprintf("unknown feature flag '%s'", name))
+		}
+		result[name] = enabled
+	}
+
+	flagsMutex.Lock()
+	defer flagsMutex.Unlock()
+
+	configured = result
+
+	return nil
+}
+
+// IsEnabled returns the state of the flag. Flags set by admins take precedence over the config. Unknown flags are never
+// enabled.
+func IsEnabled(name string) bool {
+	flagsMutex.RLock()
+	defer flagsMutex.RUnlock()
+
+	if enabled, ok := overrides[name]; ok {
+		return enabled
+	}
+
+	return configured[name]
+}
+
+// GetFlags returns the states of all known flags sorted by name.
+func GetFlags() []*Flag {
+	flagsMutex.RLock()
+	defer flagsMutex.RUnlock()
+
+	result := make([]*Flag, 0, len(configured))
+	for name, enabled := range configured {
+		override, overridden := overrides[name]
+		if overridden {
+			enabled = override
+		}
+
+		result = append(result, &Flag{
+			Name:       name,
+			Enabled:    enabled,
+			Overridden: overridden,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+// GetEnabledFlags returns the names of all enabled flags sorted by name.
+func GetEnabledFlags() []string {
+	result := make([]string, 0)
+
+	for _, flag := range GetFlags() {
+		if flag.Enabled {
+			result = append(result, flag.Name)
+		}
+	}
+
+	return result
+}
+
+// LoadOverrides replaces the flags set by admins with the ones stored in the database.
+func (s *FeatureService) LoadOverrides() error {
+	loadedOverrides, err := s.store.getOverrides()
+	if err != nil {
+		return err
+	}
+
+	flagsMutex.Lock()
+	defer flagsMutex.Unlock()
+
+	overrides = make(map[string]bool)
+	for name, enabled := range loadedOverrides {
+		// Flags of removed features might still be in the database
+		if isKnown(name) {
+			overrides[name] = enabled
+		}
+	}
+
+	return nil
+}
+
+// SetOverride stores the state of the flag set by the given admin and applies it right away. The caller has to make
+// sure the user is an admin.
+func (s *FeatureService) SetOverride(name string, enabled bool, userId string) (*Flag, error) {
+	if !isKnown(name) {
+		return nil, errors.New(fmt.Sprintf("unknown feature flag '%s'", name))
+	}
+
+	err := s.store.setOverride(name, enabled, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	flagsMutex.Lock()
+	overrides[name] = enabled
+	flagsMutex.Unlock()
+
+	s.Log("Feature flag '%s' set to %v by %s", name, enabled, userId)
+
+	return &Flag{Name: name, Enabled: enabled, Overridden: true}, nil
+}
+
+// RemoveOverride removes the state set by an admin, so that the state from the config is used again. The caller has to
+// make sure the user is an admin.
+func (s *FeatureService) RemoveOverride(name string) (*Flag, error) {
+	if !isKnown(name) {
+		return nil, errors.New(fmt.Sprintf("unknown feature flag '%s'", name))
+	}
+
+	err := s.store.removeOverride(name)
+	if err != nil {
+		return nil, err
+	}
+
+	flagsMutex.Lock()
+	delete(overrides, name)
+	enabled := configured[name]
+	flagsMutex.Unlock()
+
+	s.Log("Feature flag '%s' reset to config value %v", name, enabled)
+
+	return &Flag{Name: name, Enabled: enabled, Overridden: false}, nil
+}
+
+func isKnown(name string) bool {
+	_, ok := defaults[name]
+	return ok
+}
+
+func copyFlags(flags map[string]bool) map[string]bool {
+	result := make(map[string]bool, len(flags))
+	for name, enabled := range flags {
+		result[name] = enabled
+	}
+	return result
+}
@@ -0,0 +1,79 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+type storePg struct {
+	*util.Logger
+	ctx   context.Context
+	tx    *sql.Tx
+	table database.Table
+}
+
+func getStore(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
+	return &storePg{
+		Logger: logger,
+		ctx:    ctx,
+		tx:     tx,
+		table:  database.TableFeatureFlags,
+	}
+}
+
+func (s *storePg) getOverrides() (map[string]bool, error) {
+	query := fmt.Sprintf("SELECT name, enabled FROM %s;", s.table)
+	s.LogQuery(query)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting feature flags")
+	}
+	defer rows.Close()
+
+	result := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		var enabled bool
+
+		err = rows.Scan(&name, &enabled)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan feature flag")
+		}
+
+		result[name] = enabled
+	}
+
+	return result, nil
+}
+
+func (s *storePg) setOverride(name string, enabled bool, userId string) error {
+	query := fmt.Sprintf(`INSERT INTO %s(name, enabled, updated_by) VALUES($1, $2, $3)
+ON CONFLICT (name) DO UPDATE SET enabled=EXCLUDED.enabled, updated_by=EXCLUDED.updated_by, updated_at=NOW();`, s.table)
+	return s.execQuery(query, name, enabled, userId)
+}
+
+func (s *storePg) removeOverride(name string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE name=$1;", s.table)
+	return s.execQuery(query, name)
+}
+
+func (s *storePg) execQuery(query string, params ...interface{}) error {
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return errors.Wrap(err, "error executing feature flag query")
+	}
+
+	return nil
+}
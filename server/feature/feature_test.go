@@ -0,0 +1,137 @@
+package feature
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/test"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+	"testing"
+
+	_ "github.com/lib/pq" // Make driver "postgres" usable
+)
+
+var (
+	tx *sql.Tx
+	s  *FeatureService
+	h  *test.TestHelper
+)
+
+func TestMain(m *testing.M) {
+	h = &test.TestHelper{
+		Setup: setup,
+	}
+
+	m.Run()
+}
+
+func setup() {
+	config.LoadConfig("../config/test.json")
+	test.InitWithDummyData()
+	sigolo.LogLevel = sigolo.LOG_DEBUG
+
+	logger := util.NewLogger()
+
+	ctx := context.Background()
+
+	var err error
+	tx, err = database.GetTransaction(ctx, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	h.Tx = tx
+	s = Init(ctx, tx, logger)
+
+	overrides = make(map[string]bool)
+}
+
+func TestConfigure(t *testing.T) {
+	defer Configure(nil)
+
+	err := Configure(map[string]bool{Oauth2: true, Websocket: false})
+	if err != nil {
+		t.Errorf("Configuring known flags should work: %s", err)
+		return
+	}
+
+	if !IsEnabled(Oauth2) || IsEnabled(Websocket) || IsEnabled(ValidationWorkflow) {
+		t.Errorf("Flags don't match: %v", GetEnabledFlags())
+	}
+
+	if IsEnabled("foo") {
+		t.Errorf("Unknown flag should not be enabled")
+	}
+
+	err = Configure(map[string]bool{"foo": true})
+	if err == nil {
+		t.Errorf("Configuring unknown flag should not work")
+	}
+
+	// Failed configuration must not change anything
+	if !IsEnabled(Oauth2) {
+		t.Errorf("Flags should not have changed: %v", GetEnabledFlags())
+	}
+}
+
+func TestGetFlags(t *testing.T) {
+	flags := GetFlags()
+
+	if len(flags) != 3 || flags[0].Name != Oauth2 || flags[1].Name != ValidationWorkflow || flags[2].Name != Websocket {
+		t.Errorf("Flags not matching: %v", flags)
+	}
+	if flags[0].Enabled || !flags[2].Enabled {
+		t.Errorf("Default states not matching: %v", flags)
+	}
+}
+
+func TestSetAndRemoveOverride(t *testing.T) {
+	h.Run(t, func() error {
+		defer func() { overrides = make(map[string]bool) }()
+
+		flag, err := s.SetOverride(ValidationWorkflow, true, "Peter")
+		if err != nil {
+			return err
+		}
+		if !flag.Enabled || !flag.Overridden || !IsEnabled(ValidationWorkflow) {
+			return errors.New(fmt.Sprintf("Flag should be enabled by override: %v", flag))
+		}
+
+		// Overrides are loaded from the database, e.g. when set on another instance
+		overrides = make(map[string]bool)
+		err = s.LoadOverrides()
+		if err != nil {
+			return err
+		}
+		if !IsEnabled(ValidationWorkflow) {
+			return errors.New("Override should have been loaded from database")
+		}
+
+		flag, err = s.RemoveOverride(ValidationWorkflow)
+		if err != nil {
+			return err
+		}
+		if flag.Enabled || flag.Overridden || IsEnabled(ValidationWorkflow) {
+			return errors.New(fmt.Sprintf("Flag should have config state again: %v", flag))
+		}
+
+		err = s.LoadOverrides()
+		if err != nil {
+			return err
+		}
+		if IsEnabled(ValidationWorkflow) {
+			return errors.New("Override should have been removed from database")
+		}
+
+		_, err = s.SetOverride("foo", true, "Peter")
+		if err == nil {
+			return errors.New("Setting unknown flag should not work")
+		}
+
+		return nil
+	})
+}
@@ -13,5 +13,7 @@ require (
 	github.com/lib/pq v1.7.0
 	github.com/paulmach/go.geojson v1.4.0
 	github.com/pkg/errors v0.9.1
-	github.com/stretchr/testify v1.6.1 // indirect
+	golang.org/x/crypto v0.0.0-20211108221036-ceb1ce70b4fa
+	google.golang.org/grpc v1.56.3
+	google.golang.org/protobuf v1.31.0
 )
@@ -0,0 +1,95 @@
+package invite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Invitation allows the OSM user to log in when the server is invite-only (see config entry "invite-only").
+type Invitation struct {
+	UserName  string    `json:"userName"` // OSM user name, compared case-insensitive
+	InvitedBy string    `json:"invitedBy"`
+	InvitedAt time.Time `json:"invitedAt"`
+}
+
+// Maximum length of OSM user names
+const maxUserNameLength = 255
+
+type InviteService struct {
+	*util.Logger
+	store *storePg
+}
+
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger) *InviteService {
+	return &InviteService{
+		Logger: logger,
+		store:  getStore(ctx, tx, logger),
+	}
+}
+
+// Invite allows the OSM user to log in. Inviting a user twice has no effect. The caller has to make sure the requesting
+// user is an admin.
+func (s *InviteService) Invite(userName string, adminId string) (*Invitation, error) {
+	userName = strings.TrimSpace(userName)
+	if userName == "" || len(userName) > maxUserNameLength {
+		return nil, errors.New(fmt.Sprintf("user name must have 1 to %d characters", maxUserNameLength))
+	}
+
+	invitation, err := s.store.getInvitation(userName)
+	if err != nil {
+		return nil, err
+	}
+	if invitation != nil {
+		return invitation, nil
+	}
+
+	invitation, err = s.store.addInvitation(userName, adminId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Log("User '%s' invited by %s", userName, adminId)
+
+	return invitation, nil
+}
+
+func (s *InviteService) GetInvitations() ([]*Invitation, error) {
+	return s.store.getInvitations()
+}
+
+// RemoveInvitation prevents further logins of the user. Tokens created before stay valid until they expire or their
+// sessions are revoked. The caller has to make sure the requesting user is an admin.
+func (s *InviteService) RemoveInvitation(userName string) error {
+	invitation, err := s.store.getInvitation(userName)
+	if err != nil {
+		return err
+	}
+	if invitation == nil {
+		return errors.New(fmt.Sprintf("user '%s' is not invited", userName))
+	}
+
+	err = s.store.removeInvitation(invitation.UserName)
+	if err != nil {
+		return err
+	}
+
+	s.Log("Invitation of user '%s' removed", invitation.UserName)
+
+	return nil
+}
+
+// IsInvited returns true when the OSM user with this name is allowed to log in.
+func (s *InviteService) IsInvited(userName string) (bool, error) {
+	invitation, err := s.store.getInvitation(userName)
+	if err != nil {
+		return false, err
+	}
+
+	return invitation != nil, nil
+}
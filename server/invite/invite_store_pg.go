@@ -0,0 +1,96 @@
+package invite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+type storePg struct {
+	*util.Logger
+	ctx   context.Context
+	tx    *sql.Tx
+	table database.Table
+}
+
+func getStore(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
+	return &storePg{
+		Logger: logger,
+		ctx:    ctx,
+		tx:     tx,
+		table:  database.TableInvitations,
+	}
+}
+
+func (s *storePg) addInvitation(userName string, adminId string) (*Invitation, error) {
+	query := fmt.Sprintf("INSERT INTO %s(user_name, invited_by) VALUES($1, $2) RETURNING user_name, invited_by, invited_at;", s.table)
+	invitations, err := s.queryInvitations(query, userName, adminId)
+	if err != nil {
+		return nil, err
+	}
+
+	return invitations[0], nil
+}
+
+func (s *storePg) getInvitations() ([]*Invitation, error) {
+	query := fmt.Sprintf("SELECT user_name, invited_by, invited_at FROM %s ORDER BY LOWER(user_name);", s.table)
+	return s.queryInvitations(query)
+}
+
+// getInvitation returns the invitation of the user (ignoring the case of the name) or "nil" when there's none.
+func (s *storePg) getInvitation(userName string) (*Invitation, error) {
+	query := fmt.Sprintf("SELECT user_name, invited_by, invited_at FROM %s WHERE LOWER(user_name)=LOWER($1);", s.table)
+	invitations, err := s.queryInvitations(query, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(invitations) == 0 {
+		return nil, nil
+	}
+
+	return invitations[0], nil
+}
+
+func (s *storePg) removeInvitation(userName string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_name=$1;", s.table)
+	s.LogQuery(query, userName)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, userName)
+	if err != nil {
+		return errors.Wrap(err, "error removing invitation")
+	}
+
+	return nil
+}
+
+func (s *storePg) queryInvitations(query string, params ...interface{}) ([]*Invitation, error) {
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error executing invitation query")
+	}
+	defer rows.Close()
+
+	result := make([]*Invitation, 0)
+	for rows.Next() {
+		invitation := &Invitation{}
+		err = rows.Scan(&invitation.UserName, &invitation.InvitedBy, &invitation.InvitedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan invitation")
+		}
+
+		result = append(result, invitation)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,138 @@
+package invite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/test"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+	"strings"
+	"testing"
+
+	_ "github.com/lib/pq" // Make driver "postgres" usable
+)
+
+var (
+	tx *sql.Tx
+	s  *InviteService
+	h  *test.TestHelper
+)
+
+func TestMain(m *testing.M) {
+	h = &test.TestHelper{
+		Setup: setup,
+	}
+
+	m.Run()
+}
+
+func setup() {
+	config.LoadConfig("../config/test.json")
+	test.InitWithDummyData()
+	sigolo.LogLevel = sigolo.LOG_DEBUG
+
+	logger := util.NewLogger()
+
+	ctx := context.Background()
+
+	var err error
+	tx, err = database.GetTransaction(ctx, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	h.Tx = tx
+	s = Init(ctx, tx, logger)
+}
+
+func TestInvite(t *testing.T) {
+	h.Run(t, func() error {
+		for _, userName := range []string{"", " ", strings.Repeat("a", maxUserNameLength+1)} {
+			_, err := s.Invite(userName, "Peter")
+			if err == nil {
+				return errors.New(fmt.Sprintf("Inviting user '%s' should not be possible", userName))
+			}
+		}
+
+		invitation, err := s.Invite(" Anna ", "Peter")
+		if err != nil {
+			return err
+		}
+		if invitation.UserName != "Anna" || invitation.InvitedBy != "Peter" {
+			return errors.New(fmt.Sprintf("Invitation not matching: %#v", invitation))
+		}
+
+		// The first invitation is kept
+		invitation, err = s.Invite("anna", "Maria")
+		if err != nil {
+			return err
+		}
+		if invitation.UserName != "Anna" || invitation.InvitedBy != "Peter" {
+			return errors.New(fmt.Sprintf("Invitation not matching: %#v", invitation))
+		}
+
+		_, err = s.Invite("carl", "Maria")
+		if err != nil {
+			return err
+		}
+
+		invitations, err := s.GetInvitations()
+		if err != nil {
+			return err
+		}
+		if len(invitations) != 2 || invitations[0].UserName != "Anna" || invitations[1].UserName != "carl" {
+			return errors.New(fmt.Sprintf("Invitations not matching: %v", invitations))
+		}
+
+		invited, err := s.IsInvited("ANNA")
+		if err != nil {
+			return err
+		}
+		if !invited {
+			return errors.New("User name should be compared case-insensitive")
+		}
+
+		invited, err = s.IsInvited("Otto")
+		if err != nil {
+			return err
+		}
+		if invited {
+			return errors.New("User should not be invited")
+		}
+
+		return nil
+	})
+}
+
+func TestRemoveInvitation(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := s.Invite("Anna", "Peter")
+		if err != nil {
+			return err
+		}
+
+		err = s.RemoveInvitation("anna")
+		if err != nil {
+			return err
+		}
+
+		invited, err := s.IsInvited("Anna")
+		if err != nil {
+			return err
+		}
+		if invited {
+			return errors.New("Invitation should have been removed")
+		}
+
+		err = s.RemoveInvitation("Anna")
+		if err == nil {
+			return errors.New("Removing a not existing invitation should not be possible")
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,293 @@
+package loadtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Names of the endpoints measured by "Run"
+const (
+	EndpointGetProjects      = "GET /projects"
+	EndpointGetTasks         = "GET /projects/{id}/tasks"
+	EndpointSetProcessPoints = "POST /tasks/{id}/processPoints"
+)
+
+const (
+	apiVersion       = "/v2.4"
+	maxProcessPoints = 100
+	taskSize         = 0.01 // Width and height of the square task geometries in degrees
+)
+
+// Options of a load test.
+type Options struct {
+	Projects        int // Number of projects created as fixtures
+	TasksPerProject int
+	Requests        int // Requests per endpoint
+	Concurrency     int // Number of requests sent at the same time
+}
+
+// Client sends requests to the API of a running server on behalf of one user.
+type Client struct {
+	baseUrl    string
+	token      string
+	httpClient *http.Client
+}
+
+// Fixtures are the projects and tasks created for a load test. All projects are owned by the user of the client and
+// have no further members, so the process points can be set without assigning the tasks.
+type Fixtures struct {
+	ProjectIds []string
+	TaskIds    []string
+}
+
+// Result contains the latencies of all requests to one endpoint.
+type Result struct {
+	Endpoint   string
+	Errors     int
+	FirstError error           // Nil when all requests succeeded
+	Duration   time.Duration   // Time from the first request until the last response
+	Latencies  []time.Duration // Sorted latencies of all requests, including the failed ones
+}
+
+// NewClient creates a client sending requests with the given token to the server at the base URL (e.g.
+// "http://localhost:8080").
+func NewClient(baseUrl string, token string, httpClient *http.Client) *Client {
+	return &Client{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		token:      token,
+		httpClient: httpClient,
+	}
+}
+
+// request sends a request to the versioned API and decodes the response into the result, which may be nil.
+func (c *Client) request(method string, path string, body interface{}, result interface{}) error {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "error marshalling request body")
+		}
+		bodyReader = bytes.NewReader(bodyBytes)
+	}
+
+	request, err := http.NewRequest(method, c.baseUrl+apiVersion+path, bodyReader)
+	if err != nil {
+		return errors.Wrapf(err, "error creating request %s %s", method, path)
+	}
+	request.Header.Set("Authorization", c.token)
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return errors.Wrapf(err, "error sending request %s %s", method, path)
+	}
+	defer response.Body.Close()
+
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return errors.Wrap(err, "error reading response body")
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("%s %s returned status %d: %s", method, path, response.StatusCode, string(responseBytes)))
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	err = json.Unmarshal(responseBytes, result)
+	return errors.Wrapf(err, "error unmarshalling response of %s %s", method, path)
+}
+
+// CreateFixtures adds the given number of projects with square tasks next to each other. The projects are added via
+// the API, so this also shows how long adding large projects takes.
+func CreateFixtures(c *Client, userId string, projects int, tasksPerProject int) (*Fixtures, error) {
+	fixtures := &Fixtures{
+		ProjectIds: make([]string, 0, projects),
+		TaskIds:    make([]string, 0, projects*tasksPerProject),
+	}
+
+	for p := 0; p < projects; p++ {
+		tasks := make([]map[string]interface{}, tasksPerProject)
+		for t := range tasks {
+			tasks[t] = map[string]interface{}{
+				"maxProcessPoints": maxProcessPoints,
+				"geometry":         squareGeometry(float64(t)*taskSize, float64(p)*taskSize),
+			}
+		}
+
+		body := map[string]interface{}{
+			"project": map[string]interface{}{
+				"name":  fmt.Sprintf("Load test %d", p+1),
+				"users": []string{userId},
+				"owner": userId,
+			},
+			"tasks": tasks,
+		}
+
+		var addedProject struct {
+			Id      string   `json:"id"`
+			TaskIds []string `json:"taskIds"`
+		}
+		err := c.request(http.MethodPost, "/projects", body, &addedProject)
+		if err != nil {
+			return fixtures, errors.Wrapf(err, "error adding project %d of %d", p+1, projects)
+		}
+
+		fixtures.ProjectIds = append(fixtures.ProjectIds, addedProject.Id)
+		fixtures.TaskIds = append(fixtures.TaskIds, addedProject.TaskIds...)
+	}
+
+	return fixtures, nil
+}
+
+// Remove deletes all projects of the fixtures including their tasks.
+func (f *Fixtures) Remove(c *Client) error {
+	for _, projectId := range f.ProjectIds {
+		err := c.request(http.MethodDelete, "/projects/"+projectId, nil, nil)
+		if err != nil {
+			return errors.Wrapf(err, "error removing project %s", projectId)
+		}
+	}
+	return nil
+}
+
+// GetProjects requests all projects of the user.
+func (c *Client) GetProjects() error {
+	return c.request(http.MethodGet, "/projects", nil, nil)
+}
+
+// GetTasks requests all tasks of the project.
+func (c *Client) GetTasks(projectId string) error {
+	return c.request(http.MethodGet, "/projects/"+projectId+"/tasks", nil, nil)
+}
+
+// SetProcessPoints sets the process points of the task. The points have to be less than the maximum process points of
+// the fixtures (which is 100), otherwise the task gets completed.
+func (c *Client) SetProcessPoints(taskId string, points int) error {
+	return c.request(http.MethodPost, fmt.Sprintf("/tasks/%s/processPoints?process_points=%d", taskId, points), nil, nil)
+}
+
+// Run sends the configured number of requests to each hot endpoint and measures their latencies. The projects and
+// tasks of the fixtures are used in turn, so that not all requests hit the same rows.
+func Run(c *Client, fixtures *Fixtures, options Options) ([]*Result, error) {
+	if len(fixtures.ProjectIds) == 0 || len(fixtures.TaskIds) == 0 {
+		return nil, errors.New("load test needs at least one project with one task")
+	}
+
+	requests := map[string]func(i int) error{
+		EndpointGetProjects: func(i int) error {
+			return c.GetProjects()
+		},
+		EndpointGetTasks: func(i int) error {
+			return c.GetTasks(fixtures.ProjectIds[i%len(fixtures.ProjectIds)])
+		},
+		EndpointSetProcessPoints: func(i int) error {
+			return c.SetProcessPoints(fixtures.TaskIds[i%len(fixtures.TaskIds)], i%(maxProcessPoints-1)+1)
+		},
+	}
+
+	results := make([]*Result, 0, len(requests))
+	for _, endpoint := range []string{EndpointGetProjects, EndpointGetTasks, EndpointSetProcessPoints} {
+		results = append(results, measure(endpoint, requests[endpoint], options.Requests, options.Concurrency))
+	}
+	return results, nil
+}
+
+// measure calls the request function the given number of times with the given number of goroutines.
+func measure(endpoint string, request func(i int) error, requests int, concurrency int) *Result {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	result := &Result{
+		Endpoint:  endpoint,
+		Latencies: make([]time.Duration, 0, requests),
+	}
+	resultMutex := &sync.Mutex{}
+
+	indices := make(chan int)
+	waitGroup := &sync.WaitGroup{}
+	start := time.Now()
+
+	for g := 0; g < concurrency; g++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for i := range indices {
+				requestStart := time.Now()
+				err := request(i)
+				latency := time.Since(requestStart)
+
+				resultMutex.Lock()
+				result.Latencies = append(result.Latencies, latency)
+				if err != nil {
+					result.Errors++
+					if result.FirstError == nil {
+						result.FirstError = err
+					}
+				}
+				resultMutex.Unlock()
+			}
+		}()
+	}
+
+	for i := 0; i < requests; i++ {
+		indices <- i
+	}
+	close(indices)
+	waitGroup.Wait()
+
+	result.Duration = time.Since(start)
+	sort.Slice(result.Latencies, func(i, j int) bool {
+		return result.Latencies[i] < result.Latencies[j]
+	})
+
+	return result
+}
+
+// Throughput returns the requests per second.
+func (r *Result) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(len(r.Latencies)) / r.Duration.Seconds()
+}
+
+// Percentile returns the latency below which the given percentage (0 to 100) of the requests were answered.
+func (r *Result) Percentile(percentage float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+
+	index := int(math.Ceil(float64(len(r.Latencies))*percentage/100)) - 1
+	if index < 0 {
+		index = 0
+	} else if index >= len(r.Latencies) {
+		index = len(r.Latencies) - 1
+	}
+	return r.Latencies[index]
+}
+
+func (r *Result) String() string {
+	return fmt.Sprintf("%-30s %6d requests %4d errors %8.1f req/s   p50 %-10v p95 %-10v p99 %-10v max %v",
+		r.Endpoint, len(r.Latencies), r.Errors, r.Throughput(),
+		r.Percentile(50).Round(time.Microsecond), r.Percentile(95).Round(time.Microsecond),
+		r.Percentile(99).Round(time.Microsecond), r.Percentile(100).Round(time.Microsecond))
+}
+
+func squareGeometry(x float64, y float64) string {
+	return fmt.Sprintf(`{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[%g,%g],[%g,%g],[%g,%g],[%g,%g],[%g,%g]]]},"properties":null}`,
+		x, y, x+taskSize, y, x+taskSize, y+taskSize, x, y+taskSize, x, y)
+}
@@ -0,0 +1,134 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCreateFixturesAndRun(t *testing.T) {
+	requestCounts := make(map[string]int)
+	mutex := &sync.Mutex{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if r.Header.Get("Authorization") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		path := r.URL.Path
+		switch {
+		case r.Method == http.MethodPost && path == "/v2.4/projects":
+			var body struct {
+				Tasks []map[string]interface{} `json:"tasks"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+
+			requestCounts["add"]++
+			taskIds := make([]string, len(body.Tasks))
+			for i := range taskIds {
+				taskIds[i] = "t" + string(rune('a'+i))
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "p" + string(rune('0'+requestCounts["add"])), "taskIds": taskIds})
+		case r.Method == http.MethodGet && path == "/v2.4/projects":
+			requestCounts[EndpointGetProjects]++
+		case r.Method == http.MethodGet && strings.HasSuffix(path, "/tasks"):
+			requestCounts[EndpointGetTasks]++
+		case r.Method == http.MethodPost && strings.HasSuffix(path, "/processPoints"):
+			requestCounts[EndpointSetProcessPoints]++
+			if r.FormValue("process_points") == "100" {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		case r.Method == http.MethodDelete:
+			requestCounts["remove"]++
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", "token", server.Client())
+
+	fixtures, err := CreateFixtures(client, "Peter", 2, 3)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(fixtures.ProjectIds) != 2 || fixtures.ProjectIds[0] != "p1" || fixtures.ProjectIds[1] != "p2" {
+		t.Errorf("Two projects should be added: %v", fixtures.ProjectIds)
+	}
+	if len(fixtures.TaskIds) != 6 {
+		t.Errorf("Six tasks should be added: %v", fixtures.TaskIds)
+	}
+
+	results, err := Run(client, fixtures, Options{Requests: 150, Concurrency: 4})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(results) != 3 {
+		t.Errorf("There should be a result for each of the three endpoints but got %d", len(results))
+		return
+	}
+	for _, result := range results {
+		if len(result.Latencies) != 150 || requestCounts[result.Endpoint] != 150 {
+			t.Errorf("%s should be requested 150 times but result has %d latencies and server got %d requests", result.Endpoint, len(result.Latencies), requestCounts[result.Endpoint])
+		}
+		if result.Errors != 0 || result.FirstError != nil {
+			t.Errorf("%s should have no errors (process points must never be the maximum) but had %d: %v", result.Endpoint, result.Errors, result.FirstError)
+		}
+		if result.Throughput() <= 0 {
+			t.Errorf("Throughput of %s should be positive", result.Endpoint)
+		}
+	}
+
+	err = fixtures.Remove(client)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if requestCounts["remove"] != 2 {
+		t.Errorf("Both projects should be removed but got %d removals", requestCounts["remove"])
+	}
+
+	_, err = Run(client, &Fixtures{}, Options{Requests: 1})
+	if err == nil {
+		t.Errorf("Running without fixtures should not be possible")
+	}
+
+	_, err = CreateFixtures(NewClient(server.URL, "wrong token", server.Client()), "Peter", 1, 1)
+	if err == nil {
+		t.Errorf("Failed requests should result in an error")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	result := &Result{Latencies: make([]time.Duration, 200)}
+	for i := range result.Latencies {
+		result.Latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	for percentage, expected := range map[float64]time.Duration{
+		0:   1 * time.Millisecond,
+		50:  100 * time.Millisecond,
+		95:  190 * time.Millisecond,
+		99:  198 * time.Millisecond,
+		100: 200 * time.Millisecond,
+	} {
+		if result.Percentile(percentage) != expected {
+			t.Errorf("Percentile %v should be %v but was %v", percentage, expected, result.Percentile(percentage))
+		}
+	}
+
+	if (&Result{}).Percentile(50) != 0 {
+		t.Errorf("Percentile without latencies should be 0")
+	}
+}
@@ -0,0 +1,70 @@
+package mail
+
+import (
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"strings"
+
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Enabled returns true when a SMTP server is configured. When this is false, nothing can be sent.
+func Enabled() bool {
+	return config.Conf.SmtpHost != ""
+}
+
+// Send sends a plain text mail with the given subject and body to the given recipient.
+func Send(logger *util.Logger, to string, subject string, body string) error {
+	if !Enabled() {
+		return errors.New("sending mails is not possible, no SMTP server configured")
+	}
+
+	address := fmt.Sprintf("%s:%d", config.Conf.SmtpHost, config.Conf.SmtpPort)
+
+	var auth smtp.Auth
+	if config.Conf.SmtpUsername != "" {
+		auth = smtp.PlainAuth("", config.Conf.SmtpUsername, config.Conf.SmtpPassword, config.Conf.SmtpHost)
+	}
+
+	message, err := buildMessage(config.Conf.MailFrom, to, subject, body)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("Send mail '%s' to %s", subject, to)
+	err = smtp.SendMail(address, auth, config.Conf.MailFrom, []string{to}, message)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("error sending mail to %s", to))
+	}
+
+	return nil
+}
+
+// buildMessage creates the mail with its header. The recipient and subject may contain user-controlled data (e.g. the
+// project name), so line breaks are not allowed there. Otherwise they could add further headers like "Bcc". Non-ASCII
+// subjects are encoded according to RFC 2047.
+func buildMessage(from string, to string, subject string, body string) ([]byte, error) {
+	if strings.ContainsAny(to, "\r\n") {
+		return nil, errors.New(fmt.Sprintf("invalid recipient '%s'", strings.TrimSpace(to)))
+	}
+	_, err := mail.ParseAddress(to)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid recipient '%s'", to)
+	}
+
+	subject = strings.Join(strings.FieldsFunc(subject, func(r rune) bool { return r == '\r' || r == '\n' }), " ")
+
+	header := []string{
+		"From: " + from,
+		"To: " + to,
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"utf-8\"",
+	}
+
+	return []byte(strings.Join(header, "\r\n") + "\r\n\r\n" + body), nil
+}
@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildMessage(t *testing.T) {
+	message, err := buildMessage("stm@example.com", "peter@example.com", "Weekly digest of Buildings", "Hello")
+	if err != nil {
+		t.Errorf("Building the message should work: %s", err.Error())
+		return
+	}
+	expected := "From: stm@example.com\r\nTo: peter@example.com\r\nSubject: Weekly digest of Buildings\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=\"utf-8\"\r\n\r\nHello"
+	if string(message) != expected {
+		t.Errorf("Message '%s' does not match '%s'", message, expected)
+	}
+}
+
+func TestBuildMessageHeaderInjection(t *testing.T) {
+	message, err := buildMessage("stm@example.com", "peter@example.com", "Buildings\r\nBcc: eve@example.com", "Hello")
+	if err != nil {
+		t.Errorf("Line breaks in the subject should be removed: %s", err.Error())
+		return
+	}
+	if strings.Contains(string(message), "\r\nBcc:") {
+		t.Errorf("Subject must not add headers: %s", message)
+	}
+
+	_, err = buildMessage("stm@example.com", "peter@example.com\r\nBcc: eve@example.com", "Digest", "Hello")
+	if err == nil {
+		t.Errorf("Line breaks in the recipient should not be possible")
+	}
+
+	_, err = buildMessage("stm@example.com", "not an address", "Digest", "Hello")
+	if err == nil {
+		t.Errorf("Invalid recipients should not be possible")
+	}
+}
+
+func TestBuildMessageEncodedSubject(t *testing.T) {
+	message, err := buildMessage("stm@example.com", "peter@example.com", "Gebäude in Köln", "Hallo")
+	if err != nil {
+		t.Errorf("Building the message should work: %s", err.Error())
+		return
+	}
+	if !strings.Contains(string(message), "\r\nSubject: =?utf-8?q?Geb=C3=A4ude_in_K=C3=B6ln?=\r\n") {
+		t.Errorf("Non-ASCII subject should be encoded: %s", message)
+	}
+}
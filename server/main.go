@@ -1,22 +1,55 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"github.com/hauke96/kingpin"
 	"github.com/hauke96/sigolo"
 	_ "github.com/lib/pq" // Make driver "postgres" usable
+	"net/http"
 	"os"
+	"time"
 
+	"github.com/hauke96/simple-task-manager/server/account"
 	"github.com/hauke96/simple-task-manager/server/api"
 	"github.com/hauke96/simple-task-manager/server/auth"
 	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/digest"
+	"github.com/hauke96/simple-task-manager/server/export"
+	"github.com/hauke96/simple-task-manager/server/feature"
+	"github.com/hauke96/simple-task-manager/server/loadtest"
+	"github.com/hauke96/simple-task-manager/server/osm"
+	"github.com/hauke96/simple-task-manager/server/outbox"
+	"github.com/hauke96/simple-task-manager/server/privacy"
+	"github.com/hauke96/simple-task-manager/server/project"
+	"github.com/hauke96/simple-task-manager/server/push"
+	"github.com/hauke96/simple-task-manager/server/retention"
+	"github.com/hauke96/simple-task-manager/server/scheduler"
+	"github.com/hauke96/simple-task-manager/server/secret"
+	"github.com/hauke96/simple-task-manager/server/session"
+	"github.com/hauke96/simple-task-manager/server/storage"
+	"github.com/hauke96/simple-task-manager/server/task"
+	"github.com/hauke96/simple-task-manager/server/usage"
 	"github.com/hauke96/simple-task-manager/server/util"
 )
 
 var (
-	app       = kingpin.New("Simple Task Manager", "A tool dividing an area of the map into smaller tasks.")
-	appConfig = app.Flag("config", "The config file. CLI argument override the settings from that file.").Short('c').Default("./config/default.json").String()
+	app           = kingpin.New("Simple Task Manager", "A tool dividing an area of the map into smaller tasks.")
+	appConfig     = app.Flag("config", "The config file. CLI argument override the settings from that file.").Short('c').Default("./config/default.json").String()
+	appAddAccount = app.Flag("add-account", "Adds a local account with the given ID, prints its login key and exits. Used to create the first admin account of instances with the 'local' auth backend.").String()
+	appVapidKey   = app.Flag("generate-vapid-key", "Prints a new key pair for push messages and exits. The private key is meant for the STM_VAPID_PRIVATE_KEY environment variable.").Bool()
+
+	appLoadTest        = app.Flag("load-test", "Serves the API locally, adds projects for a load test user, measures the latency of the most used endpoints, removes the projects again and exits. Don't use this on the production database.").Bool()
+	appLoadProjects    = app.Flag("load-projects", "Number of projects added by the load test.").Default("10").Int()
+	appLoadTasks       = app.Flag("load-tasks", "Number of tasks per project added by the load test.").Default("100").Int()
+	appLoadRequests    = app.Flag("load-requests", "Number of requests per endpoint sent by the load test.").Default("1000").Int()
+	appLoadConcurrency = app.Flag("load-concurrency", "Number of requests the load test sends at the same time.").Default("10").Int()
 )
 
+// ID and name of the user owning the projects of the load test
+const loadTestUser = "load-test"
+
 func configureCliArgs() {
 	app.Author("Hauke Stieler")
 	app.Version(util.VERSION)
@@ -33,6 +66,192 @@ func configureLogging() {
 	}
 }
 
+func configureScheduler() {
+	loadFeatureFlagsJob := &scheduler.Job{
+		Name:     "load feature flags",
+		Interval: time.Minute,
+		Run:      feature.LoadOverridesJob,
+	}
+	scheduler.RunOnce(loadFeatureFlagsJob)
+	scheduler.Register(loadFeatureFlagsJob)
+
+	reencryptSubscriptionsJob := &scheduler.Job{
+		Name:     "re-encrypt push subscriptions",
+		Interval: 24 * time.Hour,
+		Run:      push.ReencryptSubscriptionsJob,
+	}
+	scheduler.RunOnce(reencryptSubscriptionsJob)
+	scheduler.Register(reencryptSubscriptionsJob)
+
+	scheduler.Register(&scheduler.Job{
+		Name:     "send digests",
+		Interval: time.Hour,
+		Run:      digest.SendDigestsJob,
+	})
+	scheduler.Register(&scheduler.Job{
+		Name:                  "dispatch outbox",
+		Interval:              time.Minute,
+		RunWithoutTransaction: outbox.DispatchJob,
+	})
+	scheduler.Register(&scheduler.Job{
+		Name:     "record project snapshots",
+		Interval: time.Hour,
+		Run:      project.RecordSnapshotsJob,
+	})
+	scheduler.Register(&scheduler.Job{
+		Name:     "repair project progress",
+		Interval: 24 * time.Hour,
+		Run:      project.RepairProgressJob,
+	})
+	scheduler.Register(&scheduler.Job{
+		Name:     "check consistency",
+		Interval: 24 * time.Hour,
+		Run:      project.CheckConsistencyJob(config.Conf.RepairInconsistencies),
+	})
+	scheduler.Register(&scheduler.Job{
+		Name:     "flush API usage",
+		Interval: time.Minute,
+		Run:      usage.FlushJob,
+	})
+	scheduler.Register(&scheduler.Job{
+		Name:     "update changesets",
+		Interval: 10 * time.Minute,
+		Run:      task.UpdateChangesetsJob,
+	})
+	scheduler.Register(&scheduler.Job{
+		Name:     "update OSM notes",
+		Interval: 10 * time.Minute,
+		Run:      task.UpdateOsmNotesJob,
+	})
+	scheduler.Register(&scheduler.Job{
+		Name:                  "look up task localities",
+		Interval:              5 * time.Minute,
+		RunWithoutTransaction: task.UpdateLocalitiesJob,
+	})
+	scheduler.Register(&scheduler.Job{
+		Name:     "unassign inactive tasks",
+		Interval: 10 * time.Minute,
+		Run:      task.UnassignInactiveTasksJob,
+	})
+	scheduler.Register(&scheduler.Job{
+		Name:     "warn about expiring assignments",
+		Interval: 10 * time.Minute,
+		Run:      task.WarnExpiringAssignmentsJob,
+	})
+	scheduler.Register(&scheduler.Job{
+		Name:     "remove expired task reservations",
+		Interval: 10 * time.Minute,
+		Run:      task.RemoveExpiredReservationsJob,
+	})
+
+	exportRetention, err := time.ParseDuration(config.Conf.ExportRetention)
+	sigolo.FatalCheckf(err, "unable to parse export retention from config entry '%s'", config.Conf.ExportRetention)
+
+	scheduler.Register(&scheduler.Job{
+		Name:     "build exports",
+		Interval: 10 * time.Second,
+		Run:      export.BuildJob(exportRetention),
+	})
+	scheduler.Register(&scheduler.Job{
+		Name:     "remove expired exports",
+		Interval: time.Hour,
+		Run:      export.CleanupJob,
+	})
+
+	scheduler.Register(&scheduler.Job{
+		Name:     "apply retention policies",
+		Interval: 24 * time.Hour,
+		Run:      retention.ApplyPoliciesJob,
+	})
+
+	if config.Conf.ArchiveGracePeriod != "" {
+		gracePeriod, err := time.ParseDuration(config.Conf.ArchiveGracePeriod)
+		sigolo.FatalCheckf(err, "unable to parse archive grace period from config entry '%s'", config.Conf.ArchiveGracePeriod)
+
+		scheduler.Register(&scheduler.Job{
+			Name:     "archive completed projects",
+			Interval: time.Hour,
+			Run:      project.ArchiveCompletedProjectsJob(gracePeriod),
+		})
+	}
+}
+
+// addLocalAccount creates an account without API call, since adding accounts via the API already needs an admin account.
+func addLocalAccount(accountId string) {
+	logger := util.NewLogger()
+	ctx := context.Background()
+
+	tx, err := database.GetTransaction(ctx, logger)
+	sigolo.FatalCheck(err)
+
+	addedAccount, err := account.Init(ctx, tx, logger, session.Init(ctx, tx, logger)).AddAccount(accountId, "cli")
+	if err != nil {
+		tx.Rollback()
+		sigolo.FatalCheck(err)
+	}
+
+	err = tx.Commit()
+	sigolo.FatalCheck(err)
+
+	// The key is written to stdout instead of the log, because it's a secret
+	fmt.Printf("Added account %s with login key: %s\n", addedAccount.Id, addedAccount.Key)
+}
+
+// generateVapidKey prints a new key pair without any further setup, since it's needed before the server is configured.
+func generateVapidKey() {
+	privateKey, publicKey, err := push.GenerateKey()
+	sigolo.FatalCheck(err)
+
+	// The key is written to stdout instead of the log, because it's a secret
+	fmt.Printf("Private key: %s\nPublic key:  %s\n", privateKey, publicKey)
+}
+
+// runLoadTest measures the performance of the API with the configured database. The scheduler doesn't run, so that the
+// jobs don't distort the results.
+func runLoadTest() {
+	server, url, err := api.ServeLocal()
+	sigolo.FatalCheck(err)
+	defer server.Close()
+
+	token, err := auth.CreateToken(util.NewLogger(), loadTestUser, loadTestUser, nil)
+	sigolo.FatalCheck(err)
+	client := loadtest.NewClient(url, token, &http.Client{})
+
+	options := loadtest.Options{
+		Projects:        *appLoadProjects,
+		TasksPerProject: *appLoadTasks,
+		Requests:        *appLoadRequests,
+		Concurrency:     *appLoadConcurrency,
+	}
+
+	// The log of every single request would distort the results
+	sigolo.LogLevel = sigolo.LOG_ERROR
+
+	start := time.Now()
+	fixtures, err := loadtest.CreateFixtures(client, loadTestUser, options.Projects, options.TasksPerProject)
+	if err != nil {
+		fixtures.Remove(client)
+		sigolo.FatalCheck(err)
+	}
+	fmt.Printf("Added %d projects with %d tasks each in %v\n", options.Projects, options.TasksPerProject, time.Since(start))
+
+	results, err := loadtest.Run(client, fixtures, options)
+	if err != nil {
+		fixtures.Remove(client)
+		sigolo.FatalCheck(err)
+	}
+
+	for _, result := range results {
+		fmt.Println(result.String())
+		if result.FirstError != nil {
+			fmt.Printf("    first error: %s\n", result.FirstError.Error())
+		}
+	}
+
+	err = fixtures.Remove(client)
+	sigolo.FatalCheck(err)
+}
+
 func main() {
 	sigolo.Info("Init simple-task-manager server v" + util.VERSION)
 
@@ -40,6 +259,11 @@ func main() {
 	_, err := app.Parse(os.Args[1:])
 	sigolo.FatalCheck(err)
 
+	if *appVapidKey {
+		generateVapidKey()
+		return
+	}
+
 	// Load config an override with CLI args
 	config.LoadConfig(*appConfig)
 	config.PrintConfig()
@@ -47,9 +271,55 @@ func main() {
 	configureLogging()
 
 	// Init of Config, Services, Storages, etc.
+	database.Init()
+	err = storage.SetDriver(config.Conf.StoreDriver)
+	sigolo.FatalCheck(err)
+
+	if *appAddAccount != "" {
+		addLocalAccount(*appAddAccount)
+		return
+	}
+
+	osm.Init()
 	auth.Init()
+
+	err = project.SetStatusThresholds(config.Conf.StatusInProgress, config.Conf.StatusNearlyDone)
+	sigolo.FatalCheck(err)
+	revertWindow, err := time.ParseDuration(config.Conf.RevertWindow)
+	sigolo.FatalCheckf(err, "unable to parse revert window from config entry '%s'", config.Conf.RevertWindow)
+	err = project.SetRevertWindow(revertWindow)
+	sigolo.FatalCheck(err)
+	reservationDuration, err := time.ParseDuration(config.Conf.ReservationDuration)
+	sigolo.FatalCheckf(err, "unable to parse reservation duration from config entry '%s'", config.Conf.ReservationDuration)
+	err = task.SetReservationDuration(reservationDuration)
+	sigolo.FatalCheck(err)
+	err = project.SetLimits(project.Limits{
+		MaxDescriptionLength: config.Conf.MaxDescriptionLength,
+		MaxNameLength:        config.Conf.MaxNameLength,
+		MaxTasks:             config.Conf.MaxTasksPerProject,
+		MaxUsers:             config.Conf.MaxUsersPerProject,
+	})
+	sigolo.FatalCheck(err)
+	err = feature.Configure(config.Conf.FeatureFlags)
+	sigolo.FatalCheck(err)
+	err = retention.Configure(config.Conf.RetentionDays)
+	sigolo.FatalCheck(err)
+	err = privacy.Configure(config.Conf.AnonymizeUsers, config.Conf.PseudonymKey)
+	sigolo.FatalCheck(err)
+	err = secret.Configure(config.Conf.EncryptionKeys)
+	sigolo.FatalCheck(err)
+	err = push.Configure(config.Conf.VapidPrivateKey, config.Conf.PushSubject)
+	sigolo.FatalCheck(err)
 	sigolo.Info("Initializes services, storages, etc.")
 
+	if *appLoadTest {
+		runLoadTest()
+		return
+	}
+
+	configureScheduler()
+	scheduler.Start()
+
 	err = api.Init()
 	if err != nil {
 		sigolo.Stack(err)
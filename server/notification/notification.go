@@ -0,0 +1,231 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/outbox"
+	"github.com/hauke96/simple-task-manager/server/push"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Types of notifications
+const (
+	TypeProjectUserAdded   = "project_user_added"
+	TypeProjectUserRemoved = "project_user_removed"
+	TypeTaskReopened       = "task_reopened"
+	TypeAssignmentExpiring = "assignment_expiring"
+	TypeProjectProgress    = "project_progress"
+)
+
+// Maximum number of notifications returned at once, older ones are only interesting in rare cases
+const maxNotifications = 100
+
+type Notification struct {
+	Id          string    `json:"id"`
+	UserId      string    `json:"userId"`
+	Type        string    `json:"type"`
+	ProjectId   string    `json:"projectId"`
+	ProjectName string    `json:"projectName"` // Stored separately, so that the name is known after a removal or deletion of the project
+	Actor       string    `json:"actor"`       // ID of the user who caused this notification, e.g. the owner adding the user to a project
+	TaskId      string    `json:"taskId"`      // Only set for notifications about a task
+	Comment     string    `json:"comment"`     // E.g. the reason why a task has been reopened, the time an expiring assignment ends or the percentage of the progress
+	CreatedAt   time.Time `json:"createdAt"`
+	Read        bool      `json:"read"`
+}
+
+// PushPayload is sent as push message for every new notification to all browsers the user subscribed with.
+type PushPayload struct {
+	Title        string        `json:"title"`
+	Body         string        `json:"body"`
+	Notification *Notification `json:"notification"`
+}
+
+// Comments in push messages are shortened to this, since the payload of push messages is limited
+const maxPushCommentLength = 500
+
+type NotificationService struct {
+	*util.Logger
+	store         *storePg
+	pushService   *push.PushService
+	outboxService *outbox.OutboxService
+}
+
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger) *NotificationService {
+	return &NotificationService{
+		Logger:        logger,
+		store:         getStore(ctx, tx, logger),
+		pushService:   push.Init(ctx, tx, logger),
+		outboxService: outbox.Init(ctx, tx, logger),
+	}
+}
+
+// AddProjectMembershipNotification adds a notification about being added to or removed from the project to the inbox of
+// the given user. There's no permission check, this is only called after the membership has been changed.
+func (s *NotificationService) AddProjectMembershipNotification(userId string, notificationType string, projectId string, projectName string, actor string) (*Notification, error) {
+	if notificationType != TypeProjectUserAdded && notificationType != TypeProjectUserRemoved {
+		return nil, errors.New(fmt.Sprintf("notification type '%s' is no membership change", notificationType))
+	}
+
+	notification, err := s.store.addNotification(userId, notificationType, projectId, projectName, "", actor, "")
+	if err != nil {
+		return nil, err
+	}
+
+	s.Log("Added notification %s of type '%s' for user %s", notification.Id, notificationType, userId)
+
+	return notification, s.addPushMessages(notification)
+}
+
+// AddTaskReopenedNotification tells the user, who completed the task, that the task has been reopened and why. There's
+// no permission check, this is only called after the task has been reopened.
+func (s *NotificationService) AddTaskReopenedNotification(userId string, projectId string, projectName string, taskId string, actor string, reason string) (*Notification, error) {
+	notification, err := s.store.addNotification(userId, TypeTaskReopened, projectId, projectName, taskId, actor, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Log("Added notification %s about reopened task %s for user %s", notification.Id, taskId, userId)
+
+	return notification, s.addPushMessages(notification)
+}
+
+// AddAssignmentExpiringNotification warns the user, that the assignment to the task ends at the given time due to
+// missing progress (see "task.UnassignInactiveTasks"). The user is only warned once per assignment, so nothing is added
+// and nil is returned when there's already a warning since the assignment.
+func (s *NotificationService) AddAssignmentExpiringNotification(userId string, projectId string, projectName string, taskId string, assignedAt time.Time, unassignAt time.Time) (*Notification, error) {
+	warned, err := s.store.hasTaskNotification(userId, TypeAssignmentExpiring, taskId, assignedAt)
+	if err != nil {
+		return nil, err
+	}
+	if warned {
+		return nil, nil
+	}
+
+	notification, err := s.store.addNotification(userId, TypeAssignmentExpiring, projectId, projectName, taskId, "", unassignAt.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Log("Added notification %s about expiring assignment of task %s for user %s", notification.Id, taskId, userId)
+
+	return notification, s.addPushMessages(notification)
+}
+
+// AddProjectProgressNotification tells a watcher of the project, that the project reached the given progress (in
+// percent). There's no permission check, this is only called for watchers of public projects.
+func (s *NotificationService) AddProjectProgressNotification(userId string, projectId string, projectName string, percent int) (*Notification, error) {
+	notification, err := s.store.addNotification(userId, TypeProjectProgress, projectId, projectName, "", "", strconv.Itoa(percent))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Log("Added notification %s about %d%% progress of project %s for user %s", notification.Id, percent, projectId, userId)
+
+	return notification, s.addPushMessages(notification)
+}
+
+// addPushMessages adds a push message about the notification for every browser the user subscribed with to the
+// outbox. Nothing happens when push messages are disabled.
+func (s *NotificationService) addPushMessages(notification *Notification) error {
+	if !push.Enabled() {
+		return nil
+	}
+
+	subscriptions, err := s.pushService.GetSubscriptions(notification.UserId)
+	if err != nil {
+		return err
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	payload, err := getPushPayload(notification)
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		err = s.outboxService.AddPush(subscription, notification.Type, payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getPushPayload returns the notification and an english text describing it as JSON, so that browsers can show the
+// text without knowing all notification types.
+func getPushPayload(notification *Notification) (string, error) {
+	n := *notification
+	if len(n.Comment) > maxPushCommentLength {
+		n.Comment = n.Comment[:maxPushCommentLength]
+	}
+
+	payload := &PushPayload{Notification: &n}
+	switch n.Type {
+	case TypeProjectUserAdded:
+		payload.Title = "Added to project"
+		payload.Body = fmt.Sprintf("%s added you to the project '%s'", n.Actor, n.ProjectName)
+	case TypeProjectUserRemoved:
+		payload.Title = "Removed from project"
+		payload.Body = fmt.Sprintf("%s removed you from the project '%s'", n.Actor, n.ProjectName)
+	case TypeTaskReopened:
+		payload.Title = "Task reopened"
+		payload.Body = fmt.Sprintf("%s reopened task %s of the project '%s': %s", n.Actor, n.TaskId, n.ProjectName, n.Comment)
+	case TypeAssignmentExpiring:
+		payload.Title = "Assignment expires soon"
+		payload.Body = fmt.Sprintf("Without progress, you'll be unassigned from task %s of the project '%s' at %s", n.TaskId, n.ProjectName, n.Comment)
+	case TypeProjectProgress:
+		payload.Title = "Project progress"
+		payload.Body = fmt.Sprintf("The project '%s' is %s%% done", n.ProjectName, n.Comment)
+	default:
+		payload.Title = "New notification"
+		payload.Body = fmt.Sprintf("New notification about the project '%s'", n.ProjectName)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to create push payload")
+	}
+	if len(data) > push.MaxPayloadSize {
+		return "", errors.New(fmt.Sprintf("push payload of notification %s is too large", n.Id))
+	}
+
+	return string(data), nil
+}
+
+// GetNotifications returns the latest notifications of the user, newest first.
+func (s *NotificationService) GetNotifications(userId string, unreadOnly bool) ([]*Notification, error) {
+	return s.store.getNotifications(userId, unreadOnly, maxNotifications)
+}
+
+// MarkRead marks the notification as read. Users can only mark their own notifications.
+func (s *NotificationService) MarkRead(notificationId string, userId string) (*Notification, error) {
+	notification, err := s.store.markRead(notificationId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Log("Marked notification %s as read", notificationId)
+
+	return notification, nil
+}
+
+// MarkAllRead marks all unread notifications of the user as read.
+func (s *NotificationService) MarkAllRead(userId string) error {
+	err := s.store.markAllRead(userId)
+	if err != nil {
+		return err
+	}
+
+	s.Log("Marked all notifications of user %s as read", userId)
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+type storePg struct {
+	*util.Logger
+	ctx   context.Context
+	tx    *sql.Tx
+	table database.Table
+}
+
+var (
+	returnValues = "id, user_id, type, project_id, project_name, COALESCE(task_id::TEXT, ''), actor, comment, created_at, read_at IS NOT NULL"
+)
+
+func getStore(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
+	return &storePg{
+		Logger: logger,
+		ctx:    ctx,
+		tx:     tx,
+		table:  database.TableNotifications,
+	}
+}
+
+// addNotification stores a new notification. The empty task ID is stored as NULL, since most notifications are not
+// about a single task.
+func (s *storePg) addNotification(userId string, notificationType string, projectId string, projectName string, taskId string, actor string, comment string) (*Notification, error) {
+	query := fmt.Sprintf("INSERT INTO %s(user_id, type, project_id, project_name, task_id, actor, comment) VALUES($1, $2, $3, $4, NULLIF($5, '')::INT, $6, $7) RETURNING %s;", s.table, returnValues)
+	return s.execQuery(query, userId, notificationType, projectId, projectName, taskId, actor, comment)
+}
+
+// hasTaskNotification returns true when the user got a notification of the given type about the task since the given
+// time.
+func (s *storePg) hasTaskNotification(userId string, notificationType string, taskId string, since time.Time) (bool, error) {
+	query := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE user_id=$1 AND type=$2 AND task_id=$3 AND created_at >= $4);", s.table)
+	s.LogQuery(query, userId, notificationType, taskId, since)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+
+	var exists bool
+	err := s.tx.QueryRowContext(ctx, query, userId, notificationType, taskId, since).Scan(&exists)
+	if err != nil {
+		return false, errors.Wrapf(err, "error checking notifications of user %s about task %s", userId, taskId)
+	}
+
+	return exists, nil
+}
+
+func (s *storePg) getNotifications(userId string, unreadOnly bool, limit int) ([]*Notification, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE user_id=$1 AND (NOT $2 OR read_at IS NULL) ORDER BY created_at DESC, id DESC LIMIT $3;", returnValues, s.table)
+	return s.execQueryForList(query, userId, unreadOnly, limit)
+}
+
+// markRead sets the read date of the notification, when it belongs to the user and hasn't been read yet. Already read
+// notifications are returned without change.
+func (s *storePg) markRead(notificationId string, userId string) (*Notification, error) {
+	query := fmt.Sprintf("UPDATE %s SET read_at=COALESCE(read_at, NOW()) WHERE id=$1 AND user_id=$2 RETURNING %s;", s.table, returnValues)
+	notification, err := s.execQuery(query, notificationId, userId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not mark notification %s of user %s as read", notificationId, userId)
+	}
+	return notification, nil
+}
+
+func (s *storePg) markAllRead(userId string) error {
+	query := fmt.Sprintf("UPDATE %s SET read_at=NOW() WHERE user_id=$1 AND read_at IS NULL;", s.table)
+	s.LogQuery(query, userId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, userId)
+	if err != nil {
+		return errors.Wrapf(err, "error marking notifications of user %s as read", userId)
+	}
+
+	return nil
+}
+
+// execQuery executes the query and returns the one notification it affected.
+func (s *storePg) execQuery(query string, params ...interface{}) (*Notification, error) {
+	notifications, err := s.execQueryForList(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(notifications) != 1 {
+		return nil, errors.New(fmt.Sprintf("expected exactly one notification but got %d", len(notifications)))
+	}
+
+	return notifications[0], nil
+}
+
+func (s *storePg) execQueryForList(query string, params ...interface{}) ([]*Notification, error) {
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not run notification query")
+	}
+	defer rows.Close()
+
+	result := make([]*Notification, 0)
+	for rows.Next() {
+		var id int
+		var projectId int
+		n := &Notification{}
+
+		err = rows.Scan(&id, &n.UserId, &n.Type, &projectId, &n.ProjectName, &n.TaskId, &n.Actor, &n.Comment, &n.CreatedAt, &n.Read)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan notification")
+		}
+
+		n.Id = strconv.Itoa(id)
+		n.ProjectId = strconv.Itoa(projectId)
+		result = append(result, n)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,244 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/test"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq" // Make driver "postgres" usable
+)
+
+var (
+	tx *sql.Tx
+	s  *NotificationService
+	h  *test.TestHelper
+)
+
+func TestMain(m *testing.M) {
+	h = &test.TestHelper{
+		Setup: setup,
+	}
+
+	m.Run()
+}
+
+func setup() {
+	config.LoadConfig("../config/test.json")
+	test.InitWithDummyData()
+	sigolo.LogLevel = sigolo.LOG_DEBUG
+
+	logger := util.NewLogger()
+
+	ctx := context.Background()
+
+	var err error
+	tx, err = database.GetTransaction(ctx, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	h.Tx = tx
+	s = Init(ctx, tx, logger)
+}
+
+func TestAddAndGetNotifications(t *testing.T) {
+	h.Run(t, func() error {
+		added, err := s.AddProjectMembershipNotification("John", TypeProjectUserAdded, "1", "Project 1", "Peter")
+		if err != nil {
+			return err
+		}
+		if added.UserId != "John" || added.ProjectId != "1" || added.ProjectName != "Project 1" || added.Actor != "Peter" || added.Read {
+			return errors.New(fmt.Sprintf("Added notification does not match: %#v", added))
+		}
+
+		_, err = s.AddProjectMembershipNotification("John", TypeProjectUserRemoved, "1", "Project 1", "Peter")
+		if err != nil {
+			return err
+		}
+
+		_, err = s.AddProjectMembershipNotification("John", "foo", "1", "Project 1", "Peter")
+		if err == nil {
+			return errors.New("Adding notification with unknown type should not work")
+		}
+
+		notifications, err := s.GetNotifications("John", false)
+		if err != nil {
+			return err
+		}
+		if len(notifications) != 2 || notifications[0].Type != TypeProjectUserRemoved || notifications[1].Type != TypeProjectUserAdded {
+			return errors.New(fmt.Sprintf("Notifications should be sorted newest first: %#v", notifications))
+		}
+
+		notifications, err = s.GetNotifications("Maria", false)
+		if err != nil {
+			return err
+		}
+		if len(notifications) != 0 {
+			return errors.New(fmt.Sprintf("Maria should not have notifications: %#v", notifications))
+		}
+
+		return nil
+	})
+}
+
+func TestAddTaskReopenedNotification(t *testing.T) {
+	h.Run(t, func() error {
+		added, err := s.AddTaskReopenedNotification("Clara", "2", "Project 2", "2", "Maria", "Some buildings are missing")
+		if err != nil {
+			return err
+		}
+		if added.Type != TypeTaskReopened || added.TaskId != "2" || added.Comment != "Some buildings are missing" || added.Actor != "Maria" {
+			return errors.New(fmt.Sprintf("Added notification does not match: %#v", added))
+		}
+
+		membershipNotification, err := s.AddProjectMembershipNotification("Clara", TypeProjectUserAdded, "1", "Project 1", "Peter")
+		if err != nil {
+			return err
+		}
+		if membershipNotification.TaskId != "" || membershipNotification.Comment != "" {
+			return errors.New(fmt.Sprintf("Membership notification should have no task and comment: %#v", membershipNotification))
+		}
+
+		return nil
+	})
+}
+
+func TestMarkRead(t *testing.T) {
+	h.Run(t, func() error {
+		first, err := s.AddProjectMembershipNotification("John", TypeProjectUserAdded, "1", "Project 1", "Peter")
+		if err != nil {
+			return err
+		}
+		_, err = s.AddProjectMembershipNotification("John", TypeProjectUserAdded, "2", "Project 2", "Maria")
+		if err != nil {
+			return err
+		}
+
+		// Notifications of other users can't be marked
+		_, err = s.MarkRead(first.Id, "Maria")
+		if err == nil {
+			return errors.New("Marking notification of other user should not work")
+		}
+
+		read, err := s.MarkRead(first.Id, "John")
+		if err != nil {
+			return err
+		}
+		if !read.Read {
+			return errors.New("Notification should be read")
+		}
+
+		unread, err := s.GetNotifications("John", true)
+		if err != nil {
+			return err
+		}
+		if len(unread) != 1 || unread[0].ProjectId != "2" {
+			return errors.New(fmt.Sprintf("Only notification of project 2 should be unread: %#v", unread))
+		}
+
+		err = s.MarkAllRead("John")
+		if err != nil {
+			return err
+		}
+
+		unread, err = s.GetNotifications("John", true)
+		if err != nil {
+			return err
+		}
+		if len(unread) != 0 {
+			return errors.New(fmt.Sprintf("All notifications should be read: %#v", unread))
+		}
+
+		return nil
+	})
+}
+
+func TestAddAssignmentExpiringNotification(t *testing.T) {
+	h.Run(t, func() error {
+		assignedAt := time.Now().Add(-3 * time.Hour)
+		unassignAt := assignedAt.Add(4 * time.Hour)
+
+		added, err := s.AddAssignmentExpiringNotification("John", "2", "Project 2", "4", assignedAt, unassignAt)
+		if err != nil {
+			return err
+		}
+		if added == nil || added.Type != TypeAssignmentExpiring || added.TaskId != "4" || added.Comment != unassignAt.UTC().Format(time.RFC3339) {
+			return errors.New(fmt.Sprintf("Added notification does not match: %#v", added))
+		}
+
+		// The user is only warned once per assignment
+		added, err = s.AddAssignmentExpiringNotification("John", "2", "Project 2", "4", assignedAt, unassignAt)
+		if err != nil {
+			return err
+		}
+		if added != nil {
+			return errors.New(fmt.Sprintf("User should not be warned twice: %#v", added))
+		}
+
+		// A new assignment gets a new warning
+		added, err = s.AddAssignmentExpiringNotification("John", "2", "Project 2", "4", time.Now().Add(time.Minute), unassignAt)
+		if err != nil {
+			return err
+		}
+		if added == nil {
+			return errors.New("User should be warned about new assignment")
+		}
+
+		return nil
+	})
+}
+
+func TestGetPushPayload(t *testing.T) {
+	notification := &Notification{
+		Id:          "1",
+		UserId:      "Clara",
+		Type:        TypeTaskReopened,
+		ProjectId:   "2",
+		ProjectName: "Project 2",
+		TaskId:      "4",
+		Actor:       "Maria",
+		Comment:     strings.Repeat("a", maxPushCommentLength+100),
+	}
+
+	payloadJson, err := getPushPayload(notification)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var payload PushPayload
+	err = json.Unmarshal([]byte(payloadJson), &payload)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if payload.Title != "Task reopened" || !strings.Contains(payload.Body, "Maria") || !strings.Contains(payload.Body, "Project 2") {
+		t.Errorf("Payload text does not match: %s", payloadJson)
+	}
+	if payload.Notification == nil || payload.Notification.Id != "1" || len(payload.Notification.Comment) != maxPushCommentLength {
+		t.Errorf("Payload should contain notification with shortened comment: %s", payloadJson)
+	}
+	if len(notification.Comment) != maxPushCommentLength+100 {
+		t.Errorf("Original notification should not be changed")
+	}
+
+	notification.Type = "unknown"
+	payloadJson, err = getPushPayload(notification)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !strings.Contains(payloadJson, "New notification") {
+		t.Errorf("Unknown types should get a generic text: %s", payloadJson)
+	}
+}
@@ -0,0 +1,250 @@
+package osm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Client performs requests against the OSM API. Responses are cached for a short time and revalidated using
+// conditional requests afterwards. Failing requests (network errors and server errors) are retried with an
+// exponential backoff. When the OSM API stays unavailable, a stale cached response is used if there is one.
+//
+// All requests (also the ones made via "HttpClient") can be rate limited and are guarded by a circuit breaker, see
+// "SetRateLimit" and "SetCircuitBreaker".
+type Client struct {
+	httpClient *http.Client
+	transport  *limitedTransport
+	cacheTtl   time.Duration
+	maxRetries int
+	retryDelay time.Duration
+
+	cache           map[string]*cacheEntry
+	cacheMutex      sync.Mutex
+	cacheEnabled    bool
+	maxCacheEntries int
+}
+
+// Maximum number of cached responses of a client. When the cache is full, the entry fetched longest ago is removed.
+const defaultMaxCacheEntries = 1000
+
+type cacheEntry struct {
+	body         []byte
+	etag         string
+	lastModified string
+	fetchedAt    time.Time
+}
+
+// RequestFactory creates a new (e.g. signed) request for every attempt, because signatures with a nonce can't be
+// reused when retrying a request.
+type RequestFactory func() (*http.Request, error)
+
+func NewClient(timeout time.Duration, cacheTtl time.Duration, maxRetries int) *Client {
+	transport := newLimitedTransport()
+
+	return &Client{
+		httpClient:      &http.Client{Timeout: timeout, Transport: transport},
+		transport:       transport,
+		cacheTtl:        cacheTtl,
+		maxRetries:      maxRetries,
+		retryDelay:      500 * time.Millisecond,
+		cache:           make(map[string]*cacheEntry),
+		cacheEnabled:    true,
+		maxCacheEntries: defaultMaxCacheEntries,
+	}
+}
+
+// SetRateLimit limits the number of requests running at the same time and ensures the given minimum interval between
+// two requests. Further requests are queued and fail when they can't be started within the queue timeout.
+func (c *Client) SetRateLimit(maxParallelRequests int, interval time.Duration, queueTimeout time.Duration) {
+	c.transport.slots = make(chan struct{}, maxParallelRequests)
+	c.transport.interval = interval
+	c.transport.queueTimeout = queueTimeout
+}
+
+// SetCircuitBreaker makes requests fail immediately for the cooldown duration after the given number of consecutive
+// requests failed (network errors and server errors), so that handlers don't wait for an OSM API that is down.
+func (c *Client) SetCircuitBreaker(failureThreshold int, cooldown time.Duration) {
+	c.transport.failureThreshold = failureThreshold
+	c.transport.cooldown = cooldown
+}
+
+// DisableCache stops caching responses, e.g. for requests which are (almost) never repeated. Without cache, there are
+// no stale responses to use when the server is not available.
+func (c *Client) DisableCache() {
+	c.cacheEnabled = false
+}
+
+// HttpClient returns the underlying HTTP client with the configured timeout, e.g. for libraries performing requests on
+// their own.
+func (c *Client) HttpClient() *http.Client {
+	return c.httpClient
+}
+
+// Get performs the request created by the factory and returns the response body. The cache key identifies the
+// response, e.g. the URL together with the user the request is made for. An empty cache key disables the cache for this
+// request, e.g. for requests with credentials which are never used twice.
+func (c *Client) Get(logger *util.Logger, cacheKey string, newRequest RequestFactory) ([]byte, error) {
+	entry := c.getCacheEntry(cacheKey)
+	if entry != nil && time.Since(entry.fetchedAt) < c.cacheTtl {
+		logger.Debug("Use cached OSM response for key %s", cacheKey)
+		return entry.body, nil
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := c.retryDelay * time.Duration(1<<uint(attempt-1))
+			logger.Log("Retry OSM request in %s (attempt %d of %d)", delay, attempt, c.maxRetries)
+			time.Sleep(delay)
+		}
+
+		body, retry, err := c.do(cacheKey, entry, newRequest)
+		if err == nil {
+			return body, nil
+		}
+
+		lastErr = err
+		logger.Err("OSM request failed: %s", err.Error())
+
+		if !retry {
+			break
+		}
+	}
+
+	if entry != nil {
+		logger.Log("OSM API not available, use stale cached response for key %s", cacheKey)
+		return entry.body, nil
+	}
+
+	return nil, lastErr
+}
+
+// Post performs the request created by the factory exactly once and returns the response body. Other than "Get", the
+// response isn't cached and failed requests aren't retried, because the request changes data on the OSM server.
+func (c *Client) Post(newRequest RequestFactory) ([]byte, error) {
+	request, err := newRequest()
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating OSM request")
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "error performing OSM request")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("OSM API responded with status %d", response.StatusCode))
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read OSM response body")
+	}
+
+	return body, nil
+}
+
+// do performs one attempt of the request. The returned bool states whether a retry makes sense in case of an error.
+func (c *Client) do(cacheKey string, entry *cacheEntry, newRequest RequestFactory) ([]byte, bool, error) {
+	request, err := newRequest()
+	if err != nil {
+		return nil, false, errors.Wrap(err, "error creating OSM request")
+	}
+
+	if entry != nil {
+		if entry.etag != "" {
+			request.Header.Set("If-None-Match", entry.etag)
+		}
+		if entry.lastModified != "" {
+			request.Header.Set("If-Modified-Since", entry.lastModified)
+		}
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		// Retrying makes no sense when the circuit is open or the queue is full
+		retry := !errors.Is(err, errCircuitOpen) && !errors.Is(err, errQueueTimeout)
+		return nil, retry, errors.Wrap(err, "error performing OSM request")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotModified && entry != nil {
+		c.setCacheEntry(cacheKey, &cacheEntry{
+			body:         entry.body,
+			etag:         entry.etag,
+			lastModified: entry.lastModified,
+			fetchedAt:    time.Now(),
+		})
+		return entry.body, false, nil
+	}
+
+	if response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests {
+		return nil, true, errors.New(fmt.Sprintf("OSM API responded with status %d", response.StatusCode))
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, false, errors.New(fmt.Sprintf("OSM API responded with status %d", response.StatusCode))
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, true, errors.Wrap(err, "could not read OSM response body")
+	}
+
+	c.setCacheEntry(cacheKey, &cacheEntry{
+		body:         body,
+		etag:         response.Header.Get("ETag"),
+		lastModified: response.Header.Get("Last-Modified"),
+		fetchedAt:    time.Now(),
+	})
+
+	return body, false, nil
+}
+
+func (c *Client) getCacheEntry(key string) *cacheEntry {
+	if key == "" {
+		return nil
+	}
+
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	return c.cache[key]
+}
+
+func (c *Client) setCacheEntry(key string, entry *cacheEntry) {
+	if !c.cacheEnabled || key == "" {
+		return
+	}
+
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	if _, ok := c.cache[key]; !ok && len(c.cache) >= c.maxCacheEntries {
+		c.removeOldestCacheEntry()
+	}
+
+	c.cache[key] = entry
+}
+
+// removeOldestCacheEntry removes the entry fetched longest ago. The cache mutex must be locked by the caller.
+func (c *Client) removeOldestCacheEntry() {
+	oldestKey := ""
+	var oldestEntry *cacheEntry
+	for key, entry := range c.cache {
+		if oldestEntry == nil || entry.fetchedAt.Before(oldestEntry.fetchedAt) {
+			oldestKey = key
+			oldestEntry = entry
+		}
+	}
+
+	if oldestEntry != nil {
+		delete(c.cache, oldestKey)
+	}
+}
@@ -0,0 +1,256 @@
+package osm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/util"
+)
+
+func TestGetCachesResponse(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("foo"))
+	}))
+	defer server.Close()
+
+	c := NewClient(time.Second, time.Minute, 0)
+	factory := func() (*http.Request, error) { return http.NewRequest(http.MethodGet, server.URL, nil) }
+
+	for i := 0; i < 3; i++ {
+		body, err := c.Get(util.NewLogger(), "key", factory)
+		if err != nil {
+			t.Errorf("Request should work: %s", err.Error())
+			return
+		}
+		if string(body) != "foo" {
+			t.Errorf("Body not matching: %s", string(body))
+		}
+	}
+
+	if requests != 1 {
+		t.Errorf("Expected exactly one request but got %d", requests)
+	}
+}
+
+func TestGetWithoutCacheKey(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("foo"))
+	}))
+	defer server.Close()
+
+	c := NewClient(time.Second, time.Minute, 0)
+	factory := func() (*http.Request, error) { return http.NewRequest(http.MethodGet, server.URL, nil) }
+
+	for i := 0; i < 3; i++ {
+		_, err := c.Get(util.NewLogger(), "", factory)
+		if err != nil {
+			t.Errorf("Request should work: %s", err.Error())
+			return
+		}
+	}
+
+	if requests != 3 {
+		t.Errorf("Expected 3 requests but got %d", requests)
+	}
+	if len(c.cache) != 0 {
+		t.Errorf("Responses without cache key should not be cached but cache has %d entries", len(c.cache))
+	}
+}
+
+func TestCacheSizeLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("foo"))
+	}))
+	defer server.Close()
+
+	c := NewClient(time.Second, time.Minute, 0)
+	c.maxCacheEntries = 2
+	factory := func() (*http.Request, error) { return http.NewRequest(http.MethodGet, server.URL, nil) }
+
+	for _, key := range []string{"a", "b", "c"} {
+		_, err := c.Get(util.NewLogger(), key, factory)
+		if err != nil {
+			t.Errorf("Request should work: %s", err.Error())
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if len(c.cache) != 2 || c.cache["a"] != nil || c.cache["b"] == nil || c.cache["c"] == nil {
+		t.Errorf("Oldest entry should have been removed: %v", c.cache)
+	}
+}
+
+func TestGetConditionalRequest(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == "\"v1\"" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", "\"v1\"")
+		w.Write([]byte("foo"))
+	}))
+	defer server.Close()
+
+	// Cache TTL of 0 makes every call a (conditional) request
+	c := NewClient(time.Second, 0, 0)
+	factory := func() (*http.Request, error) { return http.NewRequest(http.MethodGet, server.URL, nil) }
+
+	c.Get(util.NewLogger(), "key", factory)
+	body, err := c.Get(util.NewLogger(), "key", factory)
+	if err != nil {
+		t.Errorf("Request should work: %s", err.Error())
+		return
+	}
+
+	if string(body) != "foo" || requests != 2 {
+		t.Errorf("Cached body should be used after 304 response: %s (%d requests)", string(body), requests)
+	}
+}
+
+func TestGetRetryAndStaleCache(t *testing.T) {
+	requests := 0
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("foo"))
+	}))
+	defer server.Close()
+
+	c := NewClient(time.Second, 0, 2)
+	c.retryDelay = time.Millisecond
+	factory := func() (*http.Request, error) { return http.NewRequest(http.MethodGet, server.URL, nil) }
+
+	// Unavailable API without cached response
+	failing = true
+	_, err := c.Get(util.NewLogger(), "key", factory)
+	if err == nil {
+		t.Error("Request should fail")
+	}
+	if requests != 3 {
+		t.Errorf("Expected 3 attempts but got %d", requests)
+	}
+
+	// Fill cache
+	failing = false
+	c.Get(util.NewLogger(), "key", factory)
+
+	// Unavailable API with cached response
+	failing = true
+	body, err := c.Get(util.NewLogger(), "key", factory)
+	if err != nil {
+		t.Errorf("Stale cache should be used: %s", err.Error())
+		return
+	}
+	if string(body) != "foo" {
+		t.Errorf("Body not matching: %s", string(body))
+	}
+}
+
+func TestGetNoRetryOnClientError(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	c := NewClient(time.Second, time.Minute, 3)
+	c.retryDelay = time.Millisecond
+	factory := func() (*http.Request, error) { return http.NewRequest(http.MethodGet, server.URL, nil) }
+
+	_, err := c.Get(util.NewLogger(), "key", factory)
+	if err == nil {
+		t.Error("Request should fail")
+	}
+	if requests != 1 {
+		t.Errorf("Client errors should not be retried but got %d requests", requests)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := NewClient(time.Second, 0, 0)
+	c.SetCircuitBreaker(2, time.Minute)
+	factory := func() (*http.Request, error) { return http.NewRequest(http.MethodGet, server.URL, nil) }
+
+	for i := 0; i < 5; i++ {
+		c.Get(util.NewLogger(), "key", factory)
+	}
+
+	if requests != 2 {
+		t.Errorf("Circuit should open after 2 failed requests but got %d requests", requests)
+	}
+
+	// After the cooldown, requests are allowed again
+	c.transport.openUntil = time.Now()
+	c.Get(util.NewLogger(), "key", factory)
+	if requests != 3 {
+		t.Errorf("Circuit should be closed after cooldown but got %d requests", requests)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("foo"))
+	}))
+	defer server.Close()
+
+	c := NewClient(time.Second, 0, 0)
+	c.SetRateLimit(1, 20*time.Millisecond, time.Second)
+	factory := func() (*http.Request, error) { return http.NewRequest(http.MethodGet, server.URL, nil) }
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := c.Get(util.NewLogger(), "key", factory)
+		if err != nil {
+			t.Errorf("Request should work: %s", err.Error())
+			return
+		}
+	}
+
+	if time.Since(start) < 40*time.Millisecond {
+		t.Errorf("Three requests should take at least two intervals but took %s", time.Since(start))
+	}
+}
+
+func TestRateLimitQueueTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("foo"))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := NewClient(time.Second, 0, 3)
+	c.SetRateLimit(1, 0, 10*time.Millisecond)
+	factory := func() (*http.Request, error) { return http.NewRequest(http.MethodGet, server.URL, nil) }
+
+	// Blocks the only slot until the server gets released
+	go c.Get(util.NewLogger(), "key1", factory)
+	time.Sleep(10 * time.Millisecond)
+
+	_, err := c.Get(util.NewLogger(), "key2", factory)
+	if err == nil {
+		t.Error("Request should fail when no slot becomes free")
+	}
+}
@@ -0,0 +1,76 @@
+package osm
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/pkg/errors"
+)
+
+// Editors the links of "EditorUrl" can open
+const (
+	EditorJosm = "josm"
+	EditorId   = "id"
+)
+
+// JOSM listens on this address for commands of its remote control
+const josmRemoteControlUrl = "http://127.0.0.1:8111"
+
+func IsValidEditor(editor string) bool {
+	return editor == EditorJosm || editor == EditorId
+}
+
+// EditorUrl returns a link opening the area of the bounding box ("[minLon, minLat, maxLon, maxLat]") in the editor. The
+// changeset comment and hashtags (each starting with "#") are set as well, so that mappers don't have to type them.
+func EditorUrl(editor string, bbox []float64, comment string, hashtags []string) (string, error) {
+	if len(bbox) != 4 {
+		return "", errors.New(fmt.Sprintf("bounding box %v has not four values", bbox))
+	}
+
+	switch editor {
+	case EditorJosm:
+		// The remote control loads the data of the area, so there's no need to open it manually
+		params := []string{
+			"left=" + formatCoordinate(bbox[0]),
+			"bottom=" + formatCoordinate(bbox[1]),
+			"right=" + formatCoordinate(bbox[2]),
+			"top=" + formatCoordinate(bbox[3]),
+			"changeset_comment=" + escape(comment),
+			"changeset_hashtags=" + escape(strings.Join(hashtags, ";")),
+		}
+		return josmRemoteControlUrl + "/load_and_zoom?" + strings.Join(params, "&"), nil
+	case EditorId:
+		// iD reads its parameters from the fragment, which isn't decoded like a query, so spaces must not become "+"
+		mapParam := fmt.Sprintf("%d/%s/%s", zoomLevel(bbox), formatCoordinate((bbox[1]+bbox[3])/2), formatCoordinate((bbox[0]+bbox[2])/2))
+		params := []string{
+			"map=" + mapParam,
+			"comment=" + escape(comment),
+			"hashtags=" + escape(strings.Join(hashtags, ",")),
+		}
+		return config.Conf.OsmBaseUrl + "/edit?editor=id#" + strings.Join(params, "&"), nil
+	}
+
+	return "", errors.New(fmt.Sprintf("unknown editor '%s', supported are '%s' and '%s'", editor, EditorJosm, EditorId))
+}
+
+// zoomLevel returns the web map zoom level at which the whole bounding box fits into a screen of about 1000 pixels.
+func zoomLevel(bbox []float64) int {
+	span := math.Max(bbox[2]-bbox[0], bbox[3]-bbox[1])
+	if span <= 0 {
+		return 19
+	}
+
+	zoom := int(math.Floor(math.Log2(360 * 4 / span)))
+	return int(math.Max(2, math.Min(19, float64(zoom))))
+}
+
+func formatCoordinate(value float64) string {
+	return fmt.Sprintf("%.7f", value)
+}
+
+func escape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
@@ -0,0 +1,127 @@
+package osm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// The usage policy of the public Nominatim server allows at most one request per second
+const nominatimRequestInterval = time.Second
+
+var (
+	nominatimClient *Client
+
+	// Address fields of Nominatim naming the area around the location, the most specific first
+	localityFields = []string{"suburb", "neighbourhood", "quarter", "hamlet", "village", "town", "city_district"}
+	// Address fields of Nominatim naming the place the area belongs to, the most specific first
+	placeFields = []string{"city", "town", "village", "municipality", "county", "state"}
+)
+
+type nominatimResponse struct {
+	Name    string            `json:"name"`
+	Address map[string]string `json:"address"`
+	Error   string            `json:"error"` // E.g. for locations in the sea
+}
+
+// initGeocoding creates the client for the Nominatim server, when one is configured. The responses are not cached,
+// since every location is only looked up once.
+func initGeocoding(timeout time.Duration, queueTimeout time.Duration, breakerCooldown time.Duration) {
+	nominatimClient = nil
+	if config.Conf.NominatimUrl == "" {
+		return
+	}
+
+	nominatimClient = NewClient(timeout, 0, config.Conf.OsmRequestRetries)
+	nominatimClient.DisableCache()
+	nominatimClient.SetRateLimit(1, nominatimRequestInterval, queueTimeout)
+	nominatimClient.SetCircuitBreaker(config.Conf.OsmBreakerThreshold, breakerCooldown)
+}
+
+// GeocodingEnabled returns true when a Nominatim server is configured.
+func GeocodingEnabled() bool {
+	return nominatimClient != nil
+}
+
+// ReverseGeocode returns a human-readable name of the area around the location, e.g. "Kibera, Nairobi". The name is
+// empty when Nominatim knows nothing about the location (e.g. in the sea).
+func ReverseGeocode(logger *util.Logger, lat float64, lon float64) (string, error) {
+	if !GeocodingEnabled() {
+		return "", errors.New("reverse geocoding is not possible, no Nominatim server configured")
+	}
+
+	params := url.Values{}
+	params.Set("format", "jsonv2")
+	params.Set("lat", strconv.FormatFloat(lat, 'f', -1, 64))
+	params.Set("lon", strconv.FormatFloat(lon, 'f', -1, 64))
+	params.Set("zoom", "14") // Suburbs, there's no need for single streets or buildings
+	params.Set("addressdetails", "1")
+	reverseUrl := fmt.Sprintf("%s/reverse?%s", strings.TrimSuffix(config.Conf.NominatimUrl, "/"), params.Encode())
+
+	responseBody, err := nominatimClient.Get(logger, reverseUrl, func() (*http.Request, error) {
+		request, err := http.NewRequest(http.MethodGet, reverseUrl, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		// Nominatim requires requests to identify the application
+		request.Header.Set("User-Agent", fmt.Sprintf("simple-task-manager (%s)", config.Conf.ServerUrl))
+		return request, nil
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "reverse geocoding of %f, %f failed", lat, lon)
+	}
+
+	return parseLocality(responseBody)
+}
+
+// parseLocality returns the most specific name of the area from the response together with the place it belongs to,
+// e.g. a suburb and its city.
+func parseLocality(responseBody []byte) (string, error) {
+	var response nominatimResponse
+	err := json.Unmarshal(responseBody, &response)
+	if err != nil {
+		return "", errors.Wrap(err, "could not parse Nominatim response")
+	}
+
+	if response.Error != "" {
+		return "", nil
+	}
+
+	locality := firstAddressField(response.Address, localityFields, "")
+	place := firstAddressField(response.Address, placeFields, locality)
+
+	names := make([]string, 0, 2)
+	for _, name := range []string{locality, place} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return response.Name, nil
+	}
+
+	return strings.Join(names, ", "), nil
+}
+
+// firstAddressField returns the value of the first of the fields that is set in the address and differs from the
+// excluded value.
+func firstAddressField(address map[string]string, fields []string, excluded string) string {
+	for _, field := range fields {
+		value := strings.TrimSpace(address[field])
+		if value != "" && value != excluded {
+			return value
+		}
+	}
+
+	return ""
+}
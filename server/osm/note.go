@@ -0,0 +1,94 @@
+package osm
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Values of the "Status" of a note
+const (
+	NoteStatusOpen   = "open"
+	NoteStatusClosed = "closed"
+	NoteStatusHidden = "hidden" // Hidden by a moderator
+)
+
+// Note contains the metadata of an OSM note without its comments.
+type Note struct {
+	Id     string  `xml:"id"`
+	Status string  `xml:"status"` // One of the "NoteStatus..." values
+	Lat    float64 `xml:"lat,attr"`
+	Lon    float64 `xml:"lon,attr"`
+}
+
+type noteResponse struct {
+	Note Note `xml:"note"`
+}
+
+// GetNote returns the metadata of the given note.
+func GetNote(logger *util.Logger, noteId string) (*Note, error) {
+	if defaultClient == nil {
+		return nil, errors.New("OSM client not initialized")
+	}
+
+	noteUrl := fmt.Sprintf("%s/api/0.6/notes/%s", config.Conf.OsmBaseUrl, noteId)
+
+	responseBody, err := defaultClient.Get(logger, noteUrl, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, noteUrl, nil)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "requesting note %s failed", noteId)
+	}
+
+	return parseNote(responseBody, noteId)
+}
+
+// CreateNote creates a new anonymous note at the given location. The server has no OSM token of the user, so the
+// caller should mention in the text where the note comes from.
+func CreateNote(logger *util.Logger, lat float64, lon float64, text string) (*Note, error) {
+	if defaultClient == nil {
+		return nil, errors.New("OSM client not initialized")
+	}
+
+	params := url.Values{}
+	params.Set("lat", strconv.FormatFloat(lat, 'f', -1, 64))
+	params.Set("lon", strconv.FormatFloat(lon, 'f', -1, 64))
+	params.Set("text", text)
+	noteUrl := fmt.Sprintf("%s/api/0.6/notes?%s", config.Conf.OsmBaseUrl, params.Encode())
+
+	responseBody, err := defaultClient.Post(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodPost, noteUrl, nil)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating note failed")
+	}
+
+	note, err := parseNote(responseBody, "")
+	if err != nil {
+		return nil, err
+	}
+	logger.Log("Created OSM note %s", note.Id)
+
+	return note, nil
+}
+
+// parseNote reads the note from the response. When an ID is given, the response must contain the note with this ID.
+func parseNote(responseBody []byte, noteId string) (*Note, error) {
+	var osmResponse noteResponse
+	err := xml.Unmarshal(responseBody, &osmResponse)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse note")
+	}
+
+	if osmResponse.Note.Id == "" || (noteId != "" && osmResponse.Note.Id != noteId) {
+		return nil, errors.New(fmt.Sprintf("response does not contain note %s", noteId))
+	}
+
+	return &osmResponse.Note, nil
+}
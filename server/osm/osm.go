@@ -0,0 +1,115 @@
+package osm
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+var (
+	defaultClient *Client
+)
+
+// Init creates the client used for all requests to the OSM server based on the config.
+func Init() {
+	requestTimeout, err := time.ParseDuration(config.Conf.OsmRequestTimeout)
+	sigolo.FatalCheckf(err, "unable to parse OSM request timeout from config entry '%s'", config.Conf.OsmRequestTimeout)
+
+	cacheTtl, err := time.ParseDuration(config.Conf.OsmCacheTtl)
+	sigolo.FatalCheckf(err, "unable to parse OSM cache TTL from config entry '%s'", config.Conf.OsmCacheTtl)
+
+	requestInterval, err := time.ParseDuration(config.Conf.OsmRequestInterval)
+	sigolo.FatalCheckf(err, "unable to parse OSM request interval from config entry '%s'", config.Conf.OsmRequestInterval)
+
+	queueTimeout, err := time.ParseDuration(config.Conf.OsmQueueTimeout)
+	sigolo.FatalCheckf(err, "unable to parse OSM queue timeout from config entry '%s'", config.Conf.OsmQueueTimeout)
+
+	breakerCooldown, err := time.ParseDuration(config.Conf.OsmBreakerCooldown)
+	sigolo.FatalCheckf(err, "unable to parse OSM circuit breaker cooldown from config entry '%s'", config.Conf.OsmBreakerCooldown)
+
+	if config.Conf.OsmMaxParallel <= 0 {
+		sigolo.Fatal("OSM max parallel requests must be positive but was %d", config.Conf.OsmMaxParallel)
+	}
+
+	defaultClient = NewClient(requestTimeout, cacheTtl, config.Conf.OsmRequestRetries)
+	defaultClient.SetRateLimit(config.Conf.OsmMaxParallel, requestInterval, queueTimeout)
+	defaultClient.SetCircuitBreaker(config.Conf.OsmBreakerThreshold, breakerCooldown)
+
+	initGeocoding(requestTimeout, queueTimeout, breakerCooldown)
+}
+
+// GetClient returns the client created by "Init".
+func GetClient() *Client {
+	return defaultClient
+}
+
+// GetChangesetCount returns the number of changesets the given user uploaded. The public user information is cached
+// like all other responses of the client.
+func GetChangesetCount(logger *util.Logger, userId string) (int, error) {
+	if defaultClient == nil {
+		return 0, errors.New("OSM client not initialized")
+	}
+
+	url := fmt.Sprintf("%s/api/0.6/user/%s", config.Conf.OsmBaseUrl, userId)
+
+	responseBody, err := defaultClient.Get(logger, url, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, url, nil)
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "requesting information of user %s failed", userId)
+	}
+
+	var osmResponse util.Osm
+	err = xml.Unmarshal(responseBody, &osmResponse)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not parse information of user %s", userId)
+	}
+
+	return osmResponse.User.Changesets.Count, nil
+}
+
+// Changeset contains the metadata of a changeset relevant for statistics.
+type Changeset struct {
+	Id           string `xml:"id,attr"`
+	UserId       string `xml:"uid,attr"`
+	ChangesCount int    `xml:"changes_count,attr"` // Number of created, modified and deleted objects
+	Open         bool   `xml:"open,attr"`          // Open changesets may still get changes
+}
+
+type changesetResponse struct {
+	Changeset Changeset `xml:"changeset"`
+}
+
+// GetChangeset returns the metadata of the given changeset without its content.
+func GetChangeset(logger *util.Logger, changesetId string) (*Changeset, error) {
+	if defaultClient == nil {
+		return nil, errors.New("OSM client not initialized")
+	}
+
+	url := fmt.Sprintf("%s/api/0.6/changeset/%s", config.Conf.OsmBaseUrl, changesetId)
+
+	responseBody, err := defaultClient.Get(logger, url, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, url, nil)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "requesting changeset %s failed", changesetId)
+	}
+
+	var osmResponse changesetResponse
+	err = xml.Unmarshal(responseBody, &osmResponse)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse changeset %s", changesetId)
+	}
+
+	if osmResponse.Changeset.Id != changesetId {
+		return nil, errors.New(fmt.Sprintf("response does not contain changeset %s", changesetId))
+	}
+
+	return &osmResponse.Changeset, nil
+}
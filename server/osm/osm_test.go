@@ -0,0 +1,206 @@
+package osm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/util"
+)
+
+func TestGetChangeset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/0.6/changeset/123" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`<osm version="0.6"><changeset id="123" user="Maria" uid="42" open="false" changes_count="17" min_lat="53.5" min_lon="9.9" max_lat="53.6" max_lon="10.0"/></osm>`))
+	}))
+	defer server.Close()
+
+	previousConf, previousClient := config.Conf, defaultClient
+	defer func() { config.Conf, defaultClient = previousConf, previousClient }()
+	config.Conf = &config.Config{OsmBaseUrl: server.URL}
+	defaultClient = NewClient(time.Second, time.Minute, 0)
+
+	changeset, err := GetChangeset(util.NewLogger(), "123")
+	if err != nil {
+		t.Errorf("Getting changeset should work: %s", err.Error())
+		return
+	}
+	if changeset.Id != "123" || changeset.UserId != "42" || changeset.ChangesCount != 17 || changeset.Open {
+		t.Errorf("Changeset does not match: %#v", changeset)
+	}
+
+	_, err = GetChangeset(util.NewLogger(), "124")
+	if err == nil {
+		t.Errorf("Getting not existing changeset should fail")
+	}
+}
+
+func TestNotes(t *testing.T) {
+	var createdText string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/0.6/notes/123":
+			w.Write([]byte(`<osm version="0.6"><note lon="9.99" lat="53.55"><id>123</id><status>closed</status></note></osm>`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/0.6/notes":
+			if r.URL.Query().Get("lat") != "53.5" || r.URL.Query().Get("lon") != "10.25" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			createdText = r.URL.Query().Get("text")
+			w.Write([]byte(`<osm version="0.6"><note lon="10.25" lat="53.5"><id>124</id><status>open</status></note></osm>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	previousConf, previousClient := config.Conf, defaultClient
+	defer func() { config.Conf, defaultClient = previousConf, previousClient }()
+	config.Conf = &config.Config{OsmBaseUrl: server.URL}
+	defaultClient = NewClient(time.Second, time.Minute, 0)
+
+	note, err := GetNote(util.NewLogger(), "123")
+	if err != nil {
+		t.Errorf("Getting note should work: %s", err.Error())
+		return
+	}
+	if note.Id != "123" || note.Status != NoteStatusClosed || note.Lat != 53.55 {
+		t.Errorf("Note does not match: %#v", note)
+	}
+
+	_, err = GetNote(util.NewLogger(), "125")
+	if err == nil {
+		t.Errorf("Getting not existing note should fail")
+	}
+
+	note, err = CreateNote(util.NewLogger(), 53.5, 10.25, "Missing building & road")
+	if err != nil {
+		t.Errorf("Creating note should work: %s", err.Error())
+		return
+	}
+	if note.Id != "124" || note.Status != NoteStatusOpen || createdText != "Missing building & road" {
+		t.Errorf("Created note does not match: %#v with text '%s'", note, createdText)
+	}
+
+	_, err = CreateNote(util.NewLogger(), 1, 2, "Wrong location")
+	if err == nil {
+		t.Errorf("Failed creation of note should result in an error")
+	}
+}
+
+func TestEditorUrl(t *testing.T) {
+	previousConf := config.Conf
+	defer func() { config.Conf = previousConf }()
+	config.Conf = &config.Config{OsmBaseUrl: "https://www.openstreetmap.org"}
+
+	bbox := []float64{9.9, 53.5, 10.0, 53.6}
+
+	josmUrl, err := EditorUrl(EditorJosm, bbox, "Add buildings #stm", []string{"#stm", "#task-3"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expectedUrl := "http://127.0.0.1:8111/load_and_zoom?left=9.9000000&bottom=53.5000000&right=10.0000000&top=53.6000000&changeset_comment=Add%20buildings%20%23stm&changeset_hashtags=%23stm%3B%23task-3"
+	if josmUrl != expectedUrl {
+		t.Errorf("JOSM URL '%s' does not match '%s'", josmUrl, expectedUrl)
+	}
+
+	idUrl, err := EditorUrl(EditorId, bbox, "Add buildings", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expectedUrl = "https://www.openstreetmap.org/edit?editor=id#map=13/53.5500000/9.9500000&comment=Add%20buildings&hashtags="
+	if idUrl != expectedUrl {
+		t.Errorf("iD URL '%s' does not match '%s'", idUrl, expectedUrl)
+	}
+
+	_, err = EditorUrl("potlatch", bbox, "", nil)
+	if err == nil {
+		t.Errorf("Unknown editors should not be possible")
+	}
+
+	_, err = EditorUrl(EditorId, []float64{1, 2}, "", nil)
+	if err == nil {
+		t.Errorf("Invalid bounding box should not be possible")
+	}
+
+	if zoomLevel([]float64{1, 1, 1, 1}) != 19 || zoomLevel([]float64{-180, -90, 180, 90}) != 2 {
+		t.Errorf("Zoom levels should be limited to the range of 2 to 19")
+	}
+}
+
+func TestParseLocality(t *testing.T) {
+	tests := []struct {
+		response string
+		locality string
+	}{
+		{`{"name":"Kibera","address":{"suburb":"Kibera","city":"Nairobi","country":"Kenya"}}`, "Kibera, Nairobi"},
+		{`{"name":"Altona","address":{"suburb":"Altona","city_district":"Altona","city":"Hamburg"}}`, "Altona, Hamburg"},
+		{`{"name":"Hamburg","address":{"city":"Hamburg","state":"Hamburg"}}`, "Hamburg"},
+		{`{"name":"Bad Bevensen","address":{"town":"Bad Bevensen","county":"Landkreis Uelzen"}}`, "Bad Bevensen, Landkreis Uelzen"},
+		{`{"name":"Some Forest","address":{}}`, "Some Forest"},
+		{`{"error":"Unable to geocode"}`, ""},
+	}
+
+	for _, test := range tests {
+		locality, err := parseLocality([]byte(test.response))
+		if err != nil {
+			t.Errorf("Parsing '%s' should work: %s", test.response, err.Error())
+			continue
+		}
+		if locality != test.locality {
+			t.Errorf("Locality '%s' of '%s' does not match '%s'", locality, test.response, test.locality)
+		}
+	}
+
+	_, err := parseLocality([]byte("<html>"))
+	if err == nil {
+		t.Errorf("Invalid responses should not be possible")
+	}
+}
+
+func TestReverseGeocode(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		if r.URL.Path != "/reverse" || r.URL.Query().Get("lat") != "-1.3133" || r.URL.Query().Get("lon") != "36.7876" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"name":"Kibera","address":{"suburb":"Kibera","city":"Nairobi"}}`))
+	}))
+	defer server.Close()
+
+	previousConf, previousClient := config.Conf, nominatimClient
+	defer func() { config.Conf, nominatimClient = previousConf, previousClient }()
+
+	config.Conf = &config.Config{}
+	initGeocoding(time.Second, time.Second, time.Minute)
+	if GeocodingEnabled() {
+		t.Errorf("Geocoding should be disabled without Nominatim URL")
+	}
+
+	config.Conf = &config.Config{NominatimUrl: server.URL + "/", ServerUrl: "https://stm.example.com"}
+	initGeocoding(time.Second, time.Second, time.Minute)
+	if !GeocodingEnabled() {
+		t.Errorf("Geocoding should be enabled with Nominatim URL")
+	}
+
+	locality, err := ReverseGeocode(util.NewLogger(), -1.3133, 36.7876)
+	if err != nil {
+		t.Errorf("Reverse geocoding should work: %s", err.Error())
+		return
+	}
+	if locality != "Kibera, Nairobi" {
+		t.Errorf("Locality '%s' does not match", locality)
+	}
+	if userAgent != "simple-task-manager (https://stm.example.com)" {
+		t.Errorf("User agent '%s' does not match", userAgent)
+	}
+}
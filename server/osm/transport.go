@@ -0,0 +1,118 @@
+package osm
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	errCircuitOpen  = errors.New("OSM API is not available (circuit breaker open)")
+	errQueueTimeout = errors.New("timeout while waiting for a free slot to perform OSM request")
+)
+
+// limitedTransport is used for all requests of the client, including the ones made by libraries via "HttpClient". It
+// queues requests so that only a limited number of them run at the same time, keeps a minimum interval between two
+// requests and stops sending requests for a while when the OSM API keeps failing (circuit breaker).
+type limitedTransport struct {
+	next http.RoundTripper
+
+	slots        chan struct{} // Buffered channel used as semaphore, nil means unlimited
+	queueTimeout time.Duration
+	interval     time.Duration
+	nextRequest  time.Time
+	rateMutex    sync.Mutex
+
+	failureThreshold    int // Consecutive failures opening the circuit, 0 disables the circuit breaker
+	cooldown            time.Duration
+	consecutiveFailures int
+	openUntil           time.Time
+	breakerMutex        sync.Mutex
+}
+
+func newLimitedTransport() *limitedTransport {
+	return &limitedTransport{
+		next: http.DefaultTransport,
+	}
+}
+
+func (t *limitedTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if !t.allowRequest() {
+		return nil, errCircuitOpen
+	}
+
+	if t.slots != nil {
+		timer := time.NewTimer(t.queueTimeout)
+		select {
+		case t.slots <- struct{}{}:
+			timer.Stop()
+			defer func() { <-t.slots }()
+		case <-timer.C:
+			return nil, errQueueTimeout
+		case <-request.Context().Done():
+			timer.Stop()
+			return nil, request.Context().Err()
+		}
+	}
+
+	t.waitForRateLimit()
+
+	response, err := t.next.RoundTrip(request)
+	t.recordResult(err == nil && response.StatusCode < 500)
+
+	return response, err
+}
+
+// waitForRateLimit blocks until the minimum interval since the previous request has passed.
+func (t *limitedTransport) waitForRateLimit() {
+	if t.interval <= 0 {
+		return
+	}
+
+	t.rateMutex.Lock()
+	now := time.Now()
+	start := t.nextRequest
+	if start.Before(now) {
+		start = now
+	}
+	t.nextRequest = start.Add(t.interval)
+	t.rateMutex.Unlock()
+
+	time.Sleep(start.Sub(now))
+}
+
+// allowRequest returns false while the circuit is open. After the cooldown, requests are allowed again and the first
+// failing one opens the circuit right away.
+func (t *limitedTransport) allowRequest() bool {
+	if t.failureThreshold <= 0 {
+		return true
+	}
+
+	t.breakerMutex.Lock()
+	defer t.breakerMutex.Unlock()
+
+	return !time.Now().Before(t.openUntil)
+}
+
+func (t *limitedTransport) recordResult(success bool) {
+	if t.failureThreshold <= 0 {
+		return
+	}
+
+	t.breakerMutex.Lock()
+	defer t.breakerMutex.Unlock()
+
+	if success {
+		t.consecutiveFailures = 0
+		return
+	}
+
+	t.consecutiveFailures++
+	if t.consecutiveFailures >= t.failureThreshold {
+		t.openUntil = time.Now().Add(t.cooldown)
+		// Keep the counter at the threshold, so one more failure after the cooldown opens the circuit again
+		t.consecutiveFailures = t.failureThreshold - 1
+	}
+}
@@ -0,0 +1,178 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/mail"
+	"github.com/hauke96/simple-task-manager/server/push"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+const (
+	TypeMail = "mail"
+	TypePush = "push"
+
+	maxAttempts   = 8  // Messages failing this often are not retried anymore
+	dispatchLimit = 50 // Maximum number of messages handled by one dispatch run
+)
+
+// Message is something to deliver after the transaction creating it has been committed. Messages are only delivered
+// once the transaction is committed and are retried when the delivery fails.
+type Message struct {
+	Id        string
+	Type      string // "mail" or "push"
+	Recipient string // Mail address or push subscription (see "push.Subscription.String()")
+	Subject   string
+	Body      string
+	Attempts  int
+}
+
+type OutboxService struct {
+	*util.Logger
+	store       *storePg
+	pushService *push.PushService
+}
+
+var (
+	// Replaced by tests, so that no SMTP server or push service is needed
+	sendMail = mail.Send
+	sendPush = push.Send
+)
+
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger) *OutboxService {
+	return &OutboxService{
+		Logger:      logger,
+		store:       getStore(ctx, tx, logger),
+		pushService: push.Init(ctx, tx, logger),
+	}
+}
+
+// DispatchJob is meant to be executed by the scheduler without transaction. It delivers up to "dispatchLimit" pending
+// messages, each one in its own transaction (see "DispatchNext"). This way, a failing message doesn't roll back the
+// state of messages delivered before.
+func DispatchJob(ctx context.Context, logger *util.Logger) error {
+	sent := 0
+	for attempts := 0; attempts < dispatchLimit; attempts++ {
+		tx, err := database.GetTransaction(ctx, logger)
+		if err != nil {
+			return errors.Wrap(err, "error getting transaction")
+		}
+
+		found, delivered, err := Init(ctx, tx, logger).DispatchNext()
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return errors.Wrap(err, "error committing state of outbox message")
+		}
+
+		if !found {
+			break
+		}
+		if delivered {
+			sent++
+		}
+	}
+
+	if sent != 0 {
+		logger.Log("Delivered %d pending messages", sent)
+	}
+
+	return nil
+}
+
+// AddMail stores a mail in the outbox using the transaction of the service. The mail is therefore only sent, when the
+// transaction is committed, and it's not lost when the server stops before sending it.
+func (s *OutboxService) AddMail(to string, subject string, body string) error {
+	err := s.store.addMessage(TypeMail, to, subject, body)
+	if err != nil {
+		return err
+	}
+
+	s.Debug("Added mail '%s' to %s to outbox", subject, to)
+
+	return nil
+}
+
+// AddPush stores a push message to the given subscription in the outbox, see "AddMail". The title is only used for
+// logging, the payload is what the browser receives. The keys of the subscription are stored encrypted.
+func (s *OutboxService) AddPush(subscription *push.Subscription, title string, payload string) error {
+	encrypted, err := subscription.WithEncryptedKeys()
+	if err != nil {
+		return err
+	}
+
+	err = s.store.addMessage(TypePush, encrypted.String(), title, payload)
+	if err != nil {
+		return err
+	}
+
+	s.Debug("Added push message '%s' to user %s to outbox", title, subscription.UserId)
+
+	return nil
+}
+
+// DispatchNext delivers the oldest pending message. The first returned bool is false when there was no pending message,
+// the second one states whether the message has been delivered. Failed deliveries are retried with an exponentially
+// increasing delay. The message is locked while being delivered, so concurrent dispatch runs (e.g. of several server
+// instances) don't send it twice. Since the state of the message is stored in the transaction of the service, the
+// transaction should be committed right afterwards. A message is only delivered again when storing its state fails or
+// the server stops after delivering but before committing.
+func (s *OutboxService) DispatchNext() (bool, bool, error) {
+	messages, err := s.store.getPendingMessages(maxAttempts, 1)
+	if err != nil {
+		return false, false, err
+	}
+	if len(messages) == 0 {
+		return false, false, nil
+	}
+	message := messages[0]
+
+	deliveryErr := s.deliver(message)
+	if deliveryErr != nil {
+		s.Err("Unable to deliver message %s (attempt %d of %d): %s", message.Id, message.Attempts+1, maxAttempts, deliveryErr.Error())
+
+		err = s.store.markFailed(message.Id, retryDelay(message.Attempts), deliveryErr.Error())
+		if err != nil {
+			return true, false, err
+		}
+
+		return true, false, nil
+	}
+
+	err = s.store.markSent(message.Id)
+	if err != nil {
+		return true, true, err
+	}
+
+	return true, true, nil
+}
+
+func (s *OutboxService) deliver(message *Message) error {
+	switch message.Type {
+	case TypeMail:
+		return sendMail(s.Logger, message.Recipient, message.Subject, message.Body)
+	case TypePush:
+		err := sendPush(s.Logger, message.Recipient, message.Body)
+		if err == push.ErrSubscriptionGone {
+			// The browser can't receive messages anymore, so there's nothing left to deliver
+			return s.pushService.RemoveGoneSubscription(message.Recipient)
+		}
+		return err
+	}
+
+	return errors.New(fmt.Sprintf("unknown message type '%s'", message.Type))
+}
+
+// retryDelay returns the time to wait after the given number of previous attempts: 1 minute, 2 minutes, 4 minutes, ...
+func retryDelay(previousAttempts int) time.Duration {
+	return time.Minute * time.Duration(1<<uint(previousAttempts))
+}
@@ -0,0 +1,93 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+type storePg struct {
+	*util.Logger
+	ctx   context.Context
+	tx    *sql.Tx
+	table database.Table
+}
+
+func getStore(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
+	return &storePg{
+		Logger: logger,
+		ctx:    ctx,
+		tx:     tx,
+		table:  database.TableOutbox,
+	}
+}
+
+func (s *storePg) addMessage(messageType string, recipient string, subject string, body string) error {
+	query := fmt.Sprintf("INSERT INTO %s(type, recipient, subject, body) VALUES($1, $2, $3, $4);", s.table)
+	return s.execQuery(query, messageType, recipient, subject, body)
+}
+
+// getPendingMessages returns the oldest messages, which haven't been sent yet and are due for a (next) attempt. The
+// returned messages are locked until the end of the transaction, already locked ones are skipped.
+func (s *storePg) getPendingMessages(maxAttempts int, limit int) ([]*Message, error) {
+	query := fmt.Sprintf(`SELECT id, type, recipient, subject, body, attempts FROM %s
+WHERE sent_at IS NULL AND attempts < $1 AND next_attempt <= NOW()
+ORDER BY id
+LIMIT $2
+FOR UPDATE SKIP LOCKED;`, s.table)
+	s.LogQuery(query, maxAttempts, limit)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, maxAttempts, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting pending outbox messages")
+	}
+	defer rows.Close()
+
+	messages := make([]*Message, 0)
+	for rows.Next() {
+		var id int
+		m := &Message{}
+
+		err = rows.Scan(&id, &m.Type, &m.Recipient, &m.Subject, &m.Body, &m.Attempts)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan outbox message")
+		}
+
+		m.Id = strconv.Itoa(id)
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
+
+func (s *storePg) markSent(id string) error {
+	query := fmt.Sprintf("UPDATE %s SET sent_at=NOW(), attempts=attempts+1, last_error='' WHERE id=$1;", s.table)
+	return s.execQuery(query, id)
+}
+
+// markFailed counts the failed attempt and schedules the next one after the given delay.
+func (s *storePg) markFailed(id string, retryDelay time.Duration, lastError string) error {
+	query := fmt.Sprintf("UPDATE %s SET attempts=attempts+1, next_attempt=NOW() + $2::INT * INTERVAL '1 second', last_error=$3 WHERE id=$1;", s.table)
+	return s.execQuery(query, id, int(retryDelay.Seconds()), lastError)
+}
+
+func (s *storePg) execQuery(query string, params ...interface{}) error {
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return errors.Wrap(err, "error executing outbox query")
+	}
+
+	return nil
+}
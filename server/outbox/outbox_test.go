@@ -0,0 +1,178 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/push"
+	"github.com/hauke96/simple-task-manager/server/secret"
+	"github.com/hauke96/simple-task-manager/server/test"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq" // Make driver "postgres" usable
+)
+
+var (
+	tx *sql.Tx
+	s  *OutboxService
+	h  *test.TestHelper
+)
+
+func TestMain(m *testing.M) {
+	h = &test.TestHelper{
+		Setup: setup,
+	}
+
+	m.Run()
+}
+
+func setup() {
+	config.LoadConfig("../config/test.json")
+	test.InitWithDummyData()
+	sigolo.LogLevel = sigolo.LOG_DEBUG
+
+	logger := util.NewLogger()
+
+	ctx := context.Background()
+
+	var err error
+	tx, err = database.GetTransaction(ctx, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	h.Tx = tx
+	s = Init(ctx, tx, logger)
+}
+
+func TestRetryDelay(t *testing.T) {
+	if retryDelay(0) != time.Minute || retryDelay(3) != 8*time.Minute {
+		t.Errorf("Retry delays not increasing exponentially: %v, %v", retryDelay(0), retryDelay(3))
+		return
+	}
+}
+
+// dispatchAll delivers all pending messages within the transaction of the test.
+func dispatchAll() error {
+	for {
+		found, _, err := s.DispatchNext()
+		if err != nil || !found {
+			return err
+		}
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	h.Run(t, func() error {
+		sentMails := make([]string, 0)
+		sendMail = func(logger *util.Logger, to string, subject string, body string) error {
+			sentMails = append(sentMails, to)
+			if to == "fail@example.com" {
+				return errors.New("test error")
+			}
+			return nil
+		}
+
+		err := s.AddMail("peter@example.com", "Subject", "Body")
+		if err != nil {
+			return err
+		}
+		err = s.AddMail("fail@example.com", "Subject", "Body")
+		if err != nil {
+			return err
+		}
+
+		err = dispatchAll()
+		if err != nil {
+			return err
+		}
+
+		if len(sentMails) != 2 {
+			return errors.New(fmt.Sprintf("Expected 2 delivery attempts but got %d", len(sentMails)))
+		}
+
+		var sentCount, failedAttempts int
+		err = tx.QueryRow("SELECT COUNT(*) FROM outbox WHERE sent_at IS NOT NULL;").Scan(&sentCount)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRow("SELECT attempts FROM outbox WHERE recipient='fail@example.com' AND sent_at IS NULL;").Scan(&failedAttempts)
+		if err != nil {
+			return err
+		}
+		if sentCount != 1 || failedAttempts != 1 {
+			return errors.New(fmt.Sprintf("Expected one sent and one failed mail but got %d sent and %d attempts", sentCount, failedAttempts))
+		}
+
+		// Neither the sent mail nor the failed one (not due yet) should be delivered again
+		err = dispatchAll()
+		if err != nil {
+			return err
+		}
+
+		if len(sentMails) != 2 {
+			return errors.New(fmt.Sprintf("Expected no further delivery attempts but got %d in total", len(sentMails)))
+		}
+
+		return nil
+	})
+}
+
+func TestDispatchPush(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := tx.Exec("INSERT INTO push_subscriptions(endpoint, user_id, p256dh, auth) VALUES('https://push.example.com/abc', 'Peter', 'key', 'auth');")
+		if err != nil {
+			return err
+		}
+
+		// The keys of subscriptions are stored encrypted
+		err = secret.Configure("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+		if err != nil {
+			return err
+		}
+		defer secret.Configure("")
+
+		var storedSubscription string
+		sentPushes := 0
+		sendPush = func(logger *util.Logger, subscriptionJson string, payload string) error {
+			storedSubscription = subscriptionJson
+			sentPushes++
+			return push.ErrSubscriptionGone
+		}
+
+		err = s.AddPush(&push.Subscription{Endpoint: "https://push.example.com/abc", Keys: push.SubscriptionKeys{P256dh: "key", Auth: "auth"}, UserId: "Peter"}, "Title", "Payload")
+		if err != nil {
+			return err
+		}
+
+		err = dispatchAll()
+		if err != nil {
+			return err
+		}
+
+		var subscriptionCount, pendingCount int
+		err = tx.QueryRow("SELECT COUNT(*) FROM push_subscriptions;").Scan(&subscriptionCount)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRow("SELECT COUNT(*) FROM outbox WHERE sent_at IS NULL;").Scan(&pendingCount)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(storedSubscription, "\"auth\":\"auth\"") {
+			return errors.New(fmt.Sprintf("Keys of subscription should be stored encrypted: %s", storedSubscription))
+		}
+		if sentPushes != 1 || subscriptionCount != 0 || pendingCount != 0 {
+			return errors.New(fmt.Sprintf("Gone subscription should be removed without retry but got %d attempts, %d subscriptions and %d pending messages", sentPushes, subscriptionCount, pendingCount))
+		}
+
+		return nil
+	})
+}
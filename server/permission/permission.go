@@ -1,63 +1,76 @@
 package permission
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+
+	"github.com/hauke96/simple-task-manager/server/storage"
 	"github.com/hauke96/simple-task-manager/server/util"
-	"github.com/lib/pq"
 	"github.com/pkg/errors"
 )
 
 type PermissionService struct {
 	*util.Logger
-	tx *sql.Tx
+	store storage.PermissionStore
 }
 
-var (
-	taskTable    = "tasks"
-	projectTable = "projects"
-)
-
-// Init the permission service for the project and task table.
-func Init(tx *sql.Tx, logger *util.Logger) *PermissionService {
+// Init the permission service for the project and task table. All queries are cancelled when the given context is
+// cancelled.
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger) *PermissionService {
 	return &PermissionService{
 		Logger: logger,
-		tx:     tx,
+		store:  storage.NewStore(storage.ComponentPermission, ctx, tx, logger).(storage.PermissionStore),
 	}
 }
 
 // VerifyOwnership check if the given user is the owner of the given project.
 func (s *PermissionService) VerifyOwnership(projectId string, user string) error {
-	query := fmt.Sprintf("SELECT * FROM %s WHERE id=$1 AND owner=$2", projectTable)
-
-	s.LogQuery(query, projectId, user)
-	rows, err := s.tx.Query(query, projectId, user)
+	isOwner, err := s.IsOwner(projectId, user)
 	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("error verifying ownership of user %s in project %s", user, projectId))
+		return err
 	}
-	defer rows.Close()
 
-	// If there's a next row, then the user "user" is in the owner of the project "projectId"
-	if !rows.Next() {
+	if !isOwner {
 		return errors.New(fmt.Sprintf("user %s is not the owner of project %s", user, projectId))
 	}
 
 	return nil
 }
 
+// IsOwner works like "VerifyOwnership" but returns false instead of an error when the user is not the owner, e.g. to
+// decide what the user is allowed to see.
+func (s *PermissionService) IsOwner(projectId string, user string) (bool, error) {
+	isOwner, err := s.store.IsOwner(projectId, user)
+	if err != nil {
+		return false, errors.Wrap(err, fmt.Sprintf("error verifying ownership of user %s in project %s", user, projectId))
+	}
+
+	return isOwner, nil
+}
+
+// VerifyOwnershipTask checks if the given user is the owner of the project, where the given task is in.
+func (s *PermissionService) VerifyOwnershipTask(taskId string, user string) error {
+	isOwner, err := s.store.IsOwnerOfTask(taskId, user)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("error verifying ownership of user %s for task %s", user, taskId))
+	}
+
+	if !isOwner {
+		return errors.New(fmt.Sprintf("user %s is not the owner of the project where the task %s is in", user, taskId))
+	}
+
+	return nil
+}
+
 // VerifyMembershipProject checks if "user" is a member of the project "id".
 func (s *PermissionService) VerifyMembershipProject(projectId string, user string) error {
-	query := fmt.Sprintf("SELECT * FROM %s WHERE id=$1 AND $2=ANY(users)", projectTable)
-
-	s.LogQuery(query, projectId, user)
-	rows, err := s.tx.Query(query, projectId, user)
+	isMember, err := s.store.IsMember(projectId, user)
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("error verifying membership of user %s in project %s", user, projectId))
 	}
-	defer rows.Close()
 
-	// If there's a next row, then the user "user" is in the list of members of project "projectId"
-	if !rows.Next() {
+	if !isMember {
 		return errors.New(fmt.Sprintf("user %s is not a member of project %s", user, projectId))
 	}
 
@@ -66,17 +79,12 @@ func (s *PermissionService) VerifyMembershipProject(projectId string, user strin
 
 // VerifyMembershipTask checks if "user" is a member of the project, where the given task with "id" is in.
 func (s *PermissionService) VerifyMembershipTask(taskId string, user string) error {
-	query := fmt.Sprintf("SELECT * FROM %s p, %s t WHERE t.project_id = p.id AND t.id = $1 AND $2=ANY(p.users);", projectTable, taskTable)
-
-	s.LogQuery(query, taskId, user)
-	rows, err := s.tx.Query(query, taskId, user)
+	isMember, err := s.store.IsMemberOfTask(taskId, user)
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("error verifying membership of user %s for task %s", user, taskId))
 	}
-	defer rows.Close()
 
-	// If there's a next row, then the given task in in the list of a project where the given user is a member of.
-	if !rows.Next() {
+	if !isMember {
 		return errors.New(fmt.Sprintf("user %s is not a member of the project where the task %s is in", user, taskId))
 	}
 
@@ -85,25 +93,10 @@ func (s *PermissionService) VerifyMembershipTask(taskId string, user string) err
 
 // VerifyMembershipTask checks if "user" is a member of the projects, where the given tasks are in.
 func (s *PermissionService) VerifyMembershipTasks(taskIds []string, user string) error {
-	query := fmt.Sprintf("SELECT COUNT(*) FROM %s p, %s t WHERE t.project_id = p.id AND t.id = ANY($1) AND $2=ANY(p.users);", projectTable, taskTable)
-
-	s.LogQuery(query, pq.Array(taskIds), user)
-	rows, err := s.tx.Query(query, pq.Array(taskIds), user)
+	taskMemberships, err := s.store.CountMemberships(taskIds, user)
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("error verifying membership of user %s for tasks %v", user, taskIds))
 	}
-	defer rows.Close()
-
-	// If there's a next row, then the given task in in the list of a project where the given user is a member of.
-	if !rows.Next() {
-		return errors.New(fmt.Sprintf("user %s is not a member of all projects where the tasks %v are in", user, taskIds))
-	}
-
-	var taskMemberships int
-	err = rows.Scan(&taskMemberships)
-	if err != nil {
-		return errors.Wrap(err, "unable to read task membership result")
-	}
 
 	if taskMemberships != len(taskIds) {
 		return errors.New(fmt.Sprintf("user %s is not a member of all %d tasks (only of %d)", user, len(taskIds), taskMemberships))
@@ -114,67 +107,82 @@ func (s *PermissionService) VerifyMembershipTasks(taskIds []string, user string)
 
 // VerifyAssignment returns an error when the given user is not assigned to the given task.
 func (s *PermissionService) VerifyAssignment(taskId string, user string) error {
-	query := fmt.Sprintf("SELECT * FROM %s WHERE id=$1 AND assigned_user=$2;", taskTable)
-
-	s.LogQuery(query, taskId, user)
-	rows, err := s.tx.Query(query, taskId, user)
+	isAssigned, err := s.store.IsAssigned(taskId, user)
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("error verifying assignment of user %s to task %s", user, taskId))
 	}
-	defer rows.Close()
 
-	// If there's a next row, then the given user is assigned to the given task
-	if !rows.Next() {
+	if !isAssigned {
 		return errors.New(fmt.Sprintf("user %s is not assigned to task %s", user, taskId))
 	}
 
 	return nil
 }
 
-// AssignmentNeeded determines whether a user needs to be assigned to tasks in this project.
-func (s *PermissionService) AssignmentInProjectNeeded(projectId string) (bool, error) {
-	query := fmt.Sprintf("SELECT ARRAY_LENGTH(users, 1) FROM %s WHERE id=$1;", projectTable)
-
-	s.LogQuery(query, projectId)
-	rows, err := s.tx.Query(query, projectId)
+// VerifyAllowedUser checks that the user is allowed to work on the task. This is the case when the task isn't
+// restricted to certain users or when the user is one of them.
+func (s *PermissionService) VerifyAllowedUser(taskId string, user string) error {
+	isAllowed, err := s.store.IsAllowedUser(taskId, user)
 	if err != nil {
-		return true, errors.Wrap(err, fmt.Sprintf("error getting assignment requirement for project %s", projectId))
+		return errors.Wrap(err, fmt.Sprintf("error verifying that user %s is allowed to work on task %s", user, taskId))
 	}
-	defer rows.Close()
 
-	if !rows.Next() {
-		return true, errors.New(fmt.Sprintf("no row to get assignment requirement for project %s", projectId))
+	if !isAllowed {
+		return errors.New(fmt.Sprintf("user %s is not allowed to work on task %s", user, taskId))
 	}
 
-	var userCount int
-	err = rows.Scan(&userCount)
+	return nil
+}
+
+// AssignmentNeeded determines whether a user needs to be assigned to tasks in this project.
+func (s *PermissionService) AssignmentInProjectNeeded(projectId string) (bool, error) {
+	userCount, err := s.store.GetUserCount(projectId)
 	if err != nil {
-		return true, errors.Wrap(err, fmt.Sprintf("error reading row to get assignment requirement for project %s", projectId))
+		return true, errors.Wrap(err, fmt.Sprintf("error getting assignment requirement for project %s", projectId))
 	}
 
 	// Tasks in a project with only one user (the owner) don't need an assignment
 	return userCount != 1, nil
 }
 
-// AssignmentInTaskNeeded determines whether a user needs to be assigned to this task.
-func (s *PermissionService) AssignmentInTaskNeeded(taskId string) (bool, error) {
-	query := fmt.Sprintf("SELECT ARRAY_LENGTH(p.users, 1) FROM %s p, %s t WHERE $1 = t.id AND t.project_id = p.id;", projectTable, taskTable)
+// MinChangesetsForTask returns the number of OSM changesets a user needs to get the task assigned.
+func (s *PermissionService) MinChangesetsForTask(taskId string) (int, error) {
+	return s.store.GetMinChangesetsOfTask(taskId)
+}
+
+// PointStepForTask returns the step size of the process points in the project of the given task, 0 means that all
+// values are allowed.
+func (s *PermissionService) PointStepForTask(taskId string) (int, error) {
+	return s.store.GetPointStepOfTask(taskId)
+}
 
-	s.LogQuery(query, taskId)
-	rows, err := s.tx.Query(query, taskId)
+// AssignmentLimitsForTask returns how many tasks a user can have assigned at the same time and how many tasks a user
+// can complete per day in the project of the given task. A limit of 0 means that there's no limit.
+func (s *PermissionService) AssignmentLimitsForTask(taskId string) (int, int, error) {
+	return s.store.GetAssignmentLimitsOfTask(taskId)
+}
+
+// VerifyNotArchivedTask checks that the project of the given task has not been archived, since archived projects can't
+// be changed anymore.
+func (s *PermissionService) VerifyNotArchivedTask(taskId string) error {
+	notArchived, err := s.store.IsNotArchivedTask(taskId)
 	if err != nil {
-		return true, errors.Wrap(err, fmt.Sprintf("error getting assignment requirement for task %s", taskId))
+		return errors.Wrap(err, fmt.Sprintf("error verifying that project of task %s is not archived", taskId))
 	}
-	defer rows.Close()
 
-	if !rows.Next() {
-		return true, errors.New(fmt.Sprintf("no row to get assignment requirement for task %s", taskId))
+	// The project of the task has to exist and must not be archived
+	if !notArchived {
+		return errors.New(fmt.Sprintf("project of task %s is archived", taskId))
 	}
 
-	var userCount int
-	err = rows.Scan(&userCount)
+	return nil
+}
+
+// AssignmentInTaskNeeded determines whether a user needs to be assigned to this task.
+func (s *PermissionService) AssignmentInTaskNeeded(taskId string) (bool, error) {
+	userCount, err := s.store.GetUserCountOfTask(taskId)
 	if err != nil {
-		return true, errors.Wrap(err, fmt.Sprintf("error reading row to get assignment requirement for task %s", taskId))
+		return true, errors.Wrap(err, fmt.Sprintf("error getting assignment requirement for task %s", taskId))
 	}
 
 	// Tasks in a project with only one user (the owner) don't need an assignment
@@ -0,0 +1,176 @@
+package permission
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/storage"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type storePg struct {
+	*util.Logger
+	ctx          context.Context
+	tx           *sql.Tx
+	taskTable    database.Table
+	projectTable database.Table
+}
+
+func init() {
+	storage.Register(storage.DriverPostgres, storage.ComponentPermission, func(ctx context.Context, tx storage.Transaction, logger *util.Logger) interface{} {
+		return newStorePg(ctx, tx.(*sql.Tx), logger)
+	})
+}
+
+func newStorePg(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
+	return &storePg{
+		Logger:       logger,
+		ctx:          ctx,
+		tx:           tx,
+		taskTable:    database.TableTasks,
+		projectTable: database.TableProjects,
+	}
+}
+
+func (s *storePg) IsOwner(projectId string, userId string) (bool, error) {
+	query := fmt.Sprintf("SELECT 1 FROM %s WHERE id=$1 AND owner=$2", s.projectTable)
+	return s.exists(query, projectId, userId)
+}
+
+func (s *storePg) IsOwnerOfTask(taskId string, userId string) (bool, error) {
+	query := fmt.Sprintf("SELECT 1 FROM %s t JOIN %s p ON p.id = t.project_id WHERE t.id = $1 AND p.owner = $2", s.taskTable, s.projectTable)
+	return s.exists(query, taskId, userId)
+}
+
+func (s *storePg) IsMember(projectId string, userId string) (bool, error) {
+	query := fmt.Sprintf("SELECT 1 FROM %s WHERE id=$1 AND $2=ANY(users)", s.projectTable)
+	return s.exists(query, projectId, userId)
+}
+
+func (s *storePg) IsMemberOfTask(taskId string, userId string) (bool, error) {
+	query := fmt.Sprintf("SELECT 1 FROM %s t JOIN %s p ON p.id = t.project_id WHERE t.id = $1 AND $2=ANY(p.users)", s.taskTable, s.projectTable)
+	return s.exists(query, taskId, userId)
+}
+
+// CountMemberships returns the number of the given tasks, which belong to a project the user is a member of.
+func (s *storePg) CountMemberships(taskIds []string, userId string) (int, error) {
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s p, %s t WHERE t.project_id = p.id AND t.id = ANY($1) AND $2=ANY(p.users);", s.projectTable, s.taskTable)
+
+	var taskMemberships int
+	err := s.queryRow(query, []interface{}{pq.Array(taskIds), userId}, &taskMemberships)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to read task membership result")
+	}
+
+	return taskMemberships, nil
+}
+
+func (s *storePg) IsAssigned(taskId string, userId string) (bool, error) {
+	query := fmt.Sprintf("SELECT 1 FROM %s WHERE id=$1 AND assigned_user=$2", s.taskTable)
+	return s.exists(query, taskId, userId)
+}
+
+func (s *storePg) IsAllowedUser(taskId string, userId string) (bool, error) {
+	query := fmt.Sprintf("SELECT 1 FROM %s WHERE id=$1 AND (CARDINALITY(allowed_users)=0 OR $2=ANY(allowed_users))", s.taskTable)
+	return s.exists(query, taskId, userId)
+}
+
+// IsNotArchivedTask returns true when the task exists and its project has not been archived.
+func (s *storePg) IsNotArchivedTask(taskId string) (bool, error) {
+	query := fmt.Sprintf("SELECT 1 FROM %s t JOIN %s p ON p.id = t.project_id WHERE t.id = $1 AND p.archived = false", s.taskTable, s.projectTable)
+	return s.exists(query, taskId)
+}
+
+func (s *storePg) GetUserCount(projectId string) (int, error) {
+	query := fmt.Sprintf("SELECT ARRAY_LENGTH(users, 1) FROM %s WHERE id=$1;", s.projectTable)
+
+	var userCount int
+	err := s.queryRow(query, []interface{}{projectId}, &userCount)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("error getting user count of project %s", projectId))
+	}
+
+	return userCount, nil
+}
+
+func (s *storePg) GetUserCountOfTask(taskId string) (int, error) {
+	query := fmt.Sprintf("SELECT ARRAY_LENGTH(p.users, 1) FROM %s p, %s t WHERE $1 = t.id AND t.project_id = p.id;", s.projectTable, s.taskTable)
+
+	var userCount int
+	err := s.queryRow(query, []interface{}{taskId}, &userCount)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("error getting user count of project of task %s", taskId))
+	}
+
+	return userCount, nil
+}
+
+func (s *storePg) GetMinChangesetsOfTask(taskId string) (int, error) {
+	query := fmt.Sprintf("SELECT p.min_changesets FROM %s p, %s t WHERE $1 = t.id AND t.project_id = p.id;", s.projectTable, s.taskTable)
+
+	var minChangesets int
+	err := s.queryRow(query, []interface{}{taskId}, &minChangesets)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("error getting minimum changesets for task %s", taskId))
+	}
+
+	return minChangesets, nil
+}
+
+func (s *storePg) GetPointStepOfTask(taskId string) (int, error) {
+	query := fmt.Sprintf("SELECT p.point_step FROM %s p, %s t WHERE $1 = t.id AND t.project_id = p.id;", s.projectTable, s.taskTable)
+
+	var pointStep int
+	err := s.queryRow(query, []interface{}{taskId}, &pointStep)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("error getting point step for task %s", taskId))
+	}
+
+	return pointStep, nil
+}
+
+// GetAssignmentLimitsOfTask returns the maximum number of assigned tasks and completions per day of the project.
+func (s *storePg) GetAssignmentLimitsOfTask(taskId string) (int, int, error) {
+	query := fmt.Sprintf("SELECT p.max_assigned_tasks, p.max_completions_per_day FROM %s p, %s t WHERE $1 = t.id AND t.project_id = p.id;", s.projectTable, s.taskTable)
+
+	var maxAssignedTasks, maxCompletions int
+	err := s.queryRow(query, []interface{}{taskId}, &maxAssignedTasks, &maxCompletions)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, fmt.Sprintf("error getting assignment limits for task %s", taskId))
+	}
+
+	return maxAssignedTasks, maxCompletions, nil
+}
+
+// exists returns true when the query returns at least one row. The query is wrapped into "SELECT EXISTS (...)", so the
+// database stops at the first matching row and only a boolean is transferred instead of whole rows.
+func (s *storePg) exists(query string, args ...interface{}) (bool, error) {
+	query = fmt.Sprintf("SELECT EXISTS (%s);", query)
+
+	var result bool
+	err := s.queryRow(query, args, &result)
+	if err != nil {
+		return false, err
+	}
+
+	return result, nil
+}
+
+// queryRow executes the query and scans the first row into the destinations. A query without result row leads to an
+// error, since all queries of this store refer to a single project or task.
+func (s *storePg) queryRow(query string, args []interface{}, dest ...interface{}) error {
+	s.LogQuery(query, args...)
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+
+	err := s.tx.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return errors.New("no row found")
+	}
+
+	return err
+}
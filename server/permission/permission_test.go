@@ -1,6 +1,7 @@
 package permission
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/hauke96/sigolo"
@@ -34,14 +35,16 @@ func setup() {
 
 	logger := util.NewLogger()
 
+	ctx := context.Background()
+
 	var err error
-	tx, err = database.GetTransaction(logger)
+	tx, err = database.GetTransaction(ctx, logger)
 	if err != nil {
 		panic(err)
 	}
 
 	h.Tx = tx
-	s = Init(tx, logger)
+	s = Init(ctx, tx, logger)
 }
 
 func TestVerifyOwnership(t *testing.T) {
@@ -76,6 +79,38 @@ func TestVerifyOwnership(t *testing.T) {
 	})
 }
 
+func TestVerifyOwnershipTask(t *testing.T) {
+	h.Run(t, func() error {
+		err := s.VerifyOwnershipTask("3", "Maria")
+		if err != nil {
+			return fmt.Errorf("This should work: %s", err.Error())
+		}
+
+		// With member who is not the owner
+
+		err = s.VerifyOwnershipTask("3", "John")
+		if err == nil {
+			return fmt.Errorf("John is not the owner")
+		}
+
+		// With owner of another project
+
+		err = s.VerifyOwnershipTask("3", "Peter")
+		if err == nil {
+			return fmt.Errorf("Peter is not the owner of this project")
+		}
+
+		// With non existing task
+
+		err = s.VerifyOwnershipTask("143536", "Maria")
+		if err == nil {
+			return fmt.Errorf("This task not even exists")
+		}
+
+		return nil
+	})
+}
+
 func TestVerifyMembershipProject(t *testing.T) {
 	h.Run(t, func() error {
 		err := s.VerifyMembershipProject("1", "Peter")
@@ -214,6 +249,26 @@ func TestAssignmentInProjectNeeded(t *testing.T) {
 	})
 }
 
+func TestMinChangesetsForTask(t *testing.T) {
+	h.Run(t, func() error {
+		minChangesets, err := s.MinChangesetsForTask("3")
+		if err != nil {
+			return fmt.Errorf("Getting minimum changesets should work: %s", err.Error())
+		}
+		if minChangesets != 0 {
+			return fmt.Errorf("Task '3' shouldn't require any changesets")
+		}
+
+		// Not existing task
+		_, err = s.MinChangesetsForTask("84675")
+		if err == nil {
+			return fmt.Errorf("Getting minimum changesets for not existing task '84675' should not work")
+		}
+
+		return nil
+	})
+}
+
 func TestAssignmentInTaskNeeded(t *testing.T) {
 	h.Run(t, func() error {
 		// Assignment not needed
@@ -0,0 +1,74 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/hauke96/sigolo"
+	"github.com/pkg/errors"
+)
+
+const pseudonymPrefix = "mapper-"
+
+var (
+	enabled bool
+	key     []byte // Key of the HMAC the pseudonyms are derived from
+)
+
+// Configure turns the anonymization of user IDs on or off. The pseudonyms are derived from the key, so they stay the
+// same as long as the key doesn't change. Without key a random one is used, so the pseudonyms change after a restart.
+func Configure(anonymize bool, pseudonymKey string) error {
+	enabled = anonymize
+	if !anonymize {
+		key = nil
+		return nil
+	}
+
+	if pseudonymKey != "" {
+		key = []byte(pseudonymKey)
+		return nil
+	}
+
+	sigolo.Info("No key for pseudonyms set, they change after a restart")
+	key = make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return errors.Wrap(err, "unable to create random key for pseudonyms")
+	}
+
+	return nil
+}
+
+// Enabled returns true when user IDs have to be replaced by pseudonyms in statistics, exports and other data visible
+// to people other than the owner of a project.
+func Enabled() bool {
+	return enabled
+}
+
+// Pseudonym returns the pseudonym of the user (e.g. "mapper-3f9a1c0b2d4e"). The same user always gets the same
+// pseudonym, so that e.g. the events of one user can still be grouped. Empty IDs (like of unassigned tasks) stay empty.
+// When the anonymization is disabled, the user ID is returned unchanged.
+func Pseudonym(userId string) string {
+	if !enabled || userId == "" {
+		return userId
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(userId))
+	return pseudonymPrefix + hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// Pseudonyms works like "Pseudonym" for several users. The given slice is not changed.
+func Pseudonyms(userIds []string) []string {
+	if userIds == nil {
+		return nil
+	}
+
+	result := make([]string, len(userIds))
+	for i, userId := range userIds {
+		result[i] = Pseudonym(userId)
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+package privacy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPseudonym(t *testing.T) {
+	defer Configure(false, "")
+
+	err := Configure(false, "key")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if Pseudonym("Peter") != "Peter" {
+		t.Errorf("User IDs should not be changed when the anonymization is disabled")
+	}
+
+	err = Configure(true, "key")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	peter := Pseudonym("Peter")
+	if !strings.HasPrefix(peter, pseudonymPrefix) || len(peter) != len(pseudonymPrefix)+12 || strings.Contains(peter, "Peter") {
+		t.Errorf("Pseudonym '%s' has an unexpected format", peter)
+	}
+	if Pseudonym("Peter") != peter {
+		t.Errorf("Pseudonym of the same user should not change")
+	}
+	if Pseudonym("Maria") == peter {
+		t.Errorf("Different users should get different pseudonyms")
+	}
+	if Pseudonym("") != "" {
+		t.Errorf("Empty user IDs should stay empty")
+	}
+
+	users := []string{"Peter", "", "Maria"}
+	pseudonyms := Pseudonyms(users)
+	if len(pseudonyms) != 3 || pseudonyms[0] != peter || pseudonyms[1] != "" || pseudonyms[2] != Pseudonym("Maria") || users[0] != "Peter" {
+		t.Errorf("Unexpected pseudonyms %v of users %v", pseudonyms, users)
+	}
+
+	err = Configure(true, "other key")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if Pseudonym("Peter") == peter {
+		t.Errorf("Pseudonyms should depend on the key")
+	}
+
+	err = Configure(true, "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if Pseudonym("Peter") == peter || !strings.HasPrefix(Pseudonym("Peter"), pseudonymPrefix) {
+		t.Errorf("A random key should be used without configured key")
+	}
+}
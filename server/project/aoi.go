@@ -0,0 +1,84 @@
+package project
+
+import (
+	"fmt"
+
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+// UpdateAoi sets the area of interest of the project, e.g. a city boundary larger than the tasks. The empty AOI removes
+// the supplied one, the AOI is then the union of all tasks again. Only the owner can do this.
+func (s *ProjectService) UpdateAoi(projectId string, aoi string, requestingUserId string) (*Project, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if aoi != "" {
+		err = verifyAoi(aoi)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	project, err := s.store.UpdateAoi(projectId, aoi)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Updated AOI of project %s (supplied=%v)", project.Id, aoi != "")
+
+	err = s.addMetadata(project)
+	if err != nil {
+		s.Err("Unable to add process point data to project %s", project.Id)
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// verifyAoi checks that the AOI is a GeoJSON polygon or multipolygon with closed rings.
+func verifyAoi(aoi string) error {
+	geometry, err := geojson.UnmarshalGeometry([]byte(aoi))
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("invalid GeoJSON AOI: %s", aoi))
+	}
+
+	var polygons [][][][]float64
+	switch geometry.Type {
+	case geojson.GeometryPolygon:
+		polygons = [][][][]float64{geometry.Polygon}
+	case geojson.GeometryMultiPolygon:
+		polygons = geometry.MultiPolygon
+	default:
+		return errors.New(fmt.Sprintf("AOI must be a polygon or multipolygon but was %s", geometry.Type))
+	}
+
+	if len(polygons) == 0 {
+		return errors.New("AOI has no polygons")
+	}
+
+	for _, rings := range polygons {
+		if len(rings) == 0 {
+			return errors.New("AOI contains polygon without rings")
+		}
+
+		for _, ring := range rings {
+			if len(ring) < 4 {
+				return errors.New(fmt.Sprintf("rings of the AOI need at least 4 points but one has %d", len(ring)))
+			}
+
+			first, last := ring[0], ring[len(ring)-1]
+			if len(first) < 2 || len(last) < 2 || first[0] != last[0] || first[1] != last[1] {
+				return errors.New("rings of the AOI must be closed")
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package project
+
+import (
+	"github.com/hauke96/simple-task-manager/server/task"
+)
+
+// UpdateChecklist replaces the checklist of the project, see "task.PrepareChecklist". Items removed from the checklist
+// are also removed from the completed items of the tasks. When "checklistPoints" is true, the process points of the
+// tasks are derived from their completed items and can't be set directly anymore. Existing process points are updated
+// with the next change of the completed items of a task.
+func (s *ProjectService) UpdateChecklist(projectId string, checklist task.Checklist, checklistPoints bool, requestingUserId string) (*Project, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := s.store.GetProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	checklist, err = task.PrepareChecklist(project.Checklist, checklist)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err = s.store.UpdateChecklist(projectId, checklist, checklistPoints)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Updated checklist of project %s to %d items (derive process points: %v)", project.Id, len(checklist), checklistPoints)
+
+	err = s.addMetadata(project)
+	if err != nil {
+		s.Err("Unable to add process point data to project %s", project.Id)
+		return nil, err
+	}
+
+	return project, nil
+}
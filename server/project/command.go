@@ -0,0 +1,226 @@
+package project
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Values of the "Type" of a command
+const (
+	CommandUserAdded          = "user_added"
+	CommandUserRemoved        = "user_removed"
+	CommandNameChanged        = "name_changed"
+	CommandDescriptionChanged = "description_changed"
+)
+
+var (
+	revertWindow = 24 * time.Hour
+)
+
+// SetRevertWindow sets the time after which commands can't be reverted anymore.
+func SetRevertWindow(window time.Duration) error {
+	if window <= 0 {
+		return errors.New(fmt.Sprintf("revert window must be positive but was %s", window))
+	}
+
+	revertWindow = window
+
+	return nil
+}
+
+func (s *ProjectService) recordCommand(projectId string, userId string, commandType string, data CommandData) error {
+	command, err := s.store.AddCommand(projectId, userId, commandType, data)
+	if err != nil {
+		return err
+	}
+	s.Debug("Recorded command %s (%s) of project %s", command.Id, commandType, projectId)
+
+	return nil
+}
+
+// GetCommands returns all commands of the project, newest first. Only the owner is allowed to see them.
+func (s *ProjectService) GetCommands(projectId string, requestingUserId string) ([]*Command, error) {
+	err := s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.GetCommands(projectId)
+}
+
+// RevertCommand performs the compensating action of the command, e.g. adds a removed user again. Commands can only be
+// reverted within the revert window and only when nothing changed in the meantime (e.g. the name has not been changed
+// again). Only the owner is allowed to do this.
+func (s *ProjectService) RevertCommand(projectId string, commandId string, requestingUserId string) (*Project, error) {
+	command, err := s.getChangeableCommand(projectId, commandId, requestingUserId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.applyCommand(command, true)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.SetCommandReverted(commandId, requestingUserId, true)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Reverted command %s (%s) of project %s", commandId, command.Type, projectId)
+
+	return s.getProjectWithMetadata(projectId)
+}
+
+// RedoCommand undoes the revert of the command. The same restrictions as for "RevertCommand" apply.
+func (s *ProjectService) RedoCommand(projectId string, commandId string, requestingUserId string) (*Project, error) {
+	command, err := s.getChangeableCommand(projectId, commandId, requestingUserId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.applyCommand(command, false)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.SetCommandReverted(commandId, requestingUserId, false)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Redid command %s (%s) of project %s", commandId, command.Type, projectId)
+
+	return s.getProjectWithMetadata(projectId)
+}
+
+// getChangeableCommand returns the command when it belongs to the project, is still within the revert window and is
+// (not) reverted as requested. The project is locked until the end of the transaction, see "LockProject" of the store.
+func (s *ProjectService) getChangeableCommand(projectId string, commandId string, requestingUserId string, reverted bool) (*Command, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	command, err := s.store.GetCommand(commandId)
+	if err != nil {
+		return nil, err
+	}
+
+	if command.ProjectId != projectId {
+		return nil, errors.New(fmt.Sprintf("command %s does not belong to project %s", commandId, projectId))
+	}
+	if time.Since(command.CreatedAt) > revertWindow {
+		return nil, errors.New(fmt.Sprintf("command %s is older than %s and can't be changed anymore", commandId, revertWindow))
+	}
+	if reverted && command.RevertedAt == nil {
+		return nil, errors.New(fmt.Sprintf("command %s is not reverted", commandId))
+	}
+	if !reverted && command.RevertedAt != nil {
+		return nil, errors.New(fmt.Sprintf("command %s is already reverted", commandId))
+	}
+
+	return command, nil
+}
+
+// applyCommand performs the compensating action of the command (revert) or the command itself again (redo). No new
+// commands are recorded for this.
+func (s *ProjectService) applyCommand(command *Command, revert bool) error {
+	project, err := s.store.GetProject(command.ProjectId)
+	if err != nil {
+		return err
+	}
+
+	isMember := false
+	for _, u := range project.Users {
+		isMember = isMember || u == command.Data.UserId
+	}
+
+	switch command.Type {
+	case CommandUserAdded, CommandUserRemoved:
+		// Reverting an addition has the same effect as redoing a removal and vice versa
+		addUser := (command.Type == CommandUserAdded) != revert
+
+		if addUser {
+			if isMember {
+				return errors.New(fmt.Sprintf("user %s is already a member of project %s", command.Data.UserId, command.ProjectId))
+			}
+
+			err = verifyUserCount(len(project.Users) + 1)
+			if err != nil {
+				return err
+			}
+
+			_, err = s.store.AddUser(command.ProjectId, command.Data.UserId)
+			if err != nil {
+				return err
+			}
+
+			if command.Type == CommandUserRemoved {
+				s.restoreAssignments(command.Data.UserId, command.Data.TaskIds)
+			}
+			return nil
+		}
+
+		if !isMember {
+			return errors.New(fmt.Sprintf("user %s is not a member of project %s", command.Data.UserId, command.ProjectId))
+		}
+		if command.Data.UserId == project.Owner {
+			return errors.New("removing the owner is not allowed")
+		}
+
+		_, _, err = s.removeUserAndUnassign(command.ProjectId, command.Data.UserId)
+		return err
+	case CommandNameChanged, CommandDescriptionChanged:
+		currentValue, targetValue := command.Data.NewValue, command.Data.OldValue
+		if !revert {
+			currentValue, targetValue = targetValue, currentValue
+		}
+
+		if command.Type == CommandNameChanged {
+			if project.Name != currentValue {
+				return errors.New(fmt.Sprintf("name of project %s has been changed in the meantime", command.ProjectId))
+			}
+			_, err = s.store.UpdateName(command.ProjectId, targetValue)
+			return err
+		}
+
+		if project.Description != currentValue {
+			return errors.New(fmt.Sprintf("description of project %s has been changed in the meantime", command.ProjectId))
+		}
+		_, err = s.store.UpdateDescription(command.ProjectId, targetValue)
+		return err
+	}
+
+	return errors.New(fmt.Sprintf("unknown command type '%s'", command.Type))
+}
+
+// restoreAssignments assigns the user again to the tasks. Tasks assigned to someone else in the meantime are skipped.
+func (s *ProjectService) restoreAssignments(userId string, taskIds []string) {
+	for _, taskId := range taskIds {
+		_, err := s.taskService.RestoreAssignment(taskId, userId)
+		if err != nil {
+			s.Log("Unable to restore assignment of user %s to task %s: %s", userId, taskId, err.Error())
+		}
+	}
+}
+
+func (s *ProjectService) getProjectWithMetadata(projectId string) (*Project, error) {
+	project, err := s.store.GetProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.addMetadata(project)
+	if err != nil {
+		s.Err("Unable to add process point data to project %s", project.Id)
+		return nil, err
+	}
+
+	return project, nil
+}
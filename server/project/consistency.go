@@ -0,0 +1,67 @@
+package project
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/quota"
+	"github.com/hauke96/simple-task-manager/server/task"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Kinds of inconsistencies found by "CheckConsistency"
+const (
+	InconsistencyOrphanedTask         = "orphaned-task"           // Task of a project which doesn't exist
+	InconsistencyMissingDependency    = "missing-dependency"      // Task depends on a task which doesn't exist or belongs to another project
+	InconsistencyNonMemberAssignment  = "non-member-assignment"   // Task is assigned to a user who isn't member of the project
+	InconsistencyNonMemberAllowedUser = "non-member-allowed-user" // Task is only allowed for a user who isn't member of the project
+)
+
+// CheckConsistencyJob creates a job for the scheduler, which checks the references of all tasks and repairs broken ones
+// when "repair" is true.
+func CheckConsistencyJob(repair bool) func(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	return func(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+		permissionService := permission.Init(ctx, tx, logger)
+		taskService := task.Init(ctx, tx, logger, permissionService)
+		_, err := Init(ctx, tx, logger, taskService, permissionService, quota.Init(ctx, tx, logger)).CheckConsistency(repair)
+		return err
+	}
+}
+
+// CheckConsistency finds tasks of missing projects, dependencies on missing tasks and tasks assigned or allowed to users
+// who aren't member of the project. With "repair", orphaned tasks are removed and all other broken references are
+// removed from the tasks. Inconsistencies are logged, since they shouldn't happen.
+func (s *ProjectService) CheckConsistency(repair bool) ([]*Inconsistency, error) {
+	inconsistencies, err := s.store.GetInconsistencies()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(inconsistencies) == 0 {
+		s.Log("References of all tasks are consistent")
+		return inconsistencies, nil
+	}
+
+	for _, i := range inconsistencies {
+		s.Err("Inconsistency '%s' of task %s in project %s: %s", i.Kind, i.TaskId, i.ProjectId, i.Reference)
+
+		if !repair {
+			continue
+		}
+
+		err = s.store.RepairInconsistency(i)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("error repairing inconsistency '%s' of task %s", i.Kind, i.TaskId))
+		}
+		i.Repaired = true
+	}
+
+	if repair {
+		s.Log("Repaired %d inconsistencies", len(inconsistencies))
+	}
+
+	return inconsistencies, nil
+}
@@ -0,0 +1,112 @@
+package project
+
+import (
+	"fmt"
+
+	"github.com/hauke96/simple-task-manager/server/privacy"
+	"github.com/pkg/errors"
+)
+
+// NearbyProject is a public project found by "GetNearbyProjects".
+type NearbyProject struct {
+	*Project
+	Distance float64 // Meters between the location and the nearest task of the project
+}
+
+var (
+	maxNearbyRadius   = 500000.0 // Meters
+	maxNearbyProjects = 100
+)
+
+// UpdatePublic sets whether everyone can find the project. Users still have to be added (e.g. via a join request) to
+// work on it. Only the owner can do this.
+func (s *ProjectService) UpdatePublic(projectId string, public bool, requestingUserId string) (*Project, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := s.store.GetProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+	if public && project.Tutorial {
+		return nil, errors.New(fmt.Sprintf("tutorial project %s can't be public", projectId))
+	}
+
+	project, err = s.store.UpdatePublic(projectId, public)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Set project %s to public=%v", project.Id, public)
+
+	err = s.addMetadata(project)
+	if err != nil {
+		s.Err("Unable to add process point data to project %s", project.Id)
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// GetNearbyProjects returns the public projects with tasks within the radius (in meters) around the location, nearest
+// projects first. Archived projects are not returned. Other than for members, the users of the projects are not part
+// of the result and the owners are anonymized when this is enabled (see "privacy.Pseudonym").
+func (s *ProjectService) GetNearbyProjects(lat float64, lon float64, radius float64) ([]*NearbyProject, error) {
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return nil, errors.New(fmt.Sprintf("location %f, %f is not a valid coordinate", lat, lon))
+	}
+	if radius <= 0 || radius > maxNearbyRadius {
+		return nil, errors.New(fmt.Sprintf("radius must be between 0 and %.0f meters but was %f", maxNearbyRadius, radius))
+	}
+
+	projectIds, distances, err := s.store.GetNearbyProjectIds(lat, lon, radius, maxNearbyProjects)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*NearbyProject, len(projectIds))
+	for i, projectId := range projectIds {
+		project, err := s.getProjectWithMetadata(projectId)
+		if err != nil {
+			return nil, err
+		}
+
+		hideMembers(project)
+		result[i] = &NearbyProject{
+			Project:  project,
+			Distance: distances[i],
+		}
+	}
+
+	s.Log("Found %d projects within %.0f meters around %f, %f", len(result), radius, lat, lon)
+
+	return result, nil
+}
+
+// GetPublicProject returns the public project to users who aren't necessarily members of it, e.g. to watch it. Like for
+// "GetNearbyProjects", the users are not part of the result and the owner is anonymized when this is enabled.
+func (s *ProjectService) GetPublicProject(projectId string) (*Project, error) {
+	project, err := s.getProjectWithMetadata(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !project.Public {
+		return nil, errors.New(fmt.Sprintf("project %s is not public", projectId))
+	}
+
+	hideMembers(project)
+	return project, nil
+}
+
+// hideMembers removes the users from the project and anonymizes the owner (see "privacy.Pseudonym") for non-members.
+func hideMembers(project *Project) {
+	project.Users = []string{}
+	project.Owner = privacy.Pseudonym(project.Owner)
+}
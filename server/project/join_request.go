@@ -0,0 +1,99 @@
+package project
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// RequestJoin stores the request of the user to join the project and returns it together with the owner of the
+// project, who has to decide about it. Members of the project can't request to join it. Requesting again doesn't
+// create a second request.
+func (s *ProjectService) RequestJoin(projectId string, requestingUserId string) (*JoinRequest, string, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = s.permissionService.VerifyMembershipProject(projectId, requestingUserId)
+	if err == nil {
+		return nil, "", errors.New(fmt.Sprintf("user %s is already a member of project %s", requestingUserId, projectId))
+	}
+
+	project, err := s.store.GetProject(projectId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	request, err := s.store.AddJoinRequest(projectId, requestingUserId)
+	if err != nil {
+		return nil, "", err
+	}
+	s.Log("User %s requested to join project %s", requestingUserId, projectId)
+
+	return request, project.Owner, nil
+}
+
+// GetJoinRequests returns all open join requests of the project, oldest first. Only the owner is allowed to see them.
+func (s *ProjectService) GetJoinRequests(projectId string, requestingUserId string) ([]*JoinRequest, error) {
+	err := s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.GetJoinRequests(projectId)
+}
+
+// GetJoinRequestsOfOwner returns the open join requests of all projects owned by the requesting user, oldest first.
+func (s *ProjectService) GetJoinRequestsOfOwner(requestingUserId string) ([]*JoinRequest, error) {
+	return s.store.GetJoinRequestsOfOwner(requestingUserId)
+}
+
+// ApproveJoinRequest adds the user of the join request to the project and removes the request. Only the owner is
+// allowed to do this.
+func (s *ProjectService) ApproveJoinRequest(projectId string, userId string, requestingUserId string) (*Project, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.RemoveJoinRequest(projectId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := s.AddUser(projectId, userId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Approved join request of user %s for project %s", userId, projectId)
+
+	return project, nil
+}
+
+// DenyJoinRequest removes the join request without adding the user to the project. Only the owner is allowed to do
+// this.
+func (s *ProjectService) DenyJoinRequest(projectId string, userId string, requestingUserId string) error {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.RemoveJoinRequest(projectId, userId)
+	if err != nil {
+		return err
+	}
+	s.Log("Denied join request of user %s for project %s", userId, projectId)
+
+	return nil
+}
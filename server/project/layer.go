@@ -0,0 +1,136 @@
+package project
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Values of the "Type" of a Layer
+const (
+	LayerTypeTms = "tms"
+	LayerTypeWms = "wms"
+)
+
+const (
+	maxLayers                 = 10
+	maxLayerNameLength        = 100
+	maxLayerAttributionLength = 1000
+	maxLayerZoom              = 24
+)
+
+// Placeholders like "{z}" or "{switch:a,b,c}" in the URLs of layers, which are replaced by the clients
+var layerPlaceholderRegex = regexp.MustCompile(`\{[^{}]*\}`)
+
+// UpdateLayers replaces all layers of the project. The order of the layers is kept, so the owner can put the
+// preferred imagery first.
+func (s *ProjectService) UpdateLayers(projectId string, layers []*Layer, requestingUserId string) (*Project, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if layers == nil {
+		layers = make([]*Layer, 0)
+	}
+
+	err = verifyLayers(layers)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := s.store.UpdateLayers(projectId, layers)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Updated layers of project %s to %d layers", project.Id, len(layers))
+
+	err = s.addMetadata(project)
+	if err != nil {
+		s.Err("Unable to add process point data to project %s", project.Id)
+		return nil, err
+	}
+
+	return project, nil
+}
+
+func verifyLayers(layers []*Layer) error {
+	if len(layers) > maxLayers {
+		return errors.New(fmt.Sprintf("A project can have at most %d layers", maxLayers))
+	}
+
+	names := make(map[string]bool)
+	for _, l := range layers {
+		if l == nil {
+			return errors.New("Layer must not be empty")
+		}
+
+		l.Name = strings.TrimSpace(l.Name)
+		if l.Name == "" || len(l.Name) > maxLayerNameLength {
+			return errors.New(fmt.Sprintf("Layer name must have 1 to %d characters", maxLayerNameLength))
+		}
+
+		if names[l.Name] {
+			return errors.New(fmt.Sprintf("There's already a layer named '%s'", l.Name))
+		}
+		names[l.Name] = true
+
+		err := verifyLayerUrl(l.Type, l.Url)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("Invalid layer '%s'", l.Name))
+		}
+
+		if len(l.Attribution) > maxLayerAttributionLength {
+			return errors.New(fmt.Sprintf("Attribution of layer '%s' must not be longer than %d characters", l.Name, maxLayerAttributionLength))
+		}
+
+		if l.MaxZoom < 0 || l.MaxZoom > maxLayerZoom {
+			return errors.New(fmt.Sprintf("Maximum zoom of layer '%s' must be between 0 and %d but was %d", l.Name, maxLayerZoom, l.MaxZoom))
+		}
+	}
+
+	return nil
+}
+
+// verifyLayerUrl checks that the URL is an HTTP(S) URL with the placeholders needed for the type of the layer.
+func verifyLayerUrl(layerType string, layerUrl string) error {
+	var requiredPlaceholders []string
+	switch layerType {
+	case LayerTypeTms:
+		requiredPlaceholders = []string{"{z}", "{x}", "{y}"}
+	case LayerTypeWms:
+		requiredPlaceholders = []string{"{bbox}"}
+	default:
+		return errors.New(fmt.Sprintf("unknown layer type '%s', use '%s' or '%s'", layerType, LayerTypeTms, LayerTypeWms))
+	}
+
+	for _, placeholder := range requiredPlaceholders {
+		if !strings.Contains(layerUrl, placeholder) {
+			return errors.New(fmt.Sprintf("URL of %s layer must contain the placeholder '%s'", layerType, placeholder))
+		}
+	}
+
+	// Placeholders (e.g. for subdomains) are no valid characters of host names
+	parsedUrl, err := url.Parse(layerPlaceholderRegex.ReplaceAllString(layerUrl, "x"))
+	if err != nil {
+		return errors.Wrap(err, "unable to parse URL")
+	}
+
+	if parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https" {
+		return errors.New(fmt.Sprintf("URL must use HTTP or HTTPS but uses '%s'", parsedUrl.Scheme))
+	}
+
+	if parsedUrl.Host == "" {
+		return errors.New("URL must contain a host")
+	}
+
+	return nil
+}
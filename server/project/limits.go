@@ -0,0 +1,66 @@
+package project
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Limits are the validation limits of projects, which can be configured per deployment. A limit of 0 means that there
+// is no limit.
+type Limits struct {
+	MaxDescriptionLength int // Characters of the description and each of its translations
+	MaxNameLength        int
+	MaxTasks             int // Tasks per project, in addition to the quota of the owner
+	MaxUsers             int // Members per project including the owner
+}
+
+var (
+	limits = Limits{
+		MaxDescriptionLength: 10000,
+	}
+)
+
+// SetLimits sets the validation limits of all projects.
+func SetLimits(newLimits Limits) error {
+	if newLimits.MaxDescriptionLength < 0 || newLimits.MaxNameLength < 0 || newLimits.MaxTasks < 0 || newLimits.MaxUsers < 0 {
+		return errors.New(fmt.Sprintf("project limits must not be negative but were %#v", newLimits))
+	}
+
+	limits = newLimits
+
+	return nil
+}
+
+// GetLimits returns the validation limits of all projects.
+func GetLimits() Limits {
+	return limits
+}
+
+func verifyNameLength(name string) error {
+	if limits.MaxNameLength > 0 && len(name) > limits.MaxNameLength {
+		return errors.New(fmt.Sprintf("Name too long. Maximum allowed are %d characters.", limits.MaxNameLength))
+	}
+	return nil
+}
+
+func verifyDescriptionLength(description string) error {
+	if limits.MaxDescriptionLength > 0 && len(description) > limits.MaxDescriptionLength {
+		return errors.New(fmt.Sprintf("Description too long. Maximum allowed are %d characters.", limits.MaxDescriptionLength))
+	}
+	return nil
+}
+
+func verifyUserCount(userCount int) error {
+	if limits.MaxUsers > 0 && userCount > limits.MaxUsers {
+		return errors.New(fmt.Sprintf("Too many users (%d). Maximum allowed are %d users per project.", userCount, limits.MaxUsers))
+	}
+	return nil
+}
+
+func verifyTaskCount(taskCount int) error {
+	if limits.MaxTasks > 0 && taskCount > limits.MaxTasks {
+		return errors.New(fmt.Sprintf("Too many tasks (%d). Maximum allowed are %d tasks per project.", taskCount, limits.MaxTasks))
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package project
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Roles of members of a project
+const (
+	MemberRoleOwner  = "owner"
+	MemberRoleMember = "member"
+)
+
+// Member is a user of a project together with their activity, e.g. for coordinators reporting the participation of
+// volunteers.
+type Member struct {
+	UserId         string     `json:"userId"`
+	Role           string     `json:"role"`           // One of the "MemberRole..." values
+	JoinedAt       time.Time  `json:"joinedAt"`       // Time the user has been added, the creation of the project for initial members
+	LastActivity   *time.Time `json:"lastActivity"`   // Latest change of a task by the user, "nil" when the user never worked on a task
+	Tasks          int        `json:"tasks"`          // Number of tasks the user worked on
+	CompletedTasks int        `json:"completedTasks"` // Number of tasks the user completed
+	ProcessPoints  int        `json:"processPoints"`  // Sum of the process point changes made by the user
+}
+
+// GetMembers returns all members of the project with their activity, the owner first. Only the owner is allowed to see
+// them.
+func (s *ProjectService) GetMembers(projectId string, requestingUserId string) ([]*Member, error) {
+	err := s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := s.store.GetProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	joinDates, err := s.store.GetJoinDates(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	activities, err := s.taskService.GetMemberActivities(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	members := []*Member{newMember(project, project.Owner, joinDates)}
+	for _, userId := range project.Users {
+		if userId != project.Owner {
+			members = append(members, newMember(project, userId, joinDates))
+		}
+	}
+
+	for _, member := range members {
+		activity, ok := activities[member.UserId]
+		if !ok {
+			continue
+		}
+
+		lastActivity := activity.LastActivity
+		member.LastActivity = &lastActivity
+		member.Tasks = activity.Tasks
+		member.CompletedTasks = activity.CompletedTasks
+		member.ProcessPoints = activity.ProcessPoints
+	}
+
+	return members, nil
+}
+
+func newMember(project *Project, userId string, joinDates map[string]time.Time) *Member {
+	member := &Member{
+		UserId:   userId,
+		Role:     MemberRoleMember,
+		JoinedAt: project.CreatedAt,
+	}
+
+	if userId == project.Owner {
+		member.Role = MemberRoleOwner
+	}
+
+	joinedAt, ok := joinDates[userId]
+	if ok {
+		member.JoinedAt = joinedAt
+	}
+
+	return member
+}
+
+// ExportMembersCsv creates a CSV file with one line per member. The last activity is empty for members who never
+// worked on a task.
+func ExportMembersCsv(members []*Member) ([]byte, error) {
+	lines := [][]string{{"user_id", "role", "joined_at", "last_activity", "tasks", "completed_tasks", "process_points"}}
+
+	for _, m := range members {
+		lastActivity := ""
+		if m.LastActivity != nil {
+			lastActivity = m.LastActivity.Format(time.RFC3339)
+		}
+
+		lines = append(lines, []string{m.UserId, m.Role, m.JoinedAt.Format(time.RFC3339), lastActivity, strconv.Itoa(m.Tasks), strconv.Itoa(m.CompletedTasks), strconv.Itoa(m.ProcessPoints)})
+	}
+
+	var buffer bytes.Buffer
+
+	writer := csv.NewWriter(&buffer)
+	err := writer.WriteAll(lines)
+	if err != nil {
+		return nil, errors.Wrap(err, "error writing CSV")
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,170 @@
+package project
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// RequestMerge requests to merge the source into the target project, e.g. when two coordinators created overlapping
+// projects. The requesting user has to own one of the projects. When the user owns both, the projects are merged
+// immediately and the archived source and merged target project are returned instead of a request.
+func (s *ProjectService) RequestMerge(sourceProjectId string, targetProjectId string, requestingUserId string) (*MergeRequest, *Project, *Project, error) {
+	source, target, err := s.getMergeableProjects(sourceProjectId, targetProjectId)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if source.Owner != requestingUserId && target.Owner != requestingUserId {
+		return nil, nil, nil, errors.New(fmt.Sprintf("user %s owns neither project %s nor %s", requestingUserId, sourceProjectId, targetProjectId))
+	}
+
+	if source.Owner == target.Owner {
+		mergedSource, mergedTarget, err := s.merge(sourceProjectId, targetProjectId)
+		return nil, mergedSource, mergedTarget, err
+	}
+
+	request, err := s.store.AddMergeRequest(sourceProjectId, targetProjectId, requestingUserId)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	s.Log("User %s requested to merge project %s into %s", requestingUserId, sourceProjectId, targetProjectId)
+
+	return request, nil, nil, nil
+}
+
+// GetMergeRequests returns all open merge requests with the project as source or target, oldest first. Only the owner
+// is allowed to see them.
+func (s *ProjectService) GetMergeRequests(projectId string, requestingUserId string) ([]*MergeRequest, error) {
+	err := s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.GetMergeRequests(projectId)
+}
+
+// ApproveMerge merges the projects of the request and returns the archived source and the merged target project. Only
+// the approver of the request is allowed to do this.
+func (s *ProjectService) ApproveMerge(sourceProjectId string, targetProjectId string, requestingUserId string) (*Project, *Project, error) {
+	request, err := s.store.GetMergeRequest(sourceProjectId, targetProjectId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if request.Approver != requestingUserId {
+		return nil, nil, errors.New(fmt.Sprintf("user %s is not allowed to approve the merge of project %s into %s", requestingUserId, sourceProjectId, targetProjectId))
+	}
+
+	_, _, err = s.getMergeableProjects(sourceProjectId, targetProjectId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.merge(sourceProjectId, targetProjectId)
+}
+
+// DenyMerge removes the merge request. The owners of both projects are allowed to do this, so the requesting user can
+// withdraw the request as well.
+func (s *ProjectService) DenyMerge(sourceProjectId string, targetProjectId string, requestingUserId string) error {
+	err := s.lockBothProjects(sourceProjectId, targetProjectId)
+	if err != nil {
+		return err
+	}
+
+	sourceErr := s.permissionService.VerifyOwnership(sourceProjectId, requestingUserId)
+	targetErr := s.permissionService.VerifyOwnership(targetProjectId, requestingUserId)
+	if sourceErr != nil && targetErr != nil {
+		return errors.New(fmt.Sprintf("user %s owns neither project %s nor %s", requestingUserId, sourceProjectId, targetProjectId))
+	}
+
+	err = s.store.RemoveMergeRequest(sourceProjectId, targetProjectId)
+	if err != nil {
+		return err
+	}
+	s.Log("Denied merge of project %s into %s", sourceProjectId, targetProjectId)
+
+	return nil
+}
+
+// getMergeableProjects locks both projects and returns them when they can be merged, which isn't possible for archived
+// projects.
+func (s *ProjectService) getMergeableProjects(sourceProjectId string, targetProjectId string) (*Project, *Project, error) {
+	if sourceProjectId == targetProjectId {
+		return nil, nil, errors.New(fmt.Sprintf("project %s can't be merged into itself", sourceProjectId))
+	}
+
+	err := s.lockBothProjects(sourceProjectId, targetProjectId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	source, err := s.store.GetProject(sourceProjectId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target, err := s.store.GetProject(targetProjectId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if source.Archived || target.Archived {
+		return nil, nil, errors.New(fmt.Sprintf("archived projects can't be merged (project %s into %s)", sourceProjectId, targetProjectId))
+	}
+
+	if source.Tutorial || target.Tutorial {
+		return nil, nil, errors.New(fmt.Sprintf("tutorial projects can't be merged (project %s into %s)", sourceProjectId, targetProjectId))
+	}
+
+	err = verifyTaskCount(len(source.TaskIDs) + len(target.TaskIDs))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	members := make(map[string]bool)
+	for _, u := range append(source.Users, target.Users...) {
+		members[u] = true
+	}
+	err = verifyUserCount(len(members))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return source, target, nil
+}
+
+// lockBothProjects locks the projects always in the same order, so that e.g. two merges of the same projects in
+// opposite directions don't deadlock.
+func (s *ProjectService) lockBothProjects(projectIdA string, projectIdB string) error {
+	if projectIdB < projectIdA {
+		projectIdA, projectIdB = projectIdB, projectIdA
+	}
+
+	err := s.store.LockProject(projectIdA)
+	if err != nil {
+		return err
+	}
+
+	return s.store.LockProject(projectIdB)
+}
+
+func (s *ProjectService) merge(sourceProjectId string, targetProjectId string) (*Project, *Project, error) {
+	err := s.store.MergeProjects(sourceProjectId, targetProjectId)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.Log("Merged project %s into %s", sourceProjectId, targetProjectId)
+
+	source, err := s.getProjectWithMetadata(sourceProjectId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target, err := s.getProjectWithMetadata(targetProjectId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return source, target, nil
+}
@@ -1,56 +1,95 @@
 package project
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/quota"
+	"github.com/hauke96/simple-task-manager/server/storage"
 	"github.com/hauke96/simple-task-manager/server/task"
 	"github.com/hauke96/simple-task-manager/server/util"
 	"github.com/pkg/errors"
 	"strings"
+	"time"
 )
 
-type Project struct {
-	Id                 string   `json:"id"`
-	Name               string   `json:"name"`
-	TaskIDs            []string `json:"taskIds"` // TODO remove?
-	Users              []string `json:"users"`
-	Owner              string   `json:"owner"`
-	Description        string   `json:"description"`
-	NeedsAssignment    bool     `json:"needsAssignment"`    // When "true", the tasks of this project need to have an assigned user
-	TotalProcessPoints int      `json:"totalProcessPoints"` // Sum of all maximum process points of all tasks
-	DoneProcessPoints  int      `json:"doneProcessPoints"`  // Sum of all process points that have been set
+// Actions of a UserChangeResult
+const (
+	UserChangeAdd    = "add"
+	UserChangeRemove = "remove"
+)
+
+// UserChangeResult states whether adding or removing one user during a batch change worked.
+type UserChangeResult struct {
+	UserId  string `json:"uid"`
+	Action  string `json:"action"` // Either "add" or "remove"
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"` // Reason why the change was not possible
 }
 
 type ProjectService struct {
 	*util.Logger
-	store             *storePg
+	store             storage.ProjectStore
 	permissionService *permission.PermissionService
 	taskService       *task.TaskService
+	quotaService      *quota.QuotaService
 }
 
 var (
-	maxDescriptionLength = 10000
+	// OSM tag values can't be longer than this
+	maxChangesetCommentLength = 255
 )
 
-func Init(tx *sql.Tx, logger *util.Logger, taskService *task.TaskService, permissionService *permission.PermissionService) *ProjectService {
+// MaxChangesetCommentLength returns the number of characters the changeset comment template can have at most.
+func MaxChangesetCommentLength() int {
+	return maxChangesetCommentLength
+}
+
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger, taskService *task.TaskService, permissionService *permission.PermissionService, quotaService *quota.QuotaService) *ProjectService {
 	return &ProjectService{
 		Logger:            logger,
-		store:             getStore(tx, logger),
+		store:             getStore(ctx, tx, logger),
 		permissionService: permissionService,
 		taskService:       taskService,
+		quotaService:      quotaService,
+	}
+}
+
+// RecordSnapshotsJob is meant to be executed by the scheduler. It stores the current progress of all projects.
+func RecordSnapshotsJob(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	permissionService := permission.Init(ctx, tx, logger)
+	taskService := task.Init(ctx, tx, logger, permissionService)
+	return Init(ctx, tx, logger, taskService, permissionService, quota.Init(ctx, tx, logger)).RecordSnapshots()
+}
+
+// RepairProgressJob is meant to be executed by the scheduler. It fixes process point sums of projects that drifted
+// apart from their tasks.
+func RepairProgressJob(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	permissionService := permission.Init(ctx, tx, logger)
+	taskService := task.Init(ctx, tx, logger, permissionService)
+	return Init(ctx, tx, logger, taskService, permissionService, quota.Init(ctx, tx, logger)).RepairProgress()
+}
+
+// ArchiveCompletedProjectsJob creates a job for the scheduler, which archives all projects completed longer than the
+// grace period ago.
+func ArchiveCompletedProjectsJob(gracePeriod time.Duration) func(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	return func(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+		permissionService := permission.Init(ctx, tx, logger)
+		taskService := task.Init(ctx, tx, logger, permissionService)
+		return Init(ctx, tx, logger, taskService, permissionService, quota.Init(ctx, tx, logger)).ArchiveCompletedProjects(gracePeriod)
 	}
 }
 
 func (s *ProjectService) GetProjects(userId string) ([]*Project, error) {
-	projects, err := s.store.getProjects(userId)
+	projects, err := s.store.GetProjects(userId)
 	if err != nil {
 		s.Err(fmt.Sprintf("Error getting projects for user %s", userId))
 		return nil, err
 	}
 
 	for _, p := range projects {
-		err = s.addMetadata(p, userId)
+		err = s.addMetadata(p)
 		if err != nil {
 			s.Err("Unable to add process point data to project %s", p.Id)
 			return nil, err
@@ -60,14 +99,49 @@ func (s *ProjectService) GetProjects(userId string) ([]*Project, error) {
 	return projects, nil
 }
 
+// GetOwnedProjects returns all projects owned by the given user.
+func (s *ProjectService) GetOwnedProjects(userId string) ([]*Project, error) {
+	projects, err := s.GetProjects(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	ownedProjects := make([]*Project, 0)
+	for _, p := range projects {
+		if p.Owner == userId {
+			ownedProjects = append(ownedProjects, p)
+		}
+	}
+
+	return ownedProjects, nil
+}
+
+// FilterChangedSince returns all projects changed after the given time.
+func FilterChangedSince(projects []*Project, since time.Time) []*Project {
+	result := make([]*Project, 0)
+
+	for _, p := range projects {
+		if p.UpdatedAt.After(since) {
+			result = append(result, p)
+		}
+	}
+
+	return result
+}
+
 func (s *ProjectService) GetProjectByTask(taskId string, userId string) (*Project, error) {
-	project, err := s.store.getProjectByTask(taskId)
+	err := s.permissionService.VerifyMembershipTask(taskId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := s.store.GetProjectByTask(taskId)
 	if err != nil {
 		s.Err("Error getting project with task %s", taskId)
 		return nil, err
 	}
 
-	err = s.addMetadata(project, userId)
+	err = s.addMetadata(project)
 	if err != nil {
 		s.Err("Unable to add process point data to project %s", project.Id)
 		return nil, err
@@ -79,6 +153,19 @@ func (s *ProjectService) GetProjectByTask(taskId string, userId string) (*Projec
 // AddProjectWithTasks takes the project and the tasks and adds them to the database. This also adds the process-point
 // metadata to the returned project.
 func (s *ProjectService) AddProjectWithTasks(projectDraft *Project, taskDrafts []*task.Task) (*Project, error) {
+	err := verifyTaskCount(len(taskDrafts))
+	if err != nil {
+		return nil, err
+	}
+
+	// Tutorial projects don't count towards the quota, so they must not be blocked by it either
+	if !projectDraft.Tutorial {
+		err = s.quotaService.VerifyNewProject(projectDraft.Owner, len(taskDrafts))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	//
 	// Store project
 	//
@@ -89,20 +176,33 @@ func (s *ProjectService) AddProjectWithTasks(projectDraft *Project, taskDrafts [
 	}
 	s.Log("Added project %s", addedProject.Id)
 
+	for _, t := range taskDrafts {
+		if t.Difficulty == "" {
+			t.Difficulty = addedProject.DefaultDifficulty
+		}
+	}
+
 	//
 	// Store tasks
 	//
 
-	_, err = s.taskService.AddTasks(taskDrafts, addedProject.Id)
+	addedTasks, err := s.taskService.AddTasks(taskDrafts, addedProject.Id)
 	if err != nil {
 		return nil, err
 	}
 	s.Log("Added tasks")
 
+	addedProject.TaskIDs = make([]string, len(addedTasks))
+	for i, t := range addedTasks {
+		addedProject.TaskIDs[i] = t.Id
+		addedProject.DoneProcessPoints += t.ProcessPoints
+		addedProject.TotalProcessPoints += t.MaxProcessPoints
+	}
+
 	//
 	// Add Metadata now, that we have tasks
 	//
-	err = s.addMetadata(addedProject, addedProject.Owner)
+	err = s.addMetadata(addedProject)
 	if err != nil {
 		return nil, err
 	}
@@ -134,13 +234,85 @@ func (s *ProjectService) AddProject(projectDraft *Project) (*Project, error) {
 		return nil, errors.New("Project must have a title")
 	}
 
-	if len(projectDraft.Description) > maxDescriptionLength {
-		return nil, errors.New(fmt.Sprintf("Description too long. Maximum allowed are %d characters.", maxDescriptionLength))
+	err := verifyNameLength(projectDraft.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifyDescriptionLength(projectDraft.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifyUserCount(len(projectDraft.Users))
+	if err != nil {
+		return nil, err
+	}
+
+	if projectDraft.DefaultDifficulty == "" {
+		projectDraft.DefaultDifficulty = task.DifficultyMedium
+	}
+	if !task.IsValidDifficulty(projectDraft.DefaultDifficulty) {
+		return nil, errors.New(fmt.Sprintf("Unknown default difficulty '%s'", projectDraft.DefaultDifficulty))
+	}
+
+	err = verifyLocalization(projectDraft.Locale, projectDraft.Descriptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if projectDraft.MinChangesets < 0 {
+		return nil, errors.New("Minimum number of changesets must not be negative")
+	}
+
+	if projectDraft.MaxAssignedTasks < 0 || projectDraft.MaxCompletions < 0 {
+		return nil, errors.New("Assignment limits must not be negative")
+	}
+
+	if projectDraft.PointStep < 0 {
+		return nil, errors.New("Point step must not be negative")
+	}
+
+	if projectDraft.UnassignAfterHours < 0 {
+		return nil, errors.New("Hours until tasks get unassigned must not be negative")
+	}
+
+	if projectDraft.Tutorial && projectDraft.Public {
+		return nil, errors.New("Tutorial projects can't be public")
+	}
+
+	if len(projectDraft.GeometryTypes) == 0 {
+		projectDraft.GeometryTypes = []string{task.GeometryTypePolygon}
+	}
+	for _, geometryType := range projectDraft.GeometryTypes {
+		if !task.IsValidGeometryType(geometryType) {
+			return nil, errors.New(fmt.Sprintf("Unknown geometry type '%s'", geometryType))
+		}
+	}
+
+	if projectDraft.ChangesetHashtags == nil {
+		projectDraft.ChangesetHashtags = []string{}
+	}
+	err = verifyChangesetTemplate(projectDraft.ChangesetComment, projectDraft.ChangesetHashtags)
+	if err != nil {
+		return nil, err
+	}
+
+	if projectDraft.Aoi != "" {
+		err = verifyAoi(projectDraft.Aoi)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Tasks belong to exactly one project and are created together with it, so existing tasks can't be reused
+	if len(projectDraft.TaskIDs) != 0 {
+		return nil, errors.New("Task IDs must not be set, tasks are added together with the project")
 	}
 
 	// Actually add project
 
-	project, err := s.store.addProject(projectDraft)
+	project, err := s.store.AddProject(projectDraft)
 	if err != nil {
 		return nil, err
 	}
@@ -155,12 +327,12 @@ func (s *ProjectService) GetProject(projectId string, potentialMemberId string)
 		return nil, err
 	}
 
-	project, err := s.store.getProject(projectId)
+	project, err := s.store.GetProject(projectId)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.addMetadata(project, potentialMemberId)
+	err = s.addMetadata(project)
 	if err != nil {
 		s.Err("Unable to add process point data to project %s", project.Id)
 		return nil, err
@@ -169,26 +341,16 @@ func (s *ProjectService) GetProject(projectId string, potentialMemberId string)
 	return project, nil
 }
 
-// addMetadata adds additional metadata for convenience. This includes information about process points as well as permissions.
-func (s *ProjectService) addMetadata(project *Project, potentialMemberId string) error {
-	tasks, err := s.taskService.GetTasks(project.Id, potentialMemberId)
-	if err != nil {
-		s.Err("getting tasks of project %s failed", project.Id)
-		return err
-	}
-
-	// Collect the overall finish-state of the project
-	for _, t := range tasks {
-		project.DoneProcessPoints += t.ProcessPoints
-		project.TotalProcessPoints += t.MaxProcessPoints
-	}
-
+// addMetadata adds additional metadata for convenience. This includes information about permissions and the status,
+// the process points are already stored in the project itself.
+func (s *ProjectService) addMetadata(project *Project) error {
 	needsAssignment, err := s.permissionService.AssignmentInProjectNeeded(project.Id)
 	if err != nil {
 		s.Err("unable to get assignment requirement for project %s", project.Id)
 		return err
 	}
 	project.NeedsAssignment = needsAssignment
+	project.Status = getStatus(project.DoneProcessPoints, project.TotalProcessPoints)
 
 	s.Log("Added task metadata to project %s", project.Id)
 
@@ -196,12 +358,17 @@ func (s *ProjectService) addMetadata(project *Project, potentialMemberId string)
 }
 
 func (s *ProjectService) AddUser(projectId, userId, potentialOwnerId string) (*Project, error) {
-	err := s.permissionService.VerifyOwnership(projectId, potentialOwnerId)
+	err := s.store.LockProject(projectId)
 	if err != nil {
 		return nil, err
 	}
 
-	p, err := s.store.getProject(projectId)
+	err = s.permissionService.VerifyOwnership(projectId, potentialOwnerId)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.store.GetProject(projectId)
 	if err != nil {
 		return nil, err
 	}
@@ -213,13 +380,23 @@ func (s *ProjectService) AddUser(projectId, userId, potentialOwnerId string) (*P
 		}
 	}
 
-	project, err := s.store.addUser(projectId, userId)
+	err = verifyUserCount(len(p.Users) + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := s.store.AddUser(projectId, userId)
 	if err != nil {
 		return nil, err
 	}
 	s.Log("Added user to project %s", project.Id)
 
-	err = s.addMetadata(project, potentialOwnerId)
+	err = s.recordCommand(projectId, potentialOwnerId, CommandUserAdded, CommandData{UserId: userId})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.addMetadata(project)
 	if err != nil {
 		s.Err("Unable to add process point data to project %s", project.Id)
 		return nil, err
@@ -229,8 +406,13 @@ func (s *ProjectService) AddUser(projectId, userId, potentialOwnerId string) (*P
 }
 
 func (s *ProjectService) RemoveUser(projectId, requestingUserId, userIdToRemove string) (*Project, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
 	// Both users have to be member of the project
-	err := s.permissionService.VerifyMembershipProject(projectId, requestingUserId)
+	err = s.permissionService.VerifyMembershipProject(projectId, requestingUserId)
 	if err != nil {
 		return nil, err
 	}
@@ -254,13 +436,41 @@ func (s *ProjectService) RemoveUser(projectId, requestingUserId, userIdToRemove
 		return nil, errors.New(fmt.Sprintf("non-owner user '%s' is not allowed to remove another user", requestingUserId))
 	}
 
-	project, err := s.store.removeUser(projectId, userIdToRemove)
+	project, unassignedTaskIds, err := s.removeUserAndUnassign(projectId, userIdToRemove)
+	if err != nil {
+		return nil, err
+	}
+
+	// Users leaving the project on their own can join again, so only removals by the owner can be reverted
+	if requestingUserIsOwner && requestingUserId != userIdToRemove {
+		err = s.recordCommand(projectId, requestingUserId, CommandUserRemoved, CommandData{UserId: userIdToRemove, TaskIds: unassignedTaskIds})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// It could happen that someone removes him-/herself, so that we just removed requestingUserId from the project.
+	// Therefore the owner is used here.
+	err = s.addMetadata(project)
 	if err != nil {
+		s.Err("Unable to add process point data to project %s", project.Id)
 		return nil, err
 	}
+
+	return project, nil
+}
+
+// removeUserAndUnassign removes the user from the project and unassigns the user from all tasks of the project. The
+// IDs of these tasks are returned as well.
+func (s *ProjectService) removeUserAndUnassign(projectId string, userIdToRemove string) (*Project, []string, error) {
+	project, err := s.store.RemoveUser(projectId, userIdToRemove)
+	if err != nil {
+		return nil, nil, err
+	}
 	s.Log("User removed from project %s", project.Id)
 
 	// Unassign removed user from all tasks
+	unassignedTaskIds := make([]string, 0)
 	for _, t := range project.TaskIDs {
 		err := s.permissionService.VerifyAssignment(t, userIdToRemove)
 
@@ -270,33 +480,124 @@ func (s *ProjectService) RemoveUser(projectId, requestingUserId, userIdToRemove
 
 			if err != nil {
 				s.Err("Unable to unassign user '%s' from task '%s'", userIdToRemove, t)
-				return nil, err
+				return nil, nil, err
 			}
 
 			s.Log("Unassigned user %s from task %s", userIdToRemove, t)
+			unassignedTaskIds = append(unassignedTaskIds, t)
 		}
 	}
 	s.Log("Unassigned the removed user %s from all tasks of project %s", userIdToRemove, project.Id)
 
-	// It could happen that someone removes him-/herself, so that we just removed requestingUserId from the project.
-	// Therefore the owner is used here.
-	err = s.addMetadata(project, project.Owner)
+	return project, unassignedTaskIds, nil
+}
+
+// ChangeUsers adds and removes all given users in one go. Only the owner is allowed to do this. Changes that aren't
+// possible (e.g. adding a user that's already a member) don't abort the whole batch but are part of the returned results.
+func (s *ProjectService) ChangeUsers(projectId string, userIdsToAdd []string, userIdsToRemove []string, requestingUserId string) (*Project, []*UserChangeResult, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	project, err := s.store.GetProject(projectId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	members := make(map[string]bool)
+	for _, u := range project.Users {
+		members[u] = true
+	}
+
+	results := make([]*UserChangeResult, 0)
+
+	for _, userId := range userIdsToAdd {
+		result := &UserChangeResult{UserId: userId, Action: UserChangeAdd}
+		results = append(results, result)
+
+		if userId == "" {
+			result.Error = "empty user ID"
+			continue
+		}
+		if members[userId] {
+			result.Error = "user already added"
+			continue
+		}
+		if verifyUserCount(len(members)+1) != nil {
+			result.Error = "project has the maximum number of users"
+			continue
+		}
+
+		_, err = s.store.AddUser(projectId, userId)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		err = s.recordCommand(projectId, requestingUserId, CommandUserAdded, CommandData{UserId: userId})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		members[userId] = true
+		result.Success = true
+	}
+
+	for _, userId := range userIdsToRemove {
+		result := &UserChangeResult{UserId: userId, Action: UserChangeRemove}
+		results = append(results, result)
+
+		if userId == project.Owner {
+			result.Error = "removing the owner is not allowed"
+			continue
+		}
+		if !members[userId] {
+			result.Error = "user is not a member"
+			continue
+		}
+
+		// This also unassigns the user from all tasks
+		_, err = s.RemoveUser(projectId, requestingUserId, userId)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		members[userId] = false
+		result.Success = true
+	}
+	s.Log("Changed users of project %s", projectId)
+
+	project, err = s.store.GetProject(projectId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.addMetadata(project)
 	if err != nil {
 		s.Err("Unable to add process point data to project %s", project.Id)
-		return nil, err
+		return nil, nil, err
 	}
 
-	return project, nil
+	return project, results, nil
 }
 
 func (s *ProjectService) DeleteProject(projectId, potentialOwnerId string) error {
-	err := s.permissionService.VerifyOwnership(projectId, potentialOwnerId)
+	err := s.store.LockProject(projectId)
 	if err != nil {
 		return err
 	}
 
-	// Then remove the project
-	err = s.store.delete(projectId)
+	err = s.permissionService.VerifyOwnership(projectId, potentialOwnerId)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.Delete(projectId, potentialOwnerId)
 	if err != nil {
 		return err
 	}
@@ -305,8 +606,142 @@ func (s *ProjectService) DeleteProject(projectId, potentialOwnerId string) error
 	return nil
 }
 
+// UpdateMinChangesets sets the number of OSM changesets users need to get a task of this project assigned. This way,
+// e.g. validation projects only get experienced mappers.
+func (s *ProjectService) UpdateMinChangesets(projectId string, minChangesets int, requestingUserId string) (*Project, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if minChangesets < 0 {
+		return nil, errors.New("Minimum number of changesets must not be negative")
+	}
+
+	project, err := s.store.UpdateMinChangesets(projectId, minChangesets)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Updated minimum number of changesets of project %s to %d", project.Id, minChangesets)
+
+	err = s.addMetadata(project)
+	if err != nil {
+		s.Err("Unable to add process point data to project %s", project.Id)
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// UpdatePointStep sets the step size of the process points, e.g. a step of 10 for tasks with 100 points means that the
+// progress can only change in steps of 10%. This keeps the meaning of the points consistent within a team. The step 0
+// allows all values again.
+func (s *ProjectService) UpdatePointStep(projectId string, pointStep int, requestingUserId string) (*Project, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if pointStep < 0 {
+		return nil, errors.New("Point step must not be negative")
+	}
+
+	project, err := s.store.UpdatePointStep(projectId, pointStep)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Updated point step of project %s to %d", project.Id, pointStep)
+
+	err = s.addMetadata(project)
+	if err != nil {
+		s.Err("Unable to add process point data to project %s", project.Id)
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// UpdateUnassignAfter sets after how many hours without any process point change assigned tasks get unassigned
+// automatically (see "task.UnassignInactiveTasks"), so that users can't hoard tasks. 0 disables this.
+func (s *ProjectService) UpdateUnassignAfter(projectId string, hours int, requestingUserId string) (*Project, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if hours < 0 {
+		return nil, errors.New("Hours until tasks get unassigned must not be negative")
+	}
+
+	project, err := s.store.UpdateUnassignAfter(projectId, hours)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Updated hours until inactive tasks of project %s get unassigned to %d", project.Id, hours)
+
+	err = s.addMetadata(project)
+	if err != nil {
+		s.Err("Unable to add process point data to project %s", project.Id)
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// UpdateAssignmentLimits sets how many tasks a user can have assigned at the same time and how many tasks a user can
+// complete per day. This spreads the work across all participants of e.g. a mapathon. A limit of 0 disables it.
+func (s *ProjectService) UpdateAssignmentLimits(projectId string, maxAssignedTasks int, maxCompletions int, requestingUserId string) (*Project, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxAssignedTasks < 0 || maxCompletions < 0 {
+		return nil, errors.New("Assignment limits must not be negative")
+	}
+
+	project, err := s.store.UpdateAssignmentLimits(projectId, maxAssignedTasks, maxCompletions)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Updated assignment limits of project %s to %d assigned tasks and %d completions per day", project.Id, maxAssignedTasks, maxCompletions)
+
+	err = s.addMetadata(project)
+	if err != nil {
+		s.Err("Unable to add process point data to project %s", project.Id)
+		return nil, err
+	}
+
+	return project, nil
+}
+
 func (s *ProjectService) UpdateName(projectId string, newName string, requestingUserId string) (*Project, error) {
-	err := s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
 	if err != nil {
 		return nil, err
 	}
@@ -318,13 +753,28 @@ func (s *ProjectService) UpdateName(projectId string, newName string, requesting
 		return nil, errors.New("No name specified")
 	}
 
-	project, err := s.store.updateName(projectId, newName)
+	err = verifyNameLength(newName)
+	if err != nil {
+		return nil, err
+	}
+
+	oldProject, err := s.store.GetProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := s.store.UpdateName(projectId, newName)
 	if err != nil {
 		return nil, err
 	}
 	s.Log("Updated name of project %s to '%s'", project.Id, newName)
 
-	err = s.addMetadata(project, requestingUserId)
+	err = s.recordCommand(projectId, requestingUserId, CommandNameChanged, CommandData{OldValue: oldProject.Name, NewValue: newName})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.addMetadata(project)
 	if err != nil {
 		s.Err("Unable to add process point data to project %s", project.Id)
 		return nil, err
@@ -334,7 +784,12 @@ func (s *ProjectService) UpdateName(projectId string, newName string, requesting
 }
 
 func (s *ProjectService) UpdateDescription(projectId string, newDescription string, requestingUserId string) (*Project, error) {
-	err := s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
 	if err != nil {
 		return nil, err
 	}
@@ -343,13 +798,28 @@ func (s *ProjectService) UpdateDescription(projectId string, newDescription stri
 		return nil, errors.New("No description specified")
 	}
 
-	project, err := s.store.updateDescription(projectId, newDescription)
+	err = verifyDescriptionLength(newDescription)
+	if err != nil {
+		return nil, err
+	}
+
+	oldProject, err := s.store.GetProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := s.store.UpdateDescription(projectId, newDescription)
 	if err != nil {
 		return nil, err
 	}
 	s.Log("Updated description of project %s", project.Id)
 
-	err = s.addMetadata(project, requestingUserId)
+	err = s.recordCommand(projectId, requestingUserId, CommandDescriptionChanged, CommandData{OldValue: oldProject.Description, NewValue: newDescription})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.addMetadata(project)
 	if err != nil {
 		s.Err("Unable to add process point data to project %s", project.Id)
 		return nil, err
@@ -357,3 +827,218 @@ func (s *ProjectService) UpdateDescription(projectId string, newDescription stri
 
 	return project, nil
 }
+
+// UpdateLocale sets the language of the (untranslated) description. The empty locale means the language is unknown.
+func (s *ProjectService) UpdateLocale(projectId string, locale string, requestingUserId string) (*Project, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifyLocalization(locale, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := s.store.UpdateLocale(projectId, locale)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Updated locale of project %s to '%s'", project.Id, locale)
+
+	err = s.addMetadata(project)
+	if err != nil {
+		s.Err("Unable to add process point data to project %s", project.Id)
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// UpdateDescriptions replaces all translations of the description. The (untranslated) description is not changed.
+func (s *ProjectService) UpdateDescriptions(projectId string, descriptions map[string]string, requestingUserId string) (*Project, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifyLocalization("", descriptions)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := s.store.UpdateDescriptions(projectId, descriptions)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Updated %d translated descriptions of project %s", len(descriptions), project.Id)
+
+	err = s.addMetadata(project)
+	if err != nil {
+		s.Err("Unable to add process point data to project %s", project.Id)
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// UpdateChangesetTemplate sets the changeset comment and hashtags, which editors should use for the tasks of the
+// project. Only the owner of the project is allowed to do this.
+func (s *ProjectService) UpdateChangesetTemplate(projectId string, comment string, hashtags []string, requestingUserId string) (*Project, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifyChangesetTemplate(comment, hashtags)
+	if err != nil {
+		return nil, err
+	}
+
+	project, err := s.store.UpdateChangesetTemplate(projectId, comment, hashtags)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Updated changeset template of project %s to '%s' with hashtags %v", project.Id, comment, hashtags)
+
+	err = s.addMetadata(project)
+	if err != nil {
+		s.Err("Unable to add process point data to project %s", project.Id)
+		return nil, err
+	}
+
+	return project, nil
+}
+
+func verifyChangesetTemplate(comment string, hashtags []string) error {
+	if len(comment) > maxChangesetCommentLength {
+		return errors.New(fmt.Sprintf("Changeset comment too long. Maximum allowed are %d characters.", maxChangesetCommentLength))
+	}
+
+	for _, hashtag := range hashtags {
+		// Editors separate the hashtags by ";" in the "hashtags" tag of the changeset
+		if len(hashtag) < 2 || !strings.HasPrefix(hashtag, "#") || strings.ContainsAny(hashtag, " \t\n;") {
+			return errors.New(fmt.Sprintf("Invalid hashtag '%s', it must start with '#' and must not contain spaces or ';'", hashtag))
+		}
+	}
+
+	return nil
+}
+
+func verifyLocalization(locale string, descriptions map[string]string) error {
+	if locale != "" && !util.IsValidLocale(locale) {
+		return errors.New(fmt.Sprintf("Invalid locale '%s'", locale))
+	}
+
+	for l, description := range descriptions {
+		if !util.IsValidLocale(l) {
+			return errors.New(fmt.Sprintf("Invalid locale '%s' of description", l))
+		}
+
+		if len(strings.TrimSpace(description)) == 0 {
+			return errors.New(fmt.Sprintf("Description for locale '%s' is empty", l))
+		}
+
+		if limits.MaxDescriptionLength > 0 && len(description) > limits.MaxDescriptionLength {
+			return errors.New(fmt.Sprintf("Description for locale '%s' too long. Maximum allowed are %d characters.", l, limits.MaxDescriptionLength))
+		}
+	}
+
+	return nil
+}
+
+// RecordSnapshots stores the current progress of all projects as snapshot of the current day. An existing snapshot of
+// the current day is overwritten, so that the last snapshot of a day represents the progress at the end of that day.
+func (s *ProjectService) RecordSnapshots() error {
+	count, err := s.store.AddSnapshots()
+	if err != nil {
+		return err
+	}
+	s.Log("Recorded snapshots of %d projects", count)
+
+	return nil
+}
+
+// UpdateCompletion marks the project as completed when all process points have been reached. When process points
+// have been removed from a completed (but not yet archived) project, it's not completed anymore. The project has to
+// contain the process point metadata. The returned bool is true when the project just got completed.
+func (s *ProjectService) UpdateCompletion(project *Project) (bool, error) {
+	completed := project.TotalProcessPoints > 0 && project.DoneProcessPoints >= project.TotalProcessPoints
+
+	if completed && project.CompletedAt == nil {
+		now := time.Now().UTC()
+		err := s.store.SetCompletedAt(project.Id, &now)
+		if err != nil {
+			return false, err
+		}
+
+		project.CompletedAt = &now
+		s.Log("Project %s has been completed", project.Id)
+		return true, nil
+	}
+
+	if !completed && project.CompletedAt != nil && !project.Archived {
+		err := s.store.SetCompletedAt(project.Id, nil)
+		if err != nil {
+			return false, err
+		}
+
+		project.CompletedAt = nil
+		s.Log("Project %s is not completed anymore", project.Id)
+	}
+
+	return false, nil
+}
+
+// RepairProgress recomputes the process point sums of all projects, which are usually updated whenever a task
+// changes. Projects with wrong sums are logged, since this shouldn't happen.
+func (s *ProjectService) RepairProgress() error {
+	projectIds, err := s.store.RepairProgress()
+	if err != nil {
+		return err
+	}
+
+	if len(projectIds) != 0 {
+		s.Err("Repaired process points of projects %v", projectIds)
+	} else {
+		s.Log("Process points of all projects are consistent")
+	}
+
+	return nil
+}
+
+// ArchiveCompletedProjects archives all projects completed longer than the grace period ago.
+func (s *ProjectService) ArchiveCompletedProjects(gracePeriod time.Duration) error {
+	projectIds, err := s.store.ArchiveCompletedProjects(time.Now().UTC().Add(-gracePeriod))
+	if err != nil {
+		return err
+	}
+	s.Log("Archived %d completed projects: %v", len(projectIds), projectIds)
+
+	return nil
+}
+
+// GetSnapshots returns all snapshots of the project in chronological order, e.g. to draw a burndown chart.
+func (s *ProjectService) GetSnapshots(projectId string, requestingUserId string) ([]*Snapshot, error) {
+	err := s.permissionService.VerifyMembershipProject(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.GetSnapshots(projectId)
+}
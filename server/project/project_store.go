@@ -0,0 +1,27 @@
+package project
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hauke96/simple-task-manager/server/storage"
+	"github.com/hauke96/simple-task-manager/server/util"
+)
+
+// The models of this package live in the "storage" package, so that the stores of all drivers can use them. See there
+// for their documentation.
+type (
+	Project       = storage.Project
+	Snapshot      = storage.Snapshot
+	Target        = storage.Target
+	Layer         = storage.Layer
+	JoinRequest   = storage.JoinRequest
+	MergeRequest  = storage.MergeRequest
+	Inconsistency = storage.Inconsistency
+	Command       = storage.Command
+	CommandData   = storage.CommandData
+)
+
+func getStore(ctx context.Context, tx *sql.Tx, logger *util.Logger) storage.ProjectStore {
+	return storage.NewStore(storage.ComponentProject, ctx, tx, logger).(storage.ProjectStore)
+}
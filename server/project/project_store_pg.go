@@ -1,46 +1,143 @@
 package project
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/storage"
+	"github.com/hauke96/simple-task-manager/server/task"
 	"github.com/hauke96/simple-task-manager/server/util"
 	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	"strconv"
+	"time"
 )
 
 // Helper struct to read raw data from database. The "Project" struct has higher-level structure (e.g. arrays), which we
 // don't have in the database columns.
 type projectRow struct {
-	id          int
-	name        string
-	users       []string
-	owner       string
-	description string
+	id                 int
+	name               string
+	users              []string
+	owner              string
+	description        string
+	defaultDifficulty  string
+	minChangesets      int
+	pointStep          int
+	maxAssignedTasks   int
+	maxCompletions     int
+	unassignAfterHours int
+	completedAt        sql.NullTime
+	archived           bool
+	doneProcessPoints  int
+	totalProcessPoints int
+	locale             string
+	descriptions       []byte
+	targets            []byte
+	layers             []byte
+	checklist          []byte
+	checklistPoints    bool
+	geometryTypes      []string
+	changesetComment   string
+	changesetHashtags  []string
+	public             bool
+	aoi                string
+	mergedInto         sql.NullInt64
+	tutorial           bool
+	createdAt          time.Time
+	updatedAt          time.Time
+}
+
+// columns returns the columns of the "returnValues" together with the fields of the row they're scanned into. The
+// project a merged project redirects to comes from the redirects.
+func (p *projectRow) columns() *database.Columns {
+	return new(database.Columns).
+		Add("id", &p.id).
+		Add("name", &p.name).
+		Add("owner", &p.owner).
+		Add("description", &p.description).
+		Add("users", pq.Array(&p.users)).
+		Add("default_difficulty", &p.defaultDifficulty).
+		Add("min_changesets", &p.minChangesets).
+		Add("point_step", &p.pointStep).
+		Add("max_assigned_tasks", &p.maxAssignedTasks).
+		Add("max_completions_per_day", &p.maxCompletions).
+		Add("unassign_after_hours", &p.unassignAfterHours).
+		Add("completed_at", &p.completedAt).
+		Add("archived", &p.archived).
+		Add("done_process_points", &p.doneProcessPoints).
+		Add("total_process_points", &p.totalProcessPoints).
+		Add("locale", &p.locale).
+		Add("descriptions", &p.descriptions).
+		Add("targets", &p.targets).
+		Add("layers", &p.layers).
+		Add("checklist", &p.checklist).
+		Add("checklist_points", &p.checklistPoints).
+		Add("geometry_types", pq.Array(&p.geometryTypes)).
+		Add("changeset_comment", &p.changesetComment).
+		Add("changeset_hashtags", pq.Array(&p.changesetHashtags)).
+		Add("public", &p.public).
+		Add("COALESCE(ST_AsGeoJSON(aoi), '')", &p.aoi).
+		Add(fmt.Sprintf("(SELECT r.target_project_id FROM %s r WHERE r.source_project_id = %s.id)", database.TableProjectRedirects, database.TableProjects), &p.mergedInto).
+		Add("tutorial", &p.tutorial).
+		Add("created_at", &p.createdAt).
+		Add("updated_at", &p.updatedAt)
 }
 
 type storePg struct {
 	*util.Logger
-	tx        *sql.Tx
-	table     string
-	taskTable string
+	ctx              context.Context
+	tx               *sql.Tx
+	table            database.Table
+	taskTable        database.Table
+	snapshotTable    database.Table
+	joinRequestTable database.Table
+	commandTable     database.Table
+	auditTable       database.Table
+	mergeTable       database.Table
+	redirectTable    database.Table
 }
 
-func getStore(tx *sql.Tx, logger *util.Logger) *storePg {
+var (
+	commandReturnValues = "id, project_id, user_id, type, data, created_at, reverted_at, reverted_by"
+	returnValues        = (&projectRow{}).columns().SelectList()
+
+	// Same as the AOI computed by the task store when tasks are added or removed
+	computedAoi = "(SELECT ST_Union(ST_MakeValid(ST_SetSRID(ST_GeomFromGeoJSON(t.geometry::JSON->>'geometry'), 4326))) FROM " + string(database.TableTasks) + " t WHERE t.project_id = %s.id)"
+)
+
+func init() {
+	storage.Register(storage.DriverPostgres, storage.ComponentProject, func(ctx context.Context, tx storage.Transaction, logger *util.Logger) interface{} {
+		return newStorePg(ctx, tx.(*sql.Tx), logger)
+	})
+}
+
+func newStorePg(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
 	return &storePg{
-		Logger:    logger,
-		tx:        tx,
-		table:     "projects",
-		taskTable: "tasks",
+		Logger:           logger,
+		ctx:              ctx,
+		tx:               tx,
+		table:            database.TableProjects,
+		taskTable:        database.TableTasks,
+		snapshotTable:    database.TableProjectSnapshots,
+		joinRequestTable: database.TableJoinRequests,
+		commandTable:     database.TableProjectCommands,
+		auditTable:       database.TableAuditLog,
+		mergeTable:       database.TableMergeRequests,
+		redirectTable:    database.TableProjectRedirects,
 	}
 }
 
-func (s *storePg) getProjects(userId string) ([]*Project, error) {
-	query := fmt.Sprintf("SELECT * FROM %s WHERE $1 = ANY(users)", s.table)
+func (s *storePg) GetProjects(userId string) ([]*Project, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE $1 = ANY(users)", returnValues, s.table)
 
 	s.LogQuery(query, userId)
 
-	rows, err := s.tx.Query(query, userId)
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, userId)
 	if err != nil {
 		return nil, errors.Wrap(err, "error executing query")
 	}
@@ -69,39 +166,52 @@ func (s *storePg) getProjects(userId string) ([]*Project, error) {
 	return projects, nil
 }
 
-func (s *storePg) getProject(projectId string) (*Project, error) {
-	query := fmt.Sprintf("SELECT * FROM %s WHERE id=$1", s.table)
+func (s *storePg) GetProject(projectId string) (*Project, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id=$1", returnValues, s.table)
 	return s.execQuery(query, projectId)
 }
 
-func (s *storePg) getProjectByTask(taskId string) (*Project, error) {
-	query := fmt.Sprintf("SELECT p.* FROM %s p, %s t WHERE $1 = t.id AND t.project_id = p.id", s.table, s.taskTable)
+func (s *storePg) GetProjectByTask(taskId string) (*Project, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = (SELECT project_id FROM %s WHERE id = $1)", returnValues, s.table, s.taskTable)
 	return s.execQuery(query, taskId)
 }
 
-// addProject adds the given project draft and assigns an ID to the project.
-func (s *storePg) addProject(draft *Project) (*Project, error) {
-	query := fmt.Sprintf("INSERT INTO %s (name, description, users, owner) VALUES($1, $2, $3, $4) RETURNING *", s.table)
+// LockProject locks the row of the project until the end of the transaction. This way, concurrent changes of the same
+// project (e.g. adding a user while the owner deletes the project) are executed one after another instead of
+// interleaving. The lock should be acquired before reading the project, so that the read data doesn't change anymore.
+func (s *storePg) LockProject(projectId string) error {
+	query := fmt.Sprintf("SELECT id FROM %s WHERE id=$1 FOR UPDATE;", s.table)
+	s.LogQuery(query, projectId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
 
-	project, err := s.execQuery(query, draft.Name, draft.Description, pq.Array(draft.Users), draft.Owner)
+	var id string
+	err := s.tx.QueryRowContext(ctx, query, projectId).Scan(&id)
+	if err == sql.ErrNoRows {
+		return errors.New(fmt.Sprintf("project %s does not exist", projectId))
+	}
 	if err != nil {
-		return nil, err
+		return errors.Wrapf(err, "error locking project %s", projectId)
 	}
 
-	for _, taskId := range draft.TaskIDs {
-		query = fmt.Sprintf("INSERT INTO %s (project_id, id) VALUES($1, $2)", s.taskTable)
-		err := s.execRawQuery(query, project.Id, taskId)
-		if err != nil {
-			return nil, err
-		}
+	return nil
+}
+
+// AddProject adds the given project draft and assigns an ID to the project.
+func (s *storePg) AddProject(draft *Project) (*Project, error) {
+	descriptions, err := marshalDescriptions(draft.Descriptions)
+	if err != nil {
+		return nil, err
 	}
 
-	project.TaskIDs = draft.TaskIDs
-	return project, nil
+	query := fmt.Sprintf("INSERT INTO %s (name, description, users, owner, default_difficulty, min_changesets, max_assigned_tasks, max_completions_per_day, locale, descriptions, geometry_types, changeset_comment, changeset_hashtags, public, aoi, aoi_supplied, point_step, tutorial, unassign_after_hours) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, CASE WHEN $15::TEXT = '' THEN NULL ELSE ST_SetSRID(ST_GeomFromGeoJSON($15::TEXT), 4326) END, $15::TEXT <> '', $16, $17, $18) RETURNING %s", s.table, returnValues)
+
+	return s.execQuery(query, draft.Name, draft.Description, pq.Array(draft.Users), draft.Owner, draft.DefaultDifficulty, draft.MinChangesets, draft.MaxAssignedTasks, draft.MaxCompletions, draft.Locale, descriptions, pq.Array(draft.GeometryTypes), draft.ChangesetComment, pq.Array(draft.ChangesetHashtags), draft.Public, draft.Aoi, draft.PointStep, draft.Tutorial, draft.UnassignAfterHours)
 }
 
-func (s *storePg) addUser(projectId string, userIdToAdd string) (*Project, error) {
-	originalProject, err := s.getProject(projectId)
+func (s *storePg) AddUser(projectId string, userIdToAdd string) (*Project, error) {
+	originalProject, err := s.GetProject(projectId)
 	if err != nil {
 		s.Err("error getting project with ID '%s'", projectId)
 		return nil, err
@@ -109,12 +219,12 @@ func (s *storePg) addUser(projectId string, userIdToAdd string) (*Project, error
 
 	newUsers := append(originalProject.Users, userIdToAdd)
 
-	query := fmt.Sprintf("UPDATE %s SET users=$1 WHERE id=$2 RETURNING *", s.table)
+	query := fmt.Sprintf("UPDATE %s SET users=$1, updated_at=NOW() WHERE id=$2 RETURNING %s", s.table, returnValues)
 	return s.execQuery(query, pq.Array(newUsers), projectId)
 }
 
-func (s *storePg) removeUser(projectId string, userIdToRemove string) (*Project, error) {
-	originalProject, err := s.getProject(projectId)
+func (s *storePg) RemoveUser(projectId string, userIdToRemove string) (*Project, error) {
+	originalProject, err := s.GetProject(projectId)
 	if err != nil {
 		s.Err("error getting project with ID '%s'", projectId)
 		return nil, err
@@ -127,31 +237,637 @@ func (s *storePg) removeUser(projectId string, userIdToRemove string) (*Project,
 		}
 	}
 
-	query := fmt.Sprintf("UPDATE %s SET users=$1 WHERE id=$2 RETURNING *", s.table)
+	query := fmt.Sprintf("UPDATE %s SET users=$1, updated_at=NOW() WHERE id=$2 RETURNING %s", s.table, returnValues)
 	return s.execQuery(query, pq.Array(remainingUsers), projectId)
 }
 
-func (s *storePg) delete(projectId string) error {
-	query := fmt.Sprintf("DELETE FROM %s WHERE id=$1", s.table)
+// Delete removes the project when it's (still) owned by the given user. Tasks and all other data of the project are
+// removed by the database within the same transaction (see the "ON DELETE CASCADE" constraints). An error is returned
+// when nothing has been deleted, e.g. because the project has been deleted or transferred concurrently.
+func (s *storePg) Delete(projectId string, ownerId string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id=$1 AND owner=$2", s.table)
+	s.LogQuery(query, projectId, ownerId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	result, err := s.tx.ExecContext(ctx, query, projectId, ownerId)
+	if err != nil {
+		return errors.Wrapf(err, "error deleting project %s", projectId)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "could not get number of deleted projects")
+	}
+	if deleted == 0 {
+		return errors.New(fmt.Sprintf("project %s does not exist or is not owned by user %s", projectId, ownerId))
+	}
 
-	_, err := s.tx.Exec(query, projectId)
-	return err
+	return nil
 }
 
-func (s *storePg) updateName(projectId string, newName string) (*Project, error) {
-	query := fmt.Sprintf("UPDATE %s SET name=$1 WHERE id=$2 RETURNING *", s.table)
+func (s *storePg) UpdateName(projectId string, newName string) (*Project, error) {
+	query := fmt.Sprintf("UPDATE %s SET name=$1, updated_at=NOW() WHERE id=$2 RETURNING %s", s.table, returnValues)
 	return s.execQuery(query, newName, projectId)
 }
 
-func (s *storePg) updateDescription(projectId string, newDescription string) (*Project, error) {
-	query := fmt.Sprintf("UPDATE %s SET description=$1 WHERE id=$2 RETURNING *", s.table)
+func (s *storePg) UpdateDescription(projectId string, newDescription string) (*Project, error) {
+	query := fmt.Sprintf("UPDATE %s SET description=$1, updated_at=NOW() WHERE id=$2 RETURNING %s", s.table, returnValues)
 	return s.execQuery(query, newDescription, projectId)
 }
 
+func (s *storePg) UpdateLocale(projectId string, locale string) (*Project, error) {
+	query := fmt.Sprintf("UPDATE %s SET locale=$1, updated_at=NOW() WHERE id=$2 RETURNING %s", s.table, returnValues)
+	return s.execQuery(query, locale, projectId)
+}
+
+func (s *storePg) UpdateDescriptions(projectId string, descriptions map[string]string) (*Project, error) {
+	descriptionsJson, err := marshalDescriptions(descriptions)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET descriptions=$1, updated_at=NOW() WHERE id=$2 RETURNING %s", s.table, returnValues)
+	return s.execQuery(query, descriptionsJson, projectId)
+}
+
+func (s *storePg) UpdateChangesetTemplate(projectId string, comment string, hashtags []string) (*Project, error) {
+	query := fmt.Sprintf("UPDATE %s SET changeset_comment=$1, changeset_hashtags=$2, updated_at=NOW() WHERE id=$3 RETURNING %s", s.table, returnValues)
+	return s.execQuery(query, comment, pq.Array(hashtags), projectId)
+}
+
+// marshalDescriptions turns the translated descriptions into JSON for the JSONB column. "nil" results in an empty object.
+func marshalDescriptions(descriptions map[string]string) (string, error) {
+	if descriptions == nil {
+		descriptions = map[string]string{}
+	}
+
+	result, err := json.Marshal(descriptions)
+	if err != nil {
+		return "", errors.Wrap(err, "could not marshal descriptions")
+	}
+
+	return string(result), nil
+}
+
+func (s *storePg) UpdateMinChangesets(projectId string, minChangesets int) (*Project, error) {
+	query := fmt.Sprintf("UPDATE %s SET min_changesets=$1, updated_at=NOW() WHERE id=$2 RETURNING %s", s.table, returnValues)
+	return s.execQuery(query, minChangesets, projectId)
+}
+
+func (s *storePg) UpdatePointStep(projectId string, pointStep int) (*Project, error) {
+	query := fmt.Sprintf("UPDATE %s SET point_step=$1, updated_at=NOW() WHERE id=$2 RETURNING %s", s.table, returnValues)
+	return s.execQuery(query, pointStep, projectId)
+}
+
+func (s *storePg) UpdatePublic(projectId string, public bool) (*Project, error) {
+	query := fmt.Sprintf("UPDATE %s SET public=$1, updated_at=NOW() WHERE id=$2 RETURNING %s", s.table, returnValues)
+	return s.execQuery(query, public, projectId)
+}
+
+// UpdateAoi sets the AOI supplied by the owner. The empty AOI removes it, so the AOI is computed from the tasks again.
+func (s *storePg) UpdateAoi(projectId string, aoi string) (*Project, error) {
+	if aoi == "" {
+		query := fmt.Sprintf("UPDATE %s SET aoi=%s, aoi_supplied=false, updated_at=NOW() WHERE id=$1 RETURNING %s", s.table, fmt.Sprintf(computedAoi, s.table), returnValues)
+		return s.execQuery(query, projectId)
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET aoi=ST_SetSRID(ST_GeomFromGeoJSON($1), 4326), aoi_supplied=true, updated_at=NOW() WHERE id=$2 RETURNING %s", s.table, returnValues)
+	return s.execQuery(query, aoi, projectId)
+}
+
+func (s *storePg) UpdateUnassignAfter(projectId string, hours int) (*Project, error) {
+	query := fmt.Sprintf("UPDATE %s SET unassign_after_hours=$1, updated_at=NOW() WHERE id=$2 RETURNING %s", s.table, returnValues)
+	return s.execQuery(query, hours, projectId)
+}
+
+func (s *storePg) UpdateTargets(projectId string, targets []*Target) (*Project, error) {
+	targetsJson, err := json.Marshal(targets)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal targets")
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET targets=$1, updated_at=NOW() WHERE id=$2 RETURNING %s", s.table, returnValues)
+	return s.execQuery(query, string(targetsJson), projectId)
+}
+
+func (s *storePg) UpdateLayers(projectId string, layers []*Layer) (*Project, error) {
+	layersJson, err := json.Marshal(layers)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal layers")
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET layers=$1, updated_at=NOW() WHERE id=$2 RETURNING %s", s.table, returnValues)
+	return s.execQuery(query, string(layersJson), projectId)
+}
+
+// UpdateChecklist sets the checklist of the project and removes the removed items from the completed items of its
+// tasks.
+func (s *storePg) UpdateChecklist(projectId string, checklist task.Checklist, checklistPoints bool) (*Project, error) {
+	checklistJson, err := json.Marshal(checklist)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal checklist")
+	}
+
+	itemIds := make([]string, len(checklist))
+	for i, item := range checklist {
+		itemIds[i] = item.Id
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET checklist_done=ARRAY(SELECT i FROM unnest(checklist_done) i WHERE i = ANY($1)), version=version+1, updated_at=NOW() WHERE project_id=$2 AND NOT checklist_done <@ $1;", s.taskTable)
+	s.LogQuery(query, itemIds, projectId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err = s.tx.ExecContext(ctx, query, pq.Array(itemIds), projectId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error removing checklist items from tasks of project %s", projectId)
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET checklist=$1, checklist_points=$2, updated_at=NOW() WHERE id=$3 RETURNING %s", s.table, returnValues)
+	return s.execQuery(query, string(checklistJson), checklistPoints, projectId)
+}
+
+func (s *storePg) UpdateAssignmentLimits(projectId string, maxAssignedTasks int, maxCompletions int) (*Project, error) {
+	query := fmt.Sprintf("UPDATE %s SET max_assigned_tasks=$1, max_completions_per_day=$2, updated_at=NOW() WHERE id=$3 RETURNING %s", s.table, returnValues)
+	return s.execQuery(query, maxAssignedTasks, maxCompletions, projectId)
+}
+
+// SetCompletedAt stores the time the project has been completed. The value "nil" marks the project as not completed.
+func (s *storePg) SetCompletedAt(projectId string, completedAt *time.Time) error {
+	query := fmt.Sprintf("UPDATE %s SET completed_at=$1, updated_at=NOW() WHERE id=$2", s.table)
+	return s.execRawQuery(query, completedAt, projectId)
+}
+
+// ArchiveCompletedProjects archives all projects completed before the given time and returns their IDs.
+func (s *storePg) ArchiveCompletedProjects(completedBefore time.Time) ([]string, error) {
+	query := fmt.Sprintf("UPDATE %s SET archived=true, updated_at=NOW() WHERE archived=false AND completed_at < $1 RETURNING id;", s.table)
+	s.LogQuery(query, completedBefore)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, completedBefore)
+	if err != nil {
+		return nil, errors.Wrap(err, "error archiving completed projects")
+	}
+	defer rows.Close()
+
+	projectIds := make([]string, 0)
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan ID of archived project")
+		}
+
+		projectIds = append(projectIds, strconv.Itoa(id))
+	}
+
+	return projectIds, nil
+}
+
+// AddJoinRequest stores the request of the user to join the project. An existing request of the user stays as it is.
+func (s *storePg) AddJoinRequest(projectId string, userId string) (*JoinRequest, error) {
+	query := fmt.Sprintf(`INSERT INTO %s(project_id, user_id) VALUES($1, $2)
+ON CONFLICT (project_id, user_id) DO UPDATE SET user_id=EXCLUDED.user_id
+RETURNING project_id, user_id, created_at;`, s.joinRequestTable)
+	s.LogQuery(query, projectId, userId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, projectId, userId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error adding join request of user %s for project %s", userId, projectId)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, errors.New("there is no next row or an error happened")
+	}
+
+	return rowToJoinRequest(rows)
+}
+
+func (s *storePg) GetJoinRequests(projectId string) ([]*JoinRequest, error) {
+	query := fmt.Sprintf("SELECT project_id, user_id, created_at FROM %s WHERE project_id=$1 ORDER BY created_at;", s.joinRequestTable)
+	s.LogQuery(query, projectId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, projectId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting join requests of project %s", projectId)
+	}
+	defer rows.Close()
+
+	requests := make([]*JoinRequest, 0)
+	for rows.Next() {
+		request, err := rowToJoinRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
+
+// GetJoinRequestsOfOwner returns the join requests of all projects owned by the given user.
+func (s *storePg) GetJoinRequestsOfOwner(ownerId string) ([]*JoinRequest, error) {
+	query := fmt.Sprintf(`SELECT j.project_id, j.user_id, j.created_at FROM %s j, %s p
+WHERE j.project_id = p.id AND p.owner = $1
+ORDER BY j.created_at;`, s.joinRequestTable, s.table)
+	s.LogQuery(query, ownerId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, ownerId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting join requests of projects owned by %s", ownerId)
+	}
+	defer rows.Close()
+
+	requests := make([]*JoinRequest, 0)
+	for rows.Next() {
+		request, err := rowToJoinRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
+
+// RemoveJoinRequest removes the request and returns an error when there's no such request.
+func (s *storePg) RemoveJoinRequest(projectId string, userId string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE project_id=$1 AND user_id=$2;", s.joinRequestTable)
+	s.LogQuery(query, projectId, userId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	result, err := s.tx.ExecContext(ctx, query, projectId, userId)
+	if err != nil {
+		return errors.Wrapf(err, "error removing join request of user %s for project %s", userId, projectId)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "could not get number of removed join requests")
+	}
+	if removed == 0 {
+		return errors.New(fmt.Sprintf("user %s did not request to join project %s", userId, projectId))
+	}
+
+	return nil
+}
+
+// AddMergeRequest stores the request to merge the source into the target project. An existing request for both projects
+// is taken over by the given user.
+func (s *storePg) AddMergeRequest(sourceProjectId string, targetProjectId string, userId string) (*MergeRequest, error) {
+	query := fmt.Sprintf(`INSERT INTO %s(source_project_id, target_project_id, requested_by) VALUES($1, $2, $3)
+ON CONFLICT (source_project_id, target_project_id) DO UPDATE SET requested_by=EXCLUDED.requested_by;`, s.mergeTable)
+	err := s.execRawQuery(query, sourceProjectId, targetProjectId, userId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error adding request to merge project %s into %s", sourceProjectId, targetProjectId)
+	}
+
+	return s.GetMergeRequest(sourceProjectId, targetProjectId)
+}
+
+func (s *storePg) GetMergeRequest(sourceProjectId string, targetProjectId string) (*MergeRequest, error) {
+	requests, err := s.execMergeRequestQuery("m.source_project_id=$1 AND m.target_project_id=$2", sourceProjectId, targetProjectId)
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, errors.New(fmt.Sprintf("there's no request to merge project %s into %s", sourceProjectId, targetProjectId))
+	}
+
+	return requests[0], nil
+}
+
+// GetMergeRequests returns the requests with the project as source or target.
+func (s *storePg) GetMergeRequests(projectId string) ([]*MergeRequest, error) {
+	return s.execMergeRequestQuery("m.source_project_id=$1 OR m.target_project_id=$1", projectId)
+}
+
+// RemoveMergeRequest removes the request and returns an error when there's no such request.
+func (s *storePg) RemoveMergeRequest(sourceProjectId string, targetProjectId string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE source_project_id=$1 AND target_project_id=$2;", s.mergeTable)
+	s.LogQuery(query, sourceProjectId, targetProjectId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	result, err := s.tx.ExecContext(ctx, query, sourceProjectId, targetProjectId)
+	if err != nil {
+		return errors.Wrapf(err, "error removing request to merge project %s into %s", sourceProjectId, targetProjectId)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "could not get number of removed merge requests")
+	}
+	if removed == 0 {
+		return errors.New(fmt.Sprintf("there's no request to merge project %s into %s", sourceProjectId, targetProjectId))
+	}
+
+	return nil
+}
+
+// execMergeRequestQuery returns the merge requests matching the condition (on table "m"), oldest first. The approver is
+// the owner of the project not owned by the requesting user.
+func (s *storePg) execMergeRequestQuery(condition string, params ...interface{}) ([]*MergeRequest, error) {
+	query := fmt.Sprintf(`SELECT m.source_project_id, m.target_project_id, m.requested_by, CASE WHEN src.owner = m.requested_by THEN tgt.owner ELSE src.owner END, m.created_at
+FROM %s m JOIN %s src ON src.id = m.source_project_id JOIN %s tgt ON tgt.id = m.target_project_id
+WHERE %s
+ORDER BY m.created_at;`, s.mergeTable, s.table, s.table, condition)
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting merge requests")
+	}
+	defer rows.Close()
+
+	requests := make([]*MergeRequest, 0)
+	for rows.Next() {
+		var sourceProjectId, targetProjectId int
+		request := &MergeRequest{}
+
+		err = rows.Scan(&sourceProjectId, &targetProjectId, &request.RequestedBy, &request.Approver, &request.CreatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan merge request")
+		}
+
+		request.SourceProjectId = strconv.Itoa(sourceProjectId)
+		request.TargetProjectId = strconv.Itoa(targetProjectId)
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
+
+// MergeProjects moves the tasks and members of the source into the target project, archives the source project and
+// stores the redirect to the target. All open merge requests of the source are removed.
+func (s *storePg) MergeProjects(sourceProjectId string, targetProjectId string) error {
+	// Members and geometry types of the source are appended unless the target already contains them
+	query := fmt.Sprintf(`UPDATE %s t SET
+	users = t.users || ARRAY(SELECT u FROM unnest(src.users) u WHERE u <> ALL(t.users)),
+	geometry_types = t.geometry_types || ARRAY(SELECT g FROM unnest(src.geometry_types) g WHERE g <> ALL(t.geometry_types)),
+	done_process_points = t.done_process_points + src.done_process_points,
+	total_process_points = t.total_process_points + src.total_process_points,
+	updated_at = NOW()
+FROM %s src
+WHERE t.id = $2 AND src.id = $1;`, s.table, s.table)
+	err := s.execRawQuery(query, sourceProjectId, targetProjectId)
+	if err != nil {
+		return errors.Wrapf(err, "error merging project %s into %s", sourceProjectId, targetProjectId)
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET project_id=$2, updated_at=NOW() WHERE project_id=$1;", s.taskTable)
+	err = s.execRawQuery(query, sourceProjectId, targetProjectId)
+	if err != nil {
+		return errors.Wrapf(err, "error moving tasks of project %s into %s", sourceProjectId, targetProjectId)
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET done_process_points=0, total_process_points=0, archived=true, updated_at=NOW() WHERE id=$1;", s.table)
+	err = s.execRawQuery(query, sourceProjectId)
+	if err != nil {
+		return errors.Wrapf(err, "error archiving merged project %s", sourceProjectId)
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET aoi=%s WHERE id = ANY($1::INTEGER[]) AND NOT aoi_supplied;", s.table, fmt.Sprintf(computedAoi, s.table))
+	err = s.execRawQuery(query, pq.Array([]string{sourceProjectId, targetProjectId}))
+	if err != nil {
+		return errors.Wrapf(err, "error updating AOI of merged projects %s and %s", sourceProjectId, targetProjectId)
+	}
+
+	query = fmt.Sprintf("INSERT INTO %s(source_project_id, target_project_id) VALUES($1, $2);", s.redirectTable)
+	err = s.execRawQuery(query, sourceProjectId, targetProjectId)
+	if err != nil {
+		return errors.Wrapf(err, "error adding redirect from project %s to %s", sourceProjectId, targetProjectId)
+	}
+
+	query = fmt.Sprintf("DELETE FROM %s WHERE source_project_id=$1 OR target_project_id=$1;", s.mergeTable)
+	return s.execRawQuery(query, sourceProjectId)
+}
+
+// RepairProgress recomputes the process point sums of all projects from their tasks and returns the IDs of the projects
+// whose stored sums were wrong.
+func (s *storePg) RepairProgress() ([]string, error) {
+	query := fmt.Sprintf(`UPDATE %s p SET done_process_points=c.done, total_process_points=c.total, updated_at=NOW()
+FROM (SELECT p.id, COALESCE(SUM(t.process_points), 0) AS done, COALESCE(SUM(t.max_process_points), 0) AS total
+	FROM %s p LEFT JOIN %s t ON t.project_id = p.id
+	GROUP BY p.id) c
+WHERE p.id = c.id AND (p.done_process_points <> c.done OR p.total_process_points <> c.total)
+RETURNING p.id;`, s.table, s.table, s.taskTable)
+	s.LogQuery(query)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "error repairing process points of projects")
+	}
+	defer rows.Close()
+
+	projectIds := make([]string, 0)
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan ID of repaired project")
+		}
+
+		projectIds = append(projectIds, strconv.Itoa(id))
+	}
+
+	return projectIds, nil
+}
+
+// GetInconsistencies returns all broken references of tasks, see "CheckConsistency". Each query returns the task ID,
+// the project ID and the broken reference.
+func (s *storePg) GetInconsistencies() ([]*Inconsistency, error) {
+	queries := []struct {
+		kind  string
+		query string
+	}{
+		{InconsistencyOrphanedTask, fmt.Sprintf(`SELECT t.id, t.project_id, t.project_id::TEXT FROM %s t
+	LEFT JOIN %s p ON p.id = t.project_id
+	WHERE p.id IS NULL ORDER BY t.id;`, s.taskTable, s.table)},
+		{InconsistencyMissingDependency, fmt.Sprintf(`SELECT t.id, t.project_id, d.id::TEXT FROM %s t
+	CROSS JOIN LATERAL unnest(t.depends_on) AS d(id)
+	LEFT JOIN %s dt ON dt.id = d.id AND dt.project_id = t.project_id
+	WHERE dt.id IS NULL ORDER BY t.id, d.id;`, s.taskTable, s.taskTable)},
+		{InconsistencyNonMemberAssignment, fmt.Sprintf(`SELECT t.id, t.project_id, t.assigned_user FROM %s t
+	JOIN %s p ON p.id = t.project_id
+	WHERE COALESCE(t.assigned_user, '') <> '' AND NOT t.assigned_user = ANY(p.users) ORDER BY t.id;`, s.taskTable, s.table)},
+		{InconsistencyNonMemberAllowedUser, fmt.Sprintf(`SELECT t.id, t.project_id, u.id FROM %s t
+	JOIN %s p ON p.id = t.project_id
+	CROSS JOIN LATERAL unnest(t.allowed_users) AS u(id)
+	WHERE NOT u.id = ANY(p.users) ORDER BY t.id, u.id;`, s.taskTable, s.table)},
+	}
+
+	inconsistencies := make([]*Inconsistency, 0)
+	for _, q := range queries {
+		result, err := s.execInconsistencyQuery(q.kind, q.query)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error checking for inconsistency '%s'", q.kind)
+		}
+		inconsistencies = append(inconsistencies, result...)
+	}
+
+	return inconsistencies, nil
+}
+
+func (s *storePg) execInconsistencyQuery(kind string, query string) ([]*Inconsistency, error) {
+	s.LogQuery(query)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not run query")
+	}
+	defer rows.Close()
+
+	result := make([]*Inconsistency, 0)
+	for rows.Next() {
+		var taskId, projectId int
+		inconsistency := &Inconsistency{Kind: kind}
+
+		err = rows.Scan(&taskId, &projectId, &inconsistency.Reference)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan inconsistency")
+		}
+
+		inconsistency.TaskId = strconv.Itoa(taskId)
+		inconsistency.ProjectId = strconv.Itoa(projectId)
+		result = append(result, inconsistency)
+	}
+
+	return result, nil
+}
+
+// RepairInconsistency removes the orphaned task or the broken reference from the task.
+func (s *storePg) RepairInconsistency(inconsistency *Inconsistency) error {
+	var query string
+	params := []interface{}{inconsistency.TaskId}
+
+	switch inconsistency.Kind {
+	case InconsistencyOrphanedTask:
+		query = fmt.Sprintf("DELETE FROM %s WHERE id=$1;", s.taskTable)
+	case InconsistencyMissingDependency:
+		query = fmt.Sprintf("UPDATE %s SET depends_on=array_remove(depends_on, $2::INTEGER), version=version+1, updated_at=NOW() WHERE id=$1;", s.taskTable)
+		params = append(params, inconsistency.Reference)
+	case InconsistencyNonMemberAssignment:
+		query = fmt.Sprintf("UPDATE %s SET assigned_user='', version=version+1, updated_at=NOW() WHERE id=$1 AND assigned_user=$2;", s.taskTable)
+		params = append(params, inconsistency.Reference)
+	case InconsistencyNonMemberAllowedUser:
+		query = fmt.Sprintf("UPDATE %s SET allowed_users=array_remove(allowed_users, $2), version=version+1, updated_at=NOW() WHERE id=$1;", s.taskTable)
+		params = append(params, inconsistency.Reference)
+	default:
+		return errors.New(fmt.Sprintf("unknown inconsistency '%s'", inconsistency.Kind))
+	}
+
+	return s.execRawQuery(query, params...)
+}
+
+// AddSnapshots stores the current process points of all projects for the current day and returns the number of
+// stored snapshots.
+func (s *storePg) AddSnapshots() (int64, error) {
+	query := fmt.Sprintf(`INSERT INTO %s(project_id, date, done_process_points, total_process_points)
+SELECT p.id, CURRENT_DATE, COALESCE(SUM(t.process_points), 0), COALESCE(SUM(t.max_process_points), 0)
+FROM %s p LEFT JOIN %s t ON t.project_id = p.id
+GROUP BY p.id
+ON CONFLICT (project_id, date) DO UPDATE SET done_process_points = EXCLUDED.done_process_points, total_process_points = EXCLUDED.total_process_points;`, s.snapshotTable, s.table, s.taskTable)
+	s.LogQuery(query)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	result, err := s.tx.ExecContext(ctx, query)
+	if err != nil {
+		return 0, errors.Wrap(err, "error adding project snapshots")
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *storePg) GetSnapshots(projectId string) ([]*Snapshot, error) {
+	query := fmt.Sprintf("SELECT date, done_process_points, total_process_points FROM %s WHERE project_id = $1 ORDER BY date;", s.snapshotTable)
+	s.LogQuery(query, projectId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, projectId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error executing query to get snapshots of project %s", projectId)
+	}
+	defer rows.Close()
+
+	snapshots := make([]*Snapshot, 0)
+	for rows.Next() {
+		var date time.Time
+		snapshot := &Snapshot{}
+
+		err = rows.Scan(&date, &snapshot.DoneProcessPoints, &snapshot.TotalProcessPoints)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan snapshot")
+		}
+
+		snapshot.Date = date.Format("2006-01-02")
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+// GetNearbyProjectIds returns the IDs of public and not archived projects whose AOI is within the radius (in meters)
+// around the location together with the distance to their AOI, nearest projects first.
+func (s *storePg) GetNearbyProjectIds(lat float64, lon float64, radius float64, limit int) ([]string, []float64, error) {
+	query := fmt.Sprintf(`SELECT id, ST_Distance(aoi::GEOGRAPHY, ST_SetSRID(ST_MakePoint($2, $1), 4326)::GEOGRAPHY) AS distance FROM %s
+WHERE public AND NOT archived AND ST_DWithin(aoi::GEOGRAPHY, ST_SetSRID(ST_MakePoint($2, $1), 4326)::GEOGRAPHY, $3)
+ORDER BY distance, id
+LIMIT $4;`, s.table)
+	s.LogQuery(query, lat, lon, radius, limit)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, lat, lon, radius, limit)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error executing query to get nearby projects")
+	}
+	defer rows.Close()
+
+	projectIds := make([]string, 0)
+	distances := make([]float64, 0)
+	for rows.Next() {
+		var id int
+		var distance float64
+
+		err = rows.Scan(&id, &distance)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "could not scan nearby project")
+		}
+
+		projectIds = append(projectIds, strconv.Itoa(id))
+		distances = append(distances, distance)
+	}
+
+	return projectIds, distances, nil
+}
+
 // execQuery executed the given query but doesn't collect any result data. Use "execQuery" to get a proper result.
 func (s *storePg) execRawQuery(query string, params ...interface{}) error {
 	s.LogQuery(query, params...)
-	rows, err := s.tx.Query(query, params...)
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
 	if err != nil {
 		return errors.Wrap(err, "could not run query")
 	}
@@ -167,7 +883,9 @@ func (s *storePg) execRawQuery(query string, params ...interface{}) error {
 // execQuery executed the given query, turns the result into a Project object and closes the query.
 func (s *storePg) execQuery(query string, params ...interface{}) (*Project, error) {
 	s.LogQuery(query, params...)
-	rows, err := s.tx.Query(query, params...)
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not run query")
 	}
@@ -197,7 +915,7 @@ func (s *storePg) execQuery(query string, params ...interface{}) (*Project, erro
 // rowToProject turns the current row into a Project object. This does not close the row.
 func (s *storePg) rowToProject(rows *sql.Rows) (*Project, error) {
 	var p projectRow
-	err := rows.Scan(&p.id, &p.name, &p.owner, &p.description, pq.Array(&p.users))
+	err := rows.Scan(p.columns().Destinations()...)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not scan rows")
 	}
@@ -209,15 +927,195 @@ func (s *storePg) rowToProject(rows *sql.Rows) (*Project, error) {
 	result.Users = p.users
 	result.Owner = p.owner
 	result.Description = p.description
+	result.DefaultDifficulty = p.defaultDifficulty
+	result.MinChangesets = p.minChangesets
+	result.PointStep = p.pointStep
+	result.MaxAssignedTasks = p.maxAssignedTasks
+	result.MaxCompletions = p.maxCompletions
+	result.UnassignAfterHours = p.unassignAfterHours
+	result.Archived = p.archived
+	result.DoneProcessPoints = p.doneProcessPoints
+	result.TotalProcessPoints = p.totalProcessPoints
+	result.Locale = p.locale
+	result.GeometryTypes = p.geometryTypes
+	result.ChangesetComment = p.changesetComment
+	result.ChangesetHashtags = p.changesetHashtags
+	result.Public = p.public
+	result.Aoi = p.aoi
+	result.Tutorial = p.tutorial
+	result.CreatedAt = p.createdAt
+	result.UpdatedAt = p.updatedAt
+
+	err = json.Unmarshal(p.descriptions, &result.Descriptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal descriptions")
+	}
+	err = json.Unmarshal(p.targets, &result.Targets)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal targets")
+	}
+	err = json.Unmarshal(p.layers, &result.Layers)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal layers")
+	}
+	err = json.Unmarshal(p.checklist, &result.Checklist)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal checklist")
+	}
+	result.ChecklistPoints = p.checklistPoints
+	if p.completedAt.Valid {
+		result.CompletedAt = &p.completedAt.Time
+	}
+	if p.mergedInto.Valid {
+		result.MergedInto = strconv.FormatInt(p.mergedInto.Int64, 10)
+	}
 
 	return &result, nil
 }
 
+func (s *storePg) AddCommand(projectId string, userId string, commandType string, data CommandData) (*Command, error) {
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling command data")
+	}
+
+	// The command is added to the audit log as well, which keeps it after the project has been removed
+	query := fmt.Sprintf(`WITH c AS (
+	INSERT INTO %s(project_id, user_id, type, data) VALUES($1, $2, $3, $4) RETURNING *
+), a AS (
+	INSERT INTO %s(created_at, project_id, user_id, action, details) SELECT created_at, project_id, user_id, type, data FROM c
+)
+SELECT %s FROM c;`, s.commandTable, s.auditTable, commandReturnValues)
+	commands, err := s.execCommandQuery(query, projectId, userId, commandType, dataJson)
+	if err != nil {
+		return nil, err
+	}
+
+	return commands[0], nil
+}
+
+func (s *storePg) GetCommands(projectId string) ([]*Command, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE project_id=$1 ORDER BY id DESC;", commandReturnValues, s.commandTable)
+	return s.execCommandQuery(query, projectId)
+}
+
+// GetJoinDates returns the time each user has been added to the project according to the commands (user ID -> time).
+// Users added at the creation of the project, or whose command has been removed by the retention, are missing.
+func (s *storePg) GetJoinDates(projectId string) (map[string]time.Time, error) {
+	query := fmt.Sprintf("SELECT data->>'userId', MAX(created_at) FROM %s WHERE project_id=$1 AND type=$2 AND reverted_at IS NULL GROUP BY data->>'userId';", s.commandTable)
+	s.LogQuery(query, projectId, CommandUserAdded)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, projectId, CommandUserAdded)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting join dates of project %s", projectId)
+	}
+	defer rows.Close()
+
+	joinDates := make(map[string]time.Time)
+	for rows.Next() {
+		var userId string
+		var joinedAt time.Time
+
+		err = rows.Scan(&userId, &joinedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan join date")
+		}
+
+		joinDates[userId] = joinedAt
+	}
+
+	return joinDates, nil
+}
+
+func (s *storePg) GetCommand(commandId string) (*Command, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id=$1;", commandReturnValues, s.commandTable)
+	commands, err := s.execCommandQuery(query, commandId)
+	if err != nil {
+		return nil, err
+	}
+	if len(commands) == 0 {
+		return nil, errors.New(fmt.Sprintf("command %s does not exist", commandId))
+	}
+
+	return commands[0], nil
+}
+
+// SetCommandReverted marks the command as reverted by the user or, when "reverted" is false, removes this mark. Both
+// are added to the audit log with the "_reverted" or "_redone" suffix.
+func (s *storePg) SetCommandReverted(commandId string, userId string, reverted bool) error {
+	query := fmt.Sprintf(`WITH c AS (
+	UPDATE %s SET reverted_at=CASE WHEN $3 THEN NOW() END, reverted_by=CASE WHEN $3 THEN $2 ELSE '' END WHERE id=$1 RETURNING *
+)
+INSERT INTO %s(created_at, project_id, user_id, action, details)
+SELECT NOW(), project_id, $2, type || CASE WHEN $3 THEN '_reverted' ELSE '_redone' END, data FROM c;`, s.commandTable, s.auditTable)
+	return s.execRawQuery(query, commandId, userId, reverted)
+}
+
+func (s *storePg) execCommandQuery(query string, params ...interface{}) ([]*Command, error) {
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error executing command query")
+	}
+	defer rows.Close()
+
+	commands := make([]*Command, 0)
+	for rows.Next() {
+		var id int
+		var projectId int
+		var data []byte
+		var revertedAt sql.NullTime
+		command := &Command{}
+
+		err = rows.Scan(&id, &projectId, &command.UserId, &command.Type, &data, &command.CreatedAt, &revertedAt, &command.RevertedBy)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan command")
+		}
+
+		err = json.Unmarshal(data, &command.Data)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not unmarshal command data")
+		}
+
+		command.Id = strconv.Itoa(id)
+		command.ProjectId = strconv.Itoa(projectId)
+		if revertedAt.Valid {
+			command.RevertedAt = &revertedAt.Time
+		}
+
+		commands = append(commands, command)
+	}
+
+	return commands, nil
+}
+
+// rowToJoinRequest turns the current row into a JoinRequest object. This does not close the row.
+func rowToJoinRequest(rows *sql.Rows) (*JoinRequest, error) {
+	var projectId int
+	request := &JoinRequest{}
+
+	err := rows.Scan(&projectId, &request.UserId, &request.CreatedAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not scan join request")
+	}
+
+	request.ProjectId = strconv.Itoa(projectId)
+
+	return request, nil
+}
+
 func (s *storePg) addTaskIdsToProject(project *Project) error {
-	query := fmt.Sprintf("SELECT ARRAY_AGG(id) FROM %s WHERE project_id = $1", s.taskTable)
+	query := fmt.Sprintf("SELECT COALESCE(ARRAY_AGG(id), '{}') FROM %s WHERE project_id = $1", s.taskTable)
 
 	s.LogQuery(query, project.Id)
-	rows, err := s.tx.Query(query, project.Id)
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, project.Id)
 	if err != nil {
 		return errors.Wrap(err, "could not run query")
 	}
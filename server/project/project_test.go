@@ -1,17 +1,21 @@
 package project
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/hauke96/sigolo"
 	"github.com/hauke96/simple-task-manager/server/config"
 	"github.com/hauke96/simple-task-manager/server/database"
 	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/quota"
 	"github.com/hauke96/simple-task-manager/server/task"
 	"github.com/hauke96/simple-task-manager/server/test"
 	"github.com/hauke96/simple-task-manager/server/util"
 	"github.com/pkg/errors"
+	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/lib/pq" // Make driver "postgres" usable
 )
@@ -38,16 +42,18 @@ func setup() {
 
 	logger := util.NewLogger()
 
+	ctx := context.Background()
+
 	var err error
-	tx, err = database.GetTransaction(logger)
+	tx, err = database.GetTransaction(ctx, logger)
 	if err != nil {
 		panic(err)
 	}
 
 	h.Tx = tx
-	permissionService := permission.Init(tx, logger)
-	taskService = task.Init(tx, logger, permissionService)
-	s = Init(tx, logger, taskService, permissionService)
+	permissionService := permission.Init(ctx, tx, logger)
+	taskService = task.Init(ctx, tx, logger, permissionService)
+	s = Init(ctx, tx, logger, taskService, permissionService, quota.Init(ctx, tx, logger))
 }
 
 func TestGetProjects(t *testing.T) {
@@ -181,6 +187,9 @@ func TestAddWithTasks(t *testing.T) {
 		}
 
 		task := tasks[0]
+		if task.Difficulty != newProject.DefaultDifficulty || newProject.DefaultDifficulty != "medium" {
+			return errors.New(fmt.Sprintf("Task should have default difficulty of project but was '%s'", task.Difficulty))
+		}
 		if task.AssignedUser != "user2" ||
 			task.MaxProcessPoints != 100 ||
 			task.ProcessPoints != 5 ||
@@ -192,6 +201,34 @@ func TestAddWithTasks(t *testing.T) {
 	})
 }
 
+func TestAddWithTasksExceedingQuota(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := tx.Exec("INSERT INTO user_quotas(user_id, max_owned_projects, max_tasks_per_project, max_total_tasks, updated_by) VALUES('Maria', 1, 0, 0, 'Peter');")
+		if err != nil {
+			return err
+		}
+
+		p := Project{
+			Name:  "Test name",
+			Users: []string{"Maria"},
+			Owner: "Maria",
+		}
+
+		t := task.Task{
+			MaxProcessPoints: 100,
+			Geometry:         "{\"type\":\"Feature\",\"geometry\":{\"type\":\"Polygon\",\"coordinates\":[[[0,0],[1,0]]]},\"properties\":null}",
+		}
+
+		// Maria already owns project 2
+		_, err = s.AddProjectWithTasks(&p, []*task.Task{&t})
+		if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+			return errors.New(fmt.Sprintf("Adding project exceeding the quota should fail: %v", err))
+		}
+
+		return nil
+	})
+}
+
 func TestAddAndGetProject(t *testing.T) {
 	h.Run(t, func() error {
 		user := "Jack"
@@ -223,7 +260,7 @@ func TestAddAndGetProject(t *testing.T) {
 }
 
 func TestAddProjectWithUsedTasks(t *testing.T) {
-	h.RunFail(t, func() error {
+	h.Run(t, func() error {
 		user := "Jen"
 		p := Project{
 			Name:    "Test name",
@@ -236,6 +273,13 @@ func TestAddProjectWithUsedTasks(t *testing.T) {
 		if err == nil {
 			return errors.New(fmt.Sprintf("The tasks are already used. This should not work."))
 		}
+
+		// Task IDs of unused tasks can't be set either, tasks are only added together with the project
+		p.TaskIDs = []string{"22", "33"}
+		_, err = s.AddProject(&p)
+		if err == nil {
+			return errors.New(fmt.Sprintf("Setting task IDs should not work."))
+		}
 		return nil
 	})
 }
@@ -282,7 +326,9 @@ func TestAddProjectWithInvalidParameters(t *testing.T) {
 		}
 
 		// Too long description not allowed
-		maxDescriptionLength = 10 // lower the border for test purposes
+		oldLimits := GetLimits()
+		defer SetLimits(oldLimits)
+		limits.MaxDescriptionLength = 10 // lower the border for test purposes
 		p = Project{
 			Owner:"foo",
 			Users:[]string{"foo"},
@@ -352,6 +398,52 @@ func TestAddUserTwice(t *testing.T) {
 	})
 }
 
+func TestChangeUsers(t *testing.T) {
+	h.Run(t, func() error {
+		p, results, err := s.ChangeUsers("2", []string{"Zoe", "John", "Zoe"}, []string{"Donny", "Maria", "Xavier"}, "Maria")
+		if err != nil {
+			return errors.Wrap(err, "Changing users should work")
+		}
+
+		expectedSuccess := []bool{true, false, false, true, false, false}
+		if len(results) != len(expectedSuccess) {
+			return errors.New(fmt.Sprintf("Expected %d results but got %d", len(expectedSuccess), len(results)))
+		}
+		for i, r := range results {
+			if r.Success != expectedSuccess[i] {
+				return errors.New(fmt.Sprintf("Result of user '%s' (%s) should be %v", r.UserId, r.Action, expectedSuccess[i]))
+			}
+			if !r.Success && r.Error == "" {
+				return errors.New(fmt.Sprintf("Failed result of user '%s' should contain reason", r.UserId))
+			}
+		}
+
+		expectedUsers := []string{"Maria", "John", "Anna", "Carl", "Clara", "Zoe"}
+		if len(p.Users) != len(expectedUsers) {
+			return errors.New(fmt.Sprintf("Users not matching: %v", p.Users))
+		}
+		for i, u := range p.Users {
+			if u != expectedUsers[i] {
+				return errors.New(fmt.Sprintf("Users not matching: %v", p.Users))
+			}
+		}
+
+		// Removed user must be unassigned
+		err = s.permissionService.VerifyAssignment("7", "Donny")
+		if err == nil {
+			return errors.New("Removed user should be unassigned from task")
+		}
+
+		// Only the owner is allowed to change users
+		_, _, err = s.ChangeUsers("2", []string{"Yvonne"}, []string{}, "John")
+		if err == nil {
+			return errors.New("Non-owner should not be able to change users")
+		}
+
+		return nil
+	})
+}
+
 func TestRemoveUser(t *testing.T) {
 	h.Run(t, func() error {
 		userToRemove := "Maria"
@@ -557,6 +649,15 @@ func TestDeleteProject(t *testing.T) {
 			return errors.New("The tasks should not exist anymore")
 		}
 
+		var remainingTasks int
+		err = tx.QueryRow("SELECT COUNT(*) FROM tasks WHERE project_id=$1;", id).Scan(&remainingTasks)
+		if err != nil {
+			return err
+		}
+		if remainingTasks != 0 {
+			return errors.New(fmt.Sprintf("%d tasks of the deleted project still exist", remainingTasks))
+		}
+
 		// Delete not existing project
 
 		err = s.DeleteProject("45356475", "Peter")
@@ -614,6 +715,331 @@ func TestUpdateName(t *testing.T) {
 	})
 }
 
+func TestUpdateMinChangesets(t *testing.T) {
+	h.Run(t, func() error {
+		project, err := s.UpdateMinChangesets("1", 250, "Peter")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error updating minimum changesets wasn't expected: %s", err))
+		}
+		if project.MinChangesets != 250 {
+			return errors.New(fmt.Sprintf("New minimum changesets doesn't match with expected one: %d != 250", project.MinChangesets))
+		}
+		if project.TotalProcessPoints != 10 || project.DoneProcessPoints != 0 {
+			return errors.New(fmt.Sprintf("Process points on project not set correctly"))
+		}
+
+		// With non-owner (Maria)
+
+		_, err = s.UpdateMinChangesets("1", 10, "Maria")
+		if err == nil {
+			return errors.New("Updating minimum changesets should not be possible for non-owner user Maria")
+		}
+
+		// Negative value
+
+		_, err = s.UpdateMinChangesets("1", -1, "Peter")
+		if err == nil {
+			return errors.New("Updating minimum changesets should not be possible with negative value")
+		}
+		return nil
+	})
+}
+
+func TestUpdatePointStep(t *testing.T) {
+	h.Run(t, func() error {
+		project, err := s.UpdatePointStep("1", 5, "Peter")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error updating point step wasn't expected: %s", err))
+		}
+		if project.PointStep != 5 {
+			return errors.New(fmt.Sprintf("New point step doesn't match with expected one: %d != 5", project.PointStep))
+		}
+
+		_, err = s.UpdatePointStep("1", 10, "Maria")
+		if err == nil {
+			return errors.New("Updating point step should not be possible for non-owner user Maria")
+		}
+
+		_, err = s.UpdatePointStep("1", -1, "Peter")
+		if err == nil {
+			return errors.New("Updating point step should not be possible with negative value")
+		}
+		return nil
+	})
+}
+
+func TestNearbyProjects(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := s.UpdatePublic("1", true, "Maria")
+		if err == nil {
+			return errors.New("Making project public should not be possible for non-owner user Maria")
+		}
+
+		project, err := s.UpdatePublic("1", true, "Peter")
+		if err != nil {
+			return err
+		}
+		if !project.Public {
+			return errors.New("Project should be public")
+		}
+
+		// The tasks of the dummy projects are about 50 meters away from 0,0 but only project 1 is public
+		projects, err := s.GetNearbyProjects(0, 0, 1000)
+		if err != nil {
+			return err
+		}
+		if len(projects) != 1 || projects[0].Id != "1" || len(projects[0].Users) != 0 {
+			return errors.New(fmt.Sprintf("Nearby projects not matching: %#v", projects))
+		}
+		if projects[0].Distance <= 0 || projects[0].Distance > 100 {
+			return errors.New(fmt.Sprintf("Distance not matching: %f", projects[0].Distance))
+		}
+
+		projects, err = s.GetNearbyProjects(0, 0, 1)
+		if err != nil {
+			return err
+		}
+		if len(projects) != 0 {
+			return errors.New(fmt.Sprintf("Project should be outside of radius: %#v", projects))
+		}
+
+		_, err = s.GetNearbyProjects(91, 0, 1000)
+		if err == nil {
+			return errors.New("Invalid latitude should not be possible")
+		}
+
+		_, err = s.GetNearbyProjects(0, 0, maxNearbyRadius+1)
+		if err == nil {
+			return errors.New("Radius larger than maximum should not be possible")
+		}
+
+		return nil
+	})
+}
+
+func TestUpdateAoi(t *testing.T) {
+	h.Run(t, func() error {
+		project, err := s.GetProject("1", "Peter")
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(project.Aoi, `"Polygon"`) {
+			return errors.New(fmt.Sprintf("AOI should be computed from the task: %s", project.Aoi))
+		}
+		computedAoi := project.Aoi
+
+		aoi := `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}`
+
+		_, err = s.UpdateAoi("1", aoi, "Maria")
+		if err == nil {
+			return errors.New("Updating AOI should not be possible for non-owner user Maria")
+		}
+
+		project, err = s.UpdateAoi("1", aoi, "Peter")
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(project.Aoi, "[1,1]") {
+			return errors.New(fmt.Sprintf("AOI should be the supplied one: %s", project.Aoi))
+		}
+
+		project, err = s.UpdateAoi("1", "", "Peter")
+		if err != nil {
+			return err
+		}
+		if project.Aoi != computedAoi {
+			return errors.New(fmt.Sprintf("AOI should be computed again: %s != %s", project.Aoi, computedAoi))
+		}
+
+		return nil
+	})
+}
+
+func TestVerifyAoi(t *testing.T) {
+	valid := []string{
+		`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`,
+		`{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[[[2,2],[3,2],[3,3],[2,2]]]]}`,
+	}
+	for _, aoi := range valid {
+		if err := verifyAoi(aoi); err != nil {
+			t.Errorf("AOI should be valid: %s: %s", aoi, err)
+		}
+	}
+
+	invalid := []string{
+		`foo`,
+		`{"type":"Point","coordinates":[0,0]}`,
+		`{"type":"Polygon","coordinates":[]}`,
+		`{"type":"Polygon","coordinates":[[[0,0],[1,0],[0,0]]]}`,
+		`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1]]]}`,
+	}
+	for _, aoi := range invalid {
+		if err := verifyAoi(aoi); err == nil {
+			t.Errorf("AOI should be invalid: %s", aoi)
+		}
+	}
+}
+
+func TestUpdateAssignmentLimits(t *testing.T) {
+	h.Run(t, func() error {
+		project, err := s.UpdateAssignmentLimits("1", 2, 5, "Peter")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error updating assignment limits wasn't expected: %s", err))
+		}
+		if project.MaxAssignedTasks != 2 || project.MaxCompletions != 5 {
+			return errors.New(fmt.Sprintf("New assignment limits don't match with expected ones: %d != 2 or %d != 5", project.MaxAssignedTasks, project.MaxCompletions))
+		}
+
+		// With non-owner (Maria)
+
+		_, err = s.UpdateAssignmentLimits("1", 1, 1, "Maria")
+		if err == nil {
+			return errors.New("Updating assignment limits should not be possible for non-owner user Maria")
+		}
+
+		// Negative value
+
+		_, err = s.UpdateAssignmentLimits("1", -1, 0, "Peter")
+		if err == nil {
+			return errors.New("Updating assignment limits should not be possible with negative value")
+		}
+		return nil
+	})
+}
+
+func TestUpdateUnassignAfter(t *testing.T) {
+	h.Run(t, func() error {
+		project, err := s.UpdateUnassignAfter("1", 24, "Peter")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error updating unassign after hours wasn't expected: %s", err))
+		}
+		if project.UnassignAfterHours != 24 {
+			return errors.New(fmt.Sprintf("New unassign after hours don't match with expected ones: %d != 24", project.UnassignAfterHours))
+		}
+
+		// With non-owner (Maria)
+
+		_, err = s.UpdateUnassignAfter("1", 1, "Maria")
+		if err == nil {
+			return errors.New("Updating unassign after hours should not be possible for non-owner user Maria")
+		}
+
+		// Negative value
+
+		_, err = s.UpdateUnassignAfter("1", -1, "Peter")
+		if err == nil {
+			return errors.New("Updating unassign after hours should not be possible with negative value")
+		}
+		return nil
+	})
+}
+
+func TestUpdateTargets(t *testing.T) {
+	h.Run(t, func() error {
+		targets := []*Target{
+			{Percentage: 100, Date: "2999-06-01"},
+			{Percentage: 50, Date: "2999-03-01"},
+		}
+		project, err := s.UpdateTargets("1", targets, "Peter")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error updating targets wasn't expected: %s", err))
+		}
+		if len(project.Targets) != 2 || project.Targets[0].Date != "2999-03-01" || project.Targets[1].Percentage != 100 {
+			return errors.New(fmt.Sprintf("New targets don't match with expected ones: %#v", project.Targets))
+		}
+
+		states, err := s.GetTargetStates("1", "Maria")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error getting target states wasn't expected: %s", err))
+		}
+		if len(states) != 2 || states[0].Status != TargetStatusOffTrack || states[1].Status != TargetStatusOffTrack {
+			return errors.New(fmt.Sprintf("Target states don't match with expected ones: %#v", states))
+		}
+
+		// With non-owner (Maria)
+
+		_, err = s.UpdateTargets("1", targets, "Maria")
+		if err == nil {
+			return errors.New("Updating targets should not be possible for non-owner user Maria")
+		}
+
+		// Invalid targets
+
+		_, err = s.UpdateTargets("1", []*Target{{Percentage: 101, Date: "2999-03-01"}}, "Peter")
+		if err == nil {
+			return errors.New("Updating targets should not be possible with percentage above 100")
+		}
+
+		_, err = s.UpdateTargets("1", []*Target{{Percentage: 50, Date: "1st of March"}}, "Peter")
+		if err == nil {
+			return errors.New("Updating targets should not be possible with invalid date")
+		}
+
+		_, err = s.UpdateTargets("1", []*Target{{Percentage: 50, Date: "2999-03-01"}, {Percentage: 60, Date: "2999-03-01"}}, "Peter")
+		if err == nil {
+			return errors.New("Updating targets should not be possible with two targets on the same date")
+		}
+
+		// Remove all targets
+
+		project, err = s.UpdateTargets("1", nil, "Peter")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error removing targets wasn't expected: %s", err))
+		}
+		if len(project.Targets) != 0 {
+			return errors.New(fmt.Sprintf("Targets should have been removed: %#v", project.Targets))
+		}
+
+		return nil
+	})
+}
+
+func TestEvaluateTargets(t *testing.T) {
+	snapshots := []*Snapshot{
+		{Date: "2021-04-30", DoneProcessPoints: 10, TotalProcessPoints: 100},
+		{Date: "2021-05-03", DoneProcessPoints: 20, TotalProcessPoints: 100},
+		{Date: "2021-05-09", DoneProcessPoints: 44, TotalProcessPoints: 100},
+	}
+	targets := []*Target{
+		{Percentage: 10, Date: "2021-04-30"},
+		{Percentage: 15, Date: "2021-05-01"},
+		{Percentage: 100, Date: "2021-05-12"},
+		{Percentage: 80, Date: "2021-05-20"},
+		{Percentage: 40, Date: "2021-06-01"},
+	}
+	now := time.Date(2021, 5, 10, 15, 0, 0, 0, time.UTC)
+
+	// Progress of 30% during the last 7 days, so about 4.3% per day
+	states, err := evaluateTargets(targets, snapshots, 50, 100, now)
+	if err != nil {
+		t.Errorf("Error evaluating targets wasn't expected: %s", err)
+		return
+	}
+
+	expected := []TargetState{
+		{Target: *targets[0], Status: TargetStatusReached, ProjectedPercentage: 10},
+		{Target: *targets[1], Status: TargetStatusMissed, ProjectedPercentage: 10},
+		{Target: *targets[2], Status: TargetStatusOffTrack, ProjectedPercentage: 58},
+		{Target: *targets[3], Status: TargetStatusOnTrack, ProjectedPercentage: 92},
+		{Target: *targets[4], Status: TargetStatusReached, ProjectedPercentage: 50},
+	}
+	for i, state := range states {
+		if *state != expected[i] {
+			t.Errorf("State of target %d doesn't match: %#v != %#v", i, *state, expected[i])
+		}
+	}
+
+	// Without recent snapshots, no progress is expected
+	states, err = evaluateTargets(targets[3:4], snapshots[:1], 50, 100, now)
+	if err != nil {
+		t.Errorf("Error evaluating targets wasn't expected: %s", err)
+		return
+	}
+	if states[0].Status != TargetStatusOffTrack || states[0].ProjectedPercentage != 50 {
+		t.Errorf("Target without recent progress should be off track: %#v", *states[0])
+	}
+}
+
 func TestUpdateDescription(t *testing.T) {
 	h.Run(t, func() error {
 		oldProject, _ := s.GetProject("1", "Peter")
@@ -647,12 +1073,1134 @@ func TestUpdateDescription(t *testing.T) {
 	})
 }
 
-func contains(projectIdToFind string, projectsToCheck []*Project) bool {
-	for _, p := range projectsToCheck {
-		if p.Id == projectIdToFind {
-			return true
+func TestGetSnapshots(t *testing.T) {
+	h.Run(t, func() error {
+		snapshots, err := s.GetSnapshots("2", "John")
+		if err != nil {
+			return err
 		}
-	}
 
-	return false
+		if len(snapshots) != 3 {
+			return errors.New(fmt.Sprintf("Expected 3 snapshots but got %d", len(snapshots)))
+		}
+
+		if snapshots[0].Date != "2020-09-01" || snapshots[0].DoneProcessPoints != 100 || snapshots[0].TotalProcessPoints != 308 {
+			return errors.New(fmt.Sprintf("First snapshot does not match: %#v", snapshots[0]))
+		}
+
+		if snapshots[2].Date != "2020-09-03" || snapshots[2].DoneProcessPoints != 154 {
+			return errors.New(fmt.Sprintf("Last snapshot does not match: %#v", snapshots[2]))
+		}
+
+		// Non-members are not allowed to see snapshots
+		_, err = s.GetSnapshots("2", "Peter")
+		if err == nil {
+			return errors.New("Peter is not a member of project 2")
+		}
+
+		return nil
+	})
+}
+
+func TestRecordSnapshots(t *testing.T) {
+	h.Run(t, func() error {
+		err := s.RecordSnapshots()
+		if err != nil {
+			return err
+		}
+
+		// Recording twice a day should overwrite the snapshot of that day
+		err = s.RecordSnapshots()
+		if err != nil {
+			return err
+		}
+
+		snapshots, err := s.GetSnapshots("3", "Otto")
+		if err != nil {
+			return err
+		}
+
+		if len(snapshots) != 1 || snapshots[0].DoneProcessPoints != 345 || snapshots[0].TotalProcessPoints != 2000 {
+			return errors.New(fmt.Sprintf("Snapshots of project 3 do not match: %#v", snapshots))
+		}
+
+		snapshots, err = s.GetSnapshots("2", "Maria")
+		if err != nil {
+			return err
+		}
+
+		if len(snapshots) != 4 {
+			return errors.New(fmt.Sprintf("Expected 4 snapshots of project 2 but got %d", len(snapshots)))
+		}
+
+		return nil
+	})
+}
+
+func TestUpdateCompletion(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := taskService.SetProcessPoints("1", 10, "Peter")
+		if err != nil {
+			return err
+		}
+
+		project, err := s.GetProject("1", "Peter")
+		if err != nil {
+			return err
+		}
+
+		justCompleted, err := s.UpdateCompletion(project)
+		if err != nil {
+			return err
+		}
+		if !justCompleted || project.CompletedAt == nil {
+			return errors.New("Project 1 should just have been completed")
+		}
+
+		// Completing again should not trigger a second completion
+		project, err = s.GetProject("1", "Peter")
+		if err != nil {
+			return err
+		}
+		if project.CompletedAt == nil {
+			return errors.New("Completion date of project 1 should have been stored")
+		}
+
+		justCompleted, err = s.UpdateCompletion(project)
+		if err != nil {
+			return err
+		}
+		if justCompleted {
+			return errors.New("Project 1 was already completed")
+		}
+
+		// Removing process points resets the completion
+		_, err = taskService.SetProcessPoints("1", 5, "Peter")
+		if err != nil {
+			return err
+		}
+
+		project, err = s.GetProject("1", "Peter")
+		if err != nil {
+			return err
+		}
+
+		_, err = s.UpdateCompletion(project)
+		if err != nil {
+			return err
+		}
+
+		project, err = s.GetProject("1", "Peter")
+		if err != nil {
+			return err
+		}
+		if project.CompletedAt != nil {
+			return errors.New("Project 1 should not be completed anymore")
+		}
+
+		return nil
+	})
+}
+
+func TestArchiveCompletedProjects(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := taskService.SetProcessPoints("1", 10, "Peter")
+		if err != nil {
+			return err
+		}
+
+		project, err := s.GetProject("1", "Peter")
+		if err != nil {
+			return err
+		}
+
+		_, err = s.UpdateCompletion(project)
+		if err != nil {
+			return err
+		}
+
+		// Not completed long enough
+		err = s.ArchiveCompletedProjects(time.Hour)
+		if err != nil {
+			return err
+		}
+
+		project, err = s.GetProject("1", "Peter")
+		if err != nil {
+			return err
+		}
+		if project.Archived {
+			return errors.New("Project 1 should not be archived before the grace period")
+		}
+
+		err = s.ArchiveCompletedProjects(0)
+		if err != nil {
+			return err
+		}
+
+		project, err = s.GetProject("1", "Peter")
+		if err != nil {
+			return err
+		}
+		if !project.Archived {
+			return errors.New("Project 1 should be archived")
+		}
+
+		// Tasks of archived projects can't be changed anymore
+		_, err = taskService.SetProcessPoints("1", 5, "Peter")
+		if err == nil {
+			return errors.New("Changing tasks of archived projects should not be possible")
+		}
+
+		return nil
+	})
+}
+
+func TestProgressUpdatedOnTaskChanges(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := taskService.SetProcessPoints("1", 7, "Peter")
+		if err != nil {
+			return err
+		}
+
+		project, err := s.GetProject("1", "Peter")
+		if err != nil {
+			return err
+		}
+		if project.DoneProcessPoints != 7 || project.TotalProcessPoints != 10 {
+			return errors.New(fmt.Sprintf("Process points of project 1 should be 7/10 but were %d/%d", project.DoneProcessPoints, project.TotalProcessPoints))
+		}
+
+		err = taskService.Delete([]string{"1"}, "Peter")
+		if err != nil {
+			return err
+		}
+
+		project, err = s.GetProject("1", "Peter")
+		if err != nil {
+			return err
+		}
+		if project.DoneProcessPoints != 0 || project.TotalProcessPoints != 0 {
+			return errors.New(fmt.Sprintf("Process points of project 1 should be 0/0 but were %d/%d", project.DoneProcessPoints, project.TotalProcessPoints))
+		}
+
+		return nil
+	})
+}
+
+func TestRepairProgress(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := tx.Exec("UPDATE projects SET done_process_points=1234, total_process_points=5678 WHERE id=2;")
+		if err != nil {
+			return err
+		}
+
+		err = s.RepairProgress()
+		if err != nil {
+			return err
+		}
+
+		tasks, err := taskService.GetTasks("2", "Maria")
+		if err != nil {
+			return err
+		}
+
+		expectedDone, expectedTotal := 0, 0
+		for _, tk := range tasks {
+			expectedDone += tk.ProcessPoints
+			expectedTotal += tk.MaxProcessPoints
+		}
+
+		project, err := s.GetProject("2", "Maria")
+		if err != nil {
+			return err
+		}
+		if project.DoneProcessPoints != expectedDone || project.TotalProcessPoints != expectedTotal {
+			return errors.New(fmt.Sprintf("Process points of project 2 should be %d/%d but were %d/%d", expectedDone, expectedTotal, project.DoneProcessPoints, project.TotalProcessPoints))
+		}
+
+		return nil
+	})
+}
+
+func TestGetOwnedProjects(t *testing.T) {
+	h.Run(t, func() error {
+		// Maria is member of project 1 and 2 but only owns project 2
+		projects, err := s.GetOwnedProjects("Maria")
+		if err != nil {
+			return err
+		}
+
+		if len(projects) != 1 || projects[0].Id != "2" {
+			return errors.New(fmt.Sprintf("Owned projects do not match: %v", projects))
+		}
+
+		return nil
+	})
+}
+
+func TestJoinRequests(t *testing.T) {
+	h.Run(t, func() error {
+		request, owner, err := s.RequestJoin("1", "Otto")
+		if err != nil {
+			return err
+		}
+		if request.ProjectId != "1" || request.UserId != "Otto" || owner != "Peter" {
+			return errors.New(fmt.Sprintf("Join request does not match: %#v, owner %s", request, owner))
+		}
+
+		_, _, err = s.RequestJoin("1", "Maria")
+		if err == nil {
+			return errors.New("Members should not be able to request to join")
+		}
+
+		_, err = s.GetJoinRequests("1", "Maria")
+		if err == nil {
+			return errors.New("Non-owners should not see join requests")
+		}
+
+		requests, err := s.GetJoinRequests("1", "Peter")
+		if err != nil {
+			return err
+		}
+		if len(requests) != 1 || requests[0].UserId != "Otto" {
+			return errors.New(fmt.Sprintf("Join requests do not match: %v", requests))
+		}
+
+		requests, err = s.GetJoinRequestsOfOwner("Peter")
+		if err != nil {
+			return err
+		}
+		if len(requests) != 1 || requests[0].ProjectId != "1" {
+			return errors.New(fmt.Sprintf("Join requests of owner do not match: %v", requests))
+		}
+
+		project, err := s.ApproveJoinRequest("1", "Otto", "Peter")
+		if err != nil {
+			return err
+		}
+		if len(project.Users) != 3 || project.Users[2] != "Otto" {
+			return errors.New(fmt.Sprintf("Otto should be a member of project 1: %v", project.Users))
+		}
+
+		// The request has been removed
+		_, err = s.ApproveJoinRequest("1", "Otto", "Peter")
+		if err == nil {
+			return errors.New("There should be no join request anymore")
+		}
+
+		_, _, err = s.RequestJoin("1", "Anna")
+		if err != nil {
+			return err
+		}
+
+		err = s.DenyJoinRequest("1", "Anna", "Maria")
+		if err == nil {
+			return errors.New("Non-owners should not be able to deny join requests")
+		}
+
+		err = s.DenyJoinRequest("1", "Anna", "Peter")
+		if err != nil {
+			return err
+		}
+
+		requests, err = s.GetJoinRequests("1", "Peter")
+		if err != nil {
+			return err
+		}
+		if len(requests) != 0 {
+			return errors.New(fmt.Sprintf("There should be no join requests anymore: %v", requests))
+		}
+
+		return nil
+	})
+}
+
+func TestMergeProjects(t *testing.T) {
+	h.Run(t, func() error {
+		_, _, _, err := s.RequestMerge("1", "1", "Peter")
+		if err == nil {
+			return errors.New("A project should not be mergeable into itself")
+		}
+
+		_, _, _, err = s.RequestMerge("2", "1", "Otto")
+		if err == nil {
+			return errors.New("Users owning none of the projects should not be able to request a merge")
+		}
+
+		request, _, _, err := s.RequestMerge("2", "1", "Maria")
+		if err != nil {
+			return err
+		}
+		if request == nil || request.SourceProjectId != "2" || request.TargetProjectId != "1" || request.RequestedBy != "Maria" || request.Approver != "Peter" {
+			return errors.New(fmt.Sprintf("Merge request does not match: %#v", request))
+		}
+
+		_, err = s.GetMergeRequests("1", "Maria")
+		if err == nil {
+			return errors.New("Non-owners should not see merge requests")
+		}
+
+		requests, err := s.GetMergeRequests("2", "Maria")
+		if err != nil {
+			return err
+		}
+		if len(requests) != 1 || requests[0].Approver != "Peter" {
+			return errors.New(fmt.Sprintf("Merge requests do not match: %v", requests))
+		}
+
+		_, _, err = s.ApproveMerge("2", "1", "Maria")
+		if err == nil {
+			return errors.New("The requesting user should not be able to approve the merge")
+		}
+
+		source, target, err := s.ApproveMerge("2", "1", "Peter")
+		if err != nil {
+			return err
+		}
+
+		if !source.Archived || source.MergedInto != "1" || len(source.TaskIDs) != 0 || source.TotalProcessPoints != 0 {
+			return errors.New(fmt.Sprintf("Source project should be archived and empty: %#v", source))
+		}
+
+		// Maria is member of both projects and must not be added twice
+		if strings.Join(target.Users, ",") != "Peter,Maria,John,Anna,Carl,Donny,Clara" {
+			return errors.New(fmt.Sprintf("Users of merged project do not match: %v", target.Users))
+		}
+		if len(target.TaskIDs) != 6 || target.DoneProcessPoints != 154 || target.TotalProcessPoints != 318 || target.MergedInto != "" {
+			return errors.New(fmt.Sprintf("Merged project does not match: %#v", target))
+		}
+
+		requests, err = s.GetMergeRequests("1", "Peter")
+		if err != nil {
+			return err
+		}
+		if len(requests) != 0 {
+			return errors.New(fmt.Sprintf("There should be no merge requests anymore: %v", requests))
+		}
+
+		_, _, _, err = s.RequestMerge("2", "1", "Peter")
+		if err == nil {
+			return errors.New("Archived projects should not be mergeable")
+		}
+
+		return nil
+	})
+}
+
+func TestDenyMerge(t *testing.T) {
+	h.Run(t, func() error {
+		_, _, _, err := s.RequestMerge("3", "1", "Peter")
+		if err != nil {
+			return err
+		}
+
+		err = s.DenyMerge("3", "1", "Maria")
+		if err == nil {
+			return errors.New("Non-owners should not be able to deny merge requests")
+		}
+
+		err = s.DenyMerge("3", "1", "Otto")
+		if err != nil {
+			return err
+		}
+
+		_, _, err = s.ApproveMerge("3", "1", "Otto")
+		if err == nil {
+			return errors.New("There should be no merge request anymore")
+		}
+
+		return nil
+	})
+}
+
+func TestUpdateLocaleAndDescriptions(t *testing.T) {
+	h.Run(t, func() error {
+		project, err := s.UpdateLocale("1", "en", "Peter")
+		if err != nil {
+			return err
+		}
+		if project.Locale != "en" {
+			return errors.New(fmt.Sprintf("Locale not set: %s", project.Locale))
+		}
+
+		project, err = s.UpdateDescriptions("1", map[string]string{"de": "Beschreibung"}, "Peter")
+		if err != nil {
+			return err
+		}
+		if len(project.Descriptions) != 1 || project.Descriptions["de"] != "Beschreibung" {
+			return errors.New(fmt.Sprintf("Descriptions not set: %v", project.Descriptions))
+		}
+
+		_, err = s.UpdateDescriptions("1", map[string]string{"de": "Beschreibung"}, "Maria")
+		if err == nil {
+			return errors.New("Non-owners should not be able to update descriptions")
+		}
+
+		_, err = s.UpdateDescriptions("1", map[string]string{"german": "Beschreibung"}, "Peter")
+		if err == nil {
+			return errors.New("Invalid locales should not be possible")
+		}
+
+		_, err = s.UpdateDescriptions("1", map[string]string{"de": " "}, "Peter")
+		if err == nil {
+			return errors.New("Empty descriptions should not be possible")
+		}
+
+		_, err = s.UpdateLocale("1", "en_US", "Peter")
+		if err == nil {
+			return errors.New("Invalid locale should not be possible")
+		}
+
+		return nil
+	})
+}
+
+func TestUpdateChangesetTemplate(t *testing.T) {
+	h.Run(t, func() error {
+		project, err := s.UpdateChangesetTemplate("1", "Mapping task {taskId}", []string{"#stm", "#stm-{projectId}"}, "Peter")
+		if err != nil {
+			return err
+		}
+		if project.ChangesetComment != "Mapping task {taskId}" || len(project.ChangesetHashtags) != 2 {
+			return errors.New(fmt.Sprintf("Changeset template not set: %s %v", project.ChangesetComment, project.ChangesetHashtags))
+		}
+
+		tasks, err := taskService.GetTasks("1", "Peter")
+		if err != nil {
+			return err
+		}
+		if tasks[0].ChangesetComment != "Mapping task 1" || tasks[0].ChangesetHashtags[1] != "#stm-1" {
+			return errors.New(fmt.Sprintf("Changeset template of task not expanded: %s %v", tasks[0].ChangesetComment, tasks[0].ChangesetHashtags))
+		}
+
+		_, err = s.UpdateChangesetTemplate("1", "foo", []string{"#stm"}, "Maria")
+		if err == nil {
+			return errors.New("Non-owners should not be able to update the changeset template")
+		}
+
+		_, err = s.UpdateChangesetTemplate("1", "foo", []string{"stm"}, "Peter")
+		if err == nil {
+			return errors.New("Hashtags without '#' should not be possible")
+		}
+
+		_, err = s.UpdateChangesetTemplate("1", "foo", []string{"#s tm"}, "Peter")
+		if err == nil {
+			return errors.New("Hashtags with whitespaces should not be possible")
+		}
+
+		return nil
+	})
+}
+
+func TestRevertCommands(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := s.RemoveUser("1", "Peter", "Maria")
+		if err != nil {
+			return err
+		}
+
+		commands, err := s.GetCommands("1", "Peter")
+		if err != nil {
+			return err
+		}
+		if len(commands) != 1 || commands[0].Type != CommandUserRemoved || commands[0].Data.UserId != "Maria" {
+			return errors.New(fmt.Sprintf("Removal should have been recorded: %#v", commands))
+		}
+		commandId := commands[0].Id
+
+		_, err = s.GetCommands("1", "Maria")
+		if err == nil {
+			return errors.New("Non-owners should not see the commands")
+		}
+
+		project, err := s.RevertCommand("1", commandId, "Peter")
+		if err != nil {
+			return err
+		}
+		if len(project.Users) != 2 {
+			return errors.New(fmt.Sprintf("Maria should be a member again: %v", project.Users))
+		}
+
+		_, err = s.RevertCommand("1", commandId, "Peter")
+		if err == nil {
+			return errors.New("Reverting twice should not be possible")
+		}
+
+		project, err = s.RedoCommand("1", commandId, "Peter")
+		if err != nil {
+			return err
+		}
+		if len(project.Users) != 1 {
+			return errors.New(fmt.Sprintf("Maria should be removed again: %v", project.Users))
+		}
+
+		var auditActions string
+		err = tx.QueryRow("SELECT string_agg(action, ',' ORDER BY id) FROM audit_log WHERE project_id=1 AND task_id IS NULL;").Scan(&auditActions)
+		if err != nil {
+			return err
+		}
+		if auditActions != "user_removed,user_removed_reverted,user_removed_redone" {
+			return errors.New(fmt.Sprintf("Command, revert and redo should be in the audit log: %s", auditActions))
+		}
+
+		// Changes in the meantime prevent the revert
+		_, err = s.UpdateName("1", "new name", "Peter")
+		if err != nil {
+			return err
+		}
+		_, err = s.UpdateName("1", "newer name", "Peter")
+		if err != nil {
+			return err
+		}
+
+		commands, err = s.GetCommands("1", "Peter")
+		if err != nil {
+			return err
+		}
+		if len(commands) != 3 || commands[1].Type != CommandNameChanged || commands[1].Data.NewValue != "new name" {
+			return errors.New(fmt.Sprintf("Name changes should have been recorded: %#v", commands))
+		}
+
+		_, err = s.RevertCommand("1", commands[1].Id, "Peter")
+		if err == nil {
+			return errors.New("Reverting an overwritten name change should not be possible")
+		}
+
+		project, err = s.RevertCommand("1", commands[0].Id, "Peter")
+		if err != nil {
+			return err
+		}
+		if project.Name != "new name" {
+			return errors.New(fmt.Sprintf("Name should have been reverted but was %s", project.Name))
+		}
+
+		_, err = s.RevertCommand("2", commands[0].Id, "Maria")
+		if err == nil {
+			return errors.New("Reverting command of other project should not be possible")
+		}
+
+		return nil
+	})
+}
+
+func TestSetRevertWindow(t *testing.T) {
+	defer SetRevertWindow(24 * time.Hour)
+
+	err := SetRevertWindow(0)
+	if err == nil {
+		t.Error("Revert window of 0 should not be possible")
+	}
+
+	err = SetRevertWindow(time.Hour)
+	if err != nil || revertWindow != time.Hour {
+		t.Errorf("Revert window should be set: %s", err)
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := tx.Exec("UPDATE projects SET created_at='2020-01-01 10:00:00', updated_at='2020-01-01 10:00:00';")
+		if err != nil {
+			return err
+		}
+
+		since := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+		projects, err := s.GetProjects("Maria")
+		if err != nil {
+			return err
+		}
+		if len(FilterChangedSince(projects, since)) != 0 {
+			return errors.New("No project should have been changed after the given time")
+		}
+
+		project, err := s.UpdateName("1", "new name", "Peter")
+		if err != nil {
+			return err
+		}
+		if !project.UpdatedAt.After(since) {
+			return errors.New(fmt.Sprintf("Modification time should have been updated but was %s", project.UpdatedAt))
+		}
+		if !project.CreatedAt.Equal(time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)) {
+			return errors.New(fmt.Sprintf("Creation time should not have been changed but was %s", project.CreatedAt))
+		}
+
+		projects, err = s.GetProjects("Maria")
+		if err != nil {
+			return err
+		}
+		changedProjects := FilterChangedSince(projects, since)
+		if len(changedProjects) != 1 || changedProjects[0].Id != "1" {
+			return errors.New(fmt.Sprintf("Only project 1 should have been changed: %#v", changedProjects))
+		}
+
+		return nil
+	})
+}
+
+func TestLocalize(t *testing.T) {
+	project := &Project{
+		Description:  "Description",
+		Locale:       "en",
+		Descriptions: map[string]string{"de": "Beschreibung", "fr": "La description"},
+	}
+
+	project.Localize("de-AT, en;q=0.5")
+	if project.Description != "Beschreibung" {
+		t.Errorf("German description expected but got '%s'", project.Description)
+		return
+	}
+
+	project.Description = "Description"
+	project.Localize("en-GB, de;q=0.5")
+	if project.Description != "Description" {
+		t.Errorf("Untranslated description expected but got '%s'", project.Description)
+		return
+	}
+
+	project.Localize("it")
+	if project.Description != "Description" {
+		t.Errorf("Untranslated description expected as fallback but got '%s'", project.Description)
+		return
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	cases := []struct {
+		done, total int
+		status      string
+	}{
+		{0, 0, StatusNotStarted},
+		{0, 100, StatusNotStarted},
+		{1, 100, StatusInProgress},
+		{79, 100, StatusInProgress},
+		{80, 100, StatusNearlyDone},
+		{99, 100, StatusNearlyDone},
+		{100, 100, StatusComplete},
+	}
+
+	for _, c := range cases {
+		status := getStatus(c.done, c.total)
+		if status != c.status {
+			t.Errorf("Status '%s' expected for %d/%d but got '%s'", c.status, c.done, c.total, status)
+			return
+		}
+	}
+
+	err := SetStatusThresholds(0.5, 0.4)
+	if err == nil {
+		t.Errorf("In-progress threshold above nearly-done threshold should not be possible")
+		return
+	}
+}
+
+func TestRenderBadge(t *testing.T) {
+	badge := string(RenderBadge(42, 100))
+	if !strings.HasPrefix(badge, "<svg") || !strings.Contains(badge, "42% done") || !strings.Contains(badge, badgeColors[StatusInProgress]) {
+		t.Errorf("Badge for project in progress expected but got %s", badge)
+		return
+	}
+
+	badge = string(RenderBadge(100, 100))
+	if !strings.Contains(badge, "complete") || !strings.Contains(badge, badgeColors[StatusComplete]) {
+		t.Errorf("Badge for complete project expected but got %s", badge)
+		return
+	}
+}
+
+func contains(projectIdToFind string, projectsToCheck []*Project) bool {
+	for _, p := range projectsToCheck {
+		if p.Id == projectIdToFind {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestLimits(t *testing.T) {
+	h.Run(t, func() error {
+		oldLimits := GetLimits()
+		defer SetLimits(oldLimits)
+
+		err := SetLimits(Limits{MaxUsers: -1})
+		if err == nil {
+			return errors.New("Negative limits should not be possible")
+		}
+
+		err = SetLimits(Limits{
+			MaxDescriptionLength: 10,
+			MaxNameLength:        5,
+			MaxTasks:             2,
+			MaxUsers:             2,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = s.UpdateName("1", "too long name", "Peter")
+		if err == nil {
+			return errors.New("Too long name should not be possible")
+		}
+
+		_, err = s.UpdateDescription("1", "too long description", "Peter")
+		if err == nil {
+			return errors.New("Too long description should not be possible")
+		}
+
+		// Project 1 already has two users
+		_, err = s.AddUser("1", "Otto", "Peter")
+		if err == nil {
+			return errors.New("Adding more users than allowed should not be possible")
+		}
+
+		// Together both projects have three tasks
+		_, _, err = s.getMergeableProjects("3", "1")
+		if err == nil {
+			return errors.New("Merging into a project with too many tasks should not be possible")
+		}
+
+		_, err = s.UpdateName("1", "short", "Peter")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Name within the limit should be possible: %s", err))
+		}
+
+		return nil
+	})
+}
+
+func TestCheckConsistency(t *testing.T) {
+	h.Run(t, func() error {
+		// Otto is no member of project 1 and Peter no member of project 2, task 5 belongs to project 3
+		_, err := tx.Exec("UPDATE tasks SET assigned_user='Otto' WHERE id=1;")
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE tasks SET allowed_users='{Maria,Peter}', depends_on='{3,5,999}' WHERE id=2;")
+		if err != nil {
+			return err
+		}
+
+		inconsistencies, err := s.CheckConsistency(false)
+		if err != nil {
+			return err
+		}
+		if len(inconsistencies) != 4 {
+			return errors.New(fmt.Sprintf("Expected 4 inconsistencies but got %d", len(inconsistencies)))
+		}
+		if inconsistencies[0].Kind != InconsistencyMissingDependency || inconsistencies[0].TaskId != "2" || inconsistencies[0].Reference != "5" ||
+			inconsistencies[1].Reference != "999" ||
+			inconsistencies[2].Kind != InconsistencyNonMemberAssignment || inconsistencies[2].TaskId != "1" || inconsistencies[2].Reference != "Otto" ||
+			inconsistencies[3].Kind != InconsistencyNonMemberAllowedUser || inconsistencies[3].Reference != "Peter" || inconsistencies[3].Repaired {
+			return errors.New(fmt.Sprintf("Inconsistencies not matching: %v", inconsistencies))
+		}
+
+		inconsistencies, err = s.CheckConsistency(true)
+		if err != nil {
+			return err
+		}
+		if len(inconsistencies) != 4 || !inconsistencies[0].Repaired {
+			return errors.New(fmt.Sprintf("All inconsistencies should have been repaired: %v", inconsistencies))
+		}
+
+		inconsistencies, err = s.CheckConsistency(false)
+		if err != nil {
+			return err
+		}
+		if len(inconsistencies) != 0 {
+			return errors.New(fmt.Sprintf("Expected no inconsistencies after repair but got %v", inconsistencies))
+		}
+
+		tasks, err := taskService.GetTasks("2", "Maria")
+		if err != nil {
+			return err
+		}
+		for _, tk := range tasks {
+			if tk.Id == "2" && (len(tk.DependsOn) != 1 || tk.DependsOn[0] != "3" || len(tk.AllowedUsers) != 1 || tk.AllowedUsers[0] != "Maria") {
+				return errors.New(fmt.Sprintf("Task 2 not repaired correctly: %#v", tk))
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestCreateTutorialProject(t *testing.T) {
+	h.Run(t, func() error {
+		// Maria already owns project 2, but tutorial projects are not affected by quotas
+		_, err := tx.Exec("INSERT INTO user_quotas(user_id, max_owned_projects, max_tasks_per_project, max_total_tasks, updated_by) VALUES('Maria', 1, 0, 0, 'Peter');")
+		if err != nil {
+			return err
+		}
+
+		project, err := s.CreateTutorialProject("Maria")
+		if err != nil {
+			return err
+		}
+		if !project.Tutorial || project.Owner != "Maria" || len(project.Users) != 1 || len(project.TaskIDs) != len(tutorialGeometries) {
+			return errors.New(fmt.Sprintf("Tutorial project does not match: %#v", project))
+		}
+
+		_, err = s.CreateTutorialProject("Maria")
+		if err == nil {
+			return errors.New("Second tutorial project should not be possible")
+		}
+
+		// The tutorial project doesn't count towards the quota
+		_, err = s.AddProjectWithTasks(&Project{Name: "Test name", Users: []string{"Maria"}, Owner: "Maria"}, []*task.Task{})
+		if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+			return errors.New(fmt.Sprintf("Adding project exceeding the quota should fail: %v", err))
+		}
+
+		_, err = s.UpdatePublic(project.Id, true, "Maria")
+		if err == nil {
+			return errors.New("Tutorial project should not be public")
+		}
+
+		_, _, _, err = s.RequestMerge(project.Id, "2", "Maria")
+		if err == nil {
+			return errors.New("Tutorial project should not be mergeable")
+		}
+
+		// Work on the tutorial is not part of the contributions
+		_, err = taskService.AssignUser(project.TaskIDs[0], "Maria")
+		if err != nil {
+			return err
+		}
+		_, err = taskService.SetProcessPoints(project.TaskIDs[0], tutorialMaxProcessPoints, "Maria")
+		if err != nil {
+			return err
+		}
+
+		contributions, err := taskService.GetContributions("Maria")
+		if err != nil {
+			return err
+		}
+		for _, c := range contributions {
+			if c.ProjectId == project.Id {
+				return errors.New(fmt.Sprintf("Contributions should not contain tutorial tasks: %#v", c))
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestUpdateLayers(t *testing.T) {
+	h.Run(t, func() error {
+		layers := []*Layer{
+			{Name: " Esri ", Type: LayerTypeTms, Url: "https://{switch:a,b}.example.com/{z}/{y}/{x}.jpg", Attribution: "© Esri", MaxZoom: 19},
+			{Name: "City orthophotos", Type: LayerTypeWms, Url: "https://geo.example.com/wms?SERVICE=WMS&BBOX={bbox}&WIDTH={width}"},
+		}
+		project, err := s.UpdateLayers("1", layers, "Peter")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error updating layers wasn't expected: %s", err))
+		}
+		if len(project.Layers) != 2 || project.Layers[0].Name != "Esri" || project.Layers[0].MaxZoom != 19 || project.Layers[1].Type != LayerTypeWms {
+			return errors.New(fmt.Sprintf("New layers don't match with expected ones: %#v", project.Layers))
+		}
+
+		project, err = s.GetProject("1", "Maria")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error getting project wasn't expected: %s", err))
+		}
+		if len(project.Layers) != 2 || project.Layers[0].Url != layers[0].Url {
+			return errors.New(fmt.Sprintf("Members should get the layers with the project: %#v", project.Layers))
+		}
+
+		// With non-owner (Maria)
+
+		_, err = s.UpdateLayers("1", layers, "Maria")
+		if err == nil {
+			return errors.New("Updating layers should not be possible for non-owner user Maria")
+		}
+
+		// Invalid layers
+
+		invalidLayers := map[string]*Layer{
+			"missing name":              {Type: LayerTypeTms, Url: "https://example.com/{z}/{x}/{y}.png"},
+			"unknown type":              {Name: "a", Type: "wmts", Url: "https://example.com/{z}/{x}/{y}.png"},
+			"TMS without placeholders":  {Name: "a", Type: LayerTypeTms, Url: "https://example.com/tiles.png"},
+			"WMS without bbox":          {Name: "a", Type: LayerTypeWms, Url: "https://example.com/wms?LAYERS=ortho"},
+			"other scheme":              {Name: "a", Type: LayerTypeTms, Url: "ftp://example.com/{z}/{x}/{y}.png"},
+			"missing host":              {Name: "a", Type: LayerTypeTms, Url: "https:///{z}/{x}/{y}.png"},
+			"too high maximum zoom":     {Name: "a", Type: LayerTypeTms, Url: "https://example.com/{z}/{x}/{y}.png", MaxZoom: 25},
+			"too long attribution text": {Name: "a", Type: LayerTypeTms, Url: "https://example.com/{z}/{x}/{y}.png", Attribution: strings.Repeat("a", 1001)},
+		}
+		for reason, layer := range invalidLayers {
+			_, err = s.UpdateLayers("1", []*Layer{layer}, "Peter")
+			if err == nil {
+				return errors.New(fmt.Sprintf("Updating layers should not be possible with %s", reason))
+			}
+		}
+
+		_, err = s.UpdateLayers("1", []*Layer{layers[0], layers[0]}, "Peter")
+		if err == nil {
+			return errors.New("Updating layers should not be possible with two layers of the same name")
+		}
+
+		// Remove all layers
+
+		project, err = s.UpdateLayers("1", nil, "Peter")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error removing layers wasn't expected: %s", err))
+		}
+		if len(project.Layers) != 0 {
+			return errors.New(fmt.Sprintf("Layers should have been removed: %#v", project.Layers))
+		}
+
+		return nil
+	})
+}
+
+func TestUpdateChecklist(t *testing.T) {
+	h.Run(t, func() error {
+		checklist := task.Checklist{{Text: "Buildings"}, {Text: "Roads"}, {Text: "Addresses"}}
+		project, err := s.UpdateChecklist("2", checklist, true, "Maria")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error updating checklist wasn't expected: %s", err))
+		}
+		if len(project.Checklist) != 3 || project.Checklist[0].Id != "1" || project.Checklist[2].Id != "3" || !project.ChecklistPoints {
+			return errors.New(fmt.Sprintf("New checklist doesn't match with expected one: %#v", project.Checklist))
+		}
+
+		_, err = taskService.SetChecklistDone("3", []string{"1", "2"}, "Maria")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error completing checklist items wasn't expected: %s", err))
+		}
+
+		// Remove "Roads" and add a new item, which must not reuse the ID of the removed one
+		project, err = s.UpdateChecklist("2", task.Checklist{{Id: "1", Text: "Buildings"}, {Id: "3", Text: "Addresses"}, {Text: "Review"}}, false, "Maria")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error updating checklist wasn't expected: %s", err))
+		}
+		if len(project.Checklist) != 3 || project.Checklist[2].Id != "4" || project.ChecklistPoints {
+			return errors.New(fmt.Sprintf("Updated checklist doesn't match with expected one: %#v", project.Checklist))
+		}
+
+		updatedTask, err := taskService.GetTask("3", "Maria")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error getting task wasn't expected: %s", err))
+		}
+		if len(updatedTask.ChecklistDone) != 1 || updatedTask.ChecklistDone[0] != "1" {
+			return errors.New(fmt.Sprintf("Removed items should be removed from the tasks: %v", updatedTask.ChecklistDone))
+		}
+
+		// With non-owner (Maria in project 1)
+
+		_, err = s.UpdateChecklist("1", checklist, false, "Maria")
+		if err == nil {
+			return errors.New("Updating the checklist should not be possible for non-owner user Maria")
+		}
+
+		_, err = s.UpdateChecklist("2", task.Checklist{{Id: "2", Text: "Roads"}}, false, "Maria")
+		if err == nil {
+			return errors.New("Removed items should not be usable again")
+		}
+
+		return nil
+	})
+}
+
+func TestLockProject(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := s.UpdateName("1", "Locked project", "Peter")
+		if err != nil {
+			return err
+		}
+
+		// Other transactions have to wait until the change is committed
+		otherTx, err := database.GetTransaction(context.Background(), util.NewLogger())
+		if err != nil {
+			return err
+		}
+		defer otherTx.Rollback()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		err = getStore(ctx, otherTx, util.NewLogger()).LockProject("1")
+		if err == nil {
+			return errors.New("Project changed by another transaction should not be lockable")
+		}
+
+		err = s.store.LockProject("300")
+		if err == nil {
+			return errors.New("Locking a not existing project should not be possible")
+		}
+
+		return nil
+	})
+}
+
+func TestGetMembers(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := s.AddUser("2", "Peter", "Maria")
+		if err != nil {
+			return err
+		}
+
+		members, err := s.GetMembers("2", "Maria")
+		if err != nil {
+			return err
+		}
+
+		if len(members) != 7 {
+			return errors.New(fmt.Sprintf("Expected 7 members but got %d", len(members)))
+		}
+
+		owner := members[0]
+		if owner.UserId != "Maria" || owner.Role != MemberRoleOwner || owner.Tasks != 1 || owner.CompletedTasks != 0 || owner.ProcessPoints != 50 {
+			return errors.New(fmt.Sprintf("Owner does not match: %#v", owner))
+		}
+		if owner.LastActivity == nil || !owner.LastActivity.Equal(time.Date(2020, 9, 2, 9, 0, 0, 0, time.UTC)) {
+			return errors.New(fmt.Sprintf("Unexpected last activity of owner: %v", owner.LastActivity))
+		}
+
+		for _, member := range members {
+			switch member.UserId {
+			case "Clara":
+				if member.Role != MemberRoleMember || member.Tasks != 1 || member.CompletedTasks != 1 || member.ProcessPoints != 100 {
+					return errors.New(fmt.Sprintf("Clara does not match: %#v", member))
+				}
+			case "John":
+				if member.LastActivity != nil || member.Tasks != 0 || !member.JoinedAt.Equal(owner.JoinedAt) {
+					return errors.New(fmt.Sprintf("John never worked on a task and is member since the creation: %#v", member))
+				}
+			case "Peter":
+				if member.JoinedAt.Before(owner.JoinedAt) {
+					return errors.New(fmt.Sprintf("Peter should have joined after the creation: %v < %v", member.JoinedAt, owner.JoinedAt))
+				}
+			}
+		}
+
+		// Only the owner is allowed to see the members
+		_, err = s.GetMembers("2", "John")
+		if err == nil {
+			return errors.New("John is not the owner of project 2")
+		}
+
+		return nil
+	})
+}
+
+func TestExportMembersCsv(t *testing.T) {
+	lastActivity := time.Date(2021, 5, 4, 12, 0, 0, 0, time.UTC)
+	members := []*Member{
+		{UserId: "Maria", Role: MemberRoleOwner, JoinedAt: time.Date(2021, 5, 1, 8, 0, 0, 0, time.UTC), LastActivity: &lastActivity, Tasks: 2, CompletedTasks: 1, ProcessPoints: 150},
+		{UserId: "John", Role: MemberRoleMember, JoinedAt: time.Date(2021, 5, 2, 8, 0, 0, 0, time.UTC)},
+	}
+
+	data, err := ExportMembersCsv(members)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "user_id,role,joined_at,last_activity,tasks,completed_tasks,process_points\n" +
+		"Maria,owner,2021-05-01T08:00:00Z,2021-05-04T12:00:00Z,2,1,150\n" +
+		"John,member,2021-05-02T08:00:00Z,,0,0,0\n"
+	if string(data) != expected {
+		t.Errorf("Unexpected CSV:\n%s", string(data))
+	}
 }
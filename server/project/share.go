@@ -0,0 +1,51 @@
+package project
+
+import (
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/auth"
+	"github.com/hauke96/simple-task-manager/server/privacy"
+)
+
+// CreateShareLink creates a token granting read-only access to the project without an account, e.g. for people
+// following the progress of a mapping event. Only the owner can share the project.
+func (s *ProjectService) CreateShareLink(projectId string, validity time.Duration, requestingUserId string) (string, time.Time, error) {
+	err := s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, validUntil, err := auth.CreateShareToken(projectId, validity)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	s.Log("Created share link for project %s valid until %s", projectId, validUntil)
+
+	return token, validUntil, nil
+}
+
+// GetSharedProject returns the project for users of a share link. The share token has to be verified by the caller, so
+// no membership is required. The members are not part of the returned project and the owner is anonymized when this is
+// enabled (see "privacy.Pseudonym").
+func (s *ProjectService) GetSharedProject(projectId string) (*Project, error) {
+	project, err := s.store.GetProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.addMetadata(project)
+	if err != nil {
+		s.Err("Unable to add process point data to project %s", project.Id)
+		return nil, err
+	}
+
+	project.Users = []string{}
+	project.Owner = privacy.Pseudonym(project.Owner)
+
+	return project, nil
+}
+
+// GetSharedSnapshots works like "GetSnapshots" but for users of a share link, see "GetSharedProject".
+func (s *ProjectService) GetSharedSnapshots(projectId string) ([]*Snapshot, error) {
+	return s.store.GetSnapshots(projectId)
+}
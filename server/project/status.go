@@ -0,0 +1,116 @@
+package project
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Values of the computed "Status" of a project
+const (
+	StatusNotStarted = "not-started"
+	StatusInProgress = "in-progress"
+	StatusNearlyDone = "nearly-done"
+	StatusComplete   = "complete"
+)
+
+var (
+	// Ratios of done process points above (in progress) or from (nearly done) which a project has the according status
+	statusInProgressThreshold = 0.0
+	statusNearlyDoneThreshold = 0.8
+
+	badgeColors = map[string]string{
+		StatusNotStarted: "#e05d44",
+		StatusInProgress: "#dfb317",
+		StatusNearlyDone: "#a4a61d",
+		StatusComplete:   "#4c1",
+	}
+)
+
+// SetStatusThresholds sets the ratios of done process points used to compute the status. A project is in progress when
+// more than "inProgress" of the points are done and nearly done from "nearlyDone" on.
+func SetStatusThresholds(inProgress float64, nearlyDone float64) error {
+	if inProgress < 0 || nearlyDone > 1 || inProgress >= nearlyDone {
+		return errors.New(fmt.Sprintf("status thresholds must fulfill 0 <= in-progress < nearly-done <= 1 but were %f and %f", inProgress, nearlyDone))
+	}
+
+	statusInProgressThreshold = inProgress
+	statusNearlyDoneThreshold = nearlyDone
+
+	return nil
+}
+
+// getStatus determines the status based on the process points and the configured thresholds. Projects without process
+// points haven't been started.
+func getStatus(doneProcessPoints int, totalProcessPoints int) string {
+	if totalProcessPoints <= 0 {
+		return StatusNotStarted
+	}
+	if doneProcessPoints >= totalProcessPoints {
+		return StatusComplete
+	}
+
+	ratio := float64(doneProcessPoints) / float64(totalProcessPoints)
+	if ratio >= statusNearlyDoneThreshold {
+		return StatusNearlyDone
+	}
+	if ratio > statusInProgressThreshold {
+		return StatusInProgress
+	}
+
+	return StatusNotStarted
+}
+
+// RenderStatusBadge renders the status of the project as SVG image. The badge is meant to be embedded into e.g. wiki
+// pages, so no membership is required. Therefore it contains only the status and the progress in percent and nothing
+// like the name of the project.
+func (s *ProjectService) RenderStatusBadge(projectId string) ([]byte, error) {
+	project, err := s.store.GetProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Log("Rendered status badge of project %s", projectId)
+
+	return RenderBadge(project.DoneProcessPoints, project.TotalProcessPoints), nil
+}
+
+// RenderBadge creates a SVG badge in the usual style: A gray label on the left and the status on the right, colored
+// according to the status.
+func RenderBadge(doneProcessPoints int, totalProcessPoints int) []byte {
+	status := getStatus(doneProcessPoints, totalProcessPoints)
+
+	label := "tasks"
+	value := "not started"
+	switch status {
+	case StatusInProgress, StatusNearlyDone:
+		percent := int(math.Floor(float64(doneProcessPoints) / float64(totalProcessPoints) * 100))
+		value = fmt.Sprintf("%d%% done", percent)
+	case StatusComplete:
+		value = "complete"
+	}
+
+	// Rough estimation of the text widths, exact values would need the font metrics
+	labelWidth := badgeTextWidth(label)
+	valueWidth := badgeTextWidth(value)
+	width := labelWidth + valueWidth
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`, width, label, value)
+	fmt.Fprintf(&buffer, `<title>%s: %s</title>`, label, value)
+	fmt.Fprintf(&buffer, `<rect width="%d" height="20" rx="3" fill="#555"/>`, width)
+	fmt.Fprintf(&buffer, `<rect x="%d" width="%d" height="20" rx="3" fill="%s"/>`, labelWidth, valueWidth, badgeColors[status])
+	fmt.Fprintf(&buffer, `<rect x="%d" width="4" height="20" fill="%s"/>`, labelWidth, badgeColors[status])
+	fmt.Fprintf(&buffer, `<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`)
+	fmt.Fprintf(&buffer, `<text x="%d" y="14">%s</text>`, labelWidth/2, label)
+	fmt.Fprintf(&buffer, `<text x="%d" y="14">%s</text>`, labelWidth+valueWidth/2, value)
+	fmt.Fprintf(&buffer, `</g></svg>`)
+
+	return buffer.Bytes()
+}
+
+func badgeTextWidth(text string) int {
+	return len(text)*7 + 10
+}
@@ -0,0 +1,212 @@
+package project
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Values of the "Status" of a TargetState
+const (
+	TargetStatusOnTrack  = "on_track"  // Target not reached yet but the recent progress is fast enough to reach it
+	TargetStatusOffTrack = "off_track" // Target not reached yet and the recent progress is too slow to reach it
+	TargetStatusReached  = "reached"
+	TargetStatusMissed   = "missed" // Target date has passed without reaching the target
+)
+
+const (
+	targetDateFormat = "2006-01-02"
+	maxTargets       = 10
+
+	// The progress of this many days before today is used to estimate the progress until the target date
+	targetTrendDays = 7
+)
+
+// TargetState tells whether a target has been or will probably be reached.
+type TargetState struct {
+	Target
+	Status              string `json:"status"`              // One of the "TargetStatus..." values
+	ProjectedPercentage int    `json:"projectedPercentage"` // Expected percentage at the target date based on the recent progress, the actual percentage for passed targets
+}
+
+// UpdateTargets replaces all targets of the project. The targets are ordered by their date and each date can only be
+// used once.
+func (s *ProjectService) UpdateTargets(projectId string, targets []*Target, requestingUserId string) (*Project, error) {
+	err := s.store.LockProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if targets == nil {
+		targets = make([]*Target, 0)
+	}
+
+	err = verifyTargets(targets)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Date < targets[j].Date
+	})
+
+	project, err := s.store.UpdateTargets(projectId, targets)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Updated targets of project %s to %d targets", project.Id, len(targets))
+
+	err = s.addMetadata(project)
+	if err != nil {
+		s.Err("Unable to add process point data to project %s", project.Id)
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// GetTargetStates returns the state of all targets of the project, see "EvaluateTargets".
+func (s *ProjectService) GetTargetStates(projectId string, requestingUserId string) ([]*TargetState, error) {
+	err := s.permissionService.VerifyMembershipProject(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.EvaluateTargets(projectId)
+}
+
+// EvaluateTargets determines for each target of the project whether it has been reached, missed or is on track. This
+// doesn't check any permissions and is e.g. used by the digest mails.
+func (s *ProjectService) EvaluateTargets(projectId string) ([]*TargetState, error) {
+	project, err := s.store.GetProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(project.Targets) == 0 {
+		return make([]*TargetState, 0), nil
+	}
+
+	snapshots, err := s.store.GetSnapshots(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	return evaluateTargets(project.Targets, snapshots, project.DoneProcessPoints, project.TotalProcessPoints, time.Now())
+}
+
+func verifyTargets(targets []*Target) error {
+	if len(targets) > maxTargets {
+		return errors.New(fmt.Sprintf("A project can have at most %d targets", maxTargets))
+	}
+
+	dates := make(map[string]bool)
+	for _, t := range targets {
+		if t == nil {
+			return errors.New("Target must not be empty")
+		}
+
+		if t.Percentage < 1 || t.Percentage > 100 {
+			return errors.New(fmt.Sprintf("Target percentage must be between 1 and 100 but was %d", t.Percentage))
+		}
+
+		_, err := time.Parse(targetDateFormat, t.Date)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("Target date '%s' must have the format YYYY-MM-DD", t.Date))
+		}
+
+		if dates[t.Date] {
+			return errors.New(fmt.Sprintf("There's already a target for %s", t.Date))
+		}
+		dates[t.Date] = true
+	}
+
+	return nil
+}
+
+// evaluateTargets compares the targets with the current progress and the snapshots (in chronological order). Passed
+// targets are reached when the snapshot of the target date (or the latest one before) reached the percentage. For
+// upcoming targets, the average daily progress of the last days is extrapolated until the target date.
+func evaluateTargets(targets []*Target, snapshots []*Snapshot, doneProcessPoints int, totalProcessPoints int, now time.Time) ([]*TargetState, error) {
+	today, err := time.Parse(targetDateFormat, now.Format(targetDateFormat))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing current date")
+	}
+
+	currentPercentage := percentage(doneProcessPoints, totalProcessPoints)
+	dailyProgress := 0.0
+	trendStart := today.AddDate(0, 0, -targetTrendDays).Format(targetDateFormat)
+	for _, snapshot := range snapshots {
+		if snapshot.Date < trendStart {
+			continue
+		}
+
+		snapshotDate, err := time.Parse(targetDateFormat, snapshot.Date)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid date of snapshot '%s'", snapshot.Date))
+		}
+
+		days := today.Sub(snapshotDate).Hours() / 24
+		if days >= 1 {
+			dailyProgress = (currentPercentage - percentage(snapshot.DoneProcessPoints, snapshot.TotalProcessPoints)) / days
+		}
+		break
+	}
+
+	states := make([]*TargetState, len(targets))
+	for i, t := range targets {
+		targetDate, err := time.Parse(targetDateFormat, t.Date)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid date of target '%s'", t.Date))
+		}
+
+		state := &TargetState{Target: *t}
+
+		if targetDate.Before(today) {
+			reachedPercentage := 0.0
+			for _, snapshot := range snapshots {
+				if snapshot.Date > t.Date {
+					break
+				}
+				reachedPercentage = percentage(snapshot.DoneProcessPoints, snapshot.TotalProcessPoints)
+			}
+
+			state.ProjectedPercentage = int(math.Floor(reachedPercentage))
+			state.Status = TargetStatusMissed
+			if reachedPercentage >= float64(t.Percentage) {
+				state.Status = TargetStatusReached
+			}
+		} else if currentPercentage >= float64(t.Percentage) {
+			state.ProjectedPercentage = int(math.Floor(currentPercentage))
+			state.Status = TargetStatusReached
+		} else {
+			remainingDays := targetDate.Sub(today).Hours() / 24
+			projectedPercentage := math.Min(100, currentPercentage+math.Max(0, dailyProgress)*remainingDays)
+
+			state.ProjectedPercentage = int(math.Floor(projectedPercentage))
+			state.Status = TargetStatusOffTrack
+			if projectedPercentage >= float64(t.Percentage) {
+				state.Status = TargetStatusOnTrack
+			}
+		}
+
+		states[i] = state
+	}
+
+	return states, nil
+}
+
+func percentage(doneProcessPoints int, totalProcessPoints int) float64 {
+	if totalProcessPoints <= 0 {
+		return 0
+	}
+	return float64(doneProcessPoints) * 100 / float64(totalProcessPoints)
+}
@@ -0,0 +1,60 @@
+package project
+
+import (
+	"fmt"
+
+	"github.com/hauke96/simple-task-manager/server/task"
+	"github.com/pkg/errors"
+)
+
+const (
+	tutorialName             = "Tutorial"
+	tutorialDescription      = "This is your personal sandbox project. Assign a task to yourself, set its process points and unassign it again. Nothing you do here counts towards any statistics, so feel free to try everything out."
+	tutorialMaxProcessPoints = 10
+)
+
+// Four small squares next to each other (in Hamburg), so that the tutorial works the same for everyone.
+var tutorialGeometries = []string{
+	`{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[9.990,53.550],[9.995,53.550],[9.995,53.553],[9.990,53.553],[9.990,53.550]]]},"properties":{"name":"1"}}`,
+	`{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[9.995,53.550],[10.000,53.550],[10.000,53.553],[9.995,53.553],[9.995,53.550]]]},"properties":{"name":"2"}}`,
+	`{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[9.990,53.553],[9.995,53.553],[9.995,53.556],[9.990,53.556],[9.990,53.553]]]},"properties":{"name":"3"}}`,
+	`{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[9.995,53.553],[10.000,53.553],[10.000,53.556],[9.995,53.556],[9.995,53.553]]]},"properties":{"name":"4"}}`,
+}
+
+// CreateTutorialProject creates a small sandbox project with predefined tasks for a new user, who becomes its owner and
+// only member. Tutorial projects don't count towards the quota and are excluded from the contributions, they also can't
+// be made public or be merged. Each user can only have one tutorial project at a time.
+func (s *ProjectService) CreateTutorialProject(userId string) (*Project, error) {
+	ownedProjects, err := s.GetOwnedProjects(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range ownedProjects {
+		if p.Tutorial {
+			return nil, errors.New(fmt.Sprintf("user %s already has the tutorial project %s", userId, p.Id))
+		}
+	}
+
+	tasks := make([]*task.Task, len(tutorialGeometries))
+	for i, geometry := range tutorialGeometries {
+		tasks[i] = &task.Task{
+			MaxProcessPoints: tutorialMaxProcessPoints,
+			Geometry:         geometry,
+		}
+	}
+
+	project, err := s.AddProjectWithTasks(&Project{
+		Name:        tutorialName,
+		Description: tutorialDescription,
+		Users:       []string{userId},
+		Owner:       userId,
+		Tutorial:    true,
+	}, tasks)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("error creating tutorial project for user %s", userId))
+	}
+	s.Log("Created tutorial project %s for user %s", project.Id, userId)
+
+	return project, nil
+}
@@ -0,0 +1,320 @@
+package push
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+
+	"github.com/hauke96/simple-task-manager/server/secret"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Subscription of a browser to receive push messages, the JSON format is the one of "PushSubscription.toJSON()" of the
+// Push API of browsers.
+type Subscription struct {
+	Endpoint       string           `json:"endpoint"`
+	ExpirationTime *int64           `json:"expirationTime,omitempty"` // Sent by browsers but not used, expired subscriptions are removed when sending fails
+	Keys           SubscriptionKeys `json:"keys"`
+	UserId         string           `json:"-"`
+}
+
+type SubscriptionKeys struct {
+	P256dh string `json:"p256dh"` // Public key of the browser to encrypt messages with (base64url)
+	Auth   string `json:"auth"`   // Secret of the browser to encrypt messages with (base64url)
+}
+
+// Subscriptions of one user above this are rejected, users don't have that many browsers
+const maxSubscriptionsPerUser = 20
+
+var (
+	vapidKey     *ecdsa.PrivateKey
+	vapidSubject string
+)
+
+type PushService struct {
+	*util.Logger
+	store *storePg
+}
+
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger) *PushService {
+	return &PushService{
+		Logger: logger,
+		store:  getStore(ctx, tx, logger),
+	}
+}
+
+// Configure sets the VAPID key push messages are signed with and the contact (a "mailto:" or "https:" URL) push
+// services can use in case of problems. Without key, push messages are disabled. The keys of the subscriptions are
+// secrets of the browsers and only stored encrypted, so the encryption keys (see package "secret") have to be
+// configured before.
+func Configure(privateKey string, subject string) error {
+	vapidKey = nil
+	vapidSubject = subject
+
+	if privateKey == "" {
+		return nil
+	}
+
+	if !secret.Enabled() {
+		return errors.New("push messages need an encryption key to store the subscriptions")
+	}
+
+	if !strings.HasPrefix(subject, "mailto:") && !strings.HasPrefix(subject, "https:") {
+		return errors.New(fmt.Sprintf("push subject '%s' must be a 'mailto:' or 'https:' URL", subject))
+	}
+
+	keyBytes, err := decodeBase64(privateKey)
+	if err != nil || len(keyBytes) != 32 {
+		return errors.New("VAPID private key must be 32 bytes encoded as base64url")
+	}
+
+	curve := elliptic.P256()
+	key := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(keyBytes)}
+	key.PublicKey.Curve = curve
+	key.PublicKey.X, key.PublicKey.Y = curve.ScalarBaseMult(keyBytes)
+
+	vapidKey = key
+	return nil
+}
+
+// Enabled returns true when a VAPID key is configured. When this is false, nothing can be sent.
+func Enabled() bool {
+	return vapidKey != nil
+}
+
+// PublicKey returns the public VAPID key (base64url), which browsers need to subscribe. It's empty when push messages
+// are disabled.
+func PublicKey() string {
+	if !Enabled() {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(elliptic.Marshal(vapidKey.Curve, vapidKey.X, vapidKey.Y))
+}
+
+// GenerateKey creates a new VAPID key pair and returns the private and public key as base64url.
+func GenerateKey() (string, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", errors.Wrap(err, "unable to generate VAPID key")
+	}
+
+	privateKey := base64.RawURLEncoding.EncodeToString(padTo32(key.D.Bytes()))
+	publicKey := base64.RawURLEncoding.EncodeToString(elliptic.Marshal(key.Curve, key.X, key.Y))
+
+	return privateKey, publicKey, nil
+}
+
+// Subscribe stores the subscription of a browser of the requesting user. An existing subscription with the same
+// endpoint is replaced, since browsers keep the endpoint when subscribing again.
+func (s *PushService) Subscribe(subscription *Subscription, requestingUserId string) (*Subscription, error) {
+	if !Enabled() {
+		return nil, errors.New("push messages are disabled on this server")
+	}
+
+	err := subscription.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions, err := s.store.getSubscriptions(requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+	if len(subscriptions) >= maxSubscriptionsPerUser {
+		return nil, errors.New(fmt.Sprintf("user %s already has the maximum of %d push subscriptions", requestingUserId, maxSubscriptionsPerUser))
+	}
+
+	subscription, err = s.store.addSubscription(subscription, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("User %s subscribed to push messages", requestingUserId)
+
+	return subscription, nil
+}
+
+// Unsubscribe removes the subscription with the endpoint, if it belongs to the requesting user. Removing an unknown
+// subscription has no effect, so that browsers can always unsubscribe.
+func (s *PushService) Unsubscribe(endpoint string, requestingUserId string) error {
+	err := s.store.removeSubscription(endpoint, requestingUserId)
+	if err != nil {
+		return err
+	}
+	s.Log("User %s unsubscribed from push messages", requestingUserId)
+
+	return nil
+}
+
+// GetSubscriptions returns all subscriptions of the user, oldest first.
+func (s *PushService) GetSubscriptions(userId string) ([]*Subscription, error) {
+	return s.store.getSubscriptions(userId)
+}
+
+// ReencryptSubscriptions encrypts the keys of all subscriptions not encrypted with the current encryption key (see
+// "secret.IsCurrent") again, e.g. after a key rotation or for subscriptions stored before the keys were encrypted. The
+// number of changed subscriptions is returned.
+func (s *PushService) ReencryptSubscriptions() (int, error) {
+	subscriptions, err := s.store.getStoredSubscriptions()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, subscription := range subscriptions {
+		if secret.IsCurrent(subscription.Keys.P256dh) && secret.IsCurrent(subscription.Keys.Auth) {
+			continue
+		}
+
+		err = subscription.decryptKeys()
+		if err != nil {
+			return count, errors.Wrapf(err, "unable to decrypt keys of push subscription of user %s", subscription.UserId)
+		}
+
+		err = s.store.updateKeys(subscription)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	if count != 0 {
+		s.Log("Encrypted the keys of %d push subscriptions again", count)
+	}
+
+	return count, nil
+}
+
+// ReencryptSubscriptionsJob runs "ReencryptSubscriptions" as scheduled job.
+func ReencryptSubscriptionsJob(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	if !secret.Enabled() {
+		return nil
+	}
+
+	_, err := Init(ctx, tx, logger).ReencryptSubscriptions()
+	return err
+}
+
+// RemoveGoneSubscription removes the given subscription (as returned by "Subscription.String()") after the push service
+// reported it as gone, e.g. because the user revoked the permission.
+func (s *PushService) RemoveGoneSubscription(subscriptionJson string) error {
+	subscription, err := ParseSubscription(subscriptionJson)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.removeSubscription(subscription.Endpoint, subscription.UserId)
+	if err != nil {
+		return err
+	}
+	s.Log("Removed gone push subscription of user %s", subscription.UserId)
+
+	return nil
+}
+
+// String returns the subscription including the user as JSON, e.g. to store it as recipient of a message in the outbox.
+func (s *Subscription) String() string {
+	data, _ := json.Marshal(struct {
+		*Subscription
+		UserId string `json:"userId"`
+	}{s, s.UserId})
+	return string(data)
+}
+
+// WithEncryptedKeys returns a copy of the subscription with the keys encrypted by the current encryption key, see
+// package "secret". Subscriptions are only stored this way, e.g. in the database or as recipient of the outbox.
+func (s *Subscription) WithEncryptedKeys() (*Subscription, error) {
+	p256dh, err := secret.Encrypt(s.Keys.P256dh)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encrypt push subscription key")
+	}
+	auth, err := secret.Encrypt(s.Keys.Auth)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encrypt push subscription secret")
+	}
+
+	encrypted := *s
+	encrypted.Keys = SubscriptionKeys{P256dh: p256dh, Auth: auth}
+	return &encrypted, nil
+}
+
+// decryptKeys decrypts the keys encrypted by "WithEncryptedKeys". Keys stored before they were encrypted stay as they
+// are.
+func (s *Subscription) decryptKeys() error {
+	p256dh, err := secret.Decrypt(s.Keys.P256dh)
+	if err != nil {
+		return err
+	}
+	auth, err := secret.Decrypt(s.Keys.Auth)
+	if err != nil {
+		return err
+	}
+
+	s.Keys = SubscriptionKeys{P256dh: p256dh, Auth: auth}
+	return nil
+}
+
+// ParseSubscription reads a subscription created by "Subscription.String()". Encrypted keys (see "WithEncryptedKeys")
+// are decrypted.
+func ParseSubscription(subscriptionJson string) (*Subscription, error) {
+	var data struct {
+		Subscription
+		UserId string `json:"userId"`
+	}
+
+	err := json.Unmarshal([]byte(subscriptionJson), &data)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse push subscription")
+	}
+
+	subscription := data.Subscription
+	subscription.UserId = data.UserId
+
+	err = subscription.decryptKeys()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decrypt keys of push subscription")
+	}
+
+	return &subscription, nil
+}
+
+// validate checks that the endpoint is a HTTPS URL and the keys can be used to encrypt messages.
+func (s *Subscription) validate() error {
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		return errors.New(fmt.Sprintf("push endpoint '%s' is no HTTPS URL", s.Endpoint))
+	}
+
+	_, err = parsePublicKey(s.Keys.P256dh)
+	if err != nil {
+		return err
+	}
+
+	auth, err := decodeBase64(s.Keys.Auth)
+	if err != nil || len(auth) != authSecretLength {
+		return errors.New(fmt.Sprintf("push auth secret must be %d bytes encoded as base64url", authSecretLength))
+	}
+
+	return nil
+}
+
+// decodeBase64 decodes base64url with and without padding, since browsers send keys without padding.
+func decodeBase64(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// padTo32 adds leading zeros to big-endian numbers shorter than 32 bytes.
+func padTo32(bytes []byte) []byte {
+	result := make([]byte, 32)
+	copy(result[32-len(bytes):], bytes)
+	return result
+}
@@ -0,0 +1,134 @@
+package push
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+type storePg struct {
+	*util.Logger
+	ctx   context.Context
+	tx    *sql.Tx
+	table database.Table
+}
+
+func getStore(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
+	return &storePg{
+		Logger: logger,
+		ctx:    ctx,
+		tx:     tx,
+		table:  database.TablePushSubscriptions,
+	}
+}
+
+// addSubscription stores the subscription for the user with encrypted keys. An existing subscription with the same
+// endpoint is replaced.
+func (s *storePg) addSubscription(subscription *Subscription, userId string) (*Subscription, error) {
+	encrypted, err := subscription.WithEncryptedKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s(endpoint, user_id, p256dh, auth) VALUES($1, $2, $3, $4)
+ON CONFLICT (endpoint) DO UPDATE SET user_id=EXCLUDED.user_id, p256dh=EXCLUDED.p256dh, auth=EXCLUDED.auth, created_at=NOW()
+RETURNING endpoint, user_id, p256dh, auth;`, s.table)
+	subscriptions, err := s.querySubscriptions(query, encrypted.Endpoint, userId, encrypted.Keys.P256dh, encrypted.Keys.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriptions[0], nil
+}
+
+func (s *storePg) getSubscriptions(userId string) ([]*Subscription, error) {
+	query := fmt.Sprintf("SELECT endpoint, user_id, p256dh, auth FROM %s WHERE user_id=$1 ORDER BY created_at, endpoint;", s.table)
+	return s.querySubscriptions(query, userId)
+}
+
+// getStoredSubscriptions returns the subscriptions of all users with the keys as they are stored, so without decrypting
+// them.
+func (s *storePg) getStoredSubscriptions() ([]*Subscription, error) {
+	query := fmt.Sprintf("SELECT endpoint, user_id, p256dh, auth FROM %s ORDER BY endpoint;", s.table)
+	return s.queryStoredSubscriptions(query)
+}
+
+// updateKeys stores the keys of the subscription encrypted with the current encryption key.
+func (s *storePg) updateKeys(subscription *Subscription) error {
+	encrypted, err := subscription.WithEncryptedKeys()
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET p256dh=$2, auth=$3 WHERE endpoint=$1;", s.table)
+	s.LogQuery(query, encrypted.Endpoint, "<encrypted>", "<encrypted>")
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err = s.tx.ExecContext(ctx, query, encrypted.Endpoint, encrypted.Keys.P256dh, encrypted.Keys.Auth)
+	if err != nil {
+		return errors.Wrapf(err, "error updating keys of push subscription of user %s", subscription.UserId)
+	}
+
+	return nil
+}
+
+func (s *storePg) removeSubscription(endpoint string, userId string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE endpoint=$1 AND user_id=$2;", s.table)
+	s.LogQuery(query, endpoint, userId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, endpoint, userId)
+	if err != nil {
+		return errors.Wrapf(err, "error removing push subscription of user %s", userId)
+	}
+
+	return nil
+}
+
+// querySubscriptions works like "queryStoredSubscriptions" but decrypts the keys of the subscriptions.
+func (s *storePg) querySubscriptions(query string, params ...interface{}) ([]*Subscription, error) {
+	subscriptions, err := s.queryStoredSubscriptions(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, subscription := range subscriptions {
+		err = subscription.decryptKeys()
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not decrypt keys of push subscription of user %s", subscription.UserId)
+		}
+	}
+
+	return subscriptions, nil
+}
+
+func (s *storePg) queryStoredSubscriptions(query string, params ...interface{}) ([]*Subscription, error) {
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error executing push subscription query")
+	}
+	defer rows.Close()
+
+	result := make([]*Subscription, 0)
+	for rows.Next() {
+		subscription := &Subscription{}
+		err = rows.Scan(&subscription.Endpoint, &subscription.UserId, &subscription.Keys.P256dh, &subscription.Keys.Auth)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan push subscription")
+		}
+
+		result = append(result, subscription)
+	}
+
+	return result, nil
+}
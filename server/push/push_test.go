@@ -0,0 +1,622 @@
+package push
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/secret"
+	"github.com/hauke96/simple-task-manager/server/test"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq" // Make driver "postgres" usable
+)
+
+var (
+	tx *sql.Tx
+	s  *PushService
+	h  *test.TestHelper
+)
+
+func TestMain(m *testing.M) {
+	h = &test.TestHelper{
+		Setup: setup,
+	}
+
+	m.Run()
+}
+
+func setup() {
+	config.LoadConfig("../config/test.json")
+	test.InitWithDummyData()
+	sigolo.LogLevel = sigolo.LOG_DEBUG
+
+	logger := util.NewLogger()
+
+	ctx := context.Background()
+
+	var err error
+	tx, err = database.GetTransaction(ctx, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	h.Tx = tx
+	s = Init(ctx, tx, logger)
+}
+
+// browser simulates a browser subscribing to push messages and decrypting them.
+type browser struct {
+	privateKey *ecdsa.PrivateKey
+	authSecret []byte
+}
+
+func newBrowser(t *testing.T) *browser {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authSecret := make([]byte, authSecretLength)
+	_, err = rand.Read(authSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &browser{key, authSecret}
+}
+
+func (b *browser) subscription(endpoint string) *Subscription {
+	return &Subscription{
+		Endpoint: endpoint,
+		Keys: SubscriptionKeys{
+			P256dh: base64.RawURLEncoding.EncodeToString(b.publicKey()),
+			Auth:   base64.RawURLEncoding.EncodeToString(b.authSecret),
+		},
+	}
+}
+
+func (b *browser) publicKey() []byte {
+	return elliptic.Marshal(b.privateKey.Curve, b.privateKey.X, b.privateKey.Y)
+}
+
+func (b *browser) decrypt(body []byte) ([]byte, error) {
+	if len(body) < headerLength {
+		return nil, errors.New("body too short")
+	}
+
+	salt := body[:16]
+	if binary.BigEndian.Uint32(body[16:20]) != recordSize || body[20] != 65 {
+		return nil, errors.New("invalid header")
+	}
+	serverPublicKey := body[21:headerLength]
+
+	curve := elliptic.P256()
+	serverX, serverY := elliptic.Unmarshal(curve, serverPublicKey)
+	if serverX == nil {
+		return nil, errors.New("invalid server key")
+	}
+	sharedX, _ := curve.ScalarMult(serverX, serverY, b.privateKey.D.Bytes())
+
+	contentKey, nonce := deriveContentKey(padTo32(sharedX.Bytes()), b.authSecret, salt, b.publicKey(), serverPublicKey)
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, body[headerLength:], nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(plaintext) == 0 || plaintext[len(plaintext)-1] != 2 {
+		return nil, errors.New("missing delimiter")
+	}
+
+	return plaintext[:len(plaintext)-1], nil
+}
+
+const testEncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func configureTestKey(t *testing.T) string {
+	privateKey, publicKey, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = secret.Configure(testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Configure(privateKey, "mailto:admin@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return publicKey
+}
+
+func TestConfigure(t *testing.T) {
+	defer Configure("", "")
+
+	err := Configure("", "")
+	if err != nil {
+		t.Error(err)
+	}
+	if Enabled() || PublicKey() != "" {
+		t.Errorf("Push messages should be disabled without key")
+	}
+
+	privateKey, publicKey, err := GenerateKey()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = Configure(privateKey, "mailto:admin@example.com")
+	if err == nil || Enabled() {
+		t.Errorf("Push messages should not be possible without encryption key")
+	}
+
+	err = secret.Configure(testEncryptionKey)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer secret.Configure("")
+
+	err = Configure(privateKey, "admin@example.com")
+	if err == nil {
+		t.Errorf("Subject without 'mailto:' or 'https:' should not be possible")
+	}
+
+	for _, key := range []string{"foo!", base64.RawURLEncoding.EncodeToString([]byte("too short"))} {
+		err = Configure(key, "mailto:admin@example.com")
+		if err == nil {
+			t.Errorf("Invalid key '%s' should not be possible", key)
+		}
+		if Enabled() {
+			t.Errorf("Push messages should be disabled with invalid key '%s'", key)
+		}
+	}
+
+	err = Configure(privateKey, "mailto:admin@example.com")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !Enabled() {
+		t.Errorf("Push messages should be enabled")
+	}
+	if PublicKey() != publicKey {
+		t.Errorf("Public key '%s' does not match generated key '%s'", PublicKey(), publicKey)
+	}
+
+	// Padded keys work as well
+	err = Configure(base64.URLEncoding.EncodeToString(padTo32(vapidKey.D.Bytes())), "https://example.com")
+	if err != nil {
+		t.Error(err)
+	}
+	if PublicKey() != publicKey {
+		t.Errorf("Public key '%s' of padded key does not match generated key '%s'", PublicKey(), publicKey)
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	b := newBrowser(t)
+	subscription := b.subscription("https://push.example.com/abc")
+
+	payload := `{"title":"Test","body":"Ünïcödé"}`
+	body, err := encrypt(subscription, []byte(payload))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	decrypted, err := b.decrypt(body)
+	if err != nil {
+		t.Errorf("Unable to decrypt message: %s", err.Error())
+		return
+	}
+	if string(decrypted) != payload {
+		t.Errorf("Decrypted payload '%s' does not match '%s'", string(decrypted), payload)
+	}
+
+	// Every message gets a new key and salt
+	otherBody, err := encrypt(subscription, []byte(payload))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(otherBody[:headerLength]) == string(body[:headerLength]) {
+		t.Errorf("Messages should not share salt and key")
+	}
+
+	_, err = encrypt(subscription, []byte(strings.Repeat("a", MaxPayloadSize)))
+	if err != nil {
+		t.Errorf("Payload of maximum size should be possible: %s", err.Error())
+	}
+	_, err = encrypt(subscription, []byte(strings.Repeat("a", MaxPayloadSize+1)))
+	if err == nil {
+		t.Errorf("Payload above maximum size should not be possible")
+	}
+
+	subscription.Keys.Auth = "abc"
+	_, err = encrypt(subscription, []byte(payload))
+	if err == nil {
+		t.Errorf("Invalid auth secret should not be possible")
+	}
+}
+
+func TestVapidAuthorization(t *testing.T) {
+	defer Configure("", "")
+	publicKey := configureTestKey(t)
+
+	now := time.Now()
+	authorization, err := vapidAuthorization("https://push.example.com/abc/def?foo=bar", now)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !strings.HasPrefix(authorization, "vapid t=") || !strings.HasSuffix(authorization, ", k="+publicKey) {
+		t.Errorf("Authorization '%s' has an unexpected format", authorization)
+		return
+	}
+
+	token := strings.TrimSuffix(strings.TrimPrefix(authorization, "vapid t="), ", k="+publicKey)
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Errorf("Token '%s' should have three parts", token)
+		return
+	}
+
+	claimsJson, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var claims struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	err = json.Unmarshal(claimsJson, &claims)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if claims.Aud != "https://push.example.com" || claims.Exp != now.Add(vapidValidity).Unix() || claims.Sub != "mailto:admin@example.com" {
+		t.Errorf("Claims do not match: %s", string(claimsJson))
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		t.Errorf("Signature should have 64 bytes")
+		return
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(signature[:32])
+	sig := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(&vapidKey.PublicKey, hash[:], r, sig) {
+		t.Errorf("Signature is not valid")
+	}
+}
+
+func TestSend(t *testing.T) {
+	defer Configure("", "")
+
+	b := newBrowser(t)
+	subscription := b.subscription("https://push.example.com/abc")
+	subscription.UserId = "Peter"
+
+	err := Send(util.NewLogger(), subscription.String(), "test")
+	if err == nil {
+		t.Errorf("Sending should not be possible without key")
+	}
+
+	configureTestKey(t)
+
+	var receivedBody []byte
+	var receivedHeader http.Header
+	status := http.StatusCreated
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedHeader = r.Header
+		receivedBody, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	originalClient := httpClient
+	httpClient = server.Client()
+	defer func() { httpClient = originalClient }()
+
+	subscription = b.subscription(server.URL + "/abc")
+	subscription.UserId = "Peter"
+
+	err = Send(util.NewLogger(), subscription.String(), "test")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if receivedHeader.Get("Content-Encoding") != "aes128gcm" || receivedHeader.Get("TTL") == "" || !strings.HasPrefix(receivedHeader.Get("Authorization"), "vapid ") {
+		t.Errorf("Unexpected headers: %v", receivedHeader)
+	}
+	decrypted, err := b.decrypt(receivedBody)
+	if err != nil || string(decrypted) != "test" {
+		t.Errorf("Unable to decrypt received message")
+	}
+
+	status = http.StatusGone
+	err = Send(util.NewLogger(), subscription.String(), "test")
+	if err != ErrSubscriptionGone {
+		t.Errorf("Expected gone subscription but got %v", err)
+	}
+
+	status = http.StatusInternalServerError
+	err = Send(util.NewLogger(), subscription.String(), "test")
+	if err == nil || err == ErrSubscriptionGone {
+		t.Errorf("Expected error but got %v", err)
+	}
+}
+
+func TestParseSubscription(t *testing.T) {
+	b := newBrowser(t)
+	subscription := b.subscription("https://push.example.com/abc")
+	subscription.UserId = "Peter"
+
+	parsed, err := ParseSubscription(subscription.String())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if parsed.Endpoint != subscription.Endpoint || parsed.UserId != "Peter" || parsed.Keys != subscription.Keys {
+		t.Errorf("Parsed subscription %v does not match %v", parsed, subscription)
+	}
+
+	_, err = ParseSubscription("foo")
+	if err == nil {
+		t.Errorf("Parsing invalid subscription should not be possible")
+	}
+}
+
+func TestEncryptedKeys(t *testing.T) {
+	err := secret.Configure(testEncryptionKey)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer secret.Configure("")
+
+	subscription := &Subscription{
+		Endpoint: "https://push.example.com/abc",
+		Keys:     SubscriptionKeys{P256dh: "public-key", Auth: "auth-secret"},
+		UserId:   "Peter",
+	}
+
+	encrypted, err := subscription.WithEncryptedKeys()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if subscription.Keys.P256dh != "public-key" || subscription.Keys.Auth != "auth-secret" {
+		t.Errorf("Keys of original subscription should not change: %v", subscription.Keys)
+	}
+	if !secret.IsCurrent(encrypted.Keys.P256dh) || !secret.IsCurrent(encrypted.Keys.Auth) {
+		t.Errorf("Keys should be encrypted: %v", encrypted.Keys)
+	}
+	if strings.Contains(encrypted.String(), "public-key") || strings.Contains(encrypted.String(), "auth-secret") {
+		t.Errorf("Stored subscription '%s' should not contain plaintext keys", encrypted.String())
+	}
+
+	parsed, err := ParseSubscription(encrypted.String())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if parsed.Keys != subscription.Keys || parsed.Endpoint != subscription.Endpoint || parsed.UserId != subscription.UserId {
+		t.Errorf("Parsed subscription %v does not match original %v", parsed, subscription)
+	}
+
+	// Subscriptions stored before the keys were encrypted can still be read
+	parsed, err = ParseSubscription(subscription.String())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if parsed.Keys != subscription.Keys {
+		t.Errorf("Parsed keys %v do not match original keys %v", parsed.Keys, subscription.Keys)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	h.Run(t, func() error {
+		defer Configure("", "")
+		b := newBrowser(t)
+
+		_, err := s.Subscribe(b.subscription("https://push.example.com/abc"), "Peter")
+		if err == nil {
+			return errors.New("Subscribing should not be possible when push messages are disabled")
+		}
+
+		configureTestKey(t)
+
+		for _, endpoint := range []string{"", "http://push.example.com/abc", "https:///abc", "foo"} {
+			_, err = s.Subscribe(b.subscription(endpoint), "Peter")
+			if err == nil {
+				return errors.New(fmt.Sprintf("Subscribing with endpoint '%s' should not be possible", endpoint))
+			}
+		}
+
+		invalidKeys := b.subscription("https://push.example.com/abc")
+		invalidKeys.Keys.P256dh = "abc"
+		_, err = s.Subscribe(invalidKeys, "Peter")
+		if err == nil {
+			return errors.New("Subscribing with invalid key should not be possible")
+		}
+
+		_, err = s.Subscribe(b.subscription("https://push.example.com/abc"), "Peter")
+		if err != nil {
+			return err
+		}
+		// Subscribing again replaces the existing subscription, even for other users
+		subscription, err := s.Subscribe(newBrowser(t).subscription("https://push.example.com/abc"), "Maria")
+		if err != nil {
+			return err
+		}
+		if subscription.UserId != "Maria" {
+			return errors.New(fmt.Sprintf("Subscription should belong to Maria but belongs to %s", subscription.UserId))
+		}
+
+		subscriptions, err := s.GetSubscriptions("Peter")
+		if err != nil {
+			return err
+		}
+		if len(subscriptions) != 0 {
+			return errors.New(fmt.Sprintf("Peter should have no subscriptions but has %d", len(subscriptions)))
+		}
+
+		subscriptions, err = s.GetSubscriptions("Maria")
+		if err != nil {
+			return err
+		}
+		if len(subscriptions) != 1 || subscriptions[0].Keys != subscription.Keys {
+			return errors.New(fmt.Sprintf("Maria should have the new subscription: %v", subscriptions))
+		}
+
+		return nil
+	})
+}
+
+func TestUnsubscribe(t *testing.T) {
+	h.Run(t, func() error {
+		defer Configure("", "")
+		configureTestKey(t)
+
+		subscription, err := s.Subscribe(newBrowser(t).subscription("https://push.example.com/abc"), "Peter")
+		if err != nil {
+			return err
+		}
+		_, err = s.Subscribe(newBrowser(t).subscription("https://push.example.com/def"), "Peter")
+		if err != nil {
+			return err
+		}
+
+		// Other users can't remove the subscription
+		err = s.Unsubscribe(subscription.Endpoint, "Maria")
+		if err != nil {
+			return err
+		}
+		subscriptions, err := s.GetSubscriptions("Peter")
+		if err != nil {
+			return err
+		}
+		if len(subscriptions) != 2 {
+			return errors.New(fmt.Sprintf("Peter should have 2 subscriptions but has %d", len(subscriptions)))
+		}
+
+		err = s.Unsubscribe(subscription.Endpoint, "Peter")
+		if err != nil {
+			return err
+		}
+		err = s.RemoveGoneSubscription(subscriptions[1].String())
+		if err != nil {
+			return err
+		}
+
+		subscriptions, err = s.GetSubscriptions("Peter")
+		if err != nil {
+			return err
+		}
+		if len(subscriptions) != 0 {
+			return errors.New(fmt.Sprintf("Peter should have no subscriptions but has %d", len(subscriptions)))
+		}
+
+		// Unknown subscriptions can be removed as well
+		return s.Unsubscribe(subscription.Endpoint, "Peter")
+	})
+}
+
+func TestReencryptSubscriptions(t *testing.T) {
+	h.Run(t, func() error {
+		defer Configure("", "")
+		defer secret.Configure("")
+		configureTestKey(t)
+
+		subscription, err := s.Subscribe(newBrowser(t).subscription("https://push.example.com/abc"), "Peter")
+		if err != nil {
+			return err
+		}
+
+		// Subscription stored before the keys were encrypted
+		_, err = tx.Exec("INSERT INTO push_subscriptions(endpoint, user_id, p256dh, auth) VALUES('https://push.example.com/def', 'Peter', 'plain-key', 'plain-auth');")
+		if err != nil {
+			return err
+		}
+
+		count, err := s.ReencryptSubscriptions()
+		if err != nil {
+			return err
+		}
+		if count != 1 {
+			return errors.New(fmt.Sprintf("One subscription should have been encrypted but were %d", count))
+		}
+
+		// Rotate the key: the old one still decrypts, the new one encrypts
+		err = secret.Configure(base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210")) + "," + testEncryptionKey)
+		if err != nil {
+			return err
+		}
+
+		count, err = s.ReencryptSubscriptions()
+		if err != nil {
+			return err
+		}
+		if count != 2 {
+			return errors.New(fmt.Sprintf("Two subscriptions should have been encrypted again but were %d", count))
+		}
+
+		stored, err := s.store.getStoredSubscriptions()
+		if err != nil {
+			return err
+		}
+		for _, storedSubscription := range stored {
+			if !secret.IsCurrent(storedSubscription.Keys.P256dh) || !secret.IsCurrent(storedSubscription.Keys.Auth) {
+				return errors.New(fmt.Sprintf("Keys of subscription %s should be encrypted with the new key", storedSubscription.Endpoint))
+			}
+		}
+
+		subscriptions, err := s.GetSubscriptions("Peter")
+		if err != nil {
+			return err
+		}
+		if len(subscriptions) != 2 || subscriptions[0].Keys != subscription.Keys || subscriptions[1].Keys.Auth != "plain-auth" {
+			return errors.New(fmt.Sprintf("Subscriptions should be readable after rotation: %v", subscriptions))
+		}
+
+		return nil
+	})
+}
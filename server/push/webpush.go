@@ -0,0 +1,222 @@
+package push
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+const (
+	authSecretLength = 16
+	recordSize       = 4096
+	headerLength     = 16 + 4 + 1 + 65 // Salt, record size, key ID length and the public key as key ID (RFC 8188)
+
+	// Maximum size of the payload of one message: One record minus the header, padding delimiter and the GCM tag
+	MaxPayloadSize = recordSize - headerLength - 1 - 16
+
+	vapidValidity = 12 * time.Hour // Validity of the VAPID token, push services accept at most 24 hours
+	messageTtl    = 24 * time.Hour // Time the push service keeps messages for offline browsers
+	sendTimeout   = 10 * time.Second
+)
+
+var (
+	// ErrSubscriptionGone is returned when the push service doesn't know the subscription anymore, e.g. because the user
+	// revoked the permission. Such subscriptions should be removed.
+	ErrSubscriptionGone = errors.New("push subscription is gone")
+
+	httpClient = &http.Client{Timeout: sendTimeout}
+)
+
+// Send encrypts the payload for the given subscription (as returned by "Subscription.String()") and sends it to its
+// push service. The request is signed with the VAPID key (RFC 8292) and the payload is encrypted as described in
+// RFC 8291, so that only the browser can read it.
+func Send(logger *util.Logger, subscriptionJson string, payload string) error {
+	if !Enabled() {
+		return errors.New("sending push messages is not possible, no VAPID key configured")
+	}
+
+	subscription, err := ParseSubscription(subscriptionJson)
+	if err != nil {
+		return err
+	}
+
+	body, err := encrypt(subscription, []byte(payload))
+	if err != nil {
+		return err
+	}
+
+	authorization, err := vapidAuthorization(subscription.Endpoint, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "unable to create push request")
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(messageTtl.Seconds())))
+
+	logger.Debug("Send push message to user %s", subscription.UserId)
+	response, err := httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error sending push message")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusGone {
+		return ErrSubscriptionGone
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		responseBody, _ := ioutil.ReadAll(response.Body)
+		return errors.New(fmt.Sprintf("push service responded with status %d: %s", response.StatusCode, string(responseBody)))
+	}
+
+	return nil
+}
+
+// encrypt returns the payload encrypted for the browser of the subscription in the "aes128gcm" content encoding. A new
+// key pair and salt are used for every message.
+func encrypt(subscription *Subscription, payload []byte) ([]byte, error) {
+	if len(payload) > MaxPayloadSize {
+		return nil, errors.New(fmt.Sprintf("push payload has %d bytes but at most %d are possible", len(payload), MaxPayloadSize))
+	}
+
+	curve := elliptic.P256()
+
+	userAgentKey, err := parsePublicKey(subscription.Keys.P256dh)
+	if err != nil {
+		return nil, err
+	}
+	userAgentPublicKey := elliptic.Marshal(curve, userAgentKey.X, userAgentKey.Y)
+
+	authSecret, err := decodeBase64(subscription.Keys.Auth)
+	if err != nil || len(authSecret) != authSecretLength {
+		return nil, errors.New(fmt.Sprintf("push auth secret must be %d bytes encoded as base64url", authSecretLength))
+	}
+
+	serverPrivateKey, serverX, serverY, err := elliptic.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate key for push message")
+	}
+	serverPublicKey := elliptic.Marshal(curve, serverX, serverY)
+
+	salt := make([]byte, 16)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create salt for push message")
+	}
+
+	sharedX, _ := curve.ScalarMult(userAgentKey.X, userAgentKey.Y, serverPrivateKey)
+	contentKey, nonce := deriveContentKey(padTo32(sharedX.Bytes()), authSecret, salt, userAgentPublicKey, serverPublicKey)
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create cipher for push message")
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create cipher for push message")
+	}
+
+	header := make([]byte, 0, headerLength)
+	header = append(header, salt...)
+	header = append(header, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(header[16:], recordSize)
+	header = append(header, byte(len(serverPublicKey)))
+	header = append(header, serverPublicKey...)
+
+	// The payload is one single record, which ends with the delimiter 0x02 and has no further padding
+	plaintext := append(append([]byte{}, payload...), 2)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// deriveContentKey returns the key and nonce to encrypt the record with (RFC 8291 section 3.4).
+func deriveContentKey(sharedSecret []byte, authSecret []byte, salt []byte, userAgentPublicKey []byte, serverPublicKey []byte) ([]byte, []byte) {
+	keyInfo := append([]byte("WebPush: info\x00"), userAgentPublicKey...)
+	keyInfo = append(keyInfo, serverPublicKey...)
+	ikm := hkdf(authSecret, sharedSecret, keyInfo, 32)
+
+	contentKey := hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+
+	return contentKey, nonce
+}
+
+// hkdf implements HKDF with SHA-256 (RFC 5869) for outputs of at most 32 bytes, which is all Web Push needs.
+func hkdf(salt []byte, secret []byte, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	expand.Write(info)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)[:length]
+}
+
+// vapidAuthorization returns the value of the "Authorization" header identifying this server to the push service
+// (RFC 8292). The token is only valid for the origin of the endpoint.
+func vapidAuthorization(endpoint string, now time.Time) (string, error) {
+	endpointUrl, err := url.Parse(endpoint)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to parse push endpoint")
+	}
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": endpointUrl.Scheme + "://" + endpointUrl.Host,
+		"exp": now.Add(vapidValidity).Unix(),
+		"sub": vapidSubject,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "unable to create VAPID claims")
+	}
+
+	unsignedToken := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	hash := sha256.Sum256([]byte(unsignedToken))
+	r, s, err := ecdsa.Sign(rand.Reader, vapidKey, hash[:])
+	if err != nil {
+		return "", errors.Wrap(err, "unable to sign VAPID token")
+	}
+	signature := append(padTo32(r.Bytes()), padTo32(s.Bytes())...)
+
+	token := unsignedToken + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, PublicKey()), nil
+}
+
+// parsePublicKey reads an uncompressed P-256 public key encoded as base64url, like the "p256dh" key of subscriptions.
+func parsePublicKey(key string) (*ecdsa.PublicKey, error) {
+	keyBytes, err := decodeBase64(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "push key is no base64url")
+	}
+
+	curve := elliptic.P256()
+	x, y := elliptic.Unmarshal(curve, keyBytes)
+	if x == nil {
+		return nil, errors.New("push key is no uncompressed P-256 public key")
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
@@ -0,0 +1,124 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/storage"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Quota limits how much a user can create on this instance, see "storage.Quota".
+type Quota = storage.Quota
+
+// UserQuota is the quota of a user together with what the user currently uses of it.
+type UserQuota struct {
+	Quota
+	UserId        string `json:"userId"`
+	Custom        bool   `json:"custom"` // True when an admin set the quota for this user, false for the default quota
+	OwnedProjects int    `json:"ownedProjects"`
+	TotalTasks    int    `json:"totalTasks"`
+}
+
+type QuotaService struct {
+	*util.Logger
+	store storage.UserStore
+}
+
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger) *QuotaService {
+	return &QuotaService{
+		Logger: logger,
+		store:  storage.NewStore(storage.ComponentUser, ctx, tx, logger).(storage.UserStore),
+	}
+}
+
+// GetQuota returns the quota of the user and the current usage. Users without custom quota get the default quota from
+// the config.
+func (s *QuotaService) GetQuota(userId string) (*UserQuota, error) {
+	quota, err := s.store.GetQuota(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UserQuota{
+		UserId: userId,
+		Custom: quota != nil,
+	}
+	if quota != nil {
+		result.Quota = *quota
+	} else {
+		result.Quota = DefaultQuota()
+	}
+
+	result.OwnedProjects, result.TotalTasks, err = s.store.GetUsage(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// SetQuota stores the quota for the given user, so that the default quota doesn't apply anymore. Existing projects and
+// tasks are kept even when they exceed the new quota. The caller has to make sure the requesting user is an admin.
+func (s *QuotaService) SetQuota(userId string, quota *Quota, adminId string) (*UserQuota, error) {
+	if quota.MaxOwnedProjects < 0 || quota.MaxTasksPerProject < 0 || quota.MaxTotalTasks < 0 {
+		return nil, errors.New("quota limits must not be negative")
+	}
+
+	err := s.store.SetQuota(userId, quota, adminId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Log("Quota of user %s set to %d owned projects, %d tasks per project and %d tasks in total by %s", userId, quota.MaxOwnedProjects, quota.MaxTasksPerProject, quota.MaxTotalTasks, adminId)
+
+	return s.GetQuota(userId)
+}
+
+// RemoveQuota removes the quota set for the given user, so that the default quota applies again. The caller has to make
+// sure the requesting user is an admin.
+func (s *QuotaService) RemoveQuota(userId string) (*UserQuota, error) {
+	err := s.store.RemoveQuota(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Log("Quota of user %s reset to default", userId)
+
+	return s.GetQuota(userId)
+}
+
+// VerifyNewProject returns an error when the user isn't allowed to create another project with the given number of
+// tasks.
+func (s *QuotaService) VerifyNewProject(userId string, taskCount int) error {
+	quota, err := s.GetQuota(userId)
+	if err != nil {
+		return err
+	}
+
+	if quota.MaxOwnedProjects > 0 && quota.OwnedProjects >= quota.MaxOwnedProjects {
+		return errors.New(fmt.Sprintf("quota exceeded: user %s already owns %d projects, at most %d are allowed", userId, quota.OwnedProjects, quota.MaxOwnedProjects))
+	}
+
+	if quota.MaxTasksPerProject > 0 && taskCount > quota.MaxTasksPerProject {
+		return errors.New(fmt.Sprintf("quota exceeded: project has %d tasks, at most %d are allowed", taskCount, quota.MaxTasksPerProject))
+	}
+
+	if quota.MaxTotalTasks > 0 && quota.TotalTasks+taskCount > quota.MaxTotalTasks {
+		return errors.New(fmt.Sprintf("quota exceeded: projects of user %s would have %d tasks in total, at most %d are allowed", userId, quota.TotalTasks+taskCount, quota.MaxTotalTasks))
+	}
+
+	return nil
+}
+
+// DefaultQuota returns the configured quota of all users without a quota set by an admin.
+func DefaultQuota() Quota {
+	return Quota{
+		MaxOwnedProjects:   config.Conf.QuotaOwnedProjects,
+		MaxTasksPerProject: config.Conf.QuotaProjectTasks,
+		MaxTotalTasks:      config.Conf.QuotaTotalTasks,
+	}
+}
@@ -0,0 +1,113 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/storage"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+type storePg struct {
+	*util.Logger
+	ctx          context.Context
+	tx           *sql.Tx
+	table        database.Table
+	projectTable database.Table
+	taskTable    database.Table
+}
+
+func init() {
+	storage.Register(storage.DriverPostgres, storage.ComponentUser, func(ctx context.Context, tx storage.Transaction, logger *util.Logger) interface{} {
+		return newStorePg(ctx, tx.(*sql.Tx), logger)
+	})
+}
+
+func newStorePg(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
+	return &storePg{
+		Logger:       logger,
+		ctx:          ctx,
+		tx:           tx,
+		table:        database.TableUserQuotas,
+		projectTable: database.TableProjects,
+		taskTable:    database.TableTasks,
+	}
+}
+
+// GetQuota returns the quota set for the user or "nil" when there's none.
+func (s *storePg) GetQuota(userId string) (*Quota, error) {
+	query := fmt.Sprintf("SELECT max_owned_projects, max_tasks_per_project, max_total_tasks FROM %s WHERE user_id=$1;", s.table)
+	s.LogQuery(query, userId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting quota of user %s", userId)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	quota := &Quota{}
+	err = rows.Scan(&quota.MaxOwnedProjects, &quota.MaxTasksPerProject, &quota.MaxTotalTasks)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not scan quota")
+	}
+
+	return quota, nil
+}
+
+func (s *storePg) SetQuota(userId string, quota *Quota, adminId string) error {
+	query := fmt.Sprintf(`INSERT INTO %s(user_id, max_owned_projects, max_tasks_per_project, max_total_tasks, updated_by) VALUES($1, $2, $3, $4, $5)
+ON CONFLICT (user_id) DO UPDATE SET max_owned_projects=EXCLUDED.max_owned_projects, max_tasks_per_project=EXCLUDED.max_tasks_per_project, max_total_tasks=EXCLUDED.max_total_tasks, updated_by=EXCLUDED.updated_by, updated_at=NOW();`, s.table)
+	return s.execQuery(query, userId, quota.MaxOwnedProjects, quota.MaxTasksPerProject, quota.MaxTotalTasks, adminId)
+}
+
+func (s *storePg) RemoveQuota(userId string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_id=$1;", s.table)
+	return s.execQuery(query, userId)
+}
+
+// GetUsage returns the number of projects owned by the user and the number of tasks in these projects. Tutorial projects
+// are not counted.
+func (s *storePg) GetUsage(userId string) (int, int, error) {
+	query := fmt.Sprintf("SELECT (SELECT COUNT(*) FROM %s WHERE owner=$1 AND NOT tutorial), (SELECT COUNT(*) FROM %s WHERE project_id IN (SELECT id FROM %s WHERE owner=$1 AND NOT tutorial));", s.projectTable, s.taskTable, s.projectTable)
+	s.LogQuery(query, userId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, userId)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "error getting usage of user %s", userId)
+	}
+	defer rows.Close()
+
+	rows.Next()
+
+	var ownedProjects, totalTasks int
+	err = rows.Scan(&ownedProjects, &totalTasks)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "could not scan usage")
+	}
+
+	return ownedProjects, totalTasks, nil
+}
+
+func (s *storePg) execQuery(query string, params ...interface{}) error {
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return errors.Wrap(err, "error executing quota query")
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/test"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+	"strings"
+	"testing"
+
+	_ "github.com/lib/pq" // Make driver "postgres" usable
+)
+
+var (
+	tx *sql.Tx
+	s  *QuotaService
+	h  *test.TestHelper
+)
+
+func TestMain(m *testing.M) {
+	h = &test.TestHelper{
+		Setup: setup,
+	}
+
+	m.Run()
+}
+
+func setup() {
+	config.LoadConfig("../config/test.json")
+	test.InitWithDummyData()
+	sigolo.LogLevel = sigolo.LOG_DEBUG
+
+	logger := util.NewLogger()
+
+	ctx := context.Background()
+
+	var err error
+	tx, err = database.GetTransaction(ctx, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	h.Tx = tx
+	s = Init(ctx, tx, logger)
+}
+
+func TestGetQuota(t *testing.T) {
+	h.Run(t, func() error {
+		config.Conf.QuotaOwnedProjects = 3
+		defer func() { config.Conf.QuotaOwnedProjects = 0 }()
+
+		quota, err := s.GetQuota("Maria")
+		if err != nil {
+			return err
+		}
+
+		// Maria owns project 2 with its five tasks
+		if quota.Custom || quota.MaxOwnedProjects != 3 || quota.OwnedProjects != 1 || quota.TotalTasks != 5 {
+			return errors.New(fmt.Sprintf("Quota not matching: %#v", quota))
+		}
+
+		return nil
+	})
+}
+
+func TestSetAndRemoveQuota(t *testing.T) {
+	h.Run(t, func() error {
+		quota, err := s.SetQuota("Maria", &Quota{MaxOwnedProjects: 2, MaxTasksPerProject: 10, MaxTotalTasks: 12}, "Peter")
+		if err != nil {
+			return err
+		}
+		if !quota.Custom || quota.MaxOwnedProjects != 2 || quota.MaxTasksPerProject != 10 || quota.MaxTotalTasks != 12 {
+			return errors.New(fmt.Sprintf("Quota not matching: %#v", quota))
+		}
+
+		quota, err = s.RemoveQuota("Maria")
+		if err != nil {
+			return err
+		}
+		if quota.Custom || quota.MaxOwnedProjects != 0 || quota.MaxTotalTasks != 0 {
+			return errors.New(fmt.Sprintf("Quota should be the default again: %#v", quota))
+		}
+
+		_, err = s.SetQuota("Maria", &Quota{MaxOwnedProjects: -1}, "Peter")
+		if err == nil {
+			return errors.New("Negative quota should not be possible")
+		}
+
+		return nil
+	})
+}
+
+func TestVerifyNewProject(t *testing.T) {
+	h.Run(t, func() error {
+		err := s.VerifyNewProject("Maria", 1000)
+		if err != nil {
+			return errors.Wrap(err, "Without quota everything should be allowed")
+		}
+
+		_, err = s.SetQuota("Maria", &Quota{MaxOwnedProjects: 2, MaxTasksPerProject: 10, MaxTotalTasks: 12}, "Peter")
+		if err != nil {
+			return err
+		}
+
+		err = s.VerifyNewProject("Maria", 7)
+		if err != nil {
+			return errors.Wrap(err, "Project within quota should be allowed")
+		}
+
+		err = s.VerifyNewProject("Maria", 11)
+		if err == nil || !strings.HasPrefix(err.Error(), "quota exceeded") {
+			return errors.New(fmt.Sprintf("Too many tasks per project should exceed quota: %v", err))
+		}
+
+		// Maria already has 5 tasks
+		err = s.VerifyNewProject("Maria", 8)
+		if err == nil || !strings.HasPrefix(err.Error(), "quota exceeded") {
+			return errors.New(fmt.Sprintf("Too many tasks in total should exceed quota: %v", err))
+		}
+
+		_, err = s.SetQuota("Maria", &Quota{MaxOwnedProjects: 1}, "Peter")
+		if err != nil {
+			return err
+		}
+
+		err = s.VerifyNewProject("Maria", 1)
+		if err == nil || !strings.HasPrefix(err.Error(), "quota exceeded") {
+			return errors.New(fmt.Sprintf("Too many owned projects should exceed quota: %v", err))
+		}
+
+		return nil
+	})
+}
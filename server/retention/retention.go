@@ -0,0 +1,143 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Names of the tables with a retention policy
+const (
+	TableTaskHistory     = string(database.TableTaskHistory)
+	TableApiUsage        = string(database.TableApiUsage)
+	TableNotifications   = string(database.TableNotifications)
+	TableOutbox          = string(database.TableOutbox)
+	TableProjectCommands = string(database.TableProjectCommands)
+	TableSessions        = string(database.TableSessions)
+)
+
+// Stats contains the configured retention of a table and how many rows have been removed from it.
+type Stats struct {
+	Table         string     `json:"table"`
+	RetentionDays int        `json:"retentionDays"` // 0 means the rows are kept forever
+	LastRunAt     *time.Time `json:"lastRunAt"`     // Not set when the policy has never been applied
+	LastPurged    int        `json:"lastPurged"`    // Rows removed by the last run
+	TotalPurged   int64      `json:"totalPurged"`   // Rows removed by all runs
+}
+
+type RetentionService struct {
+	*util.Logger
+	store *storePg
+}
+
+var (
+	// Tables with a retention policy. Old rows of the task history are aggregated (one row per task and user) because
+	// the statistics are based on them, rows of all other tables are removed.
+	tables = []string{TableTaskHistory, TableApiUsage, TableNotifications, TableOutbox, TableProjectCommands, TableSessions}
+
+	// Table name -> days after which rows are aggregated or removed. Tables not in here are never changed.
+	retentionDays = make(map[string]int)
+)
+
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger) *RetentionService {
+	return &RetentionService{
+		Logger: logger,
+		store:  getStore(ctx, tx, logger),
+	}
+}
+
+// ApplyPoliciesJob is meant to be executed by the scheduler. It applies the retention policies of all tables.
+func ApplyPoliciesJob(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	return Init(ctx, tx, logger).ApplyPolicies()
+}
+
+// Configure sets the retention (in days) of the tables from the config. Tables not mentioned there or with 0 days keep
+// their rows forever.
+func Configure(days map[string]int) error {
+	result := make(map[string]int)
+
+	for table, d := range days {
+		if !isKnown(table) {
+			return errors.New(fmt.Sprintf("no retention policy for table '%s'", table))
+		}
+		if d < 0 {
+			return errors.New(fmt.Sprintf("retention of table '%s' must not be negative but was %d", table, d))
+		}
+		if d > 0 {
+			result[table] = d
+		}
+	}
+
+	retentionDays = result
+
+	return nil
+}
+
+// ApplyPolicies aggregates or removes the rows older than the configured retention of each table and updates the
+// statistics of the removed rows.
+func (s *RetentionService) ApplyPolicies() error {
+	for _, table := range tables {
+		days, ok := retentionDays[table]
+		if !ok {
+			continue
+		}
+
+		cutoff := time.Now().UTC().AddDate(0, 0, -days)
+
+		purged, err := s.store.purge(table, cutoff)
+		if err != nil {
+			return err
+		}
+
+		err = s.store.addStats(table, purged)
+		if err != nil {
+			return err
+		}
+
+		if purged != 0 {
+			s.Log("Purged %d rows older than %d days from table %s", purged, days, table)
+		}
+	}
+
+	return nil
+}
+
+// GetStats returns the retention and statistics of all tables with a retention policy sorted by table name.
+func (s *RetentionService) GetStats() ([]*Stats, error) {
+	storedStats, err := s.store.getStats()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Stats, 0)
+	for _, table := range tables {
+		stats, ok := storedStats[table]
+		if !ok {
+			stats = &Stats{Table: table}
+		}
+		stats.RetentionDays = retentionDays[table]
+
+		result = append(result, stats)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Table < result[j].Table
+	})
+
+	return result, nil
+}
+
+func isKnown(table string) bool {
+	for _, t := range tables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+type storePg struct {
+	*util.Logger
+	ctx   context.Context
+	tx    *sql.Tx
+	table database.Table
+}
+
+var (
+	// Queries removing the rows older than $1 and returning the number of removed rows. Messages in the outbox are
+	// retried for a few hours at most, so old ones have either been sent or given up. Sessions are removed once they
+	// expired before $1, so active sessions are never removed.
+	purgeQueries = map[string]string{
+		TableTaskHistory: fmt.Sprintf(`WITH removed AS (DELETE FROM %[1]s WHERE created_at < $1 RETURNING *),
+aggregated AS (
+	INSERT INTO %[1]s(task_id, user_id, type, process_points, points_delta, created_at)
+	SELECT task_id, user_id, (ARRAY_AGG(type ORDER BY created_at DESC, id DESC))[1], (ARRAY_AGG(process_points ORDER BY created_at DESC, id DESC))[1], SUM(points_delta)::INT, MAX(created_at)
+	FROM removed GROUP BY task_id, user_id
+	RETURNING 1
+)
+SELECT (SELECT COUNT(*) FROM removed) - (SELECT COUNT(*) FROM aggregated);`, database.TableTaskHistory),
+		TableApiUsage:        fmt.Sprintf(`WITH removed AS (DELETE FROM %s WHERE date < $1::DATE RETURNING 1) SELECT COUNT(*) FROM removed;`, database.TableApiUsage),
+		TableNotifications:   fmt.Sprintf(`WITH removed AS (DELETE FROM %s WHERE created_at < $1 RETURNING 1) SELECT COUNT(*) FROM removed;`, database.TableNotifications),
+		TableOutbox:          fmt.Sprintf(`WITH removed AS (DELETE FROM %s WHERE created_at < $1 RETURNING 1) SELECT COUNT(*) FROM removed;`, database.TableOutbox),
+		TableProjectCommands: fmt.Sprintf(`WITH removed AS (DELETE FROM %s WHERE created_at < $1 RETURNING 1) SELECT COUNT(*) FROM removed;`, database.TableProjectCommands),
+		TableSessions:        fmt.Sprintf(`WITH removed AS (DELETE FROM %s WHERE valid_until < $1 RETURNING 1) SELECT COUNT(*) FROM removed;`, database.TableSessions),
+	}
+)
+
+func getStore(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
+	return &storePg{
+		Logger: logger,
+		ctx:    ctx,
+		tx:     tx,
+		table:  database.TableRetentionStats,
+	}
+}
+
+// purge aggregates or removes the rows of the table older than the cutoff and returns the number of removed rows.
+func (s *storePg) purge(table string, cutoff time.Time) (int, error) {
+	query, ok := purgeQueries[table]
+	if !ok {
+		return 0, errors.New(fmt.Sprintf("no retention policy for table '%s'", table))
+	}
+	s.LogQuery(query, cutoff)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+
+	var purged int
+	err := s.tx.QueryRowContext(ctx, query, cutoff).Scan(&purged)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error purging rows of table %s", table)
+	}
+
+	return purged, nil
+}
+
+func (s *storePg) addStats(table string, purged int) error {
+	query := fmt.Sprintf(`INSERT INTO %s(table_name, last_run_at, last_purged, total_purged) VALUES($1, NOW(), $2, $2)
+ON CONFLICT (table_name) DO UPDATE SET last_run_at=EXCLUDED.last_run_at, last_purged=EXCLUDED.last_purged, total_purged=%s.total_purged+EXCLUDED.total_purged;`, s.table, s.table)
+	s.LogQuery(query, table, purged)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, table, purged)
+	if err != nil {
+		return errors.Wrapf(err, "error updating retention statistics of table %s", table)
+	}
+
+	return nil
+}
+
+// getStats returns the statistics of all tables whose policy has been applied at least once (table name -> stats).
+func (s *storePg) getStats() (map[string]*Stats, error) {
+	query := fmt.Sprintf("SELECT table_name, last_run_at, last_purged, total_purged FROM %s;", s.table)
+	s.LogQuery(query)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting retention statistics")
+	}
+	defer rows.Close()
+
+	result := make(map[string]*Stats)
+	for rows.Next() {
+		var lastRunAt time.Time
+		stats := &Stats{}
+
+		err = rows.Scan(&stats.Table, &lastRunAt, &stats.LastPurged, &stats.TotalPurged)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan retention statistics")
+		}
+
+		stats.LastRunAt = &lastRunAt
+		result[stats.Table] = stats
+	}
+
+	return result, nil
+}
@@ -0,0 +1,161 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/test"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+	"testing"
+
+	_ "github.com/lib/pq" // Make driver "postgres" usable
+)
+
+var (
+	tx *sql.Tx
+	s  *RetentionService
+	h  *test.TestHelper
+)
+
+func TestMain(m *testing.M) {
+	h = &test.TestHelper{
+		Setup: setup,
+	}
+
+	m.Run()
+}
+
+func setup() {
+	config.LoadConfig("../config/test.json")
+	test.InitWithDummyData()
+	sigolo.LogLevel = sigolo.LOG_DEBUG
+
+	logger := util.NewLogger()
+
+	ctx := context.Background()
+
+	var err error
+	tx, err = database.GetTransaction(ctx, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	h.Tx = tx
+	s = Init(ctx, tx, logger)
+}
+
+func TestConfigure(t *testing.T) {
+	defer Configure(nil)
+
+	err := Configure(map[string]int{TableTaskHistory: 30, TableOutbox: 0})
+	if err != nil {
+		t.Errorf("Configuring known tables should work: %s", err)
+		return
+	}
+
+	if len(retentionDays) != 1 || retentionDays[TableTaskHistory] != 30 {
+		t.Errorf("Retention not matching: %v", retentionDays)
+	}
+
+	err = Configure(map[string]int{"tasks": 30})
+	if err == nil {
+		t.Errorf("Configuring table without policy should not work")
+	}
+
+	err = Configure(map[string]int{TableNotifications: -1})
+	if err == nil {
+		t.Errorf("Configuring negative retention should not work")
+	}
+
+	// Failed configuration must not change anything
+	if retentionDays[TableTaskHistory] != 30 {
+		t.Errorf("Retention should not have changed: %v", retentionDays)
+	}
+}
+
+func TestApplyPolicies(t *testing.T) {
+	h.Run(t, func() error {
+		defer Configure(nil)
+
+		err := Configure(map[string]int{TableTaskHistory: 30, TableNotifications: 30})
+		if err != nil {
+			return err
+		}
+
+		err = s.ApplyPolicies()
+		if err != nil {
+			return err
+		}
+
+		// The seven history entries of the dummy data are aggregated into one entry per task and user
+		stats, err := getTableStats(TableTaskHistory)
+		if err != nil {
+			return err
+		}
+		if stats.RetentionDays != 30 || stats.LastRunAt == nil || stats.LastPurged != 4 || stats.TotalPurged != 4 {
+			return errors.New(fmt.Sprintf("Stats of task history not matching: %#v", stats))
+		}
+
+		var count, points int
+		err = tx.QueryRow("SELECT COUNT(*), SUM(points_delta) FROM task_history;").Scan(&count, &points)
+		if err != nil {
+			return err
+		}
+		if count != 3 || points != 153 {
+			return errors.New(fmt.Sprintf("Aggregated history not matching: %d entries with %d points", count, points))
+		}
+
+		var entryType string
+		var processPoints int
+		err = tx.QueryRow("SELECT type, process_points FROM task_history WHERE task_id=2;").Scan(&entryType, &processPoints)
+		if err != nil {
+			return err
+		}
+		if entryType != "unassigned" || processPoints != 100 {
+			return errors.New(fmt.Sprintf("Aggregated entry should have state of latest entry: %s %d", entryType, processPoints))
+		}
+
+		// Aggregated entries are not counted again
+		err = s.ApplyPolicies()
+		if err != nil {
+			return err
+		}
+
+		stats, err = getTableStats(TableTaskHistory)
+		if err != nil {
+			return err
+		}
+		if stats.LastPurged != 0 || stats.TotalPurged != 4 {
+			return errors.New(fmt.Sprintf("Stats of second run not matching: %#v", stats))
+		}
+
+		stats, err = getTableStats(TableOutbox)
+		if err != nil {
+			return err
+		}
+		if stats.RetentionDays != 0 || stats.LastRunAt != nil {
+			return errors.New(fmt.Sprintf("Policy of outbox should not have been applied: %#v", stats))
+		}
+
+		return nil
+	})
+}
+
+func getTableStats(table string) (*Stats, error) {
+	allStats, err := s.GetStats()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stats := range allStats {
+		if stats.Table == table {
+			return stats, nil
+		}
+	}
+
+	return nil, errors.New(fmt.Sprintf("No stats for table %s", table))
+}
@@ -0,0 +1,373 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: stm.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	SimpleTaskManager_GetProjects_FullMethodName      = "/stm.v1.SimpleTaskManager/GetProjects"
+	SimpleTaskManager_GetProject_FullMethodName       = "/stm.v1.SimpleTaskManager/GetProject"
+	SimpleTaskManager_GetTasks_FullMethodName         = "/stm.v1.SimpleTaskManager/GetTasks"
+	SimpleTaskManager_AssignTask_FullMethodName       = "/stm.v1.SimpleTaskManager/AssignTask"
+	SimpleTaskManager_UnassignTask_FullMethodName     = "/stm.v1.SimpleTaskManager/UnassignTask"
+	SimpleTaskManager_SetProcessPoints_FullMethodName = "/stm.v1.SimpleTaskManager/SetProcessPoints"
+	SimpleTaskManager_StreamUpdates_FullMethodName    = "/stm.v1.SimpleTaskManager/StreamUpdates"
+)
+
+// SimpleTaskManagerClient is the client API for SimpleTaskManager service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SimpleTaskManagerClient interface {
+	// Gets all projects of the user.
+	GetProjects(ctx context.Context, in *GetProjectsRequest, opts ...grpc.CallOption) (*ProjectList, error)
+	// Gets one project, the user must be member of it.
+	GetProject(ctx context.Context, in *GetProjectRequest, opts ...grpc.CallOption) (*Project, error)
+	// Gets all tasks of a project, the user must be member of it.
+	GetTasks(ctx context.Context, in *GetTasksRequest, opts ...grpc.CallOption) (*TaskList, error)
+	// Assigns the user to the task.
+	AssignTask(ctx context.Context, in *TaskRequest, opts ...grpc.CallOption) (*Task, error)
+	// Unassigns the user from the task.
+	UnassignTask(ctx context.Context, in *TaskRequest, opts ...grpc.CallOption) (*Task, error)
+	// Sets the process points of the task.
+	SetProcessPoints(ctx context.Context, in *SetProcessPointsRequest, opts ...grpc.CallOption) (*Task, error)
+	// Streams the same events as the websocket connection of the REST API until the client cancels the call.
+	StreamUpdates(ctx context.Context, in *StreamUpdatesRequest, opts ...grpc.CallOption) (SimpleTaskManager_StreamUpdatesClient, error)
+}
+
+type simpleTaskManagerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSimpleTaskManagerClient(cc grpc.ClientConnInterface) SimpleTaskManagerClient {
+	return &simpleTaskManagerClient{cc}
+}
+
+func (c *simpleTaskManagerClient) GetProjects(ctx context.Context, in *GetProjectsRequest, opts ...grpc.CallOption) (*ProjectList, error) {
+	out := new(ProjectList)
+	err := c.cc.Invoke(ctx, SimpleTaskManager_GetProjects_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simpleTaskManagerClient) GetProject(ctx context.Context, in *GetProjectRequest, opts ...grpc.CallOption) (*Project, error) {
+	out := new(Project)
+	err := c.cc.Invoke(ctx, SimpleTaskManager_GetProject_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simpleTaskManagerClient) GetTasks(ctx context.Context, in *GetTasksRequest, opts ...grpc.CallOption) (*TaskList, error) {
+	out := new(TaskList)
+	err := c.cc.Invoke(ctx, SimpleTaskManager_GetTasks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simpleTaskManagerClient) AssignTask(ctx context.Context, in *TaskRequest, opts ...grpc.CallOption) (*Task, error) {
+	out := new(Task)
+	err := c.cc.Invoke(ctx, SimpleTaskManager_AssignTask_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simpleTaskManagerClient) UnassignTask(ctx context.Context, in *TaskRequest, opts ...grpc.CallOption) (*Task, error) {
+	out := new(Task)
+	err := c.cc.Invoke(ctx, SimpleTaskManager_UnassignTask_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simpleTaskManagerClient) SetProcessPoints(ctx context.Context, in *SetProcessPointsRequest, opts ...grpc.CallOption) (*Task, error) {
+	out := new(Task)
+	err := c.cc.Invoke(ctx, SimpleTaskManager_SetProcessPoints_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simpleTaskManagerClient) StreamUpdates(ctx context.Context, in *StreamUpdatesRequest, opts ...grpc.CallOption) (SimpleTaskManager_StreamUpdatesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SimpleTaskManager_ServiceDesc.Streams[0], SimpleTaskManager_StreamUpdates_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &simpleTaskManagerStreamUpdatesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SimpleTaskManager_StreamUpdatesClient interface {
+	Recv() (*Update, error)
+	grpc.ClientStream
+}
+
+type simpleTaskManagerStreamUpdatesClient struct {
+	grpc.ClientStream
+}
+
+func (x *simpleTaskManagerStreamUpdatesClient) Recv() (*Update, error) {
+	m := new(Update)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SimpleTaskManagerServer is the server API for SimpleTaskManager service.
+// All implementations must embed UnimplementedSimpleTaskManagerServer
+// for forward compatibility
+type SimpleTaskManagerServer interface {
+	// Gets all projects of the user.
+	GetProjects(context.Context, *GetProjectsRequest) (*ProjectList, error)
+	// Gets one project, the user must be member of it.
+	GetProject(context.Context, *GetProjectRequest) (*Project, error)
+	// Gets all tasks of a project, the user must be member of it.
+	GetTasks(context.Context, *GetTasksRequest) (*TaskList, error)
+	// Assigns the user to the task.
+	AssignTask(context.Context, *TaskRequest) (*Task, error)
+	// Unassigns the user from the task.
+	UnassignTask(context.Context, *TaskRequest) (*Task, error)
+	// Sets the process points of the task.
+	SetProcessPoints(context.Context, *SetProcessPointsRequest) (*Task, error)
+	// Streams the same events as the websocket connection of the REST API until the client cancels the call.
+	StreamUpdates(*StreamUpdatesRequest, SimpleTaskManager_StreamUpdatesServer) error
+	mustEmbedUnimplementedSimpleTaskManagerServer()
+}
+
+// UnimplementedSimpleTaskManagerServer must be embedded to have forward compatible implementations.
+type UnimplementedSimpleTaskManagerServer struct {
+}
+
+func (UnimplementedSimpleTaskManagerServer) GetProjects(context.Context, *GetProjectsRequest) (*ProjectList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProjects not implemented")
+}
+func (UnimplementedSimpleTaskManagerServer) GetProject(context.Context, *GetProjectRequest) (*Project, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProject not implemented")
+}
+func (UnimplementedSimpleTaskManagerServer) GetTasks(context.Context, *GetTasksRequest) (*TaskList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTasks not implemented")
+}
+func (UnimplementedSimpleTaskManagerServer) AssignTask(context.Context, *TaskRequest) (*Task, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AssignTask not implemented")
+}
+func (UnimplementedSimpleTaskManagerServer) UnassignTask(context.Context, *TaskRequest) (*Task, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnassignTask not implemented")
+}
+func (UnimplementedSimpleTaskManagerServer) SetProcessPoints(context.Context, *SetProcessPointsRequest) (*Task, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetProcessPoints not implemented")
+}
+func (UnimplementedSimpleTaskManagerServer) StreamUpdates(*StreamUpdatesRequest, SimpleTaskManager_StreamUpdatesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamUpdates not implemented")
+}
+func (UnimplementedSimpleTaskManagerServer) mustEmbedUnimplementedSimpleTaskManagerServer() {}
+
+// UnsafeSimpleTaskManagerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SimpleTaskManagerServer will
+// result in compilation errors.
+type UnsafeSimpleTaskManagerServer interface {
+	mustEmbedUnimplementedSimpleTaskManagerServer()
+}
+
+func RegisterSimpleTaskManagerServer(s grpc.ServiceRegistrar, srv SimpleTaskManagerServer) {
+	s.RegisterService(&SimpleTaskManager_ServiceDesc, srv)
+}
+
+func _SimpleTaskManager_GetProjects_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProjectsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimpleTaskManagerServer).GetProjects(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SimpleTaskManager_GetProjects_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimpleTaskManagerServer).GetProjects(ctx, req.(*GetProjectsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SimpleTaskManager_GetProject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimpleTaskManagerServer).GetProject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SimpleTaskManager_GetProject_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimpleTaskManagerServer).GetProject(ctx, req.(*GetProjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SimpleTaskManager_GetTasks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTasksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimpleTaskManagerServer).GetTasks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SimpleTaskManager_GetTasks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimpleTaskManagerServer).GetTasks(ctx, req.(*GetTasksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SimpleTaskManager_AssignTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TaskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimpleTaskManagerServer).AssignTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SimpleTaskManager_AssignTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimpleTaskManagerServer).AssignTask(ctx, req.(*TaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SimpleTaskManager_UnassignTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TaskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimpleTaskManagerServer).UnassignTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SimpleTaskManager_UnassignTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimpleTaskManagerServer).UnassignTask(ctx, req.(*TaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SimpleTaskManager_SetProcessPoints_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetProcessPointsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimpleTaskManagerServer).SetProcessPoints(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SimpleTaskManager_SetProcessPoints_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimpleTaskManagerServer).SetProcessPoints(ctx, req.(*SetProcessPointsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SimpleTaskManager_StreamUpdates_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamUpdatesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SimpleTaskManagerServer).StreamUpdates(m, &simpleTaskManagerStreamUpdatesServer{stream})
+}
+
+type SimpleTaskManager_StreamUpdatesServer interface {
+	Send(*Update) error
+	grpc.ServerStream
+}
+
+type simpleTaskManagerStreamUpdatesServer struct {
+	grpc.ServerStream
+}
+
+func (x *simpleTaskManagerStreamUpdatesServer) Send(m *Update) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// SimpleTaskManager_ServiceDesc is the grpc.ServiceDesc for SimpleTaskManager service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SimpleTaskManager_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "stm.v1.SimpleTaskManager",
+	HandlerType: (*SimpleTaskManagerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetProjects",
+			Handler:    _SimpleTaskManager_GetProjects_Handler,
+		},
+		{
+			MethodName: "GetProject",
+			Handler:    _SimpleTaskManager_GetProject_Handler,
+		},
+		{
+			MethodName: "GetTasks",
+			Handler:    _SimpleTaskManager_GetTasks_Handler,
+		},
+		{
+			MethodName: "AssignTask",
+			Handler:    _SimpleTaskManager_AssignTask_Handler,
+		},
+		{
+			MethodName: "UnassignTask",
+			Handler:    _SimpleTaskManager_UnassignTask_Handler,
+		},
+		{
+			MethodName: "SetProcessPoints",
+			Handler:    _SimpleTaskManager_SetProcessPoints_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamUpdates",
+			Handler:       _SimpleTaskManager_StreamUpdates_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "stm.proto",
+}
@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Job is a piece of work which is executed periodically. Each execution gets its own transaction, which is committed
+// when "Run" returns without error and rolled back otherwise. Jobs doing slow work besides the database (like HTTP
+// requests) set "RunWithoutTransaction" instead and open short transactions themselves, so that no transaction stays
+// open during that work.
+type Job struct {
+	Name                  string
+	Interval              time.Duration
+	Run                   func(ctx context.Context, tx *sql.Tx, logger *util.Logger) error
+	RunWithoutTransaction func(ctx context.Context, logger *util.Logger) error
+}
+
+var (
+	jobs = make([]*Job, 0)
+)
+
+// Register adds the job to the list of jobs started by "Start". Jobs registered after "Start" has been called are not
+// executed.
+func Register(job *Job) {
+	jobs = append(jobs, job)
+}
+
+// Start runs every registered job in its own goroutine. This function does not block.
+func Start() {
+	for _, job := range jobs {
+		go loop(job)
+	}
+}
+
+// RunOnce executes the job right away and blocks until it's finished, e.g. to load data before the server starts.
+func RunOnce(job *Job) {
+	execute(job)
+}
+
+func loop(job *Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		execute(job)
+	}
+}
+
+// execute runs the job once within a new transaction and handles commit, rollback and panics.
+func execute(job *Job) {
+	logger := util.NewLogger()
+	logger.Log("Execute job '%s'", job.Name)
+
+	// Jobs don't belong to any request, so they only get cancelled by the query timeouts
+	ctx := context.Background()
+
+	if job.RunWithoutTransaction != nil {
+		executeWithoutTransaction(ctx, job, logger)
+		return
+	}
+
+	tx, err := database.GetTransaction(ctx, logger)
+	if err != nil {
+		logger.Err("Unable to get transaction for job '%s'", job.Name)
+		logger.Stack(err)
+		return
+	}
+
+	// Recover from panic and perform rollback on transaction
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Err("!! PANIC !! Recover from panic in job '%s'", job.Name)
+			logger.Stack(errors.New(fmt.Sprintf("%v", r)))
+
+			rollbackErr := tx.Rollback()
+			if rollbackErr != nil {
+				logger.Stack(errors.Wrap(rollbackErr, "error performing rollback"))
+			}
+		}
+	}()
+
+	err = job.Run(ctx, tx, logger)
+	if err != nil {
+		logger.Err("Job '%s' failed", job.Name)
+		logger.Stack(err)
+
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			logger.Stack(errors.Wrap(rollbackErr, "error performing rollback"))
+		}
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Err("Unable to commit transaction of job '%s'", job.Name)
+		logger.Stack(err)
+		return
+	}
+
+	logger.Log("Finished job '%s'", job.Name)
+}
+
+// executeWithoutTransaction runs the job once without transaction and handles panics.
+func executeWithoutTransaction(ctx context.Context, job *Job, logger *util.Logger) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Err("!! PANIC !! Recover from panic in job '%s'", job.Name)
+			logger.Stack(errors.New(fmt.Sprintf("%v", r)))
+		}
+	}()
+
+	err := job.RunWithoutTransaction(ctx, logger)
+	if err != nil {
+		logger.Err("Job '%s' failed", job.Name)
+		logger.Stack(err)
+		return
+	}
+
+	logger.Log("Finished job '%s'", job.Name)
+}
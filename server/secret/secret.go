@@ -0,0 +1,142 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Prefix of encrypted values, values without it are considered to be stored before they were encrypted
+const encryptedPrefix = "enc:"
+
+type encryptionKey struct {
+	id   string // Derived from the key, so that the key of a value can be found without trying all of them
+	aead cipher.AEAD
+}
+
+var (
+	// The first key encrypts new values, all keys decrypt existing ones
+	keys []*encryptionKey
+)
+
+// Configure sets the keys secrets in the database are encrypted with (AES-256-GCM). The keys are separated by commas and
+// each of them consists of 32 bytes encoded as base64. New values are encrypted with the first key, the other keys are
+// only used to decrypt existing values. To rotate the keys, a new key is added in front of the existing ones. Once all
+// values have been encrypted again (see "IsCurrent"), the old keys can be removed. Without keys, secrets can't be
+// stored at all.
+func Configure(encodedKeys string) error {
+	keys = nil
+
+	for _, encodedKey := range strings.Split(encodedKeys, ",") {
+		encodedKey = strings.TrimSpace(encodedKey)
+		if encodedKey == "" {
+			continue
+		}
+
+		keyBytes, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil || len(keyBytes) != 32 {
+			keys = nil
+			return errors.New("encryption keys must be 32 bytes encoded as base64")
+		}
+
+		block, err := aes.NewCipher(keyBytes)
+		if err != nil {
+			keys = nil
+			return errors.Wrap(err, "unable to create cipher of encryption key")
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			keys = nil
+			return errors.Wrap(err, "unable to create cipher of encryption key")
+		}
+
+		hash := sha256.Sum256(keyBytes)
+		keys = append(keys, &encryptionKey{
+			id:   hex.EncodeToString(hash[:4]),
+			aead: aead,
+		})
+	}
+
+	return nil
+}
+
+// Enabled returns true when at least one key is configured. When this is false, nothing can be encrypted.
+func Enabled() bool {
+	return len(keys) != 0
+}
+
+// Encrypt encrypts the value with the current (first) key. The result contains the ID of the key, so it can be
+// decrypted after a key rotation as well.
+func Encrypt(value string) (string, error) {
+	if !Enabled() {
+		return "", errors.New("no encryption key configured")
+	}
+	key := keys[0]
+
+	nonce := make([]byte, key.aead.NonceSize())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to create nonce")
+	}
+
+	encrypted := key.aead.Seal(nonce, nonce, []byte(value), nil)
+	return currentPrefix() + base64.RawURLEncoding.EncodeToString(encrypted), nil
+}
+
+// Decrypt decrypts values created by "Encrypt" with any of the configured keys. Values stored before they were
+// encrypted are returned unchanged.
+func Decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(value, encryptedPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", errors.New("encrypted value has an invalid format")
+	}
+
+	key := findKey(parts[0])
+	if key == nil {
+		return "", errors.New(fmt.Sprintf("encryption key %s is not configured", parts[0]))
+	}
+
+	encrypted, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || len(encrypted) < key.aead.NonceSize() {
+		return "", errors.New("encrypted value has an invalid format")
+	}
+
+	nonceSize := key.aead.NonceSize()
+	decrypted, err := key.aead.Open(nil, encrypted[:nonceSize], encrypted[nonceSize:], nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to decrypt value with key %s", key.id)
+	}
+
+	return string(decrypted), nil
+}
+
+// IsCurrent returns true when the value has been encrypted with the current key. All other values (encrypted with old
+// keys or not encrypted at all) should be encrypted again.
+func IsCurrent(value string) bool {
+	return Enabled() && strings.HasPrefix(value, currentPrefix())
+}
+
+func currentPrefix() string {
+	return encryptedPrefix + keys[0].id + ":"
+}
+
+func findKey(id string) *encryptionKey {
+	for _, key := range keys {
+		if key.id == id {
+			return key
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package secret
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	oldKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	newKey = "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+)
+
+func TestEncryptAndDecrypt(t *testing.T) {
+	defer Configure("")
+
+	err := Configure("")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, err = Encrypt("secret")
+	if err == nil {
+		t.Errorf("Encrypting without key should not be possible")
+	}
+
+	err = Configure(oldKey)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	encrypted, err := Encrypt("secret")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !strings.HasPrefix(encrypted, encryptedPrefix) || strings.Contains(encrypted, "secret") || !IsCurrent(encrypted) {
+		t.Errorf("Encrypted value '%s' has an unexpected format", encrypted)
+	}
+
+	otherEncrypted, _ := Encrypt("secret")
+	if otherEncrypted == encrypted {
+		t.Errorf("Same values should be encrypted differently")
+	}
+
+	decrypted, err := Decrypt(encrypted)
+	if err != nil || decrypted != "secret" {
+		t.Errorf("Value should be decrypted but was '%s': %v", decrypted, err)
+	}
+
+	decrypted, err = Decrypt("plain")
+	if err != nil || decrypted != "plain" || IsCurrent("plain") {
+		t.Errorf("Unencrypted values should be returned unchanged but was '%s': %v", decrypted, err)
+	}
+
+	_, err = Decrypt(encrypted[:len(encrypted)-2] + "xx")
+	if err == nil {
+		t.Errorf("Modified values should not be decrypted")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	defer Configure("")
+
+	err := Configure(oldKey)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	encrypted, _ := Encrypt("secret")
+
+	// The new key encrypts, the old one still decrypts
+	err = Configure(newKey + ", " + oldKey)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if IsCurrent(encrypted) {
+		t.Errorf("Value of old key should not be current")
+	}
+	decrypted, err := Decrypt(encrypted)
+	if err != nil || decrypted != "secret" {
+		t.Errorf("Value of old key should be decrypted but was '%s': %v", decrypted, err)
+	}
+
+	reencrypted, _ := Encrypt(decrypted)
+	if !IsCurrent(reencrypted) {
+		t.Errorf("Value should be encrypted with the new key: %s", reencrypted)
+	}
+
+	// Without the old key, its values can't be decrypted anymore
+	err = Configure(newKey)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, err = Decrypt(encrypted)
+	if err == nil {
+		t.Errorf("Value of removed key should not be decrypted")
+	}
+	decrypted, err = Decrypt(reencrypted)
+	if err != nil || decrypted != "secret" {
+		t.Errorf("Value of new key should be decrypted but was '%s': %v", decrypted, err)
+	}
+
+	err = Configure("not-a-key")
+	if err == nil || Enabled() {
+		t.Errorf("Invalid keys should not be possible")
+	}
+}
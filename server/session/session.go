@@ -0,0 +1,178 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Session is created on every login and referenced by the token of the user. Tokens of revoked sessions are rejected.
+type Session struct {
+	Id         string     `json:"id"`
+	UserId     string     `json:"userId"`
+	Ip         string     `json:"ip"`        // Address of the client during the login
+	UserAgent  string     `json:"userAgent"` // User agent of the client during the login
+	CreatedAt  time.Time  `json:"createdAt"` // Time of the login
+	LastUsedAt time.Time  `json:"lastUsedAt"`
+	ValidUntil time.Time  `json:"validUntil"` // Expiration time of the token
+	RevokedAt  *time.Time `json:"revokedAt"`  // Not set while the session hasn't been revoked
+	Current    bool       `json:"current"`    // True for the session of the requesting user, not stored
+}
+
+type SessionService struct {
+	*util.Logger
+	store *storePg
+}
+
+var (
+	maxLogins     = 100         // Number of past logins returned by "GetLogins"
+	touchInterval = time.Minute // Minimum time between two updates of the last usage, see "NeedsTouch"
+)
+
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger) *SessionService {
+	return &SessionService{
+		Logger: logger,
+		store:  getStore(ctx, tx, logger),
+	}
+}
+
+// InitWithoutTransaction works like "Init" but runs the queries directly on the database connection. This is meant for
+// the read-only "Verify" on every request, which doesn't need a transaction of its own.
+func InitWithoutTransaction(ctx context.Context, db *sql.DB, logger *util.Logger) *SessionService {
+	return &SessionService{
+		Logger: logger,
+		store:  getStore(ctx, db, logger),
+	}
+}
+
+// NeedsTouch returns true when the last usage of the session is so long ago that it should be updated, see "Touch".
+func (s *Session) NeedsTouch() bool {
+	return time.Since(s.LastUsedAt) >= touchInterval
+}
+
+// Start stores a new session for the login of the user.
+func (s *SessionService) Start(userId string, ip string, userAgent string, validUntil time.Time) (*Session, error) {
+	session, err := s.store.addSession(userId, ip, userAgent, validUntil)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Started session %s of user %s from %s", session.Id, userId, ip)
+
+	return session, nil
+}
+
+// Verify checks that the session belongs to the user and is neither revoked nor expired. This only reads the session,
+// the time of the last usage has to be updated separately via "Touch".
+func (s *SessionService) Verify(sessionId string, userId string) (*Session, error) {
+	session, err := s.store.getSession(sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.UserId != userId {
+		return nil, errors.New(fmt.Sprintf("session %s doesn't belong to user %s", sessionId, userId))
+	}
+	if session.RevokedAt != nil {
+		return nil, errors.New(fmt.Sprintf("session %s has been revoked", sessionId))
+	}
+	if session.ValidUntil.Before(time.Now()) {
+		return nil, errors.New(fmt.Sprintf("session %s expired", sessionId))
+	}
+
+	return session, nil
+}
+
+// Touch updates the time of the last usage of the session, at most once per minute.
+func (s *SessionService) Touch(sessionId string) error {
+	return s.store.touchSession(sessionId)
+}
+
+// GetSessions returns the active sessions of the user, newest first. The session "currentSessionId" is marked as the
+// current one.
+func (s *SessionService) GetSessions(userId string, currentSessionId string) ([]*Session, error) {
+	sessions, err := s.store.getActiveSessions(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	markCurrent(sessions, currentSessionId)
+	return sessions, nil
+}
+
+// GetLogins returns the latest logins of the user (including revoked and expired sessions), newest first.
+func (s *SessionService) GetLogins(userId string, currentSessionId string) ([]*Session, error) {
+	sessions, err := s.store.getSessions(userId, maxLogins)
+	if err != nil {
+		return nil, err
+	}
+
+	markCurrent(sessions, currentSessionId)
+	return sessions, nil
+}
+
+// Revoke ends the session, so that its token can't be used anymore. Users can only revoke their own sessions.
+func (s *SessionService) Revoke(sessionId string, requestingUserId string) error {
+	session, err := s.store.getSession(sessionId)
+	if err != nil {
+		return err
+	}
+
+	if session.UserId != requestingUserId {
+		return errors.New(fmt.Sprintf("user %s is not allowed to revoke session %s", requestingUserId, sessionId))
+	}
+	if session.RevokedAt != nil {
+		return errors.New(fmt.Sprintf("session %s has already been revoked", sessionId))
+	}
+
+	err = s.store.revokeSessions(requestingUserId, []string{sessionId})
+	if err != nil {
+		return err
+	}
+	s.Log("Revoked session %s of user %s", sessionId, requestingUserId)
+
+	return nil
+}
+
+// RevokeOthers revokes all active sessions of the user except the current one, e.g. after logging in on a public
+// computer. It returns the number of revoked sessions.
+func (s *SessionService) RevokeOthers(userId string, currentSessionId string) (int, error) {
+	sessions, err := s.store.getActiveSessions(userId)
+	if err != nil {
+		return 0, err
+	}
+
+	sessionIds := make([]string, 0)
+	for _, session := range sessions {
+		if session.Id != currentSessionId {
+			sessionIds = append(sessionIds, session.Id)
+		}
+	}
+
+	if len(sessionIds) == 0 {
+		return 0, nil
+	}
+
+	err = s.store.revokeSessions(userId, sessionIds)
+	if err != nil {
+		return 0, err
+	}
+	s.Log("Revoked %d sessions of user %s", len(sessionIds), userId)
+
+	return len(sessionIds), nil
+}
+
+// RevokeAll revokes all active sessions of the user, e.g. when the account of the user got removed. It returns the
+// number of revoked sessions.
+func (s *SessionService) RevokeAll(userId string) (int, error) {
+	return s.RevokeOthers(userId, "")
+}
+
+func markCurrent(sessions []*Session, currentSessionId string) {
+	for _, session := range sessions {
+		session.Current = session.Id == currentSessionId
+	}
+}
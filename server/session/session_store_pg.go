@@ -0,0 +1,147 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// queryer is implemented by transactions and by the database connection itself, see "InitWithoutTransaction".
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type storePg struct {
+	*util.Logger
+	ctx   context.Context
+	tx    queryer
+	table database.Table
+}
+
+var (
+	returnValues = "id, user_id, ip, user_agent, created_at, last_used_at, valid_until, revoked_at"
+)
+
+func getStore(ctx context.Context, tx queryer, logger *util.Logger) *storePg {
+	return &storePg{
+		Logger: logger,
+		ctx:    ctx,
+		tx:     tx,
+		table:  database.TableSessions,
+	}
+}
+
+func (s *storePg) addSession(userId string, ip string, userAgent string, validUntil time.Time) (*Session, error) {
+	query := fmt.Sprintf("INSERT INTO %s(user_id, ip, user_agent, valid_until) VALUES($1, $2, $3, $4) RETURNING %s;", s.table, returnValues)
+	sessions, err := s.execQuery(query, userId, ip, userAgent, validUntil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error adding session of user %s", userId)
+	}
+
+	return sessions[0], nil
+}
+
+func (s *storePg) getSession(sessionId string) (*Session, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id=$1;", returnValues, s.table)
+	sessions, err := s.execQuery(query, sessionId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting session %s", sessionId)
+	}
+	if len(sessions) == 0 {
+		return nil, errors.New(fmt.Sprintf("session %s not found", sessionId))
+	}
+
+	return sessions[0], nil
+}
+
+// getActiveSessions returns the sessions of the user that are neither revoked nor expired, newest first.
+func (s *storePg) getActiveSessions(userId string) ([]*Session, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE user_id=$1 AND revoked_at IS NULL AND valid_until > NOW() ORDER BY created_at DESC, id DESC;", returnValues, s.table)
+	sessions, err := s.execQuery(query, userId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting active sessions of user %s", userId)
+	}
+
+	return sessions, nil
+}
+
+// getSessions returns the latest sessions of the user, newest first.
+func (s *storePg) getSessions(userId string, limit int) ([]*Session, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE user_id=$1 ORDER BY created_at DESC, id DESC LIMIT $2;", returnValues, s.table)
+	sessions, err := s.execQuery(query, userId, limit)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting sessions of user %s", userId)
+	}
+
+	return sessions, nil
+}
+
+// touchSession updates the time of the last usage. Concurrent requests might touch the session at the same time, so
+// this only happens when the last update is at least one minute ago (see "touchInterval").
+func (s *storePg) touchSession(sessionId string) error {
+	query := fmt.Sprintf("UPDATE %s SET last_used_at=NOW() WHERE id=$1 AND last_used_at < NOW() - INTERVAL '1 minute';", s.table)
+	s.LogQuery(query, sessionId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, sessionId)
+	if err != nil {
+		return errors.Wrapf(err, "error updating last usage of session %s", sessionId)
+	}
+
+	return nil
+}
+
+func (s *storePg) revokeSessions(userId string, sessionIds []string) error {
+	query := fmt.Sprintf("UPDATE %s SET revoked_at=NOW() WHERE user_id=$1 AND id = ANY($2::INTEGER[]) AND revoked_at IS NULL;", s.table)
+	s.LogQuery(query, userId, sessionIds)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, userId, pq.Array(sessionIds))
+	if err != nil {
+		return errors.Wrapf(err, "error revoking sessions %v of user %s", sessionIds, userId)
+	}
+
+	return nil
+}
+
+func (s *storePg) execQuery(query string, params ...interface{}) ([]*Session, error) {
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not run query")
+	}
+	defer rows.Close()
+
+	sessions := make([]*Session, 0)
+	for rows.Next() {
+		var id int
+		var revokedAt sql.NullTime
+		session := &Session{}
+
+		err = rows.Scan(&id, &session.UserId, &session.Ip, &session.UserAgent, &session.CreatedAt, &session.LastUsedAt, &session.ValidUntil, &revokedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan session")
+		}
+
+		session.Id = strconv.Itoa(id)
+		if revokedAt.Valid {
+			session.RevokedAt = &revokedAt.Time
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,172 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/test"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq" // Make driver "postgres" usable
+)
+
+var (
+	tx *sql.Tx
+	s  *SessionService
+	h  *test.TestHelper
+)
+
+func TestMain(m *testing.M) {
+	h = &test.TestHelper{
+		Setup: setup,
+	}
+
+	m.Run()
+}
+
+func setup() {
+	config.LoadConfig("../config/test.json")
+	test.InitWithDummyData()
+	sigolo.LogLevel = sigolo.LOG_DEBUG
+
+	logger := util.NewLogger()
+
+	ctx := context.Background()
+
+	var err error
+	tx, err = database.GetTransaction(ctx, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	h.Tx = tx
+	s = Init(ctx, tx, logger)
+}
+
+func TestSessions(t *testing.T) {
+	h.Run(t, func() error {
+		validUntil := time.Now().Add(time.Hour)
+
+		first, err := s.Start("Peter", "127.0.0.1", "Firefox", validUntil)
+		if err != nil {
+			return err
+		}
+		if first.UserId != "Peter" || first.Ip != "127.0.0.1" || first.UserAgent != "Firefox" || first.RevokedAt != nil {
+			return errors.New(fmt.Sprintf("Session does not match: %#v", first))
+		}
+
+		second, err := s.Start("Peter", "10.0.0.1", "Chrome", validUntil)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.Verify(first.Id, "Peter")
+		if err != nil {
+			return err
+		}
+
+		_, err = s.Verify(first.Id, "Maria")
+		if err == nil {
+			return errors.New("Session of other user should not be valid")
+		}
+
+		sessions, err := s.GetSessions("Peter", second.Id)
+		if err != nil {
+			return err
+		}
+		if len(sessions) != 2 || sessions[0].Id != second.Id || !sessions[0].Current || sessions[1].Current {
+			return errors.New(fmt.Sprintf("Sessions do not match: %v", sessions))
+		}
+
+		err = s.Revoke(first.Id, "Maria")
+		if err == nil {
+			return errors.New("Users should not be able to revoke sessions of others")
+		}
+
+		err = s.Revoke(first.Id, "Peter")
+		if err != nil {
+			return err
+		}
+
+		_, err = s.Verify(first.Id, "Peter")
+		if err == nil {
+			return errors.New("Revoked session should not be valid")
+		}
+
+		sessions, err = s.GetSessions("Peter", second.Id)
+		if err != nil {
+			return err
+		}
+		if len(sessions) != 1 || sessions[0].Id != second.Id {
+			return errors.New(fmt.Sprintf("Only the second session should be active: %v", sessions))
+		}
+
+		// The login audit still contains the revoked session
+		logins, err := s.GetLogins("Peter", second.Id)
+		if err != nil {
+			return err
+		}
+		if len(logins) != 2 || logins[1].RevokedAt == nil {
+			return errors.New(fmt.Sprintf("Logins do not match: %v", logins))
+		}
+
+		return nil
+	})
+}
+
+func TestRevokeOthers(t *testing.T) {
+	h.Run(t, func() error {
+		validUntil := time.Now().Add(time.Hour)
+
+		current, err := s.Start("Maria", "", "", validUntil)
+		if err != nil {
+			return err
+		}
+		_, err = s.Start("Maria", "", "", validUntil)
+		if err != nil {
+			return err
+		}
+		_, err = s.Start("Maria", "", "", validUntil)
+		if err != nil {
+			return err
+		}
+
+		expired, err := s.Start("Maria", "", "", time.Now().Add(-time.Minute))
+		if err != nil {
+			return err
+		}
+		_, err = s.Verify(expired.Id, "Maria")
+		if err == nil {
+			return errors.New("Expired session should not be valid")
+		}
+
+		revoked, err := s.RevokeOthers("Maria", current.Id)
+		if err != nil {
+			return err
+		}
+		if revoked != 2 {
+			return errors.New(fmt.Sprintf("Two sessions should have been revoked but were %d", revoked))
+		}
+
+		_, err = s.Verify(current.Id, "Maria")
+		return err
+	})
+}
+
+func TestNeedsTouch(t *testing.T) {
+	recent := &Session{LastUsedAt: time.Now().Add(-10 * time.Second)}
+	if recent.NeedsTouch() {
+		t.Errorf("Recently used session should not need a touch")
+	}
+
+	old := &Session{LastUsedAt: time.Now().Add(-2 * time.Minute)}
+	if !old.NeedsTouch() {
+		t.Errorf("Session used two minutes ago should need a touch")
+	}
+}
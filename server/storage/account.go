@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"time"
+)
+
+// AccountStore persists the accounts of the "local" auth backend. It's used by the "account" package.
+type AccountStore interface {
+	AddAccount(accountId string, keyHash string, adminId string) (*Account, error)
+	GetAccounts() ([]*Account, error)
+	GetAccount(accountId string) (*Account, error)
+	GetAccountByKeyHash(keyHash string) (*Account, error)
+	SetKeyHash(accountId string, keyHash string) error
+	RemoveAccount(accountId string) error
+}
+
+// Account of the "local" auth backend. The ID is chosen by the admin and is used as user ID and user name, so it can
+// e.g. be added to the admins in the config.
+type Account struct {
+	Id        string    `json:"id"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	Key       string    `json:"key,omitempty"` // Only set right after creating the account or renewing its key
+}
@@ -0,0 +1,20 @@
+package storage
+
+// PermissionStore answers the questions the permission checks need, e.g. whether a user is a member of a project. It's
+// used by the "permission" package, which turns the answers into errors. The "...OfTask" methods refer to the project
+// of the given task.
+type PermissionStore interface {
+	IsOwner(projectId string, userId string) (bool, error)
+	IsOwnerOfTask(taskId string, userId string) (bool, error)
+	IsMember(projectId string, userId string) (bool, error)
+	IsMemberOfTask(taskId string, userId string) (bool, error)
+	CountMemberships(taskIds []string, userId string) (int, error)
+	IsAssigned(taskId string, userId string) (bool, error)
+	IsAllowedUser(taskId string, userId string) (bool, error)
+	IsNotArchivedTask(taskId string) (bool, error)
+	GetUserCount(projectId string) (int, error)
+	GetUserCountOfTask(taskId string) (int, error)
+	GetMinChangesetsOfTask(taskId string) (int, error)
+	GetPointStepOfTask(taskId string) (int, error)
+	GetAssignmentLimitsOfTask(taskId string) (int, int, error)
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/util"
+)
+
+// ProjectStore persists projects including their snapshots, join requests, merge requests and commands. It's used by
+// the "project" package.
+type ProjectStore interface {
+	GetProjects(userId string) ([]*Project, error)
+	GetProject(projectId string) (*Project, error)
+	GetProjectByTask(taskId string) (*Project, error)
+	LockProject(projectId string) error
+	AddProject(draft *Project) (*Project, error)
+	AddUser(projectId string, userIdToAdd string) (*Project, error)
+	RemoveUser(projectId string, userIdToRemove string) (*Project, error)
+	Delete(projectId string, ownerId string) error
+	UpdateName(projectId string, newName string) (*Project, error)
+	UpdateDescription(projectId string, newDescription string) (*Project, error)
+	UpdateLocale(projectId string, locale string) (*Project, error)
+	UpdateDescriptions(projectId string, descriptions map[string]string) (*Project, error)
+	UpdateChangesetTemplate(projectId string, comment string, hashtags []string) (*Project, error)
+	UpdateMinChangesets(projectId string, minChangesets int) (*Project, error)
+	UpdatePointStep(projectId string, pointStep int) (*Project, error)
+	UpdatePublic(projectId string, public bool) (*Project, error)
+	UpdateAoi(projectId string, aoi string) (*Project, error)
+	UpdateUnassignAfter(projectId string, hours int) (*Project, error)
+	UpdateTargets(projectId string, targets []*Target) (*Project, error)
+	UpdateLayers(projectId string, layers []*Layer) (*Project, error)
+	UpdateChecklist(projectId string, checklist Checklist, checklistPoints bool) (*Project, error)
+	UpdateAssignmentLimits(projectId string, maxAssignedTasks int, maxCompletions int) (*Project, error)
+	SetCompletedAt(projectId string, completedAt *time.Time) error
+	ArchiveCompletedProjects(completedBefore time.Time) ([]string, error)
+	AddJoinRequest(projectId string, userId string) (*JoinRequest, error)
+	GetJoinRequests(projectId string) ([]*JoinRequest, error)
+	GetJoinRequestsOfOwner(ownerId string) ([]*JoinRequest, error)
+	RemoveJoinRequest(projectId string, userId string) error
+	AddMergeRequest(sourceProjectId string, targetProjectId string, userId string) (*MergeRequest, error)
+	GetMergeRequest(sourceProjectId string, targetProjectId string) (*MergeRequest, error)
+	GetMergeRequests(projectId string) ([]*MergeRequest, error)
+	RemoveMergeRequest(sourceProjectId string, targetProjectId string) error
+	MergeProjects(sourceProjectId string, targetProjectId string) error
+	RepairProgress() ([]string, error)
+	GetInconsistencies() ([]*Inconsistency, error)
+	RepairInconsistency(inconsistency *Inconsistency) error
+	AddSnapshots() (int64, error)
+	GetSnapshots(projectId string) ([]*Snapshot, error)
+	GetNearbyProjectIds(lat float64, lon float64, radius float64, limit int) ([]string, []float64, error)
+	AddCommand(projectId string, userId string, commandType string, data CommandData) (*Command, error)
+	GetCommands(projectId string) ([]*Command, error)
+	GetCommand(commandId string) (*Command, error)
+	GetJoinDates(projectId string) (map[string]time.Time, error)
+	SetCommandReverted(commandId string, userId string, reverted bool) error
+}
+
+// Project is the model used by the services and stores. It's not sent to clients directly, the API has its own DTOs.
+type Project struct {
+	Id                 string
+	Name               string
+	TaskIDs            []string // Computed from the "project_id" of the tasks, not stored in the project itself
+	Users              []string
+	Owner              string
+	Description        string
+	NeedsAssignment    bool              // When "true", the tasks of this project need to have an assigned user
+	TotalProcessPoints int               // Sum of all maximum process points of all tasks
+	DoneProcessPoints  int               // Sum of all process points that have been set
+	Status             string            // One of the "project.Status..." values, computed from the process points
+	DefaultDifficulty  string            // Difficulty of all tasks added without explicit difficulty
+	MinChangesets      int               // Users need at least this many OSM changesets to get a task assigned
+	PointStep          int               // Process points can only be set to multiples of this step (or the maximum), 0 means no restriction
+	MaxAssignedTasks   int               // Number of tasks a user can have assigned at the same time, 0 means no limit
+	MaxCompletions     int               // Number of tasks a user can complete per day, 0 means no limit
+	UnassignAfterHours int               // Tasks assigned this long without process point change get unassigned, 0 disables this
+	CompletedAt        *time.Time        // Time when all process points have been reached, "nil" while the project is not completed
+	Archived           bool              // Tasks of archived projects can't be changed anymore
+	Locale             string            // Language of the description, e.g. "en" or "de-AT"
+	Descriptions       map[string]string // Translations of the description (locale -> text)
+	Targets            []*Target         // Progress the owner wants to reach by certain dates, ordered by date
+	Layers             []*Layer          // Imagery all members should map with, see "project.UpdateLayers"
+	Checklist          Checklist         // Instructions for every task, the tasks track which of the items are completed
+	ChecklistPoints    bool              // When "true", the process points of the tasks are derived from their completed checklist items
+	GeometryTypes      []string          // Geometry types of the tasks, see "task.GeometryType..." values
+	ChangesetComment   string            // Template of the changeset comment of the tasks, see "task.ExpandChangesetTemplate"
+	ChangesetHashtags  []string          // Templates of the changeset hashtags of the tasks, each starting with "#"
+	Public             bool              // Public projects can be found by everyone, e.g. via "project.GetNearbyProjects"
+	Aoi                string            // GeoJSON geometry of the area of interest, the union of all tasks unless supplied by the owner
+	MergedInto         string            // ID of the project this (archived) project has been merged into, empty if not merged
+	Tutorial           bool              // Sandbox project for new users, see "project.CreateTutorialProject"
+	CreatedAt          time.Time         // Set by the store
+	UpdatedAt          time.Time         // Set by the store on every change of the project or its process points
+}
+
+// Snapshot is the progress of a project at the end of a day (or the latest progress of the current day).
+type Snapshot struct {
+	Date               string `json:"date"` // Format: "YYYY-MM-DD"
+	DoneProcessPoints  int    `json:"doneProcessPoints"`
+	TotalProcessPoints int    `json:"totalProcessPoints"`
+}
+
+// Localize replaces the description by the translation matching the "Accept-Language" header best. The description
+// stays as it is when it already matches best (see "Locale") or when no translation matches.
+func (p *Project) Localize(acceptLanguage string) {
+	translations := make([]string, 0, len(p.Descriptions))
+	for l := range p.Descriptions {
+		translations = append(translations, l)
+	}
+	// Map iteration is random, so sort to always get the same match
+	sort.Strings(translations)
+
+	available := translations
+	if p.Locale != "" {
+		available = append([]string{p.Locale}, translations...)
+	}
+
+	locale := util.MatchLocale(acceptLanguage, available)
+	if description, ok := p.Descriptions[locale]; ok && locale != p.Locale {
+		p.Description = description
+	}
+}
+
+// Target is the progress the owner wants to reach by a certain date, e.g. 80% of all process points by June 1.
+type Target struct {
+	Percentage int    `json:"percentage"` // Percentage of the done process points, 1 to 100
+	Date       string `json:"date"`       // Format: "YYYY-MM-DD"
+}
+
+// Layer is imagery (e.g. aerial images) the owner wants all members to map with, so that everyone uses the same and
+// e.g. up-to-date imagery. The URL uses the placeholders of the imagery index used by common editors like JOSM and iD.
+type Layer struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`        // One of the "project.LayerType..." values
+	Url         string `json:"url"`         // TMS: must contain "{z}", "{x}" and "{y}"; WMS: must contain "{bbox}"
+	Attribution string `json:"attribution"` // Text that must be shown when using the layer, e.g. the copyright
+	MaxZoom     int    `json:"maxZoom"`     // Highest zoom level the imagery is available for, 0 means unknown
+}
+
+// JoinRequest is the request of a user, who's not a member of the project, to join the project.
+type JoinRequest struct {
+	ProjectId string    `json:"projectId"`
+	UserId    string    `json:"userId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// MergeRequest is the request of the owner of one project to merge the source into the target project. The owner of the
+// other project has to approve it.
+type MergeRequest struct {
+	SourceProjectId string    `json:"sourceProjectId"`
+	TargetProjectId string    `json:"targetProjectId"`
+	RequestedBy     string    `json:"requestedBy"`
+	Approver        string    `json:"approver"` // Owner of the project not owned by the requesting user
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// Inconsistency is a broken reference of a task.
+type Inconsistency struct {
+	Kind      string `json:"kind"` // One of the "project.Inconsistency..." values
+	ProjectId string `json:"projectId"`
+	TaskId    string `json:"taskId"`
+	Reference string `json:"reference"` // The broken reference, so the missing project or task or the non-member user
+	Repaired  bool   `json:"repaired"`
+}
+
+// Command is a change of a project made by its owner. It can be reverted within the revert window and the revert can be
+// undone (redo) as well.
+type Command struct {
+	Id         string      `json:"id"`
+	ProjectId  string      `json:"projectId"`
+	UserId     string      `json:"userId"` // The user who made the change
+	Type       string      `json:"type"`   // One of the "project.Command..." values
+	Data       CommandData `json:"data"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	RevertedAt *time.Time  `json:"revertedAt"` // Set while the command is reverted
+	RevertedBy string      `json:"revertedBy,omitempty"`
+}
+
+// CommandData contains everything needed to revert and redo a command. Only the fields needed for the type are set.
+type CommandData struct {
+	UserId   string   `json:"userId,omitempty"`   // The added or removed user
+	TaskIds  []string `json:"taskIds,omitempty"`  // Tasks the removed user has been unassigned from
+	OldValue string   `json:"oldValue,omitempty"` // Name or description before the change
+	NewValue string   `json:"newValue,omitempty"` // Name or description after the change
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// DriverPostgres is the default driver storing everything in the PostgreSQL database.
+const DriverPostgres = "postgres"
+
+// Components with a store, each driver has to register a store for all of them. A component is usually the package
+// of the service using the store.
+const (
+	ComponentAccount    = "account"
+	ComponentPermission = "permission"
+	ComponentProject    = "project"
+	ComponentTask       = "task"
+	ComponentUser       = "user"
+)
+
+var components = []string{ComponentAccount, ComponentPermission, ComponentProject, ComponentTask, ComponentUser}
+
+// Transaction is the transaction of a request or job, all stores created for it work within this transaction. The
+// services pass a "*sql.Tx", which fulfills this interface. Drivers not based on SQL can ignore it or cast it to the
+// transaction type they know.
+type Transaction interface {
+	Commit() error
+	Rollback() error
+}
+
+// Factory creates the store of a component for the given transaction. The store has to implement the store interface
+// of the component in this package (e.g. "ProjectStore" for the "project" component).
+type Factory func(ctx context.Context, tx Transaction, logger *util.Logger) interface{}
+
+var (
+	// Factories of all registered drivers: driver -> component -> factory
+	factories      = make(map[string]map[string]Factory)
+	driver         = DriverPostgres
+	factoriesMutex = &sync.RWMutex{}
+)
+
+// Register makes the store of the component available for the given driver. It's meant to be called from the "init"
+// function of the package implementing the stores of the driver, so that adding a driver doesn't require changes of the
+// services. Like
+// "sql.Register", this panics when a store is registered twice.
+func Register(driverName string, component string, factory Factory) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+
+	if factory == nil {
+		panic(fmt.Sprintf("store factory of component '%s' for driver '%s' is nil", component, driverName))
+	}
+
+	if _, ok := factories[driverName]; !ok {
+		factories[driverName] = make(map[string]Factory)
+	}
+
+	if _, ok := factories[driverName][component]; ok {
+		panic(fmt.Sprintf("store of component '%s' for driver '%s' is already registered", component, driverName))
+	}
+
+	factories[driverName][component] = factory
+}
+
+// SetDriver selects the driver used by all stores. The driver must have a store for every component.
+func SetDriver(driverName string) error {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+
+	driverFactories, ok := factories[driverName]
+	if !ok {
+		return errors.New(fmt.Sprintf("unknown store driver '%s', registered are %v", driverName, drivers()))
+	}
+
+	for _, component := range components {
+		if _, ok := driverFactories[component]; !ok {
+			return errors.New(fmt.Sprintf("store driver '%s' has no store for component '%s'", driverName, component))
+		}
+	}
+
+	driver = driverName
+
+	return nil
+}
+
+// GetDriver returns the name of the selected driver.
+func GetDriver() string {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+
+	return driver
+}
+
+// GetDrivers returns the names of all registered drivers in alphabetical order.
+func GetDrivers() []string {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+
+	return drivers()
+}
+
+// NewStore creates the store of the component using the selected driver. The caller casts the result to the store
+// interface of the component, e.g. "ProjectStore". Since "SetDriver" verifies that all stores exist, a missing store is a programming error
+// and results in a panic.
+func NewStore(component string, ctx context.Context, tx Transaction, logger *util.Logger) interface{} {
+	factoriesMutex.RLock()
+	factory, ok := factories[driver][component]
+	driverName := driver
+	factoriesMutex.RUnlock()
+
+	if !ok {
+		panic(fmt.Sprintf("store driver '%s' has no store for component '%s'", driverName, component))
+	}
+
+	return factory(ctx, tx, logger)
+}
+
+func drivers() []string {
+	result := make([]string, 0, len(factories))
+	for name := range factories {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hauke96/simple-task-manager/server/util"
+)
+
+type memoryStore struct {
+	component string
+}
+
+func memoryFactory(component string) Factory {
+	return func(ctx context.Context, tx Transaction, logger *util.Logger) interface{} {
+		return &memoryStore{component: component}
+	}
+}
+
+func TestDrivers(t *testing.T) {
+	previousDriver := driver
+	defer func() {
+		driver = previousDriver
+	}()
+
+	// Incomplete drivers can't be used
+	Register("memory", ComponentProject, memoryFactory(ComponentProject))
+	err := SetDriver("memory")
+	if err == nil {
+		t.Errorf("Driver without store for all components should not be usable")
+	}
+
+	Register("memory", ComponentTask, memoryFactory(ComponentTask))
+	Register("memory", ComponentAccount, memoryFactory(ComponentAccount))
+	Register("memory", ComponentPermission, memoryFactory(ComponentPermission))
+	Register("memory", ComponentUser, memoryFactory(ComponentUser))
+	err = SetDriver("memory")
+	if err != nil {
+		t.Fatalf("Driver with store for all components should be usable: %s", err.Error())
+	}
+	if GetDriver() != "memory" {
+		t.Errorf("Driver should be 'memory' but was '%s'", GetDriver())
+	}
+
+	store, ok := NewStore(ComponentTask, context.Background(), nil, util.NewLogger()).(*memoryStore)
+	if !ok || store.component != ComponentTask {
+		t.Errorf("Store of the selected driver should be created: %#v", store)
+	}
+
+	err = SetDriver("sqlite")
+	if err == nil {
+		t.Errorf("Unknown driver should not be usable")
+	}
+	if GetDriver() != "memory" {
+		t.Errorf("Driver should not change when setting an unknown driver")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Registering a store twice should panic")
+			}
+		}()
+		Register("memory", ComponentTask, memoryFactory(ComponentTask))
+	}()
+}
@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/osm"
+)
+
+// TaskStore persists tasks including their history, notes, changesets and handovers. It's used by the "task" package.
+// The savepoints allow the service to undo single changes without rolling back the whole transaction.
+type TaskStore interface {
+	GetTasks(projectId string) ([]*Task, error)
+	GetAssignedTasks(userId string) ([]*AssignedTask, error)
+	GetChangedTasks(userId string, since time.Time) ([]*AssignedTask, error)
+	GetTask(taskId string) (*Task, error)
+	AddTasks(newTasks []*Task, projectId string) ([]*Task, error)
+	AssignUser(taskId, userId string) (*Task, error)
+	UnassignUser(taskId string) (*Task, error)
+	SetProcessPoints(taskId string, newPoints int) (*Task, error)
+	SetChecklistDone(taskId string, itemIds []string) (*Task, error)
+	GetChecklist(taskId string) (Checklist, bool, error)
+	SetDifficulty(taskId string, difficulty string) (*Task, error)
+	SetAllowedUsers(taskId string, allowedUsers []string) (*Task, error)
+	SetDependencies(taskId string, dependsOn []string) (*Task, error)
+	GetTasksOfSameProject(taskId string) ([]*Task, error)
+	Flag(taskId string, reason string, comment string, userId string) (*Task, error)
+	ResolveFlag(taskId string) (*Task, error)
+	GetNote(taskId string) (*TaskNote, error)
+	GetNotes(projectId string) ([]*TaskNote, error)
+	SetNote(taskId string, text string, userId string) (*TaskNote, error)
+	RemoveNote(taskId string) error
+	GetInactiveTasks() ([]*Task, error)
+	GetExpiringAssignments() ([]*ExpiringAssignment, error)
+	AddHandover(taskId string, fromUser string, toUser string) (*Handover, error)
+	GetHandover(taskId string) (*Handover, error)
+	GetHandoversTo(userId string) ([]*Handover, error)
+	RemoveHandover(taskId string) error
+	ReserveTask(taskId string, userId string, editor string, duration time.Duration) (bool, error)
+	RemoveReservation(taskId string, userId string) error
+	RemoveExpiredReservations() (int64, error)
+	LinkChangeset(taskId string, changesetId string, userId string) error
+	GetChangesetStats(projectId string) (*ChangesetStats, error)
+	GetAreaStats(projectId string) (*AreaStats, error)
+	GetMemberActivities(projectId string) (map[string]*MemberActivity, error)
+	GetChangesetsToUpdate(limit int) ([]string, error)
+	UpdateChangeset(changeset *osm.Changeset) error
+	LinkOsmNote(taskId string, noteId string, open bool, userId string) (*OsmNote, error)
+	GetOsmNotes(taskId string) ([]*OsmNote, error)
+	GetOsmNotesToUpdate(limit int) ([]string, error)
+	UpdateOsmNote(noteId string, open bool) error
+	GetTasksWithoutLocality(limit int) ([]*Task, error)
+	SetLocalityAttempted(taskIds []string) error
+	SetLocality(taskId string, locality string) error
+	Delete(taskIds []string) error
+	SetSavepoint() error
+	ReleaseSavepoint() error
+	RollbackToSavepoint() error
+	GetProjectAoi(projectId string) (string, error)
+	AddHistoryEntry(taskId string, userId string, entryType string, processPoints int, pointsDelta int) error
+	AddHistoryEntryWithComment(taskId string, userId string, entryType string, processPoints int, pointsDelta int, comment string) error
+	GetContributions(userId string) ([]*Contribution, error)
+	GetGeometryTypes(projectId string) ([]string, error)
+	GetTimeline(projectId string) ([]*TimelineEvent, error)
+	GetLastMapper(taskId string) (string, error)
+	CountAssignedTasks(taskId string, userId string) (int, error)
+	CountCompletionsToday(taskId string, userId string) (int, error)
+}
+
+// Task is the model used by the services and stores. It's not sent to clients directly, the API has its own DTOs.
+type Task struct {
+	Id                string
+	ProcessPoints     int
+	MaxProcessPoints  int
+	Geometry          string
+	AssignedUser      string
+	BoundingBox       []float64        // [minLon, minLat, maxLon, maxLat] of the geometry, set by the server
+	Centroid          []float64        // [lon, lat] of the geometries center of mass, set by the server
+	Area              float64          // Area of polygons in km², set by the server
+	Perimeter         float64          // Length of the outline of polygons and of lines in km, set by the server
+	Version           int              // Increased with every change, used to detect conflicting changes
+	Difficulty        string           // One of the "task.Difficulty..." values
+	AllowedUsers      []string         // Only these members may work on the task, empty allows all members
+	DependsOn         []string         // IDs of tasks of the same project, which have to be completed before this task can be assigned
+	Blocked           bool             // True when at least one of the "DependsOn" tasks isn't completed yet, set by the store
+	ChecklistDone     []string         // IDs of the completed items of the checklist of the project
+	OpenOsmNotes      int              // Number of linked OSM notes which are still open, set by the store
+	Locality          string           // Name of the area around the centroid (e.g. "Kibera, Nairobi"), empty until it has been looked up
+	Flag              *TaskFlag        // Set when the task couldn't be completed, "nil" otherwise
+	Reservation       *TaskReservation // Set while someone has the task opened in an editor, "nil" otherwise
+	ChangesetComment  string           // Changeset comment editors should use for this task, based on the template of the project
+	ChangesetHashtags []string         // Changeset hashtags editors should use for this task, based on the template of the project
+	CreatedAt         time.Time        // Set by the store
+	UpdatedAt         time.Time        // Set by the store on every change of the task
+}
+
+// AssignedTask is a task together with its project, e.g. to list the tasks of a user across all projects.
+type AssignedTask struct {
+	*Task
+	ProjectId   string
+	ProjectName string
+	AssignedAt  *time.Time // Time of the latest assignment according to the task history, "nil" for unassigned tasks
+}
+
+// Contribution summarizes the activity of one user on one task based on the task history.
+type Contribution struct {
+	TaskId           string    `json:"taskId"`
+	ProjectId        string    `json:"projectId"`
+	ProcessPoints    int       `json:"processPoints"` // Sum of all process point changes made by the user
+	MaxProcessPoints int       `json:"maxProcessPoints"`
+	Completed        bool      `json:"completed"` // True when the user set the process points to the maximum
+	Area             float64   `json:"area"`      // Area of the task in km², see "Task.Area"
+	Perimeter        float64   `json:"perimeter"` // Perimeter of the task in km, see "Task.Perimeter"
+	FirstActivity    time.Time `json:"firstActivity"`
+	LastActivity     time.Time `json:"lastActivity"`
+}
+
+// IsDone returns true when all process points of the task have been reached.
+func (t *Task) IsDone() bool {
+	return t.ProcessPoints >= t.MaxProcessPoints
+}
+
+// ChecklistItem is one step of the instructions of a project, e.g. "map buildings" or "add addresses". Each task tracks
+// which of these items have been completed.
+type ChecklistItem struct {
+	Id   string `json:"id"` // Set by the server, stays the same when the items are reordered or their text changes
+	Text string `json:"text"`
+}
+
+// Checklist contains the instructions of a project in the order they should be followed.
+type Checklist []*ChecklistItem
+
+// TaskNote is a private note of the project owner on a task (e.g. "bad imagery in NE corner"). Notes are not part of
+// the task and only the owner is allowed to see them.
+type TaskNote struct {
+	TaskId    string    `json:"taskId"`
+	Text      string    `json:"text"`
+	UpdatedBy string    `json:"updatedBy"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ExpiringAssignment is the assignment of a user to a task, which ends soon due to missing progress.
+type ExpiringAssignment struct {
+	TaskId      string
+	ProjectId   string
+	ProjectName string
+	UserId      string
+	AssignedAt  time.Time
+	UnassignAt  time.Time
+}
+
+// Handover is the offer of the assigned user to hand the task over to another member. The task stays assigned to the
+// offering user until the other member accepts it.
+type Handover struct {
+	TaskId    string    `json:"taskId"`
+	ProjectId string    `json:"projectId"`
+	FromUser  string    `json:"fromUser"`
+	ToUser    string    `json:"toUser"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ChangesetStats is the mapping impact of a project based on the changesets linked to its tasks. Changesets linked to
+// several tasks are only counted once.
+type ChangesetStats struct {
+	ProjectId         string `json:"projectId"`
+	Changesets        int    `json:"changesets"`
+	ChangedObjects    int    `json:"changedObjects"`    // Created, modified and deleted objects of all changesets
+	Contributors      int    `json:"contributors"`      // Distinct OSM users who uploaded the changesets
+	PendingChangesets int    `json:"pendingChangesets"` // Changesets not loaded from the OSM API yet, they're not part of the other numbers except "Changesets"
+}
+
+// AreaStats is the coverage of a project: The size of all tasks compared to the size of the completed ones. Lines and
+// points have no area, so the perimeter (length of lines) is given as well.
+type AreaStats struct {
+	ProjectId       string           `json:"projectId"`
+	TotalArea       float64          `json:"totalArea"`       // km² of all tasks
+	MappedArea      float64          `json:"mappedArea"`      // km² of the completed tasks
+	TotalPerimeter  float64          `json:"totalPerimeter"`  // km of all tasks
+	MappedPerimeter float64          `json:"mappedPerimeter"` // km of the completed tasks
+	Users           []*UserAreaStats `json:"users"`           // Users who completed tasks, the largest mapped area first
+}
+
+// UserAreaStats is the size of the tasks one user completed. A completed task is attributed to the user who set the
+// process points to the maximum the last time.
+type UserAreaStats struct {
+	UserId          string  `json:"userId"`
+	CompletedTasks  int     `json:"completedTasks"`
+	MappedArea      float64 `json:"mappedArea"`      // km²
+	MappedPerimeter float64 `json:"mappedPerimeter"` // km
+}
+
+// MemberActivity summarizes the work of one user on the tasks of a project based on the task history.
+type MemberActivity struct {
+	UserId         string
+	LastActivity   time.Time // Time of the latest history entry of the user
+	Tasks          int       // Number of tasks the user has history entries for
+	ProcessPoints  int       // Sum of the process point changes made by the user
+	CompletedTasks int       // Number of tasks the user completed, see "UserAreaStats"
+}
+
+// OsmNote is an OSM note linked to a task, e.g. about an issue the mapper couldn't solve on their own. Other than the
+// "TaskNote" of the owner, OSM notes are public and can be solved by every OSM user.
+type OsmNote struct {
+	NoteId   string    `json:"noteId"`
+	Open     bool      `json:"open"` // State of the note according to the last request to the OSM API
+	LinkedBy string    `json:"linkedBy"`
+	LinkedAt time.Time `json:"linkedAt"`
+}
+
+// TimelineEvent is one entry of the task history within the timeline of a project.
+type TimelineEvent struct {
+	TaskId            string    `json:"taskId"`
+	UserId            string    `json:"userId"`
+	Type              string    `json:"type"`              // One of the "task.History..." values
+	ProcessPoints     int       `json:"processPoints"`     // Process points of the task after this event
+	PointsDelta       int       `json:"pointsDelta"`       // Change of the process points caused by this event
+	Comment           string    `json:"comment"`           // E.g. the reason for reopening the task, empty for most events
+	DoneProcessPoints int       `json:"doneProcessPoints"` // Sum of all point changes of the project up to this event
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+// TaskFlag marks a task which couldn't be completed. Flagged tasks can't be assigned until the owner resolves the flag.
+type TaskFlag struct {
+	Reason    string    `json:"reason"` // One of the "task.FlagReason..." values
+	Comment   string    `json:"comment"`
+	UserId    string    `json:"userId"` // User who flagged the task
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TaskReservation shows that a user opened the task in an editor recently. Other than the assignment, it's only a hint
+// for others and ends automatically.
+type TaskReservation struct {
+	UserId    string    `json:"userId"`
+	Editor    string    `json:"editor"` // One of the "osm.Editor..." values
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,17 @@
+package storage
+
+// UserStore persists data belonging to single users instead of projects or tasks, currently their quotas. It's used by
+// the "quota" package.
+type UserStore interface {
+	GetQuota(userId string) (*Quota, error)
+	SetQuota(userId string, quota *Quota, adminId string) error
+	RemoveQuota(userId string) error
+	GetUsage(userId string) (int, int, error)
+}
+
+// Quota limits how much a user can create on this instance. All limits are per user, 0 means no limit.
+type Quota struct {
+	MaxOwnedProjects   int `json:"maxOwnedProjects" validate:"min=0"`
+	MaxTasksPerProject int `json:"maxTasksPerProject" validate:"min=0"`
+	MaxTotalTasks      int `json:"maxTotalTasks" validate:"min=0"` // Sum of the tasks of all projects owned by the user
+}
@@ -0,0 +1,25 @@
+package task
+
+// GetMemberActivities returns the activity of all users who worked on tasks of the project (user ID -> activity), also
+// of users who aren't members anymore. There's no permission check, the caller has to verify that the requesting user
+// is allowed to see this.
+func (s *TaskService) GetMemberActivities(projectId string) (map[string]*MemberActivity, error) {
+	activities, err := s.store.GetMemberActivities(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	areaStats, err := s.store.GetAreaStats(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, userStats := range areaStats.Users {
+		activity, ok := activities[userStats.UserId]
+		if ok {
+			activity.CompletedTasks = userStats.CompletedTasks
+		}
+	}
+
+	return activities, nil
+}
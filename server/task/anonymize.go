@@ -0,0 +1,59 @@
+package task
+
+import (
+	"github.com/hauke96/simple-task-manager/server/privacy"
+)
+
+// showRealNames returns false when the anonymization is enabled and the user is not the owner of the project, so the
+// user IDs in statistics and exports have to be replaced by pseudonyms.
+func (s *TaskService) showRealNames(projectId string, requestingUserId string) (bool, error) {
+	if !privacy.Enabled() {
+		return true, nil
+	}
+
+	return s.permissionService.IsOwner(projectId, requestingUserId)
+}
+
+// anonymizeTasks replaces all user IDs of the tasks by their pseudonyms, see "privacy.Pseudonym".
+func anonymizeTasks(tasks []*Task) {
+	for _, t := range tasks {
+		t.AssignedUser = privacy.Pseudonym(t.AssignedUser)
+		t.AllowedUsers = privacy.Pseudonyms(t.AllowedUsers)
+		if t.Flag != nil {
+			t.Flag.UserId = privacy.Pseudonym(t.Flag.UserId)
+		}
+		if t.Reservation != nil {
+			t.Reservation.UserId = privacy.Pseudonym(t.Reservation.UserId)
+		}
+	}
+}
+
+// anonymizeSharedTasks removes the user IDs from tasks viewed via share link, since the viewers are not members of the
+// project. The allowed users are removed completely, all other user IDs are replaced by pseudonyms when the
+// anonymization is enabled and removed otherwise.
+func anonymizeSharedTasks(tasks []*Task) {
+	anonymize := func(userId string) string {
+		if !privacy.Enabled() {
+			return ""
+		}
+		return privacy.Pseudonym(userId)
+	}
+
+	for _, t := range tasks {
+		t.AssignedUser = anonymize(t.AssignedUser)
+		t.AllowedUsers = []string{}
+		if t.Flag != nil {
+			t.Flag.UserId = anonymize(t.Flag.UserId)
+		}
+		if t.Reservation != nil {
+			t.Reservation.UserId = anonymize(t.Reservation.UserId)
+		}
+	}
+}
+
+// anonymizeTimeline replaces the user IDs of the events by their pseudonyms, see "privacy.Pseudonym".
+func anonymizeTimeline(events []*TimelineEvent) {
+	for _, e := range events {
+		e.UserId = privacy.Pseudonym(e.UserId)
+	}
+}
@@ -0,0 +1,12 @@
+package task
+
+// GetAreaStats returns the area statistics of the project, see "AreaStats". Only the owner of the project is allowed to
+// do this.
+func (s *TaskService) GetAreaStats(projectId string, requestingUserId string) (*AreaStats, error) {
+	err := s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.GetAreaStats(projectId)
+}
@@ -0,0 +1,92 @@
+package task
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+
+	"github.com/hauke96/simple-task-manager/server/osm"
+	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Maximum number of changesets loaded from the OSM API in one run of the job, so that a run doesn't take ages after
+// many changesets have been linked.
+const changesetUpdateBatchSize = 100
+
+var changesetIdRegex = regexp.MustCompile(`^[1-9][0-9]*$`)
+
+// UpdateChangesetsJob is a job for the scheduler, which loads the metadata of new and still open changesets from the OSM
+// API.
+func UpdateChangesetsJob(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	return Init(ctx, tx, logger, permission.Init(ctx, tx, logger)).UpdateChangesets()
+}
+
+// LinkChangeset links the changeset to the task, e.g. after the user uploaded it with the editor. Any member of the
+// project is allowed to do this. The changeset is loaded from the OSM API later by "UpdateChangesets".
+func (s *TaskService) LinkChangeset(taskId string, changesetId string, requestingUserId string) error {
+	if !changesetIdRegex.MatchString(changesetId) {
+		return errors.New(fmt.Sprintf("invalid changeset ID '%s'", changesetId))
+	}
+
+	err := s.permissionService.VerifyMembershipTask(taskId, requestingUserId)
+	if err != nil {
+		return err
+	}
+
+	err = s.permissionService.VerifyNotArchivedTask(taskId)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.LinkChangeset(taskId, changesetId, requestingUserId)
+	if err != nil {
+		return err
+	}
+	s.Log("Linked changeset %s to task %s", changesetId, taskId)
+
+	return nil
+}
+
+// GetChangesetStats returns the statistics of the changesets linked to the tasks of the project. Only the owner of the
+// project is allowed to do this.
+func (s *TaskService) GetChangesetStats(projectId string, requestingUserId string) (*ChangesetStats, error) {
+	err := s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.GetChangesetStats(projectId)
+}
+
+// UpdateChangesets loads the metadata (user and number of changes) of linked changesets, which haven't been loaded yet
+// or were still open the last time. Changesets failing to load are logged and tried again in the next run.
+func (s *TaskService) UpdateChangesets() error {
+	changesetIds, err := s.store.GetChangesetsToUpdate(changesetUpdateBatchSize)
+	if err != nil {
+		return err
+	}
+
+	updated := 0
+	for _, changesetId := range changesetIds {
+		changeset, err := osm.GetChangeset(s.Logger, changesetId)
+		if err != nil {
+			s.Err("Unable to load changeset %s: %s", changesetId, err.Error())
+			continue
+		}
+
+		err = s.store.UpdateChangeset(changeset)
+		if err != nil {
+			return err
+		}
+		updated++
+	}
+
+	if len(changesetIds) != 0 {
+		s.Log("Updated %d of %d linked changesets", updated, len(changesetIds))
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package task
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	maxChecklistItems      = 50
+	maxChecklistItemLength = 500
+)
+
+// PrepareChecklist verifies the new checklist of a project and returns it with IDs for the new items (the ones
+// without ID). All other IDs must belong to items of the current checklist, so that the completed items of the tasks
+// keep their meaning.
+func PrepareChecklist(currentItems Checklist, newItems Checklist) (Checklist, error) {
+	if len(newItems) > maxChecklistItems {
+		return nil, errors.New(fmt.Sprintf("A checklist can have at most %d items", maxChecklistItems))
+	}
+
+	currentIds := make(map[string]bool)
+	nextId := 1
+	for _, item := range currentItems {
+		currentIds[item.Id] = true
+		if id, err := strconv.Atoi(item.Id); err == nil && id >= nextId {
+			nextId = id + 1
+		}
+	}
+
+	usedIds := make(map[string]bool)
+	result := make(Checklist, len(newItems))
+	for i, item := range newItems {
+		if item == nil {
+			return nil, errors.New("Checklist item must not be empty")
+		}
+
+		text := strings.TrimSpace(item.Text)
+		if text == "" || len(text) > maxChecklistItemLength {
+			return nil, errors.New(fmt.Sprintf("Text of checklist items must have 1 to %d characters", maxChecklistItemLength))
+		}
+
+		id := item.Id
+		if id == "" {
+			id = strconv.Itoa(nextId)
+			nextId++
+		} else if !currentIds[id] {
+			return nil, errors.New(fmt.Sprintf("Checklist item %s doesn't exist, new items must not have an ID", id))
+		}
+
+		if usedIds[id] {
+			return nil, errors.New(fmt.Sprintf("Checklist item %s is used twice", id))
+		}
+		usedIds[id] = true
+
+		result[i] = &ChecklistItem{
+			Id:   id,
+			Text: text,
+		}
+	}
+
+	return result, nil
+}
+
+// SetChecklistDone replaces the completed checklist items of the task. The same users as for setting the process points
+// are allowed to do this. When the project derives the process points from the checklist, they are set to the ratio of
+// completed items (all items completed means the maximum process points).
+func (s *TaskService) SetChecklistDone(taskId string, itemIds []string, requestingUserId string) (*Task, error) {
+	err := s.verifyProgressAllowed(taskId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	checklist, derivePoints, err := s.store.GetChecklist(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the order of the checklist, so that the result doesn't depend on the order of the given IDs
+	completedIds := make(map[string]bool)
+	for _, id := range itemIds {
+		if !containsItem(checklist, id) {
+			return nil, errors.New(fmt.Sprintf("checklist item %s doesn't exist", id))
+		}
+		completedIds[id] = true
+	}
+
+	doneItems := make([]string, 0, len(completedIds))
+	for _, item := range checklist {
+		if completedIds[item.Id] {
+			doneItems = append(doneItems, item.Id)
+		}
+	}
+
+	task, err := s.store.SetChecklistDone(taskId, doneItems)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Set %d of %d checklist items of task %s as completed", len(doneItems), len(checklist), taskId)
+
+	if !derivePoints || len(checklist) == 0 {
+		return task, nil
+	}
+
+	oldPoints := task.ProcessPoints
+	newPoints := task.MaxProcessPoints * len(doneItems) / len(checklist)
+	if newPoints == oldPoints {
+		return task, nil
+	}
+
+	task, err = s.store.SetProcessPoints(taskId, newPoints)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Set process points of task %s to %d according to its checklist", taskId, newPoints)
+
+	err = s.store.AddHistoryEntry(taskId, requestingUserId, HistoryProcessPointsSet, newPoints, newPoints-oldPoints)
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+func containsItem(c Checklist, id string) bool {
+	for _, item := range c {
+		if item.Id == id {
+			return true
+		}
+	}
+	return false
+}
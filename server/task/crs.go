@@ -0,0 +1,254 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+// Coordinate reference systems task geometries can be sent in. Geometries in other systems than WGS84 are reprojected
+// to WGS84 before they're stored.
+const (
+	CrsWgs84       = "EPSG:4326"
+	CrsWebMercator = "EPSG:3857"
+	CrsUtmNorth    = "EPSG:326xx" // WGS84 / UTM zones 1N to 60N (EPSG:32601 to EPSG:32660)
+	CrsUtmSouth    = "EPSG:327xx" // WGS84 / UTM zones 1S to 60S (EPSG:32701 to EPSG:32760)
+)
+
+// Parameters of the WGS84 ellipsoid and the projections based on it
+const (
+	webMercatorRadius     = 6378137.0
+	wgs84SemiMajorAxis    = 6378137.0
+	wgs84Flattening       = 1 / 298.257223563
+	utmScaleFactor        = 0.9996
+	utmFalseEasting       = 500000.0
+	utmFalseNorthingSouth = 10000000.0
+)
+
+// Other codes of the web mercator projection, which are still used by some GIS exports
+var webMercatorAliases = map[int]bool{3857: true, 900913: true, 3785: true, 102100: true, 102113: true}
+
+// Matches "EPSG:3857", "urn:ogc:def:crs:EPSG::3857", "urn:ogc:def:crs:EPSG:6.6:3857" and just "3857"
+var epsgCodeRegex = regexp.MustCompile(`(?i)^(?:(?:urn:ogc:def:crs:)?epsg:(?:[0-9.]*:)?)?([0-9]+)$`)
+
+// GetSupportedCrs returns all coordinate reference systems task geometries can be sent in.
+func GetSupportedCrs() []string {
+	return []string{CrsWgs84, CrsWebMercator, CrsUtmNorth, CrsUtmSouth}
+}
+
+// ReprojectGeometry reprojects the coordinates of the GeoJSON feature from the given coordinate reference system (like
+// "EPSG:3857") to WGS84. Without "crs", the "crs" member of the feature (as written by many GIS exports) is used.
+// Features without any CRS are returned unchanged, the "crs" member is removed from all others.
+func ReprojectGeometry(geometry string, crs string) (string, error) {
+	if crs == "" && !strings.Contains(geometry, `"crs"`) {
+		return geometry, nil
+	}
+
+	feature, err := geojson.UnmarshalFeature([]byte(geometry))
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("invalid GeoJSON: %s", geometry))
+	}
+
+	if crs == "" {
+		crs, err = crsName(feature.CRS)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	toWgs84, err := getProjection(crs)
+	if err != nil {
+		return "", err
+	}
+
+	if feature.Geometry != nil {
+		err = reprojectGeometry(feature.Geometry, toWgs84)
+		if err != nil {
+			return "", errors.Wrap(err, fmt.Sprintf("error reprojecting geometry from %s", crs))
+		}
+	}
+	feature.CRS = nil
+
+	featureBytes, err := json.Marshal(feature)
+	if err != nil {
+		return "", errors.Wrap(err, "error marshalling reprojected feature")
+	}
+
+	return string(featureBytes), nil
+}
+
+// crsName returns the name of a named CRS object like {"type": "name", "properties": {"name": "EPSG:3857"}}.
+func crsName(crs map[string]interface{}) (string, error) {
+	properties, ok := crs["properties"].(map[string]interface{})
+	if !ok || crs["type"] != "name" {
+		return "", errors.New(fmt.Sprintf("only named CRS objects are supported: %v", crs))
+	}
+
+	name, ok := properties["name"].(string)
+	if !ok || name == "" {
+		return "", errors.New(fmt.Sprintf("CRS object has no name: %v", crs))
+	}
+
+	return name, nil
+}
+
+// projection converts a coordinate of a projected CRS into longitude and latitude.
+type projection func(x float64, y float64) (float64, float64)
+
+// getProjection returns the conversion of the given CRS to WGS84.
+func getProjection(crs string) (projection, error) {
+	crs = strings.TrimSpace(crs)
+
+	// The "CRS84" is WGS84 with the usual longitude/latitude order of GeoJSON
+	if strings.HasSuffix(strings.ToUpper(crs), "CRS84") {
+		return nil, nil
+	}
+
+	match := epsgCodeRegex.FindStringSubmatch(crs)
+	if match == nil {
+		return nil, errors.New(fmt.Sprintf("unknown coordinate reference system '%s', supported are %v", crs, GetSupportedCrs()))
+	}
+	code, err := strconv.Atoi(match[1])
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("invalid EPSG code in '%s'", crs))
+	}
+
+	switch {
+	case code == 4326:
+		return nil, nil
+	case webMercatorAliases[code]:
+		return webMercatorToWgs84, nil
+	case code >= 32601 && code <= 32660:
+		return utmToWgs84(code-32600, false), nil
+	case code >= 32701 && code <= 32760:
+		return utmToWgs84(code-32700, true), nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("unsupported coordinate reference system '%s', supported are %v", crs, GetSupportedCrs()))
+}
+
+// reprojectGeometry converts all coordinates of the geometry (and of all geometries of a collection) in place. A "nil"
+// projection leaves the coordinates unchanged.
+func reprojectGeometry(g *geojson.Geometry, toWgs84 projection) error {
+	if toWgs84 == nil {
+		return nil
+	}
+
+	switch g.Type {
+	case geojson.GeometryPoint:
+		return reprojectCoordinates([][]float64{g.Point}, toWgs84)
+	case geojson.GeometryMultiPoint:
+		return reprojectCoordinates(g.MultiPoint, toWgs84)
+	case geojson.GeometryLineString:
+		return reprojectCoordinates(g.LineString, toWgs84)
+	case geojson.GeometryMultiLineString:
+		for _, line := range g.MultiLineString {
+			err := reprojectCoordinates(line, toWgs84)
+			if err != nil {
+				return err
+			}
+		}
+	case geojson.GeometryPolygon:
+		for _, ring := range g.Polygon {
+			err := reprojectCoordinates(ring, toWgs84)
+			if err != nil {
+				return err
+			}
+		}
+	case geojson.GeometryMultiPolygon:
+		for _, polygon := range g.MultiPolygon {
+			for _, ring := range polygon {
+				err := reprojectCoordinates(ring, toWgs84)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	case geojson.GeometryCollection:
+		for _, geometry := range g.Geometries {
+			err := reprojectGeometry(geometry, toWgs84)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// reprojectCoordinates converts the first two values of each coordinate, further values (like the height) are kept.
+// Coordinates outside of the valid longitude and latitude ranges are rejected, since they indicate a wrong CRS.
+func reprojectCoordinates(coordinates [][]float64, toWgs84 projection) error {
+	for _, c := range coordinates {
+		if len(c) < 2 {
+			return errors.New(fmt.Sprintf("coordinate %v needs at least two values", c))
+		}
+
+		lon, lat := toWgs84(c[0], c[1])
+		if math.IsNaN(lon) || math.IsNaN(lat) || lon < -180 || lon > 180 || lat < -90 || lat > 90 {
+			return errors.New(fmt.Sprintf("coordinate %v is outside of the valid area of the coordinate reference system", c))
+		}
+
+		c[0] = lon
+		c[1] = lat
+	}
+
+	return nil
+}
+
+func webMercatorToWgs84(x float64, y float64) (float64, float64) {
+	lon := x / webMercatorRadius * 180 / math.Pi
+	lat := (2*math.Atan(math.Exp(y/webMercatorRadius)) - math.Pi/2) * 180 / math.Pi
+	return lon, lat
+}
+
+// utmToWgs84 returns the inverse transverse mercator projection of the given UTM zone (see Snyder, "Map Projections - A
+// Working Manual", p. 63f), which is accurate to far below a meter within the zone.
+func utmToWgs84(zone int, south bool) projection {
+	centralMeridian := float64(zone-1)*6 - 180 + 3
+
+	e2 := wgs84Flattening * (2 - wgs84Flattening)
+	ep2 := e2 / (1 - e2)
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+
+	return func(easting float64, northing float64) (float64, float64) {
+		x := easting - utmFalseEasting
+		y := northing
+		if south {
+			y -= utmFalseNorthingSouth
+		}
+
+		m := y / utmScaleFactor
+		mu := m / (wgs84SemiMajorAxis * (1 - e2/4 - 3*e2*e2/64 - 5*e2*e2*e2/256))
+
+		phi1 := mu +
+			(3*e1/2-27*math.Pow(e1, 3)/32)*math.Sin(2*mu) +
+			(21*e1*e1/16-55*math.Pow(e1, 4)/32)*math.Sin(4*mu) +
+			(151*math.Pow(e1, 3)/96)*math.Sin(6*mu) +
+			(1097*math.Pow(e1, 4)/512)*math.Sin(8*mu)
+
+		sinPhi1 := math.Sin(phi1)
+		cosPhi1 := math.Cos(phi1)
+		tanPhi1 := math.Tan(phi1)
+
+		n1 := wgs84SemiMajorAxis / math.Sqrt(1-e2*sinPhi1*sinPhi1)
+		t1 := tanPhi1 * tanPhi1
+		c1 := ep2 * cosPhi1 * cosPhi1
+		r1 := wgs84SemiMajorAxis * (1 - e2) / math.Pow(1-e2*sinPhi1*sinPhi1, 1.5)
+		d := x / (n1 * utmScaleFactor)
+
+		lat := phi1 - (n1*tanPhi1/r1)*(d*d/2-
+			(5+3*t1+10*c1-4*c1*c1-9*ep2)*math.Pow(d, 4)/24+
+			(61+90*t1+298*c1+45*t1*t1-252*ep2-3*c1*c1)*math.Pow(d, 6)/720)
+		lon := (d - (1+2*t1+c1)*math.Pow(d, 3)/6 +
+			(5-2*c1+28*t1-3*c1*c1+8*ep2+24*t1*t1)*math.Pow(d, 5)/120) / cosPhi1
+
+		return centralMeridian + lon*180/math.Pi, lat * 180 / math.Pi
+	}
+}
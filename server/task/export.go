@@ -0,0 +1,458 @@
+package task
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"time"
+
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+const (
+	ExportFormatGpx     = "gpx"
+	ExportFormatOsm     = "osm"
+	ExportFormatGeojson = "geojson"
+	ExportFormatCsv     = "csv"
+	ExportFormatHistory = "history" // CSV file of the timeline
+)
+
+var (
+	exportContentTypes = map[string]string{
+		ExportFormatGpx:     "application/gpx+xml",
+		ExportFormatOsm:     "application/x-osm+xml",
+		ExportFormatGeojson: "application/geo+json",
+		ExportFormatCsv:     "text/csv",
+		ExportFormatHistory: "text/csv",
+	}
+)
+
+type gpx struct {
+	XMLName   xml.Name      `xml:"gpx"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Xmlns     string        `xml:"xmlns,attr"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+	Tracks    []gpxTrack    `xml:"trk"`
+}
+
+type gpxWaypoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Name string  `xml:"name"`
+}
+
+type gpxTrack struct {
+	Name     string            `xml:"name"`
+	Segments []gpxTrackSegment `xml:"trkseg"`
+}
+
+type gpxTrackSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+type osmXml struct {
+	XMLName   xml.Name      `xml:"osm"`
+	Version   string        `xml:"version,attr"`
+	Generator string        `xml:"generator,attr"`
+	Upload    string        `xml:"upload,attr"`
+	Nodes     []osmNode     `xml:"node"`
+	Ways      []osmWay      `xml:"way"`
+	Relations []osmRelation `xml:"relation"`
+}
+
+type osmNode struct {
+	Id   int64    `xml:"id,attr"`
+	Lat  float64  `xml:"lat,attr"`
+	Lon  float64  `xml:"lon,attr"`
+	Tags []osmTag `xml:"tag"`
+}
+
+type osmWay struct {
+	Id       int64        `xml:"id,attr"`
+	NodeRefs []osmNodeRef `xml:"nd"`
+	Tags     []osmTag     `xml:"tag"`
+}
+
+type osmNodeRef struct {
+	Ref int64 `xml:"ref,attr"`
+}
+
+type osmRelation struct {
+	Id      int64       `xml:"id,attr"`
+	Members []osmMember `xml:"member"`
+	Tags    []osmTag    `xml:"tag"`
+}
+
+type osmMember struct {
+	Type string `xml:"type,attr"`
+	Ref  int64  `xml:"ref,attr"`
+	Role string `xml:"role,attr"`
+}
+
+type osmTag struct {
+	Key   string `xml:"k,attr"`
+	Value string `xml:"v,attr"`
+}
+
+// FilterDone returns all tasks which are done.
+func FilterDone(tasks []*Task) []*Task {
+	result := make([]*Task, 0)
+
+	for _, t := range tasks {
+		if t.IsDone() {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
+
+// ExportDoneTasks checks the membership of the requesting user and converts the outlines of all done tasks of the
+// project into the given format.
+func (s *TaskService) ExportDoneTasks(projectId string, format string, requestingUserId string) ([]byte, error) {
+	tasks, err := s.GetTasks(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	doneTasks := FilterDone(tasks)
+
+	var result []byte
+	switch format {
+	case ExportFormatGpx:
+		result, err = ExportGpx(doneTasks)
+	case ExportFormatOsm:
+		result, err = ExportOsm(doneTasks)
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown export format '%s'", format))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.Log("Exported %d done tasks of project %s as %s", len(doneTasks), projectId, format)
+
+	return result, nil
+}
+
+// IsValidExportFormat returns true for all formats supported by "Export".
+func IsValidExportFormat(format string) bool {
+	_, ok := exportContentTypes[format]
+	return ok
+}
+
+// ExportContentType returns the MIME type of files in the given format.
+func ExportContentType(format string) string {
+	return exportContentTypes[format]
+}
+
+// ExportFileName returns the name of the exported file, e.g. "project-1.gpx" or "project-1-history.csv".
+func ExportFileName(projectId string, format string) string {
+	if format == ExportFormatHistory {
+		return fmt.Sprintf("project-%s-history.csv", projectId)
+	}
+	return fmt.Sprintf("project-%s.%s", projectId, format)
+}
+
+// Export checks the membership of the requesting user and exports the project in the given format. The GPX and OSM
+// formats only contain the done tasks (see "ExportDoneTasks"), the GeoJSON and CSV formats contain all tasks and the
+// history format contains the timeline of the project. The user IDs are anonymized like in "GetTimeline".
+func (s *TaskService) Export(projectId string, format string, requestingUserId string) ([]byte, error) {
+	switch format {
+	case ExportFormatGpx, ExportFormatOsm:
+		return s.ExportDoneTasks(projectId, format, requestingUserId)
+	case ExportFormatHistory:
+		events, err := s.GetTimeline(projectId, requestingUserId)
+		if err != nil {
+			return nil, err
+		}
+		return ExportHistoryCsv(events)
+	}
+
+	tasks, err := s.GetTasks(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	showRealNames, err := s.showRealNames(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+	if !showRealNames {
+		anonymizeTasks(tasks)
+	}
+
+	var result []byte
+	switch format {
+	case ExportFormatGeojson:
+		result, err = ExportGeojson(tasks)
+	case ExportFormatCsv:
+		result, err = ExportCsv(tasks)
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown export format '%s'", format))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.Log("Exported %d tasks of project %s as %s", len(tasks), projectId, format)
+
+	return result, nil
+}
+
+// ExportGeojson combines the tasks into one feature collection. The properties of each feature contain the state of
+// the task in addition to the original properties.
+func ExportGeojson(tasks []*Task) ([]byte, error) {
+	collection := geojson.NewFeatureCollection()
+
+	for _, t := range tasks {
+		feature, err := geojson.UnmarshalFeature([]byte(t.Geometry))
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid GeoJSON of task %s", t.Id))
+		}
+
+		feature.SetProperty("stm:task_id", t.Id)
+		feature.SetProperty("stm:process_points", t.ProcessPoints)
+		feature.SetProperty("stm:max_process_points", t.MaxProcessPoints)
+		feature.SetProperty("stm:assigned_user", t.AssignedUser)
+		feature.SetProperty("stm:difficulty", t.Difficulty)
+
+		collection.AddFeature(feature)
+	}
+
+	result, err := collection.MarshalJSON()
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling feature collection")
+	}
+
+	return result, nil
+}
+
+// ExportCsv creates a CSV file with one line per task containing its state but not its geometry.
+func ExportCsv(tasks []*Task) ([]byte, error) {
+	lines := [][]string{{"id", "process_points", "max_process_points", "assigned_user", "difficulty", "updated_at"}}
+
+	for _, t := range tasks {
+		lines = append(lines, []string{t.Id, strconv.Itoa(t.ProcessPoints), strconv.Itoa(t.MaxProcessPoints), t.AssignedUser, t.Difficulty, t.UpdatedAt.Format(time.RFC3339)})
+	}
+
+	return marshalCsv(lines)
+}
+
+// ExportHistoryCsv creates a CSV file with one line per timeline event.
+func ExportHistoryCsv(events []*TimelineEvent) ([]byte, error) {
+	lines := [][]string{{"task_id", "user_id", "type", "process_points", "points_delta", "done_process_points", "created_at", "comment"}}
+
+	for _, e := range events {
+		lines = append(lines, []string{e.TaskId, e.UserId, e.Type, strconv.Itoa(e.ProcessPoints), strconv.Itoa(e.PointsDelta), strconv.Itoa(e.DoneProcessPoints), e.CreatedAt.Format(time.RFC3339), e.Comment})
+	}
+
+	return marshalCsv(lines)
+}
+
+// ExportGpx converts the outlines of the tasks into a GPX file with one track per task and one track segment per ring.
+// Lines become tracks with one segment and points become waypoints.
+func ExportGpx(tasks []*Task) ([]byte, error) {
+	result := gpx{
+		Version:   "1.1",
+		Creator:   "simple-task-manager",
+		Xmlns:     "http://www.topografix.com/GPX/1/1",
+		Waypoints: make([]gpxWaypoint, 0),
+		Tracks:    make([]gpxTrack, 0),
+	}
+
+	for _, t := range tasks {
+		geometry, name, err := exportGeometry(t)
+		if err != nil {
+			return nil, err
+		}
+
+		if geometry.Type == geojson.GeometryPoint {
+			result.Waypoints = append(result.Waypoints, gpxWaypoint{Lat: geometry.Point[1], Lon: geometry.Point[0], Name: name})
+			continue
+		}
+
+		track := gpxTrack{
+			Name: name,
+		}
+
+		for _, ring := range geometryCoordinates(geometry) {
+			segment := gpxTrackSegment{}
+			for _, point := range ring {
+				segment.Points = append(segment.Points, gpxPoint{Lat: point[1], Lon: point[0]})
+			}
+			track.Segments = append(track.Segments, segment)
+		}
+
+		result.Tracks = append(result.Tracks, track)
+	}
+
+	return marshalXml(result)
+}
+
+// ExportOsm converts the outlines of the tasks into an OSM XML file. Each ring becomes a closed way, tasks with holes
+// become multipolygon relations. Lines become (not closed) ways and points become nodes. All objects have negative IDs
+// and the file is marked as not uploadable, so it can only be used as reference layer.
+func ExportOsm(tasks []*Task) ([]byte, error) {
+	result := osmXml{
+		Version:   "0.6",
+		Generator: "simple-task-manager",
+		Upload:    "false",
+	}
+
+	var lastId int64 = 0
+	nextId := func() int64 {
+		lastId--
+		return lastId
+	}
+
+	for _, t := range tasks {
+		geometry, name, err := exportGeometry(t)
+		if err != nil {
+			return nil, err
+		}
+
+		tags := []osmTag{
+			{Key: "name", Value: name},
+			{Key: "stm:task_id", Value: t.Id},
+			{Key: "stm:process_points", Value: fmt.Sprintf("%d/%d", t.ProcessPoints, t.MaxProcessPoints)},
+		}
+
+		switch geometry.Type {
+		case geojson.GeometryPoint:
+			result.Nodes = append(result.Nodes, osmNode{Id: nextId(), Lat: geometry.Point[1], Lon: geometry.Point[0], Tags: tags})
+			continue
+		case geojson.GeometryLineString:
+			way := osmWay{
+				Id:   nextId(),
+				Tags: tags,
+			}
+			for _, point := range geometry.LineString {
+				node := osmNode{Id: nextId(), Lat: point[1], Lon: point[0]}
+				result.Nodes = append(result.Nodes, node)
+				way.NodeRefs = append(way.NodeRefs, osmNodeRef{Ref: node.Id})
+			}
+			result.Ways = append(result.Ways, way)
+			continue
+		}
+
+		rings := geometry.Polygon
+
+		wayIds := make([]int64, 0)
+		for _, ring := range rings {
+			way := osmWay{
+				Id: nextId(),
+			}
+
+			// The last point of a GeoJSON ring equals the first one, in OSM the way just references the first node again
+			var firstNodeId int64
+			for i, point := range ring {
+				if i == len(ring)-1 && i > 0 {
+					way.NodeRefs = append(way.NodeRefs, osmNodeRef{Ref: firstNodeId})
+					break
+				}
+
+				node := osmNode{Id: nextId(), Lat: point[1], Lon: point[0]}
+				if i == 0 {
+					firstNodeId = node.Id
+				}
+
+				result.Nodes = append(result.Nodes, node)
+				way.NodeRefs = append(way.NodeRefs, osmNodeRef{Ref: node.Id})
+			}
+
+			result.Ways = append(result.Ways, way)
+			wayIds = append(wayIds, way.Id)
+		}
+
+		if len(wayIds) == 1 {
+			result.Ways[len(result.Ways)-1].Tags = append(tags, osmTag{Key: "area", Value: "yes"})
+			continue
+		}
+
+		relation := osmRelation{
+			Id:   nextId(),
+			Tags: append(tags, osmTag{Key: "type", Value: "multipolygon"}),
+		}
+		for i, wayId := range wayIds {
+			role := "inner"
+			if i == 0 {
+				role = "outer"
+			}
+			relation.Members = append(relation.Members, osmMember{Type: "way", Ref: wayId, Role: role})
+		}
+
+		result.Relations = append(result.Relations, relation)
+	}
+
+	return marshalXml(result)
+}
+
+// exportGeometry returns the geometry (polygon, line string or point) of the task and its name. Tasks without name in
+// their properties are named after their ID.
+func exportGeometry(t *Task) (*geojson.Geometry, string, error) {
+	feature, err := geojson.UnmarshalFeature([]byte(t.Geometry))
+	if err != nil {
+		return nil, "", errors.Wrap(err, fmt.Sprintf("invalid GeoJSON of task %s", t.Id))
+	}
+
+	if feature.Geometry == nil || !IsValidGeometryType(string(feature.Geometry.Type)) {
+		return nil, "", errors.New(fmt.Sprintf("geometry of task %s is neither a polygon, a line string nor a point", t.Id))
+	}
+
+	name := "Task " + t.Id
+	if featureName, ok := feature.Properties["name"]; ok && featureName != nil {
+		switch n := featureName.(type) {
+		case string:
+			if n != "" {
+				name = n
+			}
+		case float64:
+			name = strconv.FormatFloat(n, 'f', -1, 64)
+		}
+	}
+
+	return feature.Geometry, name, nil
+}
+
+// geometryCoordinates returns the coordinates of the geometry as list of lines: The rings of polygons, the line itself
+// or the point as line with only one point.
+func geometryCoordinates(geometry *geojson.Geometry) [][][]float64 {
+	switch geometry.Type {
+	case geojson.GeometryLineString:
+		return [][][]float64{geometry.LineString}
+	case geojson.GeometryPoint:
+		return [][][]float64{{geometry.Point}}
+	}
+	return geometry.Polygon
+}
+
+func marshalXml(data interface{}) ([]byte, error) {
+	xmlBytes, err := xml.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling XML")
+	}
+
+	return append([]byte(xml.Header), xmlBytes...), nil
+}
+
+func marshalCsv(lines [][]string) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	writer := csv.NewWriter(&buffer)
+	err := writer.WriteAll(lines)
+	if err != nil {
+		return nil, errors.Wrap(err, "error writing CSV")
+	}
+
+	return buffer.Bytes(), nil
+}
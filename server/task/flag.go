@@ -0,0 +1,99 @@
+package task
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Reasons why a mapper couldn't complete a task
+const (
+	FlagReasonBadImagery = "bad_imagery"
+	FlagReasonUnmappable = "unmappable"
+	FlagReasonTooLarge   = "too_large"
+	FlagReasonOther      = "other"
+)
+
+const maxFlagCommentLength = 1000
+
+func IsValidFlagReason(reason string) bool {
+	return reason == FlagReasonBadImagery || reason == FlagReasonUnmappable || reason == FlagReasonTooLarge || reason == FlagReasonOther
+}
+
+// Flag marks the task as not completable and unassigns it. Only members of the project are allowed to do this and only
+// when the task isn't assigned to someone else.
+func (s *TaskService) Flag(taskId string, reason string, comment string, requestingUserId string) (*Task, error) {
+	if !IsValidFlagReason(reason) {
+		return nil, errors.New(fmt.Sprintf("unknown flag reason '%s'", reason))
+	}
+
+	if len(comment) > maxFlagCommentLength {
+		return nil, errors.New(fmt.Sprintf("comment too long, maximum allowed are %d characters", maxFlagCommentLength))
+	}
+
+	err := s.permissionService.VerifyNotArchivedTask(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyMembershipTask(taskId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := s.store.GetTask(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	if task.Flag != nil {
+		return nil, errors.New(fmt.Sprintf("task %s is already flagged", taskId))
+	}
+
+	assignedUser := strings.TrimSpace(task.AssignedUser)
+	if assignedUser != "" && assignedUser != requestingUserId {
+		return nil, errors.New(fmt.Sprintf("task %s is assigned to another user", taskId))
+	}
+
+	task, err = s.store.Flag(taskId, reason, comment, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Flagged task %s with reason %s", taskId, reason)
+
+	if assignedUser != "" {
+		err = s.store.AddHistoryEntry(taskId, requestingUserId, HistoryUnassigned, task.ProcessPoints, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return task, nil
+}
+
+// ResolveFlag removes the flag from the task, so that it can be assigned again. Only the owner of the project is
+// allowed to do this.
+func (s *TaskService) ResolveFlag(taskId string, requestingUserId string) (*Task, error) {
+	err := s.permissionService.VerifyOwnershipTask(taskId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := s.store.GetTask(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	if task.Flag == nil {
+		return nil, errors.New(fmt.Sprintf("task %s is not flagged", taskId))
+	}
+
+	task, err = s.store.ResolveFlag(taskId)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Resolved flag of task %s", taskId)
+
+	return task, nil
+}
@@ -0,0 +1,381 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+// Mean radius of the earth in km, the sizes of tasks are computed on a sphere with this radius (like PostGIS does for
+// geographies without spheroid).
+const earthRadius = 6371.0088
+
+// Geometry types of tasks. Projects only allow polygons unless configured otherwise.
+const (
+	GeometryTypePolygon    = "Polygon"
+	GeometryTypeLineString = "LineString"
+	GeometryTypePoint      = "Point"
+)
+
+// taskShape is the geometry of a task reduced to its type and coordinates, which is enough for the computed fields and
+// the duplicate check.
+type taskShape struct {
+	geometryType string
+	coordinates  [][]float64 // Outer ring of polygons, all points of lines and the single point of points
+}
+
+// GetGeometryTypes returns all geometry types tasks can have.
+func GetGeometryTypes() []string {
+	return []string{GeometryTypePolygon, GeometryTypeLineString, GeometryTypePoint}
+}
+
+func IsValidGeometryType(geometryType string) bool {
+	return geometryType == GeometryTypePolygon || geometryType == GeometryTypeLineString || geometryType == GeometryTypePoint
+}
+
+// parseTaskShape checks that the given GeoJSON is a feature with a polygon, line string or point and returns its shape.
+func parseTaskShape(geometry string) (*taskShape, error) {
+	feature, err := geojson.UnmarshalFeature([]byte(geometry))
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("invalid GeoJSON: %s", geometry))
+	}
+
+	if feature.Type != "Feature" || feature.Geometry == nil {
+		return nil, errors.New(fmt.Sprintf("task geometry is not a feature: %s", geometry))
+	}
+
+	shape := &taskShape{
+		geometryType: string(feature.Geometry.Type),
+	}
+
+	switch feature.Geometry.Type {
+	case geojson.GeometryPolygon:
+		if len(feature.Geometry.Polygon) != 0 {
+			shape.coordinates = feature.Geometry.Polygon[0]
+		}
+	case geojson.GeometryLineString:
+		shape.coordinates = feature.Geometry.LineString
+	case geojson.GeometryPoint:
+		if len(feature.Geometry.Point) >= 2 {
+			shape.coordinates = [][]float64{feature.Geometry.Point}
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("task geometry is neither a polygon, a line string nor a point: %s", geometry))
+	}
+
+	if len(shape.coordinates) == 0 {
+		return nil, errors.New(fmt.Sprintf("task geometry has no coordinates: %s", geometry))
+	}
+
+	return shape, nil
+}
+
+// aoiRings returns all rings of the given GeoJSON polygon or multipolygon, e.g. the AOI of a project. Other geometries
+// (like the computed AOI of projects with lines or points) and empty strings have no rings.
+func aoiRings(aoi string) ([][][]float64, error) {
+	if aoi == "" {
+		return nil, nil
+	}
+
+	geometry, err := geojson.UnmarshalGeometry([]byte(aoi))
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("invalid AOI: %s", aoi))
+	}
+
+	switch geometry.Type {
+	case geojson.GeometryPolygon:
+		return geometry.Polygon, nil
+	case geojson.GeometryMultiPolygon:
+		rings := make([][][]float64, 0)
+		for _, polygon := range geometry.MultiPolygon {
+			rings = append(rings, polygon...)
+		}
+		return rings, nil
+	}
+
+	return nil, nil
+}
+
+// centroid returns the center of mass of polygons and the mean of all points for other geometries.
+func (s *taskShape) centroid() []float64 {
+	if s.geometryType == GeometryTypePolygon {
+		return centroid(s.coordinates)
+	}
+	return meanPoint(s.coordinates)
+}
+
+// area returns the area of polygons in km² and 0 for other geometries. Like for the centroid, only the outer ring is
+// taken into account.
+func (s *taskShape) area() float64 {
+	if s.geometryType == GeometryTypePolygon {
+		return sphericalArea(s.coordinates)
+	}
+	return 0
+}
+
+// perimeter returns the length of the outer ring of polygons and the length of lines in km and 0 for points.
+func (s *taskShape) perimeter() float64 {
+	length := 0.0
+	for i := 0; i < len(s.coordinates)-1; i++ {
+		length += distance(s.coordinates[i], s.coordinates[i+1])
+	}
+	return length
+}
+
+// duplicateRatio returns how much both shapes are the same: The overlap of polygons and either 0 or 1 for other
+// geometries, depending on whether they have the same coordinates.
+func duplicateRatio(a *taskShape, b *taskShape) float64 {
+	if a.geometryType != b.geometryType {
+		return 0
+	}
+
+	if a.geometryType == GeometryTypePolygon {
+		return overlapRatio(a.coordinates, b.coordinates)
+	}
+
+	if ringsEqual(a.coordinates, b.coordinates) {
+		return 1
+	}
+	return 0
+}
+
+// simplifyGeometry applies the Douglas-Peucker algorithm to every ring of the polygon or to the line within the given
+// GeoJSON feature. The tolerance is given in the unit of the coordinates (so usually degree). Rings which would
+// degenerate (less than four points) stay as they are. Points can't be simplified and are returned unchanged.
+func simplifyGeometry(geometry string, tolerance float64) (string, error) {
+	feature, err := geojson.UnmarshalFeature([]byte(geometry))
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("invalid GeoJSON: %s", geometry))
+	}
+
+	if feature.Geometry == nil {
+		return "", errors.New("only polygons and lines can be simplified")
+	}
+
+	switch feature.Geometry.Type {
+	case geojson.GeometryPolygon:
+		for i, ring := range feature.Geometry.Polygon {
+			simplifiedRing := douglasPeucker(ring, tolerance)
+
+			// A valid linear ring has at least four points (first and last are equal)
+			if len(simplifiedRing) >= 4 {
+				feature.Geometry.Polygon[i] = simplifiedRing
+			}
+		}
+	case geojson.GeometryLineString:
+		feature.Geometry.LineString = douglasPeucker(feature.Geometry.LineString, tolerance)
+	case geojson.GeometryPoint:
+		return geometry, nil
+	default:
+		return "", errors.New("only polygons and lines can be simplified")
+	}
+
+	simplifiedBytes, err := json.Marshal(feature)
+	if err != nil {
+		return "", errors.Wrap(err, "error marshalling simplified feature")
+	}
+
+	return string(simplifiedBytes), nil
+}
+
+// douglasPeucker removes all points of the line which are closer than "tolerance" to the simplified line. The first and
+// last point are always kept.
+func douglasPeucker(points [][]float64, tolerance float64) [][]float64 {
+	if len(points) < 3 {
+		return points
+	}
+
+	first := points[0]
+	last := points[len(points)-1]
+
+	maxDistance := 0.0
+	maxIndex := 0
+	for i := 1; i < len(points)-1; i++ {
+		distance := perpendicularDistance(points[i], first, last)
+		if distance > maxDistance {
+			maxDistance = distance
+			maxIndex = i
+		}
+	}
+
+	if maxDistance <= tolerance {
+		return [][]float64{first, last}
+	}
+
+	// Simplify both parts separately and join them without duplicating the point at "maxIndex"
+	left := douglasPeucker(points[:maxIndex+1], tolerance)
+	right := douglasPeucker(points[maxIndex:], tolerance)
+
+	return append(left[:len(left)-1], right...)
+}
+
+// perpendicularDistance determines the distance of "point" to the line through "lineStart" and "lineEnd". When start
+// and end of the line are equal (e.g. for closed rings), the distance between the points is returned.
+func perpendicularDistance(point []float64, lineStart []float64, lineEnd []float64) float64 {
+	dx := lineEnd[0] - lineStart[0]
+	dy := lineEnd[1] - lineStart[1]
+
+	lineLength := math.Hypot(dx, dy)
+	if lineLength == 0 {
+		return math.Hypot(point[0]-lineStart[0], point[1]-lineStart[1])
+	}
+
+	return math.Abs(dy*point[0]-dx*point[1]+lineEnd[0]*lineStart[1]-lineEnd[1]*lineStart[0]) / lineLength
+}
+
+// boundingBox returns the extent of the given ring in the GeoJSON order "[minLon, minLat, maxLon, maxLat]".
+func boundingBox(ring [][]float64) []float64 {
+	bbox := []float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+
+	for _, p := range ring {
+		bbox[0] = math.Min(bbox[0], p[0])
+		bbox[1] = math.Min(bbox[1], p[1])
+		bbox[2] = math.Max(bbox[2], p[0])
+		bbox[3] = math.Max(bbox[3], p[1])
+	}
+
+	return bbox
+}
+
+// centroid returns the center of mass of the given closed ring. For degenerated rings without area, the mean of all
+// points is used.
+func centroid(ring [][]float64) []float64 {
+	area := 0.0
+	cx := 0.0
+	cy := 0.0
+
+	for i := 0; i < len(ring)-1; i++ {
+		cross := ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+		area += cross / 2
+		cx += (ring[i][0] + ring[i+1][0]) * cross
+		cy += (ring[i][1] + ring[i+1][1]) * cross
+	}
+
+	if area == 0 {
+		return meanPoint(ring)
+	}
+
+	return []float64{cx / (6 * area), cy / (6 * area)}
+}
+
+// meanPoint returns the mean of all given points.
+func meanPoint(points [][]float64) []float64 {
+	sumX := 0.0
+	sumY := 0.0
+	for _, p := range points {
+		sumX += p[0]
+		sumY += p[1]
+	}
+	return []float64{sumX / float64(len(points)), sumY / float64(len(points))}
+}
+
+// polygonArea returns the absolute area of the given closed ring using the shoelace formula.
+func polygonArea(ring [][]float64) float64 {
+	area := 0.0
+	for i := 0; i < len(ring)-1; i++ {
+		area += ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+	}
+	return math.Abs(area / 2)
+}
+
+// sphericalArea returns the absolute area of the given closed ring (in degree) on the earth in km². This is the
+// approximation by Chamberlain and Duquette ("Some algorithms for polygons on a sphere"), which is precise enough for
+// the size of tasks.
+func sphericalArea(ring [][]float64) float64 {
+	area := 0.0
+	for i := 0; i < len(ring)-1; i++ {
+		lon1, lat1 := toRadians(ring[i][0]), toRadians(ring[i][1])
+		lon2, lat2 := toRadians(ring[i+1][0]), toRadians(ring[i+1][1])
+		area += (lon2 - lon1) * (2 + math.Sin(lat1) + math.Sin(lat2))
+	}
+	return math.Abs(area * earthRadius * earthRadius / 2)
+}
+
+// distance returns the great-circle distance between both points (in degree) in km using the haversine formula.
+func distance(a []float64, b []float64) float64 {
+	lat1, lat2 := toRadians(a[1]), toRadians(b[1])
+	dLat := lat2 - lat1
+	dLon := toRadians(b[0] - a[0])
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func toRadians(degree float64) float64 {
+	return degree * math.Pi / 180
+}
+
+// containsPoint checks via ray casting whether the point (x, y) lies within the given closed ring.
+func containsPoint(ring [][]float64, x float64, y float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func ringsEqual(a [][]float64, b [][]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if len(a[i]) < 2 || len(b[i]) < 2 || a[i][0] != b[i][0] || a[i][1] != b[i][1] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// overlapRatio returns the intersection over union of both rings, so 0 for disjoint and 1 for identical rings. The
+// intersection area is estimated by sampling a grid of points within the intersection of both bounding boxes, which is
+// precise enough to detect (near) duplicates without a full polygon clipping implementation.
+func overlapRatio(a [][]float64, b [][]float64) float64 {
+	if ringsEqual(a, b) {
+		return 1
+	}
+
+	areaA := polygonArea(a)
+	areaB := polygonArea(b)
+	if areaA == 0 || areaB == 0 {
+		return 0
+	}
+
+	bboxA := boundingBox(a)
+	bboxB := boundingBox(b)
+	minX := math.Max(bboxA[0], bboxB[0])
+	minY := math.Max(bboxA[1], bboxB[1])
+	maxX := math.Min(bboxA[2], bboxB[2])
+	maxY := math.Min(bboxA[3], bboxB[3])
+	if minX >= maxX || minY >= maxY {
+		return 0
+	}
+
+	samples := 50
+	dx := (maxX - minX) / float64(samples)
+	dy := (maxY - minY) / float64(samples)
+
+	hits := 0
+	for i := 0; i < samples; i++ {
+		for j := 0; j < samples; j++ {
+			x := minX + (float64(i)+0.5)*dx
+			y := minY + (float64(j)+0.5)*dy
+
+			if containsPoint(a, x, y) && containsPoint(b, x, y) {
+				hits++
+			}
+		}
+	}
+
+	intersection := float64(hits) * dx * dy
+	return intersection / (areaA + areaB - intersection)
+}
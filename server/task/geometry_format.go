@@ -0,0 +1,207 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+// Formats of task geometries sent by clients. Geometries in other formats than GeoJSON are converted into GeoJSON
+// features before they're stored.
+const (
+	GeometryFormatGeojson   = "geojson"
+	GeometryFormatWkt       = "wkt"
+	GeometryFormatPolyline  = "polyline"  // Encoded polyline with a precision of 5 decimal places
+	GeometryFormatPolyline6 = "polyline6" // Encoded polyline with a precision of 6 decimal places (e.g. used by OSRM)
+)
+
+// GetGeometryFormats returns all formats task geometries can be sent in.
+func GetGeometryFormats() []string {
+	return []string{GeometryFormatGeojson, GeometryFormatWkt, GeometryFormatPolyline, GeometryFormatPolyline6}
+}
+
+// ConvertGeometry converts the geometry in the given format into a GeoJSON feature. GeoJSON geometries (and geometries
+// without format) are returned unchanged.
+func ConvertGeometry(geometry string, format string) (string, error) {
+	var g *geojson.Geometry
+	var err error
+
+	switch format {
+	case "", GeometryFormatGeojson:
+		return geometry, nil
+	case GeometryFormatWkt:
+		g, err = parseWkt(geometry)
+	case GeometryFormatPolyline:
+		g, err = decodePolyline(geometry, 5)
+	case GeometryFormatPolyline6:
+		g, err = decodePolyline(geometry, 6)
+	default:
+		return "", errors.New(fmt.Sprintf("unknown geometry format '%s', supported are %v", format, GetGeometryFormats()))
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	featureBytes, err := json.Marshal(geojson.NewFeature(g))
+	if err != nil {
+		return "", errors.Wrap(err, "error marshalling converted feature")
+	}
+
+	return string(featureBytes), nil
+}
+
+// parseWkt parses the WKT of a point, line string or polygon like "POLYGON ((0 0, 1 0, 1 1, 0 0))". Coordinates with more
+// than two values (e.g. "POINT Z (1 2 3)") keep all of them.
+func parseWkt(wkt string) (*geojson.Geometry, error) {
+	wkt = strings.TrimSpace(wkt)
+
+	openingIndex := strings.Index(wkt, "(")
+	if openingIndex == -1 || !strings.HasSuffix(wkt, ")") {
+		return nil, errors.New(fmt.Sprintf("invalid WKT: %s", wkt))
+	}
+
+	// The type may be followed by a dimension like "Z" or "M"
+	geometryType := strings.Fields(strings.ToUpper(wkt[:openingIndex]))
+	if len(geometryType) == 0 {
+		return nil, errors.New(fmt.Sprintf("WKT has no geometry type: %s", wkt))
+	}
+
+	body := strings.TrimSpace(wkt[openingIndex+1 : len(wkt)-1])
+
+	switch geometryType[0] {
+	case "POINT":
+		point, err := parseWktCoordinate(body)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid WKT point: %s", wkt))
+		}
+		return geojson.NewPointGeometry(point), nil
+	case "LINESTRING":
+		line, err := parseWktCoordinates(body)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid WKT line string: %s", wkt))
+		}
+		return geojson.NewLineStringGeometry(line), nil
+	case "POLYGON":
+		polygon := make([][][]float64, 0)
+		for _, ringBody := range strings.Split(body, "),") {
+			ringBody = strings.TrimSpace(ringBody)
+			ringBody = strings.TrimPrefix(ringBody, "(")
+			ringBody = strings.TrimSuffix(ringBody, ")")
+
+			ring, err := parseWktCoordinates(ringBody)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("invalid WKT polygon: %s", wkt))
+			}
+			polygon = append(polygon, ring)
+		}
+		return geojson.NewPolygonGeometry(polygon), nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("WKT is neither a polygon, a line string nor a point: %s", wkt))
+}
+
+// parseWktCoordinates parses comma separated coordinates like "0 0, 1 0, 1 1".
+func parseWktCoordinates(body string) ([][]float64, error) {
+	coordinates := make([][]float64, 0)
+	for _, coordinateString := range strings.Split(body, ",") {
+		coordinate, err := parseWktCoordinate(coordinateString)
+		if err != nil {
+			return nil, err
+		}
+		coordinates = append(coordinates, coordinate)
+	}
+	return coordinates, nil
+}
+
+// parseWktCoordinate parses a single, space separated coordinate like "9.9 53.5".
+func parseWktCoordinate(coordinateString string) ([]float64, error) {
+	values := strings.Fields(coordinateString)
+	if len(values) < 2 {
+		return nil, errors.New(fmt.Sprintf("coordinate '%s' needs at least two values", coordinateString))
+	}
+
+	coordinate := make([]float64, len(values))
+	for i, value := range values {
+		var err error
+		coordinate[i], err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid coordinate '%s'", coordinateString))
+		}
+	}
+	return coordinate, nil
+}
+
+// decodePolyline decodes the encoded polyline (see https://developers.google.com/maps/documentation/utilities/polylinealgorithm)
+// with the given precision. Polylines store latitude first, so the order is swapped to match GeoJSON. A polyline with a
+// single coordinate becomes a point, all others become line strings.
+func decodePolyline(polyline string, precision int) (*geojson.Geometry, error) {
+	factor := math.Pow10(precision)
+	coordinates := make([][]float64, 0)
+
+	index := 0
+	lat := 0
+	lon := 0
+	for index < len(polyline) {
+		var latDelta, lonDelta int
+		var err error
+
+		latDelta, index, err = decodePolylineValue(polyline, index)
+		if err != nil {
+			return nil, err
+		}
+		lonDelta, index, err = decodePolylineValue(polyline, index)
+		if err != nil {
+			return nil, err
+		}
+
+		lat += latDelta
+		lon += lonDelta
+		coordinates = append(coordinates, []float64{float64(lon) / factor, float64(lat) / factor})
+	}
+
+	switch len(coordinates) {
+	case 0:
+		return nil, errors.New("polyline has no coordinates")
+	case 1:
+		return geojson.NewPointGeometry(coordinates[0]), nil
+	}
+
+	return geojson.NewLineStringGeometry(coordinates), nil
+}
+
+// decodePolylineValue decodes the value starting at "index" and returns it together with the index of the next value.
+func decodePolylineValue(polyline string, index int) (int, int, error) {
+	result := 0
+	shift := uint(0)
+
+	for {
+		if index >= len(polyline) {
+			return 0, index, errors.New(fmt.Sprintf("polyline ends within a value: %s", polyline))
+		}
+
+		b := int(polyline[index]) - 63
+		index++
+		if b < 0 || b > 63 {
+			return 0, index, errors.New(fmt.Sprintf("invalid character '%c' in polyline", polyline[index-1]))
+		}
+
+		result |= (b & 0x1f) << shift
+		shift += 5
+
+		if b < 0x20 {
+			break
+		}
+	}
+
+	// The lowest bit marks negative values
+	if result&1 != 0 {
+		return ^(result >> 1), index, nil
+	}
+	return result >> 1, index, nil
+}
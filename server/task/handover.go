@@ -0,0 +1,142 @@
+package task
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// RequestHandover offers the task to another member of the project. Only the assigned user is allowed to do this and
+// the other member must be allowed to work on the task. A task has at most one handover, a new one replaces the
+// previous one.
+func (s *TaskService) RequestHandover(taskId string, toUserId string, requestingUserId string) (*Handover, error) {
+	if toUserId == requestingUserId {
+		return nil, errors.New(fmt.Sprintf("user %s can't hand task %s over to themselves", requestingUserId, taskId))
+	}
+
+	err := s.permissionService.VerifyNotArchivedTask(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyAssignment(taskId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyMembershipTask(taskId, toUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.permissionService.VerifyAllowedUser(taskId, toUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	handover, err := s.store.AddHandover(taskId, requestingUserId, toUserId)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("User %s offered task %s to user %s", requestingUserId, taskId, toUserId)
+
+	return handover, nil
+}
+
+// GetHandovers returns the handovers offered to the user, oldest first.
+func (s *TaskService) GetHandovers(userId string) ([]*Handover, error) {
+	return s.store.GetHandoversTo(userId)
+}
+
+// AcceptHandover assigns the task to the user the handover has been offered to. The process points are kept and the
+// history contains the handover of the previous user followed by the assignment of the new one. The requirements of
+// "AssignUser" (e.g. the assignment limits) also apply to the new user.
+func (s *TaskService) AcceptHandover(taskId string, requestingUserId string) (*Task, *Handover, error) {
+	handover, err := s.getHandoverTo(taskId, requestingUserId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.permissionService.VerifyNotArchivedTask(taskId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The task might have been unassigned or handed over in another way in the meantime
+	err = s.permissionService.VerifyAssignment(taskId, handover.FromUser)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, fmt.Sprintf("task %s is not assigned to user %s anymore", taskId, handover.FromUser))
+	}
+
+	err = s.permissionService.VerifyAllowedUser(taskId, requestingUserId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.verifyExperience(taskId, requestingUserId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.verifyAssignmentLimits(taskId, requestingUserId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.store.RemoveHandover(taskId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	task, err := s.store.AssignUser(taskId, requestingUserId)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.Log("Handed task %s over from user %s to user %s", taskId, handover.FromUser, requestingUserId)
+
+	err = s.store.AddHistoryEntry(taskId, handover.FromUser, HistoryHandedOver, task.ProcessPoints, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.store.AddHistoryEntry(taskId, requestingUserId, HistoryAssigned, task.ProcessPoints, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return task, handover, nil
+}
+
+// RemoveHandover removes the handover of the task. The user it has been offered to (declining it) and the user who
+// offered it (withdrawing it) are allowed to do this.
+func (s *TaskService) RemoveHandover(taskId string, requestingUserId string) (*Handover, error) {
+	handover, err := s.store.GetHandover(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	if handover == nil || (handover.FromUser != requestingUserId && handover.ToUser != requestingUserId) {
+		return nil, errors.New(fmt.Sprintf("task %s has no handover of or to user %s", taskId, requestingUserId))
+	}
+
+	err = s.store.RemoveHandover(taskId)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("User %s removed handover of task %s from user %s to user %s", requestingUserId, taskId, handover.FromUser, handover.ToUser)
+
+	return handover, nil
+}
+
+func (s *TaskService) getHandoverTo(taskId string, userId string) (*Handover, error) {
+	handover, err := s.store.GetHandover(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	if handover == nil || handover.ToUser != userId {
+		return nil, errors.New(fmt.Sprintf("task %s has not been offered to user %s", taskId, userId))
+	}
+
+	return handover, nil
+}
@@ -0,0 +1,80 @@
+package task
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hauke96/simple-task-manager/server/notification"
+	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/util"
+)
+
+// UnassignInactiveTasksJob is a job for the scheduler, which unassigns tasks without progress, see
+// "UnassignInactiveTasks".
+func UnassignInactiveTasksJob(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	return Init(ctx, tx, logger, permission.Init(ctx, tx, logger)).UnassignInactiveTasks()
+}
+
+// WarnExpiringAssignmentsJob is a job for the scheduler, which warns users before they get unassigned, see
+// "WarnExpiringAssignments".
+func WarnExpiringAssignmentsJob(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	return Init(ctx, tx, logger, permission.Init(ctx, tx, logger)).WarnExpiringAssignments(notification.Init(ctx, tx, logger))
+}
+
+// UnassignInactiveTasks unassigns all tasks, which have been assigned longer than the "unassign after" hours of their
+// project without any process point change since the assignment. This prevents users from hoarding tasks they don't
+// work on. Projects without this setting and archived projects are not affected.
+func (s *TaskService) UnassignInactiveTasks() error {
+	tasks, err := s.store.GetInactiveTasks()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tasks {
+		assignedUser := t.AssignedUser
+
+		task, err := s.store.UnassignUser(t.Id)
+		if err != nil {
+			return err
+		}
+		s.Log("Unassigned user %s from task %s due to missing progress", assignedUser, t.Id)
+
+		err = s.store.AddHistoryEntry(t.Id, assignedUser, HistoryAutoUnassigned, task.ProcessPoints, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(tasks) != 0 {
+		s.Log("Unassigned %d tasks without progress", len(tasks))
+	}
+
+	return nil
+}
+
+// WarnExpiringAssignments adds a notification for every user, who will be unassigned from a task within the last quarter
+// of the "unassign after" hours of its project (see "UnassignInactiveTasks"), e.g. 6 hours before for 24 hours. Every
+// assignment is only warned about once.
+func (s *TaskService) WarnExpiringAssignments(notificationService *notification.NotificationService) error {
+	assignments, err := s.store.GetExpiringAssignments()
+	if err != nil {
+		return err
+	}
+
+	warned := 0
+	for _, a := range assignments {
+		n, err := notificationService.AddAssignmentExpiringNotification(a.UserId, a.ProjectId, a.ProjectName, a.TaskId, a.AssignedAt, a.UnassignAt)
+		if err != nil {
+			return err
+		}
+		if n != nil {
+			warned++
+		}
+	}
+
+	if warned != 0 {
+		s.Log("Warned %d users about expiring assignments", warned)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package task
+
+import (
+	"context"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/osm"
+	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// Maximum number of tasks looked up in one run of the job. Nominatim allows one request per second, so a run takes
+// about this many seconds.
+const localityUpdateBatchSize = 50
+
+// UpdateLocalitiesJob is a job for the scheduler, which looks up the locality of the centroid of tasks, which don't
+// have one yet. This happens in a job instead of on task creation, because projects may have hundreds of tasks and
+// Nominatim only allows one request per second. The lookups therefore take a while and happen outside of any
+// transaction: The tasks are fetched in one transaction and the localities stored in another one. Tasks failing to be
+// looked up are logged and tried again after all other tasks without locality.
+func UpdateLocalitiesJob(ctx context.Context, logger *util.Logger) error {
+	if !osm.GeocodingEnabled() {
+		return nil
+	}
+
+	var tasks []*Task
+	err := inTransaction(ctx, logger, func(s *TaskService) error {
+		var err error
+		tasks, err = s.startLocalityLookup()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	localities := make(map[string]string)
+	for _, task := range tasks {
+		locality, err := osm.ReverseGeocode(logger, task.Centroid[1], task.Centroid[0])
+		if err != nil {
+			logger.Err("Unable to look up locality of task %s: %s", task.Id, err.Error())
+			continue
+		}
+		localities[task.Id] = locality
+	}
+
+	err = inTransaction(ctx, logger, func(s *TaskService) error {
+		return s.setLocalities(localities)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Log("Looked up the locality of %d of %d tasks", len(localities), len(tasks))
+	return nil
+}
+
+// startLocalityLookup returns the next tasks to look up the locality for and marks them as attempted, so that tasks
+// failing to be looked up don't come first in the next run.
+func (s *TaskService) startLocalityLookup() ([]*Task, error) {
+	tasks, err := s.store.GetTasksWithoutLocality(localityUpdateBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return tasks, nil
+	}
+
+	taskIds := make([]string, len(tasks))
+	for i, task := range tasks {
+		taskIds[i] = task.Id
+	}
+
+	err = s.store.SetLocalityAttempted(taskIds)
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// setLocalities stores the looked up localities, the keys of the map are task IDs.
+func (s *TaskService) setLocalities(localities map[string]string) error {
+	for taskId, locality := range localities {
+		err := s.store.SetLocality(taskId, locality)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inTransaction calls the function with a task service in a new transaction, which is committed when the function
+// returns without error and rolled back otherwise.
+func inTransaction(ctx context.Context, logger *util.Logger, f func(s *TaskService) error) error {
+	tx, err := database.GetTransaction(ctx, logger)
+	if err != nil {
+		return errors.Wrap(err, "error getting transaction")
+	}
+
+	err = f(Init(ctx, tx, logger, permission.Init(ctx, tx, logger)))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "error committing transaction")
+	}
+
+	return nil
+}
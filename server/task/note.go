@@ -0,0 +1,62 @@
+package task
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const maxNoteLength = 5000
+
+// GetNote returns the note of the task or nil, when the task has no note. Only the owner of the project is allowed to do
+// this.
+func (s *TaskService) GetNote(taskId string, requestingUserId string) (*TaskNote, error) {
+	err := s.permissionService.VerifyOwnershipTask(taskId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.GetNote(taskId)
+}
+
+// GetNotes returns the notes of all tasks of the project. Only the owner of the project is allowed to do this.
+func (s *TaskService) GetNotes(projectId string, requestingUserId string) ([]*TaskNote, error) {
+	err := s.permissionService.VerifyOwnership(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.GetNotes(projectId)
+}
+
+// SetNote replaces the note of the task, an empty text removes the note. Only the owner of the project is allowed to do
+// this. The returned note is nil, when it has been removed.
+func (s *TaskService) SetNote(taskId string, text string, requestingUserId string) (*TaskNote, error) {
+	if len(text) > maxNoteLength {
+		return nil, errors.New(fmt.Sprintf("note too long, maximum allowed are %d characters", maxNoteLength))
+	}
+
+	err := s.permissionService.VerifyOwnershipTask(taskId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(text) == "" {
+		err = s.store.RemoveNote(taskId)
+		if err != nil {
+			return nil, err
+		}
+		s.Log("Removed note of task %s", taskId)
+
+		return nil, nil
+	}
+
+	note, err := s.store.SetNote(taskId, text, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Updated note of task %s", taskId)
+
+	return note, nil
+}
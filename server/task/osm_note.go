@@ -0,0 +1,143 @@
+package task
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hauke96/simple-task-manager/server/osm"
+	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Maximum number of notes loaded from the OSM API in one run of the job, see "changesetUpdateBatchSize"
+	osmNoteUpdateBatchSize = 100
+	maxOsmNoteTextLength   = 2000
+)
+
+var osmNoteIdRegex = regexp.MustCompile(`^[1-9][0-9]*$`)
+
+// UpdateOsmNotesJob is a job for the scheduler, which loads the state of the open notes from the OSM API.
+func UpdateOsmNotesJob(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	return Init(ctx, tx, logger, permission.Init(ctx, tx, logger)).UpdateOsmNotes()
+}
+
+// GetOsmNotes returns the OSM notes linked to the task, oldest first. All members of the project are allowed to see
+// them.
+func (s *TaskService) GetOsmNotes(taskId string, requestingUserId string) ([]*OsmNote, error) {
+	err := s.permissionService.VerifyMembershipTask(taskId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.GetOsmNotes(taskId)
+}
+
+// CreateOsmNote creates an OSM note with the text at the centroid of the task and links it to the task. The note is
+// created anonymously, so the text gets a hint where it comes from. Every member of the project is allowed to do this.
+func (s *TaskService) CreateOsmNote(taskId string, text string, requestingUserId string) (*OsmNote, *Task, error) {
+	text = strings.TrimSpace(text)
+	if text == "" || len(text) > maxOsmNoteTextLength {
+		return nil, nil, errors.New(fmt.Sprintf("text of OSM notes must have 1 to %d characters", maxOsmNoteTextLength))
+	}
+
+	err := s.verifyOsmNoteAllowed(taskId, requestingUserId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	task, err := s.store.GetTask(taskId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(task.Centroid) != 2 {
+		return nil, nil, errors.New(fmt.Sprintf("task %s has no centroid to create the note at", taskId))
+	}
+
+	text = fmt.Sprintf("%s\n\n(Created via the Simple Task Manager for task %s)", text, taskId)
+	note, err := osm.CreateNote(s.Logger, task.Centroid[1], task.Centroid[0], text)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.linkOsmNote(taskId, note, requestingUserId)
+}
+
+// LinkOsmNote links an existing OSM note to the task, e.g. when someone else already reported the issue. Every member
+// of the project is allowed to do this.
+func (s *TaskService) LinkOsmNote(taskId string, noteId string, requestingUserId string) (*OsmNote, *Task, error) {
+	if !osmNoteIdRegex.MatchString(noteId) {
+		return nil, nil, errors.New(fmt.Sprintf("invalid note ID '%s'", noteId))
+	}
+
+	err := s.verifyOsmNoteAllowed(taskId, requestingUserId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	note, err := osm.GetNote(s.Logger, noteId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.linkOsmNote(taskId, note, requestingUserId)
+}
+
+func (s *TaskService) verifyOsmNoteAllowed(taskId string, requestingUserId string) error {
+	err := s.permissionService.VerifyMembershipTask(taskId, requestingUserId)
+	if err != nil {
+		return err
+	}
+
+	return s.permissionService.VerifyNotArchivedTask(taskId)
+}
+
+// linkOsmNote stores the link and returns it together with the updated task.
+func (s *TaskService) linkOsmNote(taskId string, note *osm.Note, requestingUserId string) (*OsmNote, *Task, error) {
+	linkedNote, err := s.store.LinkOsmNote(taskId, note.Id, note.Status == osm.NoteStatusOpen, requestingUserId)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.Log("Linked OSM note %s to task %s", note.Id, taskId)
+
+	task, err := s.store.GetTask(taskId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return linkedNote, task, nil
+}
+
+// UpdateOsmNotes loads the state of the open notes from the OSM API, the ones not loaded for the longest time first.
+// Notes failing to load are logged and tried again in the next run.
+func (s *TaskService) UpdateOsmNotes() error {
+	noteIds, err := s.store.GetOsmNotesToUpdate(osmNoteUpdateBatchSize)
+	if err != nil {
+		return err
+	}
+
+	updated := 0
+	for _, noteId := range noteIds {
+		note, err := osm.GetNote(s.Logger, noteId)
+		if err != nil {
+			s.Err("Unable to load OSM note %s: %s", noteId, err.Error())
+			continue
+		}
+
+		err = s.store.UpdateOsmNote(noteId, note.Status == osm.NoteStatusOpen)
+		if err != nil {
+			return err
+		}
+		updated++
+	}
+
+	if len(noteIds) != 0 {
+		s.Log("Updated %d of %d open OSM notes", updated, len(noteIds))
+	}
+
+	return nil
+}
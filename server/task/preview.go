@@ -0,0 +1,286 @@
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sort"
+
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/pkg/errors"
+)
+
+const (
+	previewMinSize = 64
+	previewMaxSize = 2048
+	previewPadding = 10
+)
+
+var (
+	previewBackgroundColor = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	previewBorderColor     = color.RGBA{R: 0, G: 150, B: 136, A: 255} // Same as the task borders in the client
+	previewAoiColor        = color.RGBA{R: 66, G: 66, B: 66, A: 255}
+)
+
+// RenderProjectPreview checks the membership of the requesting user and renders all tasks and the AOI of the project
+// into a PNG image. The longer edge of the image has the given size in pixels.
+func (s *TaskService) RenderProjectPreview(projectId string, size int, requestingUserId string) ([]byte, error) {
+	tasks, err := s.GetTasks(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	aoi, err := s.store.GetProjectAoi(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := RenderPreview(tasks, aoi, size)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Log("Rendered preview of project %s with %d tasks", projectId, len(tasks))
+
+	return result, nil
+}
+
+// RenderPreview draws the polygons of the tasks colored by their process points (red for new tasks, yellow for tasks in
+// progress and green for done tasks, like in the client) and returns the image encoded as PNG. Lines and points are
+// drawn in these colors as well. The outline of the AOI (GeoJSON polygon or multipolygon, may be empty) is drawn on top.
+// The longer edge of the image has the given size in pixels.
+func RenderPreview(tasks []*Task, aoi string, size int) ([]byte, error) {
+	if size < previewMinSize || size > previewMaxSize {
+		return nil, errors.New(fmt.Sprintf("size must be between %d and %d but was %d", previewMinSize, previewMaxSize, size))
+	}
+
+	geometryTypes := make([]geojson.GeometryType, len(tasks))
+	polygons := make([][][][]float64, len(tasks))
+	for i, t := range tasks {
+		geometry, _, err := exportGeometry(t)
+		if err != nil {
+			return nil, err
+		}
+		geometryTypes[i] = geometry.Type
+		polygons[i] = geometryCoordinates(geometry)
+	}
+
+	aoiOutline, err := aoiRings(aoi)
+	if err != nil {
+		return nil, err
+	}
+
+	// The AOI might be larger than the tasks (e.g. when supplied by the owner), so it's part of the extent as well
+	projection := newPreviewProjection(append(polygons, aoiOutline), size)
+
+	img := image.NewRGBA(image.Rect(0, 0, projection.width, projection.height))
+	for x := 0; x < projection.width; x++ {
+		for y := 0; y < projection.height; y++ {
+			img.Set(x, y, previewBackgroundColor)
+		}
+	}
+
+	pixelPolygons := make([][][][]float64, len(polygons))
+	for i, rings := range polygons {
+		pixelPolygons[i] = projection.projectRings(rings)
+		if geometryTypes[i] == geojson.GeometryPolygon {
+			fillPolygon(img, pixelPolygons[i], processPointsColor(tasks[i].ProcessPoints, tasks[i].MaxProcessPoints))
+		}
+	}
+
+	// Borders, lines and points are drawn afterwards so that they aren't covered by the filling of neighboring tasks
+	for i, rings := range pixelPolygons {
+		c := previewBorderColor
+		if geometryTypes[i] != geojson.GeometryPolygon {
+			c = processPointsColor(tasks[i].ProcessPoints, tasks[i].MaxProcessPoints)
+		}
+
+		if geometryTypes[i] == geojson.GeometryPoint {
+			drawPoint(img, rings[0][0], c)
+			continue
+		}
+
+		for _, ring := range rings {
+			for j := 0; j < len(ring)-1; j++ {
+				drawLine(img, ring[j], ring[j+1], c)
+			}
+		}
+	}
+
+	for _, ring := range projection.projectRings(aoiOutline) {
+		for j := 0; j < len(ring)-1; j++ {
+			drawLine(img, ring[j], ring[j+1], previewAoiColor)
+		}
+	}
+
+	var buffer bytes.Buffer
+	err = png.Encode(&buffer, img)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding PNG")
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// processPointsColor returns a color from red (no process points) over yellow to green (all process points).
+func processPointsColor(processPoints int, maxProcessPoints int) color.RGBA {
+	ratio := 0.0
+	if maxProcessPoints > 0 {
+		ratio = math.Max(0, math.Min(1, float64(processPoints)/float64(maxProcessPoints)))
+	}
+
+	return color.RGBA{
+		R: uint8(math.Round(math.Min(255, 511*(1-ratio)))),
+		G: uint8(math.Round(math.Min(255, 511*ratio))),
+		B: 0,
+		A: 255,
+	}
+}
+
+// previewProjection maps coordinates (lon, lat) onto pixels. The longitude is scaled by the cosine of the mean latitude,
+// which is precise enough for the area of a usual project.
+type previewProjection struct {
+	minLon, maxLat float64
+	lonFactor      float64
+	scale          float64
+	width, height  int
+}
+
+func newPreviewProjection(polygons [][][][]float64, size int) *previewProjection {
+	minLon, minLat := math.Inf(1), math.Inf(1)
+	maxLon, maxLat := math.Inf(-1), math.Inf(-1)
+	for _, rings := range polygons {
+		for _, ring := range rings {
+			for _, point := range ring {
+				minLon = math.Min(minLon, point[0])
+				maxLon = math.Max(maxLon, point[0])
+				minLat = math.Min(minLat, point[1])
+				maxLat = math.Max(maxLat, point[1])
+			}
+		}
+	}
+
+	// Projects without tasks result in an empty image
+	if math.IsInf(minLon, 1) {
+		minLon, minLat, maxLon, maxLat = 0, 0, 0, 0
+	}
+
+	lonFactor := math.Cos((minLat + maxLat) / 2 * math.Pi / 180)
+	extentX := (maxLon - minLon) * lonFactor
+	extentY := maxLat - minLat
+
+	drawableSize := float64(size - 2*previewPadding)
+	scale := 1.0
+	if math.Max(extentX, extentY) > 0 {
+		scale = drawableSize / math.Max(extentX, extentY)
+	}
+
+	return &previewProjection{
+		minLon:    minLon,
+		maxLat:    maxLat,
+		lonFactor: lonFactor,
+		scale:     scale,
+		width:     int(math.Ceil(extentX*scale)) + 2*previewPadding,
+		height:    int(math.Ceil(extentY*scale)) + 2*previewPadding,
+	}
+}
+
+func (p *previewProjection) projectRings(rings [][][]float64) [][][]float64 {
+	result := make([][][]float64, len(rings))
+
+	for i, ring := range rings {
+		result[i] = make([][]float64, len(ring))
+		for j, point := range ring {
+			result[i][j] = []float64{
+				(point[0]-p.minLon)*p.lonFactor*p.scale + previewPadding,
+				(p.maxLat-point[1])*p.scale + previewPadding,
+			}
+		}
+	}
+
+	return result
+}
+
+// fillPolygon fills the polygon given in pixel coordinates using a scanline algorithm with the even-odd rule, so holes
+// stay empty.
+func fillPolygon(img *image.RGBA, rings [][][]float64, c color.RGBA) {
+	bounds := img.Bounds()
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		scanY := float64(y) + 0.5
+		intersections := make([]float64, 0)
+
+		for _, ring := range rings {
+			for i := 0; i < len(ring)-1; i++ {
+				a, b := ring[i], ring[i+1]
+				if (a[1] <= scanY && b[1] > scanY) || (b[1] <= scanY && a[1] > scanY) {
+					intersections = append(intersections, a[0]+(scanY-a[1])/(b[1]-a[1])*(b[0]-a[0]))
+				}
+			}
+		}
+
+		sort.Float64s(intersections)
+
+		for i := 0; i+1 < len(intersections); i += 2 {
+			startX := int(math.Ceil(intersections[i] - 0.5))
+			endX := int(math.Floor(intersections[i+1] - 0.5))
+			for x := startX; x <= endX; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+}
+
+// drawPoint draws a square of 5x5 pixels around the given pixel coordinate.
+func drawPoint(img *image.RGBA, point []float64, c color.RGBA) {
+	x, y := int(math.Round(point[0])), int(math.Round(point[1]))
+	for dx := -2; dx <= 2; dx++ {
+		for dy := -2; dy <= 2; dy++ {
+			img.SetRGBA(x+dx, y+dy, c)
+		}
+	}
+}
+
+// drawLine draws a line between the two pixel coordinates using the Bresenham algorithm.
+func drawLine(img *image.RGBA, from []float64, to []float64, c color.RGBA) {
+	x0, y0 := int(math.Round(from[0])), int(math.Round(from[1]))
+	x1, y1 := int(math.Round(to[0])), int(math.Round(to[1]))
+
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	stepX, stepY := 1, 1
+	if x0 > x1 {
+		stepX = -1
+	}
+	if y0 > y1 {
+		stepY = -1
+	}
+
+	e := dx + dy
+	for {
+		img.SetRGBA(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += stepX
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += stepY
+		}
+	}
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
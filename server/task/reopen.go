@@ -0,0 +1,78 @@
+package task
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const maxReopenReasonLength = 1000
+
+// Reopen resets the process points and the completed checklist items of a completed task and unassigns it, e.g.
+// because the validation of the mapping failed. The reason is mandatory and stored in the history of the task. Only the
+// owner of the project is allowed to do this. Besides the reopened task, the user who completed the task is returned
+// (empty when unknown), so that this user can be informed.
+func (s *TaskService) Reopen(taskId string, reason string, requestingUserId string) (*Task, string, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, "", errors.New("reason for reopening the task must not be empty")
+	}
+
+	if len(reason) > maxReopenReasonLength {
+		return nil, "", errors.New(fmt.Sprintf("reason too long, maximum allowed are %d characters", maxReopenReasonLength))
+	}
+
+	err := s.permissionService.VerifyNotArchivedTask(taskId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = s.permissionService.VerifyOwnershipTask(taskId, requestingUserId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	task, err := s.store.GetTask(taskId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if task.ProcessPoints < task.MaxProcessPoints {
+		return nil, "", errors.New(fmt.Sprintf("task %s is not completed", taskId))
+	}
+
+	previousMapper, err := s.store.GetLastMapper(taskId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	oldPoints := task.ProcessPoints
+
+	task, err = s.store.SetProcessPoints(taskId, 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(task.ChecklistDone) != 0 {
+		task, err = s.store.SetChecklistDone(taskId, []string{})
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if strings.TrimSpace(task.AssignedUser) != "" {
+		task, err = s.store.UnassignUser(taskId)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	s.Log("Reopened task %s completed by user %s", taskId, previousMapper)
+
+	err = s.store.AddHistoryEntryWithComment(taskId, requestingUserId, HistoryReopened, 0, -oldPoints, reason)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return task, previousMapper, nil
+}
@@ -0,0 +1,126 @@
+package task
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/osm"
+	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+var (
+	reservationDuration = 15 * time.Minute
+)
+
+// SetReservationDuration sets the time tasks stay reserved after being opened in an editor.
+func SetReservationDuration(duration time.Duration) error {
+	if duration <= 0 {
+		return errors.New(fmt.Sprintf("reservation duration must be positive but was %s", duration))
+	}
+
+	reservationDuration = duration
+
+	return nil
+}
+
+// RemoveExpiredReservationsJob is a job for the scheduler, which removes reservations that already ended.
+func RemoveExpiredReservationsJob(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	return Init(ctx, tx, logger, permission.Init(ctx, tx, logger)).RemoveExpiredReservations()
+}
+
+// ReserveForEditor reserves the task for the requesting user and returns the link to open it in the editor (see
+// "osm.EditorUrl"). Every member allowed to work on the task can do this, unless the task is completed, assigned to
+// someone else or reserved by someone else. Reserving the task again extends the reservation.
+func (s *TaskService) ReserveForEditor(taskId string, editor string, requestingUserId string) (*Task, string, error) {
+	if !osm.IsValidEditor(editor) {
+		return nil, "", errors.New(fmt.Sprintf("unknown editor '%s'", editor))
+	}
+
+	err := s.permissionService.VerifyMembershipTask(taskId, requestingUserId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = s.permissionService.VerifyNotArchivedTask(taskId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = s.permissionService.VerifyAllowedUser(taskId, requestingUserId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	task, err := s.store.GetTask(taskId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if task.ProcessPoints == task.MaxProcessPoints {
+		return nil, "", errors.New(fmt.Sprintf("task %s is already completed", taskId))
+	}
+	if task.AssignedUser != "" && task.AssignedUser != requestingUserId {
+		return nil, "", errors.New(fmt.Sprintf("task %s is assigned to another user", taskId))
+	}
+	if task.Reservation != nil && task.Reservation.UserId != requestingUserId {
+		return nil, "", errors.New(fmt.Sprintf("task %s is reserved by %s until %s", taskId, task.Reservation.UserId, task.Reservation.ExpiresAt.UTC().Format(time.RFC3339)))
+	}
+
+	editorUrl, err := osm.EditorUrl(editor, task.BoundingBox, task.ChangesetComment, task.ChangesetHashtags)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The store only replaces own or expired reservations, in case someone else reserved the task in the meantime
+	reserved, err := s.store.ReserveTask(taskId, requestingUserId, editor, reservationDuration)
+	if err != nil {
+		return nil, "", err
+	}
+	if !reserved {
+		return nil, "", errors.New(fmt.Sprintf("task %s has been reserved by another user in the meantime", taskId))
+	}
+	s.Log("Reserved task %s for user %s (editor %s)", taskId, requestingUserId, editor)
+
+	task, err = s.store.GetTask(taskId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return task, editorUrl, nil
+}
+
+// ReleaseReservation ends the reservation of the requesting user, e.g. after the changes have been uploaded.
+// Reservations of other users are not touched, so releasing a task without own reservation has no effect.
+func (s *TaskService) ReleaseReservation(taskId string, requestingUserId string) (*Task, error) {
+	err := s.permissionService.VerifyMembershipTask(taskId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.RemoveReservation(taskId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Released reservation of task %s for user %s", taskId, requestingUserId)
+
+	return s.store.GetTask(taskId)
+}
+
+// RemoveExpiredReservations removes all reservations that already ended. Tasks don't show expired reservations anyway,
+// so this only keeps the table small.
+func (s *TaskService) RemoveExpiredReservations() error {
+	removed, err := s.store.RemoveExpiredReservations()
+	if err != nil {
+		return err
+	}
+
+	if removed != 0 {
+		s.Log("Removed %d expired task reservations", removed)
+	}
+
+	return nil
+}
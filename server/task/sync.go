@@ -0,0 +1,128 @@
+package task
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Types of operations clients can queue while being offline
+const (
+	OperationAssign           = "assign"
+	OperationUnassign         = "unassign"
+	OperationSetProcessPoints = "set_process_points"
+)
+
+var (
+	maxOperationsPerSync = 1000
+)
+
+// Operation is a change of a task a client made (e.g. while being offline), which is applied later on.
+type Operation struct {
+	Id            string    `json:"id" validate:"required"` // Chosen by the client to match the results to its operations
+	Type          string    `json:"type" validate:"oneof=assign unassign set_process_points"`
+	TaskId        string    `json:"taskId" validate:"required"`
+	Version       int       `json:"version"`       // Version of the task this change is based on
+	ProcessPoints int       `json:"processPoints"` // Only used by "set_process_points"
+	Timestamp     time.Time `json:"timestamp"`     // Client time of the change, operations are applied in this order
+}
+
+type OperationResult struct {
+	Id       string `json:"id"`
+	Success  bool   `json:"success"`
+	Conflict bool   `json:"conflict"`        // True when the task has been changed since the version of the operation
+	Error    string `json:"error,omitempty"` // Reason why the operation failed
+	Task     *Task  `json:"task,omitempty"`  // The current state of the task
+}
+
+// ApplyOperations applies the operations in the order of their timestamps. An operation is only applied when it's based
+// on the current version of the task, otherwise it's a conflict and the client gets the current task to resolve it.
+// Operations of the same batch build on each other: A client queuing several changes of a task only knows the version
+// from before it went offline, so every applied operation raises the version the next operation on that task is
+// expected to have by one. Failing operations don't abort the other operations, each operation is undone completely
+// when it fails. The returned results state what happened to each of them.
+func (s *TaskService) ApplyOperations(operations []*Operation, requestingUserId string) ([]*OperationResult, error) {
+	if len(operations) > maxOperationsPerSync {
+		return nil, errors.New(fmt.Sprintf("too many operations, maximum allowed are %d", maxOperationsPerSync))
+	}
+
+	sort.SliceStable(operations, func(i, j int) bool {
+		return operations[i].Timestamp.Before(operations[j].Timestamp)
+	})
+
+	// Task ID -> Number of operations of this batch applied to the task
+	appliedOperations := make(map[string]int)
+
+	results := make([]*OperationResult, len(operations))
+	for i, operation := range operations {
+		err := s.store.SetSavepoint()
+		if err != nil {
+			return nil, err
+		}
+
+		results[i] = s.applyOperation(operation, operation.Version+appliedOperations[operation.TaskId], requestingUserId)
+
+		if results[i].Success {
+			appliedOperations[operation.TaskId]++
+			err = s.store.ReleaseSavepoint()
+		} else {
+			// Also undoes partial changes and makes the transaction usable again after database errors
+			err = s.store.RollbackToSavepoint()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.Log("Applied %d operations of user %s", len(operations), requestingUserId)
+
+	return results, nil
+}
+
+// applyOperation applies the operation when the task has the expected version.
+func (s *TaskService) applyOperation(operation *Operation, expectedVersion int, requestingUserId string) *OperationResult {
+	result := &OperationResult{Id: operation.Id}
+
+	err := s.permissionService.VerifyMembershipTask(operation.TaskId, requestingUserId)
+	if err != nil {
+		result.Error = fmt.Sprintf("user is not a member of the project of task %s", operation.TaskId)
+		return result
+	}
+
+	task, err := s.store.GetTask(operation.TaskId)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	if task.Version != expectedVersion {
+		s.Log("Conflict for operation %s on task %s: expected version %d but task has version %d", operation.Id, task.Id, expectedVersion, task.Version)
+		result.Conflict = true
+		result.Error = "task has been changed in the meantime"
+		result.Task = task
+		return result
+	}
+
+	switch operation.Type {
+	case OperationAssign:
+		task, err = s.AssignUser(operation.TaskId, requestingUserId)
+	case OperationUnassign:
+		task, err = s.UnassignUser(operation.TaskId, requestingUserId)
+	case OperationSetProcessPoints:
+		task, err = s.SetProcessPoints(operation.TaskId, operation.ProcessPoints, requestingUserId)
+	default:
+		err = errors.New(fmt.Sprintf("unknown operation type '%s'", operation.Type))
+	}
+
+	if err != nil {
+		s.Err("Operation %s on task %s failed: %s", operation.Id, operation.TaskId, err.Error())
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Success = true
+	result.Task = task
+	return result
+}
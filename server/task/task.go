@@ -1,33 +1,49 @@
 package task
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"github.com/hauke96/simple-task-manager/server/osm"
 	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/storage"
 	"github.com/hauke96/simple-task-manager/server/util"
-	geojson "github.com/paulmach/go.geojson"
 	"github.com/pkg/errors"
 	"strings"
+	"time"
 )
 
-type Task struct {
-	Id               string `json:"id"`
-	ProcessPoints    int    `json:"processPoints"`
-	MaxProcessPoints int    `json:"maxProcessPoints"`
-	Geometry         string `json:"geometry"`
-	AssignedUser     string `json:"assignedUser"`
-}
+// Types of entries in the task history
+const (
+	HistoryAssigned         = "assigned"
+	HistoryUnassigned       = "unassigned"
+	HistoryProcessPointsSet = "process_points_set"
+	HistoryHandedOver       = "handed_over"     // The user handed the task over to another member, who is assigned afterwards
+	HistoryAutoUnassigned   = "auto_unassigned" // The user got unassigned due to missing progress, see "UnassignInactiveTasks"
+	HistoryReopened         = "reopened"        // The owner reset the process points of a completed task, the entry has the reason as comment
+)
+
+// Difficulties of tasks, so that e.g. beginners can choose easy tasks
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+// Tasks overlapping existing tasks of the project (or each other) by at least this ratio (intersection over union) are
+// considered to be duplicates, e.g. because the same grid has been uploaded twice.
+const duplicateOverlapThreshold = 0.9
 
 type TaskService struct {
 	*util.Logger
-	store             *storePg
+	store             storage.TaskStore
 	permissionService *permission.PermissionService
 }
 
-func Init(tx *sql.Tx, logger *util.Logger, permissionService *permission.PermissionService) *TaskService {
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger, permissionService *permission.PermissionService) *TaskService {
 	return &TaskService{
 		Logger:            logger,
-		store:             getStore(tx, logger),
+		store:             getStore(ctx, tx, logger),
 		permissionService: permissionService,
 	}
 }
@@ -39,29 +55,107 @@ func (s *TaskService) GetTasks(projectId string, requestingUserId string) ([]*Ta
 		return nil, err
 	}
 
-	return s.store.getTasks(projectId)
+	return s.store.GetTasks(projectId)
+}
+
+// GetSharedTasks gets the tasks of the project for users of a share link. The share token has to be verified by the
+// caller, so no membership is required. The tasks contain no user IDs, see "anonymizeSharedTasks".
+func (s *TaskService) GetSharedTasks(projectId string) ([]*Task, error) {
+	tasks, err := s.store.GetTasks(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	anonymizeSharedTasks(tasks)
+
+	return tasks, nil
+}
+
+// GetSimplifiedTasks works like "GetTasks" but simplifies the geometries of all tasks using the given tolerance (in
+// degree). The full precision geometry of a task can be requested via "GetTask".
+func (s *TaskService) GetSimplifiedTasks(projectId string, tolerance float64, requestingUserId string) ([]*Task, error) {
+	if tolerance <= 0 {
+		return nil, errors.New(fmt.Sprintf("tolerance must be positive but was %f", tolerance))
+	}
+
+	tasks, err := s.GetTasks(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range tasks {
+		t.Geometry, err = simplifyGeometry(t.Geometry, tolerance)
+		if err != nil {
+			s.Err("Unable to simplify geometry of task %s", t.Id)
+			return nil, err
+		}
+	}
+	s.Log("Simplified geometries of %d tasks with tolerance %f", len(tasks), tolerance)
+
+	return tasks, nil
+}
+
+// GetTask checks the membership of the requesting user and returns the task with its full precision geometry.
+func (s *TaskService) GetTask(taskId string, requestingUserId string) (*Task, error) {
+	err := s.permissionService.VerifyMembershipTask(taskId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.GetTask(taskId)
 }
 
-// AddTasks sets the ID of the tasks and adds them to the storage.
+// AddTasks sets the ID of the tasks and adds them to the storage. Only the geometry types allowed by the project are
+// accepted. Tasks with (nearly) the same geometry as other new tasks or as existing tasks of the project are rejected.
 func (s *TaskService) AddTasks(newTasks []*Task, projectId string) ([]*Task, error) {
-	for _, t := range newTasks {
+	geometryTypes, err := s.store.GetGeometryTypes(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	shapes := make([]*taskShape, len(newTasks))
+
+	for i, t := range newTasks {
+		if t.Difficulty == "" {
+			t.Difficulty = DifficultyMedium
+		}
+		if !IsValidDifficulty(t.Difficulty) {
+			return nil, errors.New(fmt.Sprintf("unknown difficulty '%s'", t.Difficulty))
+		}
+
 		if t.ProcessPoints < 0 || t.MaxProcessPoints < 1 || t.MaxProcessPoints < t.ProcessPoints {
 			return nil, errors.New(fmt.Sprintf("process points of task are out of range (%d / %d)", t.ProcessPoints, t.MaxProcessPoints))
 		}
 
-		// Check for valid geojson
-		feature, err := geojson.UnmarshalFeature([]byte(t.Geometry))
+		shape, err := parseTaskShape(t.Geometry)
 		if err != nil {
-			return nil, errors.Wrap(err, fmt.Sprintf("invalid GeoJSON: %s", t.Geometry))
+			return nil, err
+		}
+
+		geometryTypeAllowed := false
+		for _, geometryType := range geometryTypes {
+			geometryTypeAllowed = geometryTypeAllowed || geometryType == shape.geometryType
 		}
 
-		s.Log("%#v", feature)
-		if feature.Type != "Feature" || feature.Geometry == nil || feature.Geometry.Type != "Polygon" {
-			return nil, errors.New(fmt.Sprintf("task Geometry is neither a feature nor a polygon: %s", t.Geometry))
+		if !geometryTypeAllowed {
+			return nil, errors.New(fmt.Sprintf("geometry type %s is not allowed in project %s, allowed are %v", shape.geometryType, projectId, geometryTypes))
 		}
+
+		// Computed once here so that clients don't need to parse the whole geometry for e.g. list views
+		t.BoundingBox = boundingBox(shape.coordinates)
+		t.Centroid = shape.centroid()
+		t.Area = shape.area()
+		t.Perimeter = shape.perimeter()
+
+		shapes[i] = shape
+	}
+
+	err = s.verifyNoDuplicates(shapes, projectId)
+	if err != nil {
+		return nil, err
 	}
 
-	tasks, err := s.store.addTasks(newTasks, projectId)
+	tasks, err := s.store.AddTasks(newTasks, projectId)
 	if err != nil {
 		return nil, err
 	}
@@ -71,6 +165,75 @@ func (s *TaskService) AddTasks(newTasks []*Task, projectId string) ([]*Task, err
 	return tasks, nil
 }
 
+// verifyNoDuplicates returns an error when one of the given shapes overlaps with another one or with the geometry of an
+// existing task of the project by at least the "duplicateOverlapThreshold". Lines and points are only duplicates when
+// they have exactly the same coordinates.
+func (s *TaskService) verifyNoDuplicates(shapes []*taskShape, projectId string) error {
+	existingTasks, err := s.store.GetTasks(projectId)
+	if err != nil {
+		return err
+	}
+
+	for _, existingTask := range existingTasks {
+		existingShape, err := parseTaskShape(existingTask.Geometry)
+		if err != nil {
+			s.Err("Unable to parse geometry of existing task %s, skip duplicate check for it", existingTask.Id)
+			continue
+		}
+
+		for i, shape := range shapes {
+			overlap := duplicateRatio(shape, existingShape)
+			if overlap >= duplicateOverlapThreshold {
+				return errors.New(fmt.Sprintf("new task %d is a duplicate of existing task %s (%.0f%% overlap)", i, existingTask.Id, overlap*100))
+			}
+		}
+	}
+
+	for i := 0; i < len(shapes); i++ {
+		for j := i + 1; j < len(shapes); j++ {
+			overlap := duplicateRatio(shapes[i], shapes[j])
+			if overlap >= duplicateOverlapThreshold {
+				return errors.New(fmt.Sprintf("new tasks %d and %d are duplicates (%.0f%% overlap)", i, j, overlap*100))
+			}
+		}
+	}
+
+	return nil
+}
+
+func IsValidDifficulty(difficulty string) bool {
+	return difficulty == DifficultyEasy || difficulty == DifficultyMedium || difficulty == DifficultyHard
+}
+
+// FilterByDifficulty returns all tasks having one of the given difficulties.
+func FilterByDifficulty(tasks []*Task, difficulties []string) []*Task {
+	result := make([]*Task, 0)
+
+	for _, t := range tasks {
+		for _, d := range difficulties {
+			if t.Difficulty == d {
+				result = append(result, t)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// FilterChangedSince returns all tasks changed after the given time.
+func FilterChangedSince(tasks []*Task, since time.Time) []*Task {
+	result := make([]*Task, 0)
+
+	for _, t := range tasks {
+		if t.UpdatedAt.After(since) {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
+
 func toTaskIds(tasks []*Task) []string {
 	ids := make([]string, len(tasks))
 	for i, v := range tasks {
@@ -80,7 +243,12 @@ func toTaskIds(tasks []*Task) []string {
 }
 
 func (s *TaskService) AssignUser(taskId, userId string) (*Task, error) {
-	task, err := s.store.getTask(taskId)
+	err := s.permissionService.VerifyNotArchivedTask(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := s.store.GetTask(taskId)
 	if err != nil {
 		return nil, err
 	}
@@ -90,69 +258,397 @@ func (s *TaskService) AssignUser(taskId, userId string) (*Task, error) {
 		return nil, errors.New(fmt.Sprintf("task %s has already an assigned userId, cannot overwrite", task.Id))
 	}
 
-	task, err = s.store.assignUser(taskId, userId)
+	if task.Flag != nil {
+		return nil, errors.New(fmt.Sprintf("task %s is flagged and can't be assigned until the flag is resolved", task.Id))
+	}
+
+	if task.Blocked {
+		return nil, errors.New(fmt.Sprintf("task %s depends on tasks %v, which have to be completed first", task.Id, task.DependsOn))
+	}
+
+	err = s.permissionService.VerifyAllowedUser(taskId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.verifyExperience(taskId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.verifyAssignmentLimits(taskId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err = s.store.AssignUser(taskId, userId)
 	if err != nil {
 		return nil, err
 	}
 	s.Log("Assigned user %s from task %s", userId, taskId)
 
+	err = s.store.AddHistoryEntry(taskId, userId, HistoryAssigned, task.ProcessPoints, 0)
+	if err != nil {
+		return nil, err
+	}
+
 	return task, nil
 }
 
+// RestoreAssignment assigns the user again after a reverted removal from the project. The requirements of "AssignUser"
+// (e.g. the assignment limits) are not checked, since the user has been assigned before. Tasks assigned to someone
+// else in the meantime are not changed.
+func (s *TaskService) RestoreAssignment(taskId, userId string) (*Task, error) {
+	task, err := s.store.GetTask(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(task.AssignedUser) != "" {
+		return nil, errors.New(fmt.Sprintf("task %s has been assigned to another user in the meantime", task.Id))
+	}
+
+	task, err = s.store.AssignUser(taskId, userId)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Restored assignment of user %s to task %s", userId, taskId)
+
+	err = s.store.AddHistoryEntry(taskId, userId, HistoryAssigned, task.ProcessPoints, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// ExpandChangesetTemplate replaces the placeholders "{taskId}" and "{projectId}" in the changeset comment and hashtags
+// templates of a project.
+func ExpandChangesetTemplate(comment string, hashtags []string, taskId string, projectId string) (string, []string) {
+	replacer := strings.NewReplacer("{taskId}", taskId, "{projectId}", projectId)
+
+	expandedHashtags := make([]string, len(hashtags))
+	for i, h := range hashtags {
+		expandedHashtags[i] = replacer.Replace(h)
+	}
+
+	return replacer.Replace(comment), expandedHashtags
+}
+
+// verifyExperience checks that the user uploaded at least as many changesets as required by the project of the task.
+func (s *TaskService) verifyExperience(taskId string, userId string) error {
+	minChangesets, err := s.permissionService.MinChangesetsForTask(taskId)
+	if err != nil {
+		return err
+	}
+
+	// Most projects don't have this restriction, so there's no need to ask the OSM server
+	if minChangesets == 0 {
+		return nil
+	}
+
+	changesets, err := osm.GetChangesetCount(s.Logger, userId)
+	if err != nil {
+		return err
+	}
+
+	if changesets < minChangesets {
+		return errors.New(fmt.Sprintf("user %s has %d changesets but the project of task %s requires %d", userId, changesets, taskId, minChangesets))
+	}
+
+	return nil
+}
+
+// verifyAssignmentLimits checks that the user has neither too many tasks of the project assigned nor completed too
+// many tasks of the project today.
+func (s *TaskService) verifyAssignmentLimits(taskId string, userId string) error {
+	maxAssignedTasks, maxCompletions, err := s.permissionService.AssignmentLimitsForTask(taskId)
+	if err != nil {
+		return err
+	}
+
+	if maxAssignedTasks > 0 {
+		assignedTasks, err := s.store.CountAssignedTasks(taskId, userId)
+		if err != nil {
+			return err
+		}
+
+		if assignedTasks >= maxAssignedTasks {
+			return errors.New(fmt.Sprintf("user %s has already %d tasks assigned, the project of task %s allows %d at the same time", userId, assignedTasks, taskId, maxAssignedTasks))
+		}
+	}
+
+	if maxCompletions > 0 {
+		completions, err := s.store.CountCompletionsToday(taskId, userId)
+		if err != nil {
+			return err
+		}
+
+		if completions >= maxCompletions {
+			return errors.New(fmt.Sprintf("user %s has already completed %d tasks today, the project of task %s allows %d per day", userId, completions, taskId, maxCompletions))
+		}
+	}
+
+	return nil
+}
+
 func (s *TaskService) UnassignUser(taskId, requestingUserId string) (*Task, error) {
-	err := s.permissionService.VerifyAssignment(taskId, requestingUserId)
+	err := s.permissionService.VerifyNotArchivedTask(taskId)
 	if err != nil {
 		return nil, err
 	}
 
-	task, err := s.store.unassignUser(taskId)
+	err = s.permissionService.VerifyAssignment(taskId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := s.store.UnassignUser(taskId)
 	if err != nil {
 		return nil, err
 	}
 	s.Log("Unassigned user %s from task %s", requestingUserId, taskId)
 
+	err = s.store.AddHistoryEntry(taskId, requestingUserId, HistoryUnassigned, task.ProcessPoints, 0)
+	if err != nil {
+		return nil, err
+	}
+
 	return task, nil
 }
 
 // SetProcessPoints updates the process points on task "id". When "needsAssignedUser" is true on the project, this
 // function also checks, whether the assigned user is equal to the requesting User.
 func (s *TaskService) SetProcessPoints(taskId string, newPoints int, requestingUserId string) (*Task, error) {
-	needsAssignment, err := s.permissionService.AssignmentInTaskNeeded(taskId)
+	err := s.verifyProgressAllowed(taskId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	checklist, derivePoints, err := s.store.GetChecklist(taskId)
+	if err != nil {
+		return nil, err
+	}
+	if derivePoints && len(checklist) != 0 {
+		return nil, errors.New(fmt.Sprintf("process points of task %s are derived from the checklist and can't be set directly", taskId))
+	}
+
+	task, err := s.store.GetTask(taskId)
 	if err != nil {
 		return nil, err
 	}
+
+	// New process points should be in the range "[0, MaxProcessPoints]" (so including 0 and MaxProcessPoints)
+	if newPoints < 0 || task.MaxProcessPoints < newPoints {
+		return nil, errors.New("process points out of range")
+	}
+
+	pointStep, err := s.permissionService.PointStepForTask(taskId)
+	if err != nil {
+		return nil, err
+	}
+	err = verifyPointStep(newPoints, task.MaxProcessPoints, pointStep)
+	if err != nil {
+		return nil, err
+	}
+
+	oldPoints := task.ProcessPoints
+
+	task, err = s.store.SetProcessPoints(taskId, newPoints)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Set process points of task %s to %d", taskId, newPoints)
+
+	err = s.store.AddHistoryEntry(taskId, requestingUserId, HistoryProcessPointsSet, newPoints, newPoints-oldPoints)
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// verifyProgressAllowed checks that the user is allowed to make progress on the task (e.g. set the process points).
+// When the project needs assignments, only the assigned user is allowed, otherwise all members. In both cases the user
+// must be one of the allowed users of the task.
+func (s *TaskService) verifyProgressAllowed(taskId string, requestingUserId string) error {
+	err := s.permissionService.VerifyNotArchivedTask(taskId)
+	if err != nil {
+		return err
+	}
+
+	needsAssignment, err := s.permissionService.AssignmentInTaskNeeded(taskId)
+	if err != nil {
+		return err
+	}
 	if needsAssignment {
 		err := s.permissionService.VerifyAssignment(taskId, requestingUserId)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	} else { // when no assignment is needed, the requesting user at least needs to be a member
 		err := s.permissionService.VerifyMembershipTask(taskId, requestingUserId)
 		if err != nil {
 			s.Err("user not a member of the project, the task %s belongs to", taskId)
+			return err
+		}
+	}
+
+	return s.permissionService.VerifyAllowedUser(taskId, requestingUserId)
+}
+
+// verifyPointStep checks that the process points are a multiple of the point step of the project. The maximum is always
+// allowed, so that tasks whose maximum isn't a multiple of the step can still be finished. The error contains the
+// nearest allowed values.
+func verifyPointStep(points int, maxPoints int, pointStep int) error {
+	if pointStep <= 0 || points%pointStep == 0 || points == maxPoints {
+		return nil
+	}
+
+	lower := points - points%pointStep
+	upper := lower + pointStep
+	if upper > maxPoints {
+		upper = maxPoints
+	}
+
+	return errors.New(fmt.Sprintf("process points must be a multiple of %d (or the maximum %d), use %d or %d instead of %d", pointStep, maxPoints, lower, upper, points))
+}
+
+// SetDifficulty changes the difficulty of the task. Only the owner of the project is allowed to do this.
+func (s *TaskService) SetDifficulty(taskId string, difficulty string, requestingUserId string) (*Task, error) {
+	if !IsValidDifficulty(difficulty) {
+		return nil, errors.New(fmt.Sprintf("unknown difficulty '%s'", difficulty))
+	}
+
+	err := s.permissionService.VerifyOwnershipTask(taskId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := s.store.SetDifficulty(taskId, difficulty)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Set difficulty of task %s to %s", taskId, difficulty)
+
+	return task, nil
+}
+
+// SetAllowedUsers restricts the task to the given members of the project, e.g. so that only validators work on
+// certain tasks. An empty list allows all members again. Only the owner of the project is allowed to do this.
+func (s *TaskService) SetAllowedUsers(taskId string, allowedUsers []string, requestingUserId string) (*Task, error) {
+	err := s.permissionService.VerifyOwnershipTask(taskId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range allowedUsers {
+		err = s.permissionService.VerifyMembershipTask(taskId, u)
+		if err != nil {
 			return nil, err
 		}
 	}
 
-	task, err := s.store.getTask(taskId)
+	task, err := s.store.SetAllowedUsers(taskId, allowedUsers)
 	if err != nil {
 		return nil, err
 	}
+	s.Log("Set allowed users of task %s to %v", taskId, allowedUsers)
 
-	// New process points should be in the range "[0, MaxProcessPoints]" (so including 0 and MaxProcessPoints)
-	if newPoints < 0 || task.MaxProcessPoints < newPoints {
-		return nil, errors.New("process points out of range")
+	return task, nil
+}
+
+// SetDependencies sets the tasks which have to be completed before the given task can be assigned, e.g. so that the
+// quality assurance of an area starts after it has been mapped. All tasks must belong to the same project and must not
+// depend on each other in a circle. An empty list removes all dependencies. Only the owner of the project is allowed to
+// do this.
+func (s *TaskService) SetDependencies(taskId string, dependsOn []string, requestingUserId string) (*Task, error) {
+	err := s.permissionService.VerifyOwnershipTask(taskId, requestingUserId)
+	if err != nil {
+		return nil, err
 	}
 
-	task, err = s.store.setProcessPoints(taskId, newPoints)
+	projectTasks, err := s.store.GetTasksOfSameProject(taskId)
 	if err != nil {
 		return nil, err
 	}
-	s.Log("Set process points of task %s to %d", taskId, newPoints)
+
+	err = verifyDependencies(taskId, dependsOn, projectTasks)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := s.store.SetDependencies(taskId, dependsOn)
+	if err != nil {
+		return nil, err
+	}
+	s.Log("Set dependencies of task %s to %v", taskId, dependsOn)
 
 	return task, nil
 }
 
+// verifyDependencies checks that the tasks the given task should depend on are other tasks of the project and that
+// these new dependencies don't lead to a circle, which would block the tasks forever.
+func verifyDependencies(taskId string, dependsOn []string, projectTasks []*Task) error {
+	dependencies := make(map[string][]string, len(projectTasks))
+	for _, t := range projectTasks {
+		dependencies[t.Id] = t.DependsOn
+	}
+	dependencies[taskId] = dependsOn
+
+	for _, id := range dependsOn {
+		if id == taskId {
+			return errors.New(fmt.Sprintf("task %s can't depend on itself", taskId))
+		}
+		if _, ok := dependencies[id]; !ok {
+			return errors.New(fmt.Sprintf("task %s is not part of the project of task %s", id, taskId))
+		}
+	}
+
+	// Depth-first search from the given task, reaching it again means there's a circle
+	visited := make(map[string]bool)
+	toVisit := append([]string{}, dependsOn...)
+	for len(toVisit) != 0 {
+		id := toVisit[len(toVisit)-1]
+		toVisit = toVisit[:len(toVisit)-1]
+
+		if id == taskId {
+			return errors.New(fmt.Sprintf("dependencies of task %s would lead to a circle", taskId))
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		toVisit = append(toVisit, dependencies[id]...)
+	}
+
+	return nil
+}
+
+// GetAssignedTasks returns all tasks of all projects the user is currently assigned to.
+func (s *TaskService) GetAssignedTasks(userId string) ([]*AssignedTask, error) {
+	return s.store.GetAssignedTasks(userId)
+}
+
+// GetChangedTasks returns all tasks of all projects the user is member of, which have been changed after the given
+// time. A zero time returns all tasks of these projects.
+func (s *TaskService) GetChangedTasks(userId string, since time.Time) ([]*AssignedTask, error) {
+	return s.store.GetChangedTasks(userId, since)
+}
+
+// GetContributions returns all tasks of all projects the given user worked on (so every task with a history entry of
+// that user). The most recent contributions come first. Tasks of tutorial projects are not included.
+func (s *TaskService) GetContributions(userId string) ([]*Contribution, error) {
+	contributions, err := s.store.GetContributions(userId)
+	if err != nil {
+		s.Err("Unable to get contributions of user %s", userId)
+		return nil, err
+	}
+
+	return contributions, nil
+}
+
 // Delete will remove the given tasks, if the requestingUser is a member of the project these tasks are in.
 // WARNING: This method, unfortunately, doesn't check the task relation to project, so there might be broken references
 // left (from a project to a not existing task). So: USE WITH CARE!!!
@@ -163,7 +659,7 @@ func (s *TaskService) Delete(taskIds []string, requestingUserId string) error {
 		return err
 	}
 
-	err = s.store.delete(taskIds)
+	err = s.store.Delete(taskIds)
 	if err != nil {
 		return err
 	}
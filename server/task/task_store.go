@@ -0,0 +1,34 @@
+package task
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hauke96/simple-task-manager/server/storage"
+	"github.com/hauke96/simple-task-manager/server/util"
+)
+
+// The models of this package live in the "storage" package, so that the stores of all drivers can use them. See there
+// for their documentation.
+type (
+	Task               = storage.Task
+	AssignedTask       = storage.AssignedTask
+	Contribution       = storage.Contribution
+	Checklist          = storage.Checklist
+	ChecklistItem      = storage.ChecklistItem
+	TaskNote           = storage.TaskNote
+	TaskFlag           = storage.TaskFlag
+	TaskReservation    = storage.TaskReservation
+	ExpiringAssignment = storage.ExpiringAssignment
+	Handover           = storage.Handover
+	ChangesetStats     = storage.ChangesetStats
+	AreaStats          = storage.AreaStats
+	UserAreaStats      = storage.UserAreaStats
+	MemberActivity     = storage.MemberActivity
+	OsmNote            = storage.OsmNote
+	TimelineEvent      = storage.TimelineEvent
+)
+
+func getStore(ctx context.Context, tx *sql.Tx, logger *util.Logger) storage.TaskStore {
+	return storage.NewStore(storage.ComponentTask, ctx, tx, logger).(storage.TaskStore)
+}
@@ -1,12 +1,18 @@
 package task
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/osm"
+	"github.com/hauke96/simple-task-manager/server/storage"
 	"github.com/hauke96/simple-task-manager/server/util"
 	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	"strconv"
+	"time"
 )
 
 type taskRow struct {
@@ -15,31 +21,122 @@ type taskRow struct {
 	maxProcessPoints int
 	geometry         string
 	assignedUser     string
+	bbox             []float64
+	centroid         []float64
+	area             float64
+	perimeter        float64
+	version          int
+	difficulty       string
+	allowedUsers     []string
+	dependsOn        []string
+	blocked          bool
+	flagReason       string
+	flagComment      string
+	flaggedBy        string
+	flaggedAt        sql.NullTime
+	checklistDone    []string
+	openOsmNotes     int
+	locality         string
+	reservedBy       string
+	reservedEditor   string
+	reservedUntil    sql.NullTime
+	createdAt        time.Time
+	updatedAt        time.Time
+	projectId        int
+	commentTemplate  string
+	hashtagTemplates []string
+}
+
+// columns returns the columns of the "returnValues" together with the fields of the row they're scanned into. The
+// "blocked" column is computed from the tasks this task depends on, the reservation only exists while it's not expired
+// and the changeset template comes from the project.
+func (t *taskRow) columns() *database.Columns {
+	activeReservation := fmt.Sprintf("FROM %s r WHERE r.task_id = %s.id AND r.expires_at > NOW()", database.TableTaskReservations, database.TableTasks)
+
+	return new(database.Columns).
+		Add("id", &t.id).
+		Add("process_points", &t.processPoints).
+		Add("max_process_points", &t.maxProcessPoints).
+		Add("geometry", &t.geometry).
+		Add("assigned_user", &t.assignedUser).
+		Add("bbox", pq.Array(&t.bbox)).
+		Add("centroid", pq.Array(&t.centroid)).
+		Add("area", &t.area).
+		Add("perimeter", &t.perimeter).
+		Add("version", &t.version).
+		Add("difficulty", &t.difficulty).
+		Add("allowed_users", pq.Array(&t.allowedUsers)).
+		Add("depends_on", pq.Array(&t.dependsOn)).
+		Add(fmt.Sprintf("EXISTS (SELECT 1 FROM %s d WHERE d.id = ANY(%s.depends_on) AND d.process_points < d.max_process_points)", database.TableTasks, database.TableTasks), &t.blocked).
+		Add("flag_reason", &t.flagReason).
+		Add("flag_comment", &t.flagComment).
+		Add("flagged_by", &t.flaggedBy).
+		Add("flagged_at", &t.flaggedAt).
+		Add("checklist_done", pq.Array(&t.checklistDone)).
+		Add(fmt.Sprintf("(SELECT COUNT(*) FROM %s n WHERE n.task_id = %s.id AND n.open)", database.TableTaskOsmNotes, database.TableTasks), &t.openOsmNotes).
+		Add("COALESCE(locality, '')", &t.locality).
+		Add(fmt.Sprintf("COALESCE((SELECT r.user_id %s), '')", activeReservation), &t.reservedBy).
+		Add(fmt.Sprintf("COALESCE((SELECT r.editor %s), '')", activeReservation), &t.reservedEditor).
+		Add(fmt.Sprintf("(SELECT r.expires_at %s)", activeReservation), &t.reservedUntil).
+		Add("created_at", &t.createdAt).
+		Add("updated_at", &t.updatedAt).
+		Add("project_id", &t.projectId).
+		Add(fmt.Sprintf("(SELECT p.changeset_comment FROM %s p WHERE p.id = %s.project_id)", database.TableProjects, database.TableTasks), &t.commentTemplate).
+		Add(fmt.Sprintf("(SELECT p.changeset_hashtags FROM %s p WHERE p.id = %s.project_id)", database.TableProjects, database.TableTasks), pq.Array(&t.hashtagTemplates))
 }
 
 type storePg struct {
 	*util.Logger
-	tx    *sql.Tx
-	table string
+	ctx              context.Context
+	tx               *sql.Tx
+	table            database.Table
+	historyTable     database.Table
+	auditTable       database.Table
+	projectTable     database.Table
+	noteTable        database.Table
+	changesetTable   database.Table
+	osmNoteTable     database.Table
+	handoverTable    database.Table
+	reservationTable database.Table
 }
 
 var (
-	returnValues = "id, process_points, max_process_points, geometry, assigned_user"
+	returnValues = (&taskRow{}).columns().SelectList()
+
+	// Geometry of a task "t" as used for the AOI of its project
+	aoiGeometry = "ST_MakeValid(ST_SetSRID(ST_GeomFromGeoJSON(t.geometry::JSON->>'geometry'), 4326))"
 )
 
-func getStore(tx *sql.Tx, logger *util.Logger) *storePg {
+func init() {
+	storage.Register(storage.DriverPostgres, storage.ComponentTask, func(ctx context.Context, tx storage.Transaction, logger *util.Logger) interface{} {
+		return newStorePg(ctx, tx.(*sql.Tx), logger)
+	})
+}
+
+func newStorePg(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
 	return &storePg{
-		Logger: logger,
-		tx:     tx,
-		table:  "tasks",
+		Logger:           logger,
+		ctx:              ctx,
+		tx:               tx,
+		table:            database.TableTasks,
+		historyTable:     database.TableTaskHistory,
+		auditTable:       database.TableAuditLog,
+		projectTable:     database.TableProjects,
+		noteTable:        database.TableTaskNotes,
+		changesetTable:   database.TableTaskChangesets,
+		osmNoteTable:     database.TableTaskOsmNotes,
+		handoverTable:    database.TableTaskHandovers,
+		reservationTable: database.TableTaskReservations,
 	}
 }
 
-func (s *storePg) getTasks(projectId string) ([]*Task, error) {
-	query := fmt.Sprintf("SELECT id,process_points,max_process_points,geometry,assigned_user FROM %s WHERE project_id = $1;", s.table)
+func (s *storePg) GetTasks(projectId string) ([]*Task, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE project_id = $1;", returnValues, s.table)
 	s.LogQuery(query, projectId)
 
-	rows, err := s.tx.Query(query, projectId)
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, projectId)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error executing query to get tasks for project %s", projectId)
 	}
@@ -63,11 +160,45 @@ func (s *storePg) getTasks(projectId string) ([]*Task, error) {
 	return tasks, nil
 }
 
-func (s *storePg) getTask(taskId string) (*Task, error) {
-	query := fmt.Sprintf("SELECT id,process_points,max_process_points,geometry,assigned_user FROM %s WHERE id = $1;", s.table)
+func (s *storePg) GetAssignedTasks(userId string) ([]*AssignedTask, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE assigned_user = $1 ORDER BY project_id, id;", s.assignedTaskColumns(2), s.table)
+	s.LogQuery(query, userId, HistoryAssigned)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, userId, HistoryAssigned)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error executing query to get tasks assigned to user %s", userId)
+	}
+	defer rows.Close()
+
+	return rowsToAssignedTasks(rows)
+}
+
+// GetChangedTasks returns all tasks of the projects the user is member of, which have been changed after the given time.
+func (s *storePg) GetChangedTasks(userId string, since time.Time) ([]*AssignedTask, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE project_id IN (SELECT id FROM %s WHERE $1 = ANY(users)) AND updated_at > $2 ORDER BY updated_at, id;", s.assignedTaskColumns(3), s.table, s.projectTable)
+	// The "updated_at" column has no time zone, so "since" must be in UTC as well
+	s.LogQuery(query, userId, since.UTC(), HistoryAssigned)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, userId, since.UTC(), HistoryAssigned)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error executing query to get changed tasks of user %s", userId)
+	}
+	defer rows.Close()
+
+	return rowsToAssignedTasks(rows)
+}
+
+func (s *storePg) GetTask(taskId string) (*Task, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = $1;", returnValues, s.table)
 	s.LogQuery(query, taskId)
 
-	rows, err := s.tx.Query(query, taskId)
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, taskId)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error executing query to get task %s", taskId)
 	}
@@ -85,7 +216,7 @@ func (s *storePg) getTask(taskId string) (*Task, error) {
 	return task, nil
 }
 
-func (s *storePg) addTasks(newTasks []*Task, projectId string) ([]*Task, error) {
+func (s *storePg) AddTasks(newTasks []*Task, projectId string) ([]*Task, error) {
 	taskIds := make([]string, 0)
 
 	// TODO Do not add one by one but instead build one large query (otherwise it's really slow)
@@ -99,13 +230,25 @@ func (s *storePg) addTasks(newTasks []*Task, projectId string) ([]*Task, error)
 		taskIds = append(taskIds, id)
 	}
 
-	return s.getTasks(projectId)
+	query := fmt.Sprintf("UPDATE %s p SET aoi=(SELECT ST_Union(%s) FROM %s t WHERE t.project_id = p.id) WHERE p.id=$1 AND NOT p.aoi_supplied;", s.projectTable, aoiGeometry, s.table)
+	err := s.execAoiQuery(query, projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetTasks(projectId)
 }
 
 func (s *storePg) addTask(task *Task, projectId string) (string, error) {
-	query := fmt.Sprintf("INSERT INTO %s(process_points, max_process_points, geometry, assigned_user, project_id) VALUES($1, $2, $3, $4, $5) RETURNING %s;", s.table, returnValues)
-	t, err := s.execQuery(query, task.ProcessPoints, task.MaxProcessPoints, task.Geometry, task.AssignedUser, projectId)
+	query := database.InsertQuery(s.table, "process_points", "max_process_points", "geometry", "assigned_user", "project_id", "bbox", "centroid", "area", "perimeter", "difficulty") + " RETURNING " + returnValues + ";"
+	t, err := s.execQuery(query, task.ProcessPoints, task.MaxProcessPoints, task.Geometry, task.AssignedUser, projectId, pq.Array(task.BoundingBox), pq.Array(task.Centroid), task.Area, task.Perimeter, task.Difficulty)
+
+	if err != nil {
+		return "", err
+	}
 
+	query = fmt.Sprintf("UPDATE %s SET done_process_points=done_process_points+$1, total_process_points=total_process_points+$2, updated_at=NOW() WHERE id=$3;", s.projectTable)
+	err = s.execProgressQuery(query, task.ProcessPoints, task.MaxProcessPoints, projectId)
 	if err != nil {
 		return "", err
 	}
@@ -113,67 +256,1175 @@ func (s *storePg) addTask(task *Task, projectId string) (string, error) {
 	return t.Id, nil
 }
 
-func (s *storePg) assignUser(taskId, userId string) (*Task, error) {
-	query := fmt.Sprintf("UPDATE %s SET assigned_user=$1 WHERE id=$2 RETURNING %s;", s.table, returnValues)
+func (s *storePg) AssignUser(taskId, userId string) (*Task, error) {
+	query := fmt.Sprintf("UPDATE %s SET assigned_user=$1, version=version+1, updated_at=NOW() WHERE id=$2 RETURNING %s;", s.table, returnValues)
 	return s.execQuery(query, userId, taskId)
 }
 
-func (s *storePg) unassignUser(taskId string) (*Task, error) {
-	query := fmt.Sprintf("UPDATE %s SET assigned_user='' WHERE id=$1 RETURNING %s;", s.table, returnValues)
+func (s *storePg) UnassignUser(taskId string) (*Task, error) {
+	query := fmt.Sprintf("UPDATE %s SET assigned_user='', version=version+1, updated_at=NOW() WHERE id=$1 RETURNING %s;", s.table, returnValues)
 	return s.execQuery(query, taskId)
 }
 
-func (s *storePg) setProcessPoints(taskId string, newPoints int) (*Task, error) {
-	query := fmt.Sprintf("UPDATE %s SET process_points=$1 WHERE id=$2 RETURNING %s;", s.table, returnValues)
+func (s *storePg) SetProcessPoints(taskId string, newPoints int) (*Task, error) {
+	// Update the project first, since the difference to the old process points of the task is needed
+	query := fmt.Sprintf("UPDATE %s p SET done_process_points=p.done_process_points+($1-t.process_points), updated_at=NOW() FROM %s t WHERE t.id=$2 AND p.id=t.project_id;", s.projectTable, s.table)
+	err := s.execProgressQuery(query, newPoints, taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tasks depending on this one might get (un)blocked, so clients syncing via "updated_at" have to get them as well
+	query = fmt.Sprintf("UPDATE %s SET updated_at=NOW() WHERE $1 = ANY(depends_on);", s.table)
+	err = s.execDependencyQuery(query, taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET process_points=$1, version=version+1, updated_at=NOW() WHERE id=$2 RETURNING %s;", s.table, returnValues)
 	return s.execQuery(query, newPoints, taskId)
 }
 
-func (s *storePg) delete(taskIds []string) error {
-	query := fmt.Sprintf("DELETE FROM %s WHERE id=ANY($1)", s.table)
+func (s *storePg) SetChecklistDone(taskId string, itemIds []string) (*Task, error) {
+	query := fmt.Sprintf("UPDATE %s SET checklist_done=$1, version=version+1, updated_at=NOW() WHERE id=$2 RETURNING %s;", s.table, returnValues)
+	return s.execQuery(query, pq.Array(itemIds), taskId)
+}
 
-	s.LogQuery(query, taskIds)
-	_, err := s.tx.Exec(query, pq.Array(taskIds))
+// GetChecklist returns the checklist of the project of the task and whether the process points are derived from it.
+func (s *storePg) GetChecklist(taskId string) (Checklist, bool, error) {
+	query := fmt.Sprintf("SELECT p.checklist, p.checklist_points FROM %s p, %s t WHERE t.id=$1 AND p.id=t.project_id;", s.projectTable, s.table)
+	s.LogQuery(query, taskId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+
+	var checklistJson []byte
+	var derivePoints bool
+	err := s.tx.QueryRowContext(ctx, query, taskId).Scan(&checklistJson, &derivePoints)
 	if err != nil {
-		return err
+		return nil, false, errors.Wrapf(err, "error getting checklist of project of task %s", taskId)
+	}
+
+	var items Checklist
+	err = json.Unmarshal(checklistJson, &items)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "could not unmarshal checklist")
+	}
+
+	return items, derivePoints, nil
+}
+
+func (s *storePg) SetDifficulty(taskId string, difficulty string) (*Task, error) {
+	query := fmt.Sprintf("UPDATE %s SET difficulty=$1, version=version+1, updated_at=NOW() WHERE id=$2 RETURNING %s;", s.table, returnValues)
+	return s.execQuery(query, difficulty, taskId)
+}
+
+func (s *storePg) SetAllowedUsers(taskId string, allowedUsers []string) (*Task, error) {
+	query := fmt.Sprintf("UPDATE %s SET allowed_users=$1, version=version+1, updated_at=NOW() WHERE id=$2 RETURNING %s;", s.table, returnValues)
+	return s.execQuery(query, pq.Array(allowedUsers), taskId)
+}
+
+func (s *storePg) SetDependencies(taskId string, dependsOn []string) (*Task, error) {
+	query := fmt.Sprintf("UPDATE %s SET depends_on=$1, version=version+1, updated_at=NOW() WHERE id=$2 RETURNING %s;", s.table, returnValues)
+	return s.execQuery(query, pq.Array(dependsOn), taskId)
+}
+
+// GetTasksOfSameProject returns all tasks of the project the given task belongs to.
+func (s *storePg) GetTasksOfSameProject(taskId string) ([]*Task, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE project_id = (SELECT project_id FROM %s WHERE id = $1);", returnValues, s.table, s.table)
+	s.LogQuery(query, taskId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, taskId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting tasks of the project of task %s", taskId)
+	}
+	defer rows.Close()
+
+	tasks := make([]*Task, 0)
+	for rows.Next() {
+		task, err := rowToTask(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "error converting row to task")
+		}
+
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+// Flag unassigns the task and marks it as flagged by the given user.
+func (s *storePg) Flag(taskId string, reason string, comment string, userId string) (*Task, error) {
+	query := fmt.Sprintf("UPDATE %s SET assigned_user='', flag_reason=$1, flag_comment=$2, flagged_by=$3, flagged_at=NOW(), version=version+1, updated_at=NOW() WHERE id=$4 RETURNING %s;", s.table, returnValues)
+	return s.execQuery(query, reason, comment, userId, taskId)
+}
+
+func (s *storePg) ResolveFlag(taskId string) (*Task, error) {
+	query := fmt.Sprintf("UPDATE %s SET flag_reason='', flag_comment='', flagged_by='', flagged_at=NULL, version=version+1, updated_at=NOW() WHERE id=$1 RETURNING %s;", s.table, returnValues)
+	return s.execQuery(query, taskId)
+}
+
+func (s *storePg) GetNote(taskId string) (*TaskNote, error) {
+	query := fmt.Sprintf("SELECT task_id, text, updated_by, updated_at FROM %s WHERE task_id=$1;", s.noteTable)
+	notes, err := s.execNoteQuery(query, taskId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting note of task %s", taskId)
+	}
+	if len(notes) == 0 {
+		return nil, nil
+	}
+
+	return notes[0], nil
+}
+
+func (s *storePg) GetNotes(projectId string) ([]*TaskNote, error) {
+	query := fmt.Sprintf("SELECT n.task_id, n.text, n.updated_by, n.updated_at FROM %s n JOIN %s t ON t.id = n.task_id WHERE t.project_id=$1 ORDER BY n.task_id;", s.noteTable, s.table)
+	notes, err := s.execNoteQuery(query, projectId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting notes of project %s", projectId)
+	}
+
+	return notes, nil
+}
+
+func (s *storePg) SetNote(taskId string, text string, userId string) (*TaskNote, error) {
+	query := fmt.Sprintf(`INSERT INTO %s(task_id, text, updated_by) VALUES($1, $2, $3)
+ON CONFLICT (task_id) DO UPDATE SET text=EXCLUDED.text, updated_by=EXCLUDED.updated_by, updated_at=NOW()
+RETURNING task_id, text, updated_by, updated_at;`, s.noteTable)
+	notes, err := s.execNoteQuery(query, taskId, text, userId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error setting note of task %s", taskId)
+	}
+
+	return notes[0], nil
+}
+
+func (s *storePg) RemoveNote(taskId string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE task_id=$1;", s.noteTable)
+	s.LogQuery(query, taskId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, taskId)
+	if err != nil {
+		return errors.Wrapf(err, "error removing note of task %s", taskId)
 	}
 
 	return nil
 }
 
-// execQuery executed the given query, turns the result into a Task object and closes the query.
-func (s *storePg) execQuery(query string, params ...interface{}) (*Task, error) {
+func (s *storePg) execNoteQuery(query string, params ...interface{}) ([]*TaskNote, error) {
 	s.LogQuery(query, params...)
-	rows, err := s.tx.Query(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not run query")
 	}
 	defer rows.Close()
 
-	rows.Next()
-	t, err := rowToTask(rows)
+	notes := make([]*TaskNote, 0)
+	for rows.Next() {
+		var taskId int
+		note := &TaskNote{}
 
-	if t == nil && err == nil {
-		return nil, errors.New(fmt.Sprintf("Task does not exist"))
+		err = rows.Scan(&taskId, &note.Text, &note.UpdatedBy, &note.UpdatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan note")
+		}
+
+		note.TaskId = strconv.Itoa(taskId)
+		notes = append(notes, note)
 	}
 
-	return t, err
+	return notes, nil
 }
 
-// rowToTask turns the current row into a Task object. This does not close the row.
-func rowToTask(rows *sql.Rows) (*Task, error) {
-	var task taskRow
-	err := rows.Scan(&task.id, &task.processPoints, &task.maxProcessPoints, &task.geometry, &task.assignedUser)
+// GetInactiveTasks returns the tasks of not archived projects with "unassign after" hours, which are assigned longer than
+// these hours according to the latest assignment in the history and had no process point change since then. Tasks
+// without assignment in the history are left out, since the time of their assignment is unknown.
+func (s *storePg) GetInactiveTasks() ([]*Task, error) {
+	query := fmt.Sprintf(`WITH a AS (
+	SELECT h.task_id, MAX(h.created_at) AS assigned_at FROM %s h, %s t
+	WHERE h.task_id = t.id AND h.type = $1 AND h.user_id = t.assigned_user
+	GROUP BY h.task_id
+)
+SELECT %s FROM %s WHERE id IN (
+	SELECT t.id FROM %s t, %s p, a
+	WHERE a.task_id = t.id AND p.id = t.project_id AND t.assigned_user <> ''
+		AND p.unassign_after_hours > 0 AND NOT p.archived AND a.assigned_at < NOW() - p.unassign_after_hours * INTERVAL '1 hour'
+		AND NOT EXISTS (SELECT 1 FROM %s h WHERE h.task_id = t.id AND h.type = $2 AND h.created_at >= a.assigned_at)
+)
+ORDER BY id;`, s.historyTable, s.table, returnValues, s.table, s.table, s.projectTable, s.historyTable)
+	s.LogQuery(query, HistoryAssigned, HistoryProcessPointsSet)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, HistoryAssigned, HistoryProcessPointsSet)
 	if err != nil {
-		return nil, errors.Wrap(err, "could not scan rows")
+		return nil, errors.Wrap(err, "error getting inactive tasks")
 	}
+	defer rows.Close()
 
-	result := Task{}
+	tasks := make([]*Task, 0)
+	for rows.Next() {
+		task, err := rowToTask(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "error converting row to task")
+		}
 
-	result.Id = strconv.Itoa(task.id)
-	result.ProcessPoints = task.processPoints
-	result.MaxProcessPoints = task.maxProcessPoints
-	result.AssignedUser = task.assignedUser
-	result.Geometry = task.geometry
+		tasks = append(tasks, task)
+	}
 
-	return &result, err
+	return tasks, nil
+}
+
+// GetExpiringAssignments returns the assignments, which are unassigned by "GetInactiveTasks" within the last quarter of
+// the "unassign after" hours of their project, unless there's progress until then.
+func (s *storePg) GetExpiringAssignments() ([]*ExpiringAssignment, error) {
+	query := fmt.Sprintf(`WITH a AS (
+	SELECT h.task_id, MAX(h.created_at) AS assigned_at FROM %s h, %s t
+	WHERE h.task_id = t.id AND h.type = $1 AND h.user_id = t.assigned_user
+	GROUP BY h.task_id
+)
+SELECT t.id, t.project_id, p.name, t.assigned_user, a.assigned_at, a.assigned_at + p.unassign_after_hours * INTERVAL '1 hour'
+FROM %s t, %s p, a
+WHERE a.task_id = t.id AND p.id = t.project_id AND t.assigned_user <> ''
+	AND p.unassign_after_hours > 0 AND NOT p.archived
+	AND a.assigned_at < NOW() - p.unassign_after_hours * INTERVAL '45 minutes'
+	AND a.assigned_at >= NOW() - p.unassign_after_hours * INTERVAL '1 hour'
+	AND NOT EXISTS (SELECT 1 FROM %s h WHERE h.task_id = t.id AND h.type = $2 AND h.created_at >= a.assigned_at)
+ORDER BY t.id;`, s.historyTable, s.table, s.table, s.projectTable, s.historyTable)
+	s.LogQuery(query, HistoryAssigned, HistoryProcessPointsSet)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, HistoryAssigned, HistoryProcessPointsSet)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting expiring assignments")
+	}
+	defer rows.Close()
+
+	assignments := make([]*ExpiringAssignment, 0)
+	for rows.Next() {
+		var taskId, projectId int
+		assignment := &ExpiringAssignment{}
+
+		err = rows.Scan(&taskId, &projectId, &assignment.ProjectName, &assignment.UserId, &assignment.AssignedAt, &assignment.UnassignAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan expiring assignment")
+		}
+
+		assignment.TaskId = strconv.Itoa(taskId)
+		assignment.ProjectId = strconv.Itoa(projectId)
+		assignments = append(assignments, assignment)
+	}
+
+	return assignments, nil
+}
+
+func (s *storePg) AddHandover(taskId string, fromUser string, toUser string) (*Handover, error) {
+	query := fmt.Sprintf(`WITH h AS (
+	INSERT INTO %s(task_id, from_user, to_user) VALUES($1, $2, $3)
+	ON CONFLICT (task_id) DO UPDATE SET from_user=EXCLUDED.from_user, to_user=EXCLUDED.to_user, created_at=NOW()
+	RETURNING task_id, from_user, to_user, created_at
+)
+SELECT h.task_id, t.project_id, h.from_user, h.to_user, h.created_at FROM h JOIN %s t ON t.id = h.task_id;`, s.handoverTable, s.table)
+	handovers, err := s.execHandoverQuery(query, taskId, fromUser, toUser)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error adding handover of task %s", taskId)
+	}
+	if len(handovers) == 0 {
+		return nil, errors.New(fmt.Sprintf("no row returned when adding handover of task %s", taskId))
+	}
+
+	return handovers[0], nil
+}
+
+func (s *storePg) GetHandover(taskId string) (*Handover, error) {
+	query := fmt.Sprintf("SELECT h.task_id, t.project_id, h.from_user, h.to_user, h.created_at FROM %s h JOIN %s t ON t.id = h.task_id WHERE h.task_id=$1;", s.handoverTable, s.table)
+	handovers, err := s.execHandoverQuery(query, taskId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting handover of task %s", taskId)
+	}
+	if len(handovers) == 0 {
+		return nil, nil
+	}
+
+	return handovers[0], nil
+}
+
+// GetHandoversTo returns the handovers offered to the user. Handovers of tasks, which aren't assigned to the offering user
+// anymore (e.g. because they unassigned it), are left out.
+func (s *storePg) GetHandoversTo(userId string) ([]*Handover, error) {
+	query := fmt.Sprintf("SELECT h.task_id, t.project_id, h.from_user, h.to_user, h.created_at FROM %s h JOIN %s t ON t.id = h.task_id WHERE h.to_user=$1 AND t.assigned_user = h.from_user ORDER BY h.created_at;", s.handoverTable, s.table)
+	handovers, err := s.execHandoverQuery(query, userId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting handovers to user %s", userId)
+	}
+
+	return handovers, nil
+}
+
+func (s *storePg) RemoveHandover(taskId string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE task_id=$1;", s.handoverTable)
+	s.LogQuery(query, taskId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, taskId)
+	if err != nil {
+		return errors.Wrapf(err, "error removing handover of task %s", taskId)
+	}
+
+	return nil
+}
+
+// ReserveTask reserves the task for the user unless someone else has an active reservation. It returns false in that
+// case.
+func (s *storePg) ReserveTask(taskId string, userId string, editor string, duration time.Duration) (bool, error) {
+	query := fmt.Sprintf(`INSERT INTO %s(task_id, user_id, editor, expires_at) VALUES($1, $2, $3, NOW() + $4 * INTERVAL '1 second')
+ON CONFLICT (task_id) DO UPDATE SET user_id=EXCLUDED.user_id, editor=EXCLUDED.editor, expires_at=EXCLUDED.expires_at
+WHERE %s.user_id = EXCLUDED.user_id OR %s.expires_at <= NOW();`, s.reservationTable, s.reservationTable, s.reservationTable)
+	params := []interface{}{taskId, userId, editor, int(duration.Seconds())}
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	result, err := s.tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return false, errors.Wrapf(err, "error reserving task %s", taskId)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrapf(err, "error getting affected rows of reservation of task %s", taskId)
+	}
+
+	return rows != 0, nil
+}
+
+func (s *storePg) RemoveReservation(taskId string, userId string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE task_id=$1 AND user_id=$2;", s.reservationTable)
+	s.LogQuery(query, taskId, userId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, taskId, userId)
+	if err != nil {
+		return errors.Wrapf(err, "error removing reservation of task %s", taskId)
+	}
+
+	return nil
+}
+
+func (s *storePg) RemoveExpiredReservations() (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE expires_at <= NOW();", s.reservationTable)
+	s.LogQuery(query)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	result, err := s.tx.ExecContext(ctx, query)
+	if err != nil {
+		return 0, errors.Wrap(err, "error removing expired reservations")
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *storePg) execHandoverQuery(query string, params ...interface{}) ([]*Handover, error) {
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not run query")
+	}
+	defer rows.Close()
+
+	handovers := make([]*Handover, 0)
+	for rows.Next() {
+		var taskId, projectId int
+		handover := &Handover{}
+
+		err = rows.Scan(&taskId, &projectId, &handover.FromUser, &handover.ToUser, &handover.CreatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan handover")
+		}
+
+		handover.TaskId = strconv.Itoa(taskId)
+		handover.ProjectId = strconv.Itoa(projectId)
+		handovers = append(handovers, handover)
+	}
+
+	return handovers, nil
+}
+
+func (s *storePg) LinkChangeset(taskId string, changesetId string, userId string) error {
+	query := fmt.Sprintf("INSERT INTO %s(task_id, changeset_id, linked_by) VALUES($1, $2, $3) ON CONFLICT (task_id, changeset_id) DO NOTHING;", s.changesetTable)
+	s.LogQuery(query, taskId, changesetId, userId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, taskId, changesetId, userId)
+	if err != nil {
+		return errors.Wrapf(err, "error linking changeset %s to task %s", changesetId, taskId)
+	}
+
+	return nil
+}
+
+// GetChangesetStats aggregates the changesets of the project. A changeset linked to several tasks has the same values in
+// all rows, so that "DISTINCT" counts it only once.
+func (s *storePg) GetChangesetStats(projectId string) (*ChangesetStats, error) {
+	query := fmt.Sprintf(`SELECT COUNT(*), COALESCE(SUM(c.changes_count), 0), COUNT(DISTINCT c.user_id), COUNT(*) FILTER (WHERE c.fetched_at IS NULL)
+FROM (SELECT DISTINCT l.changeset_id, l.user_id, l.changes_count, l.fetched_at FROM %s l JOIN %s t ON t.id = l.task_id WHERE t.project_id=$1) c;`, s.changesetTable, s.table)
+	s.LogQuery(query, projectId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, projectId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting changeset statistics of project %s", projectId)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, errors.New(fmt.Sprintf("no row to get changeset statistics of project %s", projectId))
+	}
+
+	stats := &ChangesetStats{ProjectId: projectId}
+	err = rows.Scan(&stats.Changesets, &stats.ChangedObjects, &stats.Contributors, &stats.PendingChangesets)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not scan changeset statistics")
+	}
+
+	return stats, nil
+}
+
+func (s *storePg) GetAreaStats(projectId string) (*AreaStats, error) {
+	query := fmt.Sprintf(`SELECT COALESCE(SUM(area), 0), COALESCE(SUM(area) FILTER (WHERE process_points = max_process_points), 0),
+	COALESCE(SUM(perimeter), 0), COALESCE(SUM(perimeter) FILTER (WHERE process_points = max_process_points), 0)
+FROM %s WHERE project_id=$1;`, s.table)
+	s.LogQuery(query, projectId)
+
+	stats := &AreaStats{ProjectId: projectId}
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	err := s.tx.QueryRowContext(ctx, query, projectId).Scan(&stats.TotalArea, &stats.MappedArea, &stats.TotalPerimeter, &stats.MappedPerimeter)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting area statistics of project %s", projectId)
+	}
+
+	// The user of the latest history entry completing the task mapped it
+	query = fmt.Sprintf(`SELECT c.user_id, COUNT(*), SUM(t.area), SUM(t.perimeter)
+FROM %s t, LATERAL (SELECT h.user_id FROM %s h WHERE h.task_id = t.id AND h.type = $2 AND h.process_points = t.max_process_points ORDER BY h.created_at DESC, h.id DESC LIMIT 1) c
+WHERE t.project_id=$1 AND t.process_points = t.max_process_points
+GROUP BY c.user_id
+ORDER BY SUM(t.area) DESC, SUM(t.perimeter) DESC, c.user_id;`, s.table, s.historyTable)
+	s.LogQuery(query, projectId, HistoryProcessPointsSet)
+
+	ctx, cancel = database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, projectId, HistoryProcessPointsSet)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting area statistics of users of project %s", projectId)
+	}
+	defer rows.Close()
+
+	stats.Users = make([]*UserAreaStats, 0)
+	for rows.Next() {
+		userStats := &UserAreaStats{}
+
+		err = rows.Scan(&userStats.UserId, &userStats.CompletedTasks, &userStats.MappedArea, &userStats.MappedPerimeter)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan area statistics of user")
+		}
+
+		stats.Users = append(stats.Users, userStats)
+	}
+
+	return stats, nil
+}
+
+// GetMemberActivities returns the activity of all users with history entries of tasks of the project. The number of
+// completed tasks is not set.
+func (s *storePg) GetMemberActivities(projectId string) (map[string]*MemberActivity, error) {
+	query := fmt.Sprintf(`SELECT h.user_id, MAX(h.created_at), COUNT(DISTINCT h.task_id), COALESCE(SUM(h.points_delta) FILTER (WHERE h.type = $2), 0)
+FROM %s h, %s t
+WHERE h.task_id = t.id AND t.project_id=$1
+GROUP BY h.user_id;`, s.historyTable, s.table)
+	s.LogQuery(query, projectId, HistoryProcessPointsSet)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, projectId, HistoryProcessPointsSet)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting member activities of project %s", projectId)
+	}
+	defer rows.Close()
+
+	activities := make(map[string]*MemberActivity)
+	for rows.Next() {
+		activity := &MemberActivity{}
+
+		err = rows.Scan(&activity.UserId, &activity.LastActivity, &activity.Tasks, &activity.ProcessPoints)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan member activity")
+		}
+
+		activities[activity.UserId] = activity
+	}
+
+	return activities, nil
+}
+
+// GetChangesetsToUpdate returns the IDs of at most "limit" changesets, which were never loaded or were still open. Never
+// loaded changesets come first.
+func (s *storePg) GetChangesetsToUpdate(limit int) ([]string, error) {
+	query := fmt.Sprintf("SELECT changeset_id FROM %s WHERE fetched_at IS NULL OR open GROUP BY changeset_id ORDER BY MIN(fetched_at) NULLS FIRST, changeset_id LIMIT $1;", s.changesetTable)
+	s.LogQuery(query, limit)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting changesets to update")
+	}
+	defer rows.Close()
+
+	changesetIds := make([]string, 0)
+	for rows.Next() {
+		var changesetId int64
+		err = rows.Scan(&changesetId)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan changeset ID")
+		}
+		changesetIds = append(changesetIds, strconv.FormatInt(changesetId, 10))
+	}
+
+	return changesetIds, nil
+}
+
+func (s *storePg) UpdateChangeset(changeset *osm.Changeset) error {
+	query := fmt.Sprintf("UPDATE %s SET user_id=$2, changes_count=$3, open=$4, fetched_at=NOW() WHERE changeset_id=$1;", s.changesetTable)
+	s.LogQuery(query, changeset.Id, changeset.UserId, changeset.ChangesCount, changeset.Open)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, changeset.Id, changeset.UserId, changeset.ChangesCount, changeset.Open)
+	if err != nil {
+		return errors.Wrapf(err, "error updating changeset %s", changeset.Id)
+	}
+
+	return nil
+}
+
+// LinkOsmNote links the note to the task. The state of the note is updated for all tasks it's already linked to.
+func (s *storePg) LinkOsmNote(taskId string, noteId string, open bool, userId string) (*OsmNote, error) {
+	err := s.UpdateOsmNote(noteId, open)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s(task_id, note_id, linked_by, open) VALUES($1, $2, $3, $4)
+ON CONFLICT (task_id, note_id) DO UPDATE SET fetched_at=NOW()
+RETURNING note_id, open, linked_by, linked_at;`, s.osmNoteTable)
+	notes, err := s.execOsmNoteQuery(query, taskId, noteId, userId, open)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error linking note %s to task %s", noteId, taskId)
+	}
+
+	// The number of open notes is part of the task, so clients syncing the changed tasks have to get it again
+	query = fmt.Sprintf("UPDATE %s SET updated_at=NOW() WHERE id=$1;", s.table)
+	s.LogQuery(query, taskId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err = s.tx.ExecContext(ctx, query, taskId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error updating task %s", taskId)
+	}
+
+	return notes[0], nil
+}
+
+func (s *storePg) GetOsmNotes(taskId string) ([]*OsmNote, error) {
+	query := fmt.Sprintf("SELECT note_id, open, linked_by, linked_at FROM %s WHERE task_id=$1 ORDER BY linked_at, note_id;", s.osmNoteTable)
+	notes, err := s.execOsmNoteQuery(query, taskId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting notes of task %s", taskId)
+	}
+
+	return notes, nil
+}
+
+// GetOsmNotesToUpdate returns the IDs of at most "limit" open notes, the ones not loaded for the longest time first.
+func (s *storePg) GetOsmNotesToUpdate(limit int) ([]string, error) {
+	query := fmt.Sprintf("SELECT note_id FROM %s WHERE open GROUP BY note_id ORDER BY MIN(fetched_at), note_id LIMIT $1;", s.osmNoteTable)
+	s.LogQuery(query, limit)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting notes to update")
+	}
+	defer rows.Close()
+
+	noteIds := make([]string, 0)
+	for rows.Next() {
+		var noteId int64
+		err = rows.Scan(&noteId)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan note ID")
+		}
+		noteIds = append(noteIds, strconv.FormatInt(noteId, 10))
+	}
+
+	return noteIds, nil
+}
+
+// UpdateOsmNote stores the state of the note for all tasks it's linked to. Tasks, for which the state changes, are
+// updated as well, since the number of open notes is part of them.
+func (s *storePg) UpdateOsmNote(noteId string, open bool) error {
+	query := fmt.Sprintf("UPDATE %s SET updated_at=NOW() WHERE id IN (SELECT task_id FROM %s WHERE note_id=$1 AND open <> $2);", s.table, s.osmNoteTable)
+	s.LogQuery(query, noteId, open)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, noteId, open)
+	if err != nil {
+		return errors.Wrapf(err, "error updating tasks of note %s", noteId)
+	}
+
+	query = fmt.Sprintf("UPDATE %s SET open=$2, fetched_at=NOW() WHERE note_id=$1;", s.osmNoteTable)
+	s.LogQuery(query, noteId, open)
+
+	ctx, cancel = database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err = s.tx.ExecContext(ctx, query, noteId, open)
+	if err != nil {
+		return errors.Wrapf(err, "error updating note %s", noteId)
+	}
+
+	return nil
+}
+
+// GetTasksWithoutLocality returns at most "limit" tasks, whose locality hasn't been looked up yet. Tasks never tried
+// come first (oldest first), then the ones whose last attempt is longest ago, so failing lookups don't block newer
+// tasks. Tasks without centroid are ignored, since there's no location to look up.
+func (s *storePg) GetTasksWithoutLocality(limit int) ([]*Task, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE locality IS NULL AND cardinality(centroid) = 2 ORDER BY locality_attempted_at NULLS FIRST, id LIMIT $1;", returnValues, s.table)
+	s.LogQuery(query, limit)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting tasks without locality")
+	}
+	defer rows.Close()
+
+	tasks := make([]*Task, 0)
+	for rows.Next() {
+		task, err := rowToTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+// SetLocalityAttempted stores the current time as time of the last locality lookup of the tasks, see
+// "GetTasksWithoutLocality".
+func (s *storePg) SetLocalityAttempted(taskIds []string) error {
+	query := fmt.Sprintf("UPDATE %s SET locality_attempted_at=NOW() WHERE id = ANY($1);", s.table)
+	s.LogQuery(query, taskIds)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, pq.Array(taskIds))
+	if err != nil {
+		return errors.Wrap(err, "error setting time of locality lookup")
+	}
+
+	return nil
+}
+
+// SetLocality stores the locality of the task. An empty locality means that there's no name for the location, so
+// it's not looked up again. Like every other change, this updates the version and the "updated_at" timestamp, so that
+// clients already having the task get the locality with their next delta sync.
+func (s *storePg) SetLocality(taskId string, locality string) error {
+	query := fmt.Sprintf("UPDATE %s SET locality=$2, version=version+1, updated_at=NOW() WHERE id=$1;", s.table)
+	s.LogQuery(query, taskId, locality)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, taskId, locality)
+	if err != nil {
+		return errors.Wrapf(err, "error setting locality of task %s", taskId)
+	}
+
+	return nil
+}
+
+func (s *storePg) execOsmNoteQuery(query string, params ...interface{}) ([]*OsmNote, error) {
+	s.LogQuery(query, params...)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not run query")
+	}
+	defer rows.Close()
+
+	notes := make([]*OsmNote, 0)
+	for rows.Next() {
+		var noteId int64
+		note := &OsmNote{}
+
+		err = rows.Scan(&noteId, &note.Open, &note.LinkedBy, &note.LinkedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan note")
+		}
+
+		note.NoteId = strconv.FormatInt(noteId, 10)
+		notes = append(notes, note)
+	}
+
+	return notes, nil
+}
+
+func (s *storePg) Delete(taskIds []string) error {
+	query := fmt.Sprintf(`UPDATE %s p SET done_process_points=p.done_process_points-d.done, total_process_points=p.total_process_points-d.total, updated_at=NOW()
+FROM (SELECT project_id, SUM(process_points) AS done, SUM(max_process_points) AS total FROM %s WHERE id=ANY($1) GROUP BY project_id) d
+WHERE p.id=d.project_id;`, s.projectTable, s.table)
+	err := s.execProgressQuery(query, pq.Array(taskIds))
+	if err != nil {
+		return err
+	}
+
+	// The deleted tasks still exist here, so they are excluded explicitly
+	query = fmt.Sprintf(`UPDATE %s p SET aoi=(SELECT ST_Union(%s) FROM %s t WHERE t.project_id = p.id AND t.id <> ALL($1::INTEGER[]))
+WHERE p.id IN (SELECT project_id FROM %s WHERE id=ANY($1)) AND NOT p.aoi_supplied;`, s.projectTable, aoiGeometry, s.table, s.table)
+	err = s.execAoiQuery(query, pq.Array(taskIds))
+	if err != nil {
+		return err
+	}
+
+	// Deleted tasks can't block other tasks anymore
+	query = fmt.Sprintf("UPDATE %s SET depends_on=ARRAY(SELECT d FROM unnest(depends_on) d WHERE d <> ALL($1::INTEGER[])), updated_at=NOW() WHERE depends_on && $1::INTEGER[];", s.table)
+	err = s.execDependencyQuery(query, pq.Array(taskIds))
+	if err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf("DELETE FROM %s WHERE id=ANY($1)", s.table)
+
+	s.LogQuery(query, taskIds)
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err = s.tx.ExecContext(ctx, query, pq.Array(taskIds))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// execProgressQuery executes the query updating the process point sums of projects. These sums are kept in the
+// project table so that they don't have to be computed on every request.
+func (s *storePg) execProgressQuery(query string, params ...interface{}) error {
+	s.LogQuery(query, params...)
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return errors.Wrap(err, "error updating process points of project")
+	}
+
+	return nil
+}
+
+// execAoiQuery executes the query updating the computed AOI of projects whose tasks changed.
+func (s *storePg) execAoiQuery(query string, params ...interface{}) error {
+	s.LogQuery(query, params...)
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return errors.Wrap(err, "error updating AOI of project")
+	}
+
+	return nil
+}
+
+// GetProjectAoi returns the AOI of the project as GeoJSON geometry or an empty string when the project has none.
+func (s *storePg) GetProjectAoi(projectId string) (string, error) {
+	query := fmt.Sprintf("SELECT COALESCE(ST_AsGeoJSON(aoi), '') FROM %s WHERE id=$1;", s.projectTable)
+	s.LogQuery(query, projectId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+
+	var aoi string
+	err := s.tx.QueryRowContext(ctx, query, projectId).Scan(&aoi)
+	if err != nil {
+		return "", errors.Wrapf(err, "error getting AOI of project %s", projectId)
+	}
+
+	return aoi, nil
+}
+
+// execDependencyQuery executes the query updating tasks which depend on changed tasks.
+func (s *storePg) execDependencyQuery(query string, params ...interface{}) error {
+	s.LogQuery(query, params...)
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return errors.Wrap(err, "error updating dependent tasks")
+	}
+
+	return nil
+}
+
+// AddHistoryEntry stores what the given user did on the task. The "processPoints" are the points of the task after this
+// action and "pointsDelta" the change caused by this action.
+func (s *storePg) AddHistoryEntry(taskId string, userId string, entryType string, processPoints int, pointsDelta int) error {
+	return s.AddHistoryEntryWithComment(taskId, userId, entryType, processPoints, pointsDelta, "")
+}
+
+// AddHistoryEntryWithComment works like "AddHistoryEntry" but also stores a comment, e.g. the reason of the action. The
+// entry is added to the audit log as well, which keeps it after the task has been removed.
+func (s *storePg) AddHistoryEntryWithComment(taskId string, userId string, entryType string, processPoints int, pointsDelta int, comment string) error {
+	query := fmt.Sprintf(`WITH h AS (
+	INSERT INTO %s(task_id, user_id, type, process_points, points_delta, comment) VALUES($1, $2, $3, $4, $5, $6) RETURNING *
+)
+INSERT INTO %s(created_at, project_id, task_id, user_id, action, details)
+SELECT h.created_at, t.project_id, h.task_id, h.user_id, h.type, jsonb_build_object('processPoints', h.process_points, 'pointsDelta', h.points_delta, 'comment', h.comment)
+FROM h JOIN %s t ON t.id = h.task_id;`, s.historyTable, s.auditTable, s.table)
+	s.LogQuery(query, taskId, userId, entryType, processPoints, pointsDelta, comment)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, taskId, userId, entryType, processPoints, pointsDelta, comment)
+	if err != nil {
+		return errors.Wrapf(err, "error adding history entry for task %s", taskId)
+	}
+
+	return nil
+}
+
+func (s *storePg) GetContributions(userId string) ([]*Contribution, error) {
+	query := fmt.Sprintf(`SELECT h.task_id, t.project_id, SUM(h.points_delta), t.max_process_points,
+	BOOL_OR(h.type = $2 AND h.process_points = t.max_process_points),
+	t.area, t.perimeter, MIN(h.created_at), MAX(h.created_at)
+FROM %s h, %s t, %s p
+WHERE h.task_id = t.id AND t.project_id = p.id AND h.user_id = $1 AND h.type <> $3 AND NOT p.tutorial
+GROUP BY h.task_id, t.project_id, t.max_process_points, t.area, t.perimeter
+ORDER BY MAX(h.created_at) DESC;`, s.historyTable, s.table, s.projectTable)
+	s.LogQuery(query, userId, HistoryProcessPointsSet, HistoryReopened)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, userId, HistoryProcessPointsSet, HistoryReopened)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error executing query to get contributions of user %s", userId)
+	}
+	defer rows.Close()
+
+	contributions := make([]*Contribution, 0)
+	for rows.Next() {
+		var taskId, projectId int
+		c := &Contribution{}
+
+		err = rows.Scan(&taskId, &projectId, &c.ProcessPoints, &c.MaxProcessPoints, &c.Completed, &c.Area, &c.Perimeter, &c.FirstActivity, &c.LastActivity)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan contribution")
+		}
+
+		c.TaskId = strconv.Itoa(taskId)
+		c.ProjectId = strconv.Itoa(projectId)
+
+		contributions = append(contributions, c)
+	}
+
+	return contributions, nil
+}
+
+// GetGeometryTypes returns the geometry types allowed for tasks of the project.
+func (s *storePg) GetGeometryTypes(projectId string) ([]string, error) {
+	query := fmt.Sprintf("SELECT geometry_types FROM %s WHERE id = $1;", s.projectTable)
+	s.LogQuery(query, projectId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, projectId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting geometry types of project %s", projectId)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, errors.New(fmt.Sprintf("project %s does not exist", projectId))
+	}
+
+	var geometryTypes []string
+	err = rows.Scan(pq.Array(&geometryTypes))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not scan geometry types")
+	}
+
+	return geometryTypes, nil
+}
+
+// GetTimeline returns the history entries of all tasks of the project, oldest first.
+func (s *storePg) GetTimeline(projectId string) ([]*TimelineEvent, error) {
+	query := fmt.Sprintf(`SELECT h.task_id, h.user_id, h.type, h.process_points, h.points_delta, h.comment, h.created_at
+FROM %s h, %s t
+WHERE h.task_id = t.id AND t.project_id = $1
+ORDER BY h.created_at, h.id;`, s.historyTable, s.table)
+	s.LogQuery(query, projectId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, projectId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error executing query to get timeline of project %s", projectId)
+	}
+	defer rows.Close()
+
+	events := make([]*TimelineEvent, 0)
+	for rows.Next() {
+		var taskId int
+		e := &TimelineEvent{}
+
+		err = rows.Scan(&taskId, &e.UserId, &e.Type, &e.ProcessPoints, &e.PointsDelta, &e.Comment, &e.CreatedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan timeline event")
+		}
+
+		e.TaskId = strconv.Itoa(taskId)
+
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// GetLastMapper returns the user who set the process points of the task most recently, an empty string if nobody did.
+func (s *storePg) GetLastMapper(taskId string) (string, error) {
+	query := fmt.Sprintf("SELECT user_id FROM %s WHERE task_id = $1 AND type = $2 ORDER BY created_at DESC, id DESC LIMIT 1;", s.historyTable)
+	s.LogQuery(query, taskId, HistoryProcessPointsSet)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, taskId, HistoryProcessPointsSet)
+	if err != nil {
+		return "", errors.Wrapf(err, "error getting last mapper of task %s", taskId)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", nil
+	}
+
+	var userId string
+	err = rows.Scan(&userId)
+	if err != nil {
+		return "", errors.Wrap(err, "could not scan last mapper")
+	}
+
+	return userId, nil
+}
+
+// CountAssignedTasks counts the tasks assigned to the user within the project of the given task.
+func (s *storePg) CountAssignedTasks(taskId string, userId string) (int, error) {
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s t, %s o WHERE o.id = $1 AND t.project_id = o.project_id AND t.assigned_user = $2;", s.table, s.table)
+	return s.execCountQuery(query, taskId, userId)
+}
+
+// CountCompletionsToday counts the tasks within the project of the given task, which the user completed (set to their
+// maximum process points) since the start of the current day.
+func (s *storePg) CountCompletionsToday(taskId string, userId string) (int, error) {
+	query := fmt.Sprintf(`SELECT COUNT(DISTINCT h.task_id)
+FROM %s h, %s t, %s o
+WHERE o.id = $1 AND t.project_id = o.project_id AND h.task_id = t.id AND h.user_id = $2
+	AND h.type = $3 AND h.process_points = t.max_process_points AND h.created_at >= CURRENT_DATE;`, s.historyTable, s.table, s.table)
+	return s.execCountQuery(query, taskId, userId, HistoryProcessPointsSet)
+}
+
+// execCountQuery executes the given query, which must return exactly one number.
+func (s *storePg) execCountQuery(query string, params ...interface{}) (int, error) {
+	s.LogQuery(query, params...)
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not run count query")
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return 0, errors.New("count query returned no row")
+	}
+
+	var count int
+	err = rows.Scan(&count)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not scan count")
+	}
+
+	return count, nil
+}
+
+// execQuery executed the given query, turns the result into a Task object and closes the query.
+func (s *storePg) execQuery(query string, params ...interface{}) (*Task, error) {
+	s.LogQuery(query, params...)
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not run query")
+	}
+	defer rows.Close()
+
+	rows.Next()
+	t, err := rowToTask(rows)
+
+	if t == nil && err == nil {
+		return nil, errors.New(fmt.Sprintf("Task does not exist"))
+	}
+
+	return t, err
+}
+
+// assignedTaskColumns are the "returnValues" followed by the ID and name of the project and the time of the latest
+// assignment. The query has to pass "HistoryAssigned" as parameter with the given number. Use "rowsToAssignedTasks" to
+// read the result.
+func (s *storePg) assignedTaskColumns(historyTypeParam int) string {
+	return fmt.Sprintf("%s, project_id, (SELECT p.name FROM %s p WHERE p.id = %s.project_id), (SELECT MAX(h.created_at) FROM %s h WHERE h.task_id = %s.id AND h.type = $%d)",
+		returnValues, s.projectTable, s.table, s.historyTable, s.table, historyTypeParam)
+}
+
+// rowsToAssignedTasks reads all rows of a query selecting the "assignedTaskColumns". This does not close the rows.
+func rowsToAssignedTasks(rows *sql.Rows) ([]*AssignedTask, error) {
+	tasks := make([]*AssignedTask, 0)
+	for rows.Next() {
+		var projectId int
+		var projectName string
+		var assignedAt sql.NullTime
+
+		task, err := rowToTask(rows, &projectId, &projectName, &assignedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "error converting row to task")
+		}
+
+		assignedTask := &AssignedTask{
+			Task:        task,
+			ProjectId:   strconv.Itoa(projectId),
+			ProjectName: projectName,
+		}
+		if task.AssignedUser != "" && assignedAt.Valid {
+			assignedTask.AssignedAt = &assignedAt.Time
+		}
+
+		tasks = append(tasks, assignedTask)
+	}
+
+	return tasks, nil
+}
+
+// rowToTask turns the current row into a Task object. This does not close the row. Queries selecting additional columns
+// after the "returnValues" have to pass destinations for them.
+func rowToTask(rows *sql.Rows, additionalColumns ...interface{}) (*Task, error) {
+	var task taskRow
+	err := rows.Scan(task.columns().Destinations(additionalColumns...)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not scan rows")
+	}
+
+	result := Task{}
+
+	result.Id = strconv.Itoa(task.id)
+	result.ProcessPoints = task.processPoints
+	result.MaxProcessPoints = task.maxProcessPoints
+	result.AssignedUser = task.assignedUser
+	result.Geometry = task.geometry
+	result.BoundingBox = task.bbox
+	result.Centroid = task.centroid
+	result.Area = task.area
+	result.Perimeter = task.perimeter
+	result.Version = task.version
+	result.Difficulty = task.difficulty
+	result.AllowedUsers = task.allowedUsers
+	result.DependsOn = task.dependsOn
+	result.Blocked = task.blocked
+	result.ChecklistDone = task.checklistDone
+	result.OpenOsmNotes = task.openOsmNotes
+	result.Locality = task.locality
+	result.CreatedAt = task.createdAt
+	result.UpdatedAt = task.updatedAt
+	result.ChangesetComment, result.ChangesetHashtags = ExpandChangesetTemplate(task.commentTemplate, task.hashtagTemplates, result.Id, strconv.Itoa(task.projectId))
+	if task.reservedBy != "" {
+		result.Reservation = &TaskReservation{
+			UserId:    task.reservedBy,
+			Editor:    task.reservedEditor,
+			ExpiresAt: task.reservedUntil.Time,
+		}
+	}
+	if task.flagReason != "" {
+		result.Flag = &TaskFlag{
+			Reason:    task.flagReason,
+			Comment:   task.flagComment,
+			UserId:    task.flaggedBy,
+			CreatedAt: task.flaggedAt.Time,
+		}
+	}
+
+	return &result, err
+}
+
+// Name of the savepoint of "SetSavepoint". Savepoints can't be nested this way, which is enough for applying one
+// operation after another.
+const savepointName = "task_operation"
+
+// SetSavepoint marks the current state of the transaction, so that later changes can be undone by
+// "RollbackToSavepoint" without rolling back the whole transaction.
+func (s *storePg) SetSavepoint() error {
+	return s.execSavepointQuery("SAVEPOINT " + savepointName + ";")
+}
+
+// ReleaseSavepoint keeps the changes made since "SetSavepoint".
+func (s *storePg) ReleaseSavepoint() error {
+	return s.execSavepointQuery("RELEASE SAVEPOINT " + savepointName + ";")
+}
+
+// RollbackToSavepoint undoes all changes made since "SetSavepoint". This also works when a query failed in the meantime,
+// which otherwise aborts the whole transaction.
+func (s *storePg) RollbackToSavepoint() error {
+	return s.execSavepointQuery("ROLLBACK TO SAVEPOINT " + savepointName + ";")
+}
+
+func (s *storePg) execSavepointQuery(query string) error {
+	s.LogQuery(query)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query)
+	if err != nil {
+		return errors.Wrap(err, "error executing savepoint query")
+	}
+
+	return nil
 }
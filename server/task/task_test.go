@@ -1,16 +1,26 @@
 package task
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/hauke96/sigolo"
 	"github.com/hauke96/simple-task-manager/server/config"
 	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/notification"
+	"github.com/hauke96/simple-task-manager/server/osm"
 	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/privacy"
 	"github.com/hauke96/simple-task-manager/server/test"
 	"github.com/hauke96/simple-task-manager/server/util"
+	geojson "github.com/paulmach/go.geojson"
 	"github.com/pkg/errors"
+	"image/png"
+	"math"
+	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/lib/pq" // Make driver "postgres" usable
 )
@@ -36,15 +46,17 @@ func setup() {
 
 	logger := util.NewLogger()
 
+	ctx := context.Background()
+
 	var err error
-	tx, err = database.GetTransaction(logger)
+	tx, err = database.GetTransaction(ctx, logger)
 	if err != nil {
 		panic(err)
 	}
 
 	h.Tx = tx
-	permissionService := permission.Init(tx, logger)
-	s = Init(tx, logger, permissionService)
+	permissionService := permission.Init(ctx, tx, logger)
+	s = Init(ctx, tx, logger, permissionService)
 }
 
 func TestGetTasks(t *testing.T) {
@@ -78,6 +90,23 @@ func TestGetTasks(t *testing.T) {
 	})
 }
 
+func TestGetTasksCancelledContext(t *testing.T) {
+	h.Run(t, func() error {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// The transaction of the test helper is not affected, only the queries of this service instance
+		cancelledService := Init(ctx, tx, s.Logger, permission.Init(ctx, tx, s.Logger))
+
+		_, err := cancelledService.GetTasks("3", "Otto")
+		if err == nil {
+			return errors.New("Getting tasks with cancelled context should not work")
+		}
+
+		return nil
+	})
+}
+
 func TestGetTasksUnknownProject(t *testing.T) {
 	h.Run(t, func() error {
 		_, err := s.GetTasks("42", "Clara")
@@ -90,6 +119,152 @@ func TestGetTasksUnknownProject(t *testing.T) {
 	})
 }
 
+func TestGetSimplifiedTasks(t *testing.T) {
+	h.Run(t, func() error {
+		tasks, err := s.GetSimplifiedTasks("2", 0.01, "Maria")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error: %s\n", err.Error()))
+		}
+
+		if len(tasks) != 5 {
+			return errors.New(fmt.Sprintf("Expected 5 tasks but got %d", len(tasks)))
+		}
+
+		feature, err := geojson.UnmarshalFeature([]byte(tasks[0].Geometry))
+		if err != nil {
+			return errors.Wrap(err, "simplified geometry should be valid GeoJSON")
+		}
+		if feature.Geometry.Type != geojson.GeometryPolygon {
+			return errors.New("simplified geometry should still be a polygon")
+		}
+
+		_, err = s.GetSimplifiedTasks("2", 0, "Maria")
+		if err == nil {
+			return errors.New("Tolerance of 0 should not be allowed")
+		}
+
+		_, err = s.GetSimplifiedTasks("2", 0.01, "Peter")
+		if err == nil {
+			return errors.New("Peter is not a member of project 2")
+		}
+
+		return nil
+	})
+}
+
+func TestGetTask(t *testing.T) {
+	h.Run(t, func() error {
+		task, err := s.GetTask("3", "John")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error: %s\n", err.Error()))
+		}
+
+		if task.Id != "3" || task.ProcessPoints != 50 || task.AssignedUser != "Maria" {
+			return errors.New(fmt.Sprintf("Task does not match: %#v", task))
+		}
+
+		_, err = s.GetTask("3", "Peter")
+		if err == nil {
+			return errors.New("Peter is not a member of project 2")
+		}
+
+		return nil
+	})
+}
+
+func TestSimplifyGeometry(t *testing.T) {
+	// Square with an additional point slightly off the lower edge
+	geometry := "{\"type\":\"Feature\",\"geometry\":{\"type\":\"Polygon\",\"coordinates\":[[[0,0],[0.5,0.001],[1,0],[1,1],[0,1],[0,0]]]},\"properties\":null}"
+
+	simplified, err := simplifyGeometry(geometry, 0.01)
+	if err != nil {
+		t.Errorf("Error: %s", err.Error())
+		return
+	}
+
+	feature, err := geojson.UnmarshalFeature([]byte(simplified))
+	if err != nil {
+		t.Errorf("Error: %s", err.Error())
+		return
+	}
+
+	ring := feature.Geometry.Polygon[0]
+	if len(ring) != 5 {
+		t.Errorf("Expected 5 points after simplification but got %d: %v", len(ring), ring)
+	}
+
+	// With a very small tolerance, nothing should be removed
+	simplified, err = simplifyGeometry(geometry, 0.0001)
+	if err != nil {
+		t.Errorf("Error: %s", err.Error())
+		return
+	}
+
+	feature, _ = geojson.UnmarshalFeature([]byte(simplified))
+	if len(feature.Geometry.Polygon[0]) != 6 {
+		t.Errorf("Expected all 6 points to stay but got %v", feature.Geometry.Polygon[0])
+	}
+
+	// With a huge tolerance the ring would degenerate, so it has to stay untouched
+	simplified, err = simplifyGeometry(geometry, 10)
+	if err != nil {
+		t.Errorf("Error: %s", err.Error())
+		return
+	}
+
+	feature, _ = geojson.UnmarshalFeature([]byte(simplified))
+	if len(feature.Geometry.Polygon[0]) != 6 {
+		t.Errorf("Degenerated ring should not be simplified but got %v", feature.Geometry.Polygon[0])
+	}
+}
+
+func TestBoundingBoxAndCentroid(t *testing.T) {
+	ring := [][]float64{{0, 0}, {2, 0}, {2, 1}, {0, 1}, {0, 0}}
+
+	bbox := boundingBox(ring)
+	if len(bbox) != 4 || bbox[0] != 0 || bbox[1] != 0 || bbox[2] != 2 || bbox[3] != 1 {
+		t.Errorf("Bounding box not matching: %v", bbox)
+	}
+
+	c := centroid(ring)
+	if len(c) != 2 || c[0] != 1 || c[1] != 0.5 {
+		t.Errorf("Centroid not matching: %v", c)
+	}
+
+	// Degenerated ring without area
+	c = centroid([][]float64{{0, 0}, {1, 0}})
+	if c[0] != 0.5 || c[1] != 0 {
+		t.Errorf("Centroid of degenerated ring not matching: %v", c)
+	}
+}
+
+func TestAreaAndPerimeter(t *testing.T) {
+	// One degree at the equator
+	square := &taskShape{geometryType: GeometryTypePolygon, coordinates: [][]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}}
+	if a := square.area(); math.Abs(a-12363.7) > 1 {
+		t.Errorf("Area of square should be about 12363.7 km² but was %f", a)
+	}
+	if p := square.perimeter(); math.Abs(p-444.76) > 0.1 {
+		t.Errorf("Perimeter of square should be about 444.76 km but was %f", p)
+	}
+
+	// The orientation of the ring doesn't matter
+	reversed := &taskShape{geometryType: GeometryTypePolygon, coordinates: [][]float64{{0, 0}, {0, 1}, {1, 1}, {1, 0}, {0, 0}}}
+	if reversed.area() != square.area() {
+		t.Errorf("Area of reversed square %f does not match %f", reversed.area(), square.area())
+	}
+
+	line := &taskShape{geometryType: GeometryTypeLineString, coordinates: [][]float64{{0, 0}, {0, 1}, {0, 2}}}
+	if line.area() != 0 || math.Abs(line.perimeter()-222.39) > 0.1 {
+		t.Errorf("Line should have no area and a length of about 222.39 km but has %f and %f", line.area(), line.perimeter())
+	}
+
+	point := &taskShape{geometryType: GeometryTypePoint, coordinates: [][]float64{{9.9, 53.5}}}
+	if point.area() != 0 || point.perimeter() != 0 {
+		t.Errorf("Point should neither have area nor perimeter but has %f and %f", point.area(), point.perimeter())
+	}
+}
+
 func TestAddTasks(t *testing.T) {
 	h.Run(t, func() error {
 		rawTask := &Task{
@@ -110,6 +285,79 @@ func TestAddTasks(t *testing.T) {
 			addedTask.ProcessPoints != 5 {
 			return errors.New(fmt.Sprintf("Added task does not match:\n%v\n%v\n", rawTask, addedTask))
 		}
+
+		if len(addedTask.BoundingBox) != 4 ||
+			addedTask.BoundingBox[0] != 0 || addedTask.BoundingBox[1] != 0 ||
+			addedTask.BoundingBox[2] != 1 || addedTask.BoundingBox[3] != 0 {
+			return errors.New(fmt.Sprintf("Bounding box of added task does not match: %v", addedTask.BoundingBox))
+		}
+
+		if len(addedTask.Centroid) != 2 || addedTask.Centroid[0] != 0.5 || addedTask.Centroid[1] != 0 {
+			return errors.New(fmt.Sprintf("Centroid of added task does not match: %v", addedTask.Centroid))
+		}
+		return nil
+	})
+}
+
+func TestOverlapRatio(t *testing.T) {
+	square := [][]float64{{0, 0}, {2, 0}, {2, 2}, {0, 2}, {0, 0}}
+
+	if r := overlapRatio(square, square); r != 1 {
+		t.Errorf("Identical rings should have ratio 1 but was %f", r)
+	}
+
+	// Same square but slightly moved
+	moved := [][]float64{{0.02, 0}, {2.02, 0}, {2.02, 2}, {0.02, 2}, {0.02, 0}}
+	if r := overlapRatio(square, moved); r < 0.95 {
+		t.Errorf("Nearly identical rings should have high ratio but was %f", r)
+	}
+
+	// Neighboring square sharing one edge
+	neighbor := [][]float64{{2, 0}, {4, 0}, {4, 2}, {2, 2}, {2, 0}}
+	if r := overlapRatio(square, neighbor); r != 0 {
+		t.Errorf("Neighboring rings should have ratio 0 but was %f", r)
+	}
+
+	// Half overlapping square
+	half := [][]float64{{1, 0}, {3, 0}, {3, 2}, {1, 2}, {1, 0}}
+	if r := overlapRatio(square, half); r < 0.3 || r > 0.36 {
+		t.Errorf("Half overlapping rings should have ratio of 1/3 but was %f", r)
+	}
+}
+
+func TestAddTasksDuplicates(t *testing.T) {
+	h.Run(t, func() error {
+		// Same geometry as existing task 3
+		rawTask := &Task{
+			ProcessPoints:    0,
+			MaxProcessPoints: 10,
+			Geometry:         "{\"type\":\"Feature\",\"geometry\":{\"type\":\"Polygon\",\"coordinates\":[[[9.944421814136854,53.56429528684478],[9.944078491382948,53.56200127796407],[9.94528012102162,53.56195029857588],[9.946653412037245,53.56429528684478],[9.944421814136854,53.56429528684478]]]},\"properties\":null}",
+		}
+
+		_, err := s.AddTasks([]*Task{rawTask}, "2")
+		if err == nil {
+			return errors.New("adding task with geometry of existing task should fail")
+		}
+
+		// Two new tasks with same geometry
+		rawTask.Geometry = "{\"type\":\"Feature\",\"geometry\":{\"type\":\"Polygon\",\"coordinates\":[[[1,1],[2,1],[2,2],[1,2],[1,1]]]},\"properties\":null}"
+		otherTask := &Task{
+			ProcessPoints:    0,
+			MaxProcessPoints: 10,
+			Geometry:         rawTask.Geometry,
+		}
+
+		_, err = s.AddTasks([]*Task{rawTask, otherTask}, "2")
+		if err == nil {
+			return errors.New("adding two tasks with same geometry should fail")
+		}
+
+		// Adding it once is fine
+		_, err = s.AddTasks([]*Task{rawTask}, "2")
+		if err != nil {
+			return errors.Wrap(err, "adding task without duplicate should work")
+		}
+
 		return nil
 	})
 }
@@ -208,6 +456,45 @@ func TestAddTasksInvalidGeometry(t *testing.T) {
 	})
 }
 
+func TestAddTasksWithGeometryTypes(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := tx.Exec("UPDATE projects SET geometry_types='{Polygon,LineString,Point}' WHERE id=1;")
+		if err != nil {
+			return err
+		}
+
+		line := &Task{
+			MaxProcessPoints: 10,
+			Geometry:         "{\"type\":\"Feature\",\"geometry\":{\"type\":\"LineString\",\"coordinates\":[[10,10],[11,10]]},\"properties\":null}",
+		}
+		point := &Task{
+			MaxProcessPoints: 10,
+			Geometry:         "{\"type\":\"Feature\",\"geometry\":{\"type\":\"Point\",\"coordinates\":[20,20]},\"properties\":null}",
+		}
+
+		addedTasks, err := s.AddTasks([]*Task{line, point}, "1")
+		if err != nil {
+			return errors.Wrap(err, "adding line and point should work when the project allows them")
+		}
+		if len(addedTasks) != 2 {
+			return errors.New(fmt.Sprintf("Expected 2 added tasks but got %d", len(addedTasks)))
+		}
+
+		_, err = tx.Exec("UPDATE projects SET geometry_types='{Point}' WHERE id=1;")
+		if err != nil {
+			return err
+		}
+
+		line.Geometry = "{\"type\":\"Feature\",\"geometry\":{\"type\":\"LineString\",\"coordinates\":[[30,30],[31,30]]},\"properties\":null}"
+		_, err = s.AddTasks([]*Task{line}, "1")
+		if err == nil {
+			return errors.New("adding line to project only allowing points should fail")
+		}
+
+		return nil
+	})
+}
+
 func TestAssignUser(t *testing.T) {
 	h.Run(t, func() error {
 		task, err := s.AssignUser("2", "assigned-user")
@@ -234,6 +521,172 @@ func TestAssignUser(t *testing.T) {
 	})
 }
 
+func TestGetTimeline(t *testing.T) {
+	h.Run(t, func() error {
+		events, err := s.GetTimeline("2", "John")
+		if err != nil {
+			return err
+		}
+
+		if len(events) != 7 {
+			return errors.New(fmt.Sprintf("Expected 7 events but got %d", len(events)))
+		}
+
+		for i := 1; i < len(events); i++ {
+			if events[i].CreatedAt.Before(events[i-1].CreatedAt) {
+				return errors.New(fmt.Sprintf("Events not in chronological order: %v before %v", events[i-1].CreatedAt, events[i].CreatedAt))
+			}
+		}
+
+		last := events[len(events)-1]
+		if last.TaskId != "7" || last.DoneProcessPoints != 153 {
+			return errors.New(fmt.Sprintf("Last event should be on task 7 with 153 done points but was %#v", last))
+		}
+
+		_, err = s.GetTimeline("2", "Otto")
+		if err == nil {
+			return errors.New("Non-member Otto should not be able to get the timeline")
+		}
+
+		return nil
+	})
+}
+
+func TestAnonymization(t *testing.T) {
+	h.Run(t, func() error {
+		err := privacy.Configure(true, "test-key")
+		if err != nil {
+			return err
+		}
+		defer privacy.Configure(false, "")
+
+		// Members get pseudonyms
+		events, err := s.GetTimeline("2", "John")
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if !strings.HasPrefix(e.UserId, "mapper-") {
+				return errors.New(fmt.Sprintf("User of event should be anonymized for members: %#v", e))
+			}
+		}
+
+		data, err := s.Export("2", ExportFormatCsv, "John")
+		if err != nil {
+			return err
+		}
+		if strings.Contains(string(data), "Maria") || !strings.Contains(string(data), privacy.Pseudonym("Maria")) {
+			return errors.New(fmt.Sprintf("Assigned users should be anonymized in exports of members: %s", string(data)))
+		}
+
+		tasks, err := s.GetSharedTasks("2")
+		if err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if task.Id == "3" && task.AssignedUser != privacy.Pseudonym("Maria") {
+				return errors.New(fmt.Sprintf("Assigned user of shared task should be anonymized: %s", task.AssignedUser))
+			}
+		}
+
+		// The owner gets the real names
+		events, err = s.GetTimeline("2", "Maria")
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if strings.HasPrefix(e.UserId, "mapper-") {
+				return errors.New(fmt.Sprintf("User of event should not be anonymized for the owner: %#v", e))
+			}
+		}
+
+		data, err = s.Export("2", ExportFormatCsv, "Maria")
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(string(data), "Maria") {
+			return errors.New(fmt.Sprintf("Assigned users should not be anonymized in exports of the owner: %s", string(data)))
+		}
+
+		return nil
+	})
+}
+
+func TestAnonymizeSharedTasks(t *testing.T) {
+	tasks := []*Task{
+		{
+			Id:           "1",
+			AssignedUser: "Maria",
+			AllowedUsers: []string{"Maria", "John"},
+			Flag:         &TaskFlag{UserId: "John"},
+			Reservation:  &TaskReservation{UserId: "Maria"},
+		},
+	}
+
+	// Without anonymization, the user IDs are removed
+	anonymizeSharedTasks(tasks)
+	task := tasks[0]
+	if task.AssignedUser != "" || len(task.AllowedUsers) != 0 || task.Flag.UserId != "" || task.Reservation.UserId != "" {
+		t.Errorf("User IDs of shared task should be removed: %#v", task)
+	}
+
+	err := privacy.Configure(true, "test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer privacy.Configure(false, "")
+
+	task.AssignedUser = "Maria"
+	task.AllowedUsers = []string{"Maria"}
+	task.Flag.UserId = "John"
+	anonymizeSharedTasks(tasks)
+	if task.AssignedUser != privacy.Pseudonym("Maria") || len(task.AllowedUsers) != 0 || task.Flag.UserId != privacy.Pseudonym("John") {
+		t.Errorf("User IDs of shared task should be pseudonyms: %#v", task)
+	}
+}
+
+func TestAssignmentLimits(t *testing.T) {
+	h.Run(t, func() error {
+		// Maria has task 3 of project 2 assigned
+		_, err := tx.Exec("UPDATE projects SET max_assigned_tasks=1 WHERE id=2;")
+		if err != nil {
+			return err
+		}
+
+		_, err = s.AssignUser("4", "Maria")
+		if err == nil {
+			return errors.New("Assigning more tasks than allowed at the same time should not work")
+		}
+
+		_, err = tx.Exec("UPDATE projects SET max_assigned_tasks=0, max_completions_per_day=1 WHERE id=2;")
+		if err != nil {
+			return err
+		}
+
+		// Donny has task 7 assigned and completes it
+		_, err = s.SetProcessPoints("7", 4, "Donny")
+		if err != nil {
+			return err
+		}
+
+		_, err = s.AssignUser("4", "Donny")
+		if err == nil {
+			return errors.New("Getting a task assigned after completing the allowed number of tasks today should not work")
+		}
+
+		// Other users are not affected
+		task, err := s.AssignUser("4", "John")
+		if err != nil {
+			return err
+		}
+		if task.AssignedUser != "John" {
+			return errors.New(fmt.Sprintf("Task should be assigned to John but was assigned to '%s'", task.AssignedUser))
+		}
+
+		return nil
+	})
+}
+
 func TestAssignUserTwice(t *testing.T) {
 	h.Run(t, func() error {
 		_, err := s.AssignUser("4", "foo-bar")
@@ -326,6 +779,1688 @@ func TestSetProcessPoints(t *testing.T) {
 	})
 }
 
+func TestSetChecklistDone(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := tx.Exec(`UPDATE projects SET checklist='[{"id":"1","text":"Buildings"},{"id":"2","text":"Roads"},{"id":"3","text":"Addresses"},{"id":"4","text":"Review"}]' WHERE id=2;`)
+		if err != nil {
+			return err
+		}
+
+		task, err := s.SetChecklistDone("3", []string{"3", "1"}, "Maria")
+		if err != nil {
+			return err
+		}
+		if len(task.ChecklistDone) != 2 || task.ChecklistDone[0] != "1" || task.ChecklistDone[1] != "3" {
+			return errors.New(fmt.Sprintf("Completed items should be stored in the order of the checklist: %v", task.ChecklistDone))
+		}
+		if task.ProcessPoints != 50 {
+			return errors.New(fmt.Sprintf("Process points shouldn't change without deriving them: %d", task.ProcessPoints))
+		}
+
+		_, err = s.SetChecklistDone("3", []string{"5"}, "Maria")
+		if err == nil {
+			return errors.New("Unknown checklist items should not be completable")
+		}
+
+		_, err = s.SetChecklistDone("3", []string{"1"}, "John")
+		if err == nil {
+			return errors.New("Only the assigned user should be able to complete checklist items")
+		}
+
+		// Derive process points from the checklist
+		_, err = tx.Exec("UPDATE projects SET checklist_points=true WHERE id=2;")
+		if err != nil {
+			return err
+		}
+
+		task, err = s.SetChecklistDone("3", []string{"1", "2", "3"}, "Maria")
+		if err != nil {
+			return err
+		}
+		if task.ProcessPoints != 75 {
+			return errors.New(fmt.Sprintf("Three of four items should result in 75 process points but got %d", task.ProcessPoints))
+		}
+
+		_, err = s.SetProcessPoints("3", 100, "Maria")
+		if err == nil {
+			return errors.New("Derived process points should not be settable directly")
+		}
+
+		task, err = s.SetChecklistDone("3", []string{"1", "2", "3", "4"}, "Maria")
+		if err != nil {
+			return err
+		}
+		if task.ProcessPoints != 100 {
+			return errors.New(fmt.Sprintf("Completing all items should complete the task but got %d points", task.ProcessPoints))
+		}
+
+		return nil
+	})
+}
+
+func TestPrepareChecklist(t *testing.T) {
+	current := Checklist{{Id: "1", Text: "Buildings"}, {Id: "3", Text: "Roads"}}
+
+	checklist, err := PrepareChecklist(current, Checklist{{Id: "3", Text: " Streets "}, {Text: "Addresses"}, {Text: "Review"}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(checklist) != 3 || checklist[0].Id != "3" || checklist[0].Text != "Streets" || checklist[1].Id != "4" || checklist[2].Id != "5" {
+		t.Errorf("Existing items should keep their ID and new items should get the next ones: %v, %v, %v", checklist[0], checklist[1], checklist[2])
+	}
+
+	for _, invalid := range []Checklist{
+		{{Id: "2", Text: "Unknown"}},
+		{{Id: "1", Text: "Buildings"}, {Id: "1", Text: "Buildings again"}},
+		{{Text: "  "}},
+		{{Text: strings.Repeat("a", maxChecklistItemLength+1)}},
+		{nil},
+	} {
+		_, err = PrepareChecklist(current, invalid)
+		if err == nil {
+			t.Errorf("Checklist %v should be invalid", invalid)
+		}
+	}
+
+	tooLong := make(Checklist, maxChecklistItems+1)
+	for i := range tooLong {
+		tooLong[i] = &ChecklistItem{Text: "Item"}
+	}
+	_, err = PrepareChecklist(nil, tooLong)
+	if err == nil {
+		t.Errorf("Checklist with more than %d items should be invalid", maxChecklistItems)
+	}
+}
+
+func TestVerifyPointStep(t *testing.T) {
+	for _, points := range []int{0, 10, 90, 95} {
+		err := verifyPointStep(points, 95, 10)
+		if err != nil {
+			t.Errorf("%d points should be allowed with step 10 and maximum 95: %s", points, err)
+		}
+	}
+
+	err := verifyPointStep(25, 100, 10)
+	if err == nil {
+		t.Fatal("25 points should not be allowed with step 10")
+	}
+	if !strings.Contains(err.Error(), "use 20 or 30 instead of 25") {
+		t.Errorf("Error should contain the nearest allowed values: %s", err)
+	}
+
+	err = verifyPointStep(92, 95, 10)
+	if err == nil || !strings.Contains(err.Error(), "use 90 or 95 instead of 92") {
+		t.Errorf("Nearest allowed values should not exceed the maximum: %v", err)
+	}
+
+	err = verifyPointStep(25, 100, 0)
+	if err != nil {
+		t.Errorf("All values should be allowed without step: %s", err)
+	}
+}
+
+func TestGetContributions(t *testing.T) {
+	h.Run(t, func() error {
+		contributions, err := s.GetContributions("Clara")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error: %s\n", err.Error()))
+		}
+
+		if len(contributions) != 1 {
+			return errors.New(fmt.Sprintf("Expected one contribution but got %d", len(contributions)))
+		}
+
+		c := contributions[0]
+		if c.TaskId != "2" ||
+			c.ProjectId != "2" ||
+			c.ProcessPoints != 100 ||
+			c.MaxProcessPoints != 100 ||
+			!c.Completed ||
+			!c.FirstActivity.Before(c.LastActivity) {
+			return errors.New(fmt.Sprintf("Contribution does not match: %#v", c))
+		}
+
+		// New actions should appear in the contributions
+		_, err = s.SetProcessPoints("3", 100, "Maria")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error: %s\n", err.Error()))
+		}
+
+		contributions, err = s.GetContributions("Maria")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error: %s\n", err.Error()))
+		}
+
+		if len(contributions) != 1 || contributions[0].ProcessPoints != 100 || !contributions[0].Completed {
+			return errors.New(fmt.Sprintf("Contribution of Maria does not match: %#v", contributions))
+		}
+
+		// Users without history have no contributions
+		contributions, err = s.GetContributions("Worf")
+		if err != nil {
+			return errors.New(fmt.Sprintf("Error: %s\n", err.Error()))
+		}
+
+		if len(contributions) != 0 {
+			return errors.New("Worf should not have contributions")
+		}
+
+		return nil
+	})
+}
+
+func TestSetDifficulty(t *testing.T) {
+	h.Run(t, func() error {
+		task, err := s.SetDifficulty("3", DifficultyHard, "Maria")
+		if err != nil {
+			return errors.Wrap(err, "owner should be able to set difficulty")
+		}
+		if task.Difficulty != DifficultyHard {
+			return errors.New(fmt.Sprintf("Difficulty not set: %s", task.Difficulty))
+		}
+
+		_, err = s.SetDifficulty("3", DifficultyEasy, "John")
+		if err == nil {
+			return errors.New("non-owner should not be able to set difficulty")
+		}
+
+		_, err = s.SetDifficulty("3", "impossible", "Maria")
+		if err == nil {
+			return errors.New("setting unknown difficulty should not work")
+		}
+
+		return nil
+	})
+}
+
+func TestSetAllowedUsers(t *testing.T) {
+	h.Run(t, func() error {
+		task, err := s.SetAllowedUsers("4", []string{"Anna"}, "Maria")
+		if err != nil {
+			return errors.Wrap(err, "owner should be able to set allowed users")
+		}
+		if len(task.AllowedUsers) != 1 || task.AllowedUsers[0] != "Anna" {
+			return errors.New(fmt.Sprintf("Allowed users not set: %v", task.AllowedUsers))
+		}
+
+		_, err = s.SetAllowedUsers("4", []string{}, "John")
+		if err == nil {
+			return errors.New("non-owner should not be able to set allowed users")
+		}
+
+		_, err = s.SetAllowedUsers("4", []string{"Peter"}, "Maria")
+		if err == nil {
+			return errors.New("non-members should not be allowed users")
+		}
+
+		_, err = s.AssignUser("4", "John")
+		if err == nil {
+			return errors.New("John is not allowed to work on task 4")
+		}
+
+		_, err = s.AssignUser("4", "Anna")
+		if err != nil {
+			return errors.Wrap(err, "Anna is allowed to work on task 4")
+		}
+
+		_, err = s.SetProcessPoints("4", 10, "Anna")
+		if err != nil {
+			return errors.Wrap(err, "Anna is allowed to set process points of task 4")
+		}
+
+		// Empty list allows everyone again
+		task, err = s.SetAllowedUsers("4", []string{}, "Maria")
+		if err != nil {
+			return err
+		}
+		if len(task.AllowedUsers) != 0 {
+			return errors.New(fmt.Sprintf("Allowed users should be empty: %v", task.AllowedUsers))
+		}
+
+		return nil
+	})
+}
+
+func TestSetDependencies(t *testing.T) {
+	h.Run(t, func() error {
+		// Task 2 is completed, task 6 is not
+		task, err := s.SetDependencies("4", []string{"2", "6"}, "Maria")
+		if err != nil {
+			return errors.Wrap(err, "owner should be able to set dependencies")
+		}
+		if len(task.DependsOn) != 2 || !task.Blocked {
+			return errors.New(fmt.Sprintf("Task should depend on two tasks and be blocked: %#v", task))
+		}
+
+		_, err = s.SetDependencies("4", []string{}, "John")
+		if err == nil {
+			return errors.New("non-owner should not be able to set dependencies")
+		}
+
+		_, err = s.AssignUser("4", "Anna")
+		if err == nil {
+			return errors.New("blocked task should not be assignable")
+		}
+
+		_, err = s.SetDependencies("6", []string{"4"}, "Maria")
+		if err == nil {
+			return errors.New("circular dependencies should not be possible")
+		}
+
+		task, err = s.SetDependencies("4", []string{"2"}, "Maria")
+		if err != nil {
+			return err
+		}
+		if task.Blocked {
+			return errors.New("task only depending on completed tasks should not be blocked")
+		}
+
+		_, err = s.AssignUser("4", "Anna")
+		if err != nil {
+			return errors.Wrap(err, "task should be assignable after its dependencies are completed")
+		}
+
+		return nil
+	})
+}
+
+func TestVerifyDependencies(t *testing.T) {
+	projectTasks := []*Task{
+		{Id: "1"},
+		{Id: "2", DependsOn: []string{"1"}},
+		{Id: "3", DependsOn: []string{"2"}},
+		{Id: "4"},
+	}
+
+	err := verifyDependencies("4", []string{"1", "3"}, projectTasks)
+	if err != nil {
+		t.Errorf("Dependencies should be valid: %s", err)
+	}
+
+	err = verifyDependencies("1", []string{"3"}, projectTasks)
+	if err == nil {
+		t.Error("Dependency 1 -> 3 -> 2 -> 1 should be a circle")
+	}
+
+	err = verifyDependencies("1", []string{"1"}, projectTasks)
+	if err == nil {
+		t.Error("Task should not depend on itself")
+	}
+
+	err = verifyDependencies("1", []string{"5"}, projectTasks)
+	if err == nil {
+		t.Error("Task of other project should not be a dependency")
+	}
+}
+
+func TestExpandChangesetTemplate(t *testing.T) {
+	comment, hashtags := ExpandChangesetTemplate("Task {taskId} of project {projectId}", []string{"#stm", "#stm-{projectId}"}, "5", "3")
+	if comment != "Task 5 of project 3" {
+		t.Errorf("Placeholders of comment not replaced: %s", comment)
+	}
+	if len(hashtags) != 2 || hashtags[0] != "#stm" || hashtags[1] != "#stm-3" {
+		t.Errorf("Placeholders of hashtags not replaced: %v", hashtags)
+	}
+
+	comment, hashtags = ExpandChangesetTemplate("", nil, "5", "3")
+	if comment != "" || hashtags == nil || len(hashtags) != 0 {
+		t.Errorf("Empty template should stay empty: '%s' %v", comment, hashtags)
+	}
+}
+
+func TestGetAssignedTasks(t *testing.T) {
+	h.Run(t, func() error {
+		tasks, err := s.GetAssignedTasks("Maria")
+		if err != nil {
+			return err
+		}
+
+		if len(tasks) != 1 || tasks[0].Id != "3" || tasks[0].ProjectId != "2" {
+			return errors.New(fmt.Sprintf("Assigned tasks do not match: %v", tasks))
+		}
+		if tasks[0].ProjectName != "Project 2" || tasks[0].AssignedAt == nil || !tasks[0].AssignedAt.Equal(time.Date(2020, 9, 2, 8, 0, 0, 0, time.UTC)) {
+			return errors.New(fmt.Sprintf("Project name or assignment time not matching: %s, %v", tasks[0].ProjectName, tasks[0].AssignedAt))
+		}
+
+		tasks, err = s.GetAssignedTasks("Anna")
+		if err != nil {
+			return err
+		}
+
+		if len(tasks) != 0 {
+			return errors.New(fmt.Sprintf("Anna should not have assigned tasks: %v", tasks))
+		}
+
+		return nil
+	})
+}
+
+func TestReopen(t *testing.T) {
+	h.Run(t, func() error {
+		_, _, err := s.Reopen("2", "Some buildings are missing", "John")
+		if err == nil {
+			return errors.New("Non-owners should not be able to reopen tasks")
+		}
+
+		_, _, err = s.Reopen("2", "  ", "Maria")
+		if err == nil {
+			return errors.New("Reopening without reason should not be possible")
+		}
+
+		_, _, err = s.Reopen("3", "Some buildings are missing", "Maria")
+		if err == nil {
+			return errors.New("Reopening a task, which is not completed, should not be possible")
+		}
+
+		task, previousMapper, err := s.Reopen("2", "Some buildings are missing", "Maria")
+		if err != nil {
+			return err
+		}
+		if task.ProcessPoints != 0 || task.AssignedUser != "" {
+			return errors.New(fmt.Sprintf("Reopened task should have no process points and no assigned user: %#v", task))
+		}
+		if previousMapper != "Clara" {
+			return errors.New(fmt.Sprintf("Previous mapper should be Clara but was '%s'", previousMapper))
+		}
+
+		events, err := s.GetTimeline("2", "Maria")
+		if err != nil {
+			return err
+		}
+		lastEvent := events[len(events)-1]
+		if lastEvent.Type != HistoryReopened || lastEvent.TaskId != "2" || lastEvent.UserId != "Maria" || lastEvent.PointsDelta != -100 || lastEvent.Comment != "Some buildings are missing" {
+			return errors.New(fmt.Sprintf("Last timeline event should be the reopening: %#v", lastEvent))
+		}
+
+		_, _, err = s.Reopen("2", "Still missing", "Maria")
+		if err == nil {
+			return errors.New("Reopening twice should not be possible")
+		}
+
+		return nil
+	})
+}
+
+func TestFlag(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := s.Flag("3", FlagReasonBadImagery, "Clouds everywhere", "John")
+		if err == nil {
+			return errors.New("Tasks assigned to other users should not be flaggable")
+		}
+
+		_, err = s.Flag("3", "boring", "", "Maria")
+		if err == nil {
+			return errors.New("Unknown reasons should not be possible")
+		}
+
+		task, err := s.Flag("3", FlagReasonBadImagery, "Clouds everywhere", "Maria")
+		if err != nil {
+			return err
+		}
+		if task.AssignedUser != "" {
+			return errors.New(fmt.Sprintf("Flagged task should be unassigned but is assigned to %s", task.AssignedUser))
+		}
+		if task.Flag == nil || task.Flag.Reason != FlagReasonBadImagery || task.Flag.Comment != "Clouds everywhere" || task.Flag.UserId != "Maria" {
+			return errors.New(fmt.Sprintf("Flag does not match: %#v", task.Flag))
+		}
+
+		_, err = s.Flag("3", FlagReasonOther, "", "Maria")
+		if err == nil {
+			return errors.New("Flagging twice should not be possible")
+		}
+
+		_, err = s.AssignUser("3", "John")
+		if err == nil {
+			return errors.New("Flagged tasks should not be assignable")
+		}
+
+		_, err = s.ResolveFlag("3", "John")
+		if err == nil {
+			return errors.New("Non-owners should not be able to resolve flags")
+		}
+
+		task, err = s.ResolveFlag("3", "Maria")
+		if err != nil {
+			return err
+		}
+		if task.Flag != nil {
+			return errors.New(fmt.Sprintf("Flag should be resolved: %#v", task.Flag))
+		}
+
+		_, err = s.AssignUser("3", "John")
+		if err != nil {
+			return errors.Wrap(err, "Task should be assignable after resolving the flag")
+		}
+
+		return nil
+	})
+}
+
+func TestNotes(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := s.SetNote("3", "Bad imagery in NE corner", "John")
+		if err == nil {
+			return errors.New("Non-owners should not be able to set notes")
+		}
+
+		_, err = s.SetNote("3", strings.Repeat("a", maxNoteLength+1), "Maria")
+		if err == nil {
+			return errors.New("Too long notes should not be possible")
+		}
+
+		note, err := s.GetNote("3", "Maria")
+		if err != nil {
+			return err
+		}
+		if note != nil {
+			return errors.New(fmt.Sprintf("Task should not have a note: %#v", note))
+		}
+
+		_, err = s.SetNote("3", "Bad imagery", "Maria")
+		if err != nil {
+			return err
+		}
+		note, err = s.SetNote("3", "Bad imagery in NE corner", "Maria")
+		if err != nil {
+			return err
+		}
+		if note.TaskId != "3" || note.Text != "Bad imagery in NE corner" || note.UpdatedBy != "Maria" {
+			return errors.New(fmt.Sprintf("Note does not match: %#v", note))
+		}
+
+		_, err = s.GetNote("3", "John")
+		if err == nil {
+			return errors.New("Non-owners should not be able to see notes")
+		}
+
+		note, err = s.GetNote("3", "Maria")
+		if err != nil {
+			return err
+		}
+		if note == nil || note.Text != "Bad imagery in NE corner" {
+			return errors.New(fmt.Sprintf("Note does not match: %#v", note))
+		}
+
+		// Only the note of task 3 exists
+		notes, err := s.GetNotes("2", "Maria")
+		if err != nil {
+			return err
+		}
+		if len(notes) != 1 || notes[0].TaskId != "3" {
+			return errors.New(fmt.Sprintf("Notes do not match: %v", notes))
+		}
+
+		_, err = s.GetNotes("2", "John")
+		if err == nil {
+			return errors.New("Non-owners should not be able to see notes")
+		}
+
+		note, err = s.SetNote("3", " ", "Maria")
+		if err != nil {
+			return err
+		}
+		if note != nil {
+			return errors.New(fmt.Sprintf("Empty note should have been removed: %#v", note))
+		}
+
+		notes, err = s.GetNotes("2", "Maria")
+		if err != nil {
+			return err
+		}
+		if len(notes) != 0 {
+			return errors.New(fmt.Sprintf("Notes should have been removed: %v", notes))
+		}
+
+		return nil
+	})
+}
+
+func TestGetAreaStats(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := tx.Exec("UPDATE tasks SET area=2, perimeter=6 WHERE project_id=2;")
+		if err != nil {
+			return err
+		}
+
+		_, err = s.GetAreaStats("2", "John")
+		if err == nil {
+			return errors.New("Non-owner should not be able to get area statistics")
+		}
+
+		// Task 2 is already completed, so two tasks with an area of 2 km² each are mapped afterwards
+		_, err = s.SetProcessPoints("3", 100, "Maria")
+		if err != nil {
+			return err
+		}
+
+		stats, err := s.GetAreaStats("2", "Maria")
+		if err != nil {
+			return err
+		}
+		if stats.ProjectId != "2" || stats.TotalArea != 10 || stats.MappedArea != 4 || stats.TotalPerimeter != 30 || stats.MappedPerimeter != 12 {
+			return errors.New(fmt.Sprintf("Area statistics do not match: %#v", stats))
+		}
+
+		var maria *UserAreaStats
+		for _, userStats := range stats.Users {
+			if userStats.UserId == "Maria" {
+				maria = userStats
+			}
+		}
+		if maria == nil || maria.CompletedTasks != 1 || maria.MappedArea != 2 || maria.MappedPerimeter != 6 {
+			return errors.New(fmt.Sprintf("Area statistics of Maria do not match: %#v", stats.Users))
+		}
+
+		contributions, err := s.GetContributions("Maria")
+		if err != nil {
+			return err
+		}
+		if len(contributions) != 1 || contributions[0].Area != 2 || contributions[0].Perimeter != 6 {
+			return errors.New(fmt.Sprintf("Contribution of Maria should contain the area: %#v", contributions))
+		}
+
+		return nil
+	})
+}
+
+func TestReserveForEditor(t *testing.T) {
+	h.Run(t, func() error {
+		_, _, err := s.ReserveForEditor("4", "potlatch", "John")
+		if err == nil {
+			return errors.New("Reserving for unknown editor should not be possible")
+		}
+		_, _, err = s.ReserveForEditor("4", osm.EditorJosm, "Peter")
+		if err == nil {
+			return errors.New("Non-member should not be able to reserve task")
+		}
+		_, _, err = s.ReserveForEditor("2", osm.EditorJosm, "John")
+		if err == nil {
+			return errors.New("Completed task should not be reservable")
+		}
+		_, _, err = s.ReserveForEditor("3", osm.EditorJosm, "John")
+		if err == nil {
+			return errors.New("Task assigned to another user should not be reservable")
+		}
+
+		task, editorUrl, err := s.ReserveForEditor("4", osm.EditorJosm, "John")
+		if err != nil {
+			return err
+		}
+		if task.Reservation == nil || task.Reservation.UserId != "John" || task.Reservation.Editor != osm.EditorJosm || task.Reservation.ExpiresAt.Before(time.Now().Add(reservationDuration-time.Minute)) {
+			return errors.New(fmt.Sprintf("Reservation does not match: %#v", task.Reservation))
+		}
+		if !strings.HasPrefix(editorUrl, "http://127.0.0.1:8111/load_and_zoom?") {
+			return errors.New(fmt.Sprintf("Unexpected editor URL '%s'", editorUrl))
+		}
+		if task.AssignedUser != "" {
+			return errors.New("Reserving should not assign the task")
+		}
+
+		// Others can't reserve the task, but the same user can extend the reservation
+		_, _, err = s.ReserveForEditor("4", osm.EditorId, "Anna")
+		if err == nil {
+			return errors.New("Reserved task should not be reservable by others")
+		}
+		task, _, err = s.ReserveForEditor("4", osm.EditorId, "John")
+		if err != nil {
+			return err
+		}
+		if task.Reservation == nil || task.Reservation.Editor != osm.EditorId {
+			return errors.New(fmt.Sprintf("Reservation should be extended: %#v", task.Reservation))
+		}
+
+		// Releasing other reservations has no effect
+		task, err = s.ReleaseReservation("4", "Anna")
+		if err != nil {
+			return err
+		}
+		if task.Reservation == nil {
+			return errors.New("Reservation of John should not be released by Anna")
+		}
+		task, err = s.ReleaseReservation("4", "John")
+		if err != nil {
+			return err
+		}
+		if task.Reservation != nil {
+			return errors.New(fmt.Sprintf("Reservation should be released: %#v", task.Reservation))
+		}
+
+		// Expired reservations are neither shown nor blocking
+		_, _, err = s.ReserveForEditor("4", osm.EditorJosm, "John")
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE task_reservations SET expires_at=NOW()-INTERVAL '1 minute' WHERE task_id=4;")
+		if err != nil {
+			return err
+		}
+		task, err = s.GetTask("4", "Anna")
+		if err != nil {
+			return err
+		}
+		if task.Reservation != nil {
+			return errors.New(fmt.Sprintf("Expired reservation should not be shown: %#v", task.Reservation))
+		}
+
+		err = s.RemoveExpiredReservations()
+		if err != nil {
+			return err
+		}
+		var count int
+		err = tx.QueryRow("SELECT COUNT(*) FROM task_reservations;").Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count != 0 {
+			return errors.New(fmt.Sprintf("Expired reservations should be removed but %d are left", count))
+		}
+
+		_, _, err = s.ReserveForEditor("4", osm.EditorJosm, "Anna")
+		return err
+	})
+}
+
+func TestChangesets(t *testing.T) {
+	h.Run(t, func() error {
+		err := s.LinkChangeset("3", "12a", "Maria")
+		if err == nil {
+			return errors.New("Invalid changeset IDs should not be possible")
+		}
+
+		err = s.LinkChangeset("3", "123", "Peter")
+		if err == nil {
+			return errors.New("Non-members should not be able to link changesets")
+		}
+
+		// Changeset 123 is linked to two tasks and only counts once
+		for _, taskId := range []string{"3", "4"} {
+			err = s.LinkChangeset(taskId, "123", "John")
+			if err != nil {
+				return err
+			}
+		}
+		err = s.LinkChangeset("3", "123", "John")
+		if err != nil {
+			return errors.New("Linking a changeset twice should work")
+		}
+		err = s.LinkChangeset("6", "124", "Anna")
+		if err != nil {
+			return err
+		}
+
+		_, err = s.GetChangesetStats("2", "John")
+		if err == nil {
+			return errors.New("Non-owners should not be able to see changeset statistics")
+		}
+
+		stats, err := s.GetChangesetStats("2", "Maria")
+		if err != nil {
+			return err
+		}
+		if stats.Changesets != 2 || stats.ChangedObjects != 0 || stats.Contributors != 0 || stats.PendingChangesets != 2 {
+			return errors.New(fmt.Sprintf("Statistics do not match: %#v", stats))
+		}
+
+		changesetIds, err := s.store.GetChangesetsToUpdate(1)
+		if err != nil {
+			return err
+		}
+		if len(changesetIds) != 1 || changesetIds[0] != "123" {
+			return errors.New(fmt.Sprintf("Changesets to update do not match: %v", changesetIds))
+		}
+
+		err = s.store.UpdateChangeset(&osm.Changeset{Id: "123", UserId: "42", ChangesCount: 17, Open: false})
+		if err != nil {
+			return err
+		}
+
+		stats, err = s.GetChangesetStats("2", "Maria")
+		if err != nil {
+			return err
+		}
+		if stats.Changesets != 2 || stats.ChangedObjects != 17 || stats.Contributors != 1 || stats.PendingChangesets != 1 {
+			return errors.New(fmt.Sprintf("Statistics do not match: %#v", stats))
+		}
+
+		// Closed changesets are not updated anymore
+		changesetIds, err = s.store.GetChangesetsToUpdate(10)
+		if err != nil {
+			return err
+		}
+		if len(changesetIds) != 1 || changesetIds[0] != "124" {
+			return errors.New(fmt.Sprintf("Changesets to update do not match: %v", changesetIds))
+		}
+
+		return nil
+	})
+}
+
+func TestOsmNotes(t *testing.T) {
+	h.Run(t, func() error {
+		_, _, err := s.LinkOsmNote("3", "12a", "John")
+		if err == nil {
+			return errors.New("Invalid note IDs should not be possible")
+		}
+
+		_, _, err = s.LinkOsmNote("3", "123", "Peter")
+		if err == nil {
+			return errors.New("Non-members should not be able to link notes")
+		}
+
+		_, _, err = s.CreateOsmNote("3", " ", "John")
+		if err == nil {
+			return errors.New("Empty notes should not be possible")
+		}
+
+		_, _, err = s.CreateOsmNote("3", strings.Repeat("a", maxOsmNoteTextLength+1), "John")
+		if err == nil {
+			return errors.New("Too long notes should not be possible")
+		}
+
+		_, err = s.GetOsmNotes("3", "Peter")
+		if err == nil {
+			return errors.New("Non-members should not be able to see notes")
+		}
+
+		// Note 123 is linked to two tasks, linking it twice to a task keeps the first link
+		for _, taskId := range []string{"3", "4", "3"} {
+			_, err = s.store.LinkOsmNote(taskId, "123", true, "John")
+			if err != nil {
+				return err
+			}
+		}
+		note, err := s.store.LinkOsmNote("3", "124", false, "Anna")
+		if err != nil {
+			return err
+		}
+		if note.NoteId != "124" || note.Open || note.LinkedBy != "Anna" {
+			return errors.New(fmt.Sprintf("Note does not match: %#v", note))
+		}
+
+		notes, err := s.GetOsmNotes("3", "Maria")
+		if err != nil {
+			return err
+		}
+		if len(notes) != 2 || notes[0].NoteId != "123" || !notes[0].Open || notes[0].LinkedBy != "John" || notes[1].NoteId != "124" {
+			return errors.New(fmt.Sprintf("Notes do not match: %v", notes))
+		}
+
+		task, err := s.store.GetTask("3")
+		if err != nil {
+			return err
+		}
+		if task.OpenOsmNotes != 1 {
+			return errors.New(fmt.Sprintf("Task should have one open note but has %d", task.OpenOsmNotes))
+		}
+
+		noteIds, err := s.store.GetOsmNotesToUpdate(10)
+		if err != nil {
+			return err
+		}
+		if len(noteIds) != 1 || noteIds[0] != "123" {
+			return errors.New(fmt.Sprintf("Notes to update do not match: %v", noteIds))
+		}
+
+		err = s.store.UpdateOsmNote("123", false)
+		if err != nil {
+			return err
+		}
+
+		for _, taskId := range []string{"3", "4"} {
+			task, err = s.store.GetTask(taskId)
+			if err != nil {
+				return err
+			}
+			if task.OpenOsmNotes != 0 {
+				return errors.New(fmt.Sprintf("Task %s should have no open notes but has %d", taskId, task.OpenOsmNotes))
+			}
+		}
+
+		// Closed notes are not updated anymore
+		noteIds, err = s.store.GetOsmNotesToUpdate(10)
+		if err != nil {
+			return err
+		}
+		if len(noteIds) != 0 {
+			return errors.New(fmt.Sprintf("Notes to update do not match: %v", noteIds))
+		}
+
+		return nil
+	})
+}
+
+func TestLocality(t *testing.T) {
+	h.Run(t, func() error {
+		// Tasks of the dummy data have no centroid, so they're not looked up
+		tasks, err := s.store.GetTasksWithoutLocality(10)
+		if err != nil {
+			return err
+		}
+		if len(tasks) != 0 {
+			return errors.New(fmt.Sprintf("Tasks without centroid should be ignored but got %d tasks", len(tasks)))
+		}
+
+		rawTask := &Task{
+			MaxProcessPoints: 10,
+			Geometry:         "{\"type\":\"Feature\",\"geometry\":{\"type\":\"Polygon\",\"coordinates\":[[[0,0],[1,0]]]},\"properties\":null}",
+		}
+		addedTasks, err := s.AddTasks([]*Task{rawTask}, "1")
+		if err != nil {
+			return err
+		}
+		addedTask := addedTasks[1]
+		if addedTask.Locality != "" {
+			return errors.New(fmt.Sprintf("New task should have no locality but has '%s'", addedTask.Locality))
+		}
+
+		tasks, err = s.startLocalityLookup()
+		if err != nil {
+			return err
+		}
+		if len(tasks) != 1 || tasks[0].Id != addedTask.Id {
+			return errors.New(fmt.Sprintf("The new task should be looked up but got %v", tasks))
+		}
+
+		// The attempted task (e.g. because the lookup failed) comes after newer tasks, which haven't been tried yet
+		addedTasks, err = s.AddTasks([]*Task{{
+			MaxProcessPoints: 10,
+			Geometry:         "{\"type\":\"Feature\",\"geometry\":{\"type\":\"Polygon\",\"coordinates\":[[[2,2],[3,2]]]},\"properties\":null}",
+		}}, "1")
+		if err != nil {
+			return err
+		}
+		var newerTask *Task
+		for _, t := range addedTasks {
+			if t.Id != addedTask.Id && len(t.Centroid) == 2 {
+				newerTask = t
+			}
+		}
+
+		tasks, err = s.store.GetTasksWithoutLocality(10)
+		if err != nil {
+			return err
+		}
+		if len(tasks) != 2 || tasks[0].Id != newerTask.Id || tasks[1].Id != addedTask.Id {
+			return errors.New(fmt.Sprintf("The newer task should be looked up first but got %v", tasks))
+		}
+
+		err = s.setLocalities(map[string]string{addedTask.Id: "Kibera, Nairobi", newerTask.Id: ""})
+		if err != nil {
+			return err
+		}
+
+		task, err := s.store.GetTask(addedTask.Id)
+		if err != nil {
+			return err
+		}
+		if task.Locality != "Kibera, Nairobi" {
+			return errors.New(fmt.Sprintf("Locality '%s' does not match", task.Locality))
+		}
+		if task.Version != addedTask.Version+1 || task.UpdatedAt.Before(addedTask.UpdatedAt) {
+			return errors.New(fmt.Sprintf("Setting the locality should change the version and update time but got %d and %s", task.Version, task.UpdatedAt))
+		}
+
+		tasks, err = s.store.GetTasksWithoutLocality(10)
+		if err != nil {
+			return err
+		}
+		if len(tasks) != 0 {
+			return errors.New(fmt.Sprintf("Tasks with locality should not be looked up again but got %d tasks", len(tasks)))
+		}
+
+		return nil
+	})
+}
+
+func TestHandover(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := s.RequestHandover("3", "Maria", "John")
+		if err == nil {
+			return errors.New("Only the assigned user should be able to offer a task")
+		}
+
+		_, err = s.RequestHandover("3", "Peter", "Maria")
+		if err == nil {
+			return errors.New("Tasks should not be offered to non-members")
+		}
+
+		_, err = s.RequestHandover("3", "Maria", "Maria")
+		if err == nil {
+			return errors.New("Tasks should not be offered to the assigned user")
+		}
+
+		handover, err := s.RequestHandover("3", "John", "Maria")
+		if err != nil {
+			return err
+		}
+		if handover.TaskId != "3" || handover.ProjectId != "2" || handover.FromUser != "Maria" || handover.ToUser != "John" {
+			return errors.New(fmt.Sprintf("Handover does not match: %#v", handover))
+		}
+
+		handovers, err := s.GetHandovers("John")
+		if err != nil {
+			return err
+		}
+		if len(handovers) != 1 || handovers[0].TaskId != "3" {
+			return errors.New(fmt.Sprintf("Handovers do not match: %v", handovers))
+		}
+
+		_, _, err = s.AcceptHandover("3", "Anna")
+		if err == nil {
+			return errors.New("Only the user the task has been offered to should be able to accept it")
+		}
+
+		task, handover, err := s.AcceptHandover("3", "John")
+		if err != nil {
+			return err
+		}
+		if task.AssignedUser != "John" || task.ProcessPoints != 50 || handover.FromUser != "Maria" {
+			return errors.New(fmt.Sprintf("Task should be handed over with its progress: %#v", task))
+		}
+
+		handovers, err = s.GetHandovers("John")
+		if err != nil {
+			return err
+		}
+		if len(handovers) != 0 {
+			return errors.New(fmt.Sprintf("Accepted handover should have been removed: %v", handovers))
+		}
+
+		events, err := s.store.GetTimeline("2")
+		if err != nil {
+			return err
+		}
+		if len(events) < 2 {
+			return errors.New(fmt.Sprintf("Handover should be in the history: %v", events))
+		}
+		handedOver, assigned := events[len(events)-2], events[len(events)-1]
+		if handedOver.Type != HistoryHandedOver || handedOver.UserId != "Maria" || assigned.Type != HistoryAssigned || assigned.UserId != "John" {
+			return errors.New(fmt.Sprintf("History does not match: %#v, %#v", handedOver, assigned))
+		}
+
+		// Declining removes the handover and keeps the assignment
+		_, err = s.RequestHandover("3", "Anna", "John")
+		if err != nil {
+			return err
+		}
+		_, err = s.RemoveHandover("3", "Carl")
+		if err == nil {
+			return errors.New("Other users should not be able to remove the handover")
+		}
+		_, err = s.RemoveHandover("3", "Anna")
+		if err != nil {
+			return err
+		}
+		_, _, err = s.AcceptHandover("3", "Anna")
+		if err == nil {
+			return errors.New("Declined handover should not be accepted")
+		}
+
+		task, err = s.GetTask("3", "John")
+		if err != nil {
+			return err
+		}
+		if task.AssignedUser != "John" {
+			return errors.New(fmt.Sprintf("Task should still be assigned to John: %#v", task))
+		}
+
+		return nil
+	})
+}
+
+func TestUnassignInactiveTasks(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := tx.Exec("UPDATE projects SET unassign_after_hours=1 WHERE id=2;")
+		if err != nil {
+			return err
+		}
+
+		_, err = s.AssignUser("4", "John")
+		if err != nil {
+			return err
+		}
+
+		// The assignment is too new
+		err = s.UnassignInactiveTasks()
+		if err != nil {
+			return err
+		}
+		task, err := s.GetTask("4", "John")
+		if err != nil {
+			return err
+		}
+		if task.AssignedUser != "John" {
+			return errors.New(fmt.Sprintf("Task 4 should still be assigned to John: %#v", task))
+		}
+
+		_, err = tx.Exec("UPDATE task_history SET created_at=NOW()-INTERVAL '2 hours' WHERE task_id=4;")
+		if err != nil {
+			return err
+		}
+
+		err = s.UnassignInactiveTasks()
+		if err != nil {
+			return err
+		}
+
+		task, err = s.GetTask("4", "John")
+		if err != nil {
+			return err
+		}
+		if task.AssignedUser != "" {
+			return errors.New(fmt.Sprintf("Task 4 should have been unassigned: %#v", task))
+		}
+
+		events, err := s.store.GetTimeline("2")
+		if err != nil {
+			return err
+		}
+		lastEvent := events[len(events)-1]
+		if lastEvent.TaskId != "4" || lastEvent.Type != HistoryAutoUnassigned || lastEvent.UserId != "John" {
+			return errors.New(fmt.Sprintf("Unassignment should be in the history: %#v", lastEvent))
+		}
+
+		// Tasks 3 and 7 are assigned for a long time but have process point changes after their assignment
+		for _, taskId := range []string{"3", "7"} {
+			task, err = s.GetTask(taskId, "Maria")
+			if err != nil {
+				return err
+			}
+			if task.AssignedUser == "" {
+				return errors.New(fmt.Sprintf("Task %s with progress should still be assigned", taskId))
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestWarnExpiringAssignments(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := tx.Exec("UPDATE projects SET unassign_after_hours=4 WHERE id=2;")
+		if err != nil {
+			return err
+		}
+
+		_, err = s.AssignUser("4", "John")
+		if err != nil {
+			return err
+		}
+
+		notificationService := notification.Init(context.Background(), tx, s.Logger)
+		countWarnings := func() (int, error) {
+			var count int
+			err := tx.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id='John' AND type=$1 AND task_id=4;", notification.TypeAssignmentExpiring).Scan(&count)
+			return count, err
+		}
+
+		// Less than three quarters of the time are over
+		_, err = tx.Exec("UPDATE task_history SET created_at=NOW()-INTERVAL '2 hours' WHERE task_id=4;")
+		if err != nil {
+			return err
+		}
+		err = s.WarnExpiringAssignments(notificationService)
+		if err != nil {
+			return err
+		}
+		count, err := countWarnings()
+		if err != nil {
+			return err
+		}
+		if count != 0 {
+			return errors.New(fmt.Sprintf("Assignment should not be expiring yet but got %d warnings", count))
+		}
+
+		// The warning is only added once
+		_, err = tx.Exec("UPDATE task_history SET created_at=NOW()-INTERVAL '3.5 hours' WHERE task_id=4;")
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 2; i++ {
+			err = s.WarnExpiringAssignments(notificationService)
+			if err != nil {
+				return err
+			}
+		}
+		count, err = countWarnings()
+		if err != nil {
+			return err
+		}
+		if count != 1 {
+			return errors.New(fmt.Sprintf("Expected one warning but got %d", count))
+		}
+
+		assignments, err := s.store.GetExpiringAssignments()
+		if err != nil {
+			return err
+		}
+		if len(assignments) != 1 || assignments[0].TaskId != "4" || assignments[0].ProjectId != "2" || assignments[0].UserId != "John" || assignments[0].UnassignAt.Sub(assignments[0].AssignedAt) != 4*time.Hour {
+			return errors.New(fmt.Sprintf("Expiring assignments do not match: %v", assignments))
+		}
+
+		// Expired assignments are unassigned instead
+		_, err = tx.Exec("UPDATE task_history SET created_at=NOW()-INTERVAL '5 hours' WHERE task_id=4;")
+		if err != nil {
+			return err
+		}
+		assignments, err = s.store.GetExpiringAssignments()
+		if err != nil {
+			return err
+		}
+		if len(assignments) != 0 {
+			return errors.New(fmt.Sprintf("Expired assignments should not be returned: %v", assignments))
+		}
+
+		return nil
+	})
+}
+
+func TestFilterByDifficulty(t *testing.T) {
+	tasks := []*Task{
+		{Id: "1", Difficulty: DifficultyEasy},
+		{Id: "2", Difficulty: DifficultyHard},
+		{Id: "3", Difficulty: DifficultyMedium},
+	}
+
+	filtered := FilterByDifficulty(tasks, []string{DifficultyEasy, DifficultyMedium})
+	if len(filtered) != 2 || filtered[0].Id != "1" || filtered[1].Id != "3" {
+		t.Errorf("Filtered tasks not matching: %v", filtered)
+	}
+
+	filtered = FilterByDifficulty(tasks, []string{})
+	if len(filtered) != 0 {
+		t.Errorf("No task should match empty filter: %v", filtered)
+	}
+}
+
+func TestFilterChangedSince(t *testing.T) {
+	since := time.Date(2021, 3, 14, 15, 0, 0, 0, time.UTC)
+	tasks := []*Task{
+		{Id: "1", UpdatedAt: since.Add(-time.Minute)},
+		{Id: "2", UpdatedAt: since.Add(time.Minute)},
+		{Id: "3", UpdatedAt: since},
+	}
+
+	filtered := FilterChangedSince(tasks, since)
+	if len(filtered) != 1 || filtered[0].Id != "2" {
+		t.Errorf("Filtered tasks not matching: %v", filtered)
+	}
+}
+
+func TestTaskTimestamps(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := tx.Exec("UPDATE tasks SET updated_at='2020-01-01 10:00:00' WHERE project_id=2;")
+		if err != nil {
+			return err
+		}
+
+		since := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+		task, err := s.AssignUser("2", "Clara")
+		if err != nil {
+			return err
+		}
+		if !task.UpdatedAt.After(since) || task.CreatedAt.IsZero() {
+			return errors.New(fmt.Sprintf("Timestamps not set correctly: %s, %s", task.CreatedAt, task.UpdatedAt))
+		}
+
+		tasks, err := s.GetTasks("2", "Clara")
+		if err != nil {
+			return err
+		}
+		changedTasks := FilterChangedSince(tasks, since)
+		if len(changedTasks) != 1 || changedTasks[0].Id != "2" {
+			return errors.New(fmt.Sprintf("Only task 2 should have been changed: %#v", changedTasks))
+		}
+
+		return nil
+	})
+}
+
+func TestGetChangedTasks(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := tx.Exec("UPDATE tasks SET updated_at='2020-01-01 10:00:00';")
+		if err != nil {
+			return err
+		}
+
+		since := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+		// Without since, all tasks of all projects of the user are returned
+		tasks, err := s.GetChangedTasks("Maria", time.Time{})
+		if err != nil {
+			return err
+		}
+		if len(tasks) != 6 {
+			return errors.New(fmt.Sprintf("Expected all 6 tasks of Marias projects but got %d", len(tasks)))
+		}
+
+		_, err = s.AssignUser("2", "Clara")
+		if err != nil {
+			return err
+		}
+
+		tasks, err = s.GetChangedTasks("Clara", since)
+		if err != nil {
+			return err
+		}
+		if len(tasks) != 1 || tasks[0].Id != "2" || tasks[0].ProjectId != "2" {
+			return errors.New(fmt.Sprintf("Only task 2 should have been changed: %#v", tasks))
+		}
+
+		// Peter is no member of project 2
+		tasks, err = s.GetChangedTasks("Peter", since)
+		if err != nil {
+			return err
+		}
+		if len(tasks) != 0 {
+			return errors.New(fmt.Sprintf("Peter should not get tasks of other projects: %#v", tasks))
+		}
+
+		return nil
+	})
+}
+
+func TestExportGpx(t *testing.T) {
+	tasks := []*Task{
+		{Id: "1", Geometry: `{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]},"properties":{"name":"foo"}}`},
+	}
+
+	data, err := ExportGpx(tasks)
+	if err != nil {
+		t.Errorf("Exporting GPX should work: %s", err)
+		return
+	}
+
+	content := string(data)
+	if !strings.Contains(content, "<name>foo</name>") || strings.Count(content, "<trkpt") != 4 {
+		t.Errorf("GPX not matching: %s", content)
+	}
+}
+
+func TestExportOsm(t *testing.T) {
+	tasks := []*Task{
+		{Id: "1", Geometry: `{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]},"properties":{}}`},
+		{Id: "2", Geometry: `{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[4,0],[4,4],[0,0]],[[1,1],[2,1],[2,2],[1,1]]]},"properties":{}}`},
+	}
+
+	data, err := ExportOsm(tasks)
+	if err != nil {
+		t.Errorf("Exporting OSM XML should work: %s", err)
+		return
+	}
+
+	content := string(data)
+	if strings.Count(content, "<node ") != 9 || strings.Count(content, "<way ") != 3 || strings.Count(content, "<relation ") != 1 {
+		t.Errorf("OSM XML not matching: %s", content)
+	}
+	if !strings.Contains(content, `v="Task 1"`) || !strings.Contains(content, `upload="false"`) {
+		t.Errorf("OSM XML not matching: %s", content)
+	}
+
+	data, err = ExportOsm([]*Task{
+		{Id: "3", Geometry: `{"type":"Feature","geometry":{"type":"Point","coordinates":[0,0]},"properties":{}}`},
+		{Id: "4", Geometry: `{"type":"Feature","geometry":{"type":"LineString","coordinates":[[0,0],[1,0],[1,1]]},"properties":{}}`},
+	})
+	if err != nil {
+		t.Errorf("Exporting points and lines should work: %s", err)
+		return
+	}
+
+	content = string(data)
+	if strings.Count(content, "<node ") != 4 || strings.Count(content, "<way ") != 1 || strings.Contains(content, `k="area"`) {
+		t.Errorf("OSM XML not matching: %s", content)
+	}
+
+	_, err = ExportOsm([]*Task{{Id: "5", Geometry: `{"type":"Feature","geometry":{"type":"MultiPoint","coordinates":[[0,0]]},"properties":{}}`}})
+	if err == nil {
+		t.Errorf("Exporting unsupported geometries should not work")
+	}
+}
+
+func TestExportGpxPointsAndLines(t *testing.T) {
+	tasks := []*Task{
+		{Id: "1", Geometry: `{"type":"Feature","geometry":{"type":"Point","coordinates":[9.5,53.5]},"properties":{"name":"foo"}}`},
+		{Id: "2", Geometry: `{"type":"Feature","geometry":{"type":"LineString","coordinates":[[0,0],[1,0],[1,1]]},"properties":{}}`},
+	}
+
+	data, err := ExportGpx(tasks)
+	if err != nil {
+		t.Errorf("Exporting GPX should work: %s", err)
+		return
+	}
+
+	content := string(data)
+	if strings.Count(content, "<wpt ") != 1 || !strings.Contains(content, `lat="53.5"`) || strings.Count(content, "<trk>") != 1 || strings.Count(content, "<trkpt") != 3 {
+		t.Errorf("GPX not matching: %s", content)
+	}
+}
+
+func TestExportGeojsonAndCsv(t *testing.T) {
+	tasks := []*Task{
+		{Id: "1", ProcessPoints: 5, MaxProcessPoints: 10, AssignedUser: "Peter", Geometry: `{"type":"Feature","geometry":{"type":"Point","coordinates":[9.5,53.5]},"properties":{"name":"foo"}}`},
+		{Id: "2", MaxProcessPoints: 10, Geometry: `{"type":"Feature","geometry":{"type":"LineString","coordinates":[[0,0],[1,0]]},"properties":null}`},
+	}
+
+	data, err := ExportGeojson(tasks)
+	if err != nil {
+		t.Errorf("Exporting GeoJSON should work: %s", err)
+		return
+	}
+
+	content := string(data)
+	if strings.Count(content, `"type":"Feature"`) != 2 || !strings.Contains(content, `"name":"foo"`) || !strings.Contains(content, `"stm:assigned_user":"Peter"`) {
+		t.Errorf("GeoJSON not matching: %s", content)
+	}
+
+	data, err = ExportCsv(tasks)
+	if err != nil {
+		t.Errorf("Exporting CSV should work: %s", err)
+		return
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "1,5,10,Peter,") {
+		t.Errorf("CSV not matching: %s", string(data))
+	}
+
+	if !IsValidExportFormat(ExportFormatHistory) || IsValidExportFormat("shp") || ExportFileName("1", ExportFormatHistory) != "project-1-history.csv" {
+		t.Errorf("Export formats not matching")
+	}
+}
+
+func TestParseTaskShape(t *testing.T) {
+	shape, err := parseTaskShape(`{"type":"Feature","geometry":{"type":"LineString","coordinates":[[0,0],[2,0]]},"properties":{}}`)
+	if err != nil {
+		t.Errorf("Parsing line string should work: %s", err)
+		return
+	}
+	if shape.geometryType != GeometryTypeLineString {
+		t.Errorf("Expected type %s but got %s", GeometryTypeLineString, shape.geometryType)
+	}
+
+	shape, err = parseTaskShape(`{"type":"Feature","geometry":{"type":"Point","coordinates":[1,2]},"properties":{}}`)
+	if err != nil {
+		t.Errorf("Parsing point should work: %s", err)
+		return
+	}
+	if shape.geometryType != GeometryTypePoint {
+		t.Errorf("Expected type %s but got %s", GeometryTypePoint, shape.geometryType)
+	}
+
+	_, err = parseTaskShape(`{"type":"Feature","geometry":{"type":"MultiPolygon","coordinates":[]},"properties":{}}`)
+	if err == nil {
+		t.Errorf("Parsing multi polygon should not work")
+	}
+}
+
+func TestConvertGeometry(t *testing.T) {
+	geometry, err := ConvertGeometry("POLYGON ((0 0, 2 0, 2 2, 0 0), (0.5 0.5, 1 0.5, 1 1, 0.5 0.5))", GeometryFormatWkt)
+	if err != nil {
+		t.Errorf("Converting WKT polygon should work: %s", err)
+		return
+	}
+	expected := `{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[2,0],[2,2],[0,0]],[[0.5,0.5],[1,0.5],[1,1],[0.5,0.5]]]},"properties":null}`
+	if geometry != expected {
+		t.Errorf("Expected %s but got %s", expected, geometry)
+	}
+
+	geometry, err = ConvertGeometry("point (9.9 53.5)", GeometryFormatWkt)
+	if err != nil {
+		t.Errorf("Converting WKT point should work: %s", err)
+		return
+	}
+	expected = `{"type":"Feature","geometry":{"type":"Point","coordinates":[9.9,53.5]},"properties":null}`
+	if geometry != expected {
+		t.Errorf("Expected %s but got %s", expected, geometry)
+	}
+
+	// Example of the polyline documentation
+	geometry, err = ConvertGeometry("_p~iF~ps|U_ulLnnqC_mqNvxq`@", GeometryFormatPolyline)
+	if err != nil {
+		t.Errorf("Converting polyline should work: %s", err)
+		return
+	}
+	expected = `{"type":"Feature","geometry":{"type":"LineString","coordinates":[[-120.2,38.5],[-120.95,40.7],[-126.453,43.252]]},"properties":null}`
+	if geometry != expected {
+		t.Errorf("Expected %s but got %s", expected, geometry)
+	}
+
+	geojson := `{"type":"Feature","geometry":{"type":"Point","coordinates":[1,2]},"properties":{}}`
+	geometry, err = ConvertGeometry(geojson, "")
+	if err != nil || geometry != geojson {
+		t.Errorf("GeoJSON should not be changed: %s", geometry)
+	}
+
+	_, err = ConvertGeometry("MULTIPOINT ((1 2), (3 4))", GeometryFormatWkt)
+	if err == nil {
+		t.Errorf("Converting WKT multi point should not work")
+	}
+
+	_, err = ConvertGeometry("_p~iF~ps|U_", GeometryFormatPolyline)
+	if err == nil {
+		t.Errorf("Converting incomplete polyline should not work")
+	}
+
+	_, err = ConvertGeometry("1,2", "csv")
+	if err == nil {
+		t.Errorf("Converting unknown format should not work")
+	}
+}
+
+func TestReprojectGeometry(t *testing.T) {
+	// Hamburg (10.0, 53.55) in web mercator and UTM zone 32N, Rio de Janeiro (-43.2, -22.9) in UTM zone 23S
+	cases := []struct {
+		geometry string
+		crs      string
+		lon      float64
+		lat      float64
+	}{
+		{`{"type":"Feature","geometry":{"type":"Point","coordinates":[1113194.9079327357,7085388.165495079]},"properties":{}}`, "EPSG:3857", 10.0, 53.55},
+		{`{"type":"Feature","geometry":{"type":"Point","coordinates":[566253.4558089846,5933921.4215515945,12]},"properties":{}}`, "urn:ogc:def:crs:EPSG::32632", 10.0, 53.55},
+		{`{"type":"Feature","geometry":{"type":"Point","coordinates":[684623.6732649725,7466421.4006655365]},"properties":{}}`, "32723", -43.2, -22.9},
+		{`{"type":"Feature","crs":{"type":"name","properties":{"name":"EPSG:900913"}},"geometry":{"type":"Point","coordinates":[1113194.9079327357,7085388.165495079]},"properties":{}}`, "", 10.0, 53.55},
+	}
+
+	for _, c := range cases {
+		geometry, err := ReprojectGeometry(c.geometry, c.crs)
+		if err != nil {
+			t.Errorf("Reprojecting %s from '%s' should work: %s", c.geometry, c.crs, err)
+			continue
+		}
+		if strings.Contains(geometry, `"crs"`) {
+			t.Errorf("CRS member should have been removed: %s", geometry)
+		}
+
+		feature, err := geojson.UnmarshalFeature([]byte(geometry))
+		if err != nil {
+			t.Errorf("Reprojected geometry should be valid: %s", err)
+			continue
+		}
+		point := feature.Geometry.Point
+		if math.Abs(point[0]-c.lon) > 0.000001 || math.Abs(point[1]-c.lat) > 0.000001 {
+			t.Errorf("Expected %f, %f from '%s' but got %v", c.lon, c.lat, c.crs, point)
+		}
+	}
+
+	// Additional values like the height are kept
+	geometry, _ := ReprojectGeometry(cases[1].geometry, cases[1].crs)
+	if !strings.Contains(geometry, ",12]") {
+		t.Errorf("Height should have been kept: %s", geometry)
+	}
+
+	polygon := `{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[1113194.9079327357,0],[0,7085388.165495079],[0,0]]]},"properties":{}}`
+	geometry, err := ReprojectGeometry(polygon, CrsWebMercator)
+	if err != nil || !strings.Contains(geometry, `[[[0,0],[10,0],[0,53.55`) {
+		t.Errorf("Reprojecting polygon should work: %s, %v", geometry, err)
+	}
+
+	wgs84 := `{"type":"Feature","geometry":{"type":"Point","coordinates":[10,53.55]},"properties":{}}`
+	geometry, err = ReprojectGeometry(wgs84, "")
+	if err != nil || geometry != wgs84 {
+		t.Errorf("Geometry without CRS should not be changed: %s", geometry)
+	}
+
+	geometry, err = ReprojectGeometry(wgs84, "urn:ogc:def:crs:OGC:1.3:CRS84")
+	if err != nil || !strings.Contains(geometry, "[10,53.55]") {
+		t.Errorf("Geometry in CRS84 should not be changed: %s", geometry)
+	}
+
+	_, err = ReprojectGeometry(wgs84, "EPSG:31467")
+	if err == nil {
+		t.Errorf("Reprojecting from unsupported CRS should not work")
+	}
+
+	_, err = ReprojectGeometry(`{"type":"Feature","geometry":{"type":"Point","coordinates":[1113194,99999999]},"properties":{}}`, "EPSG:32632")
+	if err == nil {
+		t.Errorf("Reprojecting coordinates outside of the CRS should not work")
+	}
+}
+
+func TestExportDoneTasks(t *testing.T) {
+	h.Run(t, func() error {
+		// Only task 2 of project 2 is done
+		data, err := s.ExportDoneTasks("2", ExportFormatGpx, "Maria")
+		if err != nil {
+			return errors.Wrap(err, "exporting done tasks should work")
+		}
+
+		if strings.Count(string(data), "<trk>") != 1 || !strings.Contains(string(data), "<name>Task 2</name>") {
+			return errors.New(fmt.Sprintf("Exported GPX not matching: %s", string(data)))
+		}
+
+		_, err = s.ExportDoneTasks("2", "shp", "Maria")
+		if err == nil {
+			return errors.New("Exporting with unknown format should not work")
+		}
+
+		_, err = s.ExportDoneTasks("2", ExportFormatOsm, "Peter")
+		if err == nil {
+			return errors.New("Exporting by non-member should not work")
+		}
+
+		return nil
+	})
+}
+
+func TestRenderPreview(t *testing.T) {
+	tasks := []*Task{
+		{Id: "1", ProcessPoints: 0, MaxProcessPoints: 10, Geometry: `{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[2,0],[2,1],[0,0]]]},"properties":null}`},
+		{Id: "2", ProcessPoints: 10, MaxProcessPoints: 10, Geometry: `{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[2,1],[0,1],[0,0]]]},"properties":null}`},
+	}
+
+	data, err := RenderPreview(tasks, "", 100)
+	if err != nil {
+		t.Errorf("Rendering preview should work: %s", err)
+		return
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Errorf("Preview should be a valid PNG: %s", err)
+		return
+	}
+
+	// The longer edge has the requested size
+	if img.Bounds().Dx() != 100 {
+		t.Errorf("Expected width of 100 but got %d", img.Bounds().Dx())
+	}
+
+	// Lower right corner belongs to the new task, upper left corner to the done task
+	r, g, _, _ := img.At(80, img.Bounds().Dy()-15).RGBA()
+	if r>>8 != 255 || g>>8 != 0 {
+		t.Errorf("New task should be red but was %d/%d", r>>8, g>>8)
+	}
+	r, g, _, _ = img.At(15, 15).RGBA()
+	if r>>8 != 0 || g>>8 != 255 {
+		t.Errorf("Done task should be green but was %d/%d", r>>8, g>>8)
+	}
+
+	_, err = RenderPreview(tasks, "", 10000)
+	if err == nil {
+		t.Errorf("Rendering huge preview should not work")
+	}
+
+	// The AOI is larger than the tasks, so its outline is at the edge of the drawable area
+	data, err = RenderPreview(tasks, `{"type":"Polygon","coordinates":[[[-1,-1],[3,-1],[3,2],[-1,2],[-1,-1]]]}`, 100)
+	if err != nil {
+		t.Errorf("Rendering preview with AOI should work: %s", err)
+		return
+	}
+
+	img, err = png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Errorf("Preview should be a valid PNG: %s", err)
+		return
+	}
+
+	r, g, b, _ := img.At(previewPadding, previewPadding).RGBA()
+	if r>>8 != 66 || g>>8 != 66 || b>>8 != 66 {
+		t.Errorf("AOI outline should be gray but was %d/%d/%d", r>>8, g>>8, b>>8)
+	}
+
+	_, err = RenderPreview(tasks, "foo", 100)
+	if err == nil {
+		t.Errorf("Rendering preview with invalid AOI should not work")
+	}
+}
+
+func TestApplyOperations(t *testing.T) {
+	h.Run(t, func() error {
+		now := time.Now()
+		operations := []*Operation{
+			// Based on the same version as the assignment, since the client was offline
+			{Id: "a", Type: OperationSetProcessPoints, TaskId: "4", Version: 0, ProcessPoints: 10, Timestamp: now.Add(2 * time.Second)},
+			{Id: "b", Type: OperationAssign, TaskId: "4", Version: 0, Timestamp: now.Add(1 * time.Second)},
+			{Id: "c", Type: OperationUnassign, TaskId: "3", Version: 5, Timestamp: now.Add(3 * time.Second)},
+			{Id: "d", Type: OperationAssign, TaskId: "5", Version: 0, Timestamp: now.Add(4 * time.Second)}, // not a member
+			{Id: "e", Type: "foo", TaskId: "2", Version: 0, Timestamp: now.Add(5 * time.Second)},
+			{Id: "f", Type: OperationAssign, TaskId: "not-a-number", Version: 0, Timestamp: now.Add(6 * time.Second)}, // database error
+			{Id: "g", Type: OperationUnassign, TaskId: "4", Version: 0, Timestamp: now.Add(7 * time.Second)},
+			{Id: "h", Type: OperationUnassign, TaskId: "4", Version: 1, Timestamp: now.Add(8 * time.Second)}, // outdated
+		}
+
+		results, err := s.ApplyOperations(operations, "Maria")
+		if err != nil {
+			return errors.Wrap(err, "applying operations should work")
+		}
+
+		if len(results) != 8 {
+			return errors.New(fmt.Sprintf("Expected 8 results but got %d", len(results)))
+		}
+
+		// Operations are applied in order of their timestamps
+		if results[0].Id != "b" || !results[0].Success || results[0].Task.AssignedUser != "Maria" {
+			return errors.New(fmt.Sprintf("Assign operation not applied correctly: %#v", results[0]))
+		}
+		if results[1].Id != "a" || !results[1].Success || results[1].Task.ProcessPoints != 10 || results[1].Task.Version != 2 {
+			return errors.New(fmt.Sprintf("Process point operation not applied correctly: %#v", results[1]))
+		}
+		if results[2].Id != "c" || results[2].Success || !results[2].Conflict || results[2].Task == nil {
+			return errors.New(fmt.Sprintf("Operation with old version should be a conflict: %#v", results[2]))
+		}
+		if results[3].Id != "d" || results[3].Success || results[3].Conflict || results[3].Error == "" {
+			return errors.New(fmt.Sprintf("Operation on foreign task should fail: %#v", results[3]))
+		}
+		if results[4].Id != "e" || results[4].Success || results[4].Error == "" {
+			return errors.New(fmt.Sprintf("Operation with unknown type should fail: %#v", results[4]))
+		}
+		if results[5].Id != "f" || results[5].Success || results[5].Error == "" {
+			return errors.New(fmt.Sprintf("Operation causing a database error should fail: %#v", results[5]))
+		}
+		// The database error doesn't affect later operations
+		if results[6].Id != "g" || !results[6].Success || results[6].Task.AssignedUser != "" || results[6].Task.Version != 3 {
+			return errors.New(fmt.Sprintf("Third operation on the same task should be applied: %#v", results[6]))
+		}
+		if results[7].Id != "h" || results[7].Success || !results[7].Conflict {
+			return errors.New(fmt.Sprintf("Operation with outdated version should be a conflict: %#v", results[7]))
+		}
+
+		return nil
+	})
+}
+
 func TestDelete(t *testing.T) {
 	h.Run(t, func() error {
 		// tasks of project 2
@@ -0,0 +1,34 @@
+package task
+
+// GetTimeline returns all events of the tasks of the project in chronological order, so that clients can replay how
+// the project has been completed over time. Only members are allowed to see it. When the anonymization is enabled,
+// only the owner gets the real user IDs, all other members get pseudonyms.
+func (s *TaskService) GetTimeline(projectId string, requestingUserId string) ([]*TimelineEvent, error) {
+	err := s.permissionService.VerifyMembershipProject(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := s.store.GetTimeline(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	doneProcessPoints := 0
+	for _, e := range events {
+		doneProcessPoints += e.PointsDelta
+		e.DoneProcessPoints = doneProcessPoints
+	}
+
+	showRealNames, err := s.showRealNames(projectId, requestingUserId)
+	if err != nil {
+		return nil, err
+	}
+	if !showRealNames {
+		anonymizeTimeline(events)
+	}
+
+	s.Log("Got %d timeline events of project %s", len(events), projectId)
+
+	return events, nil
+}
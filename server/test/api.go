@@ -0,0 +1,102 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+)
+
+// ApiClient sends requests to a test server on behalf of a fake user. The user doesn't need an OSM account, the token
+// is created by the auth package in the same way as after a real login.
+//
+// This package doesn't create the token itself, since the auth package depends on services (e.g. the sessions) whose
+// tests use this package.
+type ApiClient struct {
+	server *httptest.Server
+	token  string
+}
+
+// NewApiClient creates a client sending requests with the given token, see "auth.CreateToken".
+func NewApiClient(server *httptest.Server, token string) *ApiClient {
+	return &ApiClient{
+		server: server,
+		token:  token,
+	}
+}
+
+// NewAnonymousApiClient creates a client sending requests without any token.
+func NewAnonymousApiClient(server *httptest.Server) *ApiClient {
+	return &ApiClient{
+		server: server,
+	}
+}
+
+// Request sends a request to the given path (e.g. "/v2.4/projects") of the test server. A body that is not nil is
+// encoded as JSON.
+func (c *ApiClient) Request(method string, path string, body interface{}) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return nil, errors.Wrap(err, "error marshalling request body")
+		}
+		bodyReader = bytes.NewReader(bodyBytes)
+	}
+
+	request, err := http.NewRequest(method, c.server.URL+path, bodyReader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating request %s %s", method, path)
+	}
+
+	if c.token != "" {
+		request.Header.Set("Authorization", c.token)
+	}
+
+	return c.server.Client().Do(request)
+}
+
+// RequestJson works like "Request" but fails for all status codes except 200 and decodes the response into the given
+// result. The result may be nil for responses without body.
+func (c *ApiClient) RequestJson(method string, path string, body interface{}, result interface{}) error {
+	response, err := c.Request(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return errors.Wrap(err, "error reading response body")
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("%s %s returned status %d: %s", method, path, response.StatusCode, string(responseBytes)))
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	err = json.Unmarshal(responseBytes, result)
+	return errors.Wrapf(err, "error unmarshalling response of %s %s", method, path)
+}
+
+// ExpectStatus sends the request and returns an error when the response has a different status code than expected.
+func (c *ApiClient) ExpectStatus(method string, path string, body interface{}, expectedStatus int) error {
+	response, err := c.Request(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != expectedStatus {
+		return errors.New(fmt.Sprintf("%s %s returned status %d but expected %d", method, path, response.StatusCode, expectedStatus))
+	}
+
+	return nil
+}
@@ -1,8 +1,30 @@
 -- 
 -- Reset database
 -- 
+DELETE FROM api_usage;
+DELETE FROM audit_log;
+DELETE FROM digest_subscriptions;
+DELETE FROM export_jobs;
+DELETE FROM feature_flags;
+DELETE FROM invitations;
+DELETE FROM local_accounts;
+DELETE FROM notifications;
+DELETE FROM outbox;
+DELETE FROM project_commands;
+DELETE FROM project_redirects;
+DELETE FROM project_snapshots;
+DELETE FROM project_watchers;
+DELETE FROM push_subscriptions;
 DELETE FROM projects;
+DELETE FROM retention_stats;
+DELETE FROM sessions;
+DELETE FROM task_changesets;
+DELETE FROM task_handovers;
+DELETE FROM task_history;
+DELETE FROM task_notes;
+DELETE FROM task_reservations;
 DELETE FROM tasks;
+DELETE FROM user_quotas;
 DELETE FROM db_versions WHERE version='test';
 
 --
@@ -33,8 +55,48 @@ INSERT INTO projects(id, name, users, owner) VALUES (3, 'Project 3', '{Otto}', '
 INSERT INTO tasks(id, project_id, process_points, max_process_points, geometry, assigned_user) VALUES (5, 3, 345, 1000, '{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[9.951631591968885,53.563785517845105],[9.935667083912245,53.55022340710764],[10.00639157121693,53.53675896834966],[10.013773010425917,53.570921724776724],[9.951631591968885,53.563785517845105]]]},"properties":null}', '');
 INSERT INTO tasks(id, project_id, process_points, max_process_points, geometry, assigned_user) VALUES (8, 3, 0, 1000, '{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[9.951631591968885,53.563785517845105],[9.935667083912245,53.55022340710764],[10.00639157121693,53.53675896834966],[10.013773010425917,53.570921724776724],[9.951631591968885,53.563785517845105]]]},"properties":null}', 'Otto');
 
+--
+-- Task history
+--
+INSERT INTO task_history(id, task_id, user_id, type, process_points, points_delta, created_at) VALUES (1, 2, 'Clara', 'assigned', 0, 0, '2020-09-01 10:00:00');
+INSERT INTO task_history(id, task_id, user_id, type, process_points, points_delta, created_at) VALUES (2, 2, 'Clara', 'process_points_set', 100, 100, '2020-09-01 12:00:00');
+INSERT INTO task_history(id, task_id, user_id, type, process_points, points_delta, created_at) VALUES (3, 2, 'Clara', 'unassigned', 100, 0, '2020-09-01 12:01:00');
+INSERT INTO task_history(id, task_id, user_id, type, process_points, points_delta, created_at) VALUES (4, 3, 'Maria', 'assigned', 0, 0, '2020-09-02 08:00:00');
+INSERT INTO task_history(id, task_id, user_id, type, process_points, points_delta, created_at) VALUES (5, 3, 'Maria', 'process_points_set', 50, 50, '2020-09-02 09:00:00');
+INSERT INTO task_history(id, task_id, user_id, type, process_points, points_delta, created_at) VALUES (6, 7, 'Donny', 'assigned', 0, 0, '2020-09-03 08:00:00');
+INSERT INTO task_history(id, task_id, user_id, type, process_points, points_delta, created_at) VALUES (7, 7, 'Donny', 'process_points_set', 3, 3, '2020-09-03 09:00:00');
+
+--
+-- Audit log, which is otherwise written together with the task history
+--
+INSERT INTO audit_log(created_at, project_id, task_id, user_id, action, details)
+SELECT h.created_at, t.project_id, h.task_id, h.user_id, h.type, jsonb_build_object('processPoints', h.process_points, 'pointsDelta', h.points_delta, 'comment', h.comment)
+FROM task_history h JOIN tasks t ON t.id = h.task_id ORDER BY h.id;
+
+--
+-- Project snapshots
+--
+INSERT INTO project_snapshots(project_id, date, done_process_points, total_process_points) VALUES (2, '2020-09-01', 100, 308);
+INSERT INTO project_snapshots(project_id, date, done_process_points, total_process_points) VALUES (2, '2020-09-02', 150, 308);
+INSERT INTO project_snapshots(project_id, date, done_process_points, total_process_points) VALUES (2, '2020-09-03', 154, 308);
+
+--
+-- Digest subscriptions
+--
+INSERT INTO digest_subscriptions(project_id, user_id, email, digest_interval, last_sent) VALUES (1, 'Peter', 'peter@example.com', 'daily', NULL);
+INSERT INTO digest_subscriptions(project_id, user_id, email, digest_interval, last_sent) VALUES (2, 'Maria', 'maria@example.com', 'weekly', NOW());
+
+--
+-- Process points and AOI of projects, which are otherwise updated when tasks change
+--
+UPDATE projects p SET
+	done_process_points = (SELECT COALESCE(SUM(t.process_points), 0) FROM tasks t WHERE t.project_id = p.id),
+	total_process_points = (SELECT COALESCE(SUM(t.max_process_points), 0) FROM tasks t WHERE t.project_id = p.id),
+	aoi = (SELECT ST_Union(ST_MakeValid(ST_SetSRID(ST_GeomFromGeoJSON(t.geometry::JSON->>'geometry'), 4326))) FROM tasks t WHERE t.project_id = p.id);
+
 --
 -- Reset sequences for primary keys
 --
 ALTER SEQUENCE projects_id_seq RESTART WITH 4;
-ALTER SEQUENCE tasks_id_seq RESTART WITH 9;
\ No newline at end of file
+ALTER SEQUENCE tasks_id_seq RESTART WITH 9;
+ALTER SEQUENCE task_history_id_seq RESTART WITH 8;
\ No newline at end of file
@@ -63,6 +63,11 @@ func (h *TestHelper) RunFail(t *testing.T, testFunc func() error) {
 }
 
 func (h *TestHelper) tearDown() {
+	// API tests have no transaction, the handlers commit their own ones
+	if h.Tx == nil {
+		return
+	}
+
 	err := h.Tx.Commit()
 	if err != nil {
 		panic(err)
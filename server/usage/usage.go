@@ -0,0 +1,99 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+// UserUsage summarizes the API calls of one user.
+type UserUsage struct {
+	UserId       string    `json:"userId"`
+	Calls        int       `json:"calls"`        // Number of calls within the requested period
+	LastActivity time.Time `json:"lastActivity"` // Time of the latest call, also when it's older than the requested period
+}
+
+type UsageService struct {
+	*util.Logger
+	store *storePg
+}
+
+// pendingUsage are the calls recorded since the last flush. Calls are counted in memory, so that requests don't need an
+// additional write to the database.
+type pendingUsage struct {
+	calls        int
+	lastActivity time.Time
+}
+
+var (
+	pending      = make(map[string]*pendingUsage)
+	pendingMutex = &sync.Mutex{}
+)
+
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger) *UsageService {
+	return &UsageService{
+		Logger: logger,
+		store:  getStore(ctx, tx, logger),
+	}
+}
+
+// FlushJob is meant to be executed by the scheduler. It writes all recorded calls to the database.
+func FlushJob(ctx context.Context, tx *sql.Tx, logger *util.Logger) error {
+	return Init(ctx, tx, logger).Flush()
+}
+
+// Record counts one API call of the given user. The call is stored in the database with the next flush.
+func Record(userId string) {
+	pendingMutex.Lock()
+	defer pendingMutex.Unlock()
+
+	usage, ok := pending[userId]
+	if !ok {
+		usage = &pendingUsage{}
+		pending[userId] = usage
+	}
+
+	usage.calls++
+	usage.lastActivity = time.Now().UTC()
+}
+
+// takePending returns all recorded calls and starts recording from scratch.
+func takePending() map[string]*pendingUsage {
+	pendingMutex.Lock()
+	defer pendingMutex.Unlock()
+
+	result := pending
+	pending = make(map[string]*pendingUsage)
+	return result
+}
+
+// Flush adds all recorded calls to the statistics in the database.
+func (s *UsageService) Flush() error {
+	usages := takePending()
+
+	for userId, usage := range usages {
+		err := s.store.addCalls(userId, usage.calls, usage.lastActivity)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.Debug("Flushed API usage of %d users", len(usages))
+
+	return nil
+}
+
+// GetUsage returns the number of API calls per user within the last days (including today), the most active user
+// first. Users without calls in this period are part of the result as well, so that inactive accounts can be spotted.
+func (s *UsageService) GetUsage(days int) ([]*UserUsage, error) {
+	if days <= 0 {
+		return nil, errors.New(fmt.Sprintf("number of days must be positive but was %d", days))
+	}
+
+	return s.store.getUsage(days)
+}
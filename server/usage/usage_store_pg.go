@@ -0,0 +1,74 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+type storePg struct {
+	*util.Logger
+	ctx   context.Context
+	tx    *sql.Tx
+	table database.Table
+}
+
+func getStore(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
+	return &storePg{
+		Logger: logger,
+		ctx:    ctx,
+		tx:     tx,
+		table:  database.TableApiUsage,
+	}
+}
+
+// addCalls adds the calls to the statistics of the day of the last activity.
+func (s *storePg) addCalls(userId string, calls int, lastActivity time.Time) error {
+	query := fmt.Sprintf(`INSERT INTO %s(user_id, date, calls, last_activity) VALUES($1, $2, $3, $4)
+ON CONFLICT (user_id, date) DO UPDATE SET calls=%s.calls+EXCLUDED.calls, last_activity=GREATEST(%s.last_activity, EXCLUDED.last_activity);`, s.table, s.table, s.table)
+	date := lastActivity.Format("2006-01-02")
+	s.LogQuery(query, userId, date, calls, lastActivity)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, userId, date, calls, lastActivity)
+	if err != nil {
+		return errors.Wrapf(err, "error adding API usage of user %s", userId)
+	}
+
+	return nil
+}
+
+func (s *storePg) getUsage(days int) ([]*UserUsage, error) {
+	query := fmt.Sprintf(`SELECT user_id, COALESCE(SUM(calls) FILTER (WHERE date > CURRENT_DATE - $1::INT), 0), MAX(last_activity) FROM %s
+GROUP BY user_id
+ORDER BY 2 DESC, user_id;`, s.table)
+	s.LogQuery(query, days)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, days)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting API usage")
+	}
+	defer rows.Close()
+
+	usages := make([]*UserUsage, 0)
+	for rows.Next() {
+		usage := &UserUsage{}
+
+		err = rows.Scan(&usage.UserId, &usage.Calls, &usage.LastActivity)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan API usage")
+		}
+
+		usages = append(usages, usage)
+	}
+
+	return usages, nil
+}
@@ -0,0 +1,103 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/test"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+	"testing"
+
+	_ "github.com/lib/pq" // Make driver "postgres" usable
+)
+
+var (
+	tx *sql.Tx
+	s  *UsageService
+	h  *test.TestHelper
+)
+
+func TestMain(m *testing.M) {
+	h = &test.TestHelper{
+		Setup: setup,
+	}
+
+	m.Run()
+}
+
+func setup() {
+	config.LoadConfig("../config/test.json")
+	test.InitWithDummyData()
+	sigolo.LogLevel = sigolo.LOG_DEBUG
+
+	logger := util.NewLogger()
+
+	ctx := context.Background()
+
+	var err error
+	tx, err = database.GetTransaction(ctx, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	h.Tx = tx
+	s = Init(ctx, tx, logger)
+}
+
+func TestRecord(t *testing.T) {
+	Record("Peter")
+	Record("Maria")
+	Record("Peter")
+
+	usages := takePending()
+	if len(usages) != 2 || usages["Peter"].calls != 2 || usages["Maria"].calls != 1 {
+		t.Errorf("Recorded usage does not match: %v", usages)
+		return
+	}
+
+	usages = takePending()
+	if len(usages) != 0 {
+		t.Errorf("Recorded usage should have been reset: %v", usages)
+		return
+	}
+}
+
+func TestFlushAndGetUsage(t *testing.T) {
+	h.Run(t, func() error {
+		Record("Peter")
+		Record("Peter")
+		Record("Maria")
+
+		err := s.Flush()
+		if err != nil {
+			return err
+		}
+
+		// Calls of the same day are added up
+		Record("Peter")
+		err = s.Flush()
+		if err != nil {
+			return err
+		}
+
+		usages, err := s.GetUsage(7)
+		if err != nil {
+			return err
+		}
+
+		if len(usages) != 2 || usages[0].UserId != "Peter" || usages[0].Calls != 3 || usages[1].UserId != "Maria" || usages[1].Calls != 1 {
+			return errors.New(fmt.Sprintf("Usage does not match: %v", usages))
+		}
+
+		_, err = s.GetUsage(0)
+		if err == nil {
+			return errors.New("Getting usage of zero days should not be possible")
+		}
+
+		return nil
+	})
+}
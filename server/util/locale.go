@@ -0,0 +1,89 @@
+package util
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var localeRegex = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// IsValidLocale checks the format of a locale like "de" or "en-US". It doesn't check whether the language exists.
+func IsValidLocale(locale string) bool {
+	return localeRegex.MatchString(locale)
+}
+
+// ParseAcceptLanguage returns the locales of the "Accept-Language" header ordered by their quality, the preferred one
+// first. The wildcard "*" and locales with a quality of 0 are ignored.
+func ParseAcceptLanguage(header string) []string {
+	type weightedLocale struct {
+		locale  string
+		quality float64
+	}
+
+	weightedLocales := make([]weightedLocale, 0)
+	for _, part := range strings.Split(header, ",") {
+		segments := strings.Split(part, ";")
+
+		locale := strings.TrimSpace(segments[0])
+		if locale == "" || locale == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, parameter := range segments[1:] {
+			parameter = strings.TrimSpace(parameter)
+			if strings.HasPrefix(parameter, "q=") {
+				q, err := strconv.ParseFloat(strings.TrimPrefix(parameter, "q="), 64)
+				if err == nil {
+					quality = q
+				}
+			}
+		}
+
+		if quality > 0 {
+			weightedLocales = append(weightedLocales, weightedLocale{locale, quality})
+		}
+	}
+
+	// Stable, so that locales with equal quality keep the order of the header
+	sort.SliceStable(weightedLocales, func(i, j int) bool {
+		return weightedLocales[i].quality > weightedLocales[j].quality
+	})
+
+	result := make([]string, len(weightedLocales))
+	for i, l := range weightedLocales {
+		result[i] = l.locale
+	}
+	return result
+}
+
+// MatchLocale returns the available locale matching the "Accept-Language" header best or "" when none matches. Locales
+// of the same language match as well (e.g. "de-AT" matches "de" or "de-DE") but exact matches are preferred, followed
+// by the locale of the language itself (e.g. "de").
+func MatchLocale(acceptLanguage string, available []string) string {
+	for _, preferred := range ParseAcceptLanguage(acceptLanguage) {
+		language := primaryLanguage(preferred)
+
+		candidates := []func(a string) bool{
+			func(a string) bool { return strings.EqualFold(a, preferred) },
+			func(a string) bool { return strings.EqualFold(a, language) },
+			func(a string) bool { return strings.EqualFold(primaryLanguage(a), language) },
+		}
+
+		for _, matches := range candidates {
+			for _, a := range available {
+				if matches(a) {
+					return a
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+func primaryLanguage(locale string) string {
+	return strings.SplitN(locale, "-", 2)[0]
+}
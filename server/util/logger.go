@@ -6,6 +6,14 @@ import (
 	"strings"
 )
 
+// Keys of the fields attached to log messages
+const (
+	LogFieldRequest = "requestId"
+	LogFieldUser    = "user"
+	LogFieldProject = "projectId"
+	LogFieldTask    = "taskId"
+)
+
 var (
 	nextTraceId = 0
 )
@@ -17,18 +25,53 @@ func NewLogger() *Logger {
 
 type Logger struct {
 	LogTraceId int
+	fields     []logField
+}
+
+type logField struct {
+	key   string
+	value string
+}
+
+// SetField attaches the key-value pair to all further messages of this logger. All services of a request share the
+// same logger, so a field set by one service also appears in the messages of all other services. Setting an existing
+// key again overwrites its value.
+func (l *Logger) SetField(key string, value string) {
+	for i, f := range l.fields {
+		if f.key == key {
+			l.fields[i].value = value
+			return
+		}
+	}
+
+	l.fields = append(l.fields, logField{key: key, value: value})
+}
+
+// prefix returns the trace-ID followed by all fields in the order they were set, e.g. "#2a | user=123 projectId=4".
+func (l *Logger) prefix() string {
+	prefix := fmt.Sprintf("#%x", l.LogTraceId)
+	if len(l.fields) == 0 {
+		return prefix
+	}
+
+	fieldStrings := make([]string, len(l.fields))
+	for i, f := range l.fields {
+		fieldStrings[i] = f.key + "=" + f.value
+	}
+
+	return prefix + " | " + strings.Join(fieldStrings, " ")
 }
 
 func (l *Logger) Log(format string, args ...interface{}) {
-	sigolo.Infob(1, "#%x | %s", l.LogTraceId, fmt.Sprintf(format, args...))
+	sigolo.Infob(1, "%s | %s", l.prefix(), fmt.Sprintf(format, args...))
 }
 
 func (l *Logger) Err(format string, args ...interface{}) {
-	sigolo.Errorb(1, "#%x | %s", l.LogTraceId, fmt.Sprintf(format, args...))
+	sigolo.Errorb(1, "%s | %s", l.prefix(), fmt.Sprintf(format, args...))
 }
 
 func (l *Logger) Debug(format string, args ...interface{}) {
-	sigolo.Debugb(1, "#%x | %s", l.LogTraceId, fmt.Sprintf(format, args...))
+	sigolo.Debugb(1, "%s | %s", l.prefix(), fmt.Sprintf(format, args...))
 }
 
 func (l *Logger) Stack(err error) {
@@ -8,4 +8,9 @@ type Osm struct {
 type OsmUser struct {
 	DisplayName string `xml:"display_name,attr"`
 	UserId string `xml:"id,attr"`
+	Changesets OsmChangesets `xml:"changesets"`
+}
+
+type OsmChangesets struct {
+	Count int `xml:"count,attr"`
 }
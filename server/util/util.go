@@ -1,11 +1,14 @@
 package util
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -30,6 +33,100 @@ func GetIntParam(param string, r *http.Request) (int, error) {
 	return strconv.Atoi(valueString)
 }
 
+func GetFloatParam(param string, r *http.Request) (float64, error) {
+	valueString, err := GetParam(param, r)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseFloat(valueString, 64)
+}
+
+func GetBoolParam(param string, r *http.Request) (bool, error) {
+	valueString, err := GetParam(param, r)
+	if err != nil {
+		return false, err
+	}
+
+	return strconv.ParseBool(valueString)
+}
+
+// GetTimeParam returns the value of the given parameter, which has to be a RFC 3339 timestamp (e.g.
+// "2021-03-14T15:09:26Z").
+func GetTimeParam(param string, r *http.Request) (time.Time, error) {
+	valueString, err := GetParam(param, r)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339, valueString)
+}
+
+// GetListParam returns the comma separated values of the given parameter. Empty values are ignored, so an empty list is
+// returned when the parameter is not specified.
+func GetListParam(param string, r *http.Request) []string {
+	values := make([]string, 0)
+
+	for _, v := range strings.Split(r.FormValue(param), ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+// FilterFields reduces the JSON representation of the given object (or list of objects) to the given fields. The
+// result can be marshalled like the original data. An error is returned when a requested field does not exist.
+func FilterFields(data interface{}, fields []string) (interface{}, error) {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling data to filter")
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(dataBytes), []byte("[")) {
+		var objects []map[string]json.RawMessage
+		err = json.Unmarshal(dataBytes, &objects)
+		if err != nil {
+			return nil, errors.Wrap(err, "error unmarshalling list of objects to filter")
+		}
+
+		result := make([]map[string]json.RawMessage, len(objects))
+		for i, o := range objects {
+			result[i], err = filterObjectFields(o, fields)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return result, nil
+	}
+
+	var object map[string]json.RawMessage
+	err = json.Unmarshal(dataBytes, &object)
+	if err != nil {
+		return nil, errors.Wrap(err, "error unmarshalling object to filter")
+	}
+
+	return filterObjectFields(object, fields)
+}
+
+func filterObjectFields(object map[string]json.RawMessage, fields []string) (map[string]json.RawMessage, error) {
+	result := make(map[string]json.RawMessage)
+
+	for _, f := range fields {
+		value, ok := object[f]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("field '%s' does not exist", f))
+		}
+
+		result[f] = value
+	}
+
+	return result, nil
+}
+
 func ResponseBadRequest(w http.ResponseWriter, logger *Logger, err error) {
 	ErrorResponse(w, logger, err, http.StatusBadRequest)
 }
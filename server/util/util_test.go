@@ -1,9 +1,13 @@
 package util
 
 import (
+	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 type DummyResponseWriter struct {
@@ -107,6 +111,142 @@ func TestGetIntParam(t *testing.T) {
 	}
 }
 
+func TestGetFloatParam(t *testing.T) {
+	params := make(map[string][]string)
+	params["foo"] = []string{"0.25"}
+	params["bar"] = []string{"abc"}
+
+	r := &http.Request{
+		Form: params,
+	}
+
+	// Existing param
+
+	param, err := GetFloatParam("foo", r)
+	if err != nil {
+		t.Errorf("Getting params should work: %s", err.Error())
+		t.Fail()
+		return
+	}
+	if param != 0.25 {
+		t.Errorf("Param should have value '0.25'")
+		t.Fail()
+		return
+	}
+
+	// Not a number
+
+	_, err = GetFloatParam("bar", r)
+	if err == nil {
+		t.Error("Getting non-number params should not work")
+		t.Fail()
+		return
+	}
+
+	// Not existing param
+
+	param, err = GetFloatParam("utini", r)
+	if err == nil {
+		t.Error("Getting params should not work")
+		t.Fail()
+		return
+	}
+	if param != 0 {
+		t.Errorf("Param for key 'utini' should be '0''")
+		t.Fail()
+		return
+	}
+}
+
+func TestGetTimeParam(t *testing.T) {
+	params := make(map[string][]string)
+	params["foo"] = []string{"2021-03-14T16:09:26+01:00"}
+	params["bar"] = []string{"yesterday"}
+
+	r := &http.Request{
+		Form: params,
+	}
+
+	param, err := GetTimeParam("foo", r)
+	if err != nil {
+		t.Errorf("Getting params should work: %s", err.Error())
+		return
+	}
+	if !param.Equal(time.Date(2021, 3, 14, 15, 9, 26, 0, time.UTC)) {
+		t.Errorf("Param not matching: %s", param)
+	}
+
+	_, err = GetTimeParam("bar", r)
+	if err == nil {
+		t.Errorf("Getting a param which is not a timestamp should fail")
+	}
+
+	_, err = GetTimeParam("utini", r)
+	if err == nil {
+		t.Errorf("Getting a not existing param should fail")
+	}
+}
+
+func TestGetListParam(t *testing.T) {
+	params := make(map[string][]string)
+	params["foo"] = []string{"id, name,,progress"}
+
+	r := &http.Request{
+		Form: params,
+	}
+
+	values := GetListParam("foo", r)
+	if len(values) != 3 || values[0] != "id" || values[1] != "name" || values[2] != "progress" {
+		t.Errorf("Values not matching: %#v", values)
+	}
+
+	values = GetListParam("utini", r)
+	if len(values) != 0 {
+		t.Errorf("Values for key 'utini' should be empty: %#v", values)
+	}
+}
+
+func TestFilterFields(t *testing.T) {
+	type dummy struct {
+		Id       string `json:"id"`
+		Name     string `json:"name"`
+		Geometry string `json:"geometry"`
+	}
+
+	// Single object
+
+	filtered, err := FilterFields(dummy{"1", "foo", "{}"}, []string{"id", "name"})
+	if err != nil {
+		t.Errorf("Filtering should work: %s", err.Error())
+		return
+	}
+
+	filteredBytes, _ := json.Marshal(filtered)
+	if string(filteredBytes) != `{"id":"1","name":"foo"}` {
+		t.Errorf("Filtered object not matching: %s", string(filteredBytes))
+	}
+
+	// List of objects
+
+	filtered, err = FilterFields([]*dummy{{"1", "foo", "{}"}, {"2", "bar", "{}"}}, []string{"id"})
+	if err != nil {
+		t.Errorf("Filtering should work: %s", err.Error())
+		return
+	}
+
+	filteredBytes, _ = json.Marshal(filtered)
+	if string(filteredBytes) != `[{"id":"1"},{"id":"2"}]` {
+		t.Errorf("Filtered list not matching: %s", string(filteredBytes))
+	}
+
+	// Unknown field
+
+	_, err = FilterFields(dummy{"1", "foo", "{}"}, []string{"id", "utini"})
+	if err == nil {
+		t.Error("Filtering unknown field should not work")
+	}
+}
+
 func TestResponseErrors(t *testing.T) {
 	logger := NewLogger()
 
@@ -132,3 +272,166 @@ func TestResponseErrors(t *testing.T) {
 		t.Errorf("response not matching: %#v", w)
 	}
 }
+
+func TestLoggerFields(t *testing.T) {
+	logger := &Logger{LogTraceId: 42}
+
+	if logger.prefix() != "#2a" {
+		t.Errorf("Prefix without fields not matching: %s", logger.prefix())
+	}
+
+	logger.SetField(LogFieldUser, "123")
+	logger.SetField(LogFieldProject, "4")
+	logger.SetField(LogFieldUser, "456")
+
+	if logger.prefix() != "#2a | user=456 projectId=4" {
+		t.Errorf("Prefix with fields not matching: %s", logger.prefix())
+	}
+}
+
+func TestIsValidLocale(t *testing.T) {
+	for _, locale := range []string{"de", "en-US", "zh-Hant-TW"} {
+		if !IsValidLocale(locale) {
+			t.Errorf("Locale '%s' should be valid", locale)
+			return
+		}
+	}
+
+	for _, locale := range []string{"", "d", "english", "de_DE", "de-"} {
+		if IsValidLocale(locale) {
+			t.Errorf("Locale '%s' should be invalid", locale)
+			return
+		}
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	locales := ParseAcceptLanguage("fr;q=0.5, de-AT, en;q=0.8, *;q=0.1, it;q=0")
+	if len(locales) != 3 || locales[0] != "de-AT" || locales[1] != "en" || locales[2] != "fr" {
+		t.Errorf("Parsed locales do not match: %v", locales)
+		return
+	}
+
+	locales = ParseAcceptLanguage("")
+	if len(locales) != 0 {
+		t.Errorf("Empty header should not contain locales: %v", locales)
+		return
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	available := []string{"en", "de-DE", "de"}
+
+	if l := MatchLocale("de-DE, en;q=0.5", available); l != "de-DE" {
+		t.Errorf("Exact match expected but got '%s'", l)
+		return
+	}
+
+	if l := MatchLocale("de-AT, en;q=0.5", available); l != "de" {
+		t.Errorf("Match of same language expected but got '%s'", l)
+		return
+	}
+
+	if l := MatchLocale("de-AT", []string{"en", "de-DE"}); l != "de-DE" {
+		t.Errorf("Match of other variant of same language expected but got '%s'", l)
+		return
+	}
+
+	if l := MatchLocale("fr, en;q=0.5", available); l != "en" {
+		t.Errorf("Match of less preferred locale expected but got '%s'", l)
+		return
+	}
+
+	if l := MatchLocale("fr", available); l != "" {
+		t.Errorf("No match expected but got '%s'", l)
+		return
+	}
+}
+
+type validationTestItem struct {
+	Name  string `json:"name" validate:"required"`
+	Count int    `json:"count" validate:"min=1,max=10"`
+}
+
+type validationTestDto struct {
+	Title string                `json:"title" validate:"max=5"`
+	Kind  string                `json:"kind" validate:"omitempty,oneof=a b"`
+	Items []*validationTestItem `json:"items" validate:"required"`
+}
+
+func decodeTestBody(body string, maxBytes int64) (*validationTestDto, error) {
+	r := &http.Request{
+		Body: ioutil.NopCloser(strings.NewReader(body)),
+	}
+
+	var dto validationTestDto
+	err := DecodeJsonBody(r, &dto, maxBytes)
+	return &dto, err
+}
+
+func TestDecodeJsonBody(t *testing.T) {
+	dto, err := decodeTestBody(`{"title":"foo","items":[{"name":"bar","count":3}]}`, 1000)
+	if err != nil {
+		t.Errorf("Decoding valid body should work: %s", err)
+		return
+	}
+	if dto.Title != "foo" || len(dto.Items) != 1 || dto.Items[0].Count != 3 {
+		t.Errorf("Decoded body does not match: %#v", dto)
+	}
+
+	invalidBodies := map[string]string{
+		`{"title":"foo","items":[{"name":"bar","count":3}],"foo":1}`: `unknown field "foo"`,
+		`{"title":1,"items":[{"name":"bar","count":3}]}`:             "field 'title' must be of type string but was number",
+		`{"items":[{"name":"bar","count":"3"}]}`:                     "must be of type integer but was string",
+		`{"items":[{"name":"bar","count":3}]} {}`:                    "only one JSON value",
+		`{"items":[`: "incomplete JSON",
+		``:           "request body is empty",
+		`{"items":[{"name":"bar","count":3}],"title":"` + strings.Repeat("x", 1000) + `"}`: "request body too large",
+	}
+
+	for body, expectedError := range invalidBodies {
+		_, err = decodeTestBody(body, 1000)
+		if err == nil || !strings.Contains(err.Error(), expectedError) {
+			t.Errorf("Expected error containing '%s' for body '%s' but got: %v", expectedError, body, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dto := &validationTestDto{
+		Title: "too long",
+		Kind:  "c",
+		Items: []*validationTestItem{
+			{Name: "foo", Count: 1},
+			{Name: "", Count: 11},
+		},
+	}
+
+	err := Validate(dto)
+	if err == nil {
+		t.Errorf("Validating invalid DTO should fail")
+		return
+	}
+
+	expectedViolations := []string{
+		"field 'title' must be at most 5 characters long",
+		"field 'kind' must be one of 'a', 'b' but was 'c'",
+		"field 'items[1].name' is required",
+		"field 'items[1].count' must be at most 10",
+	}
+	for _, v := range expectedViolations {
+		if !strings.Contains(err.Error(), v) {
+			t.Errorf("Expected violation '%s' in: %s", v, err.Error())
+		}
+	}
+
+	err = Validate(&validationTestDto{Items: []*validationTestItem{{Name: "foo", Count: 1}}})
+	if err != nil {
+		t.Errorf("Validating valid DTO should work: %s", err)
+	}
+
+	err = Validate(&validationTestDto{})
+	if err == nil || !strings.Contains(err.Error(), "field 'items' is required") {
+		t.Errorf("Empty list should be invalid: %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DecodeJsonBody strictly decodes the JSON body of the request into the target and validates it (see "Validate"). Unknown
+// fields, wrong types, additional data after the JSON value and bodies larger than "maxBytes" are rejected. The returned
+// errors name the affected field, so they can be passed to the client.
+func DecodeJsonBody(r *http.Request, target interface{}, maxBytes int64) error {
+	bodyBytes, err := ReadBody(r, maxBytes)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(bodyBytes))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(target)
+	if err != nil {
+		return describeDecodeError(err)
+	}
+
+	if decoder.More() {
+		return errors.New("request body must contain only one JSON value")
+	}
+
+	return Validate(target)
+}
+
+// ReadBody reads the whole body of the request but fails when it's larger than "maxBytes".
+func ReadBody(r *http.Request, maxBytes int64) ([]byte, error) {
+	// Read one byte more than allowed to detect too large bodies
+	bodyBytes, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading request body")
+	}
+
+	if int64(len(bodyBytes)) > maxBytes {
+		return nil, errors.New(fmt.Sprintf("request body too large, maximum allowed are %d bytes", maxBytes))
+	}
+
+	return bodyBytes, nil
+}
+
+func describeDecodeError(err error) error {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return errors.New(fmt.Sprintf("invalid JSON at position %d: %s", e.Offset, e.Error()))
+	case *json.UnmarshalTypeError:
+		if e.Field == "" {
+			return errors.New(fmt.Sprintf("request body must be of type %s but was %s", jsonTypeName(e.Type), e.Value))
+		}
+		return errors.New(fmt.Sprintf("field '%s' must be of type %s but was %s", e.Field, jsonTypeName(e.Type), e.Value))
+	}
+
+	if err == io.EOF {
+		return errors.New("request body is empty")
+	}
+	if err == io.ErrUnexpectedEOF {
+		return errors.New("request body is incomplete JSON")
+	}
+	if strings.HasPrefix(err.Error(), "json: unknown field ") {
+		return errors.New(fmt.Sprintf("unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field ")))
+	}
+
+	return errors.Wrap(err, "error decoding request body")
+}
+
+// jsonTypeName returns the name of the JSON type the Go type is decoded from.
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Ptr:
+		return jsonTypeName(t.Elem())
+	}
+	return "object"
+}
+
+// Validate checks the "validate" tags of all fields of the given struct and of all nested structs. The tag contains a
+// comma separated list of these rules:
+//   - required: The value must not be the zero value, slices and maps must not be empty
+//   - omitempty: Skips all other rules when the value is the zero value
+//   - min=<n>, max=<n>: Minimum/maximum value of numbers, length of strings or number of elements of slices and maps
+//   - oneof=<a> <b> ...: The string must be one of the space separated values
+//
+// All violations are returned in one error, the fields are named after their JSON names (e.g. "tasks[2].geometry").
+func Validate(v interface{}) error {
+	violations := validateValue(reflect.ValueOf(v), "")
+	if len(violations) != 0 {
+		return errors.New(strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+func validateValue(value reflect.Value, path string) []string {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return validateValue(value.Elem(), path)
+	case reflect.Slice, reflect.Array:
+		violations := make([]string, 0)
+		for i := 0; i < value.Len(); i++ {
+			violations = append(violations, validateValue(value.Index(i), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return violations
+	case reflect.Struct:
+		return validateStruct(value, path)
+	}
+	return nil
+}
+
+func validateStruct(value reflect.Value, path string) []string {
+	violations := make([]string, 0)
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+
+		fieldPath := jsonFieldName(field)
+		if path != "" {
+			fieldPath = path + "." + fieldPath
+		}
+
+		fieldValue := value.Field(i)
+		violations = append(violations, validateField(fieldValue, fieldPath, field.Tag.Get("validate"))...)
+		violations = append(violations, validateValue(fieldValue, fieldPath)...)
+	}
+
+	return violations
+}
+
+func validateField(value reflect.Value, path string, tag string) []string {
+	if tag == "" {
+		return nil
+	}
+
+	violations := make([]string, 0)
+	for _, rule := range strings.Split(tag, ",") {
+		name, param := rule, ""
+		if i := strings.Index(rule, "="); i != -1 {
+			name, param = rule[:i], rule[i+1:]
+		}
+
+		switch name {
+		case "omitempty":
+			if value.IsZero() {
+				return violations
+			}
+		case "required":
+			if value.IsZero() || ((value.Kind() == reflect.Slice || value.Kind() == reflect.Map) && value.Len() == 0) {
+				violations = append(violations, fmt.Sprintf("field '%s' is required", path))
+			}
+		case "min", "max":
+			limit, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				panic(fmt.Sprintf("invalid validation rule '%s' of field '%s'", rule, path))
+			}
+
+			size, message := validationSize(value)
+			if name == "min" && size < limit {
+				violations = append(violations, fmt.Sprintf(message, path, "at least", param))
+			} else if name == "max" && size > limit {
+				violations = append(violations, fmt.Sprintf(message, path, "at most", param))
+			}
+		case "oneof":
+			allowed := strings.Split(param, " ")
+			valid := false
+			for _, a := range allowed {
+				valid = valid || value.String() == a
+			}
+			if !valid {
+				violations = append(violations, fmt.Sprintf("field '%s' must be one of '%s' but was '%s'", path, strings.Join(allowed, "', '"), value.String()))
+			}
+		default:
+			panic(fmt.Sprintf("unknown validation rule '%s' of field '%s'", rule, path))
+		}
+	}
+
+	return violations
+}
+
+// validationSize returns the value, which is compared by the "min" and "max" rules, and the format of the error message
+// (with placeholders for the field, "at least"/"at most" and the limit).
+func validationSize(value reflect.Value) (float64, string) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), "field '%s' must be %s %s"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), "field '%s' must be %s %s"
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), "field '%s' must be %s %s"
+	case reflect.String:
+		return float64(len(value.String())), "field '%s' must be %s %s characters long"
+	}
+	return float64(value.Len()), "field '%s' must contain %s %s elements"
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,130 @@
+package watch
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/hauke96/simple-task-manager/server/notification"
+	"github.com/hauke96/simple-task-manager/server/project"
+	"github.com/hauke96/simple-task-manager/server/util"
+)
+
+// Watchers get a notification whenever the progress of the project reaches a multiple of this (in percent)
+const progressMilestone = 25
+
+// Watch is the subscription of a user to a public project to follow its progress without being a member.
+type Watch struct {
+	ProjectId string    `json:"projectId"`
+	UserId    string    `json:"userId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type WatchService struct {
+	*util.Logger
+	store               *storePg
+	projectService      *project.ProjectService
+	notificationService *notification.NotificationService
+}
+
+func Init(ctx context.Context, tx *sql.Tx, logger *util.Logger, projectService *project.ProjectService, notificationService *notification.NotificationService) *WatchService {
+	return &WatchService{
+		Logger:              logger,
+		store:               getStore(ctx, tx, logger),
+		projectService:      projectService,
+		notificationService: notificationService,
+	}
+}
+
+// Watch lets the requesting user watch the project, which has to be public. Watching doesn't make the user a member,
+// so tasks can't be claimed. Milestones reached before are not notified. Watching a project again has no effect.
+func (s *WatchService) Watch(projectId string, requestingUserId string) (*Watch, error) {
+	p, err := s.projectService.GetPublicProject(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	watch, err := s.store.addWatch(projectId, requestingUserId, reachedMilestone(p))
+	if err != nil {
+		return nil, err
+	}
+	s.Log("User %s watches project %s", requestingUserId, projectId)
+
+	return watch, nil
+}
+
+// Unwatch removes the watch of the requesting user. Removing a not existing watch has no effect, so that users can
+// still stop watching projects which aren't public anymore.
+func (s *WatchService) Unwatch(projectId string, requestingUserId string) error {
+	err := s.store.removeWatch(projectId, requestingUserId)
+	if err != nil {
+		return err
+	}
+	s.Log("User %s doesn't watch project %s anymore", requestingUserId, projectId)
+
+	return nil
+}
+
+// GetWatchedProjects returns the projects the user watches, the latest watched first. Projects which aren't public
+// anymore are left out. Like for all non-members, the users of the projects are not part of the result.
+func (s *WatchService) GetWatchedProjects(userId string) ([]*project.Project, error) {
+	projectIds, err := s.store.getWatchedProjectIds(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	projects := make([]*project.Project, 0, len(projectIds))
+	for _, projectId := range projectIds {
+		p, err := s.projectService.GetPublicProject(projectId)
+		if err != nil {
+			return nil, err
+		}
+
+		projects = append(projects, p)
+	}
+
+	return projects, nil
+}
+
+// NotifyProgress adds a notification for every watcher of the public project, which hasn't been notified about the
+// currently reached milestone (see "progressMilestone") yet. The project has to contain the process point metadata.
+// When the progress went back (e.g. because tasks have been added), the milestone is notified again once it's reached
+// again. The added notifications are returned, e.g. to send them via websocket.
+func (s *WatchService) NotifyProgress(p *project.Project) ([]*notification.Notification, error) {
+	notifications := make([]*notification.Notification, 0)
+	if !p.Public {
+		return notifications, nil
+	}
+
+	milestone := reachedMilestone(p)
+	watchers, err := s.store.updateNotifiedProgress(p.Id, milestone)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, userId := range watchers {
+		n, err := s.notificationService.AddProjectProgressNotification(userId, p.Id, p.Name, milestone)
+		if err != nil {
+			return nil, err
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	return notifications, nil
+}
+
+// reachedMilestone returns the largest milestone (in percent) the progress of the project reached, 0 for projects
+// without process points.
+func reachedMilestone(p *project.Project) int {
+	if p.TotalProcessPoints <= 0 {
+		return 0
+	}
+
+	percent := p.DoneProcessPoints * 100 / p.TotalProcessPoints
+	if percent > 100 {
+		percent = 100
+	}
+
+	return percent / progressMilestone * progressMilestone
+}
@@ -0,0 +1,133 @@
+package watch
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+)
+
+type storePg struct {
+	*util.Logger
+	ctx          context.Context
+	tx           *sql.Tx
+	table        database.Table
+	projectTable database.Table
+}
+
+var (
+	returnValues = "project_id, user_id, created_at"
+)
+
+func getStore(ctx context.Context, tx *sql.Tx, logger *util.Logger) *storePg {
+	return &storePg{
+		Logger:       logger,
+		ctx:          ctx,
+		tx:           tx,
+		table:        database.TableProjectWatchers,
+		projectTable: database.TableProjects,
+	}
+}
+
+// addWatch adds the watch with the given milestone as already notified. An existing watch is returned unchanged.
+func (s *storePg) addWatch(projectId string, userId string, notifiedProgress int) (*Watch, error) {
+	query := fmt.Sprintf(`INSERT INTO %s(project_id, user_id, notified_progress) VALUES($1, $2, $3)
+ON CONFLICT (project_id, user_id) DO UPDATE SET project_id=EXCLUDED.project_id
+RETURNING %s;`, s.table, returnValues)
+	s.LogQuery(query, projectId, userId, notifiedProgress)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+
+	var projectIdInt int
+	watch := &Watch{}
+	err := s.tx.QueryRowContext(ctx, query, projectId, userId, notifiedProgress).Scan(&projectIdInt, &watch.UserId, &watch.CreatedAt)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error adding watch of user %s for project %s", userId, projectId)
+	}
+	watch.ProjectId = strconv.Itoa(projectIdInt)
+
+	return watch, nil
+}
+
+func (s *storePg) removeWatch(projectId string, userId string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE project_id=$1 AND user_id=$2;", s.table)
+	s.LogQuery(query, projectId, userId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	_, err := s.tx.ExecContext(ctx, query, projectId, userId)
+	if err != nil {
+		return errors.Wrapf(err, "error removing watch of user %s for project %s", userId, projectId)
+	}
+
+	return nil
+}
+
+// getWatchedProjectIds returns the IDs of the public projects the user watches, the latest watched first.
+func (s *storePg) getWatchedProjectIds(userId string) ([]string, error) {
+	query := fmt.Sprintf("SELECT w.project_id FROM %s w JOIN %s p ON p.id = w.project_id WHERE w.user_id=$1 AND p.public ORDER BY w.created_at DESC, w.project_id;", s.table, s.projectTable)
+	s.LogQuery(query, userId)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting watched projects of user %s", userId)
+	}
+	defer rows.Close()
+
+	projectIds := make([]string, 0)
+	for rows.Next() {
+		var projectId int
+
+		err = rows.Scan(&projectId)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan watched project")
+		}
+
+		projectIds = append(projectIds, strconv.Itoa(projectId))
+	}
+
+	return projectIds, nil
+}
+
+// updateNotifiedProgress sets the notified milestone of all watchers of the project to the given one and returns the
+// watchers, whose previous milestone was lower. Watchers with a higher milestone are only updated, so that they get
+// notified again when the progress increases again.
+func (s *storePg) updateNotifiedProgress(projectId string, milestone int) ([]string, error) {
+	query := fmt.Sprintf(`UPDATE %s w SET notified_progress=$2
+FROM %s old
+WHERE w.project_id=$1 AND w.notified_progress<>$2 AND old.project_id = w.project_id AND old.user_id = w.user_id
+RETURNING w.user_id, old.notified_progress < $2;`, s.table, s.table)
+	s.LogQuery(query, projectId, milestone)
+
+	ctx, cancel := database.QueryContext(s.ctx, s.Logger, query)
+	defer cancel()
+	rows, err := s.tx.QueryContext(ctx, query, projectId, milestone)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error updating notified progress of watchers of project %s", projectId)
+	}
+	defer rows.Close()
+
+	userIds := make([]string, 0)
+	for rows.Next() {
+		var userId string
+		var increased bool
+
+		err = rows.Scan(&userId, &increased)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not scan watcher")
+		}
+
+		if increased {
+			userIds = append(userIds, userId)
+		}
+	}
+
+	return userIds, nil
+}
@@ -0,0 +1,208 @@
+package watch
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/hauke96/sigolo"
+	"github.com/hauke96/simple-task-manager/server/config"
+	"github.com/hauke96/simple-task-manager/server/database"
+	"github.com/hauke96/simple-task-manager/server/notification"
+	"github.com/hauke96/simple-task-manager/server/permission"
+	"github.com/hauke96/simple-task-manager/server/project"
+	"github.com/hauke96/simple-task-manager/server/quota"
+	"github.com/hauke96/simple-task-manager/server/task"
+	"github.com/hauke96/simple-task-manager/server/test"
+	"github.com/hauke96/simple-task-manager/server/util"
+	"github.com/pkg/errors"
+
+	_ "github.com/lib/pq" // Make driver "postgres" usable
+)
+
+var (
+	tx             *sql.Tx
+	s              *WatchService
+	projectService *project.ProjectService
+	h              *test.TestHelper
+)
+
+func TestMain(m *testing.M) {
+	h = &test.TestHelper{
+		Setup: setup,
+	}
+
+	m.Run()
+}
+
+func setup() {
+	config.LoadConfig("../config/test.json")
+	test.InitWithDummyData()
+	sigolo.LogLevel = sigolo.LOG_DEBUG
+
+	logger := util.NewLogger()
+
+	ctx := context.Background()
+
+	var err error
+	tx, err = database.GetTransaction(ctx, logger)
+	if err != nil {
+		panic(err)
+	}
+
+	h.Tx = tx
+	permissionService := permission.Init(ctx, tx, logger)
+	taskService := task.Init(ctx, tx, logger, permissionService)
+	projectService = project.Init(ctx, tx, logger, taskService, permissionService, quota.Init(ctx, tx, logger))
+	s = Init(ctx, tx, logger, projectService, notification.Init(ctx, tx, logger))
+}
+
+func TestWatch(t *testing.T) {
+	h.Run(t, func() error {
+		// Only public projects can be watched
+		_, err := s.Watch("3", "Peter")
+		if err == nil {
+			return errors.New("Project 3 is not public and should not be watchable")
+		}
+
+		_, err = projectService.UpdatePublic("3", true, "Otto")
+		if err != nil {
+			return err
+		}
+
+		watch, err := s.Watch("3", "Peter")
+		if err != nil {
+			return err
+		}
+		if watch.ProjectId != "3" || watch.UserId != "Peter" {
+			return errors.New(fmt.Sprintf("Watch does not match: %#v", watch))
+		}
+
+		// Watching again has no effect
+		_, err = s.Watch("3", "Peter")
+		if err != nil {
+			return err
+		}
+
+		projects, err := s.GetWatchedProjects("Peter")
+		if err != nil {
+			return err
+		}
+		if len(projects) != 1 || projects[0].Id != "3" {
+			return errors.New(fmt.Sprintf("Peter should watch project 3: %#v", projects))
+		}
+		if len(projects[0].Users) != 0 {
+			return errors.New("Watchers should not see the members of the project")
+		}
+
+		// Watching doesn't make Peter a member
+		_, err = projectService.GetProject("3", "Peter")
+		if err == nil {
+			return errors.New("Peter should not be a member of project 3")
+		}
+
+		err = s.Unwatch("3", "Peter")
+		if err != nil {
+			return err
+		}
+
+		projects, err = s.GetWatchedProjects("Peter")
+		if err != nil {
+			return err
+		}
+		if len(projects) != 0 {
+			return errors.New(fmt.Sprintf("Peter should not watch any project anymore: %#v", projects))
+		}
+
+		return nil
+	})
+}
+
+func TestNotifyProgress(t *testing.T) {
+	h.Run(t, func() error {
+		_, err := projectService.UpdatePublic("3", true, "Otto")
+		if err != nil {
+			return err
+		}
+
+		_, err = s.Watch("3", "Peter")
+		if err != nil {
+			return err
+		}
+
+		p, err := projectService.GetProject("3", "Otto")
+		if err != nil {
+			return err
+		}
+
+		// 345 of 2000 points, no milestone reached
+		notifications, err := s.NotifyProgress(p)
+		if err != nil {
+			return err
+		}
+		if len(notifications) != 0 {
+			return errors.New(fmt.Sprintf("No milestone has been reached: %#v", notifications))
+		}
+
+		p.DoneProcessPoints = 1000
+		notifications, err = s.NotifyProgress(p)
+		if err != nil {
+			return err
+		}
+		if len(notifications) != 1 || notifications[0].UserId != "Peter" || notifications[0].Type != notification.TypeProjectProgress || notifications[0].Comment != "50" {
+			return errors.New(fmt.Sprintf("Peter should be notified about 50%%: %#v", notifications))
+		}
+
+		// Every milestone is only notified once
+		notifications, err = s.NotifyProgress(p)
+		if err != nil {
+			return err
+		}
+		if len(notifications) != 0 {
+			return errors.New(fmt.Sprintf("Milestone should not be notified twice: %#v", notifications))
+		}
+
+		// Going back is not notified but reaching the milestone again is
+		p.DoneProcessPoints = 345
+		notifications, err = s.NotifyProgress(p)
+		if err != nil {
+			return err
+		}
+		if len(notifications) != 0 {
+			return errors.New(fmt.Sprintf("Going back should not be notified: %#v", notifications))
+		}
+
+		p.DoneProcessPoints = 1000
+		notifications, err = s.NotifyProgress(p)
+		if err != nil {
+			return err
+		}
+		if len(notifications) != 1 {
+			return errors.New(fmt.Sprintf("Milestone reached again should be notified: %#v", notifications))
+		}
+
+		return nil
+	})
+}
+
+func TestReachedMilestone(t *testing.T) {
+	milestones := map[[2]int]int{
+		{0, 0}:      0,
+		{0, 100}:    0,
+		{24, 100}:   0,
+		{25, 100}:   25,
+		{74, 100}:   50,
+		{99, 100}:   75,
+		{100, 100}:  100,
+		{120, 100}:  100,
+		{345, 2000}: 0,
+	}
+
+	for points, expected := range milestones {
+		milestone := reachedMilestone(&project.Project{DoneProcessPoints: points[0], TotalProcessPoints: points[1]})
+		if milestone != expected {
+			t.Errorf("Milestone of %d/%d should be %d but was %d", points[0], points[1], expected, milestone)
+		}
+	}
+}
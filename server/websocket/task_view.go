@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"sort"
+	"sync"
+)
+
+// TaskViewers are the users currently viewing a task (e.g. its detail view), so that others can see that someone is
+// probably about to work on it. This is just a hint, the task is not locked in any way.
+type TaskViewers struct {
+	TaskId string   `json:"taskId"`
+	Users  []string `json:"users"`
+}
+
+type taskView struct {
+	taskId    string
+	projectId string
+	members   []string // Users informed about changes of the viewers
+}
+
+var (
+	// The task each connection currently views. A connection views at most one task, so that clients don't have to
+	// stop viewing the previous task explicitly.
+	taskViews      = make(map[*connection]*taskView)
+	taskViewsMutex = &sync.Mutex{}
+)
+
+// GetTaskViewers returns the viewers of all viewed tasks of the project.
+func GetTaskViewers(projectId string) []*TaskViewers {
+	taskViewsMutex.Lock()
+	defer taskViewsMutex.Unlock()
+
+	taskIds := make([]string, 0)
+	for _, view := range taskViews {
+		if view.projectId == projectId && !containsUid(taskIds, view.taskId) {
+			taskIds = append(taskIds, view.taskId)
+		}
+	}
+	sort.Strings(taskIds)
+
+	result := make([]*TaskViewers, len(taskIds))
+	for i, taskId := range taskIds {
+		result[i] = getViewers(taskId)
+	}
+	return result
+}
+
+// viewTask marks the task as viewed by the user of the connection and informs all members of the project. An empty
+// task ID stops viewing the current task.
+func (s *WebsocketSender) viewTask(c *connection, taskId string, verifyTaskView TaskViewVerifier) {
+	if taskId == "" {
+		s.stopViewing(c)
+		return
+	}
+
+	projectId, members, err := verifyTaskView(c.uid, taskId)
+	if err != nil {
+		s.Err("User %s is not allowed to view task %s: %s", c.uid, taskId, err.Error())
+		s.sendControl(c, MessageType_Error, "viewing task not allowed")
+		return
+	}
+
+	view := &taskView{
+		taskId:    taskId,
+		projectId: projectId,
+		members:   members,
+	}
+
+	taskViewsMutex.Lock()
+	previousView := taskViews[c]
+	taskViews[c] = view
+	taskViewsMutex.Unlock()
+
+	if previousView != nil && previousView.taskId != taskId {
+		s.sendViewers(previousView)
+	}
+	s.sendViewers(view)
+}
+
+// stopViewing removes the viewed task of the connection, e.g. when it's closed, and informs all members of the project.
+func (s *WebsocketSender) stopViewing(c *connection) {
+	taskViewsMutex.Lock()
+	view, ok := taskViews[c]
+	delete(taskViews, c)
+	taskViewsMutex.Unlock()
+
+	if ok {
+		s.sendViewers(view)
+	}
+}
+
+// sendViewers sends the current viewers of the task of the view to the members of its project. These messages are not
+// stored in the event log, since the viewers are outdated after a reconnect anyway.
+func (s *WebsocketSender) sendViewers(view *taskView) {
+	taskViewsMutex.Lock()
+	viewers := getViewers(view.taskId)
+	taskViewsMutex.Unlock()
+
+	s.deliver([]Message{{Type: MessageType_TaskViewers, ProjectId: view.projectId, Data: viewers}}, view.members...)
+}
+
+// getViewers returns the distinct users viewing the task. The "taskViewsMutex" must be locked by the caller.
+func getViewers(taskId string) *TaskViewers {
+	users := make([]string, 0)
+	for c, view := range taskViews {
+		if view.taskId == taskId && !containsUid(users, c.uid) {
+			users = append(users, c.uid)
+		}
+	}
+	sort.Strings(users)
+
+	return &TaskViewers{
+		TaskId: taskId,
+		Users:  users,
+	}
+}
@@ -4,19 +4,90 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/hauke96/simple-task-manager/server/util"
 	"net/http"
+	"sync"
+	"time"
 )
 
+// Events sent by the server
 const (
 	MessageType_ProjectAdded       = "project_added"
 	MessageType_ProjectUpdated     = "project_updated"
 	MessageType_ProjectDeleted     = "project_deleted"
 	MessageType_ProjectUserRemoved = "project_user_removed"
+	MessageType_ProjectCompleted   = "project_completed"
+	MessageType_JoinRequested      = "project_join_requested"
+	MessageType_MergeRequested     = "project_merge_requested"
+	MessageType_TaskFlagged        = "task_flagged"
+	MessageType_HandoverRequested  = "task_handover_requested" // Sent to the user the task is offered to
+	MessageType_HandoverAccepted   = "task_handover_accepted"  // Sent to the user who offered the task
+	MessageType_HandoverRemoved    = "task_handover_removed"   // Sent to the other user when the handover has been declined or withdrawn
+	MessageType_TaskViewers        = "task_viewers"            // Users currently viewing a task changed, not stored in the event log
+	MessageType_Notification       = "notification"            // New entry in the inbox of the user, not bound to any project subscription
+)
+
+// Control messages sent by the server as answer to client messages
+const (
+	MessageType_Authenticated  = "authenticated"
+	MessageType_Subscribed     = "subscribed"
+	MessageType_Unsubscribed   = "unsubscribed"
+	MessageType_ResyncRequired = "resync_required" // Missed events can't be replayed, the client has to reload all data
+	MessageType_Error          = "error"
+)
+
+// Messages sent by the client
+const (
+	ClientMessageType_Auth        = "auth"
+	ClientMessageType_Subscribe   = "subscribe"
+	ClientMessageType_Unsubscribe = "unsubscribe"
+	ClientMessageType_Resume      = "resume"
+	ClientMessageType_View        = "view"
 )
 
 type Message struct {
+	// Increasing ID of events, which is used by clients to resume after reconnects. Not set for control messages.
+	Id int64 `json:"id,omitempty"`
 	// One of the "MessageType" strings
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
+	Type string `json:"type"`
+	// The project the event belongs to. Clients with subscriptions only get events of their subscribed projects.
+	ProjectId string      `json:"projectId,omitempty"`
+	Data      interface{} `json:"data"`
+}
+
+type ClientMessage struct {
+	// One of the "ClientMessageType" strings
+	Type        string `json:"type"`
+	Token       string `json:"token,omitempty"`       // Used by "auth"
+	ProjectId   string `json:"projectId,omitempty"`   // Used by "subscribe" and "unsubscribe"
+	LastEventId int64  `json:"lastEventId,omitempty"` // Used by "resume"
+	TaskId      string `json:"taskId,omitempty"`      // Used by "view"
+}
+
+// Authenticator verifies the given token and returns the ID of the user.
+type Authenticator func(token string) (string, error)
+
+// SubscriptionVerifier returns an error when the user is not allowed to receive the events of the project.
+type SubscriptionVerifier func(uid string, projectId string) error
+
+// TaskViewVerifier returns an error when the user is not allowed to view the task. Otherwise it returns the project of
+// the task and its members, who get informed about the users viewing the task.
+type TaskViewVerifier func(uid string, taskId string) (string, []string, error)
+
+type connection struct {
+	conn *websocket.Conn
+	uid  string
+	// IDs of subscribed projects. Without subscriptions, the events of all projects of the user are sent.
+	subscriptions map[string]bool
+	mutex         sync.Mutex
+}
+
+// listener receives the events of a user within this process, e.g. to forward them via gRPC streams.
+type listener struct {
+	messages chan Message
+}
+
+type loggedEvent struct {
+	message Message
+	uids    []string
 }
 
 var (
@@ -24,13 +95,30 @@ var (
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
 		// Yes, the documentation says that this could lead to a CSRF vulnerability. Since the STM Clients doesn't use
-		// cookies but values from the local storage, this is not a problem. Also: The websocket connection is only used
-		// to SEND things and not to RECEIVE things.
+		// cookies but values from the local storage, this is not a problem. Also: Clients authenticate with their token
+		// and only send control messages, all actual changes happen via the REST API.
 		CheckOrigin: func(r *http.Request) bool { return true },
 	}
 
+	// Time a client has to send the "auth" message after connecting
+	authTimeout = 10 * time.Second
+
 	// One user should be able to have multiple open websocket connections
-	connections = make(map[string][]*websocket.Conn, 0)
+	connections      = make(map[string][]*connection, 0)
+	connectionsMutex = &sync.Mutex{}
+
+	listeners      = make(map[string][]*listener, 0)
+	listenersMutex = &sync.Mutex{}
+	// Number of events a listener can lag behind before further events are dropped
+	listenerBufferSize = 100
+
+	// The most recent events, used to replay events clients missed while being disconnected
+	eventLog      = make([]*loggedEvent, 0)
+	eventLogSize  = 1000
+	eventLogMutex = &sync.Mutex{}
+	// The IDs start with the current time (in ms) so that IDs of events after a restart are larger than the IDs from
+	// before. Clients with such an old ID then get a "resync_required" message when resuming.
+	lastEventId = time.Now().UnixNano() / int64(time.Millisecond)
 )
 
 type WebsocketSender struct {
@@ -43,7 +131,9 @@ func Init(logger *util.Logger) *WebsocketSender {
 	}
 }
 
-func (s *WebsocketSender) GetWebsocketConnection(w http.ResponseWriter, r *http.Request, uid string) {
+// GetWebsocketConnection upgrades the request to a websocket connection and handles all messages of the client. When
+// the uid is empty, the client has to authenticate with an "auth" message first.
+func (s *WebsocketSender) GetWebsocketConnection(w http.ResponseWriter, r *http.Request, uid string, authenticate Authenticator, verifySubscription SubscriptionVerifier, verifyTaskView TaskViewVerifier) {
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		//sigolo.Error("Could not upgrade response writer and request to websocket connection")
@@ -52,52 +142,300 @@ func (s *WebsocketSender) GetWebsocketConnection(w http.ResponseWriter, r *http.
 		return
 	}
 
-	if connections[uid] == nil {
-		connections[uid] = make([]*websocket.Conn, 0)
+	c := &connection{
+		conn:          ws,
+		uid:           uid,
+		subscriptions: make(map[string]bool),
+	}
+
+	if uid != "" {
+		addConnection(c)
 	}
 
-	connections[uid] = append(connections[uid], ws)
+	go s.handleClientMessages(c, authenticate, verifySubscription, verifyTaskView)
+}
+
+// handleClientMessages reads the messages of the client until the connection gets closed.
+func (s *WebsocketSender) handleClientMessages(c *connection, authenticate Authenticator, verifySubscription SubscriptionVerifier, verifyTaskView TaskViewVerifier) {
+	defer s.closeConnection(c)
+
+	if c.uid == "" {
+		c.conn.SetReadDeadline(time.Now().Add(authTimeout))
+	}
+
+	for {
+		var message ClientMessage
+		err := c.conn.ReadJSON(&message)
+		if err != nil {
+			// Happens every time a client disconnects, so no error logging here
+			s.Debug("Stop reading from websocket: %s", err.Error())
+			return
+		}
+
+		if message.Type == ClientMessageType_Auth {
+			if c.uid != "" {
+				s.sendControl(c, MessageType_Error, "already authenticated")
+				continue
+			}
+
+			uid, err := authenticate(message.Token)
+			if err != nil {
+				s.Err("Websocket authentication failed: %s", err.Error())
+				// No further information to caller (which is a potential attacker)
+				s.sendControl(c, MessageType_Error, "authentication failed")
+				return
+			}
+
+			c.uid = uid
+			c.conn.SetReadDeadline(time.Time{})
+			addConnection(c)
+			s.sendControl(c, MessageType_Authenticated, uid)
+			continue
+		}
+
+		if c.uid == "" {
+			s.sendControl(c, MessageType_Error, "not authenticated")
+			return
+		}
+
+		switch message.Type {
+		case ClientMessageType_Subscribe:
+			err = verifySubscription(c.uid, message.ProjectId)
+			if err != nil {
+				s.Err("User %s is not allowed to subscribe to project %s: %s", c.uid, message.ProjectId, err.Error())
+				s.sendControl(c, MessageType_Error, "subscription not allowed")
+				continue
+			}
+
+			c.mutex.Lock()
+			c.subscriptions[message.ProjectId] = true
+			c.mutex.Unlock()
+
+			s.sendControl(c, MessageType_Subscribed, message.ProjectId)
+		case ClientMessageType_Unsubscribe:
+			c.mutex.Lock()
+			delete(c.subscriptions, message.ProjectId)
+			c.mutex.Unlock()
+
+			s.sendControl(c, MessageType_Unsubscribed, message.ProjectId)
+		case ClientMessageType_Resume:
+			s.replay(c, message.LastEventId)
+		case ClientMessageType_View:
+			s.viewTask(c, message.TaskId, verifyTaskView)
+		default:
+			s.sendControl(c, MessageType_Error, "unknown message type")
+		}
+	}
 }
 
 func (s *WebsocketSender) Send(message Message, uids ...string) {
 	s.SendAll([]Message{message}, uids...)
 }
 
+// SendAll assigns an ID to each message, stores them in the event log and sends them to all connections of the given
+// users which are subscribed to the project of the message.
 func (s *WebsocketSender) SendAll(messages []Message, uids ...string) {
+	eventLogMutex.Lock()
+	for i := range messages {
+		lastEventId++
+		messages[i].Id = lastEventId
+
+		eventLog = append(eventLog, &loggedEvent{
+			message: messages[i],
+			uids:    uids,
+		})
+	}
+	if len(eventLog) > eventLogSize {
+		eventLog = eventLog[len(eventLog)-eventLogSize:]
+	}
+	eventLogMutex.Unlock()
+
+	s.deliver(messages, uids...)
+}
+
+// deliver sends the messages to all connections and listeners of the given users without adding them to the event log.
+func (s *WebsocketSender) deliver(messages []Message, uids ...string) {
 	for _, uid := range uids {
-		userConnections := connections[uid]
+		for _, c := range getConnections(uid) {
+			s.write(c, c.filter(messages))
+		}
+		for _, l := range getListeners(uid) {
+			s.notify(l, uid, messages)
+		}
+	}
+}
 
-		for i := 0; i < len(userConnections); i++ {
-			conn := userConnections[i]
+// Listen registers a listener for all events of the given user. The returned function removes the listener again and
+// must be called when the caller stops reading from the channel.
+func (s *WebsocketSender) Listen(uid string) (<-chan Message, func()) {
+	l := &listener{
+		messages: make(chan Message, listenerBufferSize),
+	}
 
-			// Send data as JSON
-			err := conn.WriteJSON(messages)
+	listenersMutex.Lock()
+	listeners[uid] = append(listeners[uid], l)
+	listenersMutex.Unlock()
 
-			if err != nil {
-				// Use Debug logging because this will happen a lot (e.g. every time someone reloads the web client)
-				s.Debug("ERROR: Unable to send to websocket")
-				s.Debug("ERROR: " + err.Error())
-
-				//sigolo.Error("Unable to send to websocket, close it. Error: %s", err.Error())
-				s.Stack(err)
-
-				err := conn.Close()
-				if err != nil {
-					s.Debug("ERROR: Wasn't even able to close it")
-					s.Debug("ERROR: " + err.Error())
-					//sigolo.Error("Wasn't even able to close it: %s", err.Error())
-					s.Stack(err)
-
-					// Remove the closed connection from the list of connections:
-					userConnections[i] = userConnections[len(userConnections)-1] // overwrite i-th element by the last element
-					userConnections[len(userConnections)-1] = nil                // delete the now duplicate last element from slice
-					userConnections = userConnections[:len(userConnections)-1]   // reduce slice size by 1
-					i--                                                          // fix index so that we don't skip the i-th connection we just copied
-				}
-			}
+	return l.messages, func() {
+		removeListener(uid, l)
+	}
+}
 
-			// Update connection list in case some connections have been removed
-			connections[uid] = userConnections
+// notify passes the messages to the listener without blocking. Events are dropped when the listener is too slow.
+func (s *WebsocketSender) notify(l *listener, uid string, messages []Message) {
+	for _, m := range messages {
+		select {
+		case l.messages <- m:
+		default:
+			s.Debug("ERROR: Listener of user %s is too slow, drop event %d", uid, m.Id)
 		}
 	}
 }
+
+// replay sends all logged events after the given ID to the client. When events might be missing (e.g. because they
+// are too old or the server restarted), the client gets a "resync_required" message instead.
+func (s *WebsocketSender) replay(c *connection, afterEventId int64) {
+	eventLogMutex.Lock()
+
+	if afterEventId > lastEventId ||
+		(len(eventLog) == 0 && afterEventId < lastEventId) ||
+		(len(eventLog) != 0 && afterEventId < eventLog[0].message.Id-1) {
+		eventLogMutex.Unlock()
+		s.Log("Events after %d not available anymore, client of user %s needs to resync", afterEventId, c.uid)
+		s.sendControl(c, MessageType_ResyncRequired, nil)
+		return
+	}
+
+	messages := make([]Message, 0)
+	for _, event := range eventLog {
+		if event.message.Id > afterEventId && containsUid(event.uids, c.uid) {
+			messages = append(messages, event.message)
+		}
+	}
+
+	eventLogMutex.Unlock()
+
+	s.Debug("Replay %d events after %d for user %s", len(messages), afterEventId, c.uid)
+	s.write(c, c.filter(messages))
+}
+
+func (s *WebsocketSender) sendControl(c *connection, messageType string, data interface{}) {
+	s.write(c, []Message{{Type: messageType, Data: data}})
+}
+
+// write sends the messages as JSON array to the client and closes the connection on errors.
+func (s *WebsocketSender) write(c *connection, messages []Message) {
+	if len(messages) == 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	err := c.conn.WriteJSON(messages)
+	c.mutex.Unlock()
+
+	if err != nil {
+		// Use Debug logging because this will happen a lot (e.g. every time someone reloads the web client)
+		s.Debug("ERROR: Unable to send to websocket, close it")
+		s.Debug("ERROR: " + err.Error())
+
+		s.closeConnection(c)
+	}
+}
+
+func (s *WebsocketSender) closeConnection(c *connection) {
+	removeConnection(c)
+	s.stopViewing(c)
+
+	err := c.conn.Close()
+	if err != nil {
+		s.Debug("ERROR: Unable to close websocket: %s", err.Error())
+	}
+}
+
+// filter returns the messages this connection is subscribed to.
+func (c *connection) filter(messages []Message) []Message {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.subscriptions) == 0 {
+		return messages
+	}
+
+	result := make([]Message, 0)
+	for _, m := range messages {
+		if m.ProjectId == "" || c.subscriptions[m.ProjectId] {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func containsUid(uids []string, uid string) bool {
+	for _, u := range uids {
+		if u == uid {
+			return true
+		}
+	}
+	return false
+}
+
+func addConnection(c *connection) {
+	connectionsMutex.Lock()
+	defer connectionsMutex.Unlock()
+
+	connections[c.uid] = append(connections[c.uid], c)
+}
+
+func removeConnection(c *connection) {
+	connectionsMutex.Lock()
+	defer connectionsMutex.Unlock()
+
+	userConnections := connections[c.uid]
+	for i, conn := range userConnections {
+		if conn == c {
+			connections[c.uid] = append(userConnections[:i], userConnections[i+1:]...)
+			break
+		}
+	}
+
+	if len(connections[c.uid]) == 0 {
+		delete(connections, c.uid)
+	}
+}
+
+// getConnections returns a copy of the connection list so that sending doesn't block other connections.
+func getConnections(uid string) []*connection {
+	connectionsMutex.Lock()
+	defer connectionsMutex.Unlock()
+
+	result := make([]*connection, len(connections[uid]))
+	copy(result, connections[uid])
+	return result
+}
+
+func removeListener(uid string, l *listener) {
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+
+	userListeners := listeners[uid]
+	for i, other := range userListeners {
+		if other == l {
+			listeners[uid] = append(userListeners[:i], userListeners[i+1:]...)
+			break
+		}
+	}
+
+	if len(listeners[uid]) == 0 {
+		delete(listeners, uid)
+	}
+}
+
+// getListeners returns a copy of the listener list of the user.
+func getListeners(uid string) []*listener {
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+
+	result := make([]*listener, len(listeners[uid]))
+	copy(result, listeners[uid])
+	return result
+}